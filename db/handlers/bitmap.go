@@ -0,0 +1,454 @@
+package handlers
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+
+	RESP "github.com/manish-singh-bisht/Redis-From-Scratch/db/resp"
+	store "github.com/manish-singh-bisht/Redis-From-Scratch/db/store"
+	tx "github.com/manish-singh-bisht/Redis-From-Scratch/db/transaction"
+)
+
+var errBitOffset = errors.New("ERR bit offset is not an integer or out of range")
+var errBitFieldType = errors.New("ERR Invalid bitfield type. Use something like i16 u8. Note that u64 is not supported but i64 is.")
+
+/*
+ 	* parseBitOffset parses the bit offset of SETBIT, GETBIT and BITFIELD, for BITFIELD a #n offset is n times the width
+	* of the field
+	* @param value []byte - the offset
+	* @param width int - the width of the field for a #n offset, 0 if it isn't allowed
+	* @return uint64 - the bit offset
+	* @return error - errBitOffset if it isn't an integer, is negative or is past BitOffsetMax
+*/
+func parseBitOffset(value []byte, width int) (uint64, error) {
+	multiplied := width > 0 && len(value) > 0 && value[0] == '#'
+	if multiplied {
+		value = value[1:]
+	}
+
+	offset, err := strconv.ParseInt(string(value), 10, 64)
+	if err != nil || offset < 0 {
+		return 0, errBitOffset
+	}
+	if multiplied {
+		if offset > store.BitOffsetMax/int64(width) {
+			return 0, errBitOffset
+		}
+		offset *= int64(width)
+	}
+	if offset > store.BitOffsetMax {
+		return 0, errBitOffset
+	}
+
+	return uint64(offset), nil
+}
+
+/*
+ 	* parseBitFieldType parses the type of a BITFIELD field, i1 to i64 or u1 to u63
+	* @param value []byte - the type
+	* @return bool - true for a signed field
+	* @return int - the width of the field
+	* @return error - errBitFieldType if the type is not valid
+*/
+func parseBitFieldType(value []byte) (bool, int, error) {
+	if len(value) < 2 {
+		return false, 0, errBitFieldType
+	}
+
+	signed := value[0] == 'i' || value[0] == 'I'
+	if !signed && value[0] != 'u' && value[0] != 'U' {
+		return false, 0, errBitFieldType
+	}
+
+	width, err := strconv.Atoi(string(value[1:]))
+	if err != nil || width < 1 || (signed && width > 64) || (!signed && width > 63) {
+		return false, 0, errBitFieldType
+	}
+
+	return signed, width, nil
+}
+
+/*
+ 	* bitFieldAofArgv leaves a BITFIELD with only GET operations out of the AOF, it changes nothing
+	* @param args []RESP.RESPMessage - the arguments of BITFIELD
+	* @return [][]byte - the command to append, nil if it only reads
+*/
+func bitFieldAofArgv(args []RESP.RESPMessage) [][]byte {
+	argv := [][]byte{[]byte("BITFIELD")}
+	writes := false
+	for _, arg := range args {
+		switch strings.ToUpper(string(arg.RESPValue)) {
+		case "SET", "INCRBY":
+			writes = true
+		}
+		argv = append(argv, arg.RESPValue)
+	}
+
+	if !writes {
+		return nil
+	}
+	return argv
+}
+
+/*
+ 	* handleSetBit handles the SETBIT command, SETBIT key offset value
+	* @param writer *RESP.Writer - the writer to write the response to
+	* @param args []RESP.RESPMessage - the arguments for the command
+	* @param store *store.Store - the store to get the data from
+	* @param clientID string - the client id
+	* @param txManager *tx.TxManager - the transaction manager
+	* @return error - the error if there is one
+	* @return integer - the bit the offset held before
+*/
+func handleSetBit(writer *RESP.Writer, args []RESP.RESPMessage, store *store.Store, clientID string, txManager *tx.TxManager) error {
+	if len(args) != 3 {
+		err := errWrongNumberOfArguments("SETBIT")
+		return HandleError(writer, []byte(err.Error()))
+	}
+
+	key := string(args[0].RESPValue)
+
+	offset, err := parseBitOffset(args[1].RESPValue, 0)
+	if err != nil {
+		return HandleError(writer, []byte(err.Error()))
+	}
+
+	bit := string(args[2].RESPValue)
+	if bit != "0" && bit != "1" {
+		return HandleError(writer, []byte("ERR bit is not an integer or out of range"))
+	}
+
+	old, err := store.SetBit(key, offset, int(bit[0]-'0'))
+	if err != nil {
+		return HandleError(writer, []byte(err.Error()))
+	}
+
+	signalModifiedKey(txManager, key)
+
+	return encodeInteger(writer, int64(old))
+}
+
+/*
+ 	* handleGetBit handles the GETBIT command, GETBIT key offset
+	* @param writer *RESP.Writer - the writer to write the response to
+	* @param args []RESP.RESPMessage - the arguments for the command
+	* @param store *store.Store - the store to get the data from
+	* @param clientID string - the client id
+	* @param txManager *tx.TxManager - the transaction manager
+	* @return error - the error if there is one
+	* @return integer - the bit, 0 past the end of the string or if the key doesn't exist
+*/
+func handleGetBit(writer *RESP.Writer, args []RESP.RESPMessage, store *store.Store, clientID string, txManager *tx.TxManager) error {
+	if len(args) != 2 {
+		err := errWrongNumberOfArguments("GETBIT")
+		return HandleError(writer, []byte(err.Error()))
+	}
+
+	offset, err := parseBitOffset(args[1].RESPValue, 0)
+	if err != nil {
+		return HandleError(writer, []byte(err.Error()))
+	}
+
+	bit, err := store.GetBit(string(args[0].RESPValue), offset)
+	if err != nil {
+		return HandleError(writer, []byte(err.Error()))
+	}
+
+	return encodeInteger(writer, int64(bit))
+}
+
+/*
+ 	* parseBitRangeUnit parses the BYTE or BIT that may follow the range of BITCOUNT and BITPOS
+	* @param value []byte - the unit
+	* @return bool - true for BIT
+	* @return error - errSyntax if it is neither
+*/
+func parseBitRangeUnit(value []byte) (bool, error) {
+	switch strings.ToUpper(string(value)) {
+	case "BYTE":
+		return false, nil
+	case "BIT":
+		return true, nil
+	}
+	return false, errSyntax
+}
+
+/*
+ 	* handleBitCount handles the BITCOUNT command, BITCOUNT key [start end [BYTE | BIT]]
+	* @param writer *RESP.Writer - the writer to write the response to
+	* @param args []RESP.RESPMessage - the arguments for the command
+	* @param store *store.Store - the store to get the data from
+	* @param clientID string - the client id
+	* @param txManager *tx.TxManager - the transaction manager
+	* @return error - the error if there is one
+	* @return integer - the number of bits set
+*/
+func handleBitCount(writer *RESP.Writer, args []RESP.RESPMessage, store *store.Store, clientID string, txManager *tx.TxManager) error {
+	if len(args) < 1 {
+		err := errWrongNumberOfArguments("BITCOUNT")
+		return HandleError(writer, []byte(err.Error()))
+	}
+	if len(args) == 2 || len(args) > 4 {
+		return HandleError(writer, []byte(errSyntax.Error()))
+	}
+
+	var start, end int64
+	var inBits bool
+	hasRange := len(args) > 1
+	if hasRange {
+		var err error
+		start, err = strconv.ParseInt(string(args[1].RESPValue), 10, 64)
+		if err != nil {
+			return HandleError(writer, []byte("ERR value is not an integer or out of range"))
+		}
+		end, err = strconv.ParseInt(string(args[2].RESPValue), 10, 64)
+		if err != nil {
+			return HandleError(writer, []byte("ERR value is not an integer or out of range"))
+		}
+		if len(args) == 4 {
+			if inBits, err = parseBitRangeUnit(args[3].RESPValue); err != nil {
+				return HandleError(writer, []byte(err.Error()))
+			}
+		}
+	}
+
+	count, err := store.BitCount(string(args[0].RESPValue), start, end, hasRange, inBits)
+	if err != nil {
+		return HandleError(writer, []byte(err.Error()))
+	}
+
+	return encodeInteger(writer, count)
+}
+
+/*
+ 	* handleBitPos handles the BITPOS command, BITPOS key bit [start [end [BYTE | BIT]]]
+	* @param writer *RESP.Writer - the writer to write the response to
+	* @param args []RESP.RESPMessage - the arguments for the command
+	* @param store *store.Store - the store to get the data from
+	* @param clientID string - the client id
+	* @param txManager *tx.TxManager - the transaction manager
+	* @return error - the error if there is one
+	* @return integer - the position of the first bit with the value, -1 if there is none
+*/
+func handleBitPos(writer *RESP.Writer, args []RESP.RESPMessage, store *store.Store, clientID string, txManager *tx.TxManager) error {
+	if len(args) < 2 {
+		err := errWrongNumberOfArguments("BITPOS")
+		return HandleError(writer, []byte(err.Error()))
+	}
+	if len(args) > 5 {
+		return HandleError(writer, []byte(errSyntax.Error()))
+	}
+
+	bit := string(args[1].RESPValue)
+	if bit != "0" && bit != "1" {
+		return HandleError(writer, []byte("ERR The bit argument must be 1 or 0."))
+	}
+
+	var start, end int64
+	var inBits bool
+	var err error
+	hasStart, hasEnd := len(args) > 2, len(args) > 3
+	if hasStart {
+		if start, err = strconv.ParseInt(string(args[2].RESPValue), 10, 64); err != nil {
+			return HandleError(writer, []byte("ERR value is not an integer or out of range"))
+		}
+	}
+	if hasEnd {
+		if end, err = strconv.ParseInt(string(args[3].RESPValue), 10, 64); err != nil {
+			return HandleError(writer, []byte("ERR value is not an integer or out of range"))
+		}
+	}
+	if len(args) == 5 {
+		if inBits, err = parseBitRangeUnit(args[4].RESPValue); err != nil {
+			return HandleError(writer, []byte(err.Error()))
+		}
+	}
+
+	pos, err := store.BitPos(string(args[0].RESPValue), int(bit[0]-'0'), start, end, hasStart, hasEnd, inBits)
+	if err != nil {
+		return HandleError(writer, []byte(err.Error()))
+	}
+
+	return encodeInteger(writer, pos)
+}
+
+/*
+ 	* handleBitOp handles the BITOP command, BITOP AND | OR | XOR | NOT destkey key [key ...]
+	* @param writer *RESP.Writer - the writer to write the response to
+	* @param args []RESP.RESPMessage - the arguments for the command
+	* @param store *store.Store - the store to get the data from
+	* @param clientID string - the client id
+	* @param txManager *tx.TxManager - the transaction manager
+	* @return error - the error if there is one
+	* @return integer - the length of the string stored at destkey
+*/
+func handleBitOp(writer *RESP.Writer, args []RESP.RESPMessage, st *store.Store, clientID string, txManager *tx.TxManager) error {
+	if len(args) < 3 {
+		err := errWrongNumberOfArguments("BITOP")
+		return HandleError(writer, []byte(err.Error()))
+	}
+
+	var op store.BitOpKind
+	switch strings.ToUpper(string(args[0].RESPValue)) {
+	case "AND":
+		op = store.BitOpAnd
+	case "OR":
+		op = store.BitOpOr
+	case "XOR":
+		op = store.BitOpXor
+	case "NOT":
+		op = store.BitOpNot
+	default:
+		return HandleError(writer, []byte(errSyntax.Error()))
+	}
+
+	dst := string(args[1].RESPValue)
+	keys := keyArgs(args[2:])
+	if op == store.BitOpNot && len(keys) != 1 {
+		return HandleError(writer, []byte("ERR BITOP NOT must be called with a single source key."))
+	}
+
+	length, err := st.BitOp(op, dst, keys)
+	if err != nil {
+		return HandleError(writer, []byte(err.Error()))
+	}
+
+	signalModifiedKey(txManager, dst)
+
+	return encodeInteger(writer, int64(length))
+}
+
+/*
+ 	* handleBitField handles the BITFIELD command,
+	* BITFIELD key [GET type offset] [SET type offset value] [INCRBY type offset increment] [OVERFLOW WRAP | SAT | FAIL] ...
+	* @param writer *RESP.Writer - the writer to write the response to
+	* @param args []RESP.RESPMessage - the arguments for the command
+	* @param store *store.Store - the store to get the data from
+	* @param clientID string - the client id
+	* @param txManager *tx.TxManager - the transaction manager
+	* @return error - the error if there is one
+	* @return array - the result of each operation, nil for one not done because of OVERFLOW FAIL
+*/
+func handleBitField(writer *RESP.Writer, args []RESP.RESPMessage, store *store.Store, clientID string, txManager *tx.TxManager) error {
+	return bitFieldGeneric(writer, args, store, txManager, "BITFIELD")
+}
+
+/*
+ 	* handleBitFieldRO handles the BITFIELD_RO command, BITFIELD_RO key [GET type offset ...], BITFIELD with only GET
+	* @param writer *RESP.Writer - the writer to write the response to
+	* @param args []RESP.RESPMessage - the arguments for the command
+	* @param store *store.Store - the store to get the data from
+	* @param clientID string - the client id
+	* @param txManager *tx.TxManager - the transaction manager
+	* @return error - the error if there is one
+	* @return array - the value of each field
+*/
+func handleBitFieldRO(writer *RESP.Writer, args []RESP.RESPMessage, store *store.Store, clientID string, txManager *tx.TxManager) error {
+	return bitFieldGeneric(writer, args, store, txManager, "BITFIELD_RO")
+}
+
+/*
+ 	* bitFieldGeneric parses and runs the operations of BITFIELD and BITFIELD_RO
+	* @param writer *RESP.Writer - the writer to write the response to
+	* @param args []RESP.RESPMessage - the arguments for the command
+	* @param st *store.Store - the store
+	* @param txManager *tx.TxManager - the transaction manager
+	* @param cmd string - BITFIELD or BITFIELD_RO, which only allows GET
+	* @return error - the error if there is one
+*/
+func bitFieldGeneric(writer *RESP.Writer, args []RESP.RESPMessage, st *store.Store, txManager *tx.TxManager, cmd string) error {
+	if len(args) < 1 {
+		err := errWrongNumberOfArguments(cmd)
+		return HandleError(writer, []byte(err.Error()))
+	}
+
+	key := string(args[0].RESPValue)
+
+	var ops []store.BitFieldOp
+	overflow := store.OverflowWrap
+	writes := false
+	for i := 1; i < len(args); {
+		subcommand := strings.ToUpper(string(args[i].RESPValue))
+
+		if subcommand == "OVERFLOW" {
+			if i+1 >= len(args) {
+				return HandleError(writer, []byte(errSyntax.Error()))
+			}
+			switch strings.ToUpper(string(args[i+1].RESPValue)) {
+			case "WRAP":
+				overflow = store.OverflowWrap
+			case "SAT":
+				overflow = store.OverflowSat
+			case "FAIL":
+				overflow = store.OverflowFail
+			default:
+				return HandleError(writer, []byte("ERR Invalid OVERFLOW type specified"))
+			}
+			i += 2
+			continue
+		}
+
+		op := store.BitFieldOp{Overflow: overflow}
+		switch subcommand {
+		case "GET":
+			op.Kind = store.BitFieldGet
+		case "SET":
+			op.Kind = store.BitFieldSet
+		case "INCRBY":
+			op.Kind = store.BitFieldIncrBy
+		default:
+			return HandleError(writer, []byte(errSyntax.Error()))
+		}
+
+		// GET takes a type and an offset, SET and INCRBY a value as well
+		need := 3
+		if op.Kind != store.BitFieldGet {
+			need = 4
+		}
+		if i+need > len(args) {
+			return HandleError(writer, []byte(errSyntax.Error()))
+		}
+
+		var err error
+		if op.Signed, op.Bits, err = parseBitFieldType(args[i+1].RESPValue); err != nil {
+			return HandleError(writer, []byte(err.Error()))
+		}
+		if op.Offset, err = parseBitOffset(args[i+2].RESPValue, op.Bits); err != nil {
+			return HandleError(writer, []byte(err.Error()))
+		}
+		if op.Kind != store.BitFieldGet {
+			if op.Value, err = strconv.ParseInt(string(args[i+3].RESPValue), 10, 64); err != nil {
+				return HandleError(writer, []byte("ERR value is not an integer or out of range"))
+			}
+			writes = true
+		}
+
+		ops = append(ops, op)
+		i += need
+	}
+
+	if writes && cmd == "BITFIELD_RO" {
+		return HandleError(writer, []byte("ERR BITFIELD_RO only supports the GET subcommand"))
+	}
+
+	results, done, err := st.BitField(key, ops)
+	if err != nil {
+		return HandleError(writer, []byte(err.Error()))
+	}
+
+	if writes {
+		signalModifiedKey(txManager, key)
+	}
+
+	elements := make([]RESP.RESPMessage, len(ops))
+	for i := range ops {
+		if !done[i] {
+			elements[i] = bulkStringMessage(nil)
+			continue
+		}
+		elements[i] = integerMessage(results[i])
+	}
+
+	return encodeArray(writer, elements)
+}
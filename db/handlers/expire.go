@@ -0,0 +1,299 @@
+package handlers
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	RESP "github.com/manish-singh-bisht/Redis-From-Scratch/db/resp"
+	store "github.com/manish-singh-bisht/Redis-From-Scratch/db/store"
+	tx "github.com/manish-singh-bisht/Redis-From-Scratch/db/transaction"
+)
+
+func errInvalidExpireTime(cmd string) error {
+	return fmt.Errorf("ERR invalid expire time in '%s' command", strings.ToLower(cmd))
+}
+
+/*
+ 	* handleExpire handles the EXPIRE command, EXPIRE key seconds [NX | XX | GT | LT]
+	* @param writer *RESP.Writer - the writer to write the response to
+	* @param args []RESP.RESPMessage - the arguments for the command
+	* @param store *store.Store - the store to get the data from
+	* @param clientID string - the client id
+	* @param txManager *tx.TxManager - the transaction manager
+	* @return error - the error if there is one
+	* @return integer - 1 if the expiration was set, 0 if the key doesn't exist or the condition wasn't met
+*/
+func handleExpire(writer *RESP.Writer, args []RESP.RESPMessage, store *store.Store, clientID string, txManager *tx.TxManager) error {
+	return expireGeneric(writer, args, store, txManager, "EXPIRE", time.Second, false)
+}
+
+/*
+ 	* handlePExpire handles the PEXPIRE command, PEXPIRE key milliseconds [NX | XX | GT | LT]
+	* @param writer *RESP.Writer - the writer to write the response to
+	* @param args []RESP.RESPMessage - the arguments for the command
+	* @param store *store.Store - the store to get the data from
+	* @param clientID string - the client id
+	* @param txManager *tx.TxManager - the transaction manager
+	* @return error - the error if there is one
+	* @return integer - 1 if the expiration was set, 0 if the key doesn't exist or the condition wasn't met
+*/
+func handlePExpire(writer *RESP.Writer, args []RESP.RESPMessage, store *store.Store, clientID string, txManager *tx.TxManager) error {
+	return expireGeneric(writer, args, store, txManager, "PEXPIRE", time.Millisecond, false)
+}
+
+/*
+ 	* handleExpireAt handles the EXPIREAT command, EXPIREAT key unix-time-seconds [NX | XX | GT | LT]
+	* @param writer *RESP.Writer - the writer to write the response to
+	* @param args []RESP.RESPMessage - the arguments for the command
+	* @param store *store.Store - the store to get the data from
+	* @param clientID string - the client id
+	* @param txManager *tx.TxManager - the transaction manager
+	* @return error - the error if there is one
+	* @return integer - 1 if the expiration was set, 0 if the key doesn't exist or the condition wasn't met
+*/
+func handleExpireAt(writer *RESP.Writer, args []RESP.RESPMessage, store *store.Store, clientID string, txManager *tx.TxManager) error {
+	return expireGeneric(writer, args, store, txManager, "EXPIREAT", time.Second, true)
+}
+
+/*
+ 	* handlePExpireAt handles the PEXPIREAT command, PEXPIREAT key unix-time-milliseconds [NX | XX | GT | LT]
+	* @param writer *RESP.Writer - the writer to write the response to
+	* @param args []RESP.RESPMessage - the arguments for the command
+	* @param store *store.Store - the store to get the data from
+	* @param clientID string - the client id
+	* @param txManager *tx.TxManager - the transaction manager
+	* @return error - the error if there is one
+	* @return integer - 1 if the expiration was set, 0 if the key doesn't exist or the condition wasn't met
+*/
+func handlePExpireAt(writer *RESP.Writer, args []RESP.RESPMessage, store *store.Store, clientID string, txManager *tx.TxManager) error {
+	return expireGeneric(writer, args, store, txManager, "PEXPIREAT", time.Millisecond, true)
+}
+
+/*
+ 	* expireGeneric implements the EXPIRE family, a time in the past deletes the key
+	* @param writer *RESP.Writer - the writer to write the response to
+	* @param args []RESP.RESPMessage - the arguments for the command
+	* @param st *store.Store - the store to get the data from
+	* @param txManager *tx.TxManager - the transaction manager
+	* @param cmd string - the name of the command, for errors
+	* @param unit time.Duration - time.Second or time.Millisecond, the unit of the time argument
+	* @param absolute bool - true if the time argument is a unix time, false if it is relative to now
+	* @return error - the error if there is one
+*/
+func expireGeneric(writer *RESP.Writer, args []RESP.RESPMessage, st *store.Store, txManager *tx.TxManager, cmd string, unit time.Duration, absolute bool) error {
+	if len(args) < 2 {
+		err := errWrongNumberOfArguments(cmd)
+		return HandleError(writer, []byte(err.Error()))
+	}
+
+	key := string(args[0].RESPValue)
+
+	value, err := strconv.ParseInt(string(args[1].RESPValue), 10, 64)
+	if err != nil {
+		return HandleError(writer, []byte("ERR value is not an integer or out of range"))
+	}
+
+	condition, err := parseExpireCondition(args[2:])
+	if err != nil {
+		return HandleError(writer, []byte(err.Error()))
+	}
+
+	at, ok := expireTimeMs(value, unit, absolute)
+	if !ok {
+		return HandleError(writer, []byte(errInvalidExpireTime(cmd).Error()))
+	}
+
+	if !st.ExpireAt(key, time.UnixMilli(at), condition) {
+		return encodeInteger(writer, 0)
+	}
+
+	signalModifiedKey(txManager, key)
+
+	return encodeInteger(writer, 1)
+}
+
+/*
+ 	* parseExpireCondition parses the NX, XX, GT and LT flags
+	* @param args []RESP.RESPMessage - the arguments after the time
+	* @return store.ExpireCondition - the flags
+	* @return error - the error if the flags are unknown or can't be combined
+*/
+func parseExpireCondition(args []RESP.RESPMessage) (store.ExpireCondition, error) {
+	condition := store.ExpireAlways
+
+	for _, arg := range args {
+		switch option := strings.ToUpper(string(arg.RESPValue)); option {
+		case "NX":
+			condition |= store.ExpireIfNoTTL
+		case "XX":
+			condition |= store.ExpireIfHasTTL
+		case "GT":
+			condition |= store.ExpireIfGreater
+		case "LT":
+			condition |= store.ExpireIfLess
+		default:
+			return 0, fmt.Errorf("ERR Unsupported option %s", arg.RESPValue)
+		}
+	}
+
+	if condition&store.ExpireIfNoTTL != 0 && condition != store.ExpireIfNoTTL {
+		return 0, fmt.Errorf("ERR NX and XX, GT or LT options at the same time are not compatible")
+	}
+	if condition&store.ExpireIfGreater != 0 && condition&store.ExpireIfLess != 0 {
+		return 0, fmt.Errorf("ERR GT and LT options at the same time are not compatible")
+	}
+
+	return condition, nil
+}
+
+/*
+ 	* expireTimeMs turns the time argument of the EXPIRE family into a unix time in milliseconds
+	* @param value int64 - the time argument
+	* @param unit time.Duration - time.Second or time.Millisecond
+	* @param absolute bool - true if value is a unix time, false if it is relative to now
+	* @return int64 - the unix time in milliseconds
+	* @return bool - false if the time doesn't fit in 64 bits
+*/
+func expireTimeMs(value int64, unit time.Duration, absolute bool) (int64, bool) {
+	if unit == time.Second {
+		if value > math.MaxInt64/1000 || value < math.MinInt64/1000 {
+			return 0, false
+		}
+		value *= 1000
+	}
+
+	if absolute {
+		return value, true
+	}
+
+	now := time.Now().UnixMilli()
+	if value > math.MaxInt64-now {
+		return 0, false
+	}
+
+	return now + value, true
+}
+
+/*
+ 	* handleTTL handles the TTL command, TTL key
+	* @param writer *RESP.Writer - the writer to write the response to
+	* @param args []RESP.RESPMessage - the arguments for the command
+	* @param store *store.Store - the store to get the data from
+	* @param clientID string - the client id
+	* @param txManager *tx.TxManager - the transaction manager
+	* @return error - the error if there is one
+	* @return integer - the seconds left to live, -1 if the key has no expiration, -2 if the key doesn't exist
+*/
+func handleTTL(writer *RESP.Writer, args []RESP.RESPMessage, store *store.Store, clientID string, txManager *tx.TxManager) error {
+	return ttlGeneric(writer, args, store, "TTL", time.Second, false)
+}
+
+/*
+ 	* handlePTTL handles the PTTL command, PTTL key
+	* @param writer *RESP.Writer - the writer to write the response to
+	* @param args []RESP.RESPMessage - the arguments for the command
+	* @param store *store.Store - the store to get the data from
+	* @param clientID string - the client id
+	* @param txManager *tx.TxManager - the transaction manager
+	* @return error - the error if there is one
+	* @return integer - the milliseconds left to live, -1 if the key has no expiration, -2 if the key doesn't exist
+*/
+func handlePTTL(writer *RESP.Writer, args []RESP.RESPMessage, store *store.Store, clientID string, txManager *tx.TxManager) error {
+	return ttlGeneric(writer, args, store, "PTTL", time.Millisecond, false)
+}
+
+/*
+ 	* handleExpireTime handles the EXPIRETIME command, EXPIRETIME key
+	* @param writer *RESP.Writer - the writer to write the response to
+	* @param args []RESP.RESPMessage - the arguments for the command
+	* @param store *store.Store - the store to get the data from
+	* @param clientID string - the client id
+	* @param txManager *tx.TxManager - the transaction manager
+	* @return error - the error if there is one
+	* @return integer - the unix time in seconds the key expires at, -1 if the key has no expiration, -2 if the key doesn't exist
+*/
+func handleExpireTime(writer *RESP.Writer, args []RESP.RESPMessage, store *store.Store, clientID string, txManager *tx.TxManager) error {
+	return ttlGeneric(writer, args, store, "EXPIRETIME", time.Second, true)
+}
+
+/*
+ 	* handlePExpireTime handles the PEXPIRETIME command, PEXPIRETIME key
+	* @param writer *RESP.Writer - the writer to write the response to
+	* @param args []RESP.RESPMessage - the arguments for the command
+	* @param store *store.Store - the store to get the data from
+	* @param clientID string - the client id
+	* @param txManager *tx.TxManager - the transaction manager
+	* @return error - the error if there is one
+	* @return integer - the unix time in milliseconds the key expires at, -1 if the key has no expiration, -2 if the key doesn't exist
+*/
+func handlePExpireTime(writer *RESP.Writer, args []RESP.RESPMessage, store *store.Store, clientID string, txManager *tx.TxManager) error {
+	return ttlGeneric(writer, args, store, "PEXPIRETIME", time.Millisecond, true)
+}
+
+/*
+ 	* ttlGeneric implements TTL, PTTL, EXPIRETIME and PEXPIRETIME
+	* @param writer *RESP.Writer - the writer to write the response to
+	* @param args []RESP.RESPMessage - the arguments for the command
+	* @param st *store.Store - the store to get the data from
+	* @param cmd string - the name of the command, for errors
+	* @param unit time.Duration - time.Second or time.Millisecond, the unit of the reply
+	* @param absolute bool - true to reply with the unix time of the expiration, false for the time left
+	* @return error - the error if there is one
+*/
+func ttlGeneric(writer *RESP.Writer, args []RESP.RESPMessage, st *store.Store, cmd string, unit time.Duration, absolute bool) error {
+	if len(args) != 1 {
+		err := errWrongNumberOfArguments(cmd)
+		return HandleError(writer, []byte(err.Error()))
+	}
+
+	expiration, exists := st.ExpireTime(string(args[0].RESPValue))
+	if !exists {
+		return encodeInteger(writer, -2)
+	}
+	if expiration.IsZero() {
+		return encodeInteger(writer, -1)
+	}
+
+	if absolute {
+		if unit == time.Second {
+			return encodeInteger(writer, expiration.Unix())
+		}
+		return encodeInteger(writer, expiration.UnixMilli())
+	}
+
+	ttl := max(time.Until(expiration).Milliseconds(), 0)
+	if unit == time.Second {
+		ttl = (ttl + 500) / 1000 // rounded, like Redis
+	}
+
+	return encodeInteger(writer, ttl)
+}
+
+/*
+ 	* handlePersist handles the PERSIST command, PERSIST key, removes the expiration of a key
+	* @param writer *RESP.Writer - the writer to write the response to
+	* @param args []RESP.RESPMessage - the arguments for the command
+	* @param store *store.Store - the store to get the data from
+	* @param clientID string - the client id
+	* @param txManager *tx.TxManager - the transaction manager
+	* @return error - the error if there is one
+	* @return integer - 1 if the expiration was removed, 0 if the key doesn't exist or has no expiration
+*/
+func handlePersist(writer *RESP.Writer, args []RESP.RESPMessage, store *store.Store, clientID string, txManager *tx.TxManager) error {
+	if len(args) != 1 {
+		err := errWrongNumberOfArguments("PERSIST")
+		return HandleError(writer, []byte(err.Error()))
+	}
+
+	key := string(args[0].RESPValue)
+	if !store.Persist(key) {
+		return encodeInteger(writer, 0)
+	}
+
+	signalModifiedKey(txManager, key)
+
+	return encodeInteger(writer, 1)
+}
@@ -0,0 +1,482 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	RESP "github.com/manish-singh-bisht/Redis-From-Scratch/db/resp"
+	store "github.com/manish-singh-bisht/Redis-From-Scratch/db/store"
+	tx "github.com/manish-singh-bisht/Redis-From-Scratch/db/transaction"
+)
+
+var errGeoNotFloat = errors.New("ERR value is not a valid float")
+
+// the meters in each unit GEODIST and GEOSEARCH take
+var geoUnits = map[string]float64{
+	"M":  1,
+	"KM": 1000,
+	"FT": 0.3048,
+	"MI": 1609.34,
+}
+
+/*
+ 	* parseGeoUnit parses a unit of distance
+	* @param value []byte - M, KM, FT or MI, in any case
+	* @return float64 - the meters in the unit
+	* @return error - the error if the unit is not supported
+*/
+func parseGeoUnit(value []byte) (float64, error) {
+	unit, exists := geoUnits[strings.ToUpper(string(value))]
+	if !exists {
+		return 0, errors.New("ERR unsupported unit provided. please use M, KM, FT, MI")
+	}
+	return unit, nil
+}
+
+/*
+ 	* parseGeoFloat parses a coordinate, a radius or a size
+	* @param value []byte - the number
+	* @return float64 - the number
+	* @return error - errGeoNotFloat if it is not a number
+*/
+func parseGeoFloat(value []byte) (float64, error) {
+	f, err := strconv.ParseFloat(string(value), 64)
+	if err != nil {
+		return 0, errGeoNotFloat
+	}
+	return f, nil
+}
+
+/*
+ 	* parseGeoPoint parses a longitude and a latitude
+	* @param longitude []byte - the longitude
+	* @param latitude []byte - the latitude
+	* @return float64 - the longitude
+	* @return float64 - the latitude
+	* @return error - the error if they are not numbers or are out of the range a point can be stored in
+*/
+func parseGeoPoint(longitude, latitude []byte) (float64, float64, error) {
+	lon, err := parseGeoFloat(longitude)
+	if err != nil {
+		return 0, 0, err
+	}
+	lat, err := parseGeoFloat(latitude)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	if !store.GeoValid(lon, lat) {
+		return 0, 0, fmt.Errorf("ERR invalid longitude,latitude pair %f,%f", lon, lat)
+	}
+	return lon, lat, nil
+}
+
+/*
+ 	* formatGeoCoordinate formats a coordinate like Redis does, with up to 17 decimals and no trailing zeros
+	* @param f float64 - the coordinate
+	* @return []byte - the formatted coordinate
+*/
+func formatGeoCoordinate(f float64) []byte {
+	formatted := strconv.FormatFloat(f, 'f', 17, 64)
+	formatted = strings.TrimRight(formatted, "0")
+	formatted = strings.TrimSuffix(formatted, ".")
+	return []byte(formatted)
+}
+
+/*
+ 	* formatGeoDistance formats a distance with 4 decimals
+	* @param distance float64 - the distance
+	* @return []byte - the formatted distance
+*/
+func formatGeoDistance(distance float64) []byte {
+	return strconv.AppendFloat(nil, distance, 'f', 4, 64)
+}
+
+/*
+ 	* geoCoordinateMessage builds the [longitude, latitude] reply of GEOPOS and WITHCOORD
+	* @param longitude float64 - the longitude
+	* @param latitude float64 - the latitude
+	* @return RESP.RESPMessage - the message
+*/
+func geoCoordinateMessage(longitude, latitude float64) RESP.RESPMessage {
+	return RESP.RESPMessage{
+		RESPType: RESP.Array,
+		RESPLen:  2,
+		RESPArrayElem: []RESP.RESPMessage{
+			bulkStringMessage(formatGeoCoordinate(longitude)),
+			bulkStringMessage(formatGeoCoordinate(latitude)),
+		},
+	}
+}
+
+/*
+ 	* handleGeoAdd handles the GEOADD command, GEOADD key [NX | XX] [CH] longitude latitude member [longitude latitude member ...]
+	* @param writer *RESP.Writer - the writer to write the response to
+	* @param args []RESP.RESPMessage - the arguments for the command
+	* @param store *store.Store - the store to get the data from
+	* @param clientID string - the client id
+	* @param txManager *tx.TxManager - the transaction manager
+	* @return error - the error if there is one
+	* @return integer - the number of members added, plus the ones whose position changed with CH
+*/
+func handleGeoAdd(writer *RESP.Writer, args []RESP.RESPMessage, st *store.Store, clientID string, txManager *tx.TxManager) error {
+	if len(args) < 4 {
+		err := errWrongNumberOfArguments("GEOADD")
+		return HandleError(writer, []byte(err.Error()))
+	}
+
+	key := string(args[0].RESPValue)
+
+	flags := store.ZAddAlways
+	changed := false
+
+	i := 1
+options:
+	for ; i < len(args); i++ {
+		switch strings.ToUpper(string(args[i].RESPValue)) {
+		case "NX":
+			flags |= store.ZAddNX
+		case "XX":
+			flags |= store.ZAddXX
+		case "CH":
+			changed = true
+		default:
+			break options
+		}
+	}
+
+	triples := args[i:]
+	if len(triples) == 0 || len(triples)%3 != 0 {
+		return HandleError(writer, []byte("ERR syntax error. Try GEOADD key [x1] [y1] [name1] [x2] [y2] [name2] ... "))
+	}
+	if flags&store.ZAddNX != 0 && flags&store.ZAddXX != 0 {
+		return HandleError(writer, []byte(errZAddXXAndNX.Error()))
+	}
+
+	members := make([]store.ZMember, 0, len(triples)/3)
+	for j := 0; j < len(triples); j += 3 {
+		longitude, latitude, err := parseGeoPoint(triples[j].RESPValue, triples[j+1].RESPValue)
+		if err != nil {
+			return HandleError(writer, []byte(err.Error()))
+		}
+		members = append(members, store.ZMember{Member: string(triples[j+2].RESPValue), Score: store.GeoScore(longitude, latitude)})
+	}
+
+	added, updated, err := st.ZAdd(key, members, flags)
+	if err != nil {
+		return HandleError(writer, []byte(err.Error()))
+	}
+
+	if added+updated > 0 {
+		signalModifiedKey(txManager, key)
+	}
+
+	if changed {
+		return encodeInteger(writer, int64(added+updated))
+	}
+	return encodeInteger(writer, int64(added))
+}
+
+/*
+ 	* handleGeoPos handles the GEOPOS command, GEOPOS key [member ...]
+	* @param writer *RESP.Writer - the writer to write the response to
+	* @param args []RESP.RESPMessage - the arguments for the command
+	* @param store *store.Store - the store to get the data from
+	* @param clientID string - the client id
+	* @param txManager *tx.TxManager - the transaction manager
+	* @return error - the error if there is one
+	* @return array - the [longitude, latitude] of each member, nil for the members that don't exist
+*/
+func handleGeoPos(writer *RESP.Writer, args []RESP.RESPMessage, st *store.Store, clientID string, txManager *tx.TxManager) error {
+	if len(args) < 1 {
+		err := errWrongNumberOfArguments("GEOPOS")
+		return HandleError(writer, []byte(err.Error()))
+	}
+
+	scores, exists, err := st.ZScore(string(args[0].RESPValue), keyArgs(args[1:]))
+	if err != nil {
+		return HandleError(writer, []byte(err.Error()))
+	}
+
+	elements := make([]RESP.RESPMessage, len(scores))
+	for i, score := range scores {
+		if !exists[i] {
+			elements[i] = RESP.RESPMessage{RESPType: RESP.Array, RESPLen: -1}
+			continue
+		}
+		elements[i] = geoCoordinateMessage(store.GeoDecode(score))
+	}
+
+	return encodeArray(writer, elements)
+}
+
+/*
+ 	* handleGeoDist handles the GEODIST command, GEODIST key member1 member2 [M | KM | FT | MI]
+	* @param writer *RESP.Writer - the writer to write the response to
+	* @param args []RESP.RESPMessage - the arguments for the command
+	* @param store *store.Store - the store to get the data from
+	* @param clientID string - the client id
+	* @param txManager *tx.TxManager - the transaction manager
+	* @return error - the error if there is one
+	* @return bulk string - the distance in the unit, meters by default, nil if either member doesn't exist
+*/
+func handleGeoDist(writer *RESP.Writer, args []RESP.RESPMessage, st *store.Store, clientID string, txManager *tx.TxManager) error {
+	if len(args) != 3 && len(args) != 4 {
+		err := errWrongNumberOfArguments("GEODIST")
+		return HandleError(writer, []byte(err.Error()))
+	}
+
+	unit := 1.0
+	if len(args) == 4 {
+		var err error
+		if unit, err = parseGeoUnit(args[3].RESPValue); err != nil {
+			return HandleError(writer, []byte(err.Error()))
+		}
+	}
+
+	scores, exists, err := st.ZScore(string(args[0].RESPValue), keyArgs(args[1:3]))
+	if err != nil {
+		return HandleError(writer, []byte(err.Error()))
+	}
+	if !exists[0] || !exists[1] {
+		return writer.EncodeNil()
+	}
+
+	lon1, lat1 := store.GeoDecode(scores[0])
+	lon2, lat2 := store.GeoDecode(scores[1])
+	return encodeBulkString(writer, formatGeoDistance(store.GeoDistance(lon1, lat1, lon2, lat2)/unit))
+}
+
+/*
+ 	* handleGeoHash handles the GEOHASH command, GEOHASH key [member ...]
+	* @param writer *RESP.Writer - the writer to write the response to
+	* @param args []RESP.RESPMessage - the arguments for the command
+	* @param store *store.Store - the store to get the data from
+	* @param clientID string - the client id
+	* @param txManager *tx.TxManager - the transaction manager
+	* @return error - the error if there is one
+	* @return array - the standard 11 character geohash of each member, nil for the members that don't exist
+*/
+func handleGeoHash(writer *RESP.Writer, args []RESP.RESPMessage, st *store.Store, clientID string, txManager *tx.TxManager) error {
+	if len(args) < 1 {
+		err := errWrongNumberOfArguments("GEOHASH")
+		return HandleError(writer, []byte(err.Error()))
+	}
+
+	scores, exists, err := st.ZScore(string(args[0].RESPValue), keyArgs(args[1:]))
+	if err != nil {
+		return HandleError(writer, []byte(err.Error()))
+	}
+
+	elements := make([]RESP.RESPMessage, len(scores))
+	for i, score := range scores {
+		var hash []byte
+		if exists[i] {
+			hash = []byte(store.GeoHashString(score))
+		}
+		elements[i] = bulkStringMessage(hash)
+	}
+
+	return encodeArray(writer, elements)
+}
+
+// geoSearchOptions are the options of GEOSEARCH and GEOSEARCHSTORE besides the query itself
+type geoSearchOptions struct {
+	withCoord, withDist, withHash bool
+	storeDist                     bool
+}
+
+/*
+ 	* parseGeoSearch parses the arguments of GEOSEARCH and GEOSEARCHSTORE after the key of the sorted set,
+	* FROMMEMBER member | FROMLONLAT longitude latitude, BYRADIUS radius unit | BYBOX width height unit,
+	* [ASC | DESC] [COUNT count [ANY]] [WITHCOORD] [WITHDIST] [WITHHASH], and [STOREDIST] for GEOSEARCHSTORE
+	* @param args []RESP.RESPMessage - the arguments
+	* @param cmd string - GEOSEARCH or GEOSEARCHSTORE
+	* @return store.GeoQuery - the query
+	* @return geoSearchOptions - the other options
+	* @return error - the error if the arguments are not valid
+*/
+func parseGeoSearch(args []RESP.RESPMessage, cmd string) (store.GeoQuery, geoSearchOptions, error) {
+	query := store.GeoQuery{}
+	options := geoSearchOptions{}
+	fromMember, fromLonLat, byRadius, byBox := false, false, false, false
+
+	for i := 0; i < len(args); i++ {
+		remaining := len(args) - i - 1
+		switch option := strings.ToUpper(string(args[i].RESPValue)); {
+		case option == "FROMMEMBER" && remaining >= 1:
+			query.FromMember = true
+			query.Member = string(args[i+1].RESPValue)
+			fromMember = true
+			i++
+		case option == "FROMLONLAT" && remaining >= 2:
+			var err error
+			if query.Longitude, query.Latitude, err = parseGeoPoint(args[i+1].RESPValue, args[i+2].RESPValue); err != nil {
+				return query, options, err
+			}
+			fromLonLat = true
+			i += 2
+		case option == "BYRADIUS" && remaining >= 2:
+			var err error
+			if query.Radius, err = parseGeoFloat(args[i+1].RESPValue); err != nil {
+				return query, options, err
+			}
+			if query.Radius < 0 {
+				return query, options, errors.New("ERR radius cannot be negative")
+			}
+			if query.Unit, err = parseGeoUnit(args[i+2].RESPValue); err != nil {
+				return query, options, err
+			}
+			byRadius = true
+			i += 2
+		case option == "BYBOX" && remaining >= 3:
+			var err error
+			if query.Width, err = parseGeoFloat(args[i+1].RESPValue); err != nil {
+				return query, options, err
+			}
+			if query.Height, err = parseGeoFloat(args[i+2].RESPValue); err != nil {
+				return query, options, err
+			}
+			if query.Width < 0 || query.Height < 0 {
+				return query, options, errors.New("ERR height or width cannot be negative")
+			}
+			if query.Unit, err = parseGeoUnit(args[i+3].RESPValue); err != nil {
+				return query, options, err
+			}
+			query.ByBox = true
+			byBox = true
+			i += 3
+		case option == "ASC":
+			query.Sort = store.GeoSortAsc
+		case option == "DESC":
+			query.Sort = store.GeoSortDesc
+		case option == "COUNT" && remaining >= 1:
+			count, err := strconv.ParseInt(string(args[i+1].RESPValue), 10, 64)
+			if err != nil {
+				return query, options, errors.New("ERR value is not an integer or out of range")
+			}
+			if count <= 0 {
+				return query, options, errors.New("ERR COUNT must be > 0")
+			}
+			query.Count = int(count)
+			i++
+			if remaining >= 2 && strings.ToUpper(string(args[i+1].RESPValue)) == "ANY" {
+				query.Any = true
+				i++
+			}
+		case option == "WITHCOORD":
+			options.withCoord = true
+		case option == "WITHDIST":
+			options.withDist = true
+		case option == "WITHHASH":
+			options.withHash = true
+		case option == "STOREDIST" && cmd == "GEOSEARCHSTORE":
+			options.storeDist = true
+		case option == "ANY":
+			return query, options, errors.New("ERR the ANY argument requires COUNT argument")
+		default:
+			return query, options, errSyntax
+		}
+	}
+
+	if fromMember == fromLonLat {
+		return query, options, fmt.Errorf("ERR exactly one of FROMMEMBER or FROMLONLAT can be specified for %s", cmd)
+	}
+	if byRadius == byBox {
+		return query, options, fmt.Errorf("ERR exactly one of BYRADIUS and BYBOX can be specified for %s", cmd)
+	}
+	if cmd == "GEOSEARCHSTORE" && (options.withCoord || options.withDist || options.withHash) {
+		return query, options, errors.New("ERR GEOSEARCHSTORE is not compatible with WITHDIST, WITHHASH and WITHCOORD options")
+	}
+
+	return query, options, nil
+}
+
+/*
+ 	* handleGeoSearch handles the GEOSEARCH command,
+	* GEOSEARCH key FROMMEMBER member | FROMLONLAT longitude latitude BYRADIUS radius unit | BYBOX width height unit
+	* [ASC | DESC] [COUNT count [ANY]] [WITHCOORD] [WITHDIST] [WITHHASH]
+	* @param writer *RESP.Writer - the writer to write the response to
+	* @param args []RESP.RESPMessage - the arguments for the command
+	* @param store *store.Store - the store to get the data from
+	* @param clientID string - the client id
+	* @param txManager *tx.TxManager - the transaction manager
+	* @return error - the error if there is one
+	* @return array - the members inside the shape, each an array of the member, its distance, its geohash and its
+	* coordinates when any of WITHDIST, WITHHASH and WITHCOORD is given
+*/
+func handleGeoSearch(writer *RESP.Writer, args []RESP.RESPMessage, store *store.Store, clientID string, txManager *tx.TxManager) error {
+	if len(args) < 6 {
+		err := errWrongNumberOfArguments("GEOSEARCH")
+		return HandleError(writer, []byte(err.Error()))
+	}
+
+	query, options, err := parseGeoSearch(args[1:], "GEOSEARCH")
+	if err != nil {
+		return HandleError(writer, []byte(err.Error()))
+	}
+
+	results, err := store.GeoSearch(string(args[0].RESPValue), query)
+	if err != nil {
+		return HandleError(writer, []byte(err.Error()))
+	}
+
+	plain := !options.withCoord && !options.withDist && !options.withHash
+	elements := make([]RESP.RESPMessage, len(results))
+	for i, found := range results {
+		if plain {
+			elements[i] = bulkStringMessage([]byte(found.Member))
+			continue
+		}
+
+		fields := []RESP.RESPMessage{bulkStringMessage([]byte(found.Member))}
+		if options.withDist {
+			fields = append(fields, bulkStringMessage(formatGeoDistance(found.Distance)))
+		}
+		if options.withHash {
+			fields = append(fields, integerMessage(int64(found.Score)))
+		}
+		if options.withCoord {
+			fields = append(fields, geoCoordinateMessage(found.Longitude, found.Latitude))
+		}
+		elements[i] = RESP.RESPMessage{RESPType: RESP.Array, RESPLen: len(fields), RESPArrayElem: fields}
+	}
+
+	return encodeArray(writer, elements)
+}
+
+/*
+ 	* handleGeoSearchStore handles the GEOSEARCHSTORE command, GEOSEARCHSTORE destination source followed by the
+	* arguments of GEOSEARCH without WITHCOORD, WITHDIST and WITHHASH, and [STOREDIST]
+	* @param writer *RESP.Writer - the writer to write the response to
+	* @param args []RESP.RESPMessage - the arguments for the command
+	* @param store *store.Store - the store to get the data from
+	* @param clientID string - the client id
+	* @param txManager *tx.TxManager - the transaction manager
+	* @return error - the error if there is one
+	* @return integer - the number of members stored at destination
+*/
+func handleGeoSearchStore(writer *RESP.Writer, args []RESP.RESPMessage, store *store.Store, clientID string, txManager *tx.TxManager) error {
+	if len(args) < 7 {
+		err := errWrongNumberOfArguments("GEOSEARCHSTORE")
+		return HandleError(writer, []byte(err.Error()))
+	}
+
+	dst := string(args[0].RESPValue)
+
+	query, options, err := parseGeoSearch(args[2:], "GEOSEARCHSTORE")
+	if err != nil {
+		return HandleError(writer, []byte(err.Error()))
+	}
+
+	stored, err := store.GeoSearchStore(dst, string(args[1].RESPValue), query, options.storeDist)
+	if err != nil {
+		return HandleError(writer, []byte(err.Error()))
+	}
+
+	signalModifiedKey(txManager, dst)
+
+	return encodeInteger(writer, int64(stored))
+}
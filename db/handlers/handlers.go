@@ -2,14 +2,14 @@ package handlers
 
 import (
 	"bytes"
+	"errors"
 	"fmt"
 	"log"
-	"reflect"
 	"strconv"
 	"strings"
-	"time"
 
 	config "github.com/manish-singh-bisht/Redis-From-Scratch/db/persistence"
+	pubsub "github.com/manish-singh-bisht/Redis-From-Scratch/db/pubsub"
 	RESP "github.com/manish-singh-bisht/Redis-From-Scratch/db/resp"
 	store "github.com/manish-singh-bisht/Redis-From-Scratch/db/store"
 	tx "github.com/manish-singh-bisht/Redis-From-Scratch/db/transaction"
@@ -18,6 +18,36 @@ import (
 // passing clientID and txManager to the handler because we need for transaction related commands, other than that we are not using them, so is it a good practice?? Not sure!!
 type commandHandler func(writer *RESP.Writer, args []RESP.RESPMessage, store *store.Store, clientID string, txManager *tx.TxManager) error
 
+// AOFLoaderClientID is the client the AOF is replayed as, keeps its MULTI/EXEC blocks apart from real clients,
+// it is the only one that can run the internal commands
+const AOFLoaderClientID = "aof-loader"
+
+// internalCommands are written to the AOF to restore state that no public command rebuilds exactly,
+// only the AOF loader runs them, to clients they don't exist
+var internalCommands = map[string]commandHandler{
+	"RATE.MARKAT":  handleRateMarkAt,  // RATE.MARK at the time it happened
+	"RATE.RESTORE": handleRateRestore, // a rate meter with its averages, for the AOF base
+	"SETLASTID":    handleSetLastID,   // NEXTID as the last ID it handed out, and the last ID of a key in the AOF base
+}
+
+/*
+ 	* lookupHandler returns the handler of a command a client can run
+	* @param cmd string - the command, in uppercase
+	* @param clientID string - the client id
+	* @return commandHandler - the handler
+	* @return bool - true if the command exists for this client
+*/
+func lookupHandler(cmd, clientID string) (commandHandler, bool) {
+	if handler, exists := getHandlers(cmd); exists {
+		return handler, true
+	}
+	if clientID != AOFLoaderClientID {
+		return nil, false
+	}
+	handler, exists := internalCommands[cmd]
+	return handler, exists
+}
+
 /**
  * getHandlers returns the handler for the given command
  * @param cmd string - the command to get the handler for
@@ -34,18 +64,41 @@ func getHandlers(cmd string) (commandHandler, bool) {
 		// gets the configuration of the server,
 		//--------currently only dir and dbfilename are supported--------
 
-		"KEYS": handleKeys,
-		// returns all the keys that match the pattern,
-		//--------currently only * is supported--------
-
-		"TYPE":   handleType, // returns the type of the key
-		"XADD":   handleXAdd, // adds a new entry to a stream, creates a stream if it doesn't exist
+		"GETSET":   handleGetSet,   // sets a key to a value and returns the old one
+		"GETDEL":   handleGetDel,   // returns the value of a key and deletes it
+		"GETEX":    handleGetEx,    // returns the value of a key and sets or removes its expiration
+		"APPEND":   handleAppend,   // appends to the value of a key, creating it if needed
+		"STRLEN":   handleStrLen,   // returns the length of the value of a key
+		"GETRANGE": handleGetRange, // returns a substring, negative offsets count from the end
+		"SETRANGE": handleSetRange, // overwrites part of the value from an offset, padding it with zero bytes
+		"MGET":     handleMGet,     // returns the values of several keys
+		"MSET":     handleMSet,     // sets several keys at once
+		"MSETNX":   handleMSetNX,   // sets several keys at once, only if none of them exists
+
+		"SETBIT":      handleSetBit,     // sets or clears a bit of a string, growing it as needed
+		"GETBIT":      handleGetBit,     // returns a bit of a string
+		"BITCOUNT":    handleBitCount,   // counts the bits set in a string, optionally within a byte or bit range
+		"BITPOS":      handleBitPos,     // returns the position of the first bit set to 1 or 0, optionally within a range
+		"BITOP":       handleBitOp,      // stores the bitwise AND, OR, XOR or NOT of strings in a key
+		"BITFIELD":    handleBitField,   // gets, sets and increments integer fields of arbitrary width in a string
+		"BITFIELD_RO": handleBitFieldRO, // BITFIELD with only GET
+
+		"KEYS": handleKeys, // returns all the keys, strings and streams, that match a glob-style pattern
+		"SCAN": handleScan, // iterates the keys a batch at a time with a cursor, optionally filtered by pattern and type
+
+		"TYPE":   handleType,  // returns the type of the key
+		"XADD":   handleXAdd,  // adds a new entry to a stream, creates a stream if it doesn't exist, optionally trims it
+		"XLEN":   handleXLen,  // the number of entries in a stream
+		"XDEL":   handleXDel,  // deletes entries from a stream by ID
+		"XTRIM":  handleXTrim, // trims a stream to a length or to a minimum ID
 		"XRANGE": handleXRange,
 		// gets a range of entries from a stream,
-		// inclusive of the start and end IDs,
-		// takes in start and end IDs as arguments,
+		// inclusive of the start and end IDs unless prefixed by "(",
+		// takes in start and end IDs as arguments, - and + for the first and last entries, and an optional COUNT,
 		// cannot read from multiple streams
-		// --------currently only xrange is supported with + and - --------
+
+		"XREVRANGE": handleXRevRange, // XRANGE from the end of the range backwards, takes the end ID first
+		"XINFO":     handleXInfo,     // STREAM, GROUPS and CONSUMERS, what a stream, its groups and their consumers hold
 
 		"XREAD": handleXRead,
 		// gets a range of entries from a stream
@@ -53,10 +106,22 @@ func getHandlers(cmd string) (commandHandler, bool) {
 		// exclusive of start id, takes in start id as argument,
 		// can also read from multiple streams(this is good when we want to read from multiple streams using just one command)
 		// also has blocking options(that is the command is blocked until the given time specified in command and during that time if entries come they will be listened nearly instantly.)
-		// --------currently only xread, blocking with and without timeout is supported, $ as id--------
-
-		"INCR": handleIncr, // increments the value of a key, value is integer, by 1
-		"EXIT": handleExit,
+		// blocks on all the streams at once, waking as soon as any of them gets entries, even one that didn't exist yet, COUNT caps the entries of each stream, $ as id
+
+		"XGROUP":     handleXGroup,     // creates, destroys and moves consumer groups and their consumers
+		"XREADGROUP": handleXReadGroup, // reads a stream as a consumer of a group, tracking what was delivered
+		"XACK":       handleXAck,       // acknowledges pending entries of a group
+		"XPENDING":   handleXPending,   // the entries delivered to a group and not acknowledged yet
+		"XCLAIM":     handleXClaim,     // moves pending entries to another consumer
+		"XAUTOCLAIM": handleXAutoClaim, // moves the pending entries idle for long enough to another consumer, a batch at a time
+
+		"INCR":        handleIncr,        // increments the value of a key, value is integer, by 1
+		"INCRBY":      handleIncrBy,      // increments the integer value of a key by a given amount, failing on overflow
+		"DECR":        handleDecr,        // decrements the integer value of a key by 1
+		"DECRBY":      handleDecrBy,      // decrements the integer value of a key by a given amount
+		"INCRBYFLOAT": handleIncrByFloat, // increments the float value of a key by a given amount
+		"EXIT":        handleExit,
+		"QUIT":        handleQuit, // replies OK and closes the connection
 
 		"MULTI": handleMulti,
 		// starts a transaction
@@ -69,6 +134,129 @@ func getHandlers(cmd string) (commandHandler, bool) {
 		// if the key is changed, the transaction is discarded
 		// if the key is not changed, the transaction is executed
 		// keys after EXEC, whether properly executed or not, are not watched
+		"SESSION": handleSession, // creates and resumes sessions, which keep the transaction, watches and blocked read of a client across reconnects
+
+		"DEL":      handleDel,      // deletes keys, returns how many existed
+		"UNLINK":   handleUnlink,   // deletes keys like DEL, freeing large values in the background
+		"EXISTS":   handleExists,   // counts how many of the keys exist
+		"RENAME":   handleRename,   // renames a key, keeping its expiration, overwrites the new name
+		"RENAMENX": handleRenameNX, // renames a key only if the new name is free
+		"COPY":     handleCopy,     // copies a key with its expiration
+
+		"EXPIRE":      handleExpire,      // sets a key's time to live in seconds, NX/XX/GT/LT set it only under a condition
+		"PEXPIRE":     handlePExpire,     // sets a key's time to live in milliseconds
+		"EXPIREAT":    handleExpireAt,    // sets a key's expiration as a unix time in seconds
+		"PEXPIREAT":   handlePExpireAt,   // sets a key's expiration as a unix time in milliseconds
+		"TTL":         handleTTL,         // seconds left to live, -1 without an expiration, -2 if the key doesn't exist
+		"PTTL":        handlePTTL,        // milliseconds left to live
+		"EXPIRETIME":  handleExpireTime,  // the unix time in seconds a key expires at
+		"PEXPIRETIME": handlePExpireTime, // the unix time in milliseconds a key expires at
+		"PERSIST":     handlePersist,     // removes a key's expiration
+
+		"LPUSH":   handleLPush,   // adds elements at the head of a list, creating it if needed
+		"RPUSH":   handleRPush,   // adds elements at the tail of a list, creating it if needed
+		"LPOP":    handleLPop,    // removes and returns elements from the head of a list
+		"RPOP":    handleRPop,    // removes and returns elements from the tail of a list
+		"LRANGE":  handleLRange,  // returns a range of elements, negative indexes count from the tail
+		"LLEN":    handleLLen,    // returns the length of a list
+		"LINDEX":  handleLIndex,  // returns the element at an index
+		"LSET":    handleLSet,    // replaces the element at an index
+		"LREM":    handleLRem,    // removes elements equal to a value
+		"LTRIM":   handleLTrim,   // keeps only a range of elements
+		"LINSERT": handleLInsert, // inserts an element before or after another one
+
+		"HSET":         handleHSet,         // sets fields of a hash, creating it if needed, returns how many were added
+		"HMSET":        handleHMSet,        // sets fields of a hash like HSET, replies OK
+		"HSETNX":       handleHSetNX,       // sets a field only if it doesn't exist
+		"HGET":         handleHGet,         // returns the value of a field
+		"HMGET":        handleHMGet,        // returns the values of several fields
+		"HDEL":         handleHDel,         // deletes fields, the hash is deleted with its last field
+		"HLEN":         handleHLen,         // returns the number of fields
+		"HEXISTS":      handleHExists,      // checks if a field exists
+		"HSTRLEN":      handleHStrLen,      // returns the length of the value of a field
+		"HGETALL":      handleHGetAll,      // returns all the fields and values
+		"HKEYS":        handleHKeys,        // returns all the fields
+		"HVALS":        handleHVals,        // returns all the values
+		"HINCRBY":      handleHIncrBy,      // increments the integer value of a field
+		"HINCRBYFLOAT": handleHIncrByFloat, // increments the float value of a field
+		"HSCAN":        handleHScan,        // iterates the fields of a hash with a cursor
+		"HEXPIRE":      handleHExpire,      // sets the time to live of fields in seconds, NX/XX/GT/LT set it only under a condition
+		"HPEXPIRE":     handleHPExpire,     // sets the time to live of fields in milliseconds
+		"HEXPIREAT":    handleHExpireAt,    // sets the expiration of fields as a unix time in seconds
+		"HPEXPIREAT":   handleHPExpireAt,   // sets the expiration of fields as a unix time in milliseconds
+		"HTTL":         handleHTTL,         // seconds left to live of fields
+		"HPTTL":        handleHPTTL,        // milliseconds left to live of fields
+		"HEXPIRETIME":  handleHExpireTime,  // the unix time in seconds fields expire at
+		"HPEXPIRETIME": handleHPExpireTime, // the unix time in milliseconds fields expire at
+		"HPERSIST":     handleHPersist,     // removes the expiration of fields
+
+		"SADD":        handleSAdd,        // adds members to a set, creating it if needed
+		"SREM":        handleSRem,        // removes members, the set is deleted with its last member
+		"SCARD":       handleSCard,       // returns the number of members
+		"SISMEMBER":   handleSIsMember,   // checks if a member is in a set
+		"SMISMEMBER":  handleSMIsMember,  // checks which of several members are in a set
+		"SMEMBERS":    handleSMembers,    // returns all the members
+		"SRANDMEMBER": handleSRandMember, // returns random members, a negative count allows repeats
+		"SPOP":        handleSPop,        // removes and returns random members
+		"SMOVE":       handleSMove,       // moves a member from one set to another
+		"SINTER":      handleSInter,      // the members in every set
+		"SUNION":      handleSUnion,      // the members in any of the sets
+		"SDIFF":       handleSDiff,       // the members of the first set that are in none of the others
+		"SINTERSTORE": handleSInterStore, // stores SINTER at a key
+		"SUNIONSTORE": handleSUnionStore, // stores SUNION at a key
+		"SDIFFSTORE":  handleSDiffStore,  // stores SDIFF at a key
+		"SINTERCARD":  handleSInterCard,  // the size of the intersection, optionally stopping at a limit
+		"SSCAN":       handleSScan,       // iterates the members of a set with a cursor
+
+		"ZADD":             handleZAdd,             // adds members to a sorted set or updates their scores, NX/XX/GT/LT/CH/INCR
+		"ZINCRBY":          handleZIncrBy,          // increments the score of a member
+		"ZREM":             handleZRem,             // removes members, the sorted set is deleted with its last member
+		"ZCARD":            handleZCard,            // returns the number of members
+		"ZSCORE":           handleZScore,           // returns the score of a member
+		"ZMSCORE":          handleZMScore,          // returns the scores of several members
+		"ZRANK":            handleZRank,            // the rank of a member, lowest score first, optionally with its score
+		"ZREVRANK":         handleZRevRank,         // the rank of a member, highest score first, optionally with its score
+		"ZRANGE":           handleZRange,           // members by rank, BYSCORE or BYLEX, REV, LIMIT and WITHSCORES
+		"ZREVRANGE":        handleZRevRange,        // ZRANGE with REV
+		"ZRANGEBYSCORE":    handleZRangeByScore,    // ZRANGE with BYSCORE
+		"ZREVRANGEBYSCORE": handleZRevRangeByScore, // ZRANGE with BYSCORE and REV
+		"ZRANGEBYLEX":      handleZRangeByLex,      // ZRANGE with BYLEX
+		"ZREVRANGEBYLEX":   handleZRevRangeByLex,   // ZRANGE with BYLEX and REV
+		"ZCOUNT":           handleZCount,           // the number of members in a score range
+		"ZLEXCOUNT":        handleZLexCount,        // the number of members in a lex range
+		"ZPOPMIN":          handleZPopMin,          // removes and returns the members with the lowest scores
+		"ZPOPMAX":          handleZPopMax,          // removes and returns the members with the highest scores
+		"ZUNIONSTORE":      handleZUnionStore,      // stores the union of sorted sets at a key, with weights and an aggregate
+		"ZINTERSTORE":      handleZInterStore,      // stores the intersection of sorted sets at a key, with weights and an aggregate
+
+		"PFADD":   handlePFAdd,   // adds elements to a HyperLogLog, creating it if needed
+		"PFCOUNT": handlePFCount, // the estimated number of distinct elements of the union of HyperLogLogs
+		"PFMERGE": handlePFMerge, // stores the union of HyperLogLogs at a key
+
+		"GEOADD":         handleGeoAdd,         // adds members at a longitude and latitude to a sorted set
+		"GEOPOS":         handleGeoPos,         // the longitude and latitude of members
+		"GEODIST":        handleGeoDist,        // the distance between two members
+		"GEOHASH":        handleGeoHash,        // the standard geohash string of members
+		"GEOSEARCH":      handleGeoSearch,      // the members inside a radius or a box
+		"GEOSEARCHSTORE": handleGeoSearchStore, // stores the members inside a radius or a box at a key
+
+		"RATE.MARK": handleRateMark, // records events on a rate meter, creating it if needed
+		"RATE.GET":  handleRateGet,  // lifetime count and 1/5/15 minute moving averages of a rate meter
+
+		"NEXTID": handleNextID, // hands out monotonic snowflake, stream or ULID IDs per key
+
+		"KEYTRACE": handleKeyTrace, // logs the commands touching keys that match a pattern to a rotating JSON-lines file
+
+		"SUBSCRIBE":    handleSubscribe,    // subscribes to channels, the client can then only (un)subscribe, PING and QUIT
+		"PSUBSCRIBE":   handlePSubscribe,   // subscribes to the channels matching glob-style patterns
+		"UNSUBSCRIBE":  handleUnsubscribe,  // unsubscribes from channels, all of them if none is given
+		"PUNSUBSCRIBE": handlePUnsubscribe, // unsubscribes from patterns, all of them if none is given
+		"PUBLISH":      handlePublish,      // sends a message to the subscribers of a channel, without waiting for them to read it
+		"PUBSUB":       handlePubSub,       // CHANNELS, NUMSUB and NUMPAT, the channels and patterns clients are subscribed to
+
+		"SAVE":     handleSave,     // writes the RDB file, blocking until it is on disk
+		"BGSAVE":   handleBgSave,   // writes the RDB file in the background from a point-in-time snapshot
+		"LASTSAVE": handleLastSave, // unix time of the last successful save
 	}
 	handler, exists := handlers[cmd]
 
@@ -76,7 +264,7 @@ func getHandlers(cmd string) (commandHandler, bool) {
 }
 
 /**
- * handlePing handles the PING command, responds with "PONG"
+ * handlePing handles the PING command, responds with "PONG", or with [pong, message] in subscribed mode
  * @param writer *RESP.Writer - the writer to write the response to
  * @param args []RESP.RESPMessage - the arguments for the command
  * @param store *store.Store - the store to get the data from
@@ -85,6 +273,15 @@ func getHandlers(cmd string) (commandHandler, bool) {
  * @return error - the error if there is one
  */
 func handlePing(writer *RESP.Writer, args []RESP.RESPMessage, store *store.Store, clientID string, txManager *tx.TxManager) error {
+	// in subscribed mode the reply looks like a message, [pong, the argument or an empty string]
+	if pubsub.GetPubSub().Subscriptions(clientID) > 0 {
+		message := []byte{}
+		if len(args) > 0 {
+			message = args[0].RESPValue
+		}
+		return encodeArray(writer, []RESP.RESPMessage{bulkStringMessage([]byte("pong")), bulkStringMessage(message)})
+	}
+
 	return writer.Encode(&RESP.RESPMessage{
 		RESPType:  RESP.SimpleString,
 		RESPValue: []byte("PONG"),
@@ -117,16 +314,18 @@ func handleEcho(writer *RESP.Writer, args []RESP.RESPMessage, store *store.Store
 }
 
 /*
- 	* handleSet handles the SET command, sets a key to a value
+ 	* handleSet handles the SET command,
+	* SET key value [NX | XX] [GET] [EX seconds | PX milliseconds | EXAT unix-time-seconds | PXAT unix-time-milliseconds | KEEPTTL]
 	* @param writer *RESP.Writer - the writer to write the response to
 	* @param args []RESP.RESPMessage - the arguments for the command
 	* @param store *store.Store - the store to get the data from
 	* @param clientID string - the client id
 	* @param txManager *tx.TxManager - the transaction manager
 	* @return error - the error if there is one
-	* @return simple string "OK"
+	* @return simple string - OK if the key was set, nil if NX or XX prevented it
+	* @return bulk string - with GET, the old value, nil if the key didn't exist
 */
-func handleSet(writer *RESP.Writer, args []RESP.RESPMessage, store *store.Store, clientID string, txManager *tx.TxManager) error {
+func handleSet(writer *RESP.Writer, args []RESP.RESPMessage, st *store.Store, clientID string, txManager *tx.TxManager) error {
 
 	if len(args) < 2 {
 		err := errWrongNumberOfArguments("SET")
@@ -137,55 +336,62 @@ func handleSet(writer *RESP.Writer, args []RESP.RESPMessage, store *store.Store,
 	key := string(args[0].RESPValue)
 	value := args[1].RESPValue
 
-	var expiration time.Duration = 0
+	var options store.SetOptions
+	expireGiven := false
 
 	// starting from 2 because 0 and 1 will be key and value respectively
 	for i := 2; i < len(args); i++ {
 		option := strings.ToUpper(string(args[i].RESPValue))
 
 		switch option {
-		case "EX":
-			if i+1 < len(args) {
-				seconds, err := strconv.Atoi(string(args[i+1].RESPValue))
-				if err != nil {
-					return HandleError(writer, []byte("ERR invalid expire time"))
-
-				}
-				expiration = time.Duration(seconds) * time.Second
-				i++ // skip the next item, which will be the "value" for "EX"
+		case "EX", "PX", "EXAT", "PXAT":
+			if expireGiven || options.KeepTTL || i+1 >= len(args) {
+				return HandleError(writer, []byte(errSyntax.Error()))
 			}
-		case "PX":
-			if i+1 < len(args) {
-				milliseconds, err := strconv.Atoi(string(args[i+1].RESPValue))
-				if err != nil {
-					return HandleError(writer, []byte("ERR invalid expire time"))
-
-				}
-				expiration = time.Duration(milliseconds) * time.Millisecond
-				i++ // skip the next item, which will be the "value" for "PX"
+			expireAt, err := parseExpireOption(option, args[i+1].RESPValue, "SET")
+			if err != nil {
+				return HandleError(writer, []byte(err.Error()))
 			}
-		case "NX":
-			_, exists := store.Get(key)
-			if exists {
-				return writer.EncodeNil()
+			options.ExpireAt = expireAt
+			expireGiven = true
+			i++ // skip the next item, which will be the time
+		case "KEEPTTL":
+			if expireGiven {
+				return HandleError(writer, []byte(errSyntax.Error()))
 			}
-		case "XX":
-			_, exists := store.Get(key)
-			if !exists {
-				return writer.EncodeNil()
+			options.KeepTTL = true
+		case "NX", "XX":
+			condition := store.SetNX
+			if option == "XX" {
+				condition = store.SetXX
+			}
+			if options.Condition != store.SetAlways && options.Condition != condition {
+				return HandleError(writer, []byte(errSyntax.Error()))
 			}
+			options.Condition = condition
+		case "GET":
+			options.Get = true
 		default:
-			return HandleError(writer, []byte("ERR syntax error"))
+			return HandleError(writer, []byte(errSyntax.Error()))
 
 		}
 	}
 
-	store.Set(key, value, expiration)
+	old, _, done, err := st.SetWithOptions(key, value, options)
+	if err != nil {
+		return HandleError(writer, []byte(err.Error()))
+	}
+
+	if done {
+		// if the key is being watched, update the key's global version
+		signalModifiedKey(txManager, key)
+	}
 
-	// if the key is being watched, update the key's global version
-	_, exists := txManager.GetGlobalKeyVersions(key)
-	if exists {
-		txManager.UpdateGlobalKeyVersionsMap(key)
+	if options.Get {
+		return encodeBulkString(writer, old)
+	}
+	if !done {
+		return writer.EncodeNil()
 	}
 	return writer.Encode(&RESP.RESPMessage{
 		RESPType:  RESP.SimpleString,
@@ -212,7 +418,10 @@ func handleGet(writer *RESP.Writer, args []RESP.RESPMessage, store *store.Store,
 	}
 
 	key := string(args[0].RESPValue)
-	value, exists := store.Get(key)
+	value, exists, err := store.Get(key)
+	if err != nil {
+		return HandleError(writer, []byte(err.Error()))
+	}
 
 	if !exists {
 
@@ -265,6 +474,52 @@ func handleConfig(writer *RESP.Writer, args []RESP.RESPMessage, store *store.Sto
 				{RESPType: RESP.BulkString, RESPLen: len(dbFilename), RESPValue: []byte(dbFilename)},
 			}
 
+		case "appendonly", "appendfilename", "appendfsync", "aof-load-truncated":
+			aofConfig := config.GetAOFConfig()
+			values := map[string]string{
+				"appendonly":         yesNo(aofConfig.Enabled),
+				"appendfilename":     aofConfig.Filename,
+				"appendfsync":        aofConfig.Fsync,
+				"aof-load-truncated": yesNo(aofConfig.LoadTruncated),
+			}
+			value := values[parameter]
+			response = []RESP.RESPMessage{
+				{RESPType: RESP.BulkString, RESPLen: len(parameter), RESPValue: []byte(parameter)},
+				{RESPType: RESP.BulkString, RESPLen: len(value), RESPValue: []byte(value)},
+			}
+
+		case "hz", "active-expire-effort":
+			hz, activeExpireEffort := config.GetExpireConfig()
+			values := map[string]int{
+				"hz":                   hz,
+				"active-expire-effort": activeExpireEffort,
+			}
+			value := strconv.Itoa(values[parameter])
+			response = []RESP.RESPMessage{
+				{RESPType: RESP.BulkString, RESPLen: len(parameter), RESPValue: []byte(parameter)},
+				{RESPType: RESP.BulkString, RESPLen: len(value), RESPValue: []byte(value)},
+			}
+
+		case "keytrace-file", "keytrace-max-size", "keytrace-max-files":
+			keyTraceConfig := config.GetKeyTraceConfig()
+			values := map[string]string{
+				"keytrace-file":      keyTraceConfig.Filename,
+				"keytrace-max-size":  strconv.FormatInt(keyTraceConfig.MaxSize, 10),
+				"keytrace-max-files": strconv.Itoa(keyTraceConfig.MaxFiles),
+			}
+			value := values[parameter]
+			response = []RESP.RESPMessage{
+				{RESPType: RESP.BulkString, RESPLen: len(parameter), RESPValue: []byte(parameter)},
+				{RESPType: RESP.BulkString, RESPLen: len(value), RESPValue: []byte(value)},
+			}
+
+		case "node-id":
+			value := strconv.Itoa(config.GetNodeID())
+			response = []RESP.RESPMessage{
+				{RESPType: RESP.BulkString, RESPLen: len(parameter), RESPValue: []byte(parameter)},
+				{RESPType: RESP.BulkString, RESPLen: len(value), RESPValue: []byte(value)},
+			}
+
 		default:
 
 			response = []RESP.RESPMessage{}
@@ -336,28 +591,7 @@ func handleType(writer *RESP.Writer, args []RESP.RESPMessage, store *store.Store
 	}
 
 	inputKey := string(args[0].RESPValue)
-	keys := store.GetKeys("*")
-
-	var typeOfKey string
-
-	for _, key := range keys {
-
-		if inputKey == key {
-			typeOfKey = reflect.TypeOf(key).String()
-		}
-
-	}
-
-	if typeOfKey == "" {
-		isStream := store.IsStreamKey(inputKey)
-		if isStream {
-			typeOfKey = "stream"
-		}
-	}
-
-	if typeOfKey == "" {
-		typeOfKey = "none"
-	}
+	typeOfKey := store.Type(inputKey)
 
 	return writer.Encode(&RESP.RESPMessage{
 		RESPType:  RESP.SimpleString,
@@ -368,13 +602,14 @@ func handleType(writer *RESP.Writer, args []RESP.RESPMessage, store *store.Store
 
 /*
  	* handleXAdd handles the XADD command, adds a new entry to a stream
+	* XADD key [NOMKSTREAM] [MAXLEN|MINID [=|~] threshold [LIMIT count]] id field value [field value ...]
 	* @param writer *RESP.Writer - the writer to write the response to
 	* @param args []RESP.RESPMessage - the arguments for the command
 	* @param store *store.Store - the store to get the data from
 	* @param clientID string - the client id
 	* @param txManager *tx.TxManager - the transaction manager
 	* @return error - the error if there is one
-	* @return bulk string - the ID of the new entry
+	* @return bulk string - the ID of the new entry, nil if the stream doesn't exist and NOMKSTREAM is given
 */
 func handleXAdd(writer *RESP.Writer, args []RESP.RESPMessage, store *store.Store, clientID string, txManager *tx.TxManager) error {
 
@@ -385,31 +620,37 @@ func handleXAdd(writer *RESP.Writer, args []RESP.RESPMessage, store *store.Store
 	}
 
 	streamName := string(args[0].RESPValue)
-	id := string(args[1].RESPValue)
 
-	// Validate that we have an even number of field-value pairs
-	if (len(args)-2)%2 != 0 {
+	noMkStream, trim, idIdx, err := parseXAddOptions(args)
+	if err != nil {
+		return HandleError(writer, []byte(err.Error()))
+	}
+	id := string(args[idIdx].RESPValue)
+
+	// Validate that we have at least one and an even number of field-value pairs
+	if len(args)-idIdx-1 < 2 || (len(args)-idIdx-1)%2 != 0 {
 		err := errWrongNumberOfArguments("XADD")
 		return HandleError(writer, []byte(err.Error()))
 	}
 
 	dataMap := make(map[string][]byte)
-	for i := 2; i < len(args); i += 2 {
+	for i := idIdx + 1; i < len(args); i += 2 {
 		key := string(args[i].RESPValue)
 		value := args[i+1].RESPValue
 		dataMap[key] = value
 	}
 
-	streamRecord, ok, err := store.XAdd(streamName, id, dataMap)
+	streamRecord, ok, err := store.XAdd(streamName, id, dataMap, noMkStream, trim)
 
 	if err != nil {
 		return HandleError(writer, []byte(err.Error()))
 	}
 
 	if !ok {
-		return HandleError(writer, []byte("ERR failed to add entry to stream"))
+		return writer.EncodeNil()
 	}
 
+	signalModifiedKey(txManager, streamName)
 	return writer.Encode(&RESP.RESPMessage{
 		RESPType:  RESP.BulkString,
 		RESPLen:   len(streamRecord.Id),
@@ -418,7 +659,9 @@ func handleXAdd(writer *RESP.Writer, args []RESP.RESPMessage, store *store.Store
 }
 
 /*
- 	* handleXRange handles the XRANGE command, gets a range of entries from a stream
+ 	* handleXRange handles the XRANGE command, gets a range of entries from a stream, XRANGE key start end [COUNT count]
+	* the bounds are inclusive unless prefixed by "(", "-" and "+" are the first and last entries, and an ID given as just
+	* its milliseconds covers all of its sequence numbers
 	* @param writer *RESP.Writer - the writer to write the response to
 	* @param args []RESP.RESPMessage - the arguments for the command
 	* @param store *store.Store - the store to get the data from
@@ -428,7 +671,7 @@ func handleXAdd(writer *RESP.Writer, args []RESP.RESPMessage, store *store.Store
 	* @return array - The actual return value is a RESP Array of arrays. Each inner array represents an entry.The first item in the inner array is the ID of the entry.The second item is a list of key value pairs, where the key value pairs are represented as a list of strings.The key value pairs are in the order they were added to the entry.
 */
 func handleXRange(writer *RESP.Writer, args []RESP.RESPMessage, store *store.Store, clientID string, txManager *tx.TxManager) error {
-	if len(args) != 3 {
+	if len(args) < 3 {
 		err := errWrongNumberOfArguments("XRANGE")
 		return HandleError(writer, []byte(err.Error()))
 	}
@@ -437,10 +680,18 @@ func handleXRange(writer *RESP.Writer, args []RESP.RESPMessage, store *store.Sto
 	startId := string(args[1].RESPValue)
 	endId := string(args[2].RESPValue)
 
-	streamRecords, err := store.XRange(streamName, startId, endId)
+	count, err := parseXRangeCount(args[3:])
 	if err != nil {
 		return HandleError(writer, []byte(err.Error()))
 	}
+
+	streamRecords, err := store.XRange(streamName, startId, endId, max(count, 0))
+	if err != nil {
+		return HandleError(writer, []byte(err.Error()))
+	}
+	if count == 0 {
+		return writer.Encode(&RESP.RESPMessage{RESPType: RESP.Array, RESPLen: -1})
+	}
 	// final response
 	// [
 	//   [
@@ -467,22 +718,70 @@ func handleXRange(writer *RESP.Writer, args []RESP.RESPMessage, store *store.Sto
 	})
 }
 
-func handleXRead(writer *RESP.Writer, args []RESP.RESPMessage, streamStore *store.Store, clientID string, txManager *tx.TxManager) error {
+/*
+ 	* handleXRevRange handles the XREVRANGE command, XRANGE with the bounds swapped and the entries from the end,
+	* XREVRANGE key end start [COUNT count]
+	* @param writer *RESP.Writer - the writer to write the response to
+	* @param args []RESP.RESPMessage - the arguments for the command
+	* @param store *store.Store - the store to get the data from
+	* @param clientID string - the client id
+	* @param txManager *tx.TxManager - the transaction manager
+	* @return error - the error if there is one
+	* @return array - the entries, the last one first, like XRANGE
+*/
+func handleXRevRange(writer *RESP.Writer, args []RESP.RESPMessage, store *store.Store, clientID string, txManager *tx.TxManager) error {
+	if len(args) < 3 {
+		err := errWrongNumberOfArguments("XREVRANGE")
+		return HandleError(writer, []byte(err.Error()))
+	}
+
+	count, err := parseXRangeCount(args[3:])
+	if err != nil {
+		return HandleError(writer, []byte(err.Error()))
+	}
+
+	streamRecords, err := store.XRevRange(string(args[0].RESPValue), string(args[1].RESPValue), string(args[2].RESPValue), max(count, 0))
+	if err != nil {
+		return HandleError(writer, []byte(err.Error()))
+	}
+	if count == 0 {
+		return writer.Encode(&RESP.RESPMessage{RESPType: RESP.Array, RESPLen: -1})
+	}
+
+	entries := store.CreateStreamMessages(streamRecords)
+	return writer.Encode(&RESP.RESPMessage{
+		RESPType:      RESP.Array,
+		RESPLen:       len(entries),
+		RESPArrayElem: entries,
+	})
+}
+
+/*
+ 	* handleXRead handles the XREAD command, XREAD [COUNT count] [BLOCK ms] STREAMS key [key ...] id [id ...]
+	* @param writer *RESP.Writer - the writer to write the response to
+	* @param args []RESP.RESPMessage - the arguments for the command
+	* @param st *store.Store - the store to get the data from
+	* @param clientID string - the client id
+	* @param txManager *tx.TxManager - the transaction manager
+	* @return array - the name and the entries after the given ID of each stream that had any, nil if there were none,
+	* with BLOCK it waits for one of the streams to get some, even a stream that doesn't exist yet
+*/
+func handleXRead(writer *RESP.Writer, args []RESP.RESPMessage, st *store.Store, clientID string, txManager *tx.TxManager) error {
 	if len(args) < 3 {
 		err := errWrongNumberOfArguments("XREAD")
 		return HandleError(writer, []byte(err.Error()))
 	}
 
 	blockMs := -1
-	count := -1
+	count := 0
 	streamStartIdx := -1
 
+options:
 	for i := 0; i < len(args); i++ {
-		option := strings.ToUpper(string(args[i].RESPValue))
-		switch option {
+		switch strings.ToUpper(string(args[i].RESPValue)) {
 		case "BLOCK":
 			if i+1 >= len(args) {
-				return HandleError(writer, []byte("ERR syntax error"))
+				return HandleError(writer, []byte(errSyntax.Error()))
 			}
 			var err error
 			blockMs, err = strconv.Atoi(string(args[i+1].RESPValue))
@@ -492,7 +791,7 @@ func handleXRead(writer *RESP.Writer, args []RESP.RESPMessage, streamStore *stor
 			i++ // skip the block value
 		case "COUNT":
 			if i+1 >= len(args) {
-				return HandleError(writer, []byte("ERR syntax error"))
+				return HandleError(writer, []byte(errSyntax.Error()))
 			}
 			var err error
 			count, err = strconv.Atoi(string(args[i+1].RESPValue))
@@ -502,144 +801,75 @@ func handleXRead(writer *RESP.Writer, args []RESP.RESPMessage, streamStore *stor
 			i++ // skip the count value
 		case "STREAMS":
 			streamStartIdx = i + 1
-			i = len(args) // exit the loop by setting i to length of args
+			break options
+		default:
+			return HandleError(writer, []byte(errSyntax.Error()))
 		}
 	}
 
 	if streamStartIdx == -1 {
-		return HandleError(writer, []byte("ERR syntax error"))
+		return HandleError(writer, []byte(errSyntax.Error()))
 	}
 
-	// calculate number of streams and validate argument count
-	// the total args for XREAD will always be odd, and removing the arg which is "STREAMS" and even pair of "COUNT" and "BLOCK", there will just streamName(s) and id(s)
-	remainingArgs := len(args) - streamStartIdx
-	if remainingArgs%2 != 0 {
-		err := errWrongNumberOfArguments("XREAD")
-		return HandleError(writer, []byte(err.Error()))
+	// as many ids as stream names, each id going with the stream at the same position
+	streams := args[streamStartIdx:]
+	if len(streams) == 0 || len(streams)%2 != 0 {
+		return HandleError(writer, []byte("ERR Unbalanced 'xread' list of streams: for each stream key an ID or '$' must be specified."))
 	}
-	numStreams := remainingArgs / 2
-
-	// collect stream names and ids
-	streamNames := args[streamStartIdx : streamStartIdx+numStreams]
-	streamIds := args[streamStartIdx+numStreams:]
-
-	var streamRecords []store.StreamRecord
-	var err error
-
-	// Process each stream
-	finalResponse := make([]RESP.RESPMessage, 0, numStreams)
-	for i := 0; i < numStreams; i++ {
-		streamName := string(streamNames[i].RESPValue)
-		startId := string(streamIds[i].RESPValue)
-
-		if blockMs >= 0 {
-			var noTimeout bool = false
-			if blockMs == 0 {
-				noTimeout = true
-			}
-			streamRecords, err = streamStore.XReadBlock(streamName, startId, blockMs, noTimeout)
-		} else {
-			streamRecords, err = streamStore.XRead(streamName, startId)
-		}
-
-		if err != nil {
-			fmt.Print(err.Error())
-			continue
-		}
-
-		// no records found in blocking mode, return nil
-		if blockMs > 0 && len(streamRecords) == 0 {
-			return writer.EncodeNil()
+	streamNames := keyArgs(streams[:len(streams)/2])
+	ids := keyArgs(streams[len(streams)/2:])
+
+	blockedInSession := blockMs >= 0 && txManager.HasSession(clientID)
+	if blockedInSession {
+		// the session keeps where the read waits, "$" resolved, so a client that loses its connection while
+		// blocked can read on from there after SESSION RESUME
+		var err error
+		if ids, err = st.XReadPosition(streamNames, ids); err != nil {
+			return HandleError(writer, []byte(err.Error()))
 		}
-
-		if len(streamRecords) == 0 {
-			fmt.Printf("stream record length is 0 for stream: %v, id: %v", streamName, startId)
-			continue
+		position := make([]string, 0, 2*len(ids))
+		for i, id := range ids {
+			position = append(position, streamNames[i], id)
 		}
-
-		// final response
-		// [
-		//   [
-		//     "some_key", this is the stream name
-		//     [
-		//       [
-		//         "1526985054079-0",
-		//         [
-		//           "temperature",
-		//           "37",
-		//           "humidity",
-		//           "94"
-		//         ]
-		//       ]
-		//     ]
-		//   ]
-		// ]
-
-		entries := streamStore.CreateStreamMessages(streamRecords)
-		streamResponse := RESP.RESPMessage{
-			RESPType: RESP.Array,
-			RESPLen:  2,
-			RESPArrayElem: []RESP.RESPMessage{
-				{
-					RESPType:  RESP.BulkString,
-					RESPLen:   len(streamName),
-					RESPValue: []byte(streamName),
-				},
-				{
-					RESPType:      RESP.Array,
-					RESPLen:       len(entries),
-					RESPArrayElem: entries,
-				},
-			},
-		}
-		finalResponse = append(finalResponse, streamResponse)
+		txManager.SetReadPosition(clientID, position)
 	}
 
-	return writer.Encode(&RESP.RESPMessage{
-		RESPType:      RESP.Array,
-		RESPLen:       len(finalResponse),
-		RESPArrayElem: finalResponse,
-	})
-}
-
-func handleIncr(writer *RESP.Writer, args []RESP.RESPMessage, store *store.Store, clientID string, txManager *tx.TxManager) error {
-	if len(args) != 1 {
-		err := errWrongNumberOfArguments("INCR")
+	var entries []store.StreamEntries
+	var err error
+	if blockMs >= 0 {
+		entries, err = st.XReadBlock(streamNames, ids, count, blockMs, blockMs == 0)
+	} else {
+		entries, err = st.XRead(streamNames, ids, count)
+	}
+	if err != nil {
 		return HandleError(writer, []byte(err.Error()))
 	}
 
-	key := string(args[0].RESPValue)
-	value, exists := store.Get(key)
-
-	// if the key is being watched, update the key's global version
-	_, globalVersionExists := txManager.GetGlobalKeyVersions(key)
-	if globalVersionExists {
-		txManager.UpdateGlobalKeyVersionsMap(key)
+	if len(entries) == 0 {
+		err = writer.EncodeNil()
+	} else {
+		err = encodeArray(writer, streamEntriesMessages(st, entries))
 	}
-
-	var newValue int
-	if !exists {
-		// if the key doesn't exist, set to 1
-		store.Set(key, []byte("1"), 0)
-		return writer.Encode(&RESP.RESPMessage{
-			RESPType:  RESP.Integer,
-			RESPValue: []byte("1"),
-		})
+	if blockedInSession && err == nil {
+		txManager.SetReadPosition(clientID, nil) // the client got the entries, nothing left to resume
 	}
+	return err
+}
 
-	// if the existing value is an integer or not
-	currentValue, err := strconv.Atoi(string(value))
-	if err != nil {
-		return HandleError(writer, []byte("ERR value is not an integer or out of range"))
+/*
+ 	* handleQuit handles the QUIT command, replies OK and closes the connection
+	* @param writer *RESP.Writer - the writer to write the response to
+	* @param args []RESP.RESPMessage - the arguments for the command
+	* @param store *store.Store - the store to get the data from
+	* @param clientID string - the client id
+	* @param txManager *tx.TxManager - the transaction manager
+	* @return error - ErrClientClosed once OK is written
+*/
+func handleQuit(writer *RESP.Writer, args []RESP.RESPMessage, store *store.Store, clientID string, txManager *tx.TxManager) error {
+	if err := encodeOK(writer); err != nil {
+		return err
 	}
-
-	newValue = currentValue + 1
-	store.Set(key, []byte(strconv.Itoa(newValue)), 0)
-
-	return writer.Encode(&RESP.RESPMessage{
-		RESPType:  RESP.Integer,
-		RESPValue: []byte(strconv.Itoa(newValue)),
-	})
+	return ErrClientClosed
 }
 
 /*
@@ -693,40 +923,89 @@ func handleExec(writer *RESP.Writer, args []RESP.RESPMessage, store *store.Store
 		})
 	}
 
-	responses := make([]RESP.RESPMessage, 0, len(commands))
-
-	// execute each command in the transaction and collect responses
-	for _, command := range commands {
-		cmd := strings.ToUpper(string(command.Cmd.RESPValue))
+	if config.GetAOFConfig().Enabled {
+		for _, command := range commands {
+			if !isWriteCommand(strings.ToUpper(string(command.Cmd.RESPValue))) {
+				continue
+			}
+			if err := config.AOFWriteError(); err != nil {
+				return HandleError(writer, []byte("EXECABORT Transaction discarded because of: "+err.Error()))
+			}
+			break
+		}
+	}
 
-		handler, exists := getHandlers(cmd)
-		if !exists {
-			return HandleError(writer, []byte(fmt.Sprintf("ERR unknown command '%s'", cmd)))
+	// the commands and the append of those that wrote hold aofMu all along, so no other write lands in the AOF between them
+	reply, err := func() (*RESP.RESPMessage, error) {
+		if config.GetAOFConfig().Enabled {
+			aofMu.Lock()
+			defer aofMu.Unlock()
 		}
 
-		var respBuf bytes.Buffer
-		tempWriter := RESP.NewWriter(&respBuf)
+		responses := make([]RESP.RESPMessage, 0, len(commands))
+		var propagated [][][]byte // write commands that succeeded, appended to the AOF as one MULTI/EXEC block
 
-		err = handler(tempWriter, command.Args, store, clientID, txManager)
-		if err != nil {
-			return HandleError(writer, []byte(err.Error()))
+		// execute each command in the transaction and collect responses
+		for _, command := range commands {
+			cmd := strings.ToUpper(string(command.Cmd.RESPValue))
+
+			handler, exists := lookupHandler(cmd, clientID)
+			if !exists {
+				return nil, fmt.Errorf("ERR unknown command '%s'", cmd)
+			}
+
+			var respBuf bytes.Buffer
+			tempWriter := RESP.NewWriter(&respBuf)
+
+			// like Redis, a blocking read in a transaction returns right away, waiting for a write of another
+			// client while holding aofMu could never end
+			switch cmd {
+			case "XREAD":
+				command.Args = withoutBlock(command.Args, 0)
+			case "XREADGROUP":
+				command.Args = withoutBlock(command.Args, 3)
+			}
+
+			err := handler(tempWriter, command.Args, store, clientID, txManager)
+			if err != nil {
+				return nil, err
+			}
+
+			// Decode the response from the buffer
+			reader := RESP.NewReader(&respBuf)
+			resp, err := reader.Decode()
+			if err != nil {
+				return nil, errors.New("ERR failed to decode response")
+			}
+
+			responses = append(responses, *resp)
+
+			if traced := tracedKeys(cmd, command.Args); traced != nil {
+				traceCommand(cmd, command.Args, traced, clientID, resp)
+			}
+
+			if isWriteCommand(cmd) && !resp.IsError() {
+				if argv := aofArgv(cmd, command.Args, resp); argv != nil {
+					propagated = append(propagated, argv)
+				}
+			}
 		}
 
-		// Decode the response from the buffer
-		reader := RESP.NewReader(&respBuf)
-		resp, err := reader.Decode()
-		if err != nil {
-			return HandleError(writer, []byte("ERR failed to decode response"))
+		if err := config.AppendTransaction(propagated); err != nil {
+			// the block is kept and retried, but the client must not take it as safely logged
+			return &RESP.RESPMessage{RESPType: RESP.Error, RESPValue: []byte(err.Error())}, nil
 		}
 
-		responses = append(responses, *resp)
+		return &RESP.RESPMessage{
+			RESPType:      RESP.Array,
+			RESPLen:       len(responses),
+			RESPArrayElem: responses,
+		}, nil
+	}()
+	if err != nil {
+		return HandleError(writer, []byte(err.Error()))
 	}
-
-	return writer.Encode(&RESP.RESPMessage{
-		RESPType:      RESP.Array,
-		RESPLen:       len(responses),
-		RESPArrayElem: responses,
-	})
+	return writer.Encode(reply)
 }
 
 /**
@@ -789,14 +1068,26 @@ func ExecuteCommand(writer *RESP.Writer, cmd string, args []RESP.RESPMessage, st
 	// convert command to uppercase for case-insensitive matching
 	cmd = strings.ToUpper(cmd)
 
-	handler, exists := getHandlers(cmd)
+	handler, exists := lookupHandler(cmd, clientID)
 	if !exists {
 		log.Printf("cmd:%v, does not exist", cmd)
 		return HandleError(writer, []byte("ERR unknown command"))
 	}
 
+	// subscribing writes a reply for each channel and then messages, none of which fits in the reply of EXEC
+	if _, subscribe := subscribedModeCommands[cmd]; subscribe && cmd != "PING" && cmd != "QUIT" && txManager.InMulti(clientID) {
+		return HandleError(writer, []byte("ERR Command not allowed inside a transaction"))
+	}
+
+	// like Redis, writes are refused while the AOF can't be written to, rather than made and lost on a restart
+	if isWriteCommand(cmd) {
+		if err := config.AOFWriteError(); err != nil {
+			return HandleError(writer, []byte(err.Error()))
+		}
+	}
+
 	// if in MULTI, queue commands except for transaction-related ones
-	if cmd != "MULTI" && cmd != "EXEC" && cmd != "DISCARD" && cmd != "WATCH" && cmd != "UNWATCH" {
+	if cmd != "MULTI" && cmd != "EXEC" && cmd != "DISCARD" && cmd != "WATCH" && cmd != "UNWATCH" && cmd != "SESSION" {
 
 		err := txManager.Queue(clientID, RESP.RESPMessage{
 			RESPType:  RESP.BulkString,
@@ -813,5 +1104,79 @@ func ExecuteCommand(writer *RESP.Writer, cmd string, args []RESP.RESPMessage, st
 		}
 	}
 
+	propagate := isWriteCommand(cmd) && config.GetAOFConfig().Enabled
+	traced := tracedKeys(cmd, args) // nil unless KEYTRACE asked for one of the keys
+
+	if propagate || traced != nil {
+		return executeAndPropagate(writer, handler, cmd, args, store, clientID, txManager, propagate, traced)
+	}
+
 	return handler(writer, args, store, clientID, txManager)
 }
+
+/*
+ 	* executeAndAppend runs a handler and, if propagate is set and the command succeeded, appends it to the AOF,
+	* both while holding aofMu, the reply is not written to the client yet so a slow client doesn't hold up the others
+	* @param handler commandHandler - the handler of the command
+	* @param cmd string - the command, in uppercase
+	* @param args []RESP.RESPMessage - the arguments for the command
+	* @param store *store.Store - the store to get the data from
+	* @param clientID string - the client id
+	* @param txManager *tx.TxManager - the transaction manager
+	* @param propagate bool - append the command to the AOF
+	* @return *RESP.RESPMessage - the reply, an error if the command could not be appended
+	* @return error - the error of the handler
+*/
+func executeAndAppend(handler commandHandler, cmd string, args []RESP.RESPMessage, store *store.Store, clientID string, txManager *tx.TxManager, propagate bool) (*RESP.RESPMessage, error) {
+	if propagate {
+		aofMu.Lock()
+		defer aofMu.Unlock()
+	}
+
+	var respBuf bytes.Buffer
+	if err := handler(RESP.NewWriter(&respBuf), args, store, clientID, txManager); err != nil {
+		return nil, err
+	}
+
+	reply, err := RESP.NewReader(&respBuf).Decode()
+	if err != nil {
+		return &RESP.RESPMessage{RESPType: RESP.Error, RESPValue: []byte("ERR failed to decode response")}, nil
+	}
+
+	if propagate && !reply.IsError() {
+		if argv := aofArgv(cmd, args, reply); argv != nil {
+			if err := config.AppendCommand(argv); err != nil {
+				// the command is kept and retried, but the client must not take it as safely logged
+				return &RESP.RESPMessage{RESPType: RESP.Error, RESPValue: []byte(err.Error())}, nil
+			}
+		}
+	}
+	return reply, nil
+}
+
+/*
+ 	* executeAndPropagate executes a command, appends it to the AOF if it is a write that succeeded and writes it
+	* to the key trace log if it touched a traced key, before replying to the client
+	* @param writer *RESP.Writer - the writer to write the response to
+	* @param handler commandHandler - the handler of the command
+	* @param cmd string - the command to execute, in uppercase
+	* @param args []RESP.RESPMessage - the arguments for the command
+	* @param store *store.Store - the store to get the data from
+	* @param clientID string - the client id
+	* @param txManager *tx.TxManager - the transaction manager
+	* @param propagate bool - append the command to the AOF
+	* @param traced []string - the keys of the command matching a KEYTRACE, nil if it is not traced
+	* @return error - the error if there is one
+*/
+func executeAndPropagate(writer *RESP.Writer, handler commandHandler, cmd string, args []RESP.RESPMessage, store *store.Store, clientID string, txManager *tx.TxManager, propagate bool, traced []string) error {
+	reply, err := executeAndAppend(handler, cmd, args, store, clientID, txManager, propagate)
+	if err != nil {
+		return err
+	}
+
+	if traced != nil {
+		traceCommand(cmd, args, traced, clientID, reply)
+	}
+
+	return writer.Encode(reply)
+}
@@ -0,0 +1,823 @@
+package handlers
+
+import (
+	"errors"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	RESP "github.com/manish-singh-bisht/Redis-From-Scratch/db/resp"
+	store "github.com/manish-singh-bisht/Redis-From-Scratch/db/store"
+	tx "github.com/manish-singh-bisht/Redis-From-Scratch/db/transaction"
+)
+
+// the latest field expiration HEXPIRE accepts, in milliseconds, like Redis
+const hashFieldMaxExpireMs = 1 << 48
+
+var (
+	errHashFieldsMissing     = errors.New("ERR Mandatory argument FIELDS is missing or not at the right position")
+	errHashNumFieldsInvalid  = errors.New("ERR Parameter `numFields` should be greater than 0")
+	errHashNumFieldsMismatch = errors.New("ERR The `numfields` parameter must match the number of arguments")
+)
+
+/*
+ 	* handleHSet handles the HSET command, HSET key field value [field value ...]
+	* @param writer *RESP.Writer - the writer to write the response to
+	* @param args []RESP.RESPMessage - the arguments for the command
+	* @param store *store.Store - the store to get the data from
+	* @param clientID string - the client id
+	* @param txManager *tx.TxManager - the transaction manager
+	* @return error - the error if there is one
+	* @return integer - the number of fields that were added
+*/
+func handleHSet(writer *RESP.Writer, args []RESP.RESPMessage, store *store.Store, clientID string, txManager *tx.TxManager) error {
+	if len(args) < 3 || len(args)%2 == 0 {
+		err := errWrongNumberOfArguments("HSET")
+		return HandleError(writer, []byte(err.Error()))
+	}
+
+	key := string(args[0].RESPValue)
+
+	added, err := store.HSet(key, argValues(args[1:]), false)
+	if err != nil {
+		return HandleError(writer, []byte(err.Error()))
+	}
+
+	signalModifiedKey(txManager, key)
+
+	return encodeInteger(writer, int64(added))
+}
+
+/*
+ 	* handleHMSet handles the HMSET command, HMSET key field value [field value ...], the older form of HSET
+	* @param writer *RESP.Writer - the writer to write the response to
+	* @param args []RESP.RESPMessage - the arguments for the command
+	* @param store *store.Store - the store to get the data from
+	* @param clientID string - the client id
+	* @param txManager *tx.TxManager - the transaction manager
+	* @return error - the error if there is one
+	* @return simple string - OK
+*/
+func handleHMSet(writer *RESP.Writer, args []RESP.RESPMessage, store *store.Store, clientID string, txManager *tx.TxManager) error {
+	if len(args) < 3 || len(args)%2 == 0 {
+		err := errWrongNumberOfArguments("HMSET")
+		return HandleError(writer, []byte(err.Error()))
+	}
+
+	key := string(args[0].RESPValue)
+
+	if _, err := store.HSet(key, argValues(args[1:]), false); err != nil {
+		return HandleError(writer, []byte(err.Error()))
+	}
+
+	signalModifiedKey(txManager, key)
+
+	return encodeOK(writer)
+}
+
+/*
+ 	* handleHSetNX handles the HSETNX command, HSETNX key field value, sets the field only if it doesn't exist
+	* @param writer *RESP.Writer - the writer to write the response to
+	* @param args []RESP.RESPMessage - the arguments for the command
+	* @param store *store.Store - the store to get the data from
+	* @param clientID string - the client id
+	* @param txManager *tx.TxManager - the transaction manager
+	* @return error - the error if there is one
+	* @return integer - 1 if the field was set, 0 if it already exists
+*/
+func handleHSetNX(writer *RESP.Writer, args []RESP.RESPMessage, store *store.Store, clientID string, txManager *tx.TxManager) error {
+	if len(args) != 3 {
+		err := errWrongNumberOfArguments("HSETNX")
+		return HandleError(writer, []byte(err.Error()))
+	}
+
+	key := string(args[0].RESPValue)
+
+	added, err := store.HSet(key, argValues(args[1:]), true)
+	if err != nil {
+		return HandleError(writer, []byte(err.Error()))
+	}
+
+	if added > 0 {
+		signalModifiedKey(txManager, key)
+	}
+
+	return encodeInteger(writer, int64(added))
+}
+
+/*
+ 	* handleHGet handles the HGET command, HGET key field
+	* @param writer *RESP.Writer - the writer to write the response to
+	* @param args []RESP.RESPMessage - the arguments for the command
+	* @param store *store.Store - the store to get the data from
+	* @param clientID string - the client id
+	* @param txManager *tx.TxManager - the transaction manager
+	* @return error - the error if there is one
+	* @return bulk string - the value of the field, nil if the key or the field doesn't exist
+*/
+func handleHGet(writer *RESP.Writer, args []RESP.RESPMessage, store *store.Store, clientID string, txManager *tx.TxManager) error {
+	if len(args) != 2 {
+		err := errWrongNumberOfArguments("HGET")
+		return HandleError(writer, []byte(err.Error()))
+	}
+
+	value, exists, err := store.HGet(string(args[0].RESPValue), string(args[1].RESPValue))
+	if err != nil {
+		return HandleError(writer, []byte(err.Error()))
+	}
+	if !exists {
+		return writer.EncodeNil()
+	}
+
+	return writer.Encode(&RESP.RESPMessage{
+		RESPType:  RESP.BulkString,
+		RESPLen:   len(value),
+		RESPValue: value,
+	})
+}
+
+/*
+ 	* handleHMGet handles the HMGET command, HMGET key field [field ...]
+	* @param writer *RESP.Writer - the writer to write the response to
+	* @param args []RESP.RESPMessage - the arguments for the command
+	* @param store *store.Store - the store to get the data from
+	* @param clientID string - the client id
+	* @param txManager *tx.TxManager - the transaction manager
+	* @return error - the error if there is one
+	* @return array - the value of each field, nil for the fields that don't exist
+*/
+func handleHMGet(writer *RESP.Writer, args []RESP.RESPMessage, store *store.Store, clientID string, txManager *tx.TxManager) error {
+	if len(args) < 2 {
+		err := errWrongNumberOfArguments("HMGET")
+		return HandleError(writer, []byte(err.Error()))
+	}
+
+	values, err := store.HMGet(string(args[0].RESPValue), keyArgs(args[1:]))
+	if err != nil {
+		return HandleError(writer, []byte(err.Error()))
+	}
+
+	return encodeArray(writer, bulkStringMessages(values))
+}
+
+/*
+ 	* handleHDel handles the HDEL command, HDEL key field [field ...]
+	* @param writer *RESP.Writer - the writer to write the response to
+	* @param args []RESP.RESPMessage - the arguments for the command
+	* @param store *store.Store - the store to get the data from
+	* @param clientID string - the client id
+	* @param txManager *tx.TxManager - the transaction manager
+	* @return error - the error if there is one
+	* @return integer - the number of fields that were deleted
+*/
+func handleHDel(writer *RESP.Writer, args []RESP.RESPMessage, store *store.Store, clientID string, txManager *tx.TxManager) error {
+	if len(args) < 2 {
+		err := errWrongNumberOfArguments("HDEL")
+		return HandleError(writer, []byte(err.Error()))
+	}
+
+	key := string(args[0].RESPValue)
+
+	deleted, err := store.HDel(key, keyArgs(args[1:]))
+	if err != nil {
+		return HandleError(writer, []byte(err.Error()))
+	}
+
+	if deleted > 0 {
+		signalModifiedKey(txManager, key)
+	}
+
+	return encodeInteger(writer, int64(deleted))
+}
+
+/*
+ 	* handleHLen handles the HLEN command, HLEN key
+	* @param writer *RESP.Writer - the writer to write the response to
+	* @param args []RESP.RESPMessage - the arguments for the command
+	* @param store *store.Store - the store to get the data from
+	* @param clientID string - the client id
+	* @param txManager *tx.TxManager - the transaction manager
+	* @return error - the error if there is one
+	* @return integer - the number of fields, 0 if the key doesn't exist
+*/
+func handleHLen(writer *RESP.Writer, args []RESP.RESPMessage, store *store.Store, clientID string, txManager *tx.TxManager) error {
+	if len(args) != 1 {
+		err := errWrongNumberOfArguments("HLEN")
+		return HandleError(writer, []byte(err.Error()))
+	}
+
+	length, err := store.HLen(string(args[0].RESPValue))
+	if err != nil {
+		return HandleError(writer, []byte(err.Error()))
+	}
+
+	return encodeInteger(writer, int64(length))
+}
+
+/*
+ 	* handleHExists handles the HEXISTS command, HEXISTS key field
+	* @param writer *RESP.Writer - the writer to write the response to
+	* @param args []RESP.RESPMessage - the arguments for the command
+	* @param store *store.Store - the store to get the data from
+	* @param clientID string - the client id
+	* @param txManager *tx.TxManager - the transaction manager
+	* @return error - the error if there is one
+	* @return integer - 1 if the field exists, 0 otherwise
+*/
+func handleHExists(writer *RESP.Writer, args []RESP.RESPMessage, store *store.Store, clientID string, txManager *tx.TxManager) error {
+	if len(args) != 2 {
+		err := errWrongNumberOfArguments("HEXISTS")
+		return HandleError(writer, []byte(err.Error()))
+	}
+
+	_, exists, err := store.HGet(string(args[0].RESPValue), string(args[1].RESPValue))
+	if err != nil {
+		return HandleError(writer, []byte(err.Error()))
+	}
+	if !exists {
+		return encodeInteger(writer, 0)
+	}
+
+	return encodeInteger(writer, 1)
+}
+
+/*
+ 	* handleHStrLen handles the HSTRLEN command, HSTRLEN key field
+	* @param writer *RESP.Writer - the writer to write the response to
+	* @param args []RESP.RESPMessage - the arguments for the command
+	* @param store *store.Store - the store to get the data from
+	* @param clientID string - the client id
+	* @param txManager *tx.TxManager - the transaction manager
+	* @return error - the error if there is one
+	* @return integer - the length of the value of the field, 0 if the key or the field doesn't exist
+*/
+func handleHStrLen(writer *RESP.Writer, args []RESP.RESPMessage, store *store.Store, clientID string, txManager *tx.TxManager) error {
+	if len(args) != 2 {
+		err := errWrongNumberOfArguments("HSTRLEN")
+		return HandleError(writer, []byte(err.Error()))
+	}
+
+	value, _, err := store.HGet(string(args[0].RESPValue), string(args[1].RESPValue))
+	if err != nil {
+		return HandleError(writer, []byte(err.Error()))
+	}
+
+	return encodeInteger(writer, int64(len(value)))
+}
+
+/*
+ 	* handleHGetAll handles the HGETALL command, HGETALL key
+	* @param writer *RESP.Writer - the writer to write the response to
+	* @param args []RESP.RESPMessage - the arguments for the command
+	* @param store *store.Store - the store to get the data from
+	* @param clientID string - the client id
+	* @param txManager *tx.TxManager - the transaction manager
+	* @return error - the error if there is one
+	* @return array - field, value, field, value..., empty if the key doesn't exist
+*/
+func handleHGetAll(writer *RESP.Writer, args []RESP.RESPMessage, store *store.Store, clientID string, txManager *tx.TxManager) error {
+	return hashGetAllGeneric(writer, args, store, "HGETALL", true, true)
+}
+
+/*
+ 	* handleHKeys handles the HKEYS command, HKEYS key
+	* @param writer *RESP.Writer - the writer to write the response to
+	* @param args []RESP.RESPMessage - the arguments for the command
+	* @param store *store.Store - the store to get the data from
+	* @param clientID string - the client id
+	* @param txManager *tx.TxManager - the transaction manager
+	* @return error - the error if there is one
+	* @return array - the fields, empty if the key doesn't exist
+*/
+func handleHKeys(writer *RESP.Writer, args []RESP.RESPMessage, store *store.Store, clientID string, txManager *tx.TxManager) error {
+	return hashGetAllGeneric(writer, args, store, "HKEYS", true, false)
+}
+
+/*
+ 	* handleHVals handles the HVALS command, HVALS key
+	* @param writer *RESP.Writer - the writer to write the response to
+	* @param args []RESP.RESPMessage - the arguments for the command
+	* @param store *store.Store - the store to get the data from
+	* @param clientID string - the client id
+	* @param txManager *tx.TxManager - the transaction manager
+	* @return error - the error if there is one
+	* @return array - the values, empty if the key doesn't exist
+*/
+func handleHVals(writer *RESP.Writer, args []RESP.RESPMessage, store *store.Store, clientID string, txManager *tx.TxManager) error {
+	return hashGetAllGeneric(writer, args, store, "HVALS", false, true)
+}
+
+/*
+ 	* hashGetAllGeneric implements HGETALL, HKEYS and HVALS
+	* @param writer *RESP.Writer - the writer to write the response to
+	* @param args []RESP.RESPMessage - the arguments for the command
+	* @param st *store.Store - the store to get the data from
+	* @param cmd string - the name of the command, for errors
+	* @param withFields bool - include the fields in the reply
+	* @param withValues bool - include the values in the reply
+	* @return error - the error if there is one
+*/
+func hashGetAllGeneric(writer *RESP.Writer, args []RESP.RESPMessage, st *store.Store, cmd string, withFields, withValues bool) error {
+	if len(args) != 1 {
+		err := errWrongNumberOfArguments(cmd)
+		return HandleError(writer, []byte(err.Error()))
+	}
+
+	pairs, err := st.HGetAll(string(args[0].RESPValue))
+	if err != nil {
+		return HandleError(writer, []byte(err.Error()))
+	}
+
+	elements := make([]RESP.RESPMessage, 0, len(pairs))
+	for i := 0; i < len(pairs); i += 2 {
+		if withFields {
+			elements = append(elements, bulkStringMessage(pairs[i]))
+		}
+		if withValues {
+			elements = append(elements, bulkStringMessage(pairs[i+1]))
+		}
+	}
+
+	return encodeArray(writer, elements)
+}
+
+/*
+ 	* handleHIncrBy handles the HINCRBY command, HINCRBY key field increment
+	* @param writer *RESP.Writer - the writer to write the response to
+	* @param args []RESP.RESPMessage - the arguments for the command
+	* @param store *store.Store - the store to get the data from
+	* @param clientID string - the client id
+	* @param txManager *tx.TxManager - the transaction manager
+	* @return error - the error if there is one
+	* @return integer - the value of the field after the increment
+*/
+func handleHIncrBy(writer *RESP.Writer, args []RESP.RESPMessage, store *store.Store, clientID string, txManager *tx.TxManager) error {
+	if len(args) != 3 {
+		err := errWrongNumberOfArguments("HINCRBY")
+		return HandleError(writer, []byte(err.Error()))
+	}
+
+	key := string(args[0].RESPValue)
+
+	delta, err := strconv.ParseInt(string(args[2].RESPValue), 10, 64)
+	if err != nil {
+		return HandleError(writer, []byte("ERR value is not an integer or out of range"))
+	}
+
+	value, err := store.HIncrBy(key, string(args[1].RESPValue), delta)
+	if err != nil {
+		return HandleError(writer, []byte(err.Error()))
+	}
+
+	signalModifiedKey(txManager, key)
+
+	return encodeInteger(writer, value)
+}
+
+/*
+ 	* handleHIncrByFloat handles the HINCRBYFLOAT command, HINCRBYFLOAT key field increment
+	* @param writer *RESP.Writer - the writer to write the response to
+	* @param args []RESP.RESPMessage - the arguments for the command
+	* @param store *store.Store - the store to get the data from
+	* @param clientID string - the client id
+	* @param txManager *tx.TxManager - the transaction manager
+	* @return error - the error if there is one
+	* @return bulk string - the value of the field after the increment
+*/
+func handleHIncrByFloat(writer *RESP.Writer, args []RESP.RESPMessage, store *store.Store, clientID string, txManager *tx.TxManager) error {
+	if len(args) != 3 {
+		err := errWrongNumberOfArguments("HINCRBYFLOAT")
+		return HandleError(writer, []byte(err.Error()))
+	}
+
+	key := string(args[0].RESPValue)
+
+	delta, err := strconv.ParseFloat(string(args[2].RESPValue), 64)
+	if err != nil || math.IsNaN(delta) || math.IsInf(delta, 0) {
+		return HandleError(writer, []byte("ERR value is not a valid float"))
+	}
+
+	value, err := store.HIncrByFloat(key, string(args[1].RESPValue), delta)
+	if err != nil {
+		return HandleError(writer, []byte(err.Error()))
+	}
+
+	signalModifiedKey(txManager, key)
+
+	return writer.Encode(&RESP.RESPMessage{
+		RESPType:  RESP.BulkString,
+		RESPLen:   len(value),
+		RESPValue: value,
+	})
+}
+
+/*
+ 	* handleHScan handles the HSCAN command, HSCAN key cursor [MATCH pattern] [COUNT count] [NOVALUES]
+	* with the same guarantees as SCAN, a field present for the whole iteration is returned exactly once
+	* @param writer *RESP.Writer - the writer to write the response to
+	* @param args []RESP.RESPMessage - the arguments for the command
+	* @param store *store.Store - the store to get the data from
+	* @param clientID string - the client id
+	* @param txManager *tx.TxManager - the transaction manager
+	* @return error - the error if there is one
+	* @return array - the cursor for the next call, 0 when the iteration is over, and an array of fields and values
+*/
+func handleHScan(writer *RESP.Writer, args []RESP.RESPMessage, store *store.Store, clientID string, txManager *tx.TxManager) error {
+	if len(args) < 2 {
+		err := errWrongNumberOfArguments("HSCAN")
+		return HandleError(writer, []byte(err.Error()))
+	}
+
+	cursor, err := strconv.ParseUint(string(args[1].RESPValue), 10, 64)
+	if err != nil {
+		return HandleError(writer, []byte("ERR invalid cursor"))
+	}
+
+	count := 10
+	pattern := ""
+	noValues := false
+
+	for i := 2; i < len(args); i++ {
+		option := strings.ToUpper(string(args[i].RESPValue))
+		if option == "NOVALUES" {
+			noValues = true
+			continue
+		}
+
+		if i+1 >= len(args) {
+			return HandleError(writer, []byte("ERR syntax error"))
+		}
+		i++
+		value := string(args[i].RESPValue)
+
+		switch option {
+		case "MATCH":
+			pattern = value
+		case "COUNT":
+			count, err = strconv.Atoi(value)
+			if err != nil {
+				return HandleError(writer, []byte("ERR value is not an integer or out of range"))
+			}
+			if count < 1 {
+				return HandleError(writer, []byte("ERR syntax error"))
+			}
+		default:
+			return HandleError(writer, []byte("ERR syntax error"))
+		}
+	}
+
+	elements, next, err := store.HScan(string(args[0].RESPValue), cursor, count, pattern, noValues)
+	if err != nil {
+		return HandleError(writer, []byte(err.Error()))
+	}
+
+	return encodeArray(writer, []RESP.RESPMessage{
+		bulkStringMessage([]byte(strconv.FormatUint(next, 10))),
+		{
+			RESPType:      RESP.Array,
+			RESPLen:       len(elements),
+			RESPArrayElem: bulkStringMessages(elements),
+		},
+	})
+}
+
+/*
+ 	* parseHashFields parses the FIELDS numfields field [field ...] part of the hash field expiration commands
+	* @param args []RESP.RESPMessage - the arguments, starting at FIELDS
+	* @return []string - the fields
+	* @return error - the error if there is one
+*/
+func parseHashFields(args []RESP.RESPMessage) ([]string, error) {
+	if len(args) < 2 || strings.ToUpper(string(args[0].RESPValue)) != "FIELDS" {
+		return nil, errHashFieldsMissing
+	}
+
+	numFields, err := strconv.Atoi(string(args[1].RESPValue))
+	if err != nil || numFields < 1 {
+		return nil, errHashNumFieldsInvalid
+	}
+	if numFields != len(args)-2 {
+		return nil, errHashNumFieldsMismatch
+	}
+
+	return keyArgs(args[2:]), nil
+}
+
+/*
+ 	* handleHExpire handles the HEXPIRE command, HEXPIRE key seconds [NX | XX | GT | LT] FIELDS numfields field [field ...]
+	* @param writer *RESP.Writer - the writer to write the response to
+	* @param args []RESP.RESPMessage - the arguments for the command
+	* @param store *store.Store - the store to get the data from
+	* @param clientID string - the client id
+	* @param txManager *tx.TxManager - the transaction manager
+	* @return error - the error if there is one
+	* @return array - for each field, -2 if it doesn't exist, 0 if the condition wasn't met, 1 if the expiration was set, 2 if the field was deleted
+*/
+func handleHExpire(writer *RESP.Writer, args []RESP.RESPMessage, store *store.Store, clientID string, txManager *tx.TxManager) error {
+	return hashExpireGeneric(writer, args, store, txManager, "HEXPIRE", time.Second, false)
+}
+
+/*
+ 	* handleHPExpire handles the HPEXPIRE command, HPEXPIRE key milliseconds [NX | XX | GT | LT] FIELDS numfields field [field ...]
+	* @param writer *RESP.Writer - the writer to write the response to
+	* @param args []RESP.RESPMessage - the arguments for the command
+	* @param store *store.Store - the store to get the data from
+	* @param clientID string - the client id
+	* @param txManager *tx.TxManager - the transaction manager
+	* @return error - the error if there is one
+	* @return array - for each field, -2 if it doesn't exist, 0 if the condition wasn't met, 1 if the expiration was set, 2 if the field was deleted
+*/
+func handleHPExpire(writer *RESP.Writer, args []RESP.RESPMessage, store *store.Store, clientID string, txManager *tx.TxManager) error {
+	return hashExpireGeneric(writer, args, store, txManager, "HPEXPIRE", time.Millisecond, false)
+}
+
+/*
+ 	* handleHExpireAt handles the HEXPIREAT command, HEXPIREAT key unix-time-seconds [NX | XX | GT | LT] FIELDS numfields field [field ...]
+	* @param writer *RESP.Writer - the writer to write the response to
+	* @param args []RESP.RESPMessage - the arguments for the command
+	* @param store *store.Store - the store to get the data from
+	* @param clientID string - the client id
+	* @param txManager *tx.TxManager - the transaction manager
+	* @return error - the error if there is one
+	* @return array - for each field, -2 if it doesn't exist, 0 if the condition wasn't met, 1 if the expiration was set, 2 if the field was deleted
+*/
+func handleHExpireAt(writer *RESP.Writer, args []RESP.RESPMessage, store *store.Store, clientID string, txManager *tx.TxManager) error {
+	return hashExpireGeneric(writer, args, store, txManager, "HEXPIREAT", time.Second, true)
+}
+
+/*
+ 	* handleHPExpireAt handles the HPEXPIREAT command, HPEXPIREAT key unix-time-milliseconds [NX | XX | GT | LT] FIELDS numfields field [field ...]
+	* @param writer *RESP.Writer - the writer to write the response to
+	* @param args []RESP.RESPMessage - the arguments for the command
+	* @param store *store.Store - the store to get the data from
+	* @param clientID string - the client id
+	* @param txManager *tx.TxManager - the transaction manager
+	* @return error - the error if there is one
+	* @return array - for each field, -2 if it doesn't exist, 0 if the condition wasn't met, 1 if the expiration was set, 2 if the field was deleted
+*/
+func handleHPExpireAt(writer *RESP.Writer, args []RESP.RESPMessage, store *store.Store, clientID string, txManager *tx.TxManager) error {
+	return hashExpireGeneric(writer, args, store, txManager, "HPEXPIREAT", time.Millisecond, true)
+}
+
+/*
+ 	* hashExpireGeneric implements the HEXPIRE family, a time in the past deletes the fields
+	* @param writer *RESP.Writer - the writer to write the response to
+	* @param args []RESP.RESPMessage - the arguments for the command
+	* @param st *store.Store - the store to get the data from
+	* @param txManager *tx.TxManager - the transaction manager
+	* @param cmd string - the name of the command, for errors
+	* @param unit time.Duration - time.Second or time.Millisecond, the unit of the time argument
+	* @param absolute bool - true if the time argument is a unix time, false if it is relative to now
+	* @return error - the error if there is one
+*/
+func hashExpireGeneric(writer *RESP.Writer, args []RESP.RESPMessage, st *store.Store, txManager *tx.TxManager, cmd string, unit time.Duration, absolute bool) error {
+	if len(args) < 4 {
+		err := errWrongNumberOfArguments(cmd)
+		return HandleError(writer, []byte(err.Error()))
+	}
+
+	key := string(args[0].RESPValue)
+
+	value, err := strconv.ParseInt(string(args[1].RESPValue), 10, 64)
+	if err != nil {
+		return HandleError(writer, []byte("ERR value is not an integer or out of range"))
+	}
+
+	// the condition is the one optional argument before FIELDS
+	rest := args[2:]
+	condition := store.ExpireAlways
+	switch strings.ToUpper(string(rest[0].RESPValue)) {
+	case "NX", "XX", "GT", "LT":
+		condition, err = parseExpireCondition(rest[:1])
+		if err != nil {
+			return HandleError(writer, []byte(err.Error()))
+		}
+		rest = rest[1:]
+	}
+
+	fields, err := parseHashFields(rest)
+	if err != nil {
+		return HandleError(writer, []byte(err.Error()))
+	}
+
+	at, ok := expireTimeMs(value, unit, absolute)
+	if value < 0 || !ok || at > hashFieldMaxExpireMs {
+		return HandleError(writer, []byte("ERR invalid expire time, must be >= 0 && <= 2^48"))
+	}
+
+	results, err := st.HExpireAt(key, fields, time.UnixMilli(at), condition)
+	if err != nil {
+		return HandleError(writer, []byte(err.Error()))
+	}
+
+	elements := make([]RESP.RESPMessage, len(results))
+	modified := false
+	for i, result := range results {
+		elements[i] = integerMessage(int64(result))
+		if result == store.HashFieldSet || result == store.HashFieldDeletedByTTL {
+			modified = true
+		}
+	}
+
+	if modified {
+		signalModifiedKey(txManager, key)
+	}
+
+	return encodeArray(writer, elements)
+}
+
+/*
+ 	* handleHTTL handles the HTTL command, HTTL key FIELDS numfields field [field ...]
+	* @param writer *RESP.Writer - the writer to write the response to
+	* @param args []RESP.RESPMessage - the arguments for the command
+	* @param store *store.Store - the store to get the data from
+	* @param clientID string - the client id
+	* @param txManager *tx.TxManager - the transaction manager
+	* @return error - the error if there is one
+	* @return array - for each field, the seconds left to live, -1 if it has no expiration, -2 if it doesn't exist
+*/
+func handleHTTL(writer *RESP.Writer, args []RESP.RESPMessage, store *store.Store, clientID string, txManager *tx.TxManager) error {
+	return hashTTLGeneric(writer, args, store, "HTTL", time.Second, false)
+}
+
+/*
+ 	* handleHPTTL handles the HPTTL command, HPTTL key FIELDS numfields field [field ...]
+	* @param writer *RESP.Writer - the writer to write the response to
+	* @param args []RESP.RESPMessage - the arguments for the command
+	* @param store *store.Store - the store to get the data from
+	* @param clientID string - the client id
+	* @param txManager *tx.TxManager - the transaction manager
+	* @return error - the error if there is one
+	* @return array - for each field, the milliseconds left to live, -1 if it has no expiration, -2 if it doesn't exist
+*/
+func handleHPTTL(writer *RESP.Writer, args []RESP.RESPMessage, store *store.Store, clientID string, txManager *tx.TxManager) error {
+	return hashTTLGeneric(writer, args, store, "HPTTL", time.Millisecond, false)
+}
+
+/*
+ 	* handleHExpireTime handles the HEXPIRETIME command, HEXPIRETIME key FIELDS numfields field [field ...]
+	* @param writer *RESP.Writer - the writer to write the response to
+	* @param args []RESP.RESPMessage - the arguments for the command
+	* @param store *store.Store - the store to get the data from
+	* @param clientID string - the client id
+	* @param txManager *tx.TxManager - the transaction manager
+	* @return error - the error if there is one
+	* @return array - for each field, the unix time in seconds it expires at, -1 if it has no expiration, -2 if it doesn't exist
+*/
+func handleHExpireTime(writer *RESP.Writer, args []RESP.RESPMessage, store *store.Store, clientID string, txManager *tx.TxManager) error {
+	return hashTTLGeneric(writer, args, store, "HEXPIRETIME", time.Second, true)
+}
+
+/*
+ 	* handleHPExpireTime handles the HPEXPIRETIME command, HPEXPIRETIME key FIELDS numfields field [field ...]
+	* @param writer *RESP.Writer - the writer to write the response to
+	* @param args []RESP.RESPMessage - the arguments for the command
+	* @param store *store.Store - the store to get the data from
+	* @param clientID string - the client id
+	* @param txManager *tx.TxManager - the transaction manager
+	* @return error - the error if there is one
+	* @return array - for each field, the unix time in milliseconds it expires at, -1 if it has no expiration, -2 if it doesn't exist
+*/
+func handleHPExpireTime(writer *RESP.Writer, args []RESP.RESPMessage, store *store.Store, clientID string, txManager *tx.TxManager) error {
+	return hashTTLGeneric(writer, args, store, "HPEXPIRETIME", time.Millisecond, true)
+}
+
+/*
+ 	* hashTTLGeneric implements HTTL, HPTTL, HEXPIRETIME and HPEXPIRETIME
+	* @param writer *RESP.Writer - the writer to write the response to
+	* @param args []RESP.RESPMessage - the arguments for the command
+	* @param st *store.Store - the store to get the data from
+	* @param cmd string - the name of the command, for errors
+	* @param unit time.Duration - time.Second or time.Millisecond, the unit of the reply
+	* @param absolute bool - true to reply with the unix time of the expiration, false for the time left
+	* @return error - the error if there is one
+*/
+func hashTTLGeneric(writer *RESP.Writer, args []RESP.RESPMessage, st *store.Store, cmd string, unit time.Duration, absolute bool) error {
+	if len(args) < 3 {
+		err := errWrongNumberOfArguments(cmd)
+		return HandleError(writer, []byte(err.Error()))
+	}
+
+	fields, err := parseHashFields(args[1:])
+	if err != nil {
+		return HandleError(writer, []byte(err.Error()))
+	}
+
+	expirations, err := st.HExpireTime(string(args[0].RESPValue), fields)
+	if err != nil {
+		return HandleError(writer, []byte(err.Error()))
+	}
+
+	now := time.Now().UnixMilli()
+	elements := make([]RESP.RESPMessage, len(expirations))
+	for i, at := range expirations {
+		if at < 0 {
+			elements[i] = integerMessage(at)
+			continue
+		}
+
+		value := at
+		if !absolute {
+			value = max(at-now, 0)
+			if unit == time.Second {
+				value = (value + 500) / 1000 // rounded, like TTL
+			}
+		} else if unit == time.Second {
+			value /= 1000
+		}
+		elements[i] = integerMessage(value)
+	}
+
+	return encodeArray(writer, elements)
+}
+
+/*
+ 	* handleHPersist handles the HPERSIST command, HPERSIST key FIELDS numfields field [field ...]
+	* @param writer *RESP.Writer - the writer to write the response to
+	* @param args []RESP.RESPMessage - the arguments for the command
+	* @param store *store.Store - the store to get the data from
+	* @param clientID string - the client id
+	* @param txManager *tx.TxManager - the transaction manager
+	* @return error - the error if there is one
+	* @return array - for each field, 1 if the expiration was removed, -1 if it has none, -2 if it doesn't exist
+*/
+func handleHPersist(writer *RESP.Writer, args []RESP.RESPMessage, st *store.Store, clientID string, txManager *tx.TxManager) error {
+	if len(args) < 3 {
+		err := errWrongNumberOfArguments("HPERSIST")
+		return HandleError(writer, []byte(err.Error()))
+	}
+
+	key := string(args[0].RESPValue)
+
+	fields, err := parseHashFields(args[1:])
+	if err != nil {
+		return HandleError(writer, []byte(err.Error()))
+	}
+
+	results, err := st.HPersist(key, fields)
+	if err != nil {
+		return HandleError(writer, []byte(err.Error()))
+	}
+
+	elements := make([]RESP.RESPMessage, len(results))
+	modified := false
+	for i, result := range results {
+		elements[i] = integerMessage(int64(result))
+		if result == store.HashFieldSet {
+			modified = true
+		}
+	}
+
+	if modified {
+		signalModifiedKey(txManager, key)
+	}
+
+	return encodeArray(writer, elements)
+}
+
+/*
+ 	* hashExpireAofArgv rewrites the HEXPIRE family into HPEXPIREAT with an absolute time, the condition is kept
+	* as it gives the same result against the same fields. nothing is logged when no field was touched
+	* @param cmd string - the command, in uppercase
+	* @param args []RESP.RESPMessage - the arguments for the command
+	* @param reply *RESP.RESPMessage - the reply the command produced
+	* @return [][]byte - the HPEXPIREAT command, nil if no field changed
+*/
+func hashExpireAofArgv(cmd string, args []RESP.RESPMessage, reply *RESP.RESPMessage) [][]byte {
+	modified := false
+	for _, result := range reply.RESPArrayElem {
+		if n, _ := strconv.Atoi(string(result.RESPValue)); n == store.HashFieldSet || n == store.HashFieldDeletedByTTL {
+			modified = true
+		}
+	}
+	if !modified {
+		return nil
+	}
+
+	value, _ := strconv.ParseInt(string(args[1].RESPValue), 10, 64)
+	unit := time.Millisecond
+	if cmd == "HEXPIRE" || cmd == "HEXPIREAT" {
+		unit = time.Second
+	}
+	at, _ := expireTimeMs(value, unit, cmd == "HEXPIREAT" || cmd == "HPEXPIREAT")
+
+	argv := [][]byte{[]byte("HPEXPIREAT"), args[0].RESPValue, []byte(strconv.FormatInt(at, 10))}
+	for _, arg := range args[2:] {
+		argv = append(argv, arg.RESPValue)
+	}
+	return argv
+}
+
+/*
+ 	* argValues returns the values of the arguments
+	* @param args []RESP.RESPMessage - the arguments
+	* @return [][]byte - the values
+*/
+func argValues(args []RESP.RESPMessage) [][]byte {
+	values := make([][]byte, len(args))
+	for i, arg := range args {
+		values[i] = arg.RESPValue
+	}
+	return values
+}
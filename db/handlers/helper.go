@@ -3,11 +3,115 @@ package handlers
 import (
 	"errors"
 	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
 
+	config "github.com/manish-singh-bisht/Redis-From-Scratch/db/persistence"
 	RESP "github.com/manish-singh-bisht/Redis-From-Scratch/db/resp"
+	tx "github.com/manish-singh-bisht/Redis-From-Scratch/db/transaction"
 )
 
 var ErrClientClosed = errors.New("client closed")
+var errSyntax = errors.New("ERR syntax error")
+
+// commands that modify the dataset, only these are appended to the AOF
+var writeCommands = map[string]struct{}{
+	"SET":            {},
+	"GETSET":         {},
+	"GETDEL":         {},
+	"GETEX":          {},
+	"APPEND":         {},
+	"SETRANGE":       {},
+	"MSET":           {},
+	"MSETNX":         {},
+	"INCR":           {},
+	"INCRBY":         {},
+	"DECR":           {},
+	"DECRBY":         {},
+	"INCRBYFLOAT":    {},
+	"SETBIT":         {},
+	"BITOP":          {},
+	"BITFIELD":       {},
+	"XADD":           {},
+	"XDEL":           {},
+	"XTRIM":          {},
+	"XGROUP":         {},
+	"XREADGROUP":     {},
+	"XACK":           {},
+	"XCLAIM":         {},
+	"XAUTOCLAIM":     {},
+	"RATE.MARK":      {},
+	"EXPIRE":         {},
+	"PEXPIRE":        {},
+	"EXPIREAT":       {},
+	"PEXPIREAT":      {},
+	"PERSIST":        {},
+	"DEL":            {},
+	"UNLINK":         {},
+	"RENAME":         {},
+	"RENAMENX":       {},
+	"COPY":           {},
+	"LPUSH":          {},
+	"RPUSH":          {},
+	"LPOP":           {},
+	"RPOP":           {},
+	"LSET":           {},
+	"LREM":           {},
+	"LTRIM":          {},
+	"LINSERT":        {},
+	"NEXTID":         {},
+	"HSET":           {},
+	"HMSET":          {},
+	"HSETNX":         {},
+	"HDEL":           {},
+	"HINCRBY":        {},
+	"HINCRBYFLOAT":   {},
+	"HEXPIRE":        {},
+	"HPEXPIRE":       {},
+	"HEXPIREAT":      {},
+	"HPEXPIREAT":     {},
+	"HPERSIST":       {},
+	"SADD":           {},
+	"SREM":           {},
+	"SPOP":           {},
+	"SMOVE":          {},
+	"SINTERSTORE":    {},
+	"SUNIONSTORE":    {},
+	"SDIFFSTORE":     {},
+	"ZADD":           {},
+	"ZINCRBY":        {},
+	"ZREM":           {},
+	"ZPOPMIN":        {},
+	"ZPOPMAX":        {},
+	"ZUNIONSTORE":    {},
+	"ZINTERSTORE":    {},
+	"PFADD":          {},
+	"PFMERGE":        {},
+	"GEOADD":         {},
+	"GEOSEARCHSTORE": {},
+}
+
+func isWriteCommand(cmd string) bool {
+	_, exists := writeCommands[cmd]
+	return exists
+}
+
+// aofMu makes running a write command and appending it to the AOF a single step, so the AOF holds the writes in the
+// order they were made and replaying it builds the same dataset, a blocked XREADGROUP lets go of it while it waits
+var aofMu sync.Mutex
+
+/*
+ 	* heldAOFLock returns aofMu when write commands run holding it, for a blocking write to let go of it while it waits
+	* @return sync.Locker - aofMu, nil when the AOF is disabled
+*/
+func heldAOFLock() sync.Locker {
+	if !config.GetAOFConfig().Enabled {
+		return nil
+	}
+	return &aofMu
+}
 
 func errWrongNumberOfArguments(cmd string) error {
 	return fmt.Errorf("ERR wrong number of arguments for '%s' command", cmd)
@@ -25,3 +129,198 @@ func HandleError(writer *RESP.Writer, errorMsg []byte) error {
 		RESPValue: errorMsg,
 	})
 }
+
+/*
+ 	* encodeOK writes the simple string "OK"
+	* @param writer *RESP.Writer - the writer to write to
+	* @return error - the error if there is one
+*/
+func encodeOK(writer *RESP.Writer) error {
+	return writer.Encode(&RESP.RESPMessage{
+		RESPType:  RESP.SimpleString,
+		RESPValue: []byte("OK"),
+	})
+}
+
+/*
+ 	* encodeInteger writes an integer reply
+	* @param writer *RESP.Writer - the writer to write to
+	* @param n int64 - the integer to write
+	* @return error - the error if there is one
+*/
+func encodeInteger(writer *RESP.Writer, n int64) error {
+	return writer.Encode(&RESP.RESPMessage{
+		RESPType:  RESP.Integer,
+		RESPValue: []byte(strconv.FormatInt(n, 10)),
+	})
+}
+
+/*
+ 	* aofArgv builds what is appended to the AOF for a write command that succeeded
+	* @param cmd string - the command, in uppercase
+	* @param args []RESP.RESPMessage - the arguments for the command
+	* @param reply *RESP.RESPMessage - the reply the command produced
+	* @return [][]byte - the command name followed by its arguments, nil if there is nothing to append
+*/
+func aofArgv(cmd string, args []RESP.RESPMessage, reply *RESP.RESPMessage) [][]byte {
+	switch cmd {
+	case "SET":
+		return setAofArgv(args)
+	case "GETEX":
+		return getExAofArgv(args, reply)
+	case "GETDEL":
+		return getDelAofArgv(args, reply)
+	case "INCRBYFLOAT":
+		return incrByFloatAofArgv(args, reply)
+	case "EXPIRE", "PEXPIRE", "EXPIREAT", "PEXPIREAT":
+		return expireAofArgv(cmd, args, reply)
+	case "NEXTID":
+		return nextIDAofArgv(args, reply)
+	case "RATE.MARK":
+		return rateMarkAofArgv(args)
+	case "HEXPIRE", "HPEXPIRE", "HEXPIREAT", "HPEXPIREAT":
+		return hashExpireAofArgv(cmd, args, reply)
+	case "SPOP":
+		return spopAofArgv(args, reply)
+	case "BITFIELD":
+		return bitFieldAofArgv(args)
+	case "XADD":
+		return xaddAofArgv(args, reply)
+	case "XREADGROUP":
+		return xreadGroupAofArgv(args, reply)
+	case "XCLAIM":
+		return xclaimAofArgv(args, reply)
+	case "XAUTOCLAIM":
+		return xautoclaimAofArgv(args, reply)
+	}
+
+	argv := make([][]byte, 0, len(args)+1)
+	argv = append(argv, []byte(cmd))
+	for _, arg := range args {
+		argv = append(argv, arg.RESPValue)
+	}
+
+	return argv
+}
+
+/*
+ 	* expireAofArgv rewrites the EXPIRE family into PEXPIREAT with an absolute time,
+	* a relative time would start counting again when the file is replayed
+	* @param cmd string - the command, in uppercase
+	* @param args []RESP.RESPMessage - the arguments for the command
+	* @param reply *RESP.RESPMessage - the reply the command produced
+	* @return [][]byte - the PEXPIREAT command, nil if the expiration was not changed
+*/
+func expireAofArgv(cmd string, args []RESP.RESPMessage, reply *RESP.RESPMessage) [][]byte {
+	// the NX/XX/GT/LT flags are dropped, so a command whose condition failed must not be replayed
+	if string(reply.RESPValue) != "1" {
+		return nil
+	}
+
+	value, _ := strconv.ParseInt(string(args[1].RESPValue), 10, 64)
+	unit := time.Millisecond
+	if cmd == "EXPIRE" || cmd == "EXPIREAT" {
+		unit = time.Second
+	}
+	at, _ := expireTimeMs(value, unit, cmd == "EXPIREAT" || cmd == "PEXPIREAT")
+
+	return [][]byte{[]byte("PEXPIREAT"), args[0].RESPValue, []byte(strconv.FormatInt(at, 10))}
+}
+
+func yesNo(b bool) string {
+	if b {
+		return "yes"
+	}
+	return "no"
+}
+
+/*
+ 	* signalModifiedKey bumps the global version of a key that is being watched, so transactions watching it abort
+	* @param txManager *tx.TxManager - the transaction manager
+	* @param key string - the key that was modified
+*/
+func signalModifiedKey(txManager *tx.TxManager, key string) {
+	txManager.SignalModifiedKey(key)
+}
+
+/*
+ 	* bulkStringMessage builds a bulk string message
+	* @param value []byte - the value of the bulk string
+	* @return RESP.RESPMessage - the message
+*/
+func bulkStringMessage(value []byte) RESP.RESPMessage {
+	return RESP.RESPMessage{
+		RESPType:  RESP.BulkString,
+		RESPLen:   len(value),
+		RESPValue: value,
+	}
+}
+
+/*
+ 	* bulkStringMessages builds a bulk string message for each value
+	* @param values [][]byte - the values
+	* @return []RESP.RESPMessage - the messages
+*/
+func bulkStringMessages(values [][]byte) []RESP.RESPMessage {
+	messages := make([]RESP.RESPMessage, len(values))
+	for i, value := range values {
+		messages[i] = bulkStringMessage(value)
+	}
+	return messages
+}
+
+/*
+ 	* integerMessage builds an integer message
+	* @param n int64 - the integer
+	* @return RESP.RESPMessage - the message
+*/
+func integerMessage(n int64) RESP.RESPMessage {
+	return RESP.RESPMessage{
+		RESPType:  RESP.Integer,
+		RESPValue: []byte(strconv.FormatInt(n, 10)),
+	}
+}
+
+/*
+ 	* arrayMessage builds an array message
+	* @param elements []RESP.RESPMessage - the elements of the array
+	* @return RESP.RESPMessage - the message
+*/
+func arrayMessage(elements []RESP.RESPMessage) RESP.RESPMessage {
+	return RESP.RESPMessage{
+		RESPType:      RESP.Array,
+		RESPLen:       len(elements),
+		RESPArrayElem: elements,
+	}
+}
+
+/*
+ 	* encodeArray writes an array reply
+	* @param writer *RESP.Writer - the writer to write to
+	* @param elements []RESP.RESPMessage - the elements of the array
+	* @return error - the error if there is one
+*/
+func encodeArray(writer *RESP.Writer, elements []RESP.RESPMessage) error {
+	return writer.Encode(&RESP.RESPMessage{
+		RESPType:      RESP.Array,
+		RESPLen:       len(elements),
+		RESPArrayElem: elements,
+	})
+}
+
+/*
+ 	* formatFloat formats a float the way replies carry them, as the shortest string that parses back to the same value.
+	* like Redis' %.17g, the exponent form is only used for values past 17 digits or below 0.0001, 1000000 is not 1e+06
+	* @param f float64 - the float to format
+	* @return []byte - the formatted float
+*/
+func formatFloat(f float64) []byte {
+	shortest := strconv.AppendFloat(nil, f, 'e', -1, 64)
+	// the infinities and NaN have no exponent
+	if _, exponent, found := strings.Cut(string(shortest), "e"); found {
+		if e, _ := strconv.Atoi(exponent); e >= -4 && e < 17 {
+			return strconv.AppendFloat(nil, f, 'f', -1, 64)
+		}
+	}
+	return strconv.AppendFloat(nil, f, 'g', -1, 64)
+}
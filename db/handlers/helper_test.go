@@ -0,0 +1,53 @@
+package handlers
+
+import (
+	"math"
+	"testing"
+)
+
+func TestFormatFloat(t *testing.T) {
+	tests := []struct {
+		f    float64
+		want string
+	}{
+		{0, "0"},
+		{1, "1"},
+		{-2.5, "-2.5"},
+		{0.1, "0.1"},
+		{0.0001, "0.0001"},
+		{0.00001, "1e-05"},
+		{1000000, "1000000"},
+		{1234567.5, "1234567.5"},
+		{-1000000, "-1000000"},
+		{3479099956230698, "3479099956230698"},
+		{1e16, "10000000000000000"},
+		{1e17, "1e+17"},
+		{1.5e300, "1.5e+300"},
+		{math.Inf(1), "+Inf"},
+	}
+
+	for _, test := range tests {
+		if got := string(formatFloat(test.f)); got != test.want {
+			t.Errorf("formatFloat(%v) = %q, want %q", test.f, got, test.want)
+		}
+	}
+}
+
+func TestFormatZScore(t *testing.T) {
+	tests := []struct {
+		score float64
+		want  string
+	}{
+		{1000000, "1000000"},
+		{1234567.5, "1234567.5"},
+		{123456789012, "123456789012"},
+		{math.Inf(1), "inf"},
+		{math.Inf(-1), "-inf"},
+	}
+
+	for _, test := range tests {
+		if got := string(formatZScore(test.score)); got != test.want {
+			t.Errorf("formatZScore(%v) = %q, want %q", test.score, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,89 @@
+package handlers
+
+import (
+	RESP "github.com/manish-singh-bisht/Redis-From-Scratch/db/resp"
+	store "github.com/manish-singh-bisht/Redis-From-Scratch/db/store"
+	tx "github.com/manish-singh-bisht/Redis-From-Scratch/db/transaction"
+)
+
+/*
+ 	* handlePFAdd handles the PFADD command, PFADD key [element ...]
+	* @param writer *RESP.Writer - the writer to write the response to
+	* @param args []RESP.RESPMessage - the arguments for the command
+	* @param store *store.Store - the store to get the data from
+	* @param clientID string - the client id
+	* @param txManager *tx.TxManager - the transaction manager
+	* @return error - the error if there is one
+	* @return integer - 1 if the key was created or the estimated cardinality may have changed, 0 otherwise
+*/
+func handlePFAdd(writer *RESP.Writer, args []RESP.RESPMessage, store *store.Store, clientID string, txManager *tx.TxManager) error {
+	if len(args) < 1 {
+		err := errWrongNumberOfArguments("PFADD")
+		return HandleError(writer, []byte(err.Error()))
+	}
+
+	key := string(args[0].RESPValue)
+
+	updated, err := store.PFAdd(key, argValues(args[1:]))
+	if err != nil {
+		return HandleError(writer, []byte(err.Error()))
+	}
+	if !updated {
+		return encodeInteger(writer, 0)
+	}
+
+	signalModifiedKey(txManager, key)
+
+	return encodeInteger(writer, 1)
+}
+
+/*
+ 	* handlePFCount handles the PFCOUNT command, PFCOUNT key [key ...]
+	* @param writer *RESP.Writer - the writer to write the response to
+	* @param args []RESP.RESPMessage - the arguments for the command
+	* @param store *store.Store - the store to get the data from
+	* @param clientID string - the client id
+	* @param txManager *tx.TxManager - the transaction manager
+	* @return error - the error if there is one
+	* @return integer - the estimated cardinality of the union of the HyperLogLogs
+*/
+func handlePFCount(writer *RESP.Writer, args []RESP.RESPMessage, store *store.Store, clientID string, txManager *tx.TxManager) error {
+	if len(args) < 1 {
+		err := errWrongNumberOfArguments("PFCOUNT")
+		return HandleError(writer, []byte(err.Error()))
+	}
+
+	count, err := store.PFCount(keyArgs(args))
+	if err != nil {
+		return HandleError(writer, []byte(err.Error()))
+	}
+
+	return encodeInteger(writer, int64(count))
+}
+
+/*
+ 	* handlePFMerge handles the PFMERGE command, PFMERGE destkey [sourcekey ...]
+	* @param writer *RESP.Writer - the writer to write the response to
+	* @param args []RESP.RESPMessage - the arguments for the command
+	* @param store *store.Store - the store to get the data from
+	* @param clientID string - the client id
+	* @param txManager *tx.TxManager - the transaction manager
+	* @return error - the error if there is one
+	* @return simple string - OK
+*/
+func handlePFMerge(writer *RESP.Writer, args []RESP.RESPMessage, store *store.Store, clientID string, txManager *tx.TxManager) error {
+	if len(args) < 1 {
+		err := errWrongNumberOfArguments("PFMERGE")
+		return HandleError(writer, []byte(err.Error()))
+	}
+
+	dst := string(args[0].RESPValue)
+
+	if err := store.PFMerge(dst, keyArgs(args[1:])); err != nil {
+		return HandleError(writer, []byte(err.Error()))
+	}
+
+	signalModifiedKey(txManager, dst)
+
+	return encodeOK(writer)
+}
@@ -0,0 +1,270 @@
+package handlers
+
+import (
+	"strconv"
+	"strings"
+
+	RESP "github.com/manish-singh-bisht/Redis-From-Scratch/db/resp"
+	store "github.com/manish-singh-bisht/Redis-From-Scratch/db/store"
+	tx "github.com/manish-singh-bisht/Redis-From-Scratch/db/transaction"
+)
+
+/*
+ 	* keyArgs returns the arguments as keys
+	* @param args []RESP.RESPMessage - the arguments
+	* @return []string - the keys
+*/
+func keyArgs(args []RESP.RESPMessage) []string {
+	keys := make([]string, len(args))
+	for i, arg := range args {
+		keys[i] = string(arg.RESPValue)
+	}
+	return keys
+}
+
+/*
+ 	* handleDel handles the DEL command, DEL key [key ...]
+	* @param writer *RESP.Writer - the writer to write the response to
+	* @param args []RESP.RESPMessage - the arguments for the command
+	* @param store *store.Store - the store to get the data from
+	* @param clientID string - the client id
+	* @param txManager *tx.TxManager - the transaction manager
+	* @return error - the error if there is one
+	* @return integer - the number of keys that were deleted
+*/
+func handleDel(writer *RESP.Writer, args []RESP.RESPMessage, store *store.Store, clientID string, txManager *tx.TxManager) error {
+	if len(args) < 1 {
+		err := errWrongNumberOfArguments("DEL")
+		return HandleError(writer, []byte(err.Error()))
+	}
+
+	keys := keyArgs(args)
+	deleted := store.Del(keys)
+
+	for _, key := range keys {
+		signalModifiedKey(txManager, key)
+	}
+
+	return encodeInteger(writer, int64(deleted))
+}
+
+/*
+ 	* handleUnlink handles the UNLINK command, UNLINK key [key ...], like DEL but large values are freed in the background
+	* @param writer *RESP.Writer - the writer to write the response to
+	* @param args []RESP.RESPMessage - the arguments for the command
+	* @param store *store.Store - the store to get the data from
+	* @param clientID string - the client id
+	* @param txManager *tx.TxManager - the transaction manager
+	* @return error - the error if there is one
+	* @return integer - the number of keys that were deleted
+*/
+func handleUnlink(writer *RESP.Writer, args []RESP.RESPMessage, store *store.Store, clientID string, txManager *tx.TxManager) error {
+	if len(args) < 1 {
+		err := errWrongNumberOfArguments("UNLINK")
+		return HandleError(writer, []byte(err.Error()))
+	}
+
+	keys := keyArgs(args)
+	deleted := store.Unlink(keys)
+
+	for _, key := range keys {
+		signalModifiedKey(txManager, key)
+	}
+
+	return encodeInteger(writer, int64(deleted))
+}
+
+/*
+ 	* handleExists handles the EXISTS command, EXISTS key [key ...]
+	* @param writer *RESP.Writer - the writer to write the response to
+	* @param args []RESP.RESPMessage - the arguments for the command
+	* @param store *store.Store - the store to get the data from
+	* @param clientID string - the client id
+	* @param txManager *tx.TxManager - the transaction manager
+	* @return error - the error if there is one
+	* @return integer - the number of keys that exist, a key given more than once is counted more than once
+*/
+func handleExists(writer *RESP.Writer, args []RESP.RESPMessage, store *store.Store, clientID string, txManager *tx.TxManager) error {
+	if len(args) < 1 {
+		err := errWrongNumberOfArguments("EXISTS")
+		return HandleError(writer, []byte(err.Error()))
+	}
+
+	return encodeInteger(writer, int64(store.Exists(keyArgs(args))))
+}
+
+/*
+ 	* handleRename handles the RENAME command, RENAME key newkey, overwrites newkey if it exists
+	* @param writer *RESP.Writer - the writer to write the response to
+	* @param args []RESP.RESPMessage - the arguments for the command
+	* @param store *store.Store - the store to get the data from
+	* @param clientID string - the client id
+	* @param txManager *tx.TxManager - the transaction manager
+	* @return error - the error if there is one
+	* @return simple string - OK
+*/
+func handleRename(writer *RESP.Writer, args []RESP.RESPMessage, store *store.Store, clientID string, txManager *tx.TxManager) error {
+	if len(args) != 2 {
+		err := errWrongNumberOfArguments("RENAME")
+		return HandleError(writer, []byte(err.Error()))
+	}
+
+	src, dst := string(args[0].RESPValue), string(args[1].RESPValue)
+
+	if _, err := store.Rename(src, dst, false); err != nil {
+		return HandleError(writer, []byte(err.Error()))
+	}
+
+	signalModifiedKey(txManager, src)
+	signalModifiedKey(txManager, dst)
+
+	return encodeOK(writer)
+}
+
+/*
+ 	* handleRenameNX handles the RENAMENX command, RENAMENX key newkey, renames only if newkey doesn't exist
+	* @param writer *RESP.Writer - the writer to write the response to
+	* @param args []RESP.RESPMessage - the arguments for the command
+	* @param store *store.Store - the store to get the data from
+	* @param clientID string - the client id
+	* @param txManager *tx.TxManager - the transaction manager
+	* @return error - the error if there is one
+	* @return integer - 1 if the key was renamed, 0 if newkey exists
+*/
+func handleRenameNX(writer *RESP.Writer, args []RESP.RESPMessage, store *store.Store, clientID string, txManager *tx.TxManager) error {
+	if len(args) != 2 {
+		err := errWrongNumberOfArguments("RENAMENX")
+		return HandleError(writer, []byte(err.Error()))
+	}
+
+	src, dst := string(args[0].RESPValue), string(args[1].RESPValue)
+
+	renamed, err := store.Rename(src, dst, true)
+	if err != nil {
+		return HandleError(writer, []byte(err.Error()))
+	}
+	if !renamed {
+		return encodeInteger(writer, 0)
+	}
+
+	signalModifiedKey(txManager, src)
+	signalModifiedKey(txManager, dst)
+
+	return encodeInteger(writer, 1)
+}
+
+/*
+ 	* handleCopy handles the COPY command, COPY source destination [DB destination-db] [REPLACE]
+	* there is a single database, so DB only accepts 0
+	* @param writer *RESP.Writer - the writer to write the response to
+	* @param args []RESP.RESPMessage - the arguments for the command
+	* @param store *store.Store - the store to get the data from
+	* @param clientID string - the client id
+	* @param txManager *tx.TxManager - the transaction manager
+	* @return error - the error if there is one
+	* @return integer - 1 if the key was copied, 0 if source doesn't exist or destination exists without REPLACE
+*/
+func handleCopy(writer *RESP.Writer, args []RESP.RESPMessage, store *store.Store, clientID string, txManager *tx.TxManager) error {
+	if len(args) < 2 {
+		err := errWrongNumberOfArguments("COPY")
+		return HandleError(writer, []byte(err.Error()))
+	}
+
+	src, dst := string(args[0].RESPValue), string(args[1].RESPValue)
+	replace := false
+
+	for i := 2; i < len(args); i++ {
+		switch strings.ToUpper(string(args[i].RESPValue)) {
+		case "REPLACE":
+			replace = true
+		case "DB":
+			if i+1 >= len(args) {
+				return HandleError(writer, []byte("ERR syntax error"))
+			}
+			i++
+			if string(args[i].RESPValue) != "0" {
+				return HandleError(writer, []byte("ERR DB index is out of range"))
+			}
+		default:
+			return HandleError(writer, []byte("ERR syntax error"))
+		}
+	}
+
+	if src == dst {
+		return HandleError(writer, []byte("ERR source and destination objects are the same"))
+	}
+
+	if !store.Copy(src, dst, replace) {
+		return encodeInteger(writer, 0)
+	}
+
+	signalModifiedKey(txManager, dst)
+
+	return encodeInteger(writer, 1)
+}
+
+/*
+ 	* handleScan handles the SCAN command, SCAN cursor [MATCH pattern] [COUNT count] [TYPE type]
+	* a key that exists for the whole iteration is returned exactly once, keys added or removed in between may or may not be
+	* @param writer *RESP.Writer - the writer to write the response to
+	* @param args []RESP.RESPMessage - the arguments for the command
+	* @param store *store.Store - the store to get the data from
+	* @param clientID string - the client id
+	* @param txManager *tx.TxManager - the transaction manager
+	* @return error - the error if there is one
+	* @return array - the cursor for the next call, 0 when the iteration is over, and an array of keys
+*/
+func handleScan(writer *RESP.Writer, args []RESP.RESPMessage, store *store.Store, clientID string, txManager *tx.TxManager) error {
+	if len(args) < 1 {
+		err := errWrongNumberOfArguments("SCAN")
+		return HandleError(writer, []byte(err.Error()))
+	}
+
+	cursor, err := strconv.ParseUint(string(args[0].RESPValue), 10, 64)
+	if err != nil {
+		return HandleError(writer, []byte("ERR invalid cursor"))
+	}
+
+	count := 10
+	pattern, typeName := "", ""
+
+	for i := 1; i < len(args); i += 2 {
+		if i+1 >= len(args) {
+			return HandleError(writer, []byte("ERR syntax error"))
+		}
+		value := string(args[i+1].RESPValue)
+
+		switch strings.ToUpper(string(args[i].RESPValue)) {
+		case "MATCH":
+			pattern = value
+		case "COUNT":
+			count, err = strconv.Atoi(value)
+			if err != nil {
+				return HandleError(writer, []byte("ERR value is not an integer or out of range"))
+			}
+			if count < 1 {
+				return HandleError(writer, []byte("ERR syntax error"))
+			}
+		case "TYPE":
+			typeName = value
+		default:
+			return HandleError(writer, []byte("ERR syntax error"))
+		}
+	}
+
+	keys, next := store.Scan(cursor, count, pattern, typeName)
+
+	elements := make([]RESP.RESPMessage, len(keys))
+	for i, key := range keys {
+		elements[i] = bulkStringMessage([]byte(key))
+	}
+
+	return encodeArray(writer, []RESP.RESPMessage{
+		bulkStringMessage([]byte(strconv.FormatUint(next, 10))),
+		{
+			RESPType:      RESP.Array,
+			RESPLen:       len(elements),
+			RESPArrayElem: elements,
+		},
+	})
+}
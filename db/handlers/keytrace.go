@@ -0,0 +1,411 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	config "github.com/manish-singh-bisht/Redis-From-Scratch/db/persistence"
+	RESP "github.com/manish-singh-bisht/Redis-From-Scratch/db/resp"
+	store "github.com/manish-singh-bisht/Redis-From-Scratch/db/store"
+	tx "github.com/manish-singh-bisht/Redis-From-Scratch/db/transaction"
+)
+
+// like SLOWLOG, long commands are cut short in the key trace log so a large value doesn't flood it
+const (
+	keyTraceMaxArgs   = 32  // the last argument kept is replaced by how many more there were
+	keyTraceMaxArgLen = 128 // longer arguments are cut and followed by how many more bytes there were
+)
+
+// keyTrace is a pattern added with KEYTRACE ADD, and which commands on the keys it matches are logged
+type keyTrace struct {
+	pattern string
+	reads   bool
+	writes  bool
+}
+
+var (
+	keyTracesMu  sync.RWMutex
+	keyTraces    []keyTrace
+	keyTraceOn   atomic.Bool // true while there is at least one trace, checked before anything else so tracing costs nothing when unused
+	clientAddrMu sync.RWMutex
+	clientAddrs  = make(map[string]string) // the remote address of each connected client, by client id
+)
+
+// keyTraceRecord is a line of the key trace log
+type keyTraceRecord struct {
+	Time    string   `json:"time"`
+	Client  string   `json:"client"`
+	Addr    string   `json:"addr"`
+	Command string   `json:"cmd"`
+	Args    []string `json:"args"`
+	Keys    []string `json:"keys"`
+	Result  string   `json:"result"`
+}
+
+// keySpec says which arguments of a command are keys, the way the first key, last key and step of Redis' command table do,
+// a negative last counts from the end, -1 being the last argument
+type keySpec struct {
+	first, last, step int
+}
+
+// commands whose keys are not just their first argument, see commandKeys for the ones that need parsing
+var keySpecs = map[string]keySpec{
+	"MGET":           {0, -1, 1},
+	"MSET":           {0, -1, 2},
+	"MSETNX":         {0, -1, 2},
+	"BITOP":          {1, -1, 1},
+	"DEL":            {0, -1, 1},
+	"UNLINK":         {0, -1, 1},
+	"EXISTS":         {0, -1, 1},
+	"WATCH":          {0, -1, 1},
+	"RENAME":         {0, 1, 1},
+	"RENAMENX":       {0, 1, 1},
+	"COPY":           {0, 1, 1},
+	"SMOVE":          {0, 1, 1},
+	"SINTER":         {0, -1, 1},
+	"SUNION":         {0, -1, 1},
+	"SDIFF":          {0, -1, 1},
+	"SINTERSTORE":    {0, -1, 1},
+	"SUNIONSTORE":    {0, -1, 1},
+	"SDIFFSTORE":     {0, -1, 1},
+	"PFCOUNT":        {0, -1, 1},
+	"PFMERGE":        {0, -1, 1},
+	"GEOSEARCHSTORE": {0, 1, 1},
+	"XGROUP":         {1, 1, 1},
+	"XINFO":          {1, 1, 1},
+}
+
+// commands that touch no key, every command not here nor in keySpecs has its key as its first argument
+var keylessCommands = map[string]struct{}{
+	"PING":     {},
+	"ECHO":     {},
+	"CONFIG":   {},
+	"KEYS":     {},
+	"SCAN":     {},
+	"EXIT":     {},
+	"MULTI":    {},
+	"EXEC":     {},
+	"DISCARD":  {},
+	"SAVE":     {},
+	"BGSAVE":   {},
+	"LASTSAVE": {},
+	"KEYTRACE": {},
+	"QUIT":     {},
+	"SESSION":  {},
+
+	// channels are not keys
+	"SUBSCRIBE":    {},
+	"PSUBSCRIBE":   {},
+	"UNSUBSCRIBE":  {},
+	"PUNSUBSCRIBE": {},
+	"PUBLISH":      {},
+	"PUBSUB":       {},
+}
+
+/*
+ 	* RegisterClient records the remote address of a client, for the key trace log
+	* @param clientID string - the client id
+	* @param addr string - the remote address of the connection
+*/
+func RegisterClient(clientID, addr string) {
+	clientAddrMu.Lock()
+	defer clientAddrMu.Unlock()
+	clientAddrs[clientID] = addr
+}
+
+/*
+ 	* UnregisterClient forgets a client once its connection is closed
+	* @param clientID string - the client id
+*/
+func UnregisterClient(clientID string) {
+	clientAddrMu.Lock()
+	defer clientAddrMu.Unlock()
+	delete(clientAddrs, clientID)
+}
+
+func clientAddr(clientID string) string {
+	clientAddrMu.RLock()
+	defer clientAddrMu.RUnlock()
+	return clientAddrs[clientID]
+}
+
+/*
+ 	* commandKeys returns the keys a command touches
+	* @param cmd string - the command, in uppercase
+	* @param args []RESP.RESPMessage - the arguments for the command
+	* @return []string - the keys
+*/
+func commandKeys(cmd string, args []RESP.RESPMessage) []string {
+	if _, keyless := keylessCommands[cmd]; keyless || len(args) == 0 {
+		return nil
+	}
+
+	switch cmd {
+	case "XREAD", "XREADGROUP":
+		// XREAD [COUNT count] [BLOCK ms] STREAMS key [key ...] id [id ...], as many keys as ids,
+		// XREADGROUP starts with GROUP group consumer, which could be named STREAMS
+		start := 0
+		if cmd == "XREADGROUP" {
+			start = min(3, len(args))
+		}
+		for i, arg := range args[start:] {
+			if strings.ToUpper(string(arg.RESPValue)) == "STREAMS" {
+				streams := args[start+i+1:]
+				return keyArgs(streams[:len(streams)/2])
+			}
+		}
+		return nil
+	case "SINTERCARD":
+		return numKeysArgs(args, 0)
+	case "ZUNIONSTORE", "ZINTERSTORE":
+		return append([]string{string(args[0].RESPValue)}, numKeysArgs(args, 1)...)
+	}
+
+	spec, exists := keySpecs[cmd]
+	if !exists {
+		return []string{string(args[0].RESPValue)}
+	}
+
+	last := spec.last
+	if last < 0 {
+		last += len(args)
+	}
+	last = min(last, len(args)-1)
+
+	var keys []string
+	for i := spec.first; i <= last; i += spec.step {
+		keys = append(keys, string(args[i].RESPValue))
+	}
+	return keys
+}
+
+/*
+ 	* numKeysArgs returns the keys of a command that gives their number first, like SINTERCARD numkeys key [key ...]
+	* @param args []RESP.RESPMessage - the arguments for the command
+	* @param at int - the position of numkeys
+	* @return []string - the keys, nil if numkeys is not valid
+*/
+func numKeysArgs(args []RESP.RESPMessage, at int) []string {
+	if at >= len(args) {
+		return nil
+	}
+
+	numKeys, err := strconv.Atoi(string(args[at].RESPValue))
+	if err != nil || numKeys < 1 || at+1+numKeys > len(args) {
+		return nil
+	}
+	return keyArgs(args[at+1 : at+1+numKeys])
+}
+
+/*
+ 	* tracedKeys returns the keys of a command that a trace asks to log, it returns right away when no trace is active
+	* @param cmd string - the command, in uppercase
+	* @param args []RESP.RESPMessage - the arguments for the command
+	* @return []string - the keys matching a trace, nil if the command is not traced
+*/
+func tracedKeys(cmd string, args []RESP.RESPMessage) []string {
+	if !keyTraceOn.Load() {
+		return nil
+	}
+
+	keys := commandKeys(cmd, args)
+	if len(keys) == 0 {
+		return nil
+	}
+
+	write := isWriteCommand(cmd)
+
+	keyTracesMu.RLock()
+	defer keyTracesMu.RUnlock()
+
+	var traced []string
+	for _, key := range keys {
+		for _, trace := range keyTraces {
+			if ((write && trace.writes) || (!write && trace.reads)) && store.MatchGlob(trace.pattern, key) {
+				traced = append(traced, key)
+				break
+			}
+		}
+	}
+	return traced
+}
+
+/*
+ 	* traceArgs truncates the arguments of a command for the key trace log
+	* @param args []RESP.RESPMessage - the arguments for the command
+	* @return []string - the arguments, cut short
+*/
+func traceArgs(args []RESP.RESPMessage) []string {
+	traced := make([]string, 0, min(len(args), keyTraceMaxArgs))
+	for i, arg := range args {
+		if i == keyTraceMaxArgs-1 && len(args) > keyTraceMaxArgs {
+			traced = append(traced, fmt.Sprintf("... (%d more arguments)", len(args)-i))
+			break
+		}
+
+		value := arg.RESPValue
+		if len(value) > keyTraceMaxArgLen {
+			traced = append(traced, fmt.Sprintf("%s... (%d more bytes)", value[:keyTraceMaxArgLen], len(value)-keyTraceMaxArgLen))
+		} else {
+			traced = append(traced, string(value))
+		}
+	}
+	return traced
+}
+
+/*
+ 	* replyType names the type of a reply for the key trace log
+	* @param reply *RESP.RESPMessage - the reply
+	* @return string - status, error, integer, bulk, nil or array
+*/
+func replyType(reply *RESP.RESPMessage) string {
+	switch reply.RESPType {
+	case RESP.SimpleString:
+		return "status"
+	case RESP.Error:
+		return "error"
+	case RESP.Integer:
+		return "integer"
+	case RESP.Array:
+		return "array"
+	}
+	if reply.RESPValue == nil {
+		return "nil"
+	}
+	return "bulk"
+}
+
+/*
+ 	* traceCommand writes a command to the key trace log, errors are logged and otherwise ignored so a full disk
+	* doesn't fail the command
+	* @param cmd string - the command, in uppercase
+	* @param args []RESP.RESPMessage - the arguments for the command
+	* @param keys []string - the keys that matched a trace
+	* @param clientID string - the client id
+	* @param reply *RESP.RESPMessage - the reply the command produced
+*/
+func traceCommand(cmd string, args []RESP.RESPMessage, keys []string, clientID string, reply *RESP.RESPMessage) {
+	record, err := json.Marshal(keyTraceRecord{
+		Time:    time.Now().UTC().Format(time.RFC3339Nano),
+		Client:  clientID,
+		Addr:    clientAddr(clientID),
+		Command: cmd,
+		Args:    traceArgs(args),
+		Keys:    keys,
+		Result:  replyType(reply),
+	})
+	if err != nil {
+		log.Printf("Error encoding key trace record: %v", err)
+		return
+	}
+
+	if err := config.AppendKeyTrace(append(record, '\n')); err != nil {
+		log.Printf("Error writing key trace log: %v", err)
+	}
+}
+
+/*
+ 	* handleKeyTrace handles the KEYTRACE command,
+	* KEYTRACE ADD pattern [READS] [WRITES] | KEYTRACE DEL pattern | KEYTRACE LIST.
+	* ADD logs the commands touching keys that match the pattern, reads, writes or both when neither is given,
+	* adding a pattern again replaces what it logs
+	* @param writer *RESP.Writer - the writer to write the response to
+	* @param args []RESP.RESPMessage - the arguments for the command
+	* @param store *store.Store - the store to get the data from
+	* @param clientID string - the client id
+	* @param txManager *tx.TxManager - the transaction manager
+	* @return error - the error if there is one
+	* @return simple string - OK for ADD
+	* @return integer - for DEL, 1 if the pattern was traced, 0 otherwise
+	* @return array - for LIST, each trace as its pattern followed by reads and/or writes
+*/
+func handleKeyTrace(writer *RESP.Writer, args []RESP.RESPMessage, store *store.Store, clientID string, txManager *tx.TxManager) error {
+	if len(args) < 1 {
+		err := errWrongNumberOfArguments("KEYTRACE")
+		return HandleError(writer, []byte(err.Error()))
+	}
+
+	subcommand := strings.ToUpper(string(args[0].RESPValue))
+
+	switch {
+	case subcommand == "ADD" && len(args) >= 2:
+		trace := keyTrace{pattern: string(args[1].RESPValue)}
+		for _, arg := range args[2:] {
+			switch strings.ToUpper(string(arg.RESPValue)) {
+			case "READS":
+				trace.reads = true
+			case "WRITES":
+				trace.writes = true
+			default:
+				return HandleError(writer, []byte(errSyntax.Error()))
+			}
+		}
+		if !trace.reads && !trace.writes {
+			trace.reads, trace.writes = true, true
+		}
+
+		keyTracesMu.Lock()
+		defer keyTracesMu.Unlock()
+
+		replaced := false
+		for i := range keyTraces {
+			if keyTraces[i].pattern == trace.pattern {
+				keyTraces[i] = trace
+				replaced = true
+			}
+		}
+		if !replaced {
+			keyTraces = append(keyTraces, trace)
+		}
+		keyTraceOn.Store(true)
+
+		return encodeOK(writer)
+
+	case subcommand == "DEL" && len(args) == 2:
+		pattern := string(args[1].RESPValue)
+
+		keyTracesMu.Lock()
+		defer keyTracesMu.Unlock()
+
+		for i := range keyTraces {
+			if keyTraces[i].pattern == pattern {
+				keyTraces = append(keyTraces[:i], keyTraces[i+1:]...)
+				keyTraceOn.Store(len(keyTraces) > 0)
+				return encodeInteger(writer, 1)
+			}
+		}
+		return encodeInteger(writer, 0)
+
+	case subcommand == "LIST" && len(args) == 1:
+		keyTracesMu.RLock()
+		defer keyTracesMu.RUnlock()
+
+		traces := make([]RESP.RESPMessage, 0, len(keyTraces))
+		for _, trace := range keyTraces {
+			elements := []RESP.RESPMessage{bulkStringMessage([]byte(trace.pattern))}
+			if trace.reads {
+				elements = append(elements, bulkStringMessage([]byte("reads")))
+			}
+			if trace.writes {
+				elements = append(elements, bulkStringMessage([]byte("writes")))
+			}
+			traces = append(traces, RESP.RESPMessage{
+				RESPType:      RESP.Array,
+				RESPLen:       len(elements),
+				RESPArrayElem: elements,
+			})
+		}
+		return encodeArray(writer, traces)
+
+	case subcommand == "ADD" || subcommand == "DEL" || subcommand == "LIST":
+		return HandleError(writer, []byte(fmt.Sprintf("ERR wrong number of arguments for 'keytrace|%s' command", strings.ToLower(subcommand))))
+	}
+
+	return HandleError(writer, []byte(fmt.Sprintf("ERR unknown subcommand '%s'. Try KEYTRACE ADD, KEYTRACE DEL or KEYTRACE LIST.", args[0].RESPValue)))
+}
@@ -0,0 +1,389 @@
+package handlers
+
+import (
+	"strconv"
+	"strings"
+
+	RESP "github.com/manish-singh-bisht/Redis-From-Scratch/db/resp"
+	store "github.com/manish-singh-bisht/Redis-From-Scratch/db/store"
+	tx "github.com/manish-singh-bisht/Redis-From-Scratch/db/transaction"
+)
+
+/*
+ 	* handleLPush handles the LPUSH command, LPUSH key element [element ...]
+	* @param writer *RESP.Writer - the writer to write the response to
+	* @param args []RESP.RESPMessage - the arguments for the command
+	* @param store *store.Store - the store to get the data from
+	* @param clientID string - the client id
+	* @param txManager *tx.TxManager - the transaction manager
+	* @return error - the error if there is one
+	* @return integer - the length of the list after the push
+*/
+func handleLPush(writer *RESP.Writer, args []RESP.RESPMessage, store *store.Store, clientID string, txManager *tx.TxManager) error {
+	return pushGeneric(writer, args, store, txManager, "LPUSH", true)
+}
+
+/*
+ 	* handleRPush handles the RPUSH command, RPUSH key element [element ...]
+	* @param writer *RESP.Writer - the writer to write the response to
+	* @param args []RESP.RESPMessage - the arguments for the command
+	* @param store *store.Store - the store to get the data from
+	* @param clientID string - the client id
+	* @param txManager *tx.TxManager - the transaction manager
+	* @return error - the error if there is one
+	* @return integer - the length of the list after the push
+*/
+func handleRPush(writer *RESP.Writer, args []RESP.RESPMessage, store *store.Store, clientID string, txManager *tx.TxManager) error {
+	return pushGeneric(writer, args, store, txManager, "RPUSH", false)
+}
+
+/*
+ 	* pushGeneric implements LPUSH and RPUSH
+	* @param writer *RESP.Writer - the writer to write the response to
+	* @param args []RESP.RESPMessage - the arguments for the command
+	* @param st *store.Store - the store to get the data from
+	* @param txManager *tx.TxManager - the transaction manager
+	* @param cmd string - the name of the command, for errors
+	* @param head bool - true to push at the head, false at the tail
+	* @return error - the error if there is one
+*/
+func pushGeneric(writer *RESP.Writer, args []RESP.RESPMessage, st *store.Store, txManager *tx.TxManager, cmd string, head bool) error {
+	if len(args) < 2 {
+		err := errWrongNumberOfArguments(cmd)
+		return HandleError(writer, []byte(err.Error()))
+	}
+
+	key := string(args[0].RESPValue)
+	values := make([][]byte, 0, len(args)-1)
+	for _, arg := range args[1:] {
+		values = append(values, arg.RESPValue)
+	}
+
+	length, err := st.ListPush(key, values, head)
+	if err != nil {
+		return HandleError(writer, []byte(err.Error()))
+	}
+
+	signalModifiedKey(txManager, key)
+
+	return encodeInteger(writer, int64(length))
+}
+
+/*
+ 	* handleLPop handles the LPOP command, LPOP key [count]
+	* @param writer *RESP.Writer - the writer to write the response to
+	* @param args []RESP.RESPMessage - the arguments for the command
+	* @param store *store.Store - the store to get the data from
+	* @param clientID string - the client id
+	* @param txManager *tx.TxManager - the transaction manager
+	* @return error - the error if there is one
+	* @return bulk string - the first element, nil if the key doesn't exist, an array of elements when count is given
+*/
+func handleLPop(writer *RESP.Writer, args []RESP.RESPMessage, store *store.Store, clientID string, txManager *tx.TxManager) error {
+	return popGeneric(writer, args, store, txManager, "LPOP", true)
+}
+
+/*
+ 	* handleRPop handles the RPOP command, RPOP key [count]
+	* @param writer *RESP.Writer - the writer to write the response to
+	* @param args []RESP.RESPMessage - the arguments for the command
+	* @param store *store.Store - the store to get the data from
+	* @param clientID string - the client id
+	* @param txManager *tx.TxManager - the transaction manager
+	* @return error - the error if there is one
+	* @return bulk string - the last element, nil if the key doesn't exist, an array of elements when count is given
+*/
+func handleRPop(writer *RESP.Writer, args []RESP.RESPMessage, store *store.Store, clientID string, txManager *tx.TxManager) error {
+	return popGeneric(writer, args, store, txManager, "RPOP", false)
+}
+
+/*
+ 	* popGeneric implements LPOP and RPOP
+	* @param writer *RESP.Writer - the writer to write the response to
+	* @param args []RESP.RESPMessage - the arguments for the command
+	* @param st *store.Store - the store to get the data from
+	* @param txManager *tx.TxManager - the transaction manager
+	* @param cmd string - the name of the command, for errors
+	* @param head bool - true to pop from the head, false from the tail
+	* @return error - the error if there is one
+*/
+func popGeneric(writer *RESP.Writer, args []RESP.RESPMessage, st *store.Store, txManager *tx.TxManager, cmd string, head bool) error {
+	if len(args) != 1 && len(args) != 2 {
+		err := errWrongNumberOfArguments(cmd)
+		return HandleError(writer, []byte(err.Error()))
+	}
+
+	key := string(args[0].RESPValue)
+
+	count := 1
+	if len(args) == 2 {
+		var err error
+		count, err = strconv.Atoi(string(args[1].RESPValue))
+		if err != nil || count < 0 {
+			return HandleError(writer, []byte("ERR value is out of range, must be positive"))
+		}
+	}
+
+	values, exists, err := st.ListPop(key, count, head)
+	if err != nil {
+		return HandleError(writer, []byte(err.Error()))
+	}
+
+	if len(values) > 0 {
+		signalModifiedKey(txManager, key)
+	}
+
+	// without a count the reply is a single element
+	if len(args) == 1 {
+		if len(values) == 0 {
+			return writer.EncodeNil()
+		}
+		return writer.Encode(&RESP.RESPMessage{
+			RESPType:  RESP.BulkString,
+			RESPLen:   len(values[0]),
+			RESPValue: values[0],
+		})
+	}
+
+	if !exists {
+		return writer.EncodeNil()
+	}
+
+	return encodeArray(writer, bulkStringMessages(values))
+}
+
+/*
+ 	* handleLRange handles the LRANGE command, LRANGE key start stop
+	* @param writer *RESP.Writer - the writer to write the response to
+	* @param args []RESP.RESPMessage - the arguments for the command
+	* @param store *store.Store - the store to get the data from
+	* @param clientID string - the client id
+	* @param txManager *tx.TxManager - the transaction manager
+	* @return error - the error if there is one
+	* @return array - the elements from start to stop, both inclusive, negative indexes count from the tail
+*/
+func handleLRange(writer *RESP.Writer, args []RESP.RESPMessage, store *store.Store, clientID string, txManager *tx.TxManager) error {
+	if len(args) != 3 {
+		err := errWrongNumberOfArguments("LRANGE")
+		return HandleError(writer, []byte(err.Error()))
+	}
+
+	start, err := strconv.Atoi(string(args[1].RESPValue))
+	if err != nil {
+		return HandleError(writer, []byte("ERR value is not an integer or out of range"))
+	}
+	stop, err := strconv.Atoi(string(args[2].RESPValue))
+	if err != nil {
+		return HandleError(writer, []byte("ERR value is not an integer or out of range"))
+	}
+
+	values, err := store.ListRange(string(args[0].RESPValue), start, stop)
+	if err != nil {
+		return HandleError(writer, []byte(err.Error()))
+	}
+
+	return encodeArray(writer, bulkStringMessages(values))
+}
+
+/*
+ 	* handleLLen handles the LLEN command, LLEN key
+	* @param writer *RESP.Writer - the writer to write the response to
+	* @param args []RESP.RESPMessage - the arguments for the command
+	* @param store *store.Store - the store to get the data from
+	* @param clientID string - the client id
+	* @param txManager *tx.TxManager - the transaction manager
+	* @return error - the error if there is one
+	* @return integer - the length of the list, 0 if the key doesn't exist
+*/
+func handleLLen(writer *RESP.Writer, args []RESP.RESPMessage, store *store.Store, clientID string, txManager *tx.TxManager) error {
+	if len(args) != 1 {
+		err := errWrongNumberOfArguments("LLEN")
+		return HandleError(writer, []byte(err.Error()))
+	}
+
+	length, err := store.ListLen(string(args[0].RESPValue))
+	if err != nil {
+		return HandleError(writer, []byte(err.Error()))
+	}
+
+	return encodeInteger(writer, int64(length))
+}
+
+/*
+ 	* handleLIndex handles the LINDEX command, LINDEX key index
+	* @param writer *RESP.Writer - the writer to write the response to
+	* @param args []RESP.RESPMessage - the arguments for the command
+	* @param store *store.Store - the store to get the data from
+	* @param clientID string - the client id
+	* @param txManager *tx.TxManager - the transaction manager
+	* @return error - the error if there is one
+	* @return bulk string - the element at index, nil if the index is out of range
+*/
+func handleLIndex(writer *RESP.Writer, args []RESP.RESPMessage, store *store.Store, clientID string, txManager *tx.TxManager) error {
+	if len(args) != 2 {
+		err := errWrongNumberOfArguments("LINDEX")
+		return HandleError(writer, []byte(err.Error()))
+	}
+
+	index, err := strconv.Atoi(string(args[1].RESPValue))
+	if err != nil {
+		return HandleError(writer, []byte("ERR value is not an integer or out of range"))
+	}
+
+	value, ok, err := store.ListIndex(string(args[0].RESPValue), index)
+	if err != nil {
+		return HandleError(writer, []byte(err.Error()))
+	}
+	if !ok {
+		return writer.EncodeNil()
+	}
+
+	return writer.Encode(&RESP.RESPMessage{
+		RESPType:  RESP.BulkString,
+		RESPLen:   len(value),
+		RESPValue: value,
+	})
+}
+
+/*
+ 	* handleLSet handles the LSET command, LSET key index element
+	* @param writer *RESP.Writer - the writer to write the response to
+	* @param args []RESP.RESPMessage - the arguments for the command
+	* @param store *store.Store - the store to get the data from
+	* @param clientID string - the client id
+	* @param txManager *tx.TxManager - the transaction manager
+	* @return error - the error if there is one
+	* @return simple string - OK
+*/
+func handleLSet(writer *RESP.Writer, args []RESP.RESPMessage, store *store.Store, clientID string, txManager *tx.TxManager) error {
+	if len(args) != 3 {
+		err := errWrongNumberOfArguments("LSET")
+		return HandleError(writer, []byte(err.Error()))
+	}
+
+	key := string(args[0].RESPValue)
+
+	index, err := strconv.Atoi(string(args[1].RESPValue))
+	if err != nil {
+		return HandleError(writer, []byte("ERR value is not an integer or out of range"))
+	}
+
+	if err := store.ListSet(key, index, args[2].RESPValue); err != nil {
+		return HandleError(writer, []byte(err.Error()))
+	}
+
+	signalModifiedKey(txManager, key)
+
+	return encodeOK(writer)
+}
+
+/*
+ 	* handleLRem handles the LREM command, LREM key count element
+	* @param writer *RESP.Writer - the writer to write the response to
+	* @param args []RESP.RESPMessage - the arguments for the command
+	* @param store *store.Store - the store to get the data from
+	* @param clientID string - the client id
+	* @param txManager *tx.TxManager - the transaction manager
+	* @return error - the error if there is one
+	* @return integer - the number of elements removed
+*/
+func handleLRem(writer *RESP.Writer, args []RESP.RESPMessage, store *store.Store, clientID string, txManager *tx.TxManager) error {
+	if len(args) != 3 {
+		err := errWrongNumberOfArguments("LREM")
+		return HandleError(writer, []byte(err.Error()))
+	}
+
+	key := string(args[0].RESPValue)
+
+	count, err := strconv.Atoi(string(args[1].RESPValue))
+	if err != nil {
+		return HandleError(writer, []byte("ERR value is not an integer or out of range"))
+	}
+
+	removed, err := store.ListRem(key, count, args[2].RESPValue)
+	if err != nil {
+		return HandleError(writer, []byte(err.Error()))
+	}
+
+	if removed > 0 {
+		signalModifiedKey(txManager, key)
+	}
+
+	return encodeInteger(writer, int64(removed))
+}
+
+/*
+ 	* handleLTrim handles the LTRIM command, LTRIM key start stop
+	* @param writer *RESP.Writer - the writer to write the response to
+	* @param args []RESP.RESPMessage - the arguments for the command
+	* @param store *store.Store - the store to get the data from
+	* @param clientID string - the client id
+	* @param txManager *tx.TxManager - the transaction manager
+	* @return error - the error if there is one
+	* @return simple string - OK
+*/
+func handleLTrim(writer *RESP.Writer, args []RESP.RESPMessage, store *store.Store, clientID string, txManager *tx.TxManager) error {
+	if len(args) != 3 {
+		err := errWrongNumberOfArguments("LTRIM")
+		return HandleError(writer, []byte(err.Error()))
+	}
+
+	key := string(args[0].RESPValue)
+
+	start, err := strconv.Atoi(string(args[1].RESPValue))
+	if err != nil {
+		return HandleError(writer, []byte("ERR value is not an integer or out of range"))
+	}
+	stop, err := strconv.Atoi(string(args[2].RESPValue))
+	if err != nil {
+		return HandleError(writer, []byte("ERR value is not an integer or out of range"))
+	}
+
+	if err := store.ListTrim(key, start, stop); err != nil {
+		return HandleError(writer, []byte(err.Error()))
+	}
+
+	signalModifiedKey(txManager, key)
+
+	return encodeOK(writer)
+}
+
+/*
+ 	* handleLInsert handles the LINSERT command, LINSERT key BEFORE | AFTER pivot element
+	* @param writer *RESP.Writer - the writer to write the response to
+	* @param args []RESP.RESPMessage - the arguments for the command
+	* @param store *store.Store - the store to get the data from
+	* @param clientID string - the client id
+	* @param txManager *tx.TxManager - the transaction manager
+	* @return error - the error if there is one
+	* @return integer - the length of the list after the insert, -1 if the pivot was not found, 0 if the key doesn't exist
+*/
+func handleLInsert(writer *RESP.Writer, args []RESP.RESPMessage, store *store.Store, clientID string, txManager *tx.TxManager) error {
+	if len(args) != 4 {
+		err := errWrongNumberOfArguments("LINSERT")
+		return HandleError(writer, []byte(err.Error()))
+	}
+
+	key := string(args[0].RESPValue)
+
+	var before bool
+	switch strings.ToUpper(string(args[1].RESPValue)) {
+	case "BEFORE":
+		before = true
+	case "AFTER":
+		before = false
+	default:
+		return HandleError(writer, []byte("ERR syntax error"))
+	}
+
+	length, err := store.ListInsert(key, args[2].RESPValue, args[3].RESPValue, before)
+	if err != nil {
+		return HandleError(writer, []byte(err.Error()))
+	}
+
+	if length > 0 {
+		signalModifiedKey(txManager, key)
+	}
+
+	return encodeInteger(writer, int64(length))
+}
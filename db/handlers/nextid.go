@@ -0,0 +1,158 @@
+package handlers
+
+import (
+	"strconv"
+	"strings"
+
+	config "github.com/manish-singh-bisht/Redis-From-Scratch/db/persistence"
+	RESP "github.com/manish-singh-bisht/Redis-From-Scratch/db/resp"
+	store "github.com/manish-singh-bisht/Redis-From-Scratch/db/store"
+	tx "github.com/manish-singh-bisht/Redis-From-Scratch/db/transaction"
+)
+
+var idFormats = map[string]store.IDFormat{
+	"SNOWFLAKE": store.IDFormatSnowflake,
+	"STREAM":    store.IDFormatStream,
+	"ULID":      store.IDFormatULID,
+}
+
+/*
+ 	* parseNextIDOptions parses the options of NEXTID, [COUNT n] [FORMAT snowflake|stream|ulid]
+	* @param args []RESP.RESPMessage - the options, after the key
+	* @return int - the number of IDs, 0 if COUNT was not given
+	* @return store.IDFormat - the format, snowflake by default
+	* @return error - the error if there is one
+*/
+func parseNextIDOptions(args []RESP.RESPMessage) (int, store.IDFormat, error) {
+	count := 0
+	format := store.IDFormatSnowflake
+
+	for i := 0; i < len(args); i += 2 {
+		if i+1 >= len(args) {
+			return 0, format, errSyntax
+		}
+		value := string(args[i+1].RESPValue)
+
+		switch strings.ToUpper(string(args[i].RESPValue)) {
+		case "COUNT":
+			var err error
+			count, err = strconv.Atoi(value)
+			if err != nil || count < 1 {
+				return 0, format, store.ErrInvalidCount
+			}
+			if count > store.NextIDMaxCount {
+				return 0, format, store.ErrCountTooLarge
+			}
+		case "FORMAT":
+			var ok bool
+			format, ok = idFormats[strings.ToUpper(value)]
+			if !ok {
+				return 0, format, errSyntax
+			}
+		default:
+			return 0, format, errSyntax
+		}
+	}
+
+	return count, format, nil
+}
+
+/*
+ 	* handleNextID handles the NEXTID command, NEXTID key [COUNT n] [FORMAT snowflake|stream|ulid]
+	* IDs of a key only ever grow, whatever the clock does, and snowflake IDs embed the node-id of the server
+	* @param writer *RESP.Writer - the writer to write the response to
+	* @param args []RESP.RESPMessage - the arguments for the command
+	* @param store *store.Store - the store to get the data from
+	* @param clientID string - the client id
+	* @param txManager *tx.TxManager - the transaction manager
+	* @return error - the error if there is one
+	* @return bulk string - the ID, an array of IDs when COUNT is given
+*/
+func handleNextID(writer *RESP.Writer, args []RESP.RESPMessage, store *store.Store, clientID string, txManager *tx.TxManager) error {
+	if len(args) < 1 {
+		err := errWrongNumberOfArguments("NEXTID")
+		return HandleError(writer, []byte(err.Error()))
+	}
+
+	key := string(args[0].RESPValue)
+
+	count, format, err := parseNextIDOptions(args[1:])
+	if err != nil {
+		return HandleError(writer, []byte(err.Error()))
+	}
+
+	ids, err := store.NextID(key, max(count, 1), format, config.GetNodeID())
+	if err != nil {
+		return HandleError(writer, []byte(err.Error()))
+	}
+
+	signalModifiedKey(txManager, key)
+
+	if count == 0 {
+		return writer.Encode(&RESP.RESPMessage{
+			RESPType:  RESP.BulkString,
+			RESPLen:   len(ids[0]),
+			RESPValue: []byte(ids[0]),
+		})
+	}
+
+	elements := make([]RESP.RESPMessage, len(ids))
+	for i, id := range ids {
+		elements[i] = bulkStringMessage([]byte(id))
+	}
+	return encodeArray(writer, elements)
+}
+
+/*
+ 	* handleSetLastID handles the internal SETLASTID command, SETLASTID key ms-seq
+	* raises the high-water mark of a NEXTID key, NEXTID is logged to the AOF this way so replaying it hands out nothing twice
+	* @param writer *RESP.Writer - the writer to write the response to
+	* @param args []RESP.RESPMessage - the arguments for the command
+	* @param store *store.Store - the store to get the data from
+	* @param clientID string - the client id
+	* @param txManager *tx.TxManager - the transaction manager
+	* @return error - the error if there is one
+	* @return simple string - OK, a mark lower than the current one is ignored
+*/
+func handleSetLastID(writer *RESP.Writer, args []RESP.RESPMessage, st *store.Store, clientID string, txManager *tx.TxManager) error {
+	if len(args) != 2 {
+		err := errWrongNumberOfArguments("SETLASTID")
+		return HandleError(writer, []byte(err.Error()))
+	}
+
+	key := string(args[0].RESPValue)
+
+	ms, seq, ok := store.DecodeID(string(args[1].RESPValue), store.IDFormatStream)
+	if !ok {
+		return HandleError(writer, []byte(store.ErrInvalidStreamId.Error()))
+	}
+
+	if err := st.SetLastID(key, ms, seq); err != nil {
+		return HandleError(writer, []byte(err.Error()))
+	}
+
+	signalModifiedKey(txManager, key)
+
+	return encodeOK(writer)
+}
+
+/*
+ 	* nextIDAofArgv rewrites NEXTID into SETLASTID with the mark of the last ID handed out,
+	* replaying NEXTID itself would generate IDs from the clock at replay time
+	* @param args []RESP.RESPMessage - the arguments for the command
+	* @param reply *RESP.RESPMessage - the reply the command produced
+	* @return [][]byte - the SETLASTID command
+*/
+func nextIDAofArgv(args []RESP.RESPMessage, reply *RESP.RESPMessage) [][]byte {
+	_, format, _ := parseNextIDOptions(args[1:])
+
+	last := reply.RESPValue
+	if reply.IsArray() {
+		last = reply.RESPArrayElem[len(reply.RESPArrayElem)-1].RESPValue
+	}
+
+	ms, seq, _ := store.DecodeID(string(last), format)
+	lastID := strconv.FormatInt(ms, 10) + "-" + strconv.Itoa(seq)
+
+	return [][]byte{[]byte("SETLASTID"), args[0].RESPValue, []byte(lastID)}
+}
@@ -0,0 +1,76 @@
+package handlers
+
+import (
+	config "github.com/manish-singh-bisht/Redis-From-Scratch/db/persistence"
+	RESP "github.com/manish-singh-bisht/Redis-From-Scratch/db/resp"
+	store "github.com/manish-singh-bisht/Redis-From-Scratch/db/store"
+	tx "github.com/manish-singh-bisht/Redis-From-Scratch/db/transaction"
+)
+
+/*
+ 	* handleSave handles the SAVE command, writes the RDB file synchronously
+	* @param writer *RESP.Writer - the writer to write the response to
+	* @param args []RESP.RESPMessage - the arguments for the command
+	* @param store *store.Store - the store to save
+	* @param clientID string - the client id
+	* @param txManager *tx.TxManager - the transaction manager
+	* @return error - the error if there is one
+	* @return simple string "OK"
+*/
+func handleSave(writer *RESP.Writer, args []RESP.RESPMessage, store *store.Store, clientID string, txManager *tx.TxManager) error {
+	if len(args) != 0 {
+		err := errWrongNumberOfArguments("SAVE")
+		return HandleError(writer, []byte(err.Error()))
+	}
+
+	if err := config.Save(store); err != nil {
+		return HandleError(writer, []byte(err.Error()))
+	}
+
+	return encodeOK(writer)
+}
+
+/*
+ 	* handleBgSave handles the BGSAVE command, writes the RDB file in the background
+	* @param writer *RESP.Writer - the writer to write the response to
+	* @param args []RESP.RESPMessage - the arguments for the command
+	* @param store *store.Store - the store to save
+	* @param clientID string - the client id
+	* @param txManager *tx.TxManager - the transaction manager
+	* @return error - the error if there is one
+	* @return simple string "Background saving started"
+*/
+func handleBgSave(writer *RESP.Writer, args []RESP.RESPMessage, store *store.Store, clientID string, txManager *tx.TxManager) error {
+	if len(args) != 0 {
+		err := errWrongNumberOfArguments("BGSAVE")
+		return HandleError(writer, []byte(err.Error()))
+	}
+
+	if err := config.BGSave(store); err != nil {
+		return HandleError(writer, []byte(err.Error()))
+	}
+
+	return writer.Encode(&RESP.RESPMessage{
+		RESPType:  RESP.SimpleString,
+		RESPValue: []byte("Background saving started"),
+	})
+}
+
+/*
+ 	* handleLastSave handles the LASTSAVE command
+	* @param writer *RESP.Writer - the writer to write the response to
+	* @param args []RESP.RESPMessage - the arguments for the command
+	* @param store *store.Store - the store to get the data from
+	* @param clientID string - the client id
+	* @param txManager *tx.TxManager - the transaction manager
+	* @return error - the error if there is one
+	* @return integer - unix time of the last successful save
+*/
+func handleLastSave(writer *RESP.Writer, args []RESP.RESPMessage, store *store.Store, clientID string, txManager *tx.TxManager) error {
+	if len(args) != 0 {
+		err := errWrongNumberOfArguments("LASTSAVE")
+		return HandleError(writer, []byte(err.Error()))
+	}
+
+	return encodeInteger(writer, config.LastSave())
+}
@@ -0,0 +1,184 @@
+package handlers
+
+import (
+	"fmt"
+	"strings"
+
+	pubsub "github.com/manish-singh-bisht/Redis-From-Scratch/db/pubsub"
+	RESP "github.com/manish-singh-bisht/Redis-From-Scratch/db/resp"
+	store "github.com/manish-singh-bisht/Redis-From-Scratch/db/store"
+	tx "github.com/manish-singh-bisht/Redis-From-Scratch/db/transaction"
+)
+
+// the commands a client in subscribed mode can run, any other is refused until it unsubscribes from everything
+var subscribedModeCommands = map[string]struct{}{
+	"SUBSCRIBE":    {},
+	"PSUBSCRIBE":   {},
+	"UNSUBSCRIBE":  {},
+	"PUNSUBSCRIBE": {},
+	"PING":         {},
+	"QUIT":         {},
+}
+
+/*
+ 	* CheckSubscribedMode refuses the commands a client in subscribed mode can't run
+	* @param clientID string - the client id
+	* @param cmd string - the command
+	* @return error - the error to reply with, nil if the command can run
+*/
+func CheckSubscribedMode(clientID, cmd string) error {
+	if _, allowed := subscribedModeCommands[strings.ToUpper(cmd)]; allowed || pubsub.GetPubSub().Subscriptions(clientID) == 0 {
+		return nil
+	}
+	return fmt.Errorf("ERR Can't execute '%s': only (P)SUBSCRIBE / (P)UNSUBSCRIBE / PING / QUIT are allowed in this context", strings.ToLower(cmd))
+}
+
+/*
+ 	* subscriptionReply returns what writes the reply of a (P)(UN)SUBSCRIBE for each channel or pattern,
+	* [kind, channel, number of subscriptions of the client]
+	* @param writer *RESP.Writer - the writer to write the replies to
+	* @param kind string - subscribe, psubscribe, unsubscribe or punsubscribe
+	* @return func(name string, count int) error - writes the reply for a channel or pattern, "" for none
+*/
+func subscriptionReply(writer *RESP.Writer, kind string) func(name string, count int) error {
+	return func(name string, count int) error {
+		var nameMessage RESP.RESPMessage
+		if name == "" {
+			nameMessage = bulkStringMessage(nil)
+		} else {
+			nameMessage = bulkStringMessage([]byte(name))
+		}
+		return encodeArray(writer, []RESP.RESPMessage{bulkStringMessage([]byte(kind)), nameMessage, integerMessage(int64(count))})
+	}
+}
+
+/*
+ 	* handleSubscribe handles the SUBSCRIBE command, SUBSCRIBE channel [channel ...], and puts the client in subscribed mode
+	* @param writer *RESP.Writer - the writer to write the response to
+	* @param args []RESP.RESPMessage - the arguments for the command
+	* @param store *store.Store - the store to get the data from
+	* @param clientID string - the client id
+	* @param txManager *tx.TxManager - the transaction manager
+	* @return array - [subscribe, channel, number of subscriptions] for each channel, then the messages published on them
+*/
+func handleSubscribe(writer *RESP.Writer, args []RESP.RESPMessage, store *store.Store, clientID string, txManager *tx.TxManager) error {
+	if len(args) < 1 {
+		err := errWrongNumberOfArguments("SUBSCRIBE")
+		return HandleError(writer, []byte(err.Error()))
+	}
+	return pubsub.GetPubSub().Subscribe(clientID, keyArgs(args), subscriptionReply(writer, "subscribe"))
+}
+
+/*
+ 	* handlePSubscribe handles the PSUBSCRIBE command, PSUBSCRIBE pattern [pattern ...], subscribing to the channels matching glob-style patterns
+	* @param writer *RESP.Writer - the writer to write the response to
+	* @param args []RESP.RESPMessage - the arguments for the command
+	* @param store *store.Store - the store to get the data from
+	* @param clientID string - the client id
+	* @param txManager *tx.TxManager - the transaction manager
+	* @return array - [psubscribe, pattern, number of subscriptions] for each pattern, then the messages published on matching channels
+*/
+func handlePSubscribe(writer *RESP.Writer, args []RESP.RESPMessage, store *store.Store, clientID string, txManager *tx.TxManager) error {
+	if len(args) < 1 {
+		err := errWrongNumberOfArguments("PSUBSCRIBE")
+		return HandleError(writer, []byte(err.Error()))
+	}
+	return pubsub.GetPubSub().PSubscribe(clientID, keyArgs(args), subscriptionReply(writer, "psubscribe"))
+}
+
+/*
+ 	* handleUnsubscribe handles the UNSUBSCRIBE command, UNSUBSCRIBE [channel ...], from every channel when none is given
+	* @param writer *RESP.Writer - the writer to write the response to
+	* @param args []RESP.RESPMessage - the arguments for the command
+	* @param store *store.Store - the store to get the data from
+	* @param clientID string - the client id
+	* @param txManager *tx.TxManager - the transaction manager
+	* @return array - [unsubscribe, channel, number of subscriptions left] for each channel, the client leaves subscribed mode at 0
+*/
+func handleUnsubscribe(writer *RESP.Writer, args []RESP.RESPMessage, store *store.Store, clientID string, txManager *tx.TxManager) error {
+	return pubsub.GetPubSub().Unsubscribe(clientID, keyArgs(args), subscriptionReply(writer, "unsubscribe"))
+}
+
+/*
+ 	* handlePUnsubscribe handles the PUNSUBSCRIBE command, PUNSUBSCRIBE [pattern ...], from every pattern when none is given
+	* @param writer *RESP.Writer - the writer to write the response to
+	* @param args []RESP.RESPMessage - the arguments for the command
+	* @param store *store.Store - the store to get the data from
+	* @param clientID string - the client id
+	* @param txManager *tx.TxManager - the transaction manager
+	* @return array - [punsubscribe, pattern, number of subscriptions left] for each pattern
+*/
+func handlePUnsubscribe(writer *RESP.Writer, args []RESP.RESPMessage, store *store.Store, clientID string, txManager *tx.TxManager) error {
+	return pubsub.GetPubSub().PUnsubscribe(clientID, keyArgs(args), subscriptionReply(writer, "punsubscribe"))
+}
+
+/*
+ 	* handlePublish handles the PUBLISH command, PUBLISH channel message
+	* @param writer *RESP.Writer - the writer to write the response to
+	* @param args []RESP.RESPMessage - the arguments for the command
+	* @param store *store.Store - the store to get the data from
+	* @param clientID string - the client id
+	* @param txManager *tx.TxManager - the transaction manager
+	* @return integer - the number of clients the message was sent to, it is written to them later on
+*/
+func handlePublish(writer *RESP.Writer, args []RESP.RESPMessage, store *store.Store, clientID string, txManager *tx.TxManager) error {
+	if len(args) != 2 {
+		err := errWrongNumberOfArguments("PUBLISH")
+		return HandleError(writer, []byte(err.Error()))
+	}
+	receivers := pubsub.GetPubSub().Publish(string(args[0].RESPValue), args[1].RESPValue)
+	return encodeInteger(writer, int64(receivers))
+}
+
+/*
+ 	* handlePubSub handles the PUBSUB command, PUBSUB CHANNELS [pattern] | PUBSUB NUMSUB [channel ...] | PUBSUB NUMPAT
+	* @param writer *RESP.Writer - the writer to write the response to
+	* @param args []RESP.RESPMessage - the arguments for the command
+	* @param store *store.Store - the store to get the data from
+	* @param clientID string - the client id
+	* @param txManager *tx.TxManager - the transaction manager
+	* @return array - for CHANNELS, the channels with subscribers, for NUMSUB, each channel followed by its number of subscribers
+	* @return integer - for NUMPAT, the number of patterns subscribed to
+*/
+func handlePubSub(writer *RESP.Writer, args []RESP.RESPMessage, store *store.Store, clientID string, txManager *tx.TxManager) error {
+	if len(args) < 1 {
+		err := errWrongNumberOfArguments("PUBSUB")
+		return HandleError(writer, []byte(err.Error()))
+	}
+
+	ps := pubsub.GetPubSub()
+	subcommand := strings.ToUpper(string(args[0].RESPValue))
+
+	switch {
+	case subcommand == "CHANNELS" && len(args) <= 2:
+		pattern := ""
+		if len(args) == 2 {
+			pattern = string(args[1].RESPValue)
+		}
+		channels := ps.Channels(pattern)
+
+		messages := make([]RESP.RESPMessage, len(channels))
+		for i, channel := range channels {
+			messages[i] = bulkStringMessage([]byte(channel))
+		}
+		return encodeArray(writer, messages)
+
+	case subcommand == "NUMSUB":
+		channels := keyArgs(args[1:])
+		counts := ps.NumSub(channels)
+
+		messages := make([]RESP.RESPMessage, 0, 2*len(channels))
+		for i, channel := range channels {
+			messages = append(messages, bulkStringMessage([]byte(channel)), integerMessage(int64(counts[i])))
+		}
+		return encodeArray(writer, messages)
+
+	case subcommand == "NUMPAT" && len(args) == 1:
+		return encodeInteger(writer, int64(ps.NumPat()))
+
+	case subcommand == "CHANNELS" || subcommand == "NUMPAT":
+		return HandleError(writer, []byte(fmt.Sprintf("ERR wrong number of arguments for 'pubsub|%s' command", strings.ToLower(subcommand))))
+	}
+
+	return HandleError(writer, []byte(fmt.Sprintf("ERR unknown subcommand '%s'. Try PUBSUB CHANNELS, NUMSUB or NUMPAT.", args[0].RESPValue)))
+}
@@ -0,0 +1,177 @@
+package handlers
+
+import (
+	"strconv"
+	"time"
+
+	RESP "github.com/manish-singh-bisht/Redis-From-Scratch/db/resp"
+	store "github.com/manish-singh-bisht/Redis-From-Scratch/db/store"
+	tx "github.com/manish-singh-bisht/Redis-From-Scratch/db/transaction"
+)
+
+/*
+ 	* handleRateMark handles the RATE.MARK command, RATE.MARK key [n], records n events (1 by default) on a rate meter
+	* @param writer *RESP.Writer - the writer to write the response to
+	* @param args []RESP.RESPMessage - the arguments for the command
+	* @param store *store.Store - the store to get the data from
+	* @param clientID string - the client id
+	* @param txManager *tx.TxManager - the transaction manager
+	* @return error - the error if there is one
+	* @return integer - the lifetime count of the meter
+*/
+func handleRateMark(writer *RESP.Writer, args []RESP.RESPMessage, store *store.Store, clientID string, txManager *tx.TxManager) error {
+	if len(args) != 1 && len(args) != 2 {
+		err := errWrongNumberOfArguments("RATE.MARK")
+		return HandleError(writer, []byte(err.Error()))
+	}
+
+	key := string(args[0].RESPValue)
+
+	var n int64 = 1
+	if len(args) == 2 {
+		var err error
+		n, err = strconv.ParseInt(string(args[1].RESPValue), 10, 64)
+		if err != nil {
+			return HandleError(writer, []byte("ERR value is not an integer or out of range"))
+		}
+	}
+
+	count, err := store.RateMark(key, n, time.Now())
+	if err != nil {
+		return HandleError(writer, []byte(err.Error()))
+	}
+
+	signalModifiedKey(txManager, key)
+
+	return encodeInteger(writer, count)
+}
+
+/*
+ 	* handleRateGet handles the RATE.GET command, RATE.GET key
+	* @param writer *RESP.Writer - the writer to write the response to
+	* @param args []RESP.RESPMessage - the arguments for the command
+	* @param store *store.Store - the store to get the data from
+	* @param clientID string - the client id
+	* @param txManager *tx.TxManager - the transaction manager
+	* @return error - the error if there is one
+	* @return array - field/value pairs: count, mean_rate, m1_rate, m5_rate and m15_rate, rates are events per second
+*/
+func handleRateGet(writer *RESP.Writer, args []RESP.RESPMessage, store *store.Store, clientID string, txManager *tx.TxManager) error {
+	if len(args) != 1 {
+		err := errWrongNumberOfArguments("RATE.GET")
+		return HandleError(writer, []byte(err.Error()))
+	}
+
+	reading, exists, err := store.RateGet(string(args[0].RESPValue))
+	if err != nil {
+		return HandleError(writer, []byte(err.Error()))
+	}
+	if !exists {
+		return writer.EncodeNil()
+	}
+
+	return encodeArray(writer, []RESP.RESPMessage{
+		bulkStringMessage([]byte("count")),
+		integerMessage(reading.Count),
+		bulkStringMessage([]byte("mean_rate")),
+		bulkStringMessage(formatFloat(reading.MeanRate)),
+		bulkStringMessage([]byte("m1_rate")),
+		bulkStringMessage(formatFloat(reading.Rates[0])),
+		bulkStringMessage([]byte("m5_rate")),
+		bulkStringMessage(formatFloat(reading.Rates[1])),
+		bulkStringMessage([]byte("m15_rate")),
+		bulkStringMessage(formatFloat(reading.Rates[2])),
+	})
+}
+
+/*
+ 	* rateMarkAofArgv rewrites RATE.MARK into RATE.MARKAT with the time of the mark, replaying RATE.MARK itself would
+	* mark the events at replay time and show them as a burst in the averages
+	* @param args []RESP.RESPMessage - the arguments for the command
+	* @return [][]byte - the RATE.MARKAT command
+*/
+func rateMarkAofArgv(args []RESP.RESPMessage) [][]byte {
+	n := []byte("1")
+	if len(args) == 2 {
+		n = args[1].RESPValue
+	}
+	return [][]byte{[]byte("RATE.MARKAT"), args[0].RESPValue, n, []byte(strconv.FormatInt(time.Now().UnixMilli(), 10))}
+}
+
+/*
+ 	* handleRateMarkAt handles the internal RATE.MARKAT command, RATE.MARKAT key n unix-time-milliseconds,
+	* RATE.MARK as the AOF holds it, the events are marked at the time they happened
+	* @param writer *RESP.Writer - the writer to write the response to
+	* @param args []RESP.RESPMessage - the arguments for the command
+	* @param store *store.Store - the store to get the data from
+	* @param clientID string - the client id
+	* @param txManager *tx.TxManager - the transaction manager
+	* @return error - the error if there is one
+	* @return integer - the lifetime count of the meter
+*/
+func handleRateMarkAt(writer *RESP.Writer, args []RESP.RESPMessage, store *store.Store, clientID string, txManager *tx.TxManager) error {
+	if len(args) != 3 {
+		err := errWrongNumberOfArguments("RATE.MARKAT")
+		return HandleError(writer, []byte(err.Error()))
+	}
+
+	n, err := strconv.ParseInt(string(args[1].RESPValue), 10, 64)
+	if err != nil {
+		return HandleError(writer, []byte("ERR value is not an integer or out of range"))
+	}
+	at, err := strconv.ParseInt(string(args[2].RESPValue), 10, 64)
+	if err != nil {
+		return HandleError(writer, []byte("ERR value is not an integer or out of range"))
+	}
+
+	count, err := store.RateMark(string(args[0].RESPValue), n, time.UnixMilli(at))
+	if err != nil {
+		return HandleError(writer, []byte(err.Error()))
+	}
+	return encodeInteger(writer, count)
+}
+
+/*
+ 	* handleRateRestore handles the internal RATE.RESTORE command,
+	* RATE.RESTORE key count uncounted m1-rate m5-rate m15-rate initialized last-tick-ms created-ms,
+	* which the AOF base rebuilds a meter with, averages included
+	* @param writer *RESP.Writer - the writer to write the response to
+	* @param args []RESP.RESPMessage - the arguments for the command
+	* @param st *store.Store - the store to get the data from
+	* @param clientID string - the client id
+	* @param txManager *tx.TxManager - the transaction manager
+	* @return error - the error if there is one
+	* @return simple string - OK
+*/
+func handleRateRestore(writer *RESP.Writer, args []RESP.RESPMessage, st *store.Store, clientID string, txManager *tx.TxManager) error {
+	if len(args) != 9 {
+		err := errWrongNumberOfArguments("RATE.RESTORE")
+		return HandleError(writer, []byte(err.Error()))
+	}
+
+	var snapshot store.RateMeterSnapshot
+	var integers [4]int64 // count, uncounted, last tick and created
+	for i, arg := range []RESP.RESPMessage{args[1], args[2], args[7], args[8]} {
+		value, err := strconv.ParseInt(string(arg.RESPValue), 10, 64)
+		if err != nil {
+			return HandleError(writer, []byte("ERR value is not an integer or out of range"))
+		}
+		integers[i] = value
+	}
+	for i := range snapshot.Rates {
+		rate, err := strconv.ParseFloat(string(args[3+i].RESPValue), 64)
+		if err != nil {
+			return HandleError(writer, []byte("ERR value is not a valid float"))
+		}
+		snapshot.Rates[i] = rate
+	}
+
+	snapshot.Count = integers[0]
+	snapshot.Uncounted = integers[1]
+	snapshot.Initialized = string(args[6].RESPValue) == "1"
+	snapshot.LastTick = time.UnixMilli(integers[2])
+	snapshot.Created = time.UnixMilli(integers[3])
+
+	st.RestoreRateMeter(string(args[0].RESPValue), snapshot, 0)
+	return encodeOK(writer)
+}
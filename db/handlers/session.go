@@ -0,0 +1,85 @@
+package handlers
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	RESP "github.com/manish-singh-bisht/Redis-From-Scratch/db/resp"
+	store "github.com/manish-singh-bisht/Redis-From-Scratch/db/store"
+	tx "github.com/manish-singh-bisht/Redis-From-Scratch/db/transaction"
+)
+
+/*
+ 	* handleSession handles the SESSION command, SESSION CREATE [TTL seconds] | SESSION RESUME token
+	* a session keeps the queued MULTI commands, the watched keys and the position of a blocked XREAD of a client,
+	* once its connection is lost they are kept for the TTL of the session, 300 seconds by default, for a new
+	* connection to take over with RESUME
+	* @param writer *RESP.Writer - the writer to write the response to
+	* @param args []RESP.RESPMessage - the arguments for the command
+	* @param store *store.Store - the store to get the data from
+	* @param clientID string - the client id
+	* @param txManager *tx.TxManager - the transaction manager
+	* @return bulk string - for CREATE, the token that resumes the session
+	* @return array - for RESUME, ttl, multi, queued, watched and blocked-read, each followed by its value,
+	* blocked-read being the stream and ID pairs the last blocked XREAD waited after, empty if it replied
+*/
+func handleSession(writer *RESP.Writer, args []RESP.RESPMessage, store *store.Store, clientID string, txManager *tx.TxManager) error {
+	if len(args) < 1 {
+		err := errWrongNumberOfArguments("SESSION")
+		return HandleError(writer, []byte(err.Error()))
+	}
+
+	subcommand := strings.ToUpper(string(args[0].RESPValue))
+
+	switch {
+	case subcommand == "CREATE" && (len(args) == 1 || len(args) == 3):
+		ttl := tx.DefaultSessionTTL
+		if len(args) == 3 {
+			if !strings.EqualFold(string(args[1].RESPValue), "TTL") {
+				return HandleError(writer, []byte(errSyntax.Error()))
+			}
+			seconds, err := strconv.ParseInt(string(args[2].RESPValue), 10, 64)
+			if err != nil || seconds <= 0 || seconds > int64(time.Duration(1<<63-1)/time.Second) {
+				return HandleError(writer, []byte("ERR invalid TTL, it must be a positive number of seconds"))
+			}
+			ttl = time.Duration(seconds) * time.Second
+		}
+
+		token, err := txManager.CreateSession(clientID, ttl)
+		if err != nil {
+			return HandleError(writer, []byte(err.Error()))
+		}
+		message := bulkStringMessage([]byte(token))
+		return writer.Encode(&message)
+
+	case subcommand == "RESUME" && len(args) == 2:
+		state, err := txManager.ResumeSession(clientID, string(args[1].RESPValue))
+		if err != nil {
+			return HandleError(writer, []byte(err.Error()))
+		}
+
+		multi := int64(0)
+		if state.InMulti {
+			multi = 1
+		}
+		position := make([]RESP.RESPMessage, len(state.ReadPosition))
+		for i, value := range state.ReadPosition {
+			position[i] = bulkStringMessage([]byte(value))
+		}
+
+		return encodeArray(writer, []RESP.RESPMessage{
+			bulkStringMessage([]byte("ttl")), integerMessage(int64(state.TTL / time.Second)),
+			bulkStringMessage([]byte("multi")), integerMessage(multi),
+			bulkStringMessage([]byte("queued")), integerMessage(int64(state.Queued)),
+			bulkStringMessage([]byte("watched")), integerMessage(int64(state.Watched)),
+			bulkStringMessage([]byte("blocked-read")), arrayMessage(position),
+		})
+
+	case subcommand == "CREATE" || subcommand == "RESUME":
+		return HandleError(writer, []byte(fmt.Sprintf("ERR wrong number of arguments for 'session|%s' command", strings.ToLower(subcommand))))
+	}
+
+	return HandleError(writer, []byte(fmt.Sprintf("ERR unknown subcommand '%s'. Try SESSION CREATE or RESUME.", args[0].RESPValue)))
+}
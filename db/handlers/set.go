@@ -0,0 +1,574 @@
+package handlers
+
+import (
+	"strconv"
+	"strings"
+
+	RESP "github.com/manish-singh-bisht/Redis-From-Scratch/db/resp"
+	store "github.com/manish-singh-bisht/Redis-From-Scratch/db/store"
+	tx "github.com/manish-singh-bisht/Redis-From-Scratch/db/transaction"
+)
+
+/*
+ 	* handleSAdd handles the SADD command, SADD key member [member ...]
+	* @param writer *RESP.Writer - the writer to write the response to
+	* @param args []RESP.RESPMessage - the arguments for the command
+	* @param store *store.Store - the store to get the data from
+	* @param clientID string - the client id
+	* @param txManager *tx.TxManager - the transaction manager
+	* @return error - the error if there is one
+	* @return integer - the number of members that were added, not counting the ones already there
+*/
+func handleSAdd(writer *RESP.Writer, args []RESP.RESPMessage, store *store.Store, clientID string, txManager *tx.TxManager) error {
+	if len(args) < 2 {
+		err := errWrongNumberOfArguments("SADD")
+		return HandleError(writer, []byte(err.Error()))
+	}
+
+	key := string(args[0].RESPValue)
+
+	added, err := store.SAdd(key, argValues(args[1:]))
+	if err != nil {
+		return HandleError(writer, []byte(err.Error()))
+	}
+
+	if added > 0 {
+		signalModifiedKey(txManager, key)
+	}
+
+	return encodeInteger(writer, int64(added))
+}
+
+/*
+ 	* handleSRem handles the SREM command, SREM key member [member ...]
+	* @param writer *RESP.Writer - the writer to write the response to
+	* @param args []RESP.RESPMessage - the arguments for the command
+	* @param store *store.Store - the store to get the data from
+	* @param clientID string - the client id
+	* @param txManager *tx.TxManager - the transaction manager
+	* @return error - the error if there is one
+	* @return integer - the number of members that were removed
+*/
+func handleSRem(writer *RESP.Writer, args []RESP.RESPMessage, store *store.Store, clientID string, txManager *tx.TxManager) error {
+	if len(args) < 2 {
+		err := errWrongNumberOfArguments("SREM")
+		return HandleError(writer, []byte(err.Error()))
+	}
+
+	key := string(args[0].RESPValue)
+
+	removed, err := store.SRem(key, keyArgs(args[1:]))
+	if err != nil {
+		return HandleError(writer, []byte(err.Error()))
+	}
+
+	if removed > 0 {
+		signalModifiedKey(txManager, key)
+	}
+
+	return encodeInteger(writer, int64(removed))
+}
+
+/*
+ 	* handleSCard handles the SCARD command, SCARD key
+	* @param writer *RESP.Writer - the writer to write the response to
+	* @param args []RESP.RESPMessage - the arguments for the command
+	* @param store *store.Store - the store to get the data from
+	* @param clientID string - the client id
+	* @param txManager *tx.TxManager - the transaction manager
+	* @return error - the error if there is one
+	* @return integer - the number of members, 0 if the key doesn't exist
+*/
+func handleSCard(writer *RESP.Writer, args []RESP.RESPMessage, store *store.Store, clientID string, txManager *tx.TxManager) error {
+	if len(args) != 1 {
+		err := errWrongNumberOfArguments("SCARD")
+		return HandleError(writer, []byte(err.Error()))
+	}
+
+	length, err := store.SCard(string(args[0].RESPValue))
+	if err != nil {
+		return HandleError(writer, []byte(err.Error()))
+	}
+
+	return encodeInteger(writer, int64(length))
+}
+
+/*
+ 	* handleSIsMember handles the SISMEMBER command, SISMEMBER key member
+	* @param writer *RESP.Writer - the writer to write the response to
+	* @param args []RESP.RESPMessage - the arguments for the command
+	* @param store *store.Store - the store to get the data from
+	* @param clientID string - the client id
+	* @param txManager *tx.TxManager - the transaction manager
+	* @return error - the error if there is one
+	* @return integer - 1 if the member is in the set, 0 otherwise
+*/
+func handleSIsMember(writer *RESP.Writer, args []RESP.RESPMessage, store *store.Store, clientID string, txManager *tx.TxManager) error {
+	if len(args) != 2 {
+		err := errWrongNumberOfArguments("SISMEMBER")
+		return HandleError(writer, []byte(err.Error()))
+	}
+
+	found, err := store.SIsMember(string(args[0].RESPValue), keyArgs(args[1:]))
+	if err != nil {
+		return HandleError(writer, []byte(err.Error()))
+	}
+	if !found[0] {
+		return encodeInteger(writer, 0)
+	}
+
+	return encodeInteger(writer, 1)
+}
+
+/*
+ 	* handleSMIsMember handles the SMISMEMBER command, SMISMEMBER key member [member ...]
+	* @param writer *RESP.Writer - the writer to write the response to
+	* @param args []RESP.RESPMessage - the arguments for the command
+	* @param store *store.Store - the store to get the data from
+	* @param clientID string - the client id
+	* @param txManager *tx.TxManager - the transaction manager
+	* @return error - the error if there is one
+	* @return array - for each member, 1 if it is in the set, 0 otherwise
+*/
+func handleSMIsMember(writer *RESP.Writer, args []RESP.RESPMessage, store *store.Store, clientID string, txManager *tx.TxManager) error {
+	if len(args) < 2 {
+		err := errWrongNumberOfArguments("SMISMEMBER")
+		return HandleError(writer, []byte(err.Error()))
+	}
+
+	found, err := store.SIsMember(string(args[0].RESPValue), keyArgs(args[1:]))
+	if err != nil {
+		return HandleError(writer, []byte(err.Error()))
+	}
+
+	elements := make([]RESP.RESPMessage, len(found))
+	for i, isMember := range found {
+		elements[i] = integerMessage(0)
+		if isMember {
+			elements[i] = integerMessage(1)
+		}
+	}
+
+	return encodeArray(writer, elements)
+}
+
+/*
+ 	* handleSMembers handles the SMEMBERS command, SMEMBERS key
+	* @param writer *RESP.Writer - the writer to write the response to
+	* @param args []RESP.RESPMessage - the arguments for the command
+	* @param store *store.Store - the store to get the data from
+	* @param clientID string - the client id
+	* @param txManager *tx.TxManager - the transaction manager
+	* @return error - the error if there is one
+	* @return array - the members, empty if the key doesn't exist
+*/
+func handleSMembers(writer *RESP.Writer, args []RESP.RESPMessage, store *store.Store, clientID string, txManager *tx.TxManager) error {
+	if len(args) != 1 {
+		err := errWrongNumberOfArguments("SMEMBERS")
+		return HandleError(writer, []byte(err.Error()))
+	}
+
+	members, err := store.SMembers(string(args[0].RESPValue))
+	if err != nil {
+		return HandleError(writer, []byte(err.Error()))
+	}
+
+	return encodeArray(writer, bulkStringMessages(members))
+}
+
+/*
+ 	* handleSRandMember handles the SRANDMEMBER command, SRANDMEMBER key [count]
+	* a positive count returns different members, a negative one allows the same member more than once
+	* @param writer *RESP.Writer - the writer to write the response to
+	* @param args []RESP.RESPMessage - the arguments for the command
+	* @param store *store.Store - the store to get the data from
+	* @param clientID string - the client id
+	* @param txManager *tx.TxManager - the transaction manager
+	* @return error - the error if there is one
+	* @return bulk string - a random member, nil if the key doesn't exist, an array of members when count is given
+*/
+func handleSRandMember(writer *RESP.Writer, args []RESP.RESPMessage, store *store.Store, clientID string, txManager *tx.TxManager) error {
+	if len(args) != 1 && len(args) != 2 {
+		err := errWrongNumberOfArguments("SRANDMEMBER")
+		return HandleError(writer, []byte(err.Error()))
+	}
+
+	count, distinct := 1, true
+	if len(args) == 2 {
+		var err error
+		count, err = strconv.Atoi(string(args[1].RESPValue))
+		if err != nil {
+			return HandleError(writer, []byte("ERR value is not an integer or out of range"))
+		}
+		if count < 0 {
+			count, distinct = -count, false
+		}
+	}
+
+	members, err := store.SRandMember(string(args[0].RESPValue), count, distinct)
+	if err != nil {
+		return HandleError(writer, []byte(err.Error()))
+	}
+
+	return encodeSetMembers(writer, members, len(args) == 1)
+}
+
+/*
+ 	* handleSPop handles the SPOP command, SPOP key [count]
+	* @param writer *RESP.Writer - the writer to write the response to
+	* @param args []RESP.RESPMessage - the arguments for the command
+	* @param store *store.Store - the store to get the data from
+	* @param clientID string - the client id
+	* @param txManager *tx.TxManager - the transaction manager
+	* @return error - the error if there is one
+	* @return bulk string - the removed member, nil if the key doesn't exist, an array of members when count is given
+*/
+func handleSPop(writer *RESP.Writer, args []RESP.RESPMessage, store *store.Store, clientID string, txManager *tx.TxManager) error {
+	if len(args) != 1 && len(args) != 2 {
+		err := errWrongNumberOfArguments("SPOP")
+		return HandleError(writer, []byte(err.Error()))
+	}
+
+	key := string(args[0].RESPValue)
+
+	count := 1
+	if len(args) == 2 {
+		var err error
+		count, err = strconv.Atoi(string(args[1].RESPValue))
+		if err != nil || count < 0 {
+			return HandleError(writer, []byte("ERR value is out of range, must be positive"))
+		}
+	}
+
+	members, err := store.SPop(key, count)
+	if err != nil {
+		return HandleError(writer, []byte(err.Error()))
+	}
+
+	if len(members) > 0 {
+		signalModifiedKey(txManager, key)
+	}
+
+	return encodeSetMembers(writer, members, len(args) == 1)
+}
+
+/*
+ 	* encodeSetMembers writes the reply of SPOP and SRANDMEMBER
+	* @param writer *RESP.Writer - the writer to write the response to
+	* @param members [][]byte - the members
+	* @param single bool - true when no count was given, the reply is then the member itself or nil
+	* @return error - the error if there is one
+*/
+func encodeSetMembers(writer *RESP.Writer, members [][]byte, single bool) error {
+	if !single {
+		return encodeArray(writer, bulkStringMessages(members))
+	}
+	if len(members) == 0 {
+		return writer.EncodeNil()
+	}
+
+	return writer.Encode(&RESP.RESPMessage{
+		RESPType:  RESP.BulkString,
+		RESPLen:   len(members[0]),
+		RESPValue: members[0],
+	})
+}
+
+/*
+ 	* handleSMove handles the SMOVE command, SMOVE source destination member
+	* @param writer *RESP.Writer - the writer to write the response to
+	* @param args []RESP.RESPMessage - the arguments for the command
+	* @param store *store.Store - the store to get the data from
+	* @param clientID string - the client id
+	* @param txManager *tx.TxManager - the transaction manager
+	* @return error - the error if there is one
+	* @return integer - 1 if the member was moved, 0 if it is not in the source set
+*/
+func handleSMove(writer *RESP.Writer, args []RESP.RESPMessage, store *store.Store, clientID string, txManager *tx.TxManager) error {
+	if len(args) != 3 {
+		err := errWrongNumberOfArguments("SMOVE")
+		return HandleError(writer, []byte(err.Error()))
+	}
+
+	src, dst := string(args[0].RESPValue), string(args[1].RESPValue)
+
+	moved, err := store.SMove(src, dst, string(args[2].RESPValue))
+	if err != nil {
+		return HandleError(writer, []byte(err.Error()))
+	}
+	if !moved {
+		return encodeInteger(writer, 0)
+	}
+
+	signalModifiedKey(txManager, src)
+	signalModifiedKey(txManager, dst)
+
+	return encodeInteger(writer, 1)
+}
+
+/*
+ 	* handleSInter handles the SINTER command, SINTER key [key ...]
+	* @param writer *RESP.Writer - the writer to write the response to
+	* @param args []RESP.RESPMessage - the arguments for the command
+	* @param store *store.Store - the store to get the data from
+	* @param clientID string - the client id
+	* @param txManager *tx.TxManager - the transaction manager
+	* @return error - the error if there is one
+	* @return array - the members in every set, a missing key counts as an empty set
+*/
+func handleSInter(writer *RESP.Writer, args []RESP.RESPMessage, st *store.Store, clientID string, txManager *tx.TxManager) error {
+	return setOpGeneric(writer, args, st, "SINTER", store.SetInter)
+}
+
+/*
+ 	* handleSUnion handles the SUNION command, SUNION key [key ...]
+	* @param writer *RESP.Writer - the writer to write the response to
+	* @param args []RESP.RESPMessage - the arguments for the command
+	* @param store *store.Store - the store to get the data from
+	* @param clientID string - the client id
+	* @param txManager *tx.TxManager - the transaction manager
+	* @return error - the error if there is one
+	* @return array - the members in any of the sets
+*/
+func handleSUnion(writer *RESP.Writer, args []RESP.RESPMessage, st *store.Store, clientID string, txManager *tx.TxManager) error {
+	return setOpGeneric(writer, args, st, "SUNION", store.SetUnion)
+}
+
+/*
+ 	* handleSDiff handles the SDIFF command, SDIFF key [key ...]
+	* @param writer *RESP.Writer - the writer to write the response to
+	* @param args []RESP.RESPMessage - the arguments for the command
+	* @param store *store.Store - the store to get the data from
+	* @param clientID string - the client id
+	* @param txManager *tx.TxManager - the transaction manager
+	* @return error - the error if there is one
+	* @return array - the members of the first set that are in none of the others
+*/
+func handleSDiff(writer *RESP.Writer, args []RESP.RESPMessage, st *store.Store, clientID string, txManager *tx.TxManager) error {
+	return setOpGeneric(writer, args, st, "SDIFF", store.SetDiff)
+}
+
+/*
+ 	* setOpGeneric implements SINTER, SUNION and SDIFF
+	* @param writer *RESP.Writer - the writer to write the response to
+	* @param args []RESP.RESPMessage - the arguments for the command
+	* @param st *store.Store - the store to get the data from
+	* @param cmd string - the name of the command, for errors
+	* @param op store.SetOperation - the operation
+	* @return error - the error if there is one
+*/
+func setOpGeneric(writer *RESP.Writer, args []RESP.RESPMessage, st *store.Store, cmd string, op store.SetOperation) error {
+	if len(args) < 1 {
+		err := errWrongNumberOfArguments(cmd)
+		return HandleError(writer, []byte(err.Error()))
+	}
+
+	members, err := st.SetOp(op, keyArgs(args))
+	if err != nil {
+		return HandleError(writer, []byte(err.Error()))
+	}
+
+	return encodeArray(writer, bulkStringMessages(members))
+}
+
+/*
+ 	* handleSInterStore handles the SINTERSTORE command, SINTERSTORE destination key [key ...]
+	* @param writer *RESP.Writer - the writer to write the response to
+	* @param args []RESP.RESPMessage - the arguments for the command
+	* @param store *store.Store - the store to get the data from
+	* @param clientID string - the client id
+	* @param txManager *tx.TxManager - the transaction manager
+	* @return error - the error if there is one
+	* @return integer - the number of members of the result, stored at destination
+*/
+func handleSInterStore(writer *RESP.Writer, args []RESP.RESPMessage, st *store.Store, clientID string, txManager *tx.TxManager) error {
+	return setOpStoreGeneric(writer, args, st, txManager, "SINTERSTORE", store.SetInter)
+}
+
+/*
+ 	* handleSUnionStore handles the SUNIONSTORE command, SUNIONSTORE destination key [key ...]
+	* @param writer *RESP.Writer - the writer to write the response to
+	* @param args []RESP.RESPMessage - the arguments for the command
+	* @param store *store.Store - the store to get the data from
+	* @param clientID string - the client id
+	* @param txManager *tx.TxManager - the transaction manager
+	* @return error - the error if there is one
+	* @return integer - the number of members of the result, stored at destination
+*/
+func handleSUnionStore(writer *RESP.Writer, args []RESP.RESPMessage, st *store.Store, clientID string, txManager *tx.TxManager) error {
+	return setOpStoreGeneric(writer, args, st, txManager, "SUNIONSTORE", store.SetUnion)
+}
+
+/*
+ 	* handleSDiffStore handles the SDIFFSTORE command, SDIFFSTORE destination key [key ...]
+	* @param writer *RESP.Writer - the writer to write the response to
+	* @param args []RESP.RESPMessage - the arguments for the command
+	* @param store *store.Store - the store to get the data from
+	* @param clientID string - the client id
+	* @param txManager *tx.TxManager - the transaction manager
+	* @return error - the error if there is one
+	* @return integer - the number of members of the result, stored at destination
+*/
+func handleSDiffStore(writer *RESP.Writer, args []RESP.RESPMessage, st *store.Store, clientID string, txManager *tx.TxManager) error {
+	return setOpStoreGeneric(writer, args, st, txManager, "SDIFFSTORE", store.SetDiff)
+}
+
+/*
+ 	* setOpStoreGeneric implements SINTERSTORE, SUNIONSTORE and SDIFFSTORE,
+	* destination is overwritten whatever its type, and deleted when the result is empty
+	* @param writer *RESP.Writer - the writer to write the response to
+	* @param args []RESP.RESPMessage - the arguments for the command
+	* @param st *store.Store - the store to get the data from
+	* @param txManager *tx.TxManager - the transaction manager
+	* @param cmd string - the name of the command, for errors
+	* @param op store.SetOperation - the operation
+	* @return error - the error if there is one
+*/
+func setOpStoreGeneric(writer *RESP.Writer, args []RESP.RESPMessage, st *store.Store, txManager *tx.TxManager, cmd string, op store.SetOperation) error {
+	if len(args) < 2 {
+		err := errWrongNumberOfArguments(cmd)
+		return HandleError(writer, []byte(err.Error()))
+	}
+
+	dst := string(args[0].RESPValue)
+
+	length, err := st.SetOpStore(op, dst, keyArgs(args[1:]))
+	if err != nil {
+		return HandleError(writer, []byte(err.Error()))
+	}
+
+	signalModifiedKey(txManager, dst)
+
+	return encodeInteger(writer, int64(length))
+}
+
+/*
+ 	* handleSInterCard handles the SINTERCARD command, SINTERCARD numkeys key [key ...] [LIMIT limit]
+	* @param writer *RESP.Writer - the writer to write the response to
+	* @param args []RESP.RESPMessage - the arguments for the command
+	* @param store *store.Store - the store to get the data from
+	* @param clientID string - the client id
+	* @param txManager *tx.TxManager - the transaction manager
+	* @return error - the error if there is one
+	* @return integer - the number of members in every set, at most limit when it is given and not 0
+*/
+func handleSInterCard(writer *RESP.Writer, args []RESP.RESPMessage, store *store.Store, clientID string, txManager *tx.TxManager) error {
+	if len(args) < 2 {
+		err := errWrongNumberOfArguments("SINTERCARD")
+		return HandleError(writer, []byte(err.Error()))
+	}
+
+	numKeys, err := strconv.Atoi(string(args[0].RESPValue))
+	if err != nil {
+		return HandleError(writer, []byte("ERR numkeys should be greater than 0"))
+	}
+	if numKeys < 1 {
+		return HandleError(writer, []byte("ERR numkeys should be greater than 0"))
+	}
+	if numKeys > len(args)-1 {
+		return HandleError(writer, []byte("ERR Number of keys can't be greater than number of args"))
+	}
+
+	limit := 0
+	options := args[1+numKeys:]
+	for i := 0; i < len(options); i += 2 {
+		if i+1 >= len(options) || strings.ToUpper(string(options[i].RESPValue)) != "LIMIT" {
+			return HandleError(writer, []byte(errSyntax.Error()))
+		}
+		limit, err = strconv.Atoi(string(options[i+1].RESPValue))
+		if err != nil || limit < 0 {
+			return HandleError(writer, []byte("ERR LIMIT can't be negative"))
+		}
+	}
+
+	count, err := store.SInterCard(keyArgs(args[1:1+numKeys]), limit)
+	if err != nil {
+		return HandleError(writer, []byte(err.Error()))
+	}
+
+	return encodeInteger(writer, int64(count))
+}
+
+/*
+ 	* handleSScan handles the SSCAN command, SSCAN key cursor [MATCH pattern] [COUNT count]
+	* with the same guarantees as SCAN, a member present for the whole iteration is returned exactly once
+	* @param writer *RESP.Writer - the writer to write the response to
+	* @param args []RESP.RESPMessage - the arguments for the command
+	* @param store *store.Store - the store to get the data from
+	* @param clientID string - the client id
+	* @param txManager *tx.TxManager - the transaction manager
+	* @return error - the error if there is one
+	* @return array - the cursor for the next call, 0 when the iteration is over, and an array of members
+*/
+func handleSScan(writer *RESP.Writer, args []RESP.RESPMessage, store *store.Store, clientID string, txManager *tx.TxManager) error {
+	if len(args) < 2 {
+		err := errWrongNumberOfArguments("SSCAN")
+		return HandleError(writer, []byte(err.Error()))
+	}
+
+	cursor, err := strconv.ParseUint(string(args[1].RESPValue), 10, 64)
+	if err != nil {
+		return HandleError(writer, []byte("ERR invalid cursor"))
+	}
+
+	count := 10
+	pattern := ""
+
+	for i := 2; i < len(args); i += 2 {
+		if i+1 >= len(args) {
+			return HandleError(writer, []byte("ERR syntax error"))
+		}
+		value := string(args[i+1].RESPValue)
+
+		switch strings.ToUpper(string(args[i].RESPValue)) {
+		case "MATCH":
+			pattern = value
+		case "COUNT":
+			count, err = strconv.Atoi(value)
+			if err != nil {
+				return HandleError(writer, []byte("ERR value is not an integer or out of range"))
+			}
+			if count < 1 {
+				return HandleError(writer, []byte("ERR syntax error"))
+			}
+		default:
+			return HandleError(writer, []byte("ERR syntax error"))
+		}
+	}
+
+	members, next, err := store.SScan(string(args[0].RESPValue), cursor, count, pattern)
+	if err != nil {
+		return HandleError(writer, []byte(err.Error()))
+	}
+
+	return encodeArray(writer, []RESP.RESPMessage{
+		bulkStringMessage([]byte(strconv.FormatUint(next, 10))),
+		{
+			RESPType:      RESP.Array,
+			RESPLen:       len(members),
+			RESPArrayElem: bulkStringMessages(members),
+		},
+	})
+}
+
+/*
+ 	* spopAofArgv rewrites SPOP into SREM of the members it removed, replaying SPOP would pick other members at random
+	* @param args []RESP.RESPMessage - the arguments for the command
+	* @param reply *RESP.RESPMessage - the reply the command produced
+	* @return [][]byte - the SREM command, nil if nothing was removed
+*/
+func spopAofArgv(args []RESP.RESPMessage, reply *RESP.RESPMessage) [][]byte {
+	argv := [][]byte{[]byte("SREM"), args[0].RESPValue}
+	if reply.IsArray() {
+		for _, member := range reply.RESPArrayElem {
+			argv = append(argv, member.RESPValue)
+		}
+	} else if reply.RESPValue != nil {
+		argv = append(argv, reply.RESPValue)
+	}
+
+	if len(argv) == 2 {
+		return nil
+	}
+	return argv
+}
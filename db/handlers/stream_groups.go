@@ -0,0 +1,605 @@
+package handlers
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	RESP "github.com/manish-singh-bisht/Redis-From-Scratch/db/resp"
+	store "github.com/manish-singh-bisht/Redis-From-Scratch/db/store"
+	tx "github.com/manish-singh-bisht/Redis-From-Scratch/db/transaction"
+)
+
+/*
+ 	* streamEntriesMessages builds the reply of XREAD and XREADGROUP, the name of each stream followed by its entries
+	* @param st *store.Store - the store, to build the entries
+	* @param entries []store.StreamEntries - the entries of each stream
+	* @return []RESP.RESPMessage - a [name, entries] array for each stream
+*/
+func streamEntriesMessages(st *store.Store, entries []store.StreamEntries) []RESP.RESPMessage {
+	messages := make([]RESP.RESPMessage, len(entries))
+	for i, stream := range entries {
+		records := st.CreateStreamMessages(stream.Records)
+		messages[i] = RESP.RESPMessage{
+			RESPType: RESP.Array,
+			RESPLen:  2,
+			RESPArrayElem: []RESP.RESPMessage{
+				bulkStringMessage([]byte(stream.Stream)),
+				{RESPType: RESP.Array, RESPLen: len(records), RESPArrayElem: records},
+			},
+		}
+	}
+	return messages
+}
+
+/*
+ 	* streamIdMessages builds an array of the IDs of entries, for the JUSTID option
+	* @param records []store.StreamRecord - the entries
+	* @return []RESP.RESPMessage - the IDs
+*/
+func streamIdMessages(records []store.StreamRecord) []RESP.RESPMessage {
+	messages := make([]RESP.RESPMessage, len(records))
+	for i, record := range records {
+		messages[i] = bulkStringMessage([]byte(record.Id))
+	}
+	return messages
+}
+
+/*
+ 	* parseMinIdleTime parses the min-idle-time of XCLAIM and XAUTOCLAIM, a negative one is taken as 0
+	* @param value []byte - the time in milliseconds
+	* @param cmd string - XCLAIM or XAUTOCLAIM, for the error
+	* @return time.Duration - the time
+	* @return error - the error if it is not an integer
+*/
+func parseMinIdleTime(value []byte, cmd string) (time.Duration, error) {
+	ms, err := strconv.ParseInt(string(value), 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("ERR Invalid min-idle-time argument for %s", cmd)
+	}
+	return time.Duration(max(ms, 0)) * time.Millisecond, nil
+}
+
+/*
+ 	* handleXGroup handles the XGROUP command, which manages the consumer groups of a stream
+	* XGROUP CREATE key group id|$ [MKSTREAM]
+	* XGROUP SETID key group id|$
+	* XGROUP DESTROY key group
+	* XGROUP CREATECONSUMER key group consumer
+	* XGROUP DELCONSUMER key group consumer
+	* @param writer *RESP.Writer - the writer to write the response to
+	* @param args []RESP.RESPMessage - the arguments for the command
+	* @param store *store.Store - the store to get the data from
+	* @param clientID string - the client id
+	* @param txManager *tx.TxManager - the transaction manager
+	* @return error - the error if there is one
+	* @return simple string - OK for CREATE and SETID
+	* @return integer - 1 if the group or consumer was destroyed or created, 0 if it wasn't there or was already there,
+	* and for DELCONSUMER the number of entries that were pending for the consumer
+*/
+func handleXGroup(writer *RESP.Writer, args []RESP.RESPMessage, store *store.Store, clientID string, txManager *tx.TxManager) error {
+	if len(args) < 1 {
+		err := errWrongNumberOfArguments("XGROUP")
+		return HandleError(writer, []byte(err.Error()))
+	}
+
+	subcommand := strings.ToUpper(string(args[0].RESPValue))
+
+	switch {
+	case subcommand == "CREATE" && len(args) >= 4:
+		mkStream := false
+		for _, arg := range args[4:] {
+			if strings.ToUpper(string(arg.RESPValue)) != "MKSTREAM" {
+				return HandleError(writer, []byte(errSyntax.Error()))
+			}
+			mkStream = true
+		}
+
+		streamName := string(args[1].RESPValue)
+		if err := store.XGroupCreate(streamName, string(args[2].RESPValue), string(args[3].RESPValue), mkStream); err != nil {
+			return HandleError(writer, []byte(err.Error()))
+		}
+		if mkStream {
+			signalModifiedKey(txManager, streamName)
+		}
+		return encodeOK(writer)
+
+	case subcommand == "SETID" && len(args) == 4:
+		if err := store.XGroupSetID(string(args[1].RESPValue), string(args[2].RESPValue), string(args[3].RESPValue)); err != nil {
+			return HandleError(writer, []byte(err.Error()))
+		}
+		return encodeOK(writer)
+
+	case subcommand == "DESTROY" && len(args) == 3:
+		destroyed, err := store.XGroupDestroy(string(args[1].RESPValue), string(args[2].RESPValue))
+		if err != nil {
+			return HandleError(writer, []byte(err.Error()))
+		}
+		if destroyed {
+			return encodeInteger(writer, 1)
+		}
+		return encodeInteger(writer, 0)
+
+	case subcommand == "CREATECONSUMER" && len(args) == 4:
+		created, err := store.XGroupCreateConsumer(string(args[1].RESPValue), string(args[2].RESPValue), string(args[3].RESPValue))
+		if err != nil {
+			return HandleError(writer, []byte(err.Error()))
+		}
+		if created {
+			return encodeInteger(writer, 1)
+		}
+		return encodeInteger(writer, 0)
+
+	case subcommand == "DELCONSUMER" && len(args) == 4:
+		pending, err := store.XGroupDelConsumer(string(args[1].RESPValue), string(args[2].RESPValue), string(args[3].RESPValue))
+		if err != nil {
+			return HandleError(writer, []byte(err.Error()))
+		}
+		return encodeInteger(writer, int64(pending))
+
+	case subcommand == "CREATE" || subcommand == "SETID" || subcommand == "DESTROY" || subcommand == "CREATECONSUMER" || subcommand == "DELCONSUMER":
+		return HandleError(writer, []byte(fmt.Sprintf("ERR wrong number of arguments for 'xgroup|%s' command", strings.ToLower(subcommand))))
+	}
+
+	return HandleError(writer, []byte(fmt.Sprintf("ERR unknown subcommand '%s'. Try XGROUP CREATE, SETID, DESTROY, CREATECONSUMER or DELCONSUMER.", args[0].RESPValue)))
+}
+
+/*
+ 	* handleXReadGroup handles the XREADGROUP command,
+	* XREADGROUP GROUP group consumer [COUNT count] [BLOCK ms] [NOACK] STREAMS key [key ...] id [id ...]
+	* with the ID ">" it reads the entries never delivered to the group, and adds them to the consumer's pending entries
+	* unless NOACK is given, with any other ID it reads the consumer's pending entries after it again
+	* @param writer *RESP.Writer - the writer to write the response to
+	* @param args []RESP.RESPMessage - the arguments for the command
+	* @param store *store.Store - the store to get the data from
+	* @param clientID string - the client id
+	* @param txManager *tx.TxManager - the transaction manager
+	* @return error - the error if there is one
+	* @return array - the name and the entries of each stream that had any, like XREAD, nil if there were none
+*/
+func handleXReadGroup(writer *RESP.Writer, args []RESP.RESPMessage, st *store.Store, clientID string, txManager *tx.TxManager) error {
+	if len(args) < 6 {
+		err := errWrongNumberOfArguments("XREADGROUP")
+		return HandleError(writer, []byte(err.Error()))
+	}
+
+	if strings.ToUpper(string(args[0].RESPValue)) != "GROUP" {
+		return HandleError(writer, []byte("ERR Missing GROUP option for XREADGROUP"))
+	}
+	group := string(args[1].RESPValue)
+	consumer := string(args[2].RESPValue)
+
+	blockMs := -1
+	count := 0
+	noAck := false
+	streamStartIdx := -1
+
+options:
+	for i := 3; i < len(args); i++ {
+		switch strings.ToUpper(string(args[i].RESPValue)) {
+		case "BLOCK":
+			if i+1 >= len(args) {
+				return HandleError(writer, []byte(errSyntax.Error()))
+			}
+			var err error
+			blockMs, err = strconv.Atoi(string(args[i+1].RESPValue))
+			if err != nil || blockMs < 0 {
+				return HandleError(writer, []byte("ERR invalid BLOCK time"))
+			}
+			i++
+		case "COUNT":
+			if i+1 >= len(args) {
+				return HandleError(writer, []byte(errSyntax.Error()))
+			}
+			var err error
+			count, err = strconv.Atoi(string(args[i+1].RESPValue))
+			if err != nil || count < 0 {
+				return HandleError(writer, []byte("ERR invalid COUNT"))
+			}
+			i++
+		case "NOACK":
+			noAck = true
+		case "STREAMS":
+			streamStartIdx = i + 1
+			break options
+		default:
+			return HandleError(writer, []byte(errSyntax.Error()))
+		}
+	}
+
+	if streamStartIdx == -1 {
+		return HandleError(writer, []byte(errSyntax.Error()))
+	}
+
+	streams := args[streamStartIdx:]
+	if len(streams) == 0 || len(streams)%2 != 0 {
+		return HandleError(writer, []byte("ERR Unbalanced 'xreadgroup' list of streams: for each stream key an ID or '>' must be specified."))
+	}
+	streamNames := keyArgs(streams[:len(streams)/2])
+	ids := keyArgs(streams[len(streams)/2:])
+
+	for _, id := range ids {
+		if id == "$" {
+			return HandleError(writer, []byte("ERR The $ ID is meaningless in the context of XREADGROUP: you want to read the history of this consumer by specifying a proper ID, or use the > ID to get new messages. The $ ID would just return an empty result set."))
+		}
+	}
+
+	var entries []store.StreamEntries
+	var err error
+	if blockMs >= 0 {
+		entries, err = st.XReadGroupBlock(group, consumer, streamNames, ids, count, noAck, blockMs, blockMs == 0, heldAOFLock())
+	} else {
+		entries, err = st.XReadGroup(group, consumer, streamNames, ids, count, noAck)
+	}
+	if err != nil {
+		return HandleError(writer, []byte(err.Error()))
+	}
+
+	if len(entries) == 0 {
+		return writer.EncodeNil()
+	}
+	return encodeArray(writer, streamEntriesMessages(st, entries))
+}
+
+/*
+ 	* xreadGroupAofArgv logs XREADGROUP without BLOCK, replaying it at the same point of the file reads the same entries
+	* without waiting for them
+	* @param args []RESP.RESPMessage - the arguments for the command
+	* @param reply *RESP.RESPMessage - the reply the command produced
+	* @return [][]byte - the XREADGROUP command, nil if it read nothing
+*/
+func xreadGroupAofArgv(args []RESP.RESPMessage, reply *RESP.RESPMessage) [][]byte {
+	if reply.RESPType != RESP.Array {
+		return nil
+	}
+
+	argv := [][]byte{[]byte("XREADGROUP")}
+	for _, arg := range withoutBlock(args, 3) {
+		argv = append(argv, arg.RESPValue)
+	}
+	return argv
+}
+
+/*
+ 	* withoutBlock drops the BLOCK option of XREAD or XREADGROUP
+	* @param args []RESP.RESPMessage - the arguments for the command
+	* @param optionsStart int - where its options start, after GROUP group consumer for XREADGROUP
+	* @return []RESP.RESPMessage - the arguments without BLOCK and its value, args itself if there was none
+*/
+func withoutBlock(args []RESP.RESPMessage, optionsStart int) []RESP.RESPMessage {
+	for i := optionsStart; i < len(args); i++ {
+		option := strings.ToUpper(string(args[i].RESPValue))
+		if option == "STREAMS" {
+			break
+		}
+		if option == "BLOCK" && i+1 < len(args) {
+			return append(args[:i:i], args[i+2:]...)
+		}
+		if option == "COUNT" {
+			i++ // skip its value, which could read BLOCK
+		}
+	}
+	return args
+}
+
+/*
+ 	* handleXAck handles the XACK command, XACK key group id [id ...]
+	* @param writer *RESP.Writer - the writer to write the response to
+	* @param args []RESP.RESPMessage - the arguments for the command
+	* @param store *store.Store - the store to get the data from
+	* @param clientID string - the client id
+	* @param txManager *tx.TxManager - the transaction manager
+	* @return error - the error if there is one
+	* @return integer - the number of entries that were pending and are now acknowledged
+*/
+func handleXAck(writer *RESP.Writer, args []RESP.RESPMessage, store *store.Store, clientID string, txManager *tx.TxManager) error {
+	if len(args) < 3 {
+		err := errWrongNumberOfArguments("XACK")
+		return HandleError(writer, []byte(err.Error()))
+	}
+
+	acked, err := store.XAck(string(args[0].RESPValue), string(args[1].RESPValue), keyArgs(args[2:]))
+	if err != nil {
+		return HandleError(writer, []byte(err.Error()))
+	}
+
+	return encodeInteger(writer, int64(acked))
+}
+
+/*
+ 	* handleXPending handles the XPENDING command, XPENDING key group [[IDLE min-idle-time] start end count [consumer]]
+	* @param writer *RESP.Writer - the writer to write the response to
+	* @param args []RESP.RESPMessage - the arguments for the command
+	* @param store *store.Store - the store to get the data from
+	* @param clientID string - the client id
+	* @param txManager *tx.TxManager - the transaction manager
+	* @return error - the error if there is one
+	* @return array - without a range, the number of pending entries, the smallest and greatest pending IDs and how many
+	* entries are pending for each consumer. with a range, the ID, consumer, idle time in milliseconds and delivery count
+	* of each pending entry in it
+*/
+func handleXPending(writer *RESP.Writer, args []RESP.RESPMessage, st *store.Store, clientID string, txManager *tx.TxManager) error {
+	if len(args) < 2 {
+		err := errWrongNumberOfArguments("XPENDING")
+		return HandleError(writer, []byte(err.Error()))
+	}
+
+	streamName := string(args[0].RESPValue)
+	group := string(args[1].RESPValue)
+
+	if len(args) == 2 {
+		summary, err := st.XPending(streamName, group)
+		if err != nil {
+			return HandleError(writer, []byte(err.Error()))
+		}
+
+		if summary.Count == 0 {
+			return encodeArray(writer, []RESP.RESPMessage{
+				integerMessage(0),
+				bulkStringMessage(nil),
+				bulkStringMessage(nil),
+				{RESPType: RESP.Array, RESPLen: -1},
+			})
+		}
+
+		consumers := make([]RESP.RESPMessage, len(summary.Consumers))
+		for i, consumer := range summary.Consumers {
+			consumers[i] = RESP.RESPMessage{
+				RESPType: RESP.Array,
+				RESPLen:  2,
+				RESPArrayElem: []RESP.RESPMessage{
+					bulkStringMessage([]byte(consumer.Name)),
+					bulkStringMessage([]byte(strconv.Itoa(consumer.Count))),
+				},
+			}
+		}
+		return encodeArray(writer, []RESP.RESPMessage{
+			integerMessage(int64(summary.Count)),
+			bulkStringMessage([]byte(summary.MinId)),
+			bulkStringMessage([]byte(summary.MaxId)),
+			{RESPType: RESP.Array, RESPLen: len(consumers), RESPArrayElem: consumers},
+		})
+	}
+
+	rangeArgs := args[2:]
+	var minIdle time.Duration
+	if strings.ToUpper(string(rangeArgs[0].RESPValue)) == "IDLE" && len(rangeArgs) >= 2 {
+		ms, err := strconv.ParseInt(string(rangeArgs[1].RESPValue), 10, 64)
+		if err != nil {
+			return HandleError(writer, []byte("ERR value is not an integer or out of range"))
+		}
+		minIdle = time.Duration(ms) * time.Millisecond
+		rangeArgs = rangeArgs[2:]
+	}
+	if len(rangeArgs) != 3 && len(rangeArgs) != 4 {
+		return HandleError(writer, []byte(errSyntax.Error()))
+	}
+
+	count, err := strconv.ParseInt(string(rangeArgs[2].RESPValue), 10, 64)
+	if err != nil {
+		return HandleError(writer, []byte("ERR value is not an integer or out of range"))
+	}
+	consumer := ""
+	if len(rangeArgs) == 4 {
+		consumer = string(rangeArgs[3].RESPValue)
+	}
+
+	entries, err := st.XPendingRange(streamName, group, string(rangeArgs[0].RESPValue), string(rangeArgs[1].RESPValue), int(max(count, 0)), consumer, minIdle)
+	if err != nil {
+		return HandleError(writer, []byte(err.Error()))
+	}
+
+	now := time.Now()
+	elements := make([]RESP.RESPMessage, len(entries))
+	for i, entry := range entries {
+		elements[i] = RESP.RESPMessage{
+			RESPType: RESP.Array,
+			RESPLen:  4,
+			RESPArrayElem: []RESP.RESPMessage{
+				bulkStringMessage([]byte(entry.Id)),
+				bulkStringMessage([]byte(entry.Consumer)),
+				integerMessage(now.Sub(entry.DeliveryTime).Milliseconds()),
+				integerMessage(entry.DeliveryCount),
+			},
+		}
+	}
+	return encodeArray(writer, elements)
+}
+
+/*
+ 	* handleXClaim handles the XCLAIM command, XCLAIM key group consumer min-idle-time id [id ...] [IDLE ms]
+	* [TIME unix-time-milliseconds] [RETRYCOUNT count] [FORCE] [JUSTID] [LASTID lastid]
+	* @param writer *RESP.Writer - the writer to write the response to
+	* @param args []RESP.RESPMessage - the arguments for the command
+	* @param store *store.Store - the store to get the data from
+	* @param clientID string - the client id
+	* @param txManager *tx.TxManager - the transaction manager
+	* @return error - the error if there is one
+	* @return array - the entries claimed, or just their IDs with JUSTID
+*/
+func handleXClaim(writer *RESP.Writer, args []RESP.RESPMessage, st *store.Store, clientID string, txManager *tx.TxManager) error {
+	if len(args) < 5 {
+		err := errWrongNumberOfArguments("XCLAIM")
+		return HandleError(writer, []byte(err.Error()))
+	}
+
+	minIdle, err := parseMinIdleTime(args[3].RESPValue, "XCLAIM")
+	if err != nil {
+		return HandleError(writer, []byte(err.Error()))
+	}
+	claim := store.StreamClaim{MinIdle: minIdle, RetryCount: -1}
+
+	i := xclaimIdsEnd(args)
+	ids := keyArgs(args[4:i])
+
+	for ; i < len(args); i++ {
+		option := strings.ToUpper(string(args[i].RESPValue))
+		hasValue := i+1 < len(args)
+
+		switch {
+		case option == "FORCE":
+			claim.Force = true
+		case option == "JUSTID":
+			claim.JustId = true
+		case option == "IDLE" && hasValue, option == "TIME" && hasValue, option == "RETRYCOUNT" && hasValue:
+			value, err := strconv.ParseInt(string(args[i+1].RESPValue), 10, 64)
+			if err != nil {
+				return HandleError(writer, []byte(fmt.Sprintf("ERR Invalid %s option argument for XCLAIM", option)))
+			}
+			switch option {
+			case "IDLE":
+				claim.DeliveryTime = time.Now().Add(-time.Duration(value) * time.Millisecond)
+			case "TIME":
+				claim.DeliveryTime = time.UnixMilli(value)
+			case "RETRYCOUNT":
+				claim.RetryCount = value
+			}
+			i++
+		case option == "LASTID" && hasValue:
+			claim.LastId = string(args[i+1].RESPValue)
+			i++
+		default:
+			return HandleError(writer, []byte(fmt.Sprintf("ERR Unrecognized XCLAIM option '%s'", args[i].RESPValue)))
+		}
+	}
+
+	claimed, err := st.XClaim(string(args[0].RESPValue), string(args[1].RESPValue), string(args[2].RESPValue), ids, claim)
+	if err != nil {
+		return HandleError(writer, []byte(err.Error()))
+	}
+
+	if claim.JustId {
+		return encodeArray(writer, streamIdMessages(claimed))
+	}
+	return encodeArray(writer, st.CreateStreamMessages(claimed))
+}
+
+// xclaimIdsEnd returns the position of the first argument of XCLAIM after its IDs
+func xclaimIdsEnd(args []RESP.RESPMessage) int {
+	i := 4
+	for i < len(args) && store.ValidStreamId(string(args[i].RESPValue)) {
+		i++
+	}
+	return i
+}
+
+/*
+ 	* replyStreamIds returns the IDs of the entries in an XCLAIM reply, which holds either entries or, with JUSTID, IDs
+	* @param reply RESP.RESPMessage - the array of entries or IDs
+	* @return [][]byte - the IDs
+*/
+func replyStreamIds(reply RESP.RESPMessage) [][]byte {
+	ids := make([][]byte, 0, len(reply.RESPArrayElem))
+	for _, element := range reply.RESPArrayElem {
+		if element.RESPType == RESP.Array {
+			element = element.RESPArrayElem[0]
+		}
+		ids = append(ids, element.RESPValue)
+	}
+	return ids
+}
+
+/*
+ 	* xclaimAofArgv logs XCLAIM with a min-idle-time of 0 and only the IDs it claimed, so the replay claims the same
+	* entries however long ago they were delivered
+	* @param args []RESP.RESPMessage - the arguments for the command
+	* @param reply *RESP.RESPMessage - the reply the command produced
+	* @return [][]byte - the XCLAIM command, nil if nothing was claimed
+*/
+func xclaimAofArgv(args []RESP.RESPMessage, reply *RESP.RESPMessage) [][]byte {
+	if len(reply.RESPArrayElem) == 0 {
+		return nil
+	}
+
+	argv := [][]byte{[]byte("XCLAIM"), args[0].RESPValue, args[1].RESPValue, args[2].RESPValue, []byte("0")}
+	argv = append(argv, replyStreamIds(*reply)...)
+	for _, arg := range args[xclaimIdsEnd(args):] {
+		argv = append(argv, arg.RESPValue)
+	}
+	return argv
+}
+
+/*
+ 	* handleXAutoClaim handles the XAUTOCLAIM command, XAUTOCLAIM key group consumer min-idle-time start [COUNT count] [JUSTID]
+	* @param writer *RESP.Writer - the writer to write the response to
+	* @param args []RESP.RESPMessage - the arguments for the command
+	* @param store *store.Store - the store to get the data from
+	* @param clientID string - the client id
+	* @param txManager *tx.TxManager - the transaction manager
+	* @return error - the error if there is one
+	* @return array - the ID to start the next call from, 0-0 when done, the entries claimed, or just their IDs with JUSTID,
+	* and the IDs of the pending entries dropped because they were deleted from the stream
+*/
+func handleXAutoClaim(writer *RESP.Writer, args []RESP.RESPMessage, st *store.Store, clientID string, txManager *tx.TxManager) error {
+	if len(args) < 5 {
+		err := errWrongNumberOfArguments("XAUTOCLAIM")
+		return HandleError(writer, []byte(err.Error()))
+	}
+
+	minIdle, err := parseMinIdleTime(args[3].RESPValue, "XAUTOCLAIM")
+	if err != nil {
+		return HandleError(writer, []byte(err.Error()))
+	}
+
+	count := int64(100)
+	justId := false
+	for i := 5; i < len(args); i++ {
+		switch option := strings.ToUpper(string(args[i].RESPValue)); {
+		case option == "COUNT" && i+1 < len(args):
+			count, err = strconv.ParseInt(string(args[i+1].RESPValue), 10, 64)
+			if err != nil || count < 1 || count > math.MaxInt64/10 {
+				return HandleError(writer, []byte("ERR COUNT must be > 0"))
+			}
+			i++
+		case option == "JUSTID":
+			justId = true
+		default:
+			return HandleError(writer, []byte(errSyntax.Error()))
+		}
+	}
+
+	next, claimed, deleted, err := st.XAutoClaim(string(args[0].RESPValue), string(args[1].RESPValue), string(args[2].RESPValue), minIdle, string(args[4].RESPValue), int(count), justId)
+	if err != nil {
+		return HandleError(writer, []byte(err.Error()))
+	}
+
+	entries := streamIdMessages(claimed)
+	if !justId {
+		entries = st.CreateStreamMessages(claimed)
+	}
+	deletedIds := make([]RESP.RESPMessage, len(deleted))
+	for i, id := range deleted {
+		deletedIds[i] = bulkStringMessage([]byte(id))
+	}
+
+	return encodeArray(writer, []RESP.RESPMessage{
+		bulkStringMessage([]byte(next)),
+		{RESPType: RESP.Array, RESPLen: len(entries), RESPArrayElem: entries},
+		{RESPType: RESP.Array, RESPLen: len(deletedIds), RESPArrayElem: deletedIds},
+	})
+}
+
+/*
+ 	* xautoclaimAofArgv logs XAUTOCLAIM as an XCLAIM of the entries it claimed and of the deleted ones it dropped,
+	* which XCLAIM drops too
+	* @param args []RESP.RESPMessage - the arguments for the command
+	* @param reply *RESP.RESPMessage - the reply the command produced
+	* @return [][]byte - the XCLAIM command, nil if nothing was claimed or dropped
+*/
+func xautoclaimAofArgv(args []RESP.RESPMessage, reply *RESP.RESPMessage) [][]byte {
+	ids := append(replyStreamIds(reply.RESPArrayElem[1]), replyStreamIds(reply.RESPArrayElem[2])...)
+	if len(ids) == 0 {
+		return nil
+	}
+
+	argv := [][]byte{[]byte("XCLAIM"), args[0].RESPValue, args[1].RESPValue, args[2].RESPValue, []byte("0")}
+	argv = append(argv, ids...)
+	for _, arg := range args[5:] {
+		if strings.ToUpper(string(arg.RESPValue)) == "JUSTID" {
+			argv = append(argv, []byte("JUSTID"))
+		}
+	}
+	return argv
+}
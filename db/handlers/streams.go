@@ -0,0 +1,453 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	RESP "github.com/manish-singh-bisht/Redis-From-Scratch/db/resp"
+	store "github.com/manish-singh-bisht/Redis-From-Scratch/db/store"
+	tx "github.com/manish-singh-bisht/Redis-From-Scratch/db/transaction"
+)
+
+// the most entries a ~ trim removes without LIMIT, Redis' 100 nodes of stream-node-max-entries
+const streamTrimDefaultLimit = 100 * 100
+
+var (
+	errStreamTrimBothStrategies = errors.New("ERR syntax error, MAXLEN and MINID options at the same time are not compatible")
+	errStreamTrimLimitNotApprox = errors.New("ERR syntax error, LIMIT cannot be used without the special ~ option")
+	errStreamMaxLenNegative     = errors.New("ERR The MAXLEN argument must be >= 0.")
+	errStreamLimitNegative      = errors.New("ERR The LIMIT argument must be >= 0.")
+)
+
+/*
+ 	* parseStreamTrim parses the MAXLEN|MINID [=|~] threshold [LIMIT count] option of XADD and XTRIM
+	* @param args []RESP.RESPMessage - the arguments, starting at MAXLEN or MINID
+	* @return store.StreamTrim - the trim
+	* @return int - the number of arguments the option took
+	* @return error - the error if the option is malformed
+*/
+func parseStreamTrim(args []RESP.RESPMessage) (store.StreamTrim, int, error) {
+	var trim store.StreamTrim
+	strategy := strings.ToUpper(string(args[0].RESPValue))
+
+	i := 1
+	if i < len(args) {
+		switch string(args[i].RESPValue) {
+		case "~":
+			trim.Approx = true
+			i++
+		case "=":
+			i++
+		}
+	}
+	if i >= len(args) {
+		return trim, 0, errSyntax
+	}
+
+	threshold := string(args[i].RESPValue)
+	i++
+	if strategy == "MAXLEN" {
+		maxLen, err := strconv.ParseInt(threshold, 10, 64)
+		if err != nil {
+			return trim, 0, errors.New("ERR value is not an integer or out of range")
+		}
+		if maxLen < 0 {
+			return trim, 0, errStreamMaxLenNegative
+		}
+		trim.MaxLen = maxLen
+	} else {
+		if !store.ValidStreamId(threshold) {
+			return trim, 0, store.ErrInvalidStreamIdArgument
+		}
+		trim.MinId = threshold
+	}
+
+	if trim.Approx {
+		trim.Limit = streamTrimDefaultLimit
+	}
+	if i+1 < len(args) && strings.ToUpper(string(args[i].RESPValue)) == "LIMIT" {
+		limit, err := strconv.ParseInt(string(args[i+1].RESPValue), 10, 64)
+		if err != nil {
+			return trim, 0, errors.New("ERR value is not an integer or out of range")
+		}
+		if limit < 0 {
+			return trim, 0, errStreamLimitNegative
+		}
+		if !trim.Approx {
+			return trim, 0, errStreamTrimLimitNotApprox
+		}
+		trim.Limit = limit
+		i += 2
+	}
+
+	return trim, i, nil
+}
+
+/*
+ 	* parseXAddOptions parses the options XADD takes between the key and the ID
+	* @param args []RESP.RESPMessage - the arguments for the command
+	* @return bool - true if NOMKSTREAM was given
+	* @return *store.StreamTrim - the MAXLEN or MINID trim, nil if there is none
+	* @return int - the index of the ID in args
+	* @return error - the error if an option is malformed
+*/
+func parseXAddOptions(args []RESP.RESPMessage) (bool, *store.StreamTrim, int, error) {
+	noMkStream := false
+	var trim *store.StreamTrim
+
+	i := 1
+	for i < len(args)-1 {
+		option := strings.ToUpper(string(args[i].RESPValue))
+		if option == "NOMKSTREAM" {
+			noMkStream = true
+			i++
+			continue
+		}
+		if option != "MAXLEN" && option != "MINID" {
+			break
+		}
+
+		if trim != nil {
+			return false, nil, 0, errStreamTrimBothStrategies
+		}
+		parsed, n, err := parseStreamTrim(args[i:])
+		if err != nil {
+			return false, nil, 0, err
+		}
+		trim = &parsed
+		i += n
+	}
+
+	return noMkStream, trim, i, nil
+}
+
+/*
+ 	* xaddAofArgv logs XADD with the ID that was actually used, an auto generated stream ID (* or ms-*) would be
+	* generated again on replay
+	* @param args []RESP.RESPMessage - the arguments for the command
+	* @param reply *RESP.RESPMessage - the reply the command produced
+	* @return [][]byte - the XADD command, nil if NOMKSTREAM left it without a stream to add to
+*/
+func xaddAofArgv(args []RESP.RESPMessage, reply *RESP.RESPMessage) [][]byte {
+	if reply.RESPValue == nil {
+		return nil
+	}
+
+	_, _, idIdx, _ := parseXAddOptions(args)
+
+	argv := make([][]byte, 0, len(args)+1)
+	argv = append(argv, []byte("XADD"))
+	for i, arg := range args {
+		if i == idIdx {
+			argv = append(argv, reply.RESPValue)
+			continue
+		}
+		argv = append(argv, arg.RESPValue)
+	}
+	return argv
+}
+
+/*
+ 	* parseXRangeCount parses the [COUNT count] of XRANGE and XREVRANGE
+	* @param args []RESP.RESPMessage - the arguments after the bounds
+	* @return int - the count, -1 without COUNT and 0 for a count of 0 or less, which gets a nil reply
+	* @return error - the error if the option is malformed
+*/
+func parseXRangeCount(args []RESP.RESPMessage) (int, error) {
+	count := -1
+	for i := 0; i < len(args); i++ {
+		if strings.ToUpper(string(args[i].RESPValue)) != "COUNT" || i+1 >= len(args) {
+			return 0, errSyntax
+		}
+		n, err := strconv.ParseInt(string(args[i+1].RESPValue), 10, 64)
+		if err != nil {
+			return 0, errors.New("ERR value is not an integer or out of range")
+		}
+		count = int(max(n, 0))
+		i++
+	}
+	return count, nil
+}
+
+/*
+ 	* handleXLen handles the XLEN command, XLEN key
+	* @param writer *RESP.Writer - the writer to write the response to
+	* @param args []RESP.RESPMessage - the arguments for the command
+	* @param store *store.Store - the store to get the data from
+	* @param clientID string - the client id
+	* @param txManager *tx.TxManager - the transaction manager
+	* @return error - the error if there is one
+	* @return integer - the number of entries in the stream, 0 if it doesn't exist
+*/
+func handleXLen(writer *RESP.Writer, args []RESP.RESPMessage, store *store.Store, clientID string, txManager *tx.TxManager) error {
+	if len(args) != 1 {
+		err := errWrongNumberOfArguments("XLEN")
+		return HandleError(writer, []byte(err.Error()))
+	}
+
+	length, err := store.XLen(string(args[0].RESPValue))
+	if err != nil {
+		return HandleError(writer, []byte(err.Error()))
+	}
+
+	return encodeInteger(writer, int64(length))
+}
+
+/*
+ 	* handleXDel handles the XDEL command, XDEL key id [id ...]
+	* the stream is kept even once its last entry is deleted, and the entries stay pending in the consumer groups
+	* @param writer *RESP.Writer - the writer to write the response to
+	* @param args []RESP.RESPMessage - the arguments for the command
+	* @param store *store.Store - the store to get the data from
+	* @param clientID string - the client id
+	* @param txManager *tx.TxManager - the transaction manager
+	* @return error - the error if there is one
+	* @return integer - the number of entries deleted
+*/
+func handleXDel(writer *RESP.Writer, args []RESP.RESPMessage, store *store.Store, clientID string, txManager *tx.TxManager) error {
+	if len(args) < 2 {
+		err := errWrongNumberOfArguments("XDEL")
+		return HandleError(writer, []byte(err.Error()))
+	}
+
+	key := string(args[0].RESPValue)
+	deleted, err := store.XDel(key, keyArgs(args[1:]))
+	if err != nil {
+		return HandleError(writer, []byte(err.Error()))
+	}
+
+	if deleted > 0 {
+		signalModifiedKey(txManager, key)
+	}
+	return encodeInteger(writer, int64(deleted))
+}
+
+/*
+ 	* handleXTrim handles the XTRIM command, XTRIM key MAXLEN|MINID [=|~] threshold [LIMIT count]
+	* MAXLEN keeps the newest threshold entries, MINID drops the entries with an ID smaller than threshold,
+	* with ~ at most LIMIT entries are removed, 10000 by default and 0 for no limit
+	* @param writer *RESP.Writer - the writer to write the response to
+	* @param args []RESP.RESPMessage - the arguments for the command
+	* @param store *store.Store - the store to get the data from
+	* @param clientID string - the client id
+	* @param txManager *tx.TxManager - the transaction manager
+	* @return error - the error if there is one
+	* @return integer - the number of entries removed
+*/
+func handleXTrim(writer *RESP.Writer, args []RESP.RESPMessage, store *store.Store, clientID string, txManager *tx.TxManager) error {
+	if len(args) < 3 {
+		err := errWrongNumberOfArguments("XTRIM")
+		return HandleError(writer, []byte(err.Error()))
+	}
+
+	strategy := strings.ToUpper(string(args[1].RESPValue))
+	if strategy != "MAXLEN" && strategy != "MINID" {
+		return HandleError(writer, []byte(errSyntax.Error()))
+	}
+
+	trim, n, err := parseStreamTrim(args[1:])
+	if err != nil {
+		return HandleError(writer, []byte(err.Error()))
+	}
+	if 1+n != len(args) {
+		next := strings.ToUpper(string(args[1+n].RESPValue))
+		if next == "MAXLEN" || next == "MINID" {
+			return HandleError(writer, []byte(errStreamTrimBothStrategies.Error()))
+		}
+		return HandleError(writer, []byte(errSyntax.Error()))
+	}
+
+	key := string(args[0].RESPValue)
+	removed, err := store.XTrim(key, trim)
+	if err != nil {
+		return HandleError(writer, []byte(err.Error()))
+	}
+
+	if removed > 0 {
+		signalModifiedKey(txManager, key)
+	}
+	return encodeInteger(writer, int64(removed))
+}
+
+/*
+ 	* handleXInfo handles the XINFO command, which describes a stream, its consumer groups and their consumers
+	* XINFO STREAM key [FULL [COUNT count]]
+	* XINFO GROUPS key
+	* XINFO CONSUMERS key group
+	* @param writer *RESP.Writer - the writer to write the response to
+	* @param args []RESP.RESPMessage - the arguments for the command
+	* @param store *store.Store - the store to get the data from
+	* @param clientID string - the client id
+	* @param txManager *tx.TxManager - the transaction manager
+	* @return error - the error if there is one
+	* @return array - field names followed by their values, an array of them for each group or consumer
+*/
+func handleXInfo(writer *RESP.Writer, args []RESP.RESPMessage, st *store.Store, clientID string, txManager *tx.TxManager) error {
+	if len(args) < 1 {
+		err := errWrongNumberOfArguments("XINFO")
+		return HandleError(writer, []byte(err.Error()))
+	}
+
+	subcommand := strings.ToUpper(string(args[0].RESPValue))
+
+	switch {
+	case subcommand == "STREAM" && len(args) >= 2:
+		full := false
+		count := 10
+		options := args[2:]
+		if len(options) > 0 {
+			if strings.ToUpper(string(options[0].RESPValue)) != "FULL" {
+				return HandleError(writer, []byte(errSyntax.Error()))
+			}
+			full = true
+			options = options[1:]
+		}
+		if len(options) > 0 {
+			if len(options) != 2 || strings.ToUpper(string(options[0].RESPValue)) != "COUNT" {
+				return HandleError(writer, []byte(errSyntax.Error()))
+			}
+			n, err := strconv.ParseInt(string(options[1].RESPValue), 10, 64)
+			if err != nil {
+				return HandleError(writer, []byte("ERR value is not an integer or out of range"))
+			}
+			if n >= 0 {
+				count = int(n)
+			}
+		}
+
+		info, err := st.XInfoStream(string(args[1].RESPValue), full, count)
+		if err != nil {
+			return HandleError(writer, []byte(err.Error()))
+		}
+		return encodeArray(writer, streamInfoMessages(st, info, full, count))
+
+	case subcommand == "GROUPS" && len(args) == 2:
+		groups, err := st.XInfoGroups(string(args[1].RESPValue))
+		if err != nil {
+			return HandleError(writer, []byte(err.Error()))
+		}
+
+		elements := make([]RESP.RESPMessage, len(groups))
+		for i, group := range groups {
+			elements[i] = arrayMessage([]RESP.RESPMessage{
+				bulkStringMessage([]byte("name")), bulkStringMessage([]byte(group.Name)),
+				bulkStringMessage([]byte("consumers")), integerMessage(int64(len(group.Consumers))),
+				bulkStringMessage([]byte("pending")), integerMessage(int64(len(group.Pending))),
+				bulkStringMessage([]byte("last-delivered-id")), bulkStringMessage([]byte(group.LastId)),
+			})
+		}
+		return encodeArray(writer, elements)
+
+	case subcommand == "CONSUMERS" && len(args) == 3:
+		consumers, err := st.XInfoConsumers(string(args[1].RESPValue), string(args[2].RESPValue))
+		if err != nil {
+			return HandleError(writer, []byte(err.Error()))
+		}
+
+		now := time.Now()
+		elements := make([]RESP.RESPMessage, len(consumers))
+		for i, consumer := range consumers {
+			inactive := int64(-1)
+			if !consumer.ActiveTime.IsZero() {
+				inactive = now.Sub(consumer.ActiveTime).Milliseconds()
+			}
+			elements[i] = arrayMessage([]RESP.RESPMessage{
+				bulkStringMessage([]byte("name")), bulkStringMessage([]byte(consumer.Name)),
+				bulkStringMessage([]byte("pending")), integerMessage(int64(consumer.Pending)),
+				bulkStringMessage([]byte("idle")), integerMessage(now.Sub(consumer.SeenTime).Milliseconds()),
+				bulkStringMessage([]byte("inactive")), integerMessage(inactive),
+			})
+		}
+		return encodeArray(writer, elements)
+
+	case subcommand == "STREAM" || subcommand == "GROUPS" || subcommand == "CONSUMERS":
+		return HandleError(writer, []byte(fmt.Sprintf("ERR wrong number of arguments for 'xinfo|%s' command", strings.ToLower(subcommand))))
+	}
+
+	return HandleError(writer, []byte(fmt.Sprintf("ERR unknown subcommand '%s'. Try XINFO STREAM, GROUPS or CONSUMERS.", args[0].RESPValue)))
+}
+
+/*
+ 	* streamInfoMessages builds the reply of XINFO STREAM
+	* @param st *store.Store - the store, to build the entries
+	* @param info store.StreamInfo - what the store reported
+	* @param full bool - the FULL form, with the entries and the groups in detail
+	* @param count int - with full, the most pending entries to list for a group or a consumer, 0 for all of them
+	* @return []RESP.RESPMessage - the field names followed by their values
+*/
+func streamInfoMessages(st *store.Store, info store.StreamInfo, full bool, count int) []RESP.RESPMessage {
+	messages := []RESP.RESPMessage{
+		bulkStringMessage([]byte("length")), integerMessage(int64(info.Length)),
+		bulkStringMessage([]byte("radix-tree-keys")), integerMessage(int64(info.IndexKeys)),
+		bulkStringMessage([]byte("radix-tree-nodes")), integerMessage(int64(info.IndexNodes)),
+		bulkStringMessage([]byte("last-generated-id")), bulkStringMessage([]byte(info.LastId)),
+	}
+
+	if !full {
+		entryMessage := func(record *store.StreamRecord) RESP.RESPMessage {
+			if record == nil {
+				return bulkStringMessage(nil)
+			}
+			return st.CreateStreamMessages([]store.StreamRecord{*record})[0]
+		}
+		return append(messages,
+			bulkStringMessage([]byte("groups")), integerMessage(int64(len(info.Groups))),
+			bulkStringMessage([]byte("first-entry")), entryMessage(info.First),
+			bulkStringMessage([]byte("last-entry")), entryMessage(info.Last),
+		)
+	}
+
+	groups := make([]RESP.RESPMessage, len(info.Groups))
+	for i, group := range info.Groups {
+		var pending []RESP.RESPMessage
+		consumerPending := make(map[string][]RESP.RESPMessage)
+		for _, entry := range group.Pending {
+			if count == 0 || len(pending) < count {
+				pending = append(pending, arrayMessage([]RESP.RESPMessage{
+					bulkStringMessage([]byte(entry.Id)),
+					bulkStringMessage([]byte(entry.Consumer)),
+					integerMessage(entry.DeliveryTime.UnixMilli()),
+					integerMessage(entry.DeliveryCount),
+				}))
+			}
+			if count == 0 || len(consumerPending[entry.Consumer]) < count {
+				consumerPending[entry.Consumer] = append(consumerPending[entry.Consumer], arrayMessage([]RESP.RESPMessage{
+					bulkStringMessage([]byte(entry.Id)),
+					integerMessage(entry.DeliveryTime.UnixMilli()),
+					integerMessage(entry.DeliveryCount),
+				}))
+			}
+		}
+
+		consumers := make([]RESP.RESPMessage, len(group.Consumers))
+		for j, consumer := range group.Consumers {
+			activeTime := int64(-1)
+			if !consumer.ActiveTime.IsZero() {
+				activeTime = consumer.ActiveTime.UnixMilli()
+			}
+			consumers[j] = arrayMessage([]RESP.RESPMessage{
+				bulkStringMessage([]byte("name")), bulkStringMessage([]byte(consumer.Name)),
+				bulkStringMessage([]byte("seen-time")), integerMessage(consumer.SeenTime.UnixMilli()),
+				bulkStringMessage([]byte("active-time")), integerMessage(activeTime),
+				bulkStringMessage([]byte("pel-count")), integerMessage(int64(consumer.Pending)),
+				bulkStringMessage([]byte("pending")), arrayMessage(consumerPending[consumer.Name]),
+			})
+		}
+
+		groups[i] = arrayMessage([]RESP.RESPMessage{
+			bulkStringMessage([]byte("name")), bulkStringMessage([]byte(group.Name)),
+			bulkStringMessage([]byte("last-delivered-id")), bulkStringMessage([]byte(group.LastId)),
+			bulkStringMessage([]byte("pel-count")), integerMessage(int64(len(group.Pending))),
+			bulkStringMessage([]byte("pending")), arrayMessage(pending),
+			bulkStringMessage([]byte("consumers")), arrayMessage(consumers),
+		})
+	}
+
+	return append(messages,
+		bulkStringMessage([]byte("entries")), arrayMessage(st.CreateStreamMessages(info.Entries)),
+		bulkStringMessage([]byte("groups")), arrayMessage(groups),
+	)
+}
@@ -0,0 +1,608 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	RESP "github.com/manish-singh-bisht/Redis-From-Scratch/db/resp"
+	store "github.com/manish-singh-bisht/Redis-From-Scratch/db/store"
+	tx "github.com/manish-singh-bisht/Redis-From-Scratch/db/transaction"
+)
+
+/*
+ 	* parseExpireOption parses the time given to the EX, PX, EXAT or PXAT option of SET and GETEX
+	* @param option string - the option, in uppercase
+	* @param value []byte - the time
+	* @param cmd string - the name of the command, for errors
+	* @return time.Time - when the key expires
+	* @return error - the error if the time is not a positive integer or is too far away
+*/
+func parseExpireOption(option string, value []byte, cmd string) (time.Time, error) {
+	n, err := strconv.ParseInt(string(value), 10, 64)
+	if err != nil {
+		return time.Time{}, errors.New("ERR value is not an integer or out of range")
+	}
+
+	errInvalid := fmt.Errorf("ERR invalid expire time in '%s' command", strings.ToLower(cmd))
+	if n <= 0 {
+		return time.Time{}, errInvalid
+	}
+
+	unit := time.Millisecond
+	if option == "EX" || option == "EXAT" {
+		unit = time.Second
+	}
+
+	ms, ok := expireTimeMs(n, unit, option == "EXAT" || option == "PXAT")
+	if !ok {
+		return time.Time{}, errInvalid
+	}
+	return time.UnixMilli(ms), nil
+}
+
+/*
+ 	* expireOptionAofArgs rewrites an EX, PX or EXAT option into PXAT, a relative time would start counting again
+	* when the file is replayed
+	* @param option string - the option, in uppercase
+	* @param value []byte - the time
+	* @return [][]byte - PXAT and the unix time in milliseconds
+*/
+func expireOptionAofArgs(option string, value []byte) [][]byte {
+	n, _ := strconv.ParseInt(string(value), 10, 64)
+
+	unit := time.Millisecond
+	if option == "EX" || option == "EXAT" {
+		unit = time.Second
+	}
+	ms, _ := expireTimeMs(n, unit, option == "EXAT" || option == "PXAT")
+
+	return [][]byte{[]byte("PXAT"), []byte(strconv.FormatInt(ms, 10))}
+}
+
+/*
+ 	* setAofArgv logs SET with its expiration as PXAT
+	* @param args []RESP.RESPMessage - the arguments for the command
+	* @return [][]byte - the SET command
+*/
+func setAofArgv(args []RESP.RESPMessage) [][]byte {
+	argv := [][]byte{[]byte("SET"), args[0].RESPValue, args[1].RESPValue}
+	for i := 2; i < len(args); i++ {
+		option := strings.ToUpper(string(args[i].RESPValue))
+		switch option {
+		case "EX", "PX", "EXAT", "PXAT":
+			argv = append(argv, expireOptionAofArgs(option, args[i+1].RESPValue)...)
+			i++
+		default:
+			argv = append(argv, args[i].RESPValue)
+		}
+	}
+	return argv
+}
+
+/*
+ 	* getExAofArgv logs GETEX as the PEXPIREAT or PERSIST it amounts to
+	* @param args []RESP.RESPMessage - the arguments for the command
+	* @param reply *RESP.RESPMessage - the reply the command produced
+	* @return [][]byte - the command, nil if the key doesn't exist or its expiration was left alone
+*/
+func getExAofArgv(args []RESP.RESPMessage, reply *RESP.RESPMessage) [][]byte {
+	if reply.RESPValue == nil || len(args) < 2 {
+		return nil
+	}
+
+	option := strings.ToUpper(string(args[1].RESPValue))
+	if option == "PERSIST" {
+		return [][]byte{[]byte("PERSIST"), args[0].RESPValue}
+	}
+
+	at := expireOptionAofArgs(option, args[2].RESPValue)[1]
+	return [][]byte{[]byte("PEXPIREAT"), args[0].RESPValue, at}
+}
+
+/*
+ 	* getDelAofArgv logs GETDEL as DEL
+	* @param args []RESP.RESPMessage - the arguments for the command
+	* @param reply *RESP.RESPMessage - the reply the command produced
+	* @return [][]byte - the DEL command, nil if the key didn't exist
+*/
+func getDelAofArgv(args []RESP.RESPMessage, reply *RESP.RESPMessage) [][]byte {
+	if reply.RESPValue == nil {
+		return nil
+	}
+	return [][]byte{[]byte("DEL"), args[0].RESPValue}
+}
+
+/*
+ 	* encodeBulkString writes a bulk string reply, a nil value is written as a nil reply
+	* @param writer *RESP.Writer - the writer to write to
+	* @param value []byte - the value
+	* @return error - the error if there is one
+*/
+func encodeBulkString(writer *RESP.Writer, value []byte) error {
+	if value == nil {
+		return writer.EncodeNil()
+	}
+	message := bulkStringMessage(value)
+	return writer.Encode(&message)
+}
+
+/*
+ 	* handleGetSet handles the GETSET command, GETSET key value, SET with GET
+	* @param writer *RESP.Writer - the writer to write the response to
+	* @param args []RESP.RESPMessage - the arguments for the command
+	* @param store *store.Store - the store to get the data from
+	* @param clientID string - the client id
+	* @param txManager *tx.TxManager - the transaction manager
+	* @return error - the error if there is one
+	* @return bulk string - the old value, nil if the key didn't exist
+*/
+func handleGetSet(writer *RESP.Writer, args []RESP.RESPMessage, st *store.Store, clientID string, txManager *tx.TxManager) error {
+	if len(args) != 2 {
+		err := errWrongNumberOfArguments("GETSET")
+		return HandleError(writer, []byte(err.Error()))
+	}
+
+	key := string(args[0].RESPValue)
+
+	old, _, _, err := st.SetWithOptions(key, args[1].RESPValue, store.SetOptions{Get: true})
+	if err != nil {
+		return HandleError(writer, []byte(err.Error()))
+	}
+
+	signalModifiedKey(txManager, key)
+
+	return encodeBulkString(writer, old)
+}
+
+/*
+ 	* handleGetDel handles the GETDEL command, GETDEL key
+	* @param writer *RESP.Writer - the writer to write the response to
+	* @param args []RESP.RESPMessage - the arguments for the command
+	* @param store *store.Store - the store to get the data from
+	* @param clientID string - the client id
+	* @param txManager *tx.TxManager - the transaction manager
+	* @return error - the error if there is one
+	* @return bulk string - the value the key had, nil if it didn't exist
+*/
+func handleGetDel(writer *RESP.Writer, args []RESP.RESPMessage, store *store.Store, clientID string, txManager *tx.TxManager) error {
+	if len(args) != 1 {
+		err := errWrongNumberOfArguments("GETDEL")
+		return HandleError(writer, []byte(err.Error()))
+	}
+
+	key := string(args[0].RESPValue)
+
+	value, exists, err := store.GetDel(key)
+	if err != nil {
+		return HandleError(writer, []byte(err.Error()))
+	}
+	if !exists {
+		return writer.EncodeNil()
+	}
+
+	signalModifiedKey(txManager, key)
+
+	return encodeBulkString(writer, value)
+}
+
+/*
+ 	* handleGetEx handles the GETEX command, GETEX key [EX seconds | PX milliseconds | EXAT unix-time-seconds | PXAT unix-time-milliseconds | PERSIST]
+	* @param writer *RESP.Writer - the writer to write the response to
+	* @param args []RESP.RESPMessage - the arguments for the command
+	* @param store *store.Store - the store to get the data from
+	* @param clientID string - the client id
+	* @param txManager *tx.TxManager - the transaction manager
+	* @return error - the error if there is one
+	* @return bulk string - the value, nil if the key doesn't exist
+*/
+func handleGetEx(writer *RESP.Writer, args []RESP.RESPMessage, store *store.Store, clientID string, txManager *tx.TxManager) error {
+	if len(args) < 1 {
+		err := errWrongNumberOfArguments("GETEX")
+		return HandleError(writer, []byte(err.Error()))
+	}
+
+	key := string(args[0].RESPValue)
+
+	var expireAt time.Time
+	persist := false
+
+	switch {
+	case len(args) == 1:
+	case len(args) == 2 && strings.ToUpper(string(args[1].RESPValue)) == "PERSIST":
+		persist = true
+	case len(args) == 3:
+		option := strings.ToUpper(string(args[1].RESPValue))
+		if option != "EX" && option != "PX" && option != "EXAT" && option != "PXAT" {
+			return HandleError(writer, []byte(errSyntax.Error()))
+		}
+		var err error
+		expireAt, err = parseExpireOption(option, args[2].RESPValue, "GETEX")
+		if err != nil {
+			return HandleError(writer, []byte(err.Error()))
+		}
+	default:
+		return HandleError(writer, []byte(errSyntax.Error()))
+	}
+
+	value, exists, err := store.GetEx(key, expireAt, persist)
+	if err != nil {
+		return HandleError(writer, []byte(err.Error()))
+	}
+	if !exists {
+		return writer.EncodeNil()
+	}
+
+	if persist || !expireAt.IsZero() {
+		signalModifiedKey(txManager, key)
+	}
+
+	return encodeBulkString(writer, value)
+}
+
+/*
+ 	* handleAppend handles the APPEND command, APPEND key value
+	* @param writer *RESP.Writer - the writer to write the response to
+	* @param args []RESP.RESPMessage - the arguments for the command
+	* @param store *store.Store - the store to get the data from
+	* @param clientID string - the client id
+	* @param txManager *tx.TxManager - the transaction manager
+	* @return error - the error if there is one
+	* @return integer - the length of the string afterwards
+*/
+func handleAppend(writer *RESP.Writer, args []RESP.RESPMessage, store *store.Store, clientID string, txManager *tx.TxManager) error {
+	if len(args) != 2 {
+		err := errWrongNumberOfArguments("APPEND")
+		return HandleError(writer, []byte(err.Error()))
+	}
+
+	key := string(args[0].RESPValue)
+
+	length, err := store.Append(key, args[1].RESPValue)
+	if err != nil {
+		return HandleError(writer, []byte(err.Error()))
+	}
+
+	signalModifiedKey(txManager, key)
+
+	return encodeInteger(writer, int64(length))
+}
+
+/*
+ 	* handleStrLen handles the STRLEN command, STRLEN key
+	* @param writer *RESP.Writer - the writer to write the response to
+	* @param args []RESP.RESPMessage - the arguments for the command
+	* @param store *store.Store - the store to get the data from
+	* @param clientID string - the client id
+	* @param txManager *tx.TxManager - the transaction manager
+	* @return error - the error if there is one
+	* @return integer - the length of the string, 0 if the key doesn't exist
+*/
+func handleStrLen(writer *RESP.Writer, args []RESP.RESPMessage, store *store.Store, clientID string, txManager *tx.TxManager) error {
+	if len(args) != 1 {
+		err := errWrongNumberOfArguments("STRLEN")
+		return HandleError(writer, []byte(err.Error()))
+	}
+
+	length, err := store.StrLen(string(args[0].RESPValue))
+	if err != nil {
+		return HandleError(writer, []byte(err.Error()))
+	}
+
+	return encodeInteger(writer, int64(length))
+}
+
+/*
+ 	* handleGetRange handles the GETRANGE command, GETRANGE key start end
+	* @param writer *RESP.Writer - the writer to write the response to
+	* @param args []RESP.RESPMessage - the arguments for the command
+	* @param store *store.Store - the store to get the data from
+	* @param clientID string - the client id
+	* @param txManager *tx.TxManager - the transaction manager
+	* @return error - the error if there is one
+	* @return bulk string - the bytes from start to end, both inclusive, negative offsets count from the end
+*/
+func handleGetRange(writer *RESP.Writer, args []RESP.RESPMessage, store *store.Store, clientID string, txManager *tx.TxManager) error {
+	if len(args) != 3 {
+		err := errWrongNumberOfArguments("GETRANGE")
+		return HandleError(writer, []byte(err.Error()))
+	}
+
+	start, err1 := strconv.Atoi(string(args[1].RESPValue))
+	end, err2 := strconv.Atoi(string(args[2].RESPValue))
+	if err1 != nil || err2 != nil {
+		return HandleError(writer, []byte("ERR value is not an integer or out of range"))
+	}
+
+	value, err := store.GetRange(string(args[0].RESPValue), start, end)
+	if err != nil {
+		return HandleError(writer, []byte(err.Error()))
+	}
+
+	return encodeBulkString(writer, value)
+}
+
+/*
+ 	* handleSetRange handles the SETRANGE command, SETRANGE key offset value
+	* @param writer *RESP.Writer - the writer to write the response to
+	* @param args []RESP.RESPMessage - the arguments for the command
+	* @param store *store.Store - the store to get the data from
+	* @param clientID string - the client id
+	* @param txManager *tx.TxManager - the transaction manager
+	* @return error - the error if there is one
+	* @return integer - the length of the string afterwards
+*/
+func handleSetRange(writer *RESP.Writer, args []RESP.RESPMessage, store *store.Store, clientID string, txManager *tx.TxManager) error {
+	if len(args) != 3 {
+		err := errWrongNumberOfArguments("SETRANGE")
+		return HandleError(writer, []byte(err.Error()))
+	}
+
+	key := string(args[0].RESPValue)
+
+	offset, err := strconv.Atoi(string(args[1].RESPValue))
+	if err != nil {
+		return HandleError(writer, []byte("ERR value is not an integer or out of range"))
+	}
+	if offset < 0 {
+		return HandleError(writer, []byte("ERR offset is out of range"))
+	}
+
+	length, err := store.SetRange(key, offset, args[2].RESPValue)
+	if err != nil {
+		return HandleError(writer, []byte(err.Error()))
+	}
+
+	if len(args[2].RESPValue) > 0 {
+		signalModifiedKey(txManager, key)
+	}
+
+	return encodeInteger(writer, int64(length))
+}
+
+/*
+ 	* handleMGet handles the MGET command, MGET key [key ...]
+	* @param writer *RESP.Writer - the writer to write the response to
+	* @param args []RESP.RESPMessage - the arguments for the command
+	* @param store *store.Store - the store to get the data from
+	* @param clientID string - the client id
+	* @param txManager *tx.TxManager - the transaction manager
+	* @return error - the error if there is one
+	* @return array - the value of each key, nil for keys that don't exist or don't hold a string
+*/
+func handleMGet(writer *RESP.Writer, args []RESP.RESPMessage, store *store.Store, clientID string, txManager *tx.TxManager) error {
+	if len(args) < 1 {
+		err := errWrongNumberOfArguments("MGET")
+		return HandleError(writer, []byte(err.Error()))
+	}
+
+	return encodeArray(writer, bulkStringMessages(store.MGet(keyArgs(args))))
+}
+
+/*
+ 	* handleMSet handles the MSET command, MSET key value [key value ...], all the keys are set at once
+	* @param writer *RESP.Writer - the writer to write the response to
+	* @param args []RESP.RESPMessage - the arguments for the command
+	* @param store *store.Store - the store to get the data from
+	* @param clientID string - the client id
+	* @param txManager *tx.TxManager - the transaction manager
+	* @return error - the error if there is one
+	* @return simple string - OK
+*/
+func handleMSet(writer *RESP.Writer, args []RESP.RESPMessage, store *store.Store, clientID string, txManager *tx.TxManager) error {
+	if len(args) < 2 || len(args)%2 != 0 {
+		err := errWrongNumberOfArguments("MSET")
+		return HandleError(writer, []byte(err.Error()))
+	}
+
+	keys, values := keyValuePairs(args)
+	store.MSet(keys, values)
+
+	for _, key := range keys {
+		signalModifiedKey(txManager, key)
+	}
+
+	return encodeOK(writer)
+}
+
+/*
+ 	* handleMSetNX handles the MSETNX command, MSETNX key value [key value ...], the keys are set only if none of them exists
+	* @param writer *RESP.Writer - the writer to write the response to
+	* @param args []RESP.RESPMessage - the arguments for the command
+	* @param store *store.Store - the store to get the data from
+	* @param clientID string - the client id
+	* @param txManager *tx.TxManager - the transaction manager
+	* @return error - the error if there is one
+	* @return integer - 1 if the keys were set, 0 if any of them exists
+*/
+func handleMSetNX(writer *RESP.Writer, args []RESP.RESPMessage, store *store.Store, clientID string, txManager *tx.TxManager) error {
+	if len(args) < 2 || len(args)%2 != 0 {
+		err := errWrongNumberOfArguments("MSETNX")
+		return HandleError(writer, []byte(err.Error()))
+	}
+
+	keys, values := keyValuePairs(args)
+	if !store.MSetNX(keys, values) {
+		return encodeInteger(writer, 0)
+	}
+
+	for _, key := range keys {
+		signalModifiedKey(txManager, key)
+	}
+
+	return encodeInteger(writer, 1)
+}
+
+/*
+ 	* keyValuePairs splits the key value pairs of MSET and MSETNX
+	* @param args []RESP.RESPMessage - the arguments, keys and values alternating
+	* @return []string - the keys
+	* @return [][]byte - the values
+*/
+func keyValuePairs(args []RESP.RESPMessage) ([]string, [][]byte) {
+	keys := make([]string, 0, len(args)/2)
+	values := make([][]byte, 0, len(args)/2)
+	for i := 0; i+1 < len(args); i += 2 {
+		keys = append(keys, string(args[i].RESPValue))
+		values = append(values, args[i+1].RESPValue)
+	}
+	return keys, values
+}
+
+/*
+ 	* incrByFloatAofArgv rewrites INCRBYFLOAT as a SET of the value it produced with KEEPTTL, so replaying the log doesn't
+	* depend on the float arithmetic giving the same result again
+	* @param args []RESP.RESPMessage - the arguments of INCRBYFLOAT
+	* @param reply *RESP.RESPMessage - the reply, the new value
+	* @return [][]byte - the command to append
+*/
+func incrByFloatAofArgv(args []RESP.RESPMessage, reply *RESP.RESPMessage) [][]byte {
+	return [][]byte{[]byte("SET"), args[0].RESPValue, reply.RESPValue, []byte("KEEPTTL")}
+}
+
+/*
+ 	* incrByGeneric adds delta to the integer value of a key and replies with the new value, for INCR, INCRBY, DECR and DECRBY
+	* @param writer *RESP.Writer - the writer to write the response to
+	* @param st *store.Store - the store
+	* @param txManager *tx.TxManager - the transaction manager
+	* @param key string - the key
+	* @param delta int64 - how much to add
+	* @return error - the error if there is one
+*/
+func incrByGeneric(writer *RESP.Writer, st *store.Store, txManager *tx.TxManager, key string, delta int64) error {
+	value, err := st.IncrBy(key, delta)
+	if err != nil {
+		return HandleError(writer, []byte(err.Error()))
+	}
+
+	signalModifiedKey(txManager, key)
+
+	return encodeInteger(writer, value)
+}
+
+/*
+ 	* handleIncr handles the INCR command, INCR key, a missing key counts as 0 and the expiration is kept
+	* @param writer *RESP.Writer - the writer to write the response to
+	* @param args []RESP.RESPMessage - the arguments for the command
+	* @param store *store.Store - the store to get the data from
+	* @param clientID string - the client id
+	* @param txManager *tx.TxManager - the transaction manager
+	* @return error - the error if there is one
+	* @return integer - the value after the increment
+*/
+func handleIncr(writer *RESP.Writer, args []RESP.RESPMessage, store *store.Store, clientID string, txManager *tx.TxManager) error {
+	if len(args) != 1 {
+		err := errWrongNumberOfArguments("INCR")
+		return HandleError(writer, []byte(err.Error()))
+	}
+
+	return incrByGeneric(writer, store, txManager, string(args[0].RESPValue), 1)
+}
+
+/*
+ 	* handleIncrBy handles the INCRBY command, INCRBY key increment
+	* @param writer *RESP.Writer - the writer to write the response to
+	* @param args []RESP.RESPMessage - the arguments for the command
+	* @param store *store.Store - the store to get the data from
+	* @param clientID string - the client id
+	* @param txManager *tx.TxManager - the transaction manager
+	* @return error - the error if there is one
+	* @return integer - the value after the increment
+*/
+func handleIncrBy(writer *RESP.Writer, args []RESP.RESPMessage, store *store.Store, clientID string, txManager *tx.TxManager) error {
+	if len(args) != 2 {
+		err := errWrongNumberOfArguments("INCRBY")
+		return HandleError(writer, []byte(err.Error()))
+	}
+
+	delta, err := strconv.ParseInt(string(args[1].RESPValue), 10, 64)
+	if err != nil {
+		return HandleError(writer, []byte("ERR value is not an integer or out of range"))
+	}
+
+	return incrByGeneric(writer, store, txManager, string(args[0].RESPValue), delta)
+}
+
+/*
+ 	* handleDecr handles the DECR command, DECR key
+	* @param writer *RESP.Writer - the writer to write the response to
+	* @param args []RESP.RESPMessage - the arguments for the command
+	* @param store *store.Store - the store to get the data from
+	* @param clientID string - the client id
+	* @param txManager *tx.TxManager - the transaction manager
+	* @return error - the error if there is one
+	* @return integer - the value after the decrement
+*/
+func handleDecr(writer *RESP.Writer, args []RESP.RESPMessage, store *store.Store, clientID string, txManager *tx.TxManager) error {
+	if len(args) != 1 {
+		err := errWrongNumberOfArguments("DECR")
+		return HandleError(writer, []byte(err.Error()))
+	}
+
+	return incrByGeneric(writer, store, txManager, string(args[0].RESPValue), -1)
+}
+
+/*
+ 	* handleDecrBy handles the DECRBY command, DECRBY key decrement
+	* @param writer *RESP.Writer - the writer to write the response to
+	* @param args []RESP.RESPMessage - the arguments for the command
+	* @param store *store.Store - the store to get the data from
+	* @param clientID string - the client id
+	* @param txManager *tx.TxManager - the transaction manager
+	* @return error - the error if there is one
+	* @return integer - the value after the decrement
+*/
+func handleDecrBy(writer *RESP.Writer, args []RESP.RESPMessage, store *store.Store, clientID string, txManager *tx.TxManager) error {
+	if len(args) != 2 {
+		err := errWrongNumberOfArguments("DECRBY")
+		return HandleError(writer, []byte(err.Error()))
+	}
+
+	delta, err := strconv.ParseInt(string(args[1].RESPValue), 10, 64)
+	if err != nil {
+		return HandleError(writer, []byte("ERR value is not an integer or out of range"))
+	}
+
+	// the smallest int64 has no positive counterpart to negate into
+	if delta == math.MinInt64 {
+		return HandleError(writer, []byte("ERR decrement would overflow"))
+	}
+
+	return incrByGeneric(writer, store, txManager, string(args[0].RESPValue), -delta)
+}
+
+/*
+ 	* handleIncrByFloat handles the INCRBYFLOAT command, INCRBYFLOAT key increment
+	* @param writer *RESP.Writer - the writer to write the response to
+	* @param args []RESP.RESPMessage - the arguments for the command
+	* @param store *store.Store - the store to get the data from
+	* @param clientID string - the client id
+	* @param txManager *tx.TxManager - the transaction manager
+	* @return error - the error if there is one
+	* @return bulk string - the value after the increment
+*/
+func handleIncrByFloat(writer *RESP.Writer, args []RESP.RESPMessage, store *store.Store, clientID string, txManager *tx.TxManager) error {
+	if len(args) != 2 {
+		err := errWrongNumberOfArguments("INCRBYFLOAT")
+		return HandleError(writer, []byte(err.Error()))
+	}
+
+	key := string(args[0].RESPValue)
+
+	delta, err := strconv.ParseFloat(string(args[1].RESPValue), 64)
+	if err != nil || math.IsNaN(delta) || math.IsInf(delta, 0) {
+		return HandleError(writer, []byte("ERR value is not a valid float"))
+	}
+
+	value, err := store.IncrByFloat(key, delta)
+	if err != nil {
+		return HandleError(writer, []byte(err.Error()))
+	}
+
+	signalModifiedKey(txManager, key)
+
+	return encodeBulkString(writer, value)
+}
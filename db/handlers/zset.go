@@ -0,0 +1,904 @@
+package handlers
+
+import (
+	"errors"
+	"math"
+	"strconv"
+	"strings"
+
+	RESP "github.com/manish-singh-bisht/Redis-From-Scratch/db/resp"
+	store "github.com/manish-singh-bisht/Redis-From-Scratch/db/store"
+	tx "github.com/manish-singh-bisht/Redis-From-Scratch/db/transaction"
+)
+
+var (
+	errZScoreNotFloat     = errors.New("ERR value is not a valid float")
+	errZRangeNotFloat     = errors.New("ERR min or max is not a float")
+	errZRangeNotLex       = errors.New("ERR min or max not valid string range item")
+	errZWeightNotFloat    = errors.New("ERR weight value is not a float")
+	errZAddXXAndNX        = errors.New("ERR XX and NX options at the same time are not compatible")
+	errZAddGTLTNX         = errors.New("ERR GT, LT, and/or NX options at the same time are not compatible")
+	errZAddIncrSinglePair = errors.New("ERR INCR option supports a single increment-element pair")
+	errZRangeLimit        = errors.New("ERR syntax error, LIMIT is only supported in combination with either BYSCORE or BYLEX")
+	errZRangeLexScores    = errors.New("ERR syntax error, WITHSCORES not supported in combination with BYLEX")
+)
+
+// what a ZRANGE ranges over
+const (
+	zrangeByRank = iota
+	zrangeByScore
+	zrangeByLex
+)
+
+/*
+ 	* parseZScore parses a score, inf, +inf and -inf are valid scores, nan is not
+	* @param value []byte - the score
+	* @return float64 - the score
+	* @return error - errZScoreNotFloat if the value is not a valid score
+*/
+func parseZScore(value []byte) (float64, error) {
+	score, err := strconv.ParseFloat(string(value), 64)
+	if err != nil || math.IsNaN(score) {
+		return 0, errZScoreNotFloat
+	}
+	return score, nil
+}
+
+/*
+ 	* formatZScore formats a score for a reply, with inf and -inf for the infinities like Redis
+	* @param score float64 - the score
+	* @return []byte - the formatted score
+*/
+func formatZScore(score float64) []byte {
+	switch {
+	case math.IsInf(score, 1):
+		return []byte("inf")
+	case math.IsInf(score, -1):
+		return []byte("-inf")
+	}
+	return formatFloat(score)
+}
+
+/*
+ 	* encodeZScore writes a score as a bulk string reply
+	* @param writer *RESP.Writer - the writer to write to
+	* @param score float64 - the score
+	* @return error - the error if there is one
+*/
+func encodeZScore(writer *RESP.Writer, score float64) error {
+	message := bulkStringMessage(formatZScore(score))
+	return writer.Encode(&message)
+}
+
+/*
+ 	* parseScoreBound parses one end of a score range, a score, exclusive when prefixed by (
+	* @param value []byte - the bound
+	* @return float64 - the score
+	* @return bool - true if the bound is exclusive
+	* @return error - errZRangeNotFloat if the bound is not a valid score
+*/
+func parseScoreBound(value []byte) (float64, bool, error) {
+	exclusive := len(value) > 0 && value[0] == '('
+	if exclusive {
+		value = value[1:]
+	}
+
+	score, err := strconv.ParseFloat(string(value), 64)
+	if err != nil || math.IsNaN(score) {
+		return 0, false, errZRangeNotFloat
+	}
+	return score, exclusive, nil
+}
+
+/*
+ 	* parseScoreRange parses the min and max of a score range
+	* @param min []byte - the lower end
+	* @param max []byte - the upper end
+	* @return store.ScoreRange - the range
+	* @return error - errZRangeNotFloat if either end is not a valid score
+*/
+func parseScoreRange(min, max []byte) (store.ScoreRange, error) {
+	var r store.ScoreRange
+	var err error
+
+	if r.Min, r.MinExclusive, err = parseScoreBound(min); err != nil {
+		return r, err
+	}
+	if r.Max, r.MaxExclusive, err = parseScoreBound(max); err != nil {
+		return r, err
+	}
+	return r, nil
+}
+
+/*
+ 	* parseLexBound parses one end of a lex range, - or +, or a member prefixed by [ when inclusive or ( when exclusive
+	* @param value []byte - the bound
+	* @return store.LexBound - the bound
+	* @return error - errZRangeNotLex if the bound is not valid
+*/
+func parseLexBound(value []byte) (store.LexBound, error) {
+	if len(value) == 0 {
+		return store.LexBound{}, errZRangeNotLex
+	}
+
+	switch value[0] {
+	case '-':
+		if len(value) == 1 {
+			return store.LexBound{Infinity: -1}, nil
+		}
+	case '+':
+		if len(value) == 1 {
+			return store.LexBound{Infinity: 1}, nil
+		}
+	case '[':
+		return store.LexBound{Value: string(value[1:])}, nil
+	case '(':
+		return store.LexBound{Value: string(value[1:]), Exclusive: true}, nil
+	}
+
+	return store.LexBound{}, errZRangeNotLex
+}
+
+/*
+ 	* parseLexRange parses the min and max of a lex range
+	* @param min []byte - the lower end
+	* @param max []byte - the upper end
+	* @return store.LexRange - the range
+	* @return error - errZRangeNotLex if either end is not valid
+*/
+func parseLexRange(min, max []byte) (store.LexRange, error) {
+	var r store.LexRange
+	var err error
+
+	if r.Min, err = parseLexBound(min); err != nil {
+		return r, err
+	}
+	if r.Max, err = parseLexBound(max); err != nil {
+		return r, err
+	}
+	return r, nil
+}
+
+/*
+ 	* zmemberMessages builds the reply of a range of members, with each score after its member when withScores is set
+	* @param members []store.ZMember - the members
+	* @param withScores bool - include the scores
+	* @return []RESP.RESPMessage - the messages
+*/
+func zmemberMessages(members []store.ZMember, withScores bool) []RESP.RESPMessage {
+	messages := make([]RESP.RESPMessage, 0, len(members)*2)
+	for _, member := range members {
+		messages = append(messages, bulkStringMessage([]byte(member.Member)))
+		if withScores {
+			messages = append(messages, bulkStringMessage(formatZScore(member.Score)))
+		}
+	}
+	return messages
+}
+
+/*
+ 	* handleZAdd handles the ZADD command, ZADD key [NX | XX] [GT | LT] [CH] [INCR] score member [score member ...]
+	* @param writer *RESP.Writer - the writer to write the response to
+	* @param args []RESP.RESPMessage - the arguments for the command
+	* @param store *store.Store - the store to get the data from
+	* @param clientID string - the client id
+	* @param txManager *tx.TxManager - the transaction manager
+	* @return error - the error if there is one
+	* @return integer - the number of members added, plus the ones whose score changed with CH
+	* @return bulk string - with INCR, the new score of the member, or nil if the options prevented the change
+*/
+func handleZAdd(writer *RESP.Writer, args []RESP.RESPMessage, st *store.Store, clientID string, txManager *tx.TxManager) error {
+	if len(args) < 3 {
+		err := errWrongNumberOfArguments("ZADD")
+		return HandleError(writer, []byte(err.Error()))
+	}
+
+	key := string(args[0].RESPValue)
+
+	flags := store.ZAddAlways
+	changed, incr := false, false
+
+	i := 1
+options:
+	for ; i < len(args); i++ {
+		switch strings.ToUpper(string(args[i].RESPValue)) {
+		case "NX":
+			flags |= store.ZAddNX
+		case "XX":
+			flags |= store.ZAddXX
+		case "GT":
+			flags |= store.ZAddGT
+		case "LT":
+			flags |= store.ZAddLT
+		case "CH":
+			changed = true
+		case "INCR":
+			incr = true
+		default:
+			break options
+		}
+	}
+
+	pairs := args[i:]
+	if len(pairs) == 0 || len(pairs)%2 != 0 {
+		return HandleError(writer, []byte(errSyntax.Error()))
+	}
+	if flags&store.ZAddNX != 0 && flags&store.ZAddXX != 0 {
+		return HandleError(writer, []byte(errZAddXXAndNX.Error()))
+	}
+	if (flags&store.ZAddGT != 0 && flags&store.ZAddLT != 0) ||
+		(flags&store.ZAddNX != 0 && flags&(store.ZAddGT|store.ZAddLT) != 0) {
+		return HandleError(writer, []byte(errZAddGTLTNX.Error()))
+	}
+	if incr && len(pairs) > 2 {
+		return HandleError(writer, []byte(errZAddIncrSinglePair.Error()))
+	}
+
+	members := make([]store.ZMember, 0, len(pairs)/2)
+	for j := 0; j < len(pairs); j += 2 {
+		score, err := parseZScore(pairs[j].RESPValue)
+		if err != nil {
+			return HandleError(writer, []byte(err.Error()))
+		}
+		members = append(members, store.ZMember{Member: string(pairs[j+1].RESPValue), Score: score})
+	}
+
+	if incr {
+		score, done, err := st.ZIncrBy(key, members[0].Member, members[0].Score, flags)
+		if err != nil {
+			return HandleError(writer, []byte(err.Error()))
+		}
+		if !done {
+			return writer.EncodeNil()
+		}
+
+		signalModifiedKey(txManager, key)
+
+		return encodeZScore(writer, score)
+	}
+
+	added, updated, err := st.ZAdd(key, members, flags)
+	if err != nil {
+		return HandleError(writer, []byte(err.Error()))
+	}
+
+	if added+updated > 0 {
+		signalModifiedKey(txManager, key)
+	}
+
+	if changed {
+		return encodeInteger(writer, int64(added+updated))
+	}
+	return encodeInteger(writer, int64(added))
+}
+
+/*
+ 	* handleZIncrBy handles the ZINCRBY command, ZINCRBY key increment member
+	* @param writer *RESP.Writer - the writer to write the response to
+	* @param args []RESP.RESPMessage - the arguments for the command
+	* @param store *store.Store - the store to get the data from
+	* @param clientID string - the client id
+	* @param txManager *tx.TxManager - the transaction manager
+	* @return error - the error if there is one
+	* @return bulk string - the new score of the member
+*/
+func handleZIncrBy(writer *RESP.Writer, args []RESP.RESPMessage, st *store.Store, clientID string, txManager *tx.TxManager) error {
+	if len(args) != 3 {
+		err := errWrongNumberOfArguments("ZINCRBY")
+		return HandleError(writer, []byte(err.Error()))
+	}
+
+	key := string(args[0].RESPValue)
+
+	delta, err := parseZScore(args[1].RESPValue)
+	if err != nil {
+		return HandleError(writer, []byte(err.Error()))
+	}
+
+	score, _, err := st.ZIncrBy(key, string(args[2].RESPValue), delta, store.ZAddAlways)
+	if err != nil {
+		return HandleError(writer, []byte(err.Error()))
+	}
+
+	signalModifiedKey(txManager, key)
+
+	return encodeZScore(writer, score)
+}
+
+/*
+ 	* handleZRem handles the ZREM command, ZREM key member [member ...]
+	* @param writer *RESP.Writer - the writer to write the response to
+	* @param args []RESP.RESPMessage - the arguments for the command
+	* @param store *store.Store - the store to get the data from
+	* @param clientID string - the client id
+	* @param txManager *tx.TxManager - the transaction manager
+	* @return error - the error if there is one
+	* @return integer - the number of members that were removed
+*/
+func handleZRem(writer *RESP.Writer, args []RESP.RESPMessage, store *store.Store, clientID string, txManager *tx.TxManager) error {
+	if len(args) < 2 {
+		err := errWrongNumberOfArguments("ZREM")
+		return HandleError(writer, []byte(err.Error()))
+	}
+
+	key := string(args[0].RESPValue)
+
+	removed, err := store.ZRem(key, keyArgs(args[1:]))
+	if err != nil {
+		return HandleError(writer, []byte(err.Error()))
+	}
+
+	if removed > 0 {
+		signalModifiedKey(txManager, key)
+	}
+
+	return encodeInteger(writer, int64(removed))
+}
+
+/*
+ 	* handleZCard handles the ZCARD command, ZCARD key
+	* @param writer *RESP.Writer - the writer to write the response to
+	* @param args []RESP.RESPMessage - the arguments for the command
+	* @param store *store.Store - the store to get the data from
+	* @param clientID string - the client id
+	* @param txManager *tx.TxManager - the transaction manager
+	* @return error - the error if there is one
+	* @return integer - the number of members, 0 if the key doesn't exist
+*/
+func handleZCard(writer *RESP.Writer, args []RESP.RESPMessage, store *store.Store, clientID string, txManager *tx.TxManager) error {
+	if len(args) != 1 {
+		err := errWrongNumberOfArguments("ZCARD")
+		return HandleError(writer, []byte(err.Error()))
+	}
+
+	length, err := store.ZCard(string(args[0].RESPValue))
+	if err != nil {
+		return HandleError(writer, []byte(err.Error()))
+	}
+
+	return encodeInteger(writer, int64(length))
+}
+
+/*
+ 	* handleZScore handles the ZSCORE command, ZSCORE key member
+	* @param writer *RESP.Writer - the writer to write the response to
+	* @param args []RESP.RESPMessage - the arguments for the command
+	* @param store *store.Store - the store to get the data from
+	* @param clientID string - the client id
+	* @param txManager *tx.TxManager - the transaction manager
+	* @return error - the error if there is one
+	* @return bulk string - the score of the member, nil if the key or the member doesn't exist
+*/
+func handleZScore(writer *RESP.Writer, args []RESP.RESPMessage, store *store.Store, clientID string, txManager *tx.TxManager) error {
+	if len(args) != 2 {
+		err := errWrongNumberOfArguments("ZSCORE")
+		return HandleError(writer, []byte(err.Error()))
+	}
+
+	scores, found, err := store.ZScore(string(args[0].RESPValue), keyArgs(args[1:]))
+	if err != nil {
+		return HandleError(writer, []byte(err.Error()))
+	}
+	if !found[0] {
+		return writer.EncodeNil()
+	}
+
+	return encodeZScore(writer, scores[0])
+}
+
+/*
+ 	* handleZMScore handles the ZMSCORE command, ZMSCORE key member [member ...]
+	* @param writer *RESP.Writer - the writer to write the response to
+	* @param args []RESP.RESPMessage - the arguments for the command
+	* @param store *store.Store - the store to get the data from
+	* @param clientID string - the client id
+	* @param txManager *tx.TxManager - the transaction manager
+	* @return error - the error if there is one
+	* @return array - the score of each member, nil for the members that don't exist
+*/
+func handleZMScore(writer *RESP.Writer, args []RESP.RESPMessage, store *store.Store, clientID string, txManager *tx.TxManager) error {
+	if len(args) < 2 {
+		err := errWrongNumberOfArguments("ZMSCORE")
+		return HandleError(writer, []byte(err.Error()))
+	}
+
+	scores, found, err := store.ZScore(string(args[0].RESPValue), keyArgs(args[1:]))
+	if err != nil {
+		return HandleError(writer, []byte(err.Error()))
+	}
+
+	elements := make([]RESP.RESPMessage, len(scores))
+	for i, score := range scores {
+		if found[i] {
+			elements[i] = bulkStringMessage(formatZScore(score))
+		} else {
+			elements[i] = bulkStringMessage(nil)
+		}
+	}
+
+	return encodeArray(writer, elements)
+}
+
+/*
+ 	* handleZRank handles the ZRANK command, ZRANK key member [WITHSCORE]
+	* @param writer *RESP.Writer - the writer to write the response to
+	* @param args []RESP.RESPMessage - the arguments for the command
+	* @param store *store.Store - the store to get the data from
+	* @param clientID string - the client id
+	* @param txManager *tx.TxManager - the transaction manager
+	* @return error - the error if there is one
+	* @return integer - the rank of the member, lowest score first, nil if the key or the member doesn't exist
+	* @return array - with WITHSCORE, the rank and the score of the member
+*/
+func handleZRank(writer *RESP.Writer, args []RESP.RESPMessage, store *store.Store, clientID string, txManager *tx.TxManager) error {
+	return zrankGeneric(writer, args, store, "ZRANK", false)
+}
+
+/*
+ 	* handleZRevRank handles the ZREVRANK command, ZREVRANK key member [WITHSCORE]
+	* @param writer *RESP.Writer - the writer to write the response to
+	* @param args []RESP.RESPMessage - the arguments for the command
+	* @param store *store.Store - the store to get the data from
+	* @param clientID string - the client id
+	* @param txManager *tx.TxManager - the transaction manager
+	* @return error - the error if there is one
+	* @return integer - the rank of the member, highest score first, nil if the key or the member doesn't exist
+	* @return array - with WITHSCORE, the rank and the score of the member
+*/
+func handleZRevRank(writer *RESP.Writer, args []RESP.RESPMessage, store *store.Store, clientID string, txManager *tx.TxManager) error {
+	return zrankGeneric(writer, args, store, "ZREVRANK", true)
+}
+
+/*
+ 	* zrankGeneric implements ZRANK and ZREVRANK
+	* @param writer *RESP.Writer - the writer to write the response to
+	* @param args []RESP.RESPMessage - the arguments for the command
+	* @param st *store.Store - the store to get the data from
+	* @param cmd string - the name of the command, for errors
+	* @param reverse bool - rank from the highest score
+	* @return error - the error if there is one
+*/
+func zrankGeneric(writer *RESP.Writer, args []RESP.RESPMessage, st *store.Store, cmd string, reverse bool) error {
+	if len(args) != 2 && len(args) != 3 {
+		err := errWrongNumberOfArguments(cmd)
+		return HandleError(writer, []byte(err.Error()))
+	}
+
+	withScore := len(args) == 3
+	if withScore && strings.ToUpper(string(args[2].RESPValue)) != "WITHSCORE" {
+		return HandleError(writer, []byte(errSyntax.Error()))
+	}
+
+	rank, score, exists, err := st.ZRank(string(args[0].RESPValue), string(args[1].RESPValue), reverse)
+	if err != nil {
+		return HandleError(writer, []byte(err.Error()))
+	}
+	if !exists {
+		return writer.EncodeNil()
+	}
+
+	if withScore {
+		return encodeArray(writer, []RESP.RESPMessage{
+			integerMessage(int64(rank)),
+			bulkStringMessage(formatZScore(score)),
+		})
+	}
+	return encodeInteger(writer, int64(rank))
+}
+
+/*
+ 	* handleZCount handles the ZCOUNT command, ZCOUNT key min max
+	* @param writer *RESP.Writer - the writer to write the response to
+	* @param args []RESP.RESPMessage - the arguments for the command
+	* @param store *store.Store - the store to get the data from
+	* @param clientID string - the client id
+	* @param txManager *tx.TxManager - the transaction manager
+	* @return error - the error if there is one
+	* @return integer - the number of members with a score between min and max
+*/
+func handleZCount(writer *RESP.Writer, args []RESP.RESPMessage, store *store.Store, clientID string, txManager *tx.TxManager) error {
+	if len(args) != 3 {
+		err := errWrongNumberOfArguments("ZCOUNT")
+		return HandleError(writer, []byte(err.Error()))
+	}
+
+	r, err := parseScoreRange(args[1].RESPValue, args[2].RESPValue)
+	if err != nil {
+		return HandleError(writer, []byte(err.Error()))
+	}
+
+	count, err := store.ZCount(string(args[0].RESPValue), r)
+	if err != nil {
+		return HandleError(writer, []byte(err.Error()))
+	}
+
+	return encodeInteger(writer, int64(count))
+}
+
+/*
+ 	* handleZLexCount handles the ZLEXCOUNT command, ZLEXCOUNT key min max
+	* @param writer *RESP.Writer - the writer to write the response to
+	* @param args []RESP.RESPMessage - the arguments for the command
+	* @param store *store.Store - the store to get the data from
+	* @param clientID string - the client id
+	* @param txManager *tx.TxManager - the transaction manager
+	* @return error - the error if there is one
+	* @return integer - the number of members between min and max
+*/
+func handleZLexCount(writer *RESP.Writer, args []RESP.RESPMessage, store *store.Store, clientID string, txManager *tx.TxManager) error {
+	if len(args) != 3 {
+		err := errWrongNumberOfArguments("ZLEXCOUNT")
+		return HandleError(writer, []byte(err.Error()))
+	}
+
+	r, err := parseLexRange(args[1].RESPValue, args[2].RESPValue)
+	if err != nil {
+		return HandleError(writer, []byte(err.Error()))
+	}
+
+	count, err := store.ZLexCount(string(args[0].RESPValue), r)
+	if err != nil {
+		return HandleError(writer, []byte(err.Error()))
+	}
+
+	return encodeInteger(writer, int64(count))
+}
+
+/*
+ 	* handleZPopMin handles the ZPOPMIN command, ZPOPMIN key [count]
+	* @param writer *RESP.Writer - the writer to write the response to
+	* @param args []RESP.RESPMessage - the arguments for the command
+	* @param store *store.Store - the store to get the data from
+	* @param clientID string - the client id
+	* @param txManager *tx.TxManager - the transaction manager
+	* @return error - the error if there is one
+	* @return array - the members that were removed, lowest score first, each followed by its score
+*/
+func handleZPopMin(writer *RESP.Writer, args []RESP.RESPMessage, store *store.Store, clientID string, txManager *tx.TxManager) error {
+	return zpopGeneric(writer, args, store, txManager, "ZPOPMIN", false)
+}
+
+/*
+ 	* handleZPopMax handles the ZPOPMAX command, ZPOPMAX key [count]
+	* @param writer *RESP.Writer - the writer to write the response to
+	* @param args []RESP.RESPMessage - the arguments for the command
+	* @param store *store.Store - the store to get the data from
+	* @param clientID string - the client id
+	* @param txManager *tx.TxManager - the transaction manager
+	* @return error - the error if there is one
+	* @return array - the members that were removed, highest score first, each followed by its score
+*/
+func handleZPopMax(writer *RESP.Writer, args []RESP.RESPMessage, store *store.Store, clientID string, txManager *tx.TxManager) error {
+	return zpopGeneric(writer, args, store, txManager, "ZPOPMAX", true)
+}
+
+/*
+ 	* zpopGeneric implements ZPOPMIN and ZPOPMAX
+	* @param writer *RESP.Writer - the writer to write the response to
+	* @param args []RESP.RESPMessage - the arguments for the command
+	* @param st *store.Store - the store to get the data from
+	* @param txManager *tx.TxManager - the transaction manager
+	* @param cmd string - the name of the command, for errors
+	* @param highest bool - pop the highest scores instead of the lowest
+	* @return error - the error if there is one
+*/
+func zpopGeneric(writer *RESP.Writer, args []RESP.RESPMessage, st *store.Store, txManager *tx.TxManager, cmd string, highest bool) error {
+	if len(args) != 1 && len(args) != 2 {
+		err := errWrongNumberOfArguments(cmd)
+		return HandleError(writer, []byte(err.Error()))
+	}
+
+	key := string(args[0].RESPValue)
+
+	count := 1
+	if len(args) == 2 {
+		var err error
+		count, err = strconv.Atoi(string(args[1].RESPValue))
+		if err != nil {
+			return HandleError(writer, []byte("ERR value is not an integer or out of range"))
+		}
+		if count < 0 {
+			return HandleError(writer, []byte("ERR value is out of range, must be positive"))
+		}
+	}
+
+	popped, err := st.ZPop(key, count, highest)
+	if err != nil {
+		return HandleError(writer, []byte(err.Error()))
+	}
+
+	if len(popped) > 0 {
+		signalModifiedKey(txManager, key)
+	}
+
+	return encodeArray(writer, zmemberMessages(popped, true))
+}
+
+/*
+ 	* handleZRange handles the ZRANGE command, ZRANGE key start stop [BYSCORE | BYLEX] [REV] [LIMIT offset count] [WITHSCORES].
+	* start and stop are ranks, scores with BYSCORE or lex bounds with BYLEX, and with REV they are given highest first
+	* @param writer *RESP.Writer - the writer to write the response to
+	* @param args []RESP.RESPMessage - the arguments for the command
+	* @param store *store.Store - the store to get the data from
+	* @param clientID string - the client id
+	* @param txManager *tx.TxManager - the transaction manager
+	* @return error - the error if there is one
+	* @return array - the members in the range, each followed by its score with WITHSCORES
+*/
+func handleZRange(writer *RESP.Writer, args []RESP.RESPMessage, store *store.Store, clientID string, txManager *tx.TxManager) error {
+	return zrangeGeneric(writer, args, store, "ZRANGE", zrangeByRank, false)
+}
+
+/*
+ 	* handleZRevRange handles the ZREVRANGE command, ZREVRANGE key start stop [WITHSCORES], ZRANGE with REV
+	* @param writer *RESP.Writer - the writer to write the response to
+	* @param args []RESP.RESPMessage - the arguments for the command
+	* @param store *store.Store - the store to get the data from
+	* @param clientID string - the client id
+	* @param txManager *tx.TxManager - the transaction manager
+	* @return error - the error if there is one
+	* @return array - the members in the range, highest score first
+*/
+func handleZRevRange(writer *RESP.Writer, args []RESP.RESPMessage, store *store.Store, clientID string, txManager *tx.TxManager) error {
+	return zrangeGeneric(writer, args, store, "ZREVRANGE", zrangeByRank, true)
+}
+
+/*
+ 	* handleZRangeByScore handles the ZRANGEBYSCORE command, ZRANGEBYSCORE key min max [WITHSCORES] [LIMIT offset count],
+	* ZRANGE with BYSCORE
+	* @param writer *RESP.Writer - the writer to write the response to
+	* @param args []RESP.RESPMessage - the arguments for the command
+	* @param store *store.Store - the store to get the data from
+	* @param clientID string - the client id
+	* @param txManager *tx.TxManager - the transaction manager
+	* @return error - the error if there is one
+	* @return array - the members with a score between min and max, lowest score first
+*/
+func handleZRangeByScore(writer *RESP.Writer, args []RESP.RESPMessage, store *store.Store, clientID string, txManager *tx.TxManager) error {
+	return zrangeGeneric(writer, args, store, "ZRANGEBYSCORE", zrangeByScore, false)
+}
+
+/*
+ 	* handleZRevRangeByScore handles the ZREVRANGEBYSCORE command, ZREVRANGEBYSCORE key max min [WITHSCORES] [LIMIT offset count],
+	* ZRANGE with BYSCORE and REV
+	* @param writer *RESP.Writer - the writer to write the response to
+	* @param args []RESP.RESPMessage - the arguments for the command
+	* @param store *store.Store - the store to get the data from
+	* @param clientID string - the client id
+	* @param txManager *tx.TxManager - the transaction manager
+	* @return error - the error if there is one
+	* @return array - the members with a score between min and max, highest score first
+*/
+func handleZRevRangeByScore(writer *RESP.Writer, args []RESP.RESPMessage, store *store.Store, clientID string, txManager *tx.TxManager) error {
+	return zrangeGeneric(writer, args, store, "ZREVRANGEBYSCORE", zrangeByScore, true)
+}
+
+/*
+ 	* handleZRangeByLex handles the ZRANGEBYLEX command, ZRANGEBYLEX key min max [LIMIT offset count], ZRANGE with BYLEX
+	* @param writer *RESP.Writer - the writer to write the response to
+	* @param args []RESP.RESPMessage - the arguments for the command
+	* @param store *store.Store - the store to get the data from
+	* @param clientID string - the client id
+	* @param txManager *tx.TxManager - the transaction manager
+	* @return error - the error if there is one
+	* @return array - the members between min and max
+*/
+func handleZRangeByLex(writer *RESP.Writer, args []RESP.RESPMessage, store *store.Store, clientID string, txManager *tx.TxManager) error {
+	return zrangeGeneric(writer, args, store, "ZRANGEBYLEX", zrangeByLex, false)
+}
+
+/*
+ 	* handleZRevRangeByLex handles the ZREVRANGEBYLEX command, ZREVRANGEBYLEX key max min [LIMIT offset count],
+	* ZRANGE with BYLEX and REV
+	* @param writer *RESP.Writer - the writer to write the response to
+	* @param args []RESP.RESPMessage - the arguments for the command
+	* @param store *store.Store - the store to get the data from
+	* @param clientID string - the client id
+	* @param txManager *tx.TxManager - the transaction manager
+	* @return error - the error if there is one
+	* @return array - the members between min and max, last first
+*/
+func handleZRevRangeByLex(writer *RESP.Writer, args []RESP.RESPMessage, store *store.Store, clientID string, txManager *tx.TxManager) error {
+	return zrangeGeneric(writer, args, store, "ZREVRANGEBYLEX", zrangeByLex, true)
+}
+
+/*
+ 	* zrangeGeneric implements ZRANGE and the older range commands it replaces, which fix the kind of range and the direction,
+	* only ZRANGE takes BYSCORE, BYLEX and REV
+	* @param writer *RESP.Writer - the writer to write the response to
+	* @param args []RESP.RESPMessage - the arguments for the command
+	* @param st *store.Store - the store to get the data from
+	* @param cmd string - the name of the command, for errors
+	* @param by int - zrangeByRank, zrangeByScore or zrangeByLex
+	* @param reverse bool - highest first, the range is then given as max then min
+	* @return error - the error if there is one
+*/
+func zrangeGeneric(writer *RESP.Writer, args []RESP.RESPMessage, st *store.Store, cmd string, by int, reverse bool) error {
+	if len(args) < 3 {
+		err := errWrongNumberOfArguments(cmd)
+		return HandleError(writer, []byte(err.Error()))
+	}
+
+	withScores, limited := false, false
+	offset, count := 0, -1
+
+	for i := 3; i < len(args); i++ {
+		option := strings.ToUpper(string(args[i].RESPValue))
+		switch {
+		case option == "WITHSCORES":
+			withScores = true
+		case option == "LIMIT" && i+2 < len(args):
+			var err1, err2 error
+			offset, err1 = strconv.Atoi(string(args[i+1].RESPValue))
+			count, err2 = strconv.Atoi(string(args[i+2].RESPValue))
+			if err1 != nil || err2 != nil {
+				return HandleError(writer, []byte("ERR value is not an integer or out of range"))
+			}
+			limited = true
+			i += 2
+		case option == "BYSCORE" && cmd == "ZRANGE":
+			by = zrangeByScore
+		case option == "BYLEX" && cmd == "ZRANGE":
+			by = zrangeByLex
+		case option == "REV" && cmd == "ZRANGE":
+			reverse = true
+		default:
+			return HandleError(writer, []byte(errSyntax.Error()))
+		}
+	}
+
+	if limited && by == zrangeByRank {
+		return HandleError(writer, []byte(errZRangeLimit.Error()))
+	}
+	if withScores && by == zrangeByLex {
+		return HandleError(writer, []byte(errZRangeLexScores.Error()))
+	}
+
+	key := string(args[0].RESPValue)
+	min, max := args[1].RESPValue, args[2].RESPValue
+	if reverse && by != zrangeByRank {
+		min, max = max, min
+	}
+
+	var members []store.ZMember
+	var err error
+
+	switch by {
+	case zrangeByRank:
+		start, err1 := strconv.Atoi(string(min))
+		stop, err2 := strconv.Atoi(string(max))
+		if err1 != nil || err2 != nil {
+			return HandleError(writer, []byte("ERR value is not an integer or out of range"))
+		}
+		members, err = st.ZRangeByRank(key, start, stop, reverse)
+	case zrangeByScore:
+		r, parseErr := parseScoreRange(min, max)
+		if parseErr != nil {
+			return HandleError(writer, []byte(parseErr.Error()))
+		}
+		members, err = st.ZRangeByScore(key, r, reverse, offset, count)
+	case zrangeByLex:
+		r, parseErr := parseLexRange(min, max)
+		if parseErr != nil {
+			return HandleError(writer, []byte(parseErr.Error()))
+		}
+		members, err = st.ZRangeByLex(key, r, reverse, offset, count)
+	}
+	if err != nil {
+		return HandleError(writer, []byte(err.Error()))
+	}
+
+	return encodeArray(writer, zmemberMessages(members, withScores))
+}
+
+/*
+ 	* handleZUnionStore handles the ZUNIONSTORE command,
+	* ZUNIONSTORE destination numkeys key [key ...] [WEIGHTS weight [weight ...]] [AGGREGATE SUM | MIN | MAX]
+	* @param writer *RESP.Writer - the writer to write the response to
+	* @param args []RESP.RESPMessage - the arguments for the command
+	* @param store *store.Store - the store to get the data from
+	* @param clientID string - the client id
+	* @param txManager *tx.TxManager - the transaction manager
+	* @return error - the error if there is one
+	* @return integer - the number of members of the result, stored at destination
+*/
+func handleZUnionStore(writer *RESP.Writer, args []RESP.RESPMessage, st *store.Store, clientID string, txManager *tx.TxManager) error {
+	return zsetOpStoreGeneric(writer, args, st, txManager, "ZUNIONSTORE", store.SetUnion)
+}
+
+/*
+ 	* handleZInterStore handles the ZINTERSTORE command,
+	* ZINTERSTORE destination numkeys key [key ...] [WEIGHTS weight [weight ...]] [AGGREGATE SUM | MIN | MAX]
+	* @param writer *RESP.Writer - the writer to write the response to
+	* @param args []RESP.RESPMessage - the arguments for the command
+	* @param store *store.Store - the store to get the data from
+	* @param clientID string - the client id
+	* @param txManager *tx.TxManager - the transaction manager
+	* @return error - the error if there is one
+	* @return integer - the number of members of the result, stored at destination
+*/
+func handleZInterStore(writer *RESP.Writer, args []RESP.RESPMessage, st *store.Store, clientID string, txManager *tx.TxManager) error {
+	return zsetOpStoreGeneric(writer, args, st, txManager, "ZINTERSTORE", store.SetInter)
+}
+
+/*
+ 	* zsetOpStoreGeneric implements ZUNIONSTORE and ZINTERSTORE, the inputs can be sets, whose members score 1,
+	* destination is overwritten whatever its type, and deleted when the result is empty
+	* @param writer *RESP.Writer - the writer to write the response to
+	* @param args []RESP.RESPMessage - the arguments for the command
+	* @param st *store.Store - the store to get the data from
+	* @param txManager *tx.TxManager - the transaction manager
+	* @param cmd string - the name of the command, for errors
+	* @param op store.SetOperation - SetUnion or SetInter
+	* @return error - the error if there is one
+*/
+func zsetOpStoreGeneric(writer *RESP.Writer, args []RESP.RESPMessage, st *store.Store, txManager *tx.TxManager, cmd string, op store.SetOperation) error {
+	if len(args) < 3 {
+		err := errWrongNumberOfArguments(cmd)
+		return HandleError(writer, []byte(err.Error()))
+	}
+
+	dst := string(args[0].RESPValue)
+
+	numKeys, err := strconv.Atoi(string(args[1].RESPValue))
+	if err != nil {
+		return HandleError(writer, []byte("ERR value is not an integer or out of range"))
+	}
+	if numKeys < 1 {
+		return HandleError(writer, []byte("ERR at least 1 input key is needed for '"+strings.ToLower(cmd)+"' command"))
+	}
+	if numKeys > len(args)-2 {
+		return HandleError(writer, []byte(errSyntax.Error()))
+	}
+
+	keys := keyArgs(args[2 : 2+numKeys])
+
+	weights := make([]float64, numKeys)
+	for i := range weights {
+		weights[i] = 1
+	}
+	aggregate := store.ZAggregateSum
+
+	options := args[2+numKeys:]
+	for i := 0; i < len(options); i++ {
+		switch strings.ToUpper(string(options[i].RESPValue)) {
+		case "WEIGHTS":
+			if i+numKeys >= len(options) {
+				return HandleError(writer, []byte(errSyntax.Error()))
+			}
+			for j := range weights {
+				weights[j], err = strconv.ParseFloat(string(options[i+1+j].RESPValue), 64)
+				if err != nil || math.IsNaN(weights[j]) {
+					return HandleError(writer, []byte(errZWeightNotFloat.Error()))
+				}
+			}
+			i += numKeys
+		case "AGGREGATE":
+			if i+1 >= len(options) {
+				return HandleError(writer, []byte(errSyntax.Error()))
+			}
+			switch strings.ToUpper(string(options[i+1].RESPValue)) {
+			case "SUM":
+				aggregate = store.ZAggregateSum
+			case "MIN":
+				aggregate = store.ZAggregateMin
+			case "MAX":
+				aggregate = store.ZAggregateMax
+			default:
+				return HandleError(writer, []byte(errSyntax.Error()))
+			}
+			i++
+		default:
+			return HandleError(writer, []byte(errSyntax.Error()))
+		}
+	}
+
+	length, err := st.ZSetOpStore(op, dst, keys, weights, aggregate)
+	if err != nil {
+		return HandleError(writer, []byte(err.Error()))
+	}
+
+	signalModifiedKey(txManager, dst)
+
+	return encodeInteger(writer, int64(length))
+}
@@ -0,0 +1,613 @@
+package persistence
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	store "github.com/manish-singh-bisht/Redis-From-Scratch/db/store"
+)
+
+var ErrAOFTruncated = errors.New("AOF file is truncated, start with -aof-load-truncated yes to load it anyway")
+var ErrAOFInvalidFormat = errors.New("bad file format reading the append only file")
+
+const (
+	FsyncAlways   = "always"   // fsync after every write, slow but at most one command is lost
+	FsyncEverySec = "everysec" // fsync once per second in the background, at most a second of writes is lost
+	FsyncNo       = "no"       // leave flushing to the OS
+)
+
+// the most elements a single command adds when a collection is written to the AOF
+const aofRewriteItemsPerCmd = 64
+
+// the longest argument an AOF command can have, Redis' proto-max-bulk-len
+const aofMaxBulkLen = 512 * 1024 * 1024
+
+type aof struct {
+	mu    sync.Mutex
+	file  *os.File
+	fsync string
+	dirty bool // written to since the last fsync, used by everysec
+
+	// like Redis, once a write fails what could not be written is kept and retried every second,
+	// write commands are refused until it makes it to the file
+	size     int64  // the size of the file up to the last command written in full
+	pending  []byte // the commands not written yet
+	err      error  // the last write error, nil once pending is written
+	retrying bool
+}
+
+var aofInstance *aof
+
+/*
+ 	* IsValidFsyncPolicy checks if the policy is one of always, everysec or no
+	* @param policy string - the policy to check
+	* @return bool - true if the policy is valid, false otherwise
+*/
+func IsValidFsyncPolicy(policy string) bool {
+	return policy == FsyncAlways || policy == FsyncEverySec || policy == FsyncNo
+}
+
+/*
+ 	* OpenAOF opens the append only file for appending, commands are only appended after this is called
+	* so the commands replayed by LoadAOF at startup are not written back to the file.
+	* when there is no file yet it is first seeded with the current dataset, otherwise whatever was loaded
+	* from the RDB file would be lost on the next restart, as the AOF takes precedence
+	* @param st *store.Store - the store, used to seed a new file
+	* @return error - the error if there is one
+*/
+func OpenAOF(st *store.Store) error {
+	aofConfig := GetAOFConfig()
+	if !aofConfig.Enabled {
+		return nil
+	}
+
+	dir, _ := GetConfig()
+	path := filepath.Join(dir, aofConfig.Filename)
+
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		if err := writeAOFBase(path, st.Snapshot()); err != nil {
+			return err
+		}
+	}
+
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to open the AOF file: %w", err)
+	}
+
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return fmt.Errorf("failed to open the AOF file: %w", err)
+	}
+
+	aofInstance = &aof{
+		file:  f,
+		fsync: aofConfig.Fsync,
+		size:  info.Size(),
+	}
+
+	if aofConfig.Fsync == FsyncEverySec {
+		go aofInstance.fsyncEverySecond()
+	}
+
+	return nil
+}
+
+/*
+ 	* AppendCommand appends a single write command to the AOF, it is a no-op when AOF is disabled
+	* @param argv [][]byte - the command name followed by its arguments
+	* @return error - the error if there is one
+*/
+func AppendCommand(argv [][]byte) error {
+	if aofInstance == nil {
+		return nil
+	}
+
+	return aofInstance.write(encodeAOFCommand(nil, argv))
+}
+
+/*
+ 	* AppendTransaction appends the write commands of an EXEC as a MULTI ... EXEC block in a single write,
+	* so the transaction is replayed as a unit
+	* @param commands [][][]byte - the commands, each one the command name followed by its arguments
+	* @return error - the error if there is one
+*/
+func AppendTransaction(commands [][][]byte) error {
+	if aofInstance == nil || len(commands) == 0 {
+		return nil
+	}
+
+	buf := encodeAOFCommand(nil, [][]byte{[]byte("MULTI")})
+	for _, argv := range commands {
+		buf = encodeAOFCommand(buf, argv)
+	}
+	buf = encodeAOFCommand(buf, [][]byte{[]byte("EXEC")})
+
+	return aofInstance.write(buf)
+}
+
+/*
+ 	* AOFWriteError returns why the AOF can't be written to, write commands are refused with it until a retry succeeds
+	* @return error - the error to reply with, nil if the AOF is disabled or fine
+*/
+func AOFWriteError() error {
+	if aofInstance == nil {
+		return nil
+	}
+
+	aofInstance.mu.Lock()
+	defer aofInstance.mu.Unlock()
+	return aofInstance.err
+}
+
+/*
+ 	* write writes the buffer to the file, syncing it right away with the always policy
+	* @param buf []byte - the bytes to write
+	* @return error - the error if there is one, the buffer is then kept and retried
+*/
+func (a *aof) write(buf []byte) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if a.err != nil {
+		// behind what is still waiting to be written
+		a.pending = append(a.pending, buf...)
+		return a.err
+	}
+
+	n, err := a.file.Write(buf)
+	if err != nil {
+		// whatever part of it was written is truncated away before it is written again
+		a.fail(buf, err)
+		return a.err
+	}
+	a.size += int64(n)
+
+	if a.fsync == FsyncAlways {
+		if err := a.file.Sync(); err != nil {
+			a.fail(nil, err)
+			return a.err
+		}
+		return nil
+	}
+
+	a.dirty = true
+	return nil
+}
+
+/*
+ 	* fail keeps what could not be written and starts retrying it, a.mu must be held
+	* @param unwritten []byte - the bytes that did not make it to the file, nil if only the fsync failed
+	* @param err error - the error of the write
+*/
+func (a *aof) fail(unwritten []byte, err error) {
+	log.Printf("Error writing to the AOF file: %v", err)
+	a.pending = append(a.pending, unwritten...)
+	a.err = fmt.Errorf("MISCONF Errors writing to the AOF file: %v", err)
+
+	if !a.retrying {
+		a.retrying = true
+		go a.retryWrites()
+	}
+}
+
+// retryWrites writes what is pending once a second until it succeeds
+func (a *aof) retryWrites() {
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		a.mu.Lock()
+		done := a.retry()
+		if done {
+			a.retrying = false
+		}
+		a.mu.Unlock()
+
+		if done {
+			log.Print("AOF write error looks solved, accepting writes again")
+			return
+		}
+	}
+}
+
+/*
+ 	* retry writes what is pending, a.mu must be held
+	* @return bool - true once it is all written and synced
+*/
+func (a *aof) retry() bool {
+	// drop whatever part of a command a short write left at the end, so the file never holds half a command
+	if err := a.file.Truncate(a.size); err != nil {
+		return false
+	}
+
+	n, err := a.file.Write(a.pending)
+	if err != nil {
+		a.err = fmt.Errorf("MISCONF Errors writing to the AOF file: %v", err)
+		return false
+	}
+	a.size += int64(n)
+
+	if err := a.file.Sync(); err != nil {
+		a.err = fmt.Errorf("MISCONF Errors writing to the AOF file: %v", err)
+		return false
+	}
+
+	a.pending = nil
+	a.err = nil
+	a.dirty = false
+	return true
+}
+
+func (a *aof) fsyncEverySecond() {
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		a.mu.Lock()
+		if a.dirty && a.err == nil {
+			if err := a.file.Sync(); err != nil {
+				a.fail(nil, err)
+			}
+			a.dirty = false
+		}
+		a.mu.Unlock()
+	}
+}
+
+/*
+ 	* writeAOFBase writes the commands that rebuild the entries to a temp file and renames it to path
+	* @param path string - the path of the AOF file
+	* @param entries []store.SnapshotEntry - the keys to write
+	* @return error - the error if there is one
+*/
+func writeAOFBase(path string, entries []store.SnapshotEntry) error {
+	var buf []byte
+	for _, entry := range entries {
+		switch entry.Type {
+		case store.TypeString:
+			argv := [][]byte{[]byte("SET"), []byte(entry.Key), entry.Value}
+			buf = encodeAOFCommand(buf, argv)
+		case store.TypeList:
+			buf = encodeAOFVariadic(buf, "RPUSH", entry.Key, entry.Elements)
+		case store.TypeSet:
+			buf = encodeAOFVariadic(buf, "SADD", entry.Key, entry.Elements)
+		case store.TypeZSet:
+			pairs := make([][]byte, 0, 2*len(entry.ZMembers))
+			for _, member := range entry.ZMembers {
+				pairs = append(pairs, []byte(formatZSetScore(member.Score)), []byte(member.Member))
+			}
+			buf = encodeAOFVariadic(buf, "ZADD", entry.Key, pairs)
+		case store.TypeHash:
+			pairs := make([][]byte, 0, 2*len(entry.Fields))
+			for _, field := range entry.Fields {
+				pairs = append(pairs, []byte(field.Field), field.Value)
+			}
+			buf = encodeAOFVariadic(buf, "HSET", entry.Key, pairs)
+
+			for _, field := range entry.Fields {
+				if field.Expiration.IsZero() {
+					continue
+				}
+				argv := [][]byte{[]byte("HPEXPIREAT"), []byte(entry.Key), []byte(strconv.FormatInt(field.Expiration.UnixMilli(), 10)), []byte("FIELDS"), []byte("1"), []byte(field.Field)}
+				buf = encodeAOFCommand(buf, argv)
+			}
+		case store.TypeRateMeter:
+			// the whole state, RATE.MARK of the count would show every past event as a burst at load time
+			meter := entry.Meter
+			initialized := "0"
+			if meter.Initialized {
+				initialized = "1"
+			}
+			argv := [][]byte{
+				[]byte("RATE.RESTORE"), []byte(entry.Key),
+				[]byte(strconv.FormatInt(meter.Count, 10)), []byte(strconv.FormatInt(meter.Uncounted, 10)),
+				[]byte(strconv.FormatFloat(meter.Rates[0], 'g', -1, 64)),
+				[]byte(strconv.FormatFloat(meter.Rates[1], 'g', -1, 64)),
+				[]byte(strconv.FormatFloat(meter.Rates[2], 'g', -1, 64)),
+				[]byte(initialized),
+				[]byte(strconv.FormatInt(meter.LastTick.UnixMilli(), 10)), []byte(strconv.FormatInt(meter.Created.UnixMilli(), 10)),
+			}
+			buf = encodeAOFCommand(buf, argv)
+		case store.TypeIDGen:
+			lastID := strconv.FormatInt(entry.IDGen.LastMs, 10) + "-" + strconv.Itoa(entry.IDGen.LastSeq)
+			argv := [][]byte{[]byte("SETLASTID"), []byte(entry.Key), []byte(lastID)}
+			buf = encodeAOFCommand(buf, argv)
+		case store.TypeStream:
+			for _, record := range entry.Records {
+				argv := [][]byte{[]byte("XADD"), []byte(entry.Key), []byte(record.Id)}
+				for _, field := range sortedFields(record.Data) {
+					argv = append(argv, []byte(field), record.Data[field])
+				}
+				buf = encodeAOFCommand(buf, argv)
+			}
+			// there is no command to set the last ID alone, so add an entry at it and delete it again, which also keeps
+			// a stream that has no entries left
+			if entry.LastId != "0-0" && (len(entry.Records) == 0 || entry.Records[len(entry.Records)-1].Id != entry.LastId) {
+				buf = encodeAOFCommand(buf, [][]byte{[]byte("XADD"), []byte(entry.Key), []byte(entry.LastId), []byte(""), []byte("")})
+				buf = encodeAOFCommand(buf, [][]byte{[]byte("XDEL"), []byte(entry.Key), []byte(entry.LastId)})
+			}
+			for _, group := range entry.Groups {
+				buf = appendStreamGroup(buf, entry.Key, group)
+			}
+		}
+
+		// an absolute time, so the key expires when it should however long it takes to replay the file
+		if !entry.Expiration.IsZero() {
+			argv := [][]byte{[]byte("PEXPIREAT"), []byte(entry.Key), []byte(strconv.FormatInt(entry.Expiration.UnixMilli(), 10))}
+			buf = encodeAOFCommand(buf, argv)
+		}
+	}
+
+	tmpPath := path + ".tmp"
+	f, err := os.Create(tmpPath)
+	if err != nil {
+		return fmt.Errorf("failed to create the AOF base: %w", err)
+	}
+
+	_, err = f.Write(buf)
+	if err == nil {
+		err = f.Sync()
+	}
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(tmpPath)
+		return fmt.Errorf("failed to write the AOF base: %w", err)
+	}
+
+	if err := os.Rename(tmpPath, path); err != nil {
+		os.Remove(tmpPath)
+		return fmt.Errorf("failed to rename the AOF base: %w", err)
+	}
+
+	return nil
+}
+
+/*
+ 	* encodeAOFVariadic appends the commands that add elements to a key, splitting them
+	* into commands of at most aofRewriteItemsPerCmd elements like Redis does
+	* @param buf []byte - the buffer to append to
+	* @param cmd string - the command, like RPUSH
+	* @param key string - the key
+	* @param elements [][]byte - the arguments after the key
+	* @return []byte - the buffer
+*/
+func encodeAOFVariadic(buf []byte, cmd, key string, elements [][]byte) []byte {
+	for start := 0; start < len(elements); start += aofRewriteItemsPerCmd {
+		end := min(start+aofRewriteItemsPerCmd, len(elements))
+
+		argv := [][]byte{[]byte(cmd), []byte(key)}
+		argv = append(argv, elements[start:end]...)
+		buf = encodeAOFCommand(buf, argv)
+	}
+	return buf
+}
+
+/*
+ 	* appendStreamGroup appends the commands that rebuild a consumer group, XGROUP CREATE at its last delivered ID,
+	* XGROUP CREATECONSUMER for each consumer and a forced XCLAIM for each pending entry that keeps its delivery time
+	* and count
+	* @param buf []byte - the buffer to append to
+	* @param key string - the key of the stream
+	* @param group store.StreamGroupSnapshot - the consumer group
+	* @return []byte - the buffer
+*/
+func appendStreamGroup(buf []byte, key string, group store.StreamGroupSnapshot) []byte {
+	buf = encodeAOFCommand(buf, [][]byte{[]byte("XGROUP"), []byte("CREATE"), []byte(key), []byte(group.Name), []byte(group.LastId), []byte("MKSTREAM")})
+
+	for _, consumer := range group.Consumers {
+		buf = encodeAOFCommand(buf, [][]byte{[]byte("XGROUP"), []byte("CREATECONSUMER"), []byte(key), []byte(group.Name), []byte(consumer.Name)})
+	}
+
+	for _, pending := range group.Pending {
+		argv := [][]byte{
+			[]byte("XCLAIM"), []byte(key), []byte(group.Name), []byte(pending.Consumer), []byte("0"), []byte(pending.Id),
+			[]byte("TIME"), []byte(strconv.FormatInt(pending.DeliveryTime.UnixMilli(), 10)),
+			[]byte("RETRYCOUNT"), []byte(strconv.FormatInt(pending.DeliveryCount, 10)),
+			[]byte("FORCE"), []byte("JUSTID"),
+		}
+		buf = encodeAOFCommand(buf, argv)
+	}
+
+	return buf
+}
+
+/*
+ 	* encodeAOFCommand appends the command to buf as a RESP array of bulk strings
+	* @param buf []byte - the buffer to append to
+	* @param argv [][]byte - the command name followed by its arguments
+	* @return []byte - the buffer
+*/
+func encodeAOFCommand(buf []byte, argv [][]byte) []byte {
+	buf = append(buf, '*')
+	buf = strconv.AppendInt(buf, int64(len(argv)), 10)
+	buf = append(buf, '\r', '\n')
+
+	for _, arg := range argv {
+		buf = append(buf, '$')
+		buf = strconv.AppendInt(buf, int64(len(arg)), 10)
+		buf = append(buf, '\r', '\n')
+		buf = append(buf, arg...)
+		buf = append(buf, '\r', '\n')
+	}
+
+	return buf
+}
+
+/*
+ 	* AOFExists checks if there is an append only file to load
+	* @return bool - true if AOF is enabled and the file exists
+*/
+func AOFExists() bool {
+	aofConfig := GetAOFConfig()
+	if !aofConfig.Enabled {
+		return false
+	}
+
+	dir, _ := GetConfig()
+	_, err := os.Stat(filepath.Join(dir, aofConfig.Filename))
+	return err == nil
+}
+
+/*
+ 	* LoadAOF reads every command of the append only file.
+	* if the last command is cut short, or a MULTI is never closed by an EXEC, the file was truncated by a crash.
+	* with aof-load-truncated the file is cut back to the last complete command and loading continues, otherwise it fails
+	* @return [][][]byte - the commands, each one the command name followed by its arguments
+	* @return error - the error if there is one
+*/
+func LoadAOF() ([][][]byte, error) {
+	aofConfig := GetAOFConfig()
+	dir, _ := GetConfig()
+	path := filepath.Join(dir, aofConfig.Filename)
+
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+	r := bufio.NewReader(f)
+
+	var commands [][][]byte
+	var offset int64      // end of the last complete command
+	var validOffset int64 // end of the last command that is not part of an open MULTI
+	validCommands := 0    // commands up to validOffset
+	inMulti := false
+	truncated := false
+
+	for {
+		argv, n, err := readAOFCommand(r, info.Size()-offset)
+		if err == io.EOF {
+			break
+		}
+		if err != nil && err != io.ErrUnexpectedEOF {
+			return nil, fmt.Errorf("%w at offset %d: %v", ErrAOFInvalidFormat, offset, err)
+		}
+		if err == io.ErrUnexpectedEOF {
+			truncated = true
+			break
+		}
+
+		offset += n
+		commands = append(commands, argv)
+
+		switch strings.ToUpper(string(argv[0])) {
+		case "MULTI":
+			inMulti = true
+		case "EXEC":
+			inMulti = false
+		}
+
+		if !inMulti {
+			validOffset = offset
+			validCommands = len(commands)
+		}
+	}
+
+	if !truncated && !inMulti {
+		return commands, nil
+	}
+
+	if !aofConfig.LoadTruncated {
+		return nil, ErrAOFTruncated
+	}
+
+	log.Printf("!!! Warning: short read while loading the AOF file %s !!!", path)
+	log.Printf("AOF %s loaded anyway, truncating it to the last valid command at offset %d", path, validOffset)
+	if err := os.Truncate(path, validOffset); err != nil {
+		return nil, fmt.Errorf("failed to truncate the AOF file: %w", err)
+	}
+
+	return commands[:validCommands], nil
+}
+
+/*
+ 	* readAOFCommand reads one RESP array of bulk strings
+	* @param r *bufio.Reader - the reader to read the AOF from
+	* @param remaining int64 - the bytes left in the file, nothing larger is allocated for a length read from it
+	* @return [][]byte - the command name followed by its arguments
+	* @return int64 - the number of bytes the command took in the file
+	* @return error - io.EOF at a clean end of file, io.ErrUnexpectedEOF if the file ends mid command
+*/
+func readAOFCommand(r *bufio.Reader, remaining int64) ([][]byte, int64, error) {
+	var n int64
+
+	readLine := func(prefix byte) (int, error) {
+		line, err := r.ReadBytes('\n')
+		n += int64(len(line))
+		if err == io.EOF {
+			if len(line) == 0 && n == 0 {
+				return 0, io.EOF
+			}
+			return 0, io.ErrUnexpectedEOF
+		}
+		if err != nil {
+			return 0, err
+		}
+		if len(line) < 3 || line[0] != prefix || !bytes.HasSuffix(line, []byte("\r\n")) {
+			return 0, fmt.Errorf("expected '%c', got %q", prefix, line)
+		}
+		return strconv.Atoi(string(line[1 : len(line)-2]))
+	}
+
+	argc, err := readLine('*')
+	if err != nil {
+		return nil, n, err
+	}
+	if argc < 1 {
+		return nil, n, fmt.Errorf("invalid number of arguments %d", argc)
+	}
+	// every argument takes at least 6 bytes, "$0\r\n\r\n", more than the file holds means it ends mid command
+	if int64(argc) > (remaining-n)/6 {
+		return nil, n, io.ErrUnexpectedEOF
+	}
+
+	argv := make([][]byte, argc)
+	for i := range argv {
+		l, err := readLine('$')
+		if err != nil {
+			return nil, n, err
+		}
+		if l < 0 || l > aofMaxBulkLen {
+			return nil, n, fmt.Errorf("invalid bulk length %d", l)
+		}
+		if int64(l)+2 > remaining-n {
+			return nil, n, io.ErrUnexpectedEOF
+		}
+
+		arg := make([]byte, l+2)
+		read, err := io.ReadFull(r, arg)
+		n += int64(read)
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			return nil, n, io.ErrUnexpectedEOF
+		}
+		if err != nil {
+			return nil, n, err
+		}
+		if !bytes.HasSuffix(arg, []byte("\r\n")) {
+			return nil, n, fmt.Errorf("bulk string not terminated by CRLF")
+		}
+
+		argv[i] = arg[:l]
+	}
+
+	return argv, n, nil
+}
@@ -0,0 +1,155 @@
+package persistence
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func aofCommands(commands ...string) string {
+	var buf []byte
+	for _, command := range commands {
+		var argv [][]byte
+		for _, arg := range strings.Fields(command) {
+			argv = append(argv, []byte(arg))
+		}
+		buf = encodeAOFCommand(buf, argv)
+	}
+	return string(buf)
+}
+
+func TestLoadAOFTruncatedTail(t *testing.T) {
+	complete := aofCommands("SET a 1", "SET b 2")
+
+	tests := []struct {
+		name          string
+		contents      string
+		loadTruncated bool
+		wantCommands  []string
+		wantSize      int // the size of the file after loading
+		wantErr       error
+	}{
+		{
+			name:         "complete",
+			contents:     complete,
+			wantCommands: []string{"SET a 1", "SET b 2"},
+			wantSize:     len(complete),
+		},
+		{
+			name:          "cut in a bulk string",
+			contents:      complete + "*3\r\n$3\r\nSET\r\n$1\r\nc\r\n$1\r\n",
+			loadTruncated: true,
+			wantCommands:  []string{"SET a 1", "SET b 2"},
+			wantSize:      len(complete),
+		},
+		{
+			name:          "cut in a length line",
+			contents:      complete + "*3\r\n$3",
+			loadTruncated: true,
+			wantCommands:  []string{"SET a 1", "SET b 2"},
+			wantSize:      len(complete),
+		},
+		{
+			name:          "cut before the CRLF of the last argument",
+			contents:      complete + "*2\r\n$3\r\nDEL\r\n$1\r\na",
+			loadTruncated: true,
+			wantCommands:  []string{"SET a 1", "SET b 2"},
+			wantSize:      len(complete),
+		},
+		{
+			name:          "MULTI never closed",
+			contents:      complete + aofCommands("MULTI", "SET c 3"),
+			loadTruncated: true,
+			wantCommands:  []string{"SET a 1", "SET b 2"},
+			wantSize:      len(complete),
+		},
+		{
+			name:          "MULTI closed",
+			contents:      complete + aofCommands("MULTI", "SET c 3", "EXEC"),
+			loadTruncated: true,
+			wantCommands:  []string{"SET a 1", "SET b 2", "MULTI", "SET c 3", "EXEC"},
+			wantSize:      len(complete + aofCommands("MULTI", "SET c 3", "EXEC")),
+		},
+		{
+			name:     "truncated without aof-load-truncated",
+			contents: complete + "*1\r\n$4\r\nPI",
+			wantErr:  ErrAOFTruncated,
+			wantSize: len(complete + "*1\r\n$4\r\nPI"),
+		},
+		{
+			name:          "negative bulk length",
+			contents:      complete + "*2\r\n$3\r\nGET\r\n$-3\r\nabc\r\n",
+			loadTruncated: true,
+			wantErr:       ErrAOFInvalidFormat,
+			wantSize:      len(complete + "*2\r\n$3\r\nGET\r\n$-3\r\nabc\r\n"),
+		},
+		{
+			name:          "bulk length past proto-max-bulk-len",
+			contents:      complete + "*2\r\n$3\r\nGET\r\n$9999999999\r\nabc\r\n",
+			loadTruncated: true,
+			wantErr:       ErrAOFInvalidFormat,
+			wantSize:      len(complete + "*2\r\n$3\r\nGET\r\n$9999999999\r\nabc\r\n"),
+		},
+		{
+			name:          "bulk length past the end of the file",
+			contents:      complete + "*2\r\n$3\r\nGET\r\n$536870912\r\nabc\r\n",
+			loadTruncated: true,
+			wantCommands:  []string{"SET a 1", "SET b 2"},
+			wantSize:      len(complete),
+		},
+		{
+			name:          "more arguments than the file holds",
+			contents:      complete + "*2000000000\r\n$3\r\nGET\r\n",
+			loadTruncated: true,
+			wantCommands:  []string{"SET a 1", "SET b 2"},
+			wantSize:      len(complete),
+		},
+		{
+			name:          "garbage is not a truncation",
+			contents:      complete + "hello\r\n",
+			loadTruncated: true,
+			wantErr:       ErrAOFInvalidFormat,
+			wantSize:      len(complete + "hello\r\n"),
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			dir := t.TempDir()
+			InitConfig(dir, "dump.rdb")
+			InitAOFConfig(AOFConfig{Enabled: true, Filename: "appendonly.aof", Fsync: FsyncNo, LoadTruncated: test.loadTruncated})
+
+			path := filepath.Join(dir, "appendonly.aof")
+			if err := os.WriteFile(path, []byte(test.contents), 0644); err != nil {
+				t.Fatal(err)
+			}
+
+			commands, err := LoadAOF()
+			if !errors.Is(err, test.wantErr) {
+				t.Fatalf("LoadAOF() error = %v, want %v", err, test.wantErr)
+			}
+
+			var got []string
+			for _, argv := range commands {
+				args := make([]string, len(argv))
+				for i, arg := range argv {
+					args[i] = string(arg)
+				}
+				got = append(got, strings.Join(args, " "))
+			}
+			if strings.Join(got, "|") != strings.Join(test.wantCommands, "|") {
+				t.Errorf("LoadAOF() = %q, want %q", got, test.wantCommands)
+			}
+
+			info, err := os.Stat(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if info.Size() != int64(test.wantSize) {
+				t.Errorf("the file is %d bytes after loading, want %d", info.Size(), test.wantSize)
+			}
+		})
+	}
+}
@@ -1,15 +1,50 @@
 package persistence
 
-import "sync"
+import (
+	"sync"
+
+	store "github.com/manish-singh-bisht/Redis-From-Scratch/db/store"
+)
+
+type AOFConfig struct {
+	Enabled       bool   // appendonly
+	Filename      string // appendfilename, relative to dir
+	Fsync         string // appendfsync, one of always, everysec or no
+	LoadTruncated bool   // aof-load-truncated, load an AOF whose last command was cut short instead of failing
+}
+
+type KeyTraceConfig struct {
+	Filename string // keytrace-file, relative to dir
+	MaxSize  int64  // keytrace-max-size, the file is rotated once it would grow past this many bytes
+	MaxFiles int    // keytrace-max-files, how many rotated files are kept besides the current one
+}
 
 var (
 	mu     sync.RWMutex
 	config = struct {
-		dir        string
-		dbFilename string
+		dir                string
+		dbFilename         string
+		aof                AOFConfig
+		keyTrace           KeyTraceConfig
+		hz                 int // how many times per second background tasks like the active expire cycle run
+		activeExpireEffort int // 1 to 10, how much work the active expire cycle does
+		nodeID             int // embedded in snowflake IDs handed out by NEXTID, so servers don't hand out the same IDs
 	}{
-		dir:        ".",
-		dbFilename: "dump.rdb",
+		dir:                ".",
+		dbFilename:         "dump.rdb",
+		hz:                 store.DefaultHz,
+		activeExpireEffort: store.DefaultActiveExpireEffort,
+		aof: AOFConfig{
+			Enabled:       false,
+			Filename:      "appendonly.aof",
+			Fsync:         FsyncEverySec,
+			LoadTruncated: true,
+		},
+		keyTrace: KeyTraceConfig{
+			Filename: "keytrace.log",
+			MaxSize:  64 * 1024 * 1024,
+			MaxFiles: 5,
+		},
 	}
 )
 
@@ -25,3 +60,52 @@ func GetConfig() (string, string) {
 	defer mu.RUnlock()
 	return config.dir, config.dbFilename
 }
+
+func InitAOFConfig(aofConfig AOFConfig) {
+	mu.Lock()
+	defer mu.Unlock()
+	config.aof = aofConfig
+}
+
+func GetAOFConfig() AOFConfig {
+	mu.RLock()
+	defer mu.RUnlock()
+	return config.aof
+}
+
+func InitKeyTraceConfig(keyTraceConfig KeyTraceConfig) {
+	mu.Lock()
+	defer mu.Unlock()
+	config.keyTrace = keyTraceConfig
+}
+
+func GetKeyTraceConfig() KeyTraceConfig {
+	mu.RLock()
+	defer mu.RUnlock()
+	return config.keyTrace
+}
+
+func InitExpireConfig(hz, activeExpireEffort int) {
+	mu.Lock()
+	defer mu.Unlock()
+	config.hz = hz
+	config.activeExpireEffort = activeExpireEffort
+}
+
+func GetExpireConfig() (int, int) {
+	mu.RLock()
+	defer mu.RUnlock()
+	return config.hz, config.activeExpireEffort
+}
+
+func InitNodeID(nodeID int) {
+	mu.Lock()
+	defer mu.Unlock()
+	config.nodeID = nodeID
+}
+
+func GetNodeID() int {
+	mu.RLock()
+	defer mu.RUnlock()
+	return config.nodeID
+}
@@ -0,0 +1,33 @@
+package persistence
+
+// Redis checksums RDB files with the reflected Jones CRC-64 (no initial or final xor), which hash/crc64 can't express
+const crc64JonesPoly = 0x95ac9329ac4bc9b5
+
+var crc64Table = func() [256]uint64 {
+	var table [256]uint64
+	for i := range table {
+		crc := uint64(i)
+		for j := 0; j < 8; j++ {
+			if crc&1 == 1 {
+				crc = (crc >> 1) ^ crc64JonesPoly
+			} else {
+				crc >>= 1
+			}
+		}
+		table[i] = crc
+	}
+	return table
+}()
+
+/*
+ 	* crc64 updates the running checksum with p
+	* @param crc uint64 - the checksum so far, 0 to start
+	* @param p []byte - the bytes to add
+	* @return uint64 - the updated checksum
+*/
+func crc64(crc uint64, p []byte) uint64 {
+	for _, b := range p {
+		crc = crc64Table[byte(crc)^b] ^ (crc >> 8)
+	}
+	return crc
+}
@@ -0,0 +1,100 @@
+package persistence
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// the key trace log is a JSON-lines file written by KEYTRACE, it is opened on the first record so a server that never
+// traces anything never creates it. once a record would take it past keytrace-max-size it is renamed to .1, the older
+// ones shift to .2, .3 and so on, and the one past keytrace-max-files is deleted
+type keyTraceLog struct {
+	mu   sync.Mutex
+	file *os.File
+	size int64
+}
+
+var keyTraceInstance keyTraceLog
+
+/*
+ 	* AppendKeyTrace appends a record to the key trace log, rotating it first when it is full
+	* @param record []byte - the record, a line of JSON ending with a newline
+	* @return error - the error if there is one
+*/
+func AppendKeyTrace(record []byte) error {
+	k := &keyTraceInstance
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	keyTraceConfig := GetKeyTraceConfig()
+	dir, _ := GetConfig()
+	path := filepath.Join(dir, keyTraceConfig.Filename)
+
+	if k.file == nil {
+		if err := k.open(path); err != nil {
+			return err
+		}
+	}
+
+	if k.size > 0 && k.size+int64(len(record)) > keyTraceConfig.MaxSize {
+		if err := k.rotate(path, keyTraceConfig.MaxFiles); err != nil {
+			return err
+		}
+	}
+
+	n, err := k.file.Write(record)
+	k.size += int64(n)
+	if err != nil {
+		return fmt.Errorf("failed to write to the key trace log: %w", err)
+	}
+	return nil
+}
+
+/*
+ 	* open opens the key trace log for appending, picking up the size of what is already there
+	* @param path string - the path of the log
+	* @return error - the error if there is one
+*/
+func (k *keyTraceLog) open(path string) error {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to open the key trace log: %w", err)
+	}
+
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return fmt.Errorf("failed to stat the key trace log: %w", err)
+	}
+
+	k.file = f
+	k.size = info.Size()
+	return nil
+}
+
+/*
+ 	* rotate shifts the rotated logs up by one, deleting the oldest, and starts a new log
+	* @param path string - the path of the log
+	* @param maxFiles int - how many rotated logs to keep
+	* @return error - the error if there is one
+*/
+func (k *keyTraceLog) rotate(path string, maxFiles int) error {
+	k.file.Close()
+	k.file = nil
+
+	if maxFiles < 1 {
+		os.Remove(path)
+	} else {
+		os.Remove(fmt.Sprintf("%s.%d", path, maxFiles))
+		for i := maxFiles - 1; i >= 1; i-- {
+			os.Rename(fmt.Sprintf("%s.%d", path, i), fmt.Sprintf("%s.%d", path, i+1))
+		}
+		if err := os.Rename(path, path+".1"); err != nil {
+			return fmt.Errorf("failed to rotate the key trace log: %w", err)
+		}
+	}
+
+	return k.open(path)
+}
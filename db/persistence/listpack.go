@@ -0,0 +1,265 @@
+package persistence
+
+import (
+	"encoding/binary"
+	"errors"
+	"strconv"
+)
+
+var ErrInvalidListpack = errors.New("invalid listpack")
+
+// listpack is the serialization Redis uses inside RDB files for small aggregates like stream nodes.
+// layout: <total-bytes uint32><num-elements uint16><element>...<0xFF>
+// every element is <encoding+data><backlen>, backlen lets Redis walk the list from the tail, we only walk it forward.
+const (
+	lpHeaderSize  = 6
+	lpEOF         = 0xFF
+	lpUnknownSize = 0xFFFF
+)
+
+type listpackWriter struct {
+	buf   []byte
+	count int
+}
+
+func newListpackWriter() *listpackWriter {
+	return &listpackWriter{
+		buf: make([]byte, lpHeaderSize),
+	}
+}
+
+/*
+ 	* appendInt appends an integer element using the smallest integer encoding that fits
+	* @param v int64 - the integer to append
+*/
+func (lp *listpackWriter) appendInt(v int64) {
+	var entry []byte
+
+	switch {
+	case v >= 0 && v <= 127:
+		entry = []byte{byte(v)} // 0xxxxxxx
+	case v >= -4096 && v <= 4095:
+		u := uint16(v) & 0x1FFF
+		entry = []byte{0xC0 | byte(u>>8), byte(u)} // 110xxxxx yyyyyyyy
+	case v >= -32768 && v <= 32767:
+		entry = []byte{0xF1, 0, 0}
+		binary.LittleEndian.PutUint16(entry[1:], uint16(v))
+	case v >= -8388608 && v <= 8388607:
+		u := uint32(v)
+		entry = []byte{0xF2, byte(u), byte(u >> 8), byte(u >> 16)}
+	case v >= -2147483648 && v <= 2147483647:
+		entry = []byte{0xF3, 0, 0, 0, 0}
+		binary.LittleEndian.PutUint32(entry[1:], uint32(v))
+	default:
+		entry = make([]byte, 9)
+		entry[0] = 0xF4
+		binary.LittleEndian.PutUint64(entry[1:], uint64(v))
+	}
+
+	lp.appendEntry(entry)
+}
+
+/*
+ 	* appendString appends a string element, strings that look like integers are stored as integers the way Redis does
+	* @param s []byte - the string to append
+*/
+func (lp *listpackWriter) appendString(s []byte) {
+	if v, err := strconv.ParseInt(string(s), 10, 64); err == nil && strconv.FormatInt(v, 10) == string(s) {
+		lp.appendInt(v)
+		return
+	}
+
+	var entry []byte
+	l := len(s)
+
+	switch {
+	case l < 64:
+		entry = append([]byte{0x80 | byte(l)}, s...) // 10xxxxxx
+	case l < 4096:
+		entry = append([]byte{0xE0 | byte(l>>8), byte(l)}, s...) // 1110xxxx yyyyyyyy
+	default:
+		entry = make([]byte, 5, 5+l)
+		entry[0] = 0xF0
+		binary.LittleEndian.PutUint32(entry[1:], uint32(l))
+		entry = append(entry, s...)
+	}
+
+	lp.appendEntry(entry)
+}
+
+func (lp *listpackWriter) appendEntry(entry []byte) {
+	lp.buf = append(lp.buf, entry...)
+	lp.buf = append(lp.buf, encodeBacklen(len(entry))...)
+	lp.count++
+}
+
+/*
+ 	* bytes finishes the listpack by writing the terminator and the header
+	* @return []byte - the serialized listpack
+*/
+func (lp *listpackWriter) bytes() []byte {
+	out := append(lp.buf, lpEOF)
+
+	binary.LittleEndian.PutUint32(out[0:4], uint32(len(out)))
+	if lp.count < lpUnknownSize {
+		binary.LittleEndian.PutUint16(out[4:6], uint16(lp.count))
+	} else {
+		binary.LittleEndian.PutUint16(out[4:6], lpUnknownSize)
+	}
+
+	return out
+}
+
+/*
+ 	* encodeBacklen encodes the length of an element so that it can be read from right to left
+	* @param l int - the length of the element's encoding and data
+	* @return []byte - the encoded length
+*/
+func encodeBacklen(l int) []byte {
+	switch {
+	case l <= 127:
+		return []byte{byte(l)}
+	case l < 16383:
+		return []byte{byte(l >> 7), byte(l&127) | 128}
+	case l < 2097151:
+		return []byte{byte(l >> 14), byte((l>>7)&127) | 128, byte(l&127) | 128}
+	case l < 268435455:
+		return []byte{byte(l >> 21), byte((l>>14)&127) | 128, byte((l>>7)&127) | 128, byte(l&127) | 128}
+	default:
+		return []byte{byte(l >> 28), byte((l>>21)&127) | 128, byte((l>>14)&127) | 128, byte((l>>7)&127) | 128, byte(l&127) | 128}
+	}
+}
+
+/*
+ 	* backlenSize returns how many bytes the backlen of an element of the given length occupies
+	* @param l int - the length of the element's encoding and data
+	* @return int - the size of the backlen
+*/
+func backlenSize(l int) int {
+	return len(encodeBacklen(l))
+}
+
+/*
+ 	* decodeListpack decodes every element of a listpack, integers are returned in their decimal string form
+	* @param buf []byte - the serialized listpack
+	* @return [][]byte - the elements
+	* @return error - the error if there is one
+*/
+func decodeListpack(buf []byte) ([][]byte, error) {
+	if len(buf) < lpHeaderSize+1 || int(binary.LittleEndian.Uint32(buf[0:4])) != len(buf) {
+		return nil, ErrInvalidListpack
+	}
+
+	var elements [][]byte
+	pos := lpHeaderSize
+
+	for pos < len(buf) && buf[pos] != lpEOF {
+		element, size, err := decodeListpackElement(buf[pos:])
+		if err != nil {
+			return nil, err
+		}
+
+		pos += size + backlenSize(size)
+		if pos > len(buf) {
+			return nil, ErrInvalidListpack
+		}
+		elements = append(elements, element)
+	}
+
+	if pos >= len(buf) {
+		return nil, ErrInvalidListpack
+	}
+
+	return elements, nil
+}
+
+/*
+ 	* decodeListpackElement decodes the element at the start of buf
+	* @param buf []byte - the listpack bytes starting at an element
+	* @return []byte - the element, integers in decimal string form
+	* @return int - the size of the element's encoding and data, without the backlen
+	* @return error - the error if there is one
+*/
+func decodeListpackElement(buf []byte) ([]byte, int, error) {
+	b := buf[0]
+
+	var v int64
+	var size int
+
+	switch {
+	case b&0x80 == 0: // 7 bit unsigned int
+		return []byte(strconv.Itoa(int(b & 0x7F))), 1, nil
+
+	case b&0xC0 == 0x80: // 6 bit length string
+		l := int(b & 0x3F)
+		return sliceListpackString(buf, 1, l)
+
+	case b&0xE0 == 0xC0: // 13 bit signed int
+		if len(buf) < 2 {
+			return nil, 0, ErrInvalidListpack
+		}
+		u := uint16(b&0x1F)<<8 | uint16(buf[1])
+		v = int64(u)
+		if u >= 1<<12 {
+			v -= 1 << 13
+		}
+		size = 2
+
+	case b&0xF0 == 0xE0: // 12 bit length string
+		if len(buf) < 2 {
+			return nil, 0, ErrInvalidListpack
+		}
+		l := int(b&0x0F)<<8 | int(buf[1])
+		return sliceListpackString(buf, 2, l)
+
+	case b == 0xF0: // 32 bit length string
+		if len(buf) < 5 {
+			return nil, 0, ErrInvalidListpack
+		}
+		l := int(binary.LittleEndian.Uint32(buf[1:5]))
+		return sliceListpackString(buf, 5, l)
+
+	case b == 0xF1:
+		if len(buf) < 3 {
+			return nil, 0, ErrInvalidListpack
+		}
+		v = int64(int16(binary.LittleEndian.Uint16(buf[1:3])))
+		size = 3
+
+	case b == 0xF2:
+		if len(buf) < 4 {
+			return nil, 0, ErrInvalidListpack
+		}
+		u := uint32(buf[1]) | uint32(buf[2])<<8 | uint32(buf[3])<<16
+		v = int64(int32(u<<8) >> 8) // sign extend the 24 bits
+		size = 4
+
+	case b == 0xF3:
+		if len(buf) < 5 {
+			return nil, 0, ErrInvalidListpack
+		}
+		v = int64(int32(binary.LittleEndian.Uint32(buf[1:5])))
+		size = 5
+
+	case b == 0xF4:
+		if len(buf) < 9 {
+			return nil, 0, ErrInvalidListpack
+		}
+		v = int64(binary.LittleEndian.Uint64(buf[1:9]))
+		size = 9
+
+	default:
+		return nil, 0, ErrInvalidListpack
+	}
+
+	return []byte(strconv.FormatInt(v, 10)), size, nil
+}
+
+func sliceListpackString(buf []byte, headerLen, l int) ([]byte, int, error) {
+	if len(buf) < headerLen+l {
+		return nil, 0, ErrInvalidListpack
+	}
+	s := make([]byte, l)
+	copy(s, buf[headerLen:headerLen+l])
+	return s, headerLen + l, nil
+}
@@ -5,9 +5,13 @@ import (
 	"encoding/binary"
 	"errors"
 	"fmt"
+	"io"
 	"log"
 	"os"
+	"strconv"
 	"time"
+
+	store "github.com/manish-singh-bisht/Redis-From-Scratch/db/store"
 )
 
 var ErrInvalidHeader = errors.New("invalid RDB header")
@@ -15,14 +19,34 @@ var ErrInvalidMetadata = errors.New("invalid RDB metadata")
 var ErrInvalidDatabase = errors.New("invalid RDB database")
 
 const (
-	RDB_METADATA   = 0xFA // Metadata
-	RDB_DB_START   = 0xFE // Database selector
-	RDB_DB_SIZE    = 0xFB // Hash table sizes
-	RDB_STRING     = 0x00
-	RDB_EXPIRES_MS = 0xFC // Expire time MS
-	RDB_EXPIRES_S  = 0xFD // Expire time S
-	RDB_EOF        = 0xFF // End of file
-	RDB_MODULE_AUX = 0xF7 // Module auxiliary data
+	RDB_METADATA           = 0xFA // Metadata
+	RDB_DB_START           = 0xFE // Database selector
+	RDB_DB_SIZE            = 0xFB // Hash table sizes
+	RDB_STRING             = 0x00
+	RDB_LIST               = 0x01 // List, plain sequence of strings
+	RDB_SET                = 0x02 // Set, plain sequence of members
+	RDB_ZSET               = 0x03 // Sorted set, members with their scores as strings
+	RDB_HASH               = 0x04 // Hash, plain sequence of field-value pairs
+	RDB_ZSET_2             = 0x05 // Sorted set, members with their scores as binary doubles
+	RDB_MODULE_2           = 0x07 // Module value, used for the types Redis doesn't have
+	RDB_SET_INTSET         = 0x0B // Set, intset of sorted integers
+	RDB_STREAM_LISTPACKS   = 0x0F // Stream, radix tree of listpacks
+	RDB_HASH_LISTPACK      = 0x10 // Hash, a single listpack of field-value pairs
+	RDB_ZSET_LISTPACK      = 0x11 // Sorted set, a single listpack of member-score pairs
+	RDB_LIST_QUICKLIST_2   = 0x12 // List, quicklist of listpacks (Redis 7.0)
+	RDB_SET_LISTPACK       = 0x14 // Set, a single listpack of members (Redis 7.2)
+	RDB_STREAM_LISTPACKS_2 = 0x13 // Stream, with first ID, max deleted ID and entries added (Redis 7.0)
+	RDB_STREAM_LISTPACKS_3 = 0x15 // Stream, with consumer active time (Redis 7.2)
+	RDB_HASH_METADATA      = 0x18 // Hash, with the expiration of each field (Redis 7.4)
+	RDB_EXPIRES_MS         = 0xFC // Expire time MS
+	RDB_EXPIRES_S          = 0xFD // Expire time S
+	RDB_EOF                = 0xFF // End of file
+	RDB_MODULE_AUX         = 0xF7 // Module auxiliary data
+)
+
+const (
+	RDB_LEN_32BIT = 0x80 // length stored in the next 4 bytes
+	RDB_LEN_64BIT = 0x81 // length stored in the next 8 bytes
 )
 
 type rdbParser struct {
@@ -33,9 +57,24 @@ type rdbParser struct {
 	expireTableSize uint64
 }
 type ParsedKeyValue struct {
-	Key       string
-	Value     []byte
-	ExpiresIn time.Duration
+	Key          string
+	Type         byte // RDB value type, RDB_STRING or one of the stream types
+	Value        []byte
+	Elements     [][]byte // elements of a list, head first, or members of a set
+	Fields       []store.HashField
+	ZMembers     []store.ZMember
+	Stream       []ParsedStreamEntry
+	StreamLastId string // the greatest ID ever added to the stream
+	StreamGroups []store.StreamGroupSnapshot
+	Module       string // for RDB_MODULE_2, the store type of the value
+	Meter        store.RateMeterSnapshot
+	IDGen        store.IDGeneratorSnapshot
+	ExpiresIn    time.Duration
+}
+
+type ParsedStreamEntry struct {
+	Id   string
+	Data map[string][]byte
 }
 
 func newRDBParser() *rdbParser {
@@ -149,9 +188,9 @@ func (p *rdbParser) parseDatabase(r *bufio.Reader) ([]ParsedKeyValue, error) {
 		}
 
 		switch b {
-		case RDB_STRING:
+		case RDB_STRING, RDB_LIST, RDB_LIST_QUICKLIST_2, RDB_SET, RDB_SET_INTSET, RDB_SET_LISTPACK, RDB_ZSET, RDB_ZSET_2, RDB_ZSET_LISTPACK, RDB_HASH, RDB_HASH_LISTPACK, RDB_HASH_METADATA, RDB_MODULE_2, RDB_STREAM_LISTPACKS, RDB_STREAM_LISTPACKS_2, RDB_STREAM_LISTPACKS_3:
 			log.Println("Adding new key-value pair")
-			kv, err := p.addKeyValue(r)
+			kv, err := p.readKeyValue(b, r)
 			if err != nil {
 				return nil, err
 			}
@@ -176,6 +215,34 @@ func (p *rdbParser) parseDatabase(r *bufio.Reader) ([]ParsedKeyValue, error) {
 	}
 }
 
+/*
+ 	* readKeyValue reads a key and its value of the given type.
+	* @param valueType byte - the RDB value type
+	* @param r *bufio.Reader - the reader to read the RDB file from
+	* @return ParsedKeyValue - the parsed key-value pair
+	* @return error - the error if there is one
+*/
+func (p *rdbParser) readKeyValue(valueType byte, r *bufio.Reader) (ParsedKeyValue, error) {
+	switch valueType {
+	case RDB_STRING:
+		return p.addKeyValue(r)
+	case RDB_LIST, RDB_LIST_QUICKLIST_2:
+		return p.addList(valueType, r)
+	case RDB_SET, RDB_SET_INTSET, RDB_SET_LISTPACK:
+		return p.addSet(valueType, r)
+	case RDB_ZSET, RDB_ZSET_2, RDB_ZSET_LISTPACK:
+		return p.addZSet(valueType, r)
+	case RDB_HASH, RDB_HASH_LISTPACK, RDB_HASH_METADATA:
+		return p.addHash(valueType, r)
+	case RDB_MODULE_2:
+		return p.addModuleValue(r)
+	case RDB_STREAM_LISTPACKS, RDB_STREAM_LISTPACKS_2, RDB_STREAM_LISTPACKS_3:
+		return p.addStream(valueType, r)
+	}
+
+	return ParsedKeyValue{}, fmt.Errorf("unknown value type: 0x%02X", valueType)
+}
+
 /*
  	* addKeyValue adds a key-value pair to the global key-value store.
 	* @param r *bufio.Reader - the reader to read the RDB file from
@@ -213,7 +280,7 @@ func (p *rdbParser) addKeyValueWithTTL(kv_type byte, r *bufio.Reader) (ParsedKey
 	switch kv_type {
 	case RDB_EXPIRES_MS:
 		bytes := make([]byte, 8)
-		_, err := r.Read(bytes)
+		_, err := io.ReadFull(r, bytes)
 		if err != nil {
 			return ParsedKeyValue{}, fmt.Errorf("error reading expire time MS: %w", err)
 		}
@@ -222,7 +289,7 @@ func (p *rdbParser) addKeyValueWithTTL(kv_type byte, r *bufio.Reader) (ParsedKey
 
 	case RDB_EXPIRES_S:
 		bytes := make([]byte, 4)
-		_, err := r.Read(bytes)
+		_, err := io.ReadFull(r, bytes)
 		if err != nil {
 			return ParsedKeyValue{}, fmt.Errorf("error reading expire time S: %w", err)
 		}
@@ -232,37 +299,24 @@ func (p *rdbParser) addKeyValueWithTTL(kv_type byte, r *bufio.Reader) (ParsedKey
 		return ParsedKeyValue{}, fmt.Errorf("unknown expire time type: 0x%02X", kv_type)
 	}
 
-	// Verify value type
+	// value type
 	b, err := r.ReadByte()
 	if err != nil {
 		return ParsedKeyValue{}, fmt.Errorf("error reading value type: %w", err)
 	}
-	if b != RDB_STRING {
-		return ParsedKeyValue{}, fmt.Errorf("expected string value type, got 0x%02X", b)
-	}
-
-	// key
-	key, err := p.readNextString(r)
-	if err != nil {
-		return ParsedKeyValue{}, fmt.Errorf("error reading db key: %w", err)
-	}
 
-	// value
-	value, err := p.readNextString(r)
+	kv, err := p.readKeyValue(b, r)
 	if err != nil {
-		return ParsedKeyValue{}, fmt.Errorf("error reading db value: %w", err)
+		return ParsedKeyValue{}, err
 	}
 
 	if expireIn < 0 {
-		log.Println("Key ", key, " expired")
+		log.Println("Key ", kv.Key, " expired")
 		return ParsedKeyValue{}, nil
 	}
 
-	return ParsedKeyValue{
-		Key:       key,
-		Value:     []byte(value),
-		ExpiresIn: expireIn,
-	}, nil
+	kv.ExpiresIn = expireIn
+	return kv, nil
 }
 
 /*
@@ -343,7 +397,7 @@ func (p *rdbParser) readNextString(r *bufio.Reader) (string, error) {
 	}
 
 	buf := make([]byte, l)
-	_, err = r.Read(buf)
+	_, err = io.ReadFull(r, buf)
 	if err != nil {
 		return "", err
 	}
@@ -414,9 +468,18 @@ func (p *rdbParser) readLength(r *bufio.Reader) (uint64, bool, error) {
 		}
 		// TODO: Check if the length is correct
 		return (uint64(b&0x3F) << 8) | uint64(b2), false, nil
-	case 0x02: // 32 bits string length
+	case 0x02: // 32 or 64 bits string length
+		if b == RDB_LEN_64BIT {
+			buf := make([]byte, 8)
+			_, err := io.ReadFull(r, buf)
+			if err != nil {
+				return 0, false, fmt.Errorf("error reading length in 64bit encoded: %w", err)
+			}
+			return binary.BigEndian.Uint64(buf), false, nil
+		}
+
 		buf := make([]byte, 4)
-		_, err := r.Read(buf)
+		_, err := io.ReadFull(r, buf)
 		if err != nil {
 			return 0, false, fmt.Errorf("error reading length in 32bit encoded: %w", err)
 		}
@@ -432,3 +495,331 @@ func (p *rdbParser) readLength(r *bufio.Reader) (uint64, bool, error) {
 
 	return 0, false, fmt.Errorf("unknown length type: 0x%02X", lengthType)
 }
+
+/*
+ 	* addStream reads a stream stored as a radix tree of listpacks.
+	* @param valueType byte - the RDB stream type, newer types carry extra metadata
+	* @param r *bufio.Reader - the reader to read the RDB file from
+	* @return ParsedKeyValue - the parsed stream
+	* @return error - the error if there is one
+*/
+func (p *rdbParser) addStream(valueType byte, r *bufio.Reader) (ParsedKeyValue, error) {
+	key, err := p.readNextString(r)
+	if err != nil {
+		return ParsedKeyValue{}, fmt.Errorf("error reading db key: %w", err)
+	}
+
+	numNodes, _, err := p.readLength(r)
+	if err != nil {
+		return ParsedKeyValue{}, err
+	}
+
+	var entries []ParsedStreamEntry
+	for i := uint64(0); i < numNodes; i++ {
+		nodeKey, err := p.readNextString(r)
+		if err != nil {
+			return ParsedKeyValue{}, fmt.Errorf("error reading stream node key: %w", err)
+		}
+		if len(nodeKey) != 16 {
+			return ParsedKeyValue{}, fmt.Errorf("invalid stream node key length: %d", len(nodeKey))
+		}
+
+		lp, err := p.readNextString(r)
+		if err != nil {
+			return ParsedKeyValue{}, fmt.Errorf("error reading stream listpack: %w", err)
+		}
+
+		masterMs := binary.BigEndian.Uint64([]byte(nodeKey[0:8]))
+		masterSeq := binary.BigEndian.Uint64([]byte(nodeKey[8:16]))
+
+		nodeEntries, err := decodeStreamNode([]byte(lp), masterMs, masterSeq)
+		if err != nil {
+			return ParsedKeyValue{}, err
+		}
+		entries = append(entries, nodeEntries...)
+	}
+
+	if err := p.skipLengths(r, 1); err != nil { // length
+		return ParsedKeyValue{}, err
+	}
+	lastMs, _, err := p.readLength(r)
+	if err != nil {
+		return ParsedKeyValue{}, err
+	}
+	lastSeq, _, err := p.readLength(r)
+	if err != nil {
+		return ParsedKeyValue{}, err
+	}
+	if valueType != RDB_STREAM_LISTPACKS {
+		// first ID, max deleted ID, entries added
+		if err := p.skipLengths(r, 5); err != nil {
+			return ParsedKeyValue{}, err
+		}
+	}
+
+	groups, err := p.readStreamGroups(valueType, r)
+	if err != nil {
+		return ParsedKeyValue{}, err
+	}
+
+	return ParsedKeyValue{
+		Key:          key,
+		Type:         valueType,
+		Stream:       entries,
+		StreamLastId: fmt.Sprintf("%d-%d", lastMs, lastSeq),
+		StreamGroups: groups,
+	}, nil
+}
+
+/*
+ 	* decodeStreamNode decodes the entries of one stream listpack, skipping deleted ones.
+	* @param lp []byte - the listpack
+	* @param masterMs uint64 - the milliseconds part of the node's ID
+	* @param masterSeq uint64 - the sequence part of the node's ID
+	* @return []ParsedStreamEntry - the entries
+	* @return error - the error if there is one
+*/
+func decodeStreamNode(lp []byte, masterMs, masterSeq uint64) ([]ParsedStreamEntry, error) {
+	elements, err := decodeListpack(lp)
+	if err != nil {
+		return nil, err
+	}
+
+	pos := 0
+	next := func() ([]byte, error) {
+		if pos >= len(elements) {
+			return nil, ErrInvalidListpack
+		}
+		pos++
+		return elements[pos-1], nil
+	}
+	nextInt := func() (int64, error) {
+		element, err := next()
+		if err != nil {
+			return 0, err
+		}
+		return strconv.ParseInt(string(element), 10, 64)
+	}
+
+	count, err := nextInt()
+	if err != nil {
+		return nil, err
+	}
+	deleted, err := nextInt()
+	if err != nil {
+		return nil, err
+	}
+	numMasterFields, err := nextInt()
+	if err != nil {
+		return nil, err
+	}
+	masterFields := make([]string, numMasterFields)
+	for i := range masterFields {
+		field, err := next()
+		if err != nil {
+			return nil, err
+		}
+		masterFields[i] = string(field)
+	}
+	if _, err := next(); err != nil { // master entry terminator
+		return nil, err
+	}
+
+	var entries []ParsedStreamEntry
+	for i := int64(0); i < count+deleted; i++ {
+		flags, err := nextInt()
+		if err != nil {
+			return nil, err
+		}
+		msDiff, err := nextInt()
+		if err != nil {
+			return nil, err
+		}
+		seqDiff, err := nextInt()
+		if err != nil {
+			return nil, err
+		}
+
+		data := make(map[string][]byte)
+		if flags&streamItemFlagSameFields != 0 {
+			for _, field := range masterFields {
+				value, err := next()
+				if err != nil {
+					return nil, err
+				}
+				data[field] = value
+			}
+		} else {
+			numFields, err := nextInt()
+			if err != nil {
+				return nil, err
+			}
+			for j := int64(0); j < numFields; j++ {
+				field, err := next()
+				if err != nil {
+					return nil, err
+				}
+				value, err := next()
+				if err != nil {
+					return nil, err
+				}
+				data[string(field)] = value
+			}
+		}
+
+		if _, err := next(); err != nil { // lp-count
+			return nil, err
+		}
+
+		if flags&streamItemFlagDeleted != 0 {
+			continue
+		}
+
+		entries = append(entries, ParsedStreamEntry{
+			Id:   fmt.Sprintf("%d-%d", masterMs+uint64(msDiff), masterSeq+uint64(seqDiff)),
+			Data: data,
+		})
+	}
+
+	return entries, nil
+}
+
+/*
+ 	* readStreamGroups reads the consumer groups of a stream.
+	* @param valueType byte - the RDB stream type
+	* @param r *bufio.Reader - the reader to read the RDB file from
+	* @return []store.StreamGroupSnapshot - the consumer groups
+	* @return error - the error if there is one
+*/
+func (p *rdbParser) readStreamGroups(valueType byte, r *bufio.Reader) ([]store.StreamGroupSnapshot, error) {
+	numGroups, _, err := p.readLength(r)
+	if err != nil {
+		return nil, err
+	}
+
+	groups := make([]store.StreamGroupSnapshot, 0, numGroups)
+	for i := uint64(0); i < numGroups; i++ {
+		name, err := p.readNextString(r)
+		if err != nil {
+			return nil, err
+		}
+
+		lastMs, _, err := p.readLength(r)
+		if err != nil {
+			return nil, err
+		}
+		lastSeq, _, err := p.readLength(r)
+		if err != nil {
+			return nil, err
+		}
+		if valueType != RDB_STREAM_LISTPACKS {
+			if err := p.skipLengths(r, 1); err != nil { // entries read
+				return nil, err
+			}
+		}
+		group := store.StreamGroupSnapshot{Name: name, LastId: fmt.Sprintf("%d-%d", lastMs, lastSeq)}
+
+		pelSize, _, err := p.readLength(r)
+		if err != nil {
+			return nil, err
+		}
+		pending := make(map[string]int, pelSize)
+		for j := uint64(0); j < pelSize; j++ {
+			id, err := readRawStreamId(r)
+			if err != nil {
+				return nil, err
+			}
+			deliveryTime, err := readMillisecondTime(r)
+			if err != nil {
+				return nil, err
+			}
+			deliveryCount, _, err := p.readLength(r)
+			if err != nil {
+				return nil, err
+			}
+			pending[id] = len(group.Pending)
+			group.Pending = append(group.Pending, store.StreamPendingEntry{Id: id, DeliveryTime: deliveryTime, DeliveryCount: int64(deliveryCount)})
+		}
+
+		numConsumers, _, err := p.readLength(r)
+		if err != nil {
+			return nil, err
+		}
+		for j := uint64(0); j < numConsumers; j++ {
+			consumerName, err := p.readNextString(r)
+			if err != nil {
+				return nil, err
+			}
+			seenTime, err := readMillisecondTime(r)
+			if err != nil {
+				return nil, err
+			}
+			consumer := store.StreamConsumerSnapshot{Name: consumerName, SeenTime: seenTime}
+			if valueType == RDB_STREAM_LISTPACKS_3 {
+				if consumer.ActiveTime, err = readMillisecondTime(r); err != nil {
+					return nil, err
+				}
+				if consumer.ActiveTime.UnixMilli() < 0 { // -1 for a consumer that never got anything
+					consumer.ActiveTime = time.Time{}
+				}
+			} else {
+				consumer.ActiveTime = seenTime // the best guess the older layouts allow
+			}
+
+			consumerPelSize, _, err := p.readLength(r)
+			if err != nil {
+				return nil, err
+			}
+			consumer.Pending = int(consumerPelSize)
+			group.Consumers = append(group.Consumers, consumer)
+			for k := uint64(0); k < consumerPelSize; k++ {
+				id, err := readRawStreamId(r)
+				if err != nil {
+					return nil, err
+				}
+				index, exists := pending[id]
+				if !exists {
+					return nil, fmt.Errorf("consumer %q of group %q has pending ID %s missing from the group", consumerName, name, id)
+				}
+				group.Pending[index].Consumer = consumerName
+			}
+		}
+
+		groups = append(groups, group)
+	}
+
+	return groups, nil
+}
+
+// readRawStreamId reads a stream ID stored as 16 big endian bytes
+func readRawStreamId(r *bufio.Reader) (string, error) {
+	raw := make([]byte, 16)
+	if _, err := io.ReadFull(r, raw); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%d-%d", binary.BigEndian.Uint64(raw[0:8]), binary.BigEndian.Uint64(raw[8:16])), nil
+}
+
+// readMillisecondTime reads a time stored as unix milliseconds in 8 little endian bytes
+func readMillisecondTime(r *bufio.Reader) (time.Time, error) {
+	raw := make([]byte, 8)
+	if _, err := io.ReadFull(r, raw); err != nil {
+		return time.Time{}, err
+	}
+	return time.UnixMilli(int64(binary.LittleEndian.Uint64(raw))), nil
+}
+
+/*
+ 	* skipLengths reads and discards n length encoded values.
+	* @param r *bufio.Reader - the reader to read the RDB file from
+	* @param n int - the number of values to skip
+	* @return error - the error if there is one
+*/
+func (p *rdbParser) skipLengths(r *bufio.Reader, n int) error {
+	for i := 0; i < n; i++ {
+		if _, _, err := p.readLength(r); err != nil {
+			return err
+		}
+	}
+	return nil
+}
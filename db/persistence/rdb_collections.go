@@ -0,0 +1,582 @@
+package persistence
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"slices"
+	"strconv"
+	"time"
+
+	store "github.com/manish-singh-bisht/Redis-From-Scratch/db/store"
+)
+
+// how a quicklist node is stored, a single large element on its own or a listpack of elements
+const (
+	quicklistNodeContainerPlain  = 1
+	quicklistNodeContainerPacked = 2
+)
+
+// elements per listpack when writing collections, Redis sizes its nodes by bytes but a count is close enough
+const listpackMaxEntries = 128
+
+// the largest field or value of a hash, or member of a set, written as a listpack, hash-max-listpack-value
+const listpackMaxValue = 64
+
+// the most members of a set written as an intset, set-max-intset-entries
+const intsetMaxEntries = 512
+
+/*
+ 	* writeList writes a list as a quicklist of listpacks, the RDB_LIST_QUICKLIST_2 format
+	* @param elements [][]byte - the elements, head first
+*/
+func (rw *rdbWriter) writeList(elements [][]byte) {
+	numNodes := (len(elements) + listpackMaxEntries - 1) / listpackMaxEntries
+	rw.writeLength(uint64(numNodes))
+
+	for start := 0; start < len(elements); start += listpackMaxEntries {
+		end := min(start+listpackMaxEntries, len(elements))
+
+		lp := newListpackWriter()
+		for _, element := range elements[start:end] {
+			lp.appendString(element)
+		}
+
+		rw.writeLength(quicklistNodeContainerPacked)
+		rw.writeString(lp.bytes())
+	}
+}
+
+/*
+ 	* addList reads a list, either a plain list of strings or a quicklist of listpacks
+	* @param valueType byte - RDB_LIST or RDB_LIST_QUICKLIST_2
+	* @param r *bufio.Reader - the reader to read the RDB file from
+	* @return ParsedKeyValue - the parsed list
+	* @return error - the error if there is one
+*/
+func (p *rdbParser) addList(valueType byte, r *bufio.Reader) (ParsedKeyValue, error) {
+	key, err := p.readNextString(r)
+	if err != nil {
+		return ParsedKeyValue{}, fmt.Errorf("error reading db key: %w", err)
+	}
+
+	n, _, err := p.readLength(r)
+	if err != nil {
+		return ParsedKeyValue{}, err
+	}
+
+	var elements [][]byte
+	for i := uint64(0); i < n; i++ {
+		if valueType == RDB_LIST {
+			element, err := p.readNextString(r)
+			if err != nil {
+				return ParsedKeyValue{}, fmt.Errorf("error reading list element: %w", err)
+			}
+			elements = append(elements, []byte(element))
+			continue
+		}
+
+		container, _, err := p.readLength(r)
+		if err != nil {
+			return ParsedKeyValue{}, err
+		}
+
+		node, err := p.readNextString(r)
+		if err != nil {
+			return ParsedKeyValue{}, fmt.Errorf("error reading list node: %w", err)
+		}
+
+		switch container {
+		case quicklistNodeContainerPlain:
+			elements = append(elements, []byte(node))
+		case quicklistNodeContainerPacked:
+			nodeElements, err := decodeListpack([]byte(node))
+			if err != nil {
+				return ParsedKeyValue{}, err
+			}
+			elements = append(elements, nodeElements...)
+		default:
+			return ParsedKeyValue{}, fmt.Errorf("unknown quicklist node container %d", container)
+		}
+	}
+
+	return ParsedKeyValue{
+		Key:      key,
+		Type:     valueType,
+		Elements: elements,
+	}, nil
+}
+
+/*
+ 	* hashValueType picks how a hash is written: with the expiration of each field when any has one,
+	* otherwise as a listpack when it is small, the way Redis would have encoded it, or as plain pairs
+	* @param fields []store.HashField - the fields
+	* @return byte - RDB_HASH_METADATA, RDB_HASH_LISTPACK or RDB_HASH
+*/
+func hashValueType(fields []store.HashField) byte {
+	compact := len(fields) <= listpackMaxEntries
+	for _, field := range fields {
+		if !field.Expiration.IsZero() {
+			return RDB_HASH_METADATA
+		}
+		if len(field.Field) > listpackMaxValue || len(field.Value) > listpackMaxValue {
+			compact = false
+		}
+	}
+
+	if compact {
+		return RDB_HASH_LISTPACK
+	}
+	return RDB_HASH
+}
+
+/*
+ 	* writeHash writes the fields of a hash in the given format.
+	* RDB_HASH_METADATA starts with the soonest field expiration, each field then stores its own relative to it, plus one, 0 for none
+	* @param valueType byte - RDB_HASH_METADATA, RDB_HASH_LISTPACK or RDB_HASH
+	* @param fields []store.HashField - the fields
+*/
+func (rw *rdbWriter) writeHash(valueType byte, fields []store.HashField) {
+	if valueType == RDB_HASH_LISTPACK {
+		lp := newListpackWriter()
+		for _, field := range fields {
+			lp.appendString([]byte(field.Field))
+			lp.appendString(field.Value)
+		}
+		rw.writeString(lp.bytes())
+		return
+	}
+
+	var minExpire uint64 = math.MaxUint64
+	if valueType == RDB_HASH_METADATA {
+		for _, field := range fields {
+			if !field.Expiration.IsZero() {
+				minExpire = min(minExpire, uint64(field.Expiration.UnixMilli()))
+			}
+		}
+
+		buf := make([]byte, 8)
+		binary.LittleEndian.PutUint64(buf, minExpire)
+		rw.write(buf)
+	}
+
+	rw.writeLength(uint64(len(fields)))
+	for _, field := range fields {
+		if valueType == RDB_HASH_METADATA {
+			var ttl uint64
+			if !field.Expiration.IsZero() {
+				ttl = uint64(field.Expiration.UnixMilli()) - minExpire + 1
+			}
+			rw.writeLength(ttl)
+		}
+		rw.writeString([]byte(field.Field))
+		rw.writeString(field.Value)
+	}
+}
+
+/*
+ 	* addHash reads a hash, as plain pairs, a listpack, or pairs with the expiration of each field
+	* @param valueType byte - RDB_HASH, RDB_HASH_LISTPACK or RDB_HASH_METADATA
+	* @param r *bufio.Reader - the reader to read the RDB file from
+	* @return ParsedKeyValue - the parsed hash
+	* @return error - the error if there is one
+*/
+func (p *rdbParser) addHash(valueType byte, r *bufio.Reader) (ParsedKeyValue, error) {
+	key, err := p.readNextString(r)
+	if err != nil {
+		return ParsedKeyValue{}, fmt.Errorf("error reading db key: %w", err)
+	}
+
+	parsed := ParsedKeyValue{
+		Key:  key,
+		Type: valueType,
+	}
+
+	if valueType == RDB_HASH_LISTPACK {
+		lp, err := p.readNextString(r)
+		if err != nil {
+			return ParsedKeyValue{}, fmt.Errorf("error reading hash listpack: %w", err)
+		}
+		elements, err := decodeListpack([]byte(lp))
+		if err != nil {
+			return ParsedKeyValue{}, err
+		}
+		if len(elements)%2 != 0 {
+			return ParsedKeyValue{}, fmt.Errorf("hash listpack of key %s has an odd number of elements", key)
+		}
+		for i := 0; i < len(elements); i += 2 {
+			parsed.Fields = append(parsed.Fields, store.HashField{Field: string(elements[i]), Value: elements[i+1]})
+		}
+		return parsed, nil
+	}
+
+	var minExpire uint64
+	if valueType == RDB_HASH_METADATA {
+		buf := make([]byte, 8)
+		if _, err := io.ReadFull(r, buf); err != nil {
+			return ParsedKeyValue{}, err
+		}
+		minExpire = binary.LittleEndian.Uint64(buf)
+	}
+
+	n, _, err := p.readLength(r)
+	if err != nil {
+		return ParsedKeyValue{}, err
+	}
+
+	for i := uint64(0); i < n; i++ {
+		var field store.HashField
+
+		if valueType == RDB_HASH_METADATA {
+			ttl, _, err := p.readLength(r)
+			if err != nil {
+				return ParsedKeyValue{}, err
+			}
+			if ttl != 0 {
+				field.Expiration = time.UnixMilli(int64(minExpire + ttl - 1))
+			}
+		}
+
+		name, err := p.readNextString(r)
+		if err != nil {
+			return ParsedKeyValue{}, fmt.Errorf("error reading hash field: %w", err)
+		}
+		value, err := p.readNextString(r)
+		if err != nil {
+			return ParsedKeyValue{}, fmt.Errorf("error reading hash value: %w", err)
+		}
+
+		field.Field = name
+		field.Value = []byte(value)
+		parsed.Fields = append(parsed.Fields, field)
+	}
+
+	return parsed, nil
+}
+
+/*
+ 	* setValueType picks how a set is written, the way Redis would have encoded it:
+	* as an intset when every member is an integer, as a listpack when it is small, otherwise as plain members
+	* @param members [][]byte - the members
+	* @return byte - RDB_SET_INTSET, RDB_SET_LISTPACK or RDB_SET
+*/
+func setValueType(members [][]byte) byte {
+	integers := len(members) <= intsetMaxEntries
+	compact := len(members) <= listpackMaxEntries
+	for _, member := range members {
+		if n, err := strconv.ParseInt(string(member), 10, 64); err != nil || strconv.FormatInt(n, 10) != string(member) {
+			integers = false
+		}
+		if len(member) > listpackMaxValue {
+			compact = false
+		}
+	}
+
+	switch {
+	case integers:
+		return RDB_SET_INTSET
+	case compact:
+		return RDB_SET_LISTPACK
+	}
+	return RDB_SET
+}
+
+/*
+ 	* writeSet writes the members of a set in the given format.
+	* an intset is a little endian header of the integer width and the count, then the integers sorted, all of that width
+	* @param valueType byte - RDB_SET_INTSET, RDB_SET_LISTPACK or RDB_SET
+	* @param members [][]byte - the members
+*/
+func (rw *rdbWriter) writeSet(valueType byte, members [][]byte) {
+	switch valueType {
+	case RDB_SET_INTSET:
+		ints := make([]int64, len(members))
+		width := 2
+		for i, member := range members {
+			ints[i], _ = strconv.ParseInt(string(member), 10, 64)
+			switch {
+			case ints[i] < math.MinInt32 || ints[i] > math.MaxInt32:
+				width = 8
+			case (ints[i] < math.MinInt16 || ints[i] > math.MaxInt16) && width < 4:
+				width = 4
+			}
+		}
+		slices.Sort(ints)
+
+		buf := make([]byte, 8+width*len(ints))
+		binary.LittleEndian.PutUint32(buf[0:], uint32(width))
+		binary.LittleEndian.PutUint32(buf[4:], uint32(len(ints)))
+		for i, n := range ints {
+			offset := 8 + i*width
+			switch width {
+			case 2:
+				binary.LittleEndian.PutUint16(buf[offset:], uint16(n))
+			case 4:
+				binary.LittleEndian.PutUint32(buf[offset:], uint32(n))
+			default:
+				binary.LittleEndian.PutUint64(buf[offset:], uint64(n))
+			}
+		}
+		rw.writeString(buf)
+	case RDB_SET_LISTPACK:
+		lp := newListpackWriter()
+		for _, member := range members {
+			lp.appendString(member)
+		}
+		rw.writeString(lp.bytes())
+	default:
+		rw.writeLength(uint64(len(members)))
+		for _, member := range members {
+			rw.writeString(member)
+		}
+	}
+}
+
+/*
+ 	* decodeIntset decodes the members of an intset
+	* @param data []byte - the intset
+	* @return [][]byte - the members
+	* @return error - the error if the intset is malformed
+*/
+func decodeIntset(data []byte) ([][]byte, error) {
+	if len(data) < 8 {
+		return nil, fmt.Errorf("intset too short")
+	}
+
+	width := int(binary.LittleEndian.Uint32(data[0:]))
+	count := int(binary.LittleEndian.Uint32(data[4:]))
+	if width != 2 && width != 4 && width != 8 {
+		return nil, fmt.Errorf("invalid intset encoding %d", width)
+	}
+	if len(data) != 8+width*count {
+		return nil, fmt.Errorf("intset of %d integers is %d bytes long", count, len(data))
+	}
+
+	members := make([][]byte, count)
+	for i := range members {
+		offset := 8 + i*width
+		var n int64
+		switch width {
+		case 2:
+			n = int64(int16(binary.LittleEndian.Uint16(data[offset:])))
+		case 4:
+			n = int64(int32(binary.LittleEndian.Uint32(data[offset:])))
+		default:
+			n = int64(binary.LittleEndian.Uint64(data[offset:]))
+		}
+		members[i] = []byte(strconv.FormatInt(n, 10))
+	}
+	return members, nil
+}
+
+/*
+ 	* addSet reads a set, as plain members, an intset or a listpack
+	* @param valueType byte - RDB_SET, RDB_SET_INTSET or RDB_SET_LISTPACK
+	* @param r *bufio.Reader - the reader to read the RDB file from
+	* @return ParsedKeyValue - the parsed set
+	* @return error - the error if there is one
+*/
+func (p *rdbParser) addSet(valueType byte, r *bufio.Reader) (ParsedKeyValue, error) {
+	key, err := p.readNextString(r)
+	if err != nil {
+		return ParsedKeyValue{}, fmt.Errorf("error reading db key: %w", err)
+	}
+
+	parsed := ParsedKeyValue{
+		Key:  key,
+		Type: valueType,
+	}
+
+	if valueType != RDB_SET {
+		data, err := p.readNextString(r)
+		if err != nil {
+			return ParsedKeyValue{}, fmt.Errorf("error reading set: %w", err)
+		}
+		if valueType == RDB_SET_INTSET {
+			parsed.Elements, err = decodeIntset([]byte(data))
+		} else {
+			parsed.Elements, err = decodeListpack([]byte(data))
+		}
+		if err != nil {
+			return ParsedKeyValue{}, err
+		}
+		return parsed, nil
+	}
+
+	n, _, err := p.readLength(r)
+	if err != nil {
+		return ParsedKeyValue{}, err
+	}
+
+	for i := uint64(0); i < n; i++ {
+		member, err := p.readNextString(r)
+		if err != nil {
+			return ParsedKeyValue{}, fmt.Errorf("error reading set member: %w", err)
+		}
+		parsed.Elements = append(parsed.Elements, []byte(member))
+	}
+
+	return parsed, nil
+}
+
+/*
+ 	* zsetValueType picks how a sorted set is written, as a listpack when it is small, the way Redis would have encoded it
+	* @param members []store.ZMember - the members
+	* @return byte - RDB_ZSET_LISTPACK or RDB_ZSET_2
+*/
+func zsetValueType(members []store.ZMember) byte {
+	if len(members) > listpackMaxEntries {
+		return RDB_ZSET_2
+	}
+	for _, member := range members {
+		if len(member.Member) > listpackMaxValue {
+			return RDB_ZSET_2
+		}
+	}
+	return RDB_ZSET_LISTPACK
+}
+
+/*
+ 	* writeZSet writes the members of a sorted set in the given format.
+	* a listpack holds member, score pairs lowest score first, with the scores as strings,
+	* RDB_ZSET_2 holds the members highest score first, like Redis, each followed by its score as a little endian double
+	* @param valueType byte - RDB_ZSET_LISTPACK or RDB_ZSET_2
+	* @param members []store.ZMember - the members, lowest score first
+*/
+func (rw *rdbWriter) writeZSet(valueType byte, members []store.ZMember) {
+	if valueType == RDB_ZSET_LISTPACK {
+		lp := newListpackWriter()
+		for _, member := range members {
+			lp.appendString([]byte(member.Member))
+			lp.appendString([]byte(formatZSetScore(member.Score)))
+		}
+		rw.writeString(lp.bytes())
+		return
+	}
+
+	rw.writeLength(uint64(len(members)))
+	buf := make([]byte, 8)
+	for i := len(members) - 1; i >= 0; i-- {
+		rw.writeString([]byte(members[i].Member))
+		binary.LittleEndian.PutUint64(buf, math.Float64bits(members[i].Score))
+		rw.write(buf)
+	}
+}
+
+/*
+ 	* formatZSetScore formats a score the way Redis writes it, the shortest string that parses back to the same float,
+	* with inf and -inf for the infinities
+	* @param score float64 - the score
+	* @return string - the formatted score
+*/
+func formatZSetScore(score float64) string {
+	switch {
+	case math.IsInf(score, 1):
+		return "inf"
+	case math.IsInf(score, -1):
+		return "-inf"
+	}
+	return strconv.FormatFloat(score, 'g', -1, 64)
+}
+
+/*
+ 	* readZSetScore reads a score of the old RDB_ZSET format, a string prefixed by its length,
+	* where the lengths 253, 254 and 255 stand for NaN, +inf and -inf
+	* @param r *bufio.Reader - the reader to read the RDB file from
+	* @return float64 - the score
+	* @return error - the error if there is one
+*/
+func readZSetScore(r *bufio.Reader) (float64, error) {
+	length, err := r.ReadByte()
+	if err != nil {
+		return 0, err
+	}
+
+	switch length {
+	case 253:
+		return math.NaN(), nil
+	case 254:
+		return math.Inf(1), nil
+	case 255:
+		return math.Inf(-1), nil
+	}
+
+	buf := make([]byte, length)
+	if _, err := io.ReadFull(r, buf); err != nil {
+		return 0, err
+	}
+	return strconv.ParseFloat(string(buf), 64)
+}
+
+/*
+ 	* addZSet reads a sorted set, as a listpack, or members with their scores as strings or as doubles
+	* @param valueType byte - RDB_ZSET, RDB_ZSET_2 or RDB_ZSET_LISTPACK
+	* @param r *bufio.Reader - the reader to read the RDB file from
+	* @return ParsedKeyValue - the parsed sorted set
+	* @return error - the error if there is one
+*/
+func (p *rdbParser) addZSet(valueType byte, r *bufio.Reader) (ParsedKeyValue, error) {
+	key, err := p.readNextString(r)
+	if err != nil {
+		return ParsedKeyValue{}, fmt.Errorf("error reading db key: %w", err)
+	}
+
+	parsed := ParsedKeyValue{
+		Key:  key,
+		Type: valueType,
+	}
+
+	if valueType == RDB_ZSET_LISTPACK {
+		lp, err := p.readNextString(r)
+		if err != nil {
+			return ParsedKeyValue{}, fmt.Errorf("error reading sorted set listpack: %w", err)
+		}
+		elements, err := decodeListpack([]byte(lp))
+		if err != nil {
+			return ParsedKeyValue{}, err
+		}
+		if len(elements)%2 != 0 {
+			return ParsedKeyValue{}, fmt.Errorf("sorted set listpack of key %s has an odd number of elements", key)
+		}
+		for i := 0; i < len(elements); i += 2 {
+			score, err := strconv.ParseFloat(string(elements[i+1]), 64)
+			if err != nil {
+				return ParsedKeyValue{}, fmt.Errorf("invalid score in sorted set %s: %w", key, err)
+			}
+			parsed.ZMembers = append(parsed.ZMembers, store.ZMember{Member: string(elements[i]), Score: score})
+		}
+		return parsed, nil
+	}
+
+	n, _, err := p.readLength(r)
+	if err != nil {
+		return ParsedKeyValue{}, err
+	}
+
+	buf := make([]byte, 8)
+	for i := uint64(0); i < n; i++ {
+		member, err := p.readNextString(r)
+		if err != nil {
+			return ParsedKeyValue{}, fmt.Errorf("error reading sorted set member: %w", err)
+		}
+
+		var score float64
+		if valueType == RDB_ZSET_2 {
+			if _, err := io.ReadFull(r, buf); err != nil {
+				return ParsedKeyValue{}, err
+			}
+			score = math.Float64frombits(binary.LittleEndian.Uint64(buf))
+		} else {
+			score, err = readZSetScore(r)
+			if err != nil {
+				return ParsedKeyValue{}, fmt.Errorf("error reading sorted set score: %w", err)
+			}
+		}
+
+		parsed.ZMembers = append(parsed.ZMembers, store.ZMember{Member: member, Score: score})
+	}
+
+	return parsed, nil
+}
@@ -0,0 +1,501 @@
+package persistence
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	store "github.com/manish-singh-bisht/Redis-From-Scratch/db/store"
+)
+
+var ErrBgSaveInProgress = errors.New("ERR Background save already in progress")
+
+const (
+	rdbVersion = "0011"
+
+	// same limit Redis uses by default (stream-node-max-entries)
+	streamNodeMaxEntries = 100
+
+	streamItemFlagNone       = 0
+	streamItemFlagDeleted    = 1
+	streamItemFlagSameFields = 2
+)
+
+var (
+	writeMu   sync.Mutex // only one save writes the temp file at a time
+	saveState = struct {
+		mu         sync.Mutex
+		inProgress bool      // true while a BGSAVE is running
+		lastSave   time.Time // time of the last successful save, server start time until then
+	}{
+		lastSave: time.Now(),
+	}
+)
+
+type rdbWriter struct {
+	w   *bufio.Writer
+	crc uint64 // running checksum of everything written so far, stored at the end of the file
+}
+
+/*
+ 	* Save writes the whole store to the RDB file, blocking the caller until the file is on disk
+	* @param st *store.Store - the store to save
+	* @return error - the error if there is one
+*/
+func Save(st *store.Store) error {
+	saveState.mu.Lock()
+	inProgress := saveState.inProgress
+	saveState.mu.Unlock()
+
+	if inProgress {
+		return ErrBgSaveInProgress
+	}
+
+	return saveSnapshot(st.Snapshot())
+}
+
+/*
+ 	* BGSave takes a snapshot of the store and writes it to the RDB file in the background
+	* the snapshot is taken before returning, so writes that happen after BGSAVE returns are not part of the dump
+	* @param st *store.Store - the store to save
+	* @return error - the error if there is one
+*/
+func BGSave(st *store.Store) error {
+	saveState.mu.Lock()
+	if saveState.inProgress {
+		saveState.mu.Unlock()
+		return ErrBgSaveInProgress
+	}
+	saveState.inProgress = true
+	saveState.mu.Unlock()
+
+	entries := st.Snapshot()
+
+	go func() {
+		defer func() {
+			saveState.mu.Lock()
+			saveState.inProgress = false
+			saveState.mu.Unlock()
+		}()
+
+		if err := saveSnapshot(entries); err != nil {
+			log.Printf("Background saving error: %v", err)
+			return
+		}
+		log.Print("Background saving terminated with success")
+	}()
+
+	return nil
+}
+
+/*
+ 	* LastSave returns the unix time of the last successful save
+	* @return int64 - the unix time in seconds
+*/
+func LastSave() int64 {
+	saveState.mu.Lock()
+	defer saveState.mu.Unlock()
+	return saveState.lastSave.Unix()
+}
+
+/*
+ 	* saveSnapshot writes the entries to a temp file and renames it over the configured RDB file,
+	* so a crash in the middle of a save leaves the previous dump untouched
+	* @param entries []store.SnapshotEntry - the keys to write
+	* @return error - the error if there is one
+*/
+func saveSnapshot(entries []store.SnapshotEntry) error {
+	writeMu.Lock()
+	defer writeMu.Unlock()
+
+	dir, dbFilename := GetConfig()
+	tmpPath := filepath.Join(dir, fmt.Sprintf("temp-%d.rdb", os.Getpid()))
+
+	f, err := os.Create(tmpPath)
+	if err != nil {
+		return fmt.Errorf("failed to create temp RDB file: %w", err)
+	}
+
+	err = writeRDB(f, entries)
+	if err == nil {
+		err = f.Sync()
+	}
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(tmpPath)
+		return fmt.Errorf("failed to write RDB file: %w", err)
+	}
+
+	if err := os.Rename(tmpPath, filepath.Join(dir, dbFilename)); err != nil {
+		os.Remove(tmpPath)
+		return fmt.Errorf("failed to rename temp RDB file: %w", err)
+	}
+
+	saveState.mu.Lock()
+	saveState.lastSave = time.Now()
+	saveState.mu.Unlock()
+
+	return nil
+}
+
+/*
+ 	* writeRDB encodes the entries in the RDB format
+	* @param w io.Writer - the writer to write to
+	* @param entries []store.SnapshotEntry - the keys to write
+	* @return error - the error if there is one
+*/
+func writeRDB(w io.Writer, entries []store.SnapshotEntry) error {
+	rw := &rdbWriter{w: bufio.NewWriter(w)}
+
+	rw.write([]byte("REDIS" + rdbVersion))
+
+	rw.writeAux("redis-ver", "7.2.0")
+	rw.writeAux("redis-bits", "64")
+	rw.writeAux("ctime", strconv.FormatInt(time.Now().Unix(), 10))
+
+	expires := 0
+	for _, entry := range entries {
+		if !entry.Expiration.IsZero() {
+			expires++
+		}
+	}
+
+	rw.writeByte(RDB_DB_START)
+	rw.writeLength(0)
+	rw.writeByte(RDB_DB_SIZE)
+	rw.writeLength(uint64(len(entries)))
+	rw.writeLength(uint64(expires))
+
+	for _, entry := range entries {
+		if err := rw.writeEntry(entry); err != nil {
+			return err
+		}
+	}
+
+	rw.writeByte(RDB_EOF)
+
+	// the checksum itself is not part of the checksum
+	checksum := make([]byte, 8)
+	binary.LittleEndian.PutUint64(checksum, rw.crc)
+	if _, err := rw.w.Write(checksum); err != nil {
+		return err
+	}
+
+	return rw.w.Flush()
+}
+
+/*
+ 	* writeEntry writes a single key with its optional expiration
+	* @param entry store.SnapshotEntry - the key to write
+	* @return error - the error if there is one
+*/
+func (rw *rdbWriter) writeEntry(entry store.SnapshotEntry) error {
+	if !entry.Expiration.IsZero() {
+		rw.writeByte(RDB_EXPIRES_MS)
+		expireAt := make([]byte, 8)
+		binary.LittleEndian.PutUint64(expireAt, uint64(entry.Expiration.UnixMilli()))
+		rw.write(expireAt)
+	}
+
+	switch entry.Type {
+	case store.TypeString:
+		rw.writeByte(RDB_STRING)
+		rw.writeString([]byte(entry.Key))
+		rw.writeString(entry.Value)
+	case store.TypeList:
+		rw.writeByte(RDB_LIST_QUICKLIST_2)
+		rw.writeString([]byte(entry.Key))
+		rw.writeList(entry.Elements)
+	case store.TypeSet:
+		valueType := setValueType(entry.Elements)
+		rw.writeByte(valueType)
+		rw.writeString([]byte(entry.Key))
+		rw.writeSet(valueType, entry.Elements)
+	case store.TypeZSet:
+		valueType := zsetValueType(entry.ZMembers)
+		rw.writeByte(valueType)
+		rw.writeString([]byte(entry.Key))
+		rw.writeZSet(valueType, entry.ZMembers)
+	case store.TypeHash:
+		valueType := hashValueType(entry.Fields)
+		rw.writeByte(valueType)
+		rw.writeString([]byte(entry.Key))
+		rw.writeHash(valueType, entry.Fields)
+	case store.TypeRateMeter:
+		rw.writeByte(RDB_MODULE_2)
+		rw.writeString([]byte(entry.Key))
+		rw.writeRateMeter(entry.Meter)
+	case store.TypeIDGen:
+		rw.writeByte(RDB_MODULE_2)
+		rw.writeString([]byte(entry.Key))
+		rw.writeIDGen(entry.IDGen)
+	case store.TypeStream:
+		rw.writeByte(RDB_STREAM_LISTPACKS)
+		rw.writeString([]byte(entry.Key))
+		return rw.writeStream(entry.Records, entry.LastId, entry.Groups)
+	default:
+		return fmt.Errorf("unknown type %q for key %q", entry.Type, entry.Key)
+	}
+
+	return nil
+}
+
+/*
+ 	* writeStream writes a stream as a radix tree of listpacks, each node holding up to streamNodeMaxEntries entries
+	* followed by its consumer groups
+	* @param records []store.StreamRecord - the entries of the stream in ID order
+	* @param lastId string - the greatest ID ever added to the stream
+	* @param groups []store.StreamGroupSnapshot - the consumer groups of the stream
+	* @return error - the error if there is one
+*/
+func (rw *rdbWriter) writeStream(records []store.StreamRecord, lastId string, groups []store.StreamGroupSnapshot) error {
+	numNodes := (len(records) + streamNodeMaxEntries - 1) / streamNodeMaxEntries
+	rw.writeLength(uint64(numNodes))
+
+	for start := 0; start < len(records); start += streamNodeMaxEntries {
+		end := min(start+streamNodeMaxEntries, len(records))
+
+		masterMs, masterSeq, err := splitStreamId(records[start].Id)
+		if err != nil {
+			return err
+		}
+
+		nodeKey := make([]byte, 16)
+		binary.BigEndian.PutUint64(nodeKey[0:8], masterMs)
+		binary.BigEndian.PutUint64(nodeKey[8:16], masterSeq)
+		rw.writeString(nodeKey)
+
+		lp, err := encodeStreamNode(records[start:end], masterMs, masterSeq)
+		if err != nil {
+			return err
+		}
+		rw.writeString(lp)
+	}
+
+	lastMs, lastSeq, err := splitStreamId(lastId)
+	if err != nil {
+		return err
+	}
+	rw.writeLength(uint64(len(records)))
+	rw.writeLength(lastMs)
+	rw.writeLength(lastSeq)
+
+	return rw.writeStreamGroups(groups)
+}
+
+/*
+ 	* writeStreamGroups writes the consumer groups of a stream, each with its last delivered ID, its pending entries list
+	* and its consumers with the IDs pending for them
+	* @param groups []store.StreamGroupSnapshot - the consumer groups
+	* @return error - the error if there is one
+*/
+func (rw *rdbWriter) writeStreamGroups(groups []store.StreamGroupSnapshot) error {
+	rw.writeLength(uint64(len(groups)))
+
+	for _, group := range groups {
+		rw.writeString([]byte(group.Name))
+
+		lastMs, lastSeq, err := splitStreamId(group.LastId)
+		if err != nil {
+			return err
+		}
+		rw.writeLength(lastMs)
+		rw.writeLength(lastSeq)
+
+		consumerPending := make(map[string][][]byte)
+		rw.writeLength(uint64(len(group.Pending)))
+		for _, pending := range group.Pending {
+			rawId, err := rawStreamId(pending.Id)
+			if err != nil {
+				return err
+			}
+			rw.write(rawId)
+			rw.writeMillisecondTime(pending.DeliveryTime)
+			rw.writeLength(uint64(pending.DeliveryCount))
+
+			consumerPending[pending.Consumer] = append(consumerPending[pending.Consumer], rawId)
+		}
+
+		rw.writeLength(uint64(len(group.Consumers)))
+		for _, consumer := range group.Consumers {
+			rw.writeString([]byte(consumer.Name))
+			rw.writeMillisecondTime(consumer.SeenTime)
+
+			rw.writeLength(uint64(len(consumerPending[consumer.Name])))
+			for _, rawId := range consumerPending[consumer.Name] {
+				rw.write(rawId)
+			}
+		}
+	}
+
+	return nil
+}
+
+// writeMillisecondTime writes a time as unix milliseconds in 8 little endian bytes
+func (rw *rdbWriter) writeMillisecondTime(t time.Time) {
+	buf := make([]byte, 8)
+	binary.LittleEndian.PutUint64(buf, uint64(t.UnixMilli()))
+	rw.write(buf)
+}
+
+// rawStreamId returns a stream ID as the 16 big endian bytes the RDB format stores it as
+func rawStreamId(id string) ([]byte, error) {
+	ms, seq, err := splitStreamId(id)
+	if err != nil {
+		return nil, err
+	}
+
+	raw := make([]byte, 16)
+	binary.BigEndian.PutUint64(raw[0:8], ms)
+	binary.BigEndian.PutUint64(raw[8:16], seq)
+	return raw, nil
+}
+
+/*
+ 	* encodeStreamNode builds the listpack of one stream node
+	* the first entry's fields become the master fields, entries with the same fields only store their values
+	* @param records []store.StreamRecord - the entries of the node
+	* @param masterMs uint64 - the milliseconds part of the node's ID
+	* @param masterSeq uint64 - the sequence part of the node's ID
+	* @return []byte - the listpack
+	* @return error - the error if there is one
+*/
+func encodeStreamNode(records []store.StreamRecord, masterMs, masterSeq uint64) ([]byte, error) {
+	masterFields := sortedFields(records[0].Data)
+
+	lp := newListpackWriter()
+	lp.appendInt(int64(len(records))) // count
+	lp.appendInt(0)                   // deleted
+	lp.appendInt(int64(len(masterFields)))
+	for _, field := range masterFields {
+		lp.appendString([]byte(field))
+	}
+	lp.appendInt(0) // master entry terminator
+
+	for _, record := range records {
+		ms, seq, err := splitStreamId(record.Id)
+		if err != nil {
+			return nil, err
+		}
+
+		fields := sortedFields(record.Data)
+		sameFields := len(fields) == len(masterFields)
+		for i := 0; sameFields && i < len(fields); i++ {
+			sameFields = fields[i] == masterFields[i]
+		}
+
+		if sameFields {
+			lp.appendInt(streamItemFlagSameFields)
+		} else {
+			lp.appendInt(streamItemFlagNone)
+		}
+		lp.appendInt(int64(ms - masterMs))
+		lp.appendInt(int64(seq - masterSeq))
+
+		if sameFields {
+			for _, field := range fields {
+				lp.appendString(record.Data[field])
+			}
+			lp.appendInt(int64(len(fields) + 3))
+		} else {
+			lp.appendInt(int64(len(fields)))
+			for _, field := range fields {
+				lp.appendString([]byte(field))
+				lp.appendString(record.Data[field])
+			}
+			lp.appendInt(int64(len(fields)*2 + 4))
+		}
+	}
+
+	return lp.bytes(), nil
+}
+
+func sortedFields(data map[string][]byte) []string {
+	fields := make([]string, 0, len(data))
+	for field := range data {
+		fields = append(fields, field)
+	}
+	sort.Strings(fields)
+	return fields
+}
+
+/*
+ 	* splitStreamId splits a "ms-seq" stream ID into its parts
+	* @param id string - the stream ID
+	* @return uint64 - the milliseconds part
+	* @return uint64 - the sequence part
+	* @return error - the error if there is one
+*/
+func splitStreamId(id string) (uint64, uint64, error) {
+	msPart, seqPart, found := strings.Cut(id, "-")
+	if !found {
+		return 0, 0, fmt.Errorf("invalid stream ID %q", id)
+	}
+
+	ms, err := strconv.ParseUint(msPart, 10, 64)
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid stream ID %q", id)
+	}
+
+	seq, err := strconv.ParseUint(seqPart, 10, 64)
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid stream ID %q", id)
+	}
+
+	return ms, seq, nil
+}
+
+func (rw *rdbWriter) write(p []byte) {
+	rw.crc = crc64(rw.crc, p)
+	rw.w.Write(p) // bufio keeps the first error and returns it from Flush
+}
+
+func (rw *rdbWriter) writeByte(b byte) {
+	rw.write([]byte{b})
+}
+
+func (rw *rdbWriter) writeAux(key, value string) {
+	rw.writeByte(RDB_METADATA)
+	rw.writeString([]byte(key))
+	rw.writeString([]byte(value))
+}
+
+/*
+ 	* writeLength writes a length using the smallest of the RDB length encodings, the reverse of readLength
+	* @param l uint64 - the length to write
+*/
+func (rw *rdbWriter) writeLength(l uint64) {
+	switch {
+	case l < 1<<6:
+		rw.writeByte(byte(l))
+	case l < 1<<14:
+		rw.write([]byte{0x40 | byte(l>>8), byte(l)})
+	case l <= 0xFFFFFFFF:
+		buf := make([]byte, 5)
+		buf[0] = RDB_LEN_32BIT
+		binary.BigEndian.PutUint32(buf[1:], uint32(l))
+		rw.write(buf)
+	default:
+		buf := make([]byte, 9)
+		buf[0] = RDB_LEN_64BIT
+		binary.BigEndian.PutUint64(buf[1:], l)
+		rw.write(buf)
+	}
+}
+
+func (rw *rdbWriter) writeString(s []byte) {
+	rw.writeLength(uint64(len(s)))
+	rw.write(s)
+}
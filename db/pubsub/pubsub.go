@@ -0,0 +1,447 @@
+package pubsub
+
+import (
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	RESP "github.com/manish-singh-bisht/Redis-From-Scratch/db/resp"
+	store "github.com/manish-singh-bisht/Redis-From-Scratch/db/store"
+)
+
+// the output buffer limit of pubsub clients, client-output-buffer-limit pubsub 32mb 8mb 60 in Redis: a subscriber
+// whose messages waiting to be written pass the hard limit, or stay past the soft limit for the soft period,
+// can't keep up and is disconnected
+const (
+	subscriberHardLimit  = 32 * 1024 * 1024 // in bytes, as the messages are written to the connection
+	subscriberSoftLimit  = 8 * 1024 * 1024
+	subscriberSoftPeriod = 60 * time.Second
+)
+
+var pubSubInstance = newPubSub()
+
+// PubSub routes the messages published on a channel to the clients subscribed to it or to a pattern matching it
+type PubSub struct {
+	mu       sync.RWMutex
+	clients  map[string]*subscriber              // every connected client by id, subscribed or not
+	channels map[string]map[*subscriber]struct{} // the subscribers of each channel, a channel is gone once it has none
+	patterns map[string]map[*subscriber]struct{} // the subscribers of each pattern, same
+}
+
+// subscriber is a connected client, in subscribed mode while it has at least one subscription
+type subscriber struct {
+	writer   *RESP.Writer
+	onSlow   func()              // closes the connection of a client that can't keep up
+	slow     sync.Once           // onSlow is called once
+	channels map[string]struct{} // guarded by PubSub.mu, like patterns
+	patterns map[string]struct{}
+
+	// replies is held while a subscription changes and its reply is written, and while a message is written,
+	// so that the reply to SUBSCRIBE always comes before the first message of the channel
+	replies sync.Mutex
+	started sync.Once // the goroutine writing the queue starts with the first subscription
+
+	queueMu     sync.Mutex
+	ready       *sync.Cond      // signalled when a message is queued or the client is gone
+	queue       []queuedMessage // the messages waiting to be written
+	queuedBytes int             // the size of the queued messages and of the one being written
+	softSince   time.Time       // when queuedBytes went past the soft limit, zero while it is under
+	closed      bool            // the client is gone
+}
+
+// queuedMessage is a message waiting to be written with its size once encoded
+type queuedMessage struct {
+	message RESP.RESPMessage
+	size    int
+}
+
+func newPubSub() *PubSub {
+	return &PubSub{
+		clients:  make(map[string]*subscriber),
+		channels: make(map[string]map[*subscriber]struct{}),
+		patterns: make(map[string]map[*subscriber]struct{}),
+	}
+}
+
+/*
+ 	* GetPubSub returns the singleton instance of PubSub
+	* @return *PubSub - the singleton instance of PubSub
+*/
+func GetPubSub() *PubSub {
+	return pubSubInstance
+}
+
+/*
+ 	* Register makes a connected client able to subscribe, the messages published to it are written to its connection
+	* by a goroutine of its own, so publishing never waits on a slow client
+	* @param clientID string - the client id
+	* @param writer *RESP.Writer - the writer of its connection
+	* @param onSlow func() - closes its connection, called if the messages waiting to be written to it pile up
+*/
+func (ps *PubSub) Register(clientID string, writer *RESP.Writer, onSlow func()) {
+	ps.mu.Lock()
+	defer ps.mu.Unlock()
+
+	sub := &subscriber{
+		writer:   writer,
+		onSlow:   onSlow,
+		channels: make(map[string]struct{}),
+		patterns: make(map[string]struct{}),
+	}
+	sub.ready = sync.NewCond(&sub.queueMu)
+	ps.clients[clientID] = sub
+}
+
+/*
+ 	* Unregister drops the subscriptions of a client once its connection is closed
+	* @param clientID string - the client id
+*/
+func (ps *PubSub) Unregister(clientID string) {
+	ps.mu.Lock()
+	defer ps.mu.Unlock()
+
+	sub, exists := ps.clients[clientID]
+	if !exists {
+		return
+	}
+	delete(ps.clients, clientID)
+
+	for channel := range sub.channels {
+		removeSubscriber(ps.channels, channel, sub)
+	}
+	for pattern := range sub.patterns {
+		removeSubscriber(ps.patterns, pattern, sub)
+	}
+
+	// publishers only send with the read lock held, so nothing is queued once it is closed
+	sub.queueMu.Lock()
+	sub.closed = true
+	sub.queue = nil
+	sub.queueMu.Unlock()
+	sub.ready.Signal()
+}
+
+/*
+ 	* Subscriptions returns how many channels and patterns a client is subscribed to, it is in subscribed mode unless it is 0
+	* @param clientID string - the client id
+	* @return int - the number of subscriptions
+*/
+func (ps *PubSub) Subscriptions(clientID string) int {
+	ps.mu.RLock()
+	defer ps.mu.RUnlock()
+
+	sub, exists := ps.clients[clientID]
+	if !exists {
+		return 0
+	}
+	return len(sub.channels) + len(sub.patterns)
+}
+
+/*
+ 	* Subscribe subscribes a client to channels
+	* @param clientID string - the client id
+	* @param channels []string - the channels
+	* @param reply func(channel string, count int) error - writes the reply for each channel, with the number of
+	* subscriptions of the client once it is subscribed, before any message of the channel can be written
+	* @return error - the error reply returned
+*/
+func (ps *PubSub) Subscribe(clientID string, channels []string, reply func(channel string, count int) error) error {
+	return ps.subscribe(clientID, channels, false, reply)
+}
+
+/*
+ 	* PSubscribe subscribes a client to glob-style patterns, the channels matching them
+	* @param clientID string - the client id
+	* @param patterns []string - the patterns
+	* @param reply func(pattern string, count int) error - like for Subscribe
+	* @return error - the error reply returned
+*/
+func (ps *PubSub) PSubscribe(clientID string, patterns []string, reply func(pattern string, count int) error) error {
+	return ps.subscribe(clientID, patterns, true, reply)
+}
+
+func (ps *PubSub) subscribe(clientID string, names []string, pattern bool, reply func(name string, count int) error) error {
+	ps.mu.RLock()
+	sub, exists := ps.clients[clientID]
+	ps.mu.RUnlock()
+	if !exists {
+		return nil
+	}
+
+	sub.replies.Lock()
+	defer sub.replies.Unlock()
+	sub.started.Do(func() { go sub.deliver() })
+
+	for _, name := range names {
+		ps.mu.Lock()
+		if pattern {
+			addSubscriber(ps.patterns, name, sub)
+			sub.patterns[name] = struct{}{}
+		} else {
+			addSubscriber(ps.channels, name, sub)
+			sub.channels[name] = struct{}{}
+		}
+		count := len(sub.channels) + len(sub.patterns)
+		ps.mu.Unlock()
+
+		if err := reply(name, count); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+/*
+ 	* Unsubscribe unsubscribes a client from channels
+	* @param clientID string - the client id
+	* @param channels []string - the channels, none for all of those it is subscribed to
+	* @param reply func(channel string, count int) error - writes the reply for each channel, with the number of
+	* subscriptions the client has left, called once with an empty channel if there was none to unsubscribe from
+	* @return error - the error reply returned
+*/
+func (ps *PubSub) Unsubscribe(clientID string, channels []string, reply func(channel string, count int) error) error {
+	return ps.unsubscribe(clientID, channels, false, reply)
+}
+
+/*
+ 	* PUnsubscribe unsubscribes a client from patterns
+	* @param clientID string - the client id
+	* @param patterns []string - the patterns, none for all of those it is subscribed to
+	* @param reply func(pattern string, count int) error - like for Unsubscribe
+	* @return error - the error reply returned
+*/
+func (ps *PubSub) PUnsubscribe(clientID string, patterns []string, reply func(pattern string, count int) error) error {
+	return ps.unsubscribe(clientID, patterns, true, reply)
+}
+
+func (ps *PubSub) unsubscribe(clientID string, names []string, pattern bool, reply func(name string, count int) error) error {
+	ps.mu.RLock()
+	sub, exists := ps.clients[clientID]
+	ps.mu.RUnlock()
+	if !exists {
+		return nil
+	}
+
+	sub.replies.Lock()
+	defer sub.replies.Unlock()
+
+	subscribed, index := sub.channels, ps.channels
+	if pattern {
+		subscribed, index = sub.patterns, ps.patterns
+	}
+
+	if len(names) == 0 {
+		ps.mu.RLock()
+		for name := range subscribed {
+			names = append(names, name)
+		}
+		ps.mu.RUnlock()
+		sort.Strings(names)
+
+		if len(names) == 0 {
+			return reply("", ps.Subscriptions(clientID))
+		}
+	}
+
+	for _, name := range names {
+		ps.mu.Lock()
+		if _, ok := subscribed[name]; ok {
+			delete(subscribed, name)
+			removeSubscriber(index, name, sub)
+		}
+		count := len(sub.channels) + len(sub.patterns)
+		ps.mu.Unlock()
+
+		if err := reply(name, count); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+/*
+ 	* Publish sends a message to the clients subscribed to a channel and to those subscribed to a pattern matching it,
+	* it is queued for each of them and written to their connections later on
+	* @param channel string - the channel
+	* @param message []byte - the message
+	* @return int - the number of clients it was sent to, a client subscribed to the channel and to patterns matching
+	* it counted once for each
+*/
+func (ps *PubSub) Publish(channel string, message []byte) int {
+	ps.mu.RLock()
+	defer ps.mu.RUnlock()
+
+	receivers := 0
+	for sub := range ps.channels[channel] {
+		sub.send(arrayMessage(bulkString("message"), bulkString(channel), bulkStringBytes(message)))
+		receivers++
+	}
+	for pattern, subs := range ps.patterns {
+		if !store.MatchGlob(pattern, channel) {
+			continue
+		}
+		for sub := range subs {
+			sub.send(arrayMessage(bulkString("pmessage"), bulkString(pattern), bulkString(channel), bulkStringBytes(message)))
+			receivers++
+		}
+	}
+	return receivers
+}
+
+/*
+ 	* Channels lists the channels with at least one subscriber, for PUBSUB CHANNELS
+	* @param pattern string - only the channels matching it, "" for all of them
+	* @return []string - the channels, sorted
+*/
+func (ps *PubSub) Channels(pattern string) []string {
+	ps.mu.RLock()
+	defer ps.mu.RUnlock()
+
+	channels := []string{}
+	for channel := range ps.channels {
+		if pattern == "" || store.MatchGlob(pattern, channel) {
+			channels = append(channels, channel)
+		}
+	}
+	sort.Strings(channels)
+	return channels
+}
+
+/*
+ 	* NumSub returns the number of subscribers of each channel, patterns left out, for PUBSUB NUMSUB
+	* @param channels []string - the channels
+	* @return []int - the number of subscribers of each
+*/
+func (ps *PubSub) NumSub(channels []string) []int {
+	ps.mu.RLock()
+	defer ps.mu.RUnlock()
+
+	counts := make([]int, len(channels))
+	for i, channel := range channels {
+		counts[i] = len(ps.channels[channel])
+	}
+	return counts
+}
+
+/*
+ 	* NumPat returns the number of patterns clients are subscribed to, for PUBSUB NUMPAT
+	* @return int - the number of distinct patterns
+*/
+func (ps *PubSub) NumPat() int {
+	ps.mu.RLock()
+	defer ps.mu.RUnlock()
+	return len(ps.patterns)
+}
+
+/*
+ 	* send queues a message without waiting, a client past the output buffer limit is disconnected rather than
+	* slowing down the publisher, PubSub.mu must be held
+	* @param message RESP.RESPMessage - the message
+*/
+func (sub *subscriber) send(message RESP.RESPMessage) {
+	size := encodedSize(message)
+
+	sub.queueMu.Lock()
+	if sub.closed {
+		sub.queueMu.Unlock()
+		return
+	}
+	sub.queue = append(sub.queue, queuedMessage{message, size})
+	sub.queuedBytes += size
+	overLimit := sub.queuedBytes > subscriberHardLimit
+	if sub.queuedBytes > subscriberSoftLimit {
+		if sub.softSince.IsZero() {
+			sub.softSince = time.Now()
+		}
+		overLimit = overLimit || time.Since(sub.softSince) > subscriberSoftPeriod
+	}
+	sub.queueMu.Unlock()
+	sub.ready.Signal()
+
+	if overLimit {
+		sub.slow.Do(sub.onSlow)
+	}
+}
+
+// deliver writes the queued messages to the connection until the client is unregistered
+func (sub *subscriber) deliver() {
+	sub.queueMu.Lock()
+	for {
+		for len(sub.queue) == 0 && !sub.closed {
+			sub.ready.Wait()
+		}
+		if sub.closed {
+			sub.queueMu.Unlock()
+			return
+		}
+		messages := sub.queue
+		sub.queue = nil
+		sub.queueMu.Unlock()
+
+		for _, queued := range messages {
+			sub.replies.Lock()
+			err := sub.writer.Encode(&queued.message)
+			sub.replies.Unlock()
+
+			if err != nil {
+				// the connection is broken, drop what is left until the client is unregistered
+				sub.slow.Do(sub.onSlow)
+			}
+
+			sub.queueMu.Lock()
+			sub.queuedBytes -= queued.size
+			if sub.queuedBytes <= subscriberSoftLimit {
+				sub.softSince = time.Time{}
+			}
+			sub.queueMu.Unlock()
+		}
+
+		sub.queueMu.Lock()
+	}
+}
+
+/*
+ 	* encodedSize returns how many bytes a message takes once encoded, what it counts for in the output buffer limit
+	* @param message RESP.RESPMessage - a bulk string or an array of them
+	* @return int - the size
+*/
+func encodedSize(message RESP.RESPMessage) int {
+	if message.RESPType == RESP.Array {
+		size := 1 + len(strconv.Itoa(len(message.RESPArrayElem))) + 2
+		for _, element := range message.RESPArrayElem {
+			size += encodedSize(element)
+		}
+		return size
+	}
+	return 1 + len(strconv.Itoa(len(message.RESPValue))) + 2 + len(message.RESPValue) + 2
+}
+
+func addSubscriber(index map[string]map[*subscriber]struct{}, name string, sub *subscriber) {
+	if index[name] == nil {
+		index[name] = make(map[*subscriber]struct{})
+	}
+	index[name][sub] = struct{}{}
+}
+
+func removeSubscriber(index map[string]map[*subscriber]struct{}, name string, sub *subscriber) {
+	delete(index[name], sub)
+	if len(index[name]) == 0 {
+		delete(index, name)
+	}
+}
+
+func bulkString(value string) RESP.RESPMessage {
+	return bulkStringBytes([]byte(value))
+}
+
+func bulkStringBytes(value []byte) RESP.RESPMessage {
+	if value == nil {
+		value = []byte{} // an empty message is still a message, not a nil
+	}
+	return RESP.RESPMessage{RESPType: RESP.BulkString, RESPLen: len(value), RESPValue: value}
+}
+
+func arrayMessage(elements ...RESP.RESPMessage) RESP.RESPMessage {
+	return RESP.RESPMessage{RESPType: RESP.Array, RESPLen: len(elements), RESPArrayElem: elements}
+}
@@ -109,10 +109,17 @@ func (r *Reader) readLength() (l int, err error) {
 }
 
 /*
- 	* encodeNil encodes a nil value
+ 	* EncodeNil encodes a nil value
 	* @return error - the error if there is one
 */
 func (w *Writer) EncodeNil() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.encodeNil()
+}
+
+// encodeNil encodes a nil value, the lock must be held
+func (w *Writer) encodeNil() error {
 	if _, err := w.writer.Write([]byte("$-1\r\n")); err != nil {
 		return err
 	}
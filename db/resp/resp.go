@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"io"
 	"strconv"
+	"sync"
 )
 
 const (
@@ -126,10 +127,18 @@ func (r *Reader) decodeBulkString() (*RESPMessage, error) {
 		return nil, fmt.Errorf("bulk string length exceeds limit")
 	}
 
-	if length <= 0 {
+	if length < 0 {
 		return &RESPMessage{RESPType: BulkString, RESPLen: 0, RESPValue: nil}, nil
 	}
 
+	// an empty string still has its trailing \r\n, and must stay distinguishable from nil
+	if length == 0 {
+		if _, _, err := r.readLine(); err != nil {
+			return nil, err
+		}
+		return &RESPMessage{RESPType: BulkString, RESPLen: 0, RESPValue: []byte{}}, nil
+	}
+
 	content := make([]byte, length)
 	if _, err := io.ReadFull(r.reader, content); err != nil {
 		return nil, err
@@ -172,6 +181,7 @@ func (r *Reader) decodeArray() (*RESPMessage, error) {
 * Writer is a writer for RESP messages
  */
 type Writer struct {
+	mu     sync.Mutex // the replies of a client and the messages published to it are written from different goroutines
 	writer *bufio.Writer
 }
 
@@ -190,7 +200,17 @@ func NewWriter(w io.Writer) *Writer {
 	* @return error - the error if there is one
 */
 func (w *Writer) Encode(msg *RESPMessage) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.encode(msg)
+}
 
+/*
+ 	* encode encodes a RESP message, the lock must be held
+	* @param msg *RESPMessage - the RESP message to encode
+	* @return error - the error if there is one
+*/
+func (w *Writer) encode(msg *RESPMessage) error {
 	switch msg.RESPType {
 
 	case SimpleString:
@@ -283,7 +303,7 @@ func (w *Writer) encodeInteger(msg *RESPMessage) error {
 */
 func (w *Writer) encodeBulkString(msg *RESPMessage) error {
 	if msg.RESPValue == nil {
-		return w.EncodeNil()
+		return w.encodeNil()
 	}
 
 	if err := w.writer.WriteByte(BulkString); err != nil {
@@ -328,7 +348,7 @@ func (w *Writer) encodeArray(msg *RESPMessage) error {
 	}
 
 	for _, element := range msg.RESPArrayElem {
-		if err := w.Encode(&element); err != nil {
+		if err := w.encode(&element); err != nil {
 			return fmt.Errorf("error encoding array element: %v", err)
 		}
 	}
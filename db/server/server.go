@@ -9,9 +9,11 @@ import (
 	"os"
 	"path/filepath"
 	"strings"
+	"time"
 
 	Handlers "github.com/manish-singh-bisht/Redis-From-Scratch/db/handlers"
 	config "github.com/manish-singh-bisht/Redis-From-Scratch/db/persistence"
+	pubsub "github.com/manish-singh-bisht/Redis-From-Scratch/db/pubsub"
 	RESP "github.com/manish-singh-bisht/Redis-From-Scratch/db/resp"
 	store "github.com/manish-singh-bisht/Redis-From-Scratch/db/store"
 	tx "github.com/manish-singh-bisht/Redis-From-Scratch/db/transaction"
@@ -23,6 +25,7 @@ type RedisServer struct {
 	store     *store.Store
 	listener  net.Listener
 	txManager *tx.TxManager
+	pubsub    *pubsub.PubSub
 }
 
 func NewRedisServer(host string, port int) *RedisServer {
@@ -31,6 +34,7 @@ func NewRedisServer(host string, port int) *RedisServer {
 		port:      port,
 		store:     store.GetStore(),
 		txManager: tx.NewTxManager(),
+		pubsub:    pubsub.GetPubSub(),
 	}
 }
 
@@ -45,21 +49,22 @@ func (redisServer *RedisServer) Start(dir, dbFilename string) error {
 	welcomeMessage()
 	config.InitConfig(dir, dbFilename)
 
-	rdbPath := filepath.Join(dir, dbFilename)
+	// the AOF has finer grained durability than the RDB snapshot, so when it is there it wins
+	if config.AOFExists() {
+		if err := redisServer.loadAOF(); err != nil {
+			return fmt.Errorf("failed to load AOF file: %v", err)
+		}
+	} else {
+		redisServer.loadRDB(filepath.Join(dir, dbFilename))
+	}
 
-	if _, err := os.Stat(rdbPath); err == nil {
-		log.Println("Loading RDB file:", rdbPath)
+	if err := config.OpenAOF(redisServer.store); err != nil {
+		return err
+	}
 
-		parser := config.GetRDBInstance()
-		parsedData, err := parser.Parse(rdbPath)
-		if err != nil {
-			log.Printf("Error loading RDB file: %v\n", err)
-		} else {
-			for _, kv := range parsedData {
-				redisServer.store.Set(kv.Key, kv.Value, kv.ExpiresIn)
-			}
-		}
-	} 
+	// a key that expires is a modified key, transactions watching it must abort
+	redisServer.store.OnExpire(redisServer.txManager.SignalModifiedKey)
+	redisServer.store.StartActiveExpire(config.GetExpireConfig())
 
 	for {
 		conn, err := redisServer.listener.Accept()
@@ -73,13 +78,114 @@ func (redisServer *RedisServer) Start(dir, dbFilename string) error {
 	}
 }
 
+/*
+ 	* loadRDB loads the keys of the RDB file into the store, if the file exists
+	* @param rdbPath string - the path to the RDB file
+*/
+func (redisServer *RedisServer) loadRDB(rdbPath string) {
+	if _, err := os.Stat(rdbPath); err != nil {
+		return
+	}
+
+	log.Println("Loading RDB file:", rdbPath)
+
+	parser := config.GetRDBInstance()
+	parsedData, err := parser.Parse(rdbPath)
+	if err != nil {
+		log.Printf("Error loading RDB file: %v\n", err)
+		return
+	}
+
+	for _, kv := range parsedData {
+		if kv.Key == "" { // expired while the server was down
+			continue
+		}
+
+		switch kv.Type {
+		case config.RDB_STREAM_LISTPACKS, config.RDB_STREAM_LISTPACKS_2, config.RDB_STREAM_LISTPACKS_3:
+			for _, entry := range kv.Stream {
+				if _, _, err := redisServer.store.XAdd(kv.Key, entry.Id, entry.Data, false, nil); err != nil {
+					log.Printf("Error loading stream %s entry %s: %v\n", kv.Key, entry.Id, err)
+				}
+			}
+			if err := redisServer.store.RestoreStreamLastId(kv.Key, kv.StreamLastId); err != nil {
+				log.Printf("Error loading stream %s last ID: %v\n", kv.Key, err)
+			}
+			if len(kv.StreamGroups) > 0 {
+				redisServer.store.RestoreStreamGroups(kv.Key, kv.StreamGroups)
+			}
+			if kv.ExpiresIn > 0 {
+				redisServer.store.ExpireAt(kv.Key, time.Now().Add(kv.ExpiresIn), store.ExpireAlways)
+			}
+		case config.RDB_LIST, config.RDB_LIST_QUICKLIST_2:
+			redisServer.store.RestoreList(kv.Key, kv.Elements, kv.ExpiresIn)
+		case config.RDB_SET, config.RDB_SET_INTSET, config.RDB_SET_LISTPACK:
+			redisServer.store.RestoreSet(kv.Key, kv.Elements, kv.ExpiresIn)
+		case config.RDB_ZSET, config.RDB_ZSET_2, config.RDB_ZSET_LISTPACK:
+			redisServer.store.RestoreZSet(kv.Key, kv.ZMembers, kv.ExpiresIn)
+		case config.RDB_HASH, config.RDB_HASH_LISTPACK, config.RDB_HASH_METADATA:
+			redisServer.store.RestoreHash(kv.Key, kv.Fields, kv.ExpiresIn)
+		case config.RDB_MODULE_2:
+			if kv.Module == store.TypeIDGen {
+				redisServer.store.RestoreIDGenerator(kv.Key, kv.IDGen, kv.ExpiresIn)
+			} else {
+				redisServer.store.RestoreRateMeter(kv.Key, kv.Meter, kv.ExpiresIn)
+			}
+		default:
+			redisServer.store.Set(kv.Key, kv.Value, kv.ExpiresIn)
+		}
+	}
+}
+
+/*
+ 	* loadAOF replays every command of the append only file through the regular command path
+	* @return error - the error if there is one
+*/
+func (redisServer *RedisServer) loadAOF() error {
+	commands, err := config.LoadAOF()
+	if err != nil {
+		return err
+	}
+
+	log.Printf("Replaying %d commands from the AOF file", len(commands))
+
+	writer := RESP.NewWriter(io.Discard) // replies of replayed commands go nowhere
+	for _, argv := range commands {
+		args := make([]RESP.RESPMessage, len(argv)-1)
+		for i, arg := range argv[1:] {
+			args[i] = RESP.RESPMessage{
+				RESPType:  RESP.BulkString,
+				RESPLen:   len(arg),
+				RESPValue: arg,
+			}
+		}
+
+		err := Handlers.ExecuteCommand(writer, string(argv[0]), args, redisServer.store, Handlers.AOFLoaderClientID, redisServer.txManager)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
 func (redisServer *RedisServer) handleConnection(conn net.Conn) {
 	defer conn.Close()
 
 	clientID := generateClientID()
+	Handlers.RegisterClient(clientID, conn.RemoteAddr().String())
+	defer Handlers.UnregisterClient(clientID)
+
+	// the transaction and watches of the client are dropped with the connection, unless it has a session to keep them for its TTL
+	defer redisServer.txManager.Disconnect(clientID)
+
 	reader := RESP.NewReader(conn)
 	writer := RESP.NewWriter(conn)
 
+	// messages published to the client are written by a goroutine of its own, a client too slow to read them is disconnected
+	redisServer.pubsub.Register(clientID, writer, func() { conn.Close() })
+	defer redisServer.pubsub.Unregister(clientID)
+
 	for {
 		msg, err := reader.Decode()
 		if err != nil {
@@ -100,6 +206,12 @@ func (redisServer *RedisServer) handleConnection(conn net.Conn) {
 		cmd := string(msg.RESPArrayElem[0].RESPValue)
 		args := msg.RESPArrayElem[1:]
 
+		// in subscribed mode, only (P)(UN)SUBSCRIBE, PING and QUIT
+		if err := Handlers.CheckSubscribedMode(clientID, cmd); err != nil {
+			Handlers.HandleError(writer, []byte(err.Error()))
+			continue
+		}
+
 		err = Handlers.ExecuteCommand(writer, cmd, args, redisServer.store, clientID, redisServer.txManager)
 		if err != nil {
 			log.Printf("Error executing command: %v", err)
@@ -120,8 +232,55 @@ const (
 func DbStart() {
 	dir := flag.String("dir", ".", "RDB file directory")
 	dbFilename := flag.String("dbfilename", "dump.rdb", "RDB filename")
+	appendOnly := flag.String("appendonly", "no", "log every write command to the append only file (yes|no)")
+	appendFilename := flag.String("appendfilename", "appendonly.aof", "append only filename, relative to dir")
+	appendFsync := flag.String("appendfsync", config.FsyncEverySec, "when to fsync the append only file (always|everysec|no)")
+	aofLoadTruncated := flag.String("aof-load-truncated", "yes", "load an append only file whose last command was cut short (yes|no)")
+	hz := flag.Int("hz", store.DefaultHz, "how many times per second background tasks like the active expire cycle run (1-500)")
+	activeExpireEffort := flag.Int("active-expire-effort", store.DefaultActiveExpireEffort, "how much work the active expire cycle does to free expired keys (1-10)")
+	keyTraceFile := flag.String("keytrace-file", "keytrace.log", "file KEYTRACE logs the commands on traced keys to, relative to dir")
+	keyTraceMaxSize := flag.Int64("keytrace-max-size", 64*1024*1024, "size in bytes past which the key trace file is rotated")
+	keyTraceMaxFiles := flag.Int("keytrace-max-files", 5, "how many rotated key trace files are kept")
+	nodeID := flag.Int("node-id", 0, fmt.Sprintf("node ID embedded in snowflake IDs handed out by NEXTID, unique per server (0-%d)", store.MaxNodeID))
 	flag.Parse()
 
+	if !config.IsValidFsyncPolicy(*appendFsync) {
+		log.Fatalf("Invalid appendfsync policy: %s", *appendFsync)
+	}
+	if *hz < store.MinHz || *hz > store.MaxHz {
+		log.Fatalf("Invalid hz: %d, must be between %d and %d", *hz, store.MinHz, store.MaxHz)
+	}
+	if *activeExpireEffort < store.MinActiveExpireEffort || *activeExpireEffort > store.MaxActiveExpireEffort {
+		log.Fatalf("Invalid active-expire-effort: %d, must be between %d and %d", *activeExpireEffort, store.MinActiveExpireEffort, store.MaxActiveExpireEffort)
+	}
+
+	if *nodeID < 0 || *nodeID > store.MaxNodeID {
+		log.Fatalf("Invalid node-id: %d, must be between 0 and %d", *nodeID, store.MaxNodeID)
+	}
+
+	if *keyTraceMaxSize < 1 {
+		log.Fatalf("Invalid keytrace-max-size: %d, must be positive", *keyTraceMaxSize)
+	}
+	if *keyTraceMaxFiles < 0 {
+		log.Fatalf("Invalid keytrace-max-files: %d, can't be negative", *keyTraceMaxFiles)
+	}
+
+	config.InitExpireConfig(*hz, *activeExpireEffort)
+	config.InitNodeID(*nodeID)
+
+	config.InitAOFConfig(config.AOFConfig{
+		Enabled:       *appendOnly == "yes",
+		Filename:      *appendFilename,
+		Fsync:         *appendFsync,
+		LoadTruncated: *aofLoadTruncated == "yes",
+	})
+
+	config.InitKeyTraceConfig(config.KeyTraceConfig{
+		Filename: *keyTraceFile,
+		MaxSize:  *keyTraceMaxSize,
+		MaxFiles: *keyTraceMaxFiles,
+	})
+
 	server := NewRedisServer(HOST, PORT)
 	if err := server.Start(*dir, *dbFilename); err != nil {
 		log.Fatalf("Failed to start Redis server: %v", err)
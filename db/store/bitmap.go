@@ -0,0 +1,544 @@
+package store
+
+import (
+	"math/bits"
+	"time"
+)
+
+// the largest bit offset, the last bit of a string of stringMaxLen bytes
+const BitOffsetMax = stringMaxLen*8 - 1
+
+// BitOpKind is the operation of BITOP
+type BitOpKind int
+
+const (
+	BitOpAnd BitOpKind = iota
+	BitOpOr
+	BitOpXor
+	BitOpNot
+)
+
+// BitFieldOverflow is how BITFIELD handles a SET or INCRBY that doesn't fit in the field
+type BitFieldOverflow int
+
+const (
+	OverflowWrap BitFieldOverflow = iota // wrap around, like integer arithmetic does
+	OverflowSat                          // saturate to the minimum or maximum value of the field
+	OverflowFail                         // don't write, the reply for the operation is nil
+)
+
+// BitFieldOpKind is the subcommand of a BITFIELD operation
+type BitFieldOpKind int
+
+const (
+	BitFieldGet BitFieldOpKind = iota
+	BitFieldSet
+	BitFieldIncrBy
+)
+
+// BitFieldOp is one GET, SET or INCRBY of a BITFIELD command
+type BitFieldOp struct {
+	Kind     BitFieldOpKind
+	Signed   bool
+	Bits     int    // the width of the field, 1 to 64 for signed fields and 1 to 63 for unsigned ones
+	Offset   uint64 // the bit offset of the field
+	Value    int64  // the value for SET, the increment for INCRBY
+	Overflow BitFieldOverflow
+}
+
+/*
+ 	* bitmapLocked returns the bytes of the string at key, the key-value write lock must be held
+	* @param key string - the key of the string
+	* @param now time.Time - the current time
+	* @return []byte - the bytes, nil if the key doesn't exist
+	* @return error - ErrWrongType if the key holds something other than a string
+*/
+func (s *Store) bitmapLocked(key string, now time.Time) ([]byte, error) {
+	stored, _, err := s.stringLocked(key, now)
+	if err != nil {
+		return nil, err
+	}
+	return stored.bytes(), nil
+}
+
+/*
+ 	* growBitmapLocked returns the string at key at least size bytes long, padded with zero bytes, for a command to modify
+	* and store back with storeBitmapLocked. the key-value write lock must be held
+	* @param key string - the key of the string
+	* @param now time.Time - the current time
+	* @param size uint64 - the length the string must have
+	* @return []byte - the string, or a copy of it when it can't be modified in place
+	* @return storedValue - the value holding the string, to store it back with
+	* @return error - ErrWrongType if the key holds something other than a string
+*/
+func (s *Store) growBitmapLocked(key string, now time.Time, size uint64) ([]byte, storedValue, error) {
+	stored, exists, err := s.stringLocked(key, now)
+	if err != nil {
+		return nil, stored, err
+	}
+	if !exists {
+		stored = storedValue{}
+	}
+
+	current := stored.bytes()
+	if stored.inPlace {
+		// only this key holds the bytes, they are modified in place and append grows them the way sdsMakeRoomFor does
+		if grow := int(size) - len(current); grow > 0 {
+			current = append(current, make([]byte, grow)...)
+		}
+		return current, stored, nil
+	}
+
+	// a copy, the old bytes may still be in use by a reply being written or a command waiting in the AOF
+	bitmap := make([]byte, max(uint64(len(current)), size))
+	copy(bitmap, current)
+	return bitmap, stored, nil
+}
+
+/*
+ 	* storeBitmapLocked stores the bytes returned by growBitmapLocked back at key, keeping its expiration, the next SETBIT
+	* or BITFIELD may modify them in place until a reply takes them
+	* @param key string - the key of the string
+	* @param bitmap []byte - the bytes
+	* @param stored storedValue - the value returned by growBitmapLocked
+*/
+func (s *Store) storeBitmapLocked(key string, bitmap []byte, stored storedValue) {
+	stored.setBytes(bitmap)
+	stored.inPlace = true
+	s.kv.put(key, stored)
+}
+
+/*
+ 	* SetBit sets or clears the bit at offset of the string at key, growing it as needed, the key is created if it
+	* doesn't exist and the expiration is kept
+	* @param key string - the key of the string
+	* @param offset uint64 - the bit offset, up to BitOffsetMax
+	* @param bit int - 1 to set the bit, 0 to clear it
+	* @return int - the bit it held before
+	* @return error - ErrWrongType if the key holds something other than a string
+*/
+func (s *Store) SetBit(key string, offset uint64, bit int) (int, error) {
+	s.kv.mu.Lock()
+	defer s.kv.mu.Unlock()
+
+	bitmap, stored, err := s.growBitmapLocked(key, time.Now(), offset/8+1)
+	if err != nil {
+		return 0, err
+	}
+
+	old := getBit(bitmap, offset)
+	setBit(bitmap, offset, bit)
+
+	s.storeBitmapLocked(key, bitmap, stored)
+	return old, nil
+}
+
+/*
+ 	* GetBit returns the bit at offset of the string at key, bits past the end of the string are 0
+	* @param key string - the key of the string
+	* @param offset uint64 - the bit offset
+	* @return int - the bit
+	* @return error - ErrWrongType if the key holds something other than a string
+*/
+func (s *Store) GetBit(key string, offset uint64) (int, error) {
+	s.kv.mu.Lock()
+	defer s.kv.mu.Unlock()
+
+	bitmap, err := s.bitmapLocked(key, time.Now())
+	if err != nil {
+		return 0, err
+	}
+	return getBit(bitmap, offset), nil
+}
+
+/*
+ 	* bitRange turns the start and end of BITCOUNT and BITPOS into the first and last bit to look at, negative offsets
+	* count from the end, and they are bytes unless inBits is set
+	* @param length int - the length of the string, in bytes
+	* @param start int64 - the first offset
+	* @param end int64 - the last offset
+	* @param inBits bool - the offsets are bits rather than bytes
+	* @return int64 - the first bit
+	* @return int64 - the last bit
+	* @return bool - false if the range is empty
+*/
+func bitRange(length int, start, end int64, inBits bool) (int64, int64, bool) {
+	total := int64(length)
+	if inBits {
+		total *= 8
+	}
+
+	if start < 0 && end < 0 && start > end {
+		return 0, 0, false
+	}
+	if start < 0 {
+		start = max(total+start, 0)
+	}
+	if end < 0 {
+		end = max(total+end, 0)
+	}
+	end = min(end, total-1)
+	if start > end {
+		return 0, 0, false
+	}
+
+	if inBits {
+		return start, end, true
+	}
+	return start * 8, end*8 + 7, true
+}
+
+/*
+ 	* BitCount counts the bits set in the string at key, all of it or between start and end, both inclusive
+	* @param key string - the key of the string
+	* @param start int64 - the first offset, negative offsets count from the end
+	* @param end int64 - the last offset
+	* @param hasRange bool - count only between start and end
+	* @param inBits bool - start and end are bits rather than bytes
+	* @return int64 - the number of bits set
+	* @return error - ErrWrongType if the key holds something other than a string
+*/
+func (s *Store) BitCount(key string, start, end int64, hasRange, inBits bool) (int64, error) {
+	s.kv.mu.Lock()
+	defer s.kv.mu.Unlock()
+
+	bitmap, err := s.bitmapLocked(key, time.Now())
+	if err != nil {
+		return 0, err
+	}
+
+	if !hasRange {
+		start, end, inBits = 0, -1, false
+	}
+	first, last, ok := bitRange(len(bitmap), start, end, inBits)
+	if !ok {
+		return 0, nil
+	}
+
+	var count int64
+	for i := first / 8; i <= last/8; i++ {
+		b := bitmap[i]
+		// mask out the bits of the first and last byte that are out of the range
+		if i == first/8 {
+			b &= 0xff >> (first % 8)
+		}
+		if i == last/8 {
+			b &= 0xff << (7 - last%8)
+		}
+		count += int64(bits.OnesCount8(b))
+	}
+	return count, nil
+}
+
+/*
+ 	* BitPos returns the position of the first bit set to 1 or 0 in the string at key, all of it or between start and end.
+	* looking for a 0 in a string of ones with no end given finds the first bit past the string, like Redis does
+	* @param key string - the key of the string
+	* @param bit int - the bit to look for, 1 or 0
+	* @param start int64 - the first offset, negative offsets count from the end
+	* @param end int64 - the last offset
+	* @param hasStart bool - start was given
+	* @param hasEnd bool - end was given
+	* @param inBits bool - start and end are bits rather than bytes
+	* @return int64 - the bit position, -1 if there is none
+	* @return error - ErrWrongType if the key holds something other than a string
+*/
+func (s *Store) BitPos(key string, bit int, start, end int64, hasStart, hasEnd, inBits bool) (int64, error) {
+	s.kv.mu.Lock()
+	defer s.kv.mu.Unlock()
+
+	bitmap, err := s.bitmapLocked(key, time.Now())
+	if err != nil {
+		return 0, err
+	}
+
+	if len(bitmap) == 0 {
+		if bit == 1 {
+			return -1, nil
+		}
+		return 0, nil
+	}
+
+	if !hasStart {
+		start = 0
+	}
+	if !hasEnd {
+		end = -1
+	}
+	first, last, ok := bitRange(len(bitmap), start, end, inBits)
+	if !ok {
+		return -1, nil
+	}
+
+	// whole bytes with none of the bits we look for are skipped
+	skip := byte(0x00)
+	if bit == 0 {
+		skip = 0xff
+	}
+
+	for i := first; i <= last; {
+		if i%8 == 0 && i+7 <= last && bitmap[i/8] == skip {
+			i += 8
+			continue
+		}
+		if getBit(bitmap, uint64(i)) == bit {
+			return i, nil
+		}
+		i++
+	}
+
+	if bit == 0 && !hasEnd {
+		return last + 1, nil
+	}
+	return -1, nil
+}
+
+/*
+ 	* BitOp stores the bitwise AND, OR, XOR or NOT of the strings at keys in dst, overwriting whatever it held.
+	* shorter strings and missing keys count as zero bytes, and dst is deleted when the result is empty
+	* @param op BitOpKind - the operation, NOT takes a single key
+	* @param dst string - the key to store the result at
+	* @param keys []string - the keys of the strings
+	* @return int - the length of the result
+	* @return error - ErrWrongType if one of the keys holds something other than a string
+*/
+func (s *Store) BitOp(op BitOpKind, dst string, keys []string) (int, error) {
+	s.kv.mu.Lock()
+	defer s.kv.mu.Unlock()
+
+	now := time.Now()
+	sources := make([][]byte, len(keys))
+	length := 0
+	for i, key := range keys {
+		bitmap, err := s.bitmapLocked(key, now)
+		if err != nil {
+			return 0, err
+		}
+		sources[i] = bitmap
+		length = max(length, len(bitmap))
+	}
+
+	result := make([]byte, length)
+	for i := range result {
+		var b byte
+		if i < len(sources[0]) {
+			b = sources[0][i]
+		}
+
+		for _, source := range sources[1:] {
+			var other byte
+			if i < len(source) {
+				other = source[i]
+			}
+			switch op {
+			case BitOpAnd:
+				b &= other
+			case BitOpOr:
+				b |= other
+			case BitOpXor:
+				b ^= other
+			}
+		}
+
+		if op == BitOpNot {
+			b = ^b
+		}
+		result[i] = b
+	}
+
+	s.overwriteLocked(dst)
+	if length > 0 {
+		s.kv.put(dst, storedValue{value: result})
+	}
+	return length, nil
+}
+
+/*
+ 	* BitField runs the GET, SET and INCRBY operations of BITFIELD on the string at key, in order. the string grows as
+	* the SET and INCRBY operations need, and the key is created if it doesn't exist, the expiration is kept
+	* @param key string - the key of the string
+	* @param ops []BitFieldOp - the operations
+	* @return []int64 - the result of each operation, the value for GET, the old value for SET, the new one for INCRBY
+	* @return []bool - for each operation, false if it was not done because of OverflowFail
+	* @return error - ErrWrongType if the key holds something other than a string
+*/
+func (s *Store) BitField(key string, ops []BitFieldOp) ([]int64, []bool, error) {
+	s.kv.mu.Lock()
+	defer s.kv.mu.Unlock()
+
+	now := time.Now()
+
+	var size uint64
+	for _, op := range ops {
+		if op.Kind != BitFieldGet {
+			size = max(size, (op.Offset+uint64(op.Bits)-1)/8+1)
+		}
+	}
+
+	var bitmap []byte
+	var stored storedValue
+	var err error
+	if size > 0 {
+		bitmap, stored, err = s.growBitmapLocked(key, now, size)
+	} else {
+		bitmap, err = s.bitmapLocked(key, now)
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	results := make([]int64, len(ops))
+	done := make([]bool, len(ops))
+	for i, op := range ops {
+		current := getBitField(bitmap, op.Offset, op.Bits, op.Signed)
+		if op.Kind == BitFieldGet {
+			results[i], done[i] = current, true
+			continue
+		}
+
+		var value int64
+		var overflow bool
+		if op.Kind == BitFieldSet {
+			value, overflow = fitBitField(op.Value, 0, op.Bits, op.Signed, op.Overflow)
+		} else {
+			value, overflow = fitBitField(current, op.Value, op.Bits, op.Signed, op.Overflow)
+		}
+		if overflow && op.Overflow == OverflowFail {
+			continue
+		}
+
+		setBitField(bitmap, op.Offset, op.Bits, value)
+		if op.Kind == BitFieldSet {
+			results[i] = current
+		} else {
+			results[i] = value
+		}
+		done[i] = true
+	}
+
+	if size > 0 {
+		s.storeBitmapLocked(key, bitmap, stored)
+	}
+	return results, done, nil
+}
+
+/*
+ 	* getBit returns the bit at offset, bits are numbered from the most significant bit of the first byte
+	* @param bitmap []byte - the bytes
+	* @param offset uint64 - the bit offset
+	* @return int - the bit, 0 past the end
+*/
+func getBit(bitmap []byte, offset uint64) int {
+	if offset/8 >= uint64(len(bitmap)) {
+		return 0
+	}
+	return int(bitmap[offset/8]>>(7-offset%8)) & 1
+}
+
+/*
+ 	* setBit sets or clears the bit at offset, which must be within the bytes
+	* @param bitmap []byte - the bytes
+	* @param offset uint64 - the bit offset
+	* @param bit int - 1 to set the bit, 0 to clear it
+*/
+func setBit(bitmap []byte, offset uint64, bit int) {
+	mask := byte(1) << (7 - offset%8)
+	if bit == 1 {
+		bitmap[offset/8] |= mask
+	} else {
+		bitmap[offset/8] &^= mask
+	}
+}
+
+/*
+ 	* getBitField reads a field of width bits at offset, most significant bit first
+	* @param bitmap []byte - the bytes
+	* @param offset uint64 - the bit offset of the field
+	* @param width int - the width of the field
+	* @param signed bool - the field is a two's complement signed integer
+	* @return int64 - the value of the field
+*/
+func getBitField(bitmap []byte, offset uint64, width int, signed bool) int64 {
+	var value uint64
+	for i := 0; i < width; i++ {
+		value = value<<1 | uint64(getBit(bitmap, offset+uint64(i)))
+	}
+
+	// sign extend from the top bit of the field
+	if signed && width < 64 && value&(1<<(width-1)) != 0 {
+		value |= ^uint64(0) << width
+	}
+	return int64(value)
+}
+
+/*
+ 	* setBitField writes the low width bits of value at offset, most significant bit first, the field must be within the bytes
+	* @param bitmap []byte - the bytes
+	* @param offset uint64 - the bit offset of the field
+	* @param width int - the width of the field
+	* @param value int64 - the value
+*/
+func setBitField(bitmap []byte, offset uint64, width int, value int64) {
+	for i := 0; i < width; i++ {
+		bit := int(uint64(value)>>(width-1-i)) & 1
+		setBit(bitmap, offset+uint64(i), bit)
+	}
+}
+
+/*
+ 	* fitBitField adds incr to value and fits the result in a field of width bits as the overflow behavior says, with
+	* the same rules as Redis, SET is an increment of 0 to the value being set
+	* @param value int64 - the current value, or the value being set
+	* @param incr int64 - the increment
+	* @param width int - the width of the field
+	* @param signed bool - the field is a two's complement signed integer
+	* @param overflow BitFieldOverflow - how to handle a result that doesn't fit
+	* @return int64 - the result
+	* @return bool - true if the result didn't fit
+*/
+func fitBitField(value, incr int64, width int, signed bool, overflow BitFieldOverflow) (int64, bool) {
+	wrap := func() int64 {
+		c := uint64(value) + uint64(incr)
+		if signed && c&(1<<(width-1)) != 0 {
+			return int64(c | ^uint64(0)<<width)
+		}
+		return int64(c &^ (^uint64(0) << width))
+	}
+
+	if !signed {
+		maxValue := uint64(1)<<width - 1
+		maxIncr := int64(maxValue - uint64(value))
+		minIncr := -value
+		switch {
+		case uint64(value) > maxValue || incr > maxIncr:
+			if overflow == OverflowSat {
+				return int64(maxValue), true
+			}
+			return wrap(), true
+		case incr < 0 && incr < minIncr:
+			if overflow == OverflowSat {
+				return 0, true
+			}
+			return wrap(), true
+		}
+		return value + incr, false
+	}
+
+	maxValue := int64(uint64(1)<<(width-1) - 1)
+	minValue := -maxValue - 1
+	maxIncr := maxValue - value
+	minIncr := minValue - value
+	switch {
+	case value > maxValue || (width != 64 && incr > maxIncr) || (value >= 0 && incr > 0 && incr > maxIncr):
+		if overflow == OverflowSat {
+			return maxValue, true
+		}
+		return wrap(), true
+	case value < minValue || (width != 64 && incr < minIncr) || (value < 0 && incr < 0 && incr < minIncr):
+		if overflow == OverflowSat {
+			return minValue, true
+		}
+		return wrap(), true
+	}
+	return value + incr, false
+}
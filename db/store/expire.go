@@ -0,0 +1,369 @@
+package store
+
+import (
+	"time"
+)
+
+// the active expire cycle follows Redis: sample keys that have a TTL, delete the expired ones,
+// and go again while more than an acceptable share of the sample was expired, within a time budget per cycle.
+// these are the values for effort 1, higher efforts sample more keys, accept fewer stale ones and get more time
+const (
+	activeExpireKeysPerLoop   = 20 // keys sampled per loop
+	activeExpireAcceptedStale = 10 // % of expired keys in a sample below which the cycle stops
+	activeExpireCycleTimePerc = 25 // % of each 1/hz period the cycle may use
+)
+
+const (
+	DefaultHz                 = 10
+	DefaultActiveExpireEffort = 1
+	MinHz                     = 1
+	MaxHz                     = 500
+	MinActiveExpireEffort     = 1
+	MaxActiveExpireEffort     = 10
+)
+
+// ExpireCondition holds the NX, XX, GT and LT flags of the EXPIRE family, XX can be combined with GT or LT
+type ExpireCondition int
+
+const ExpireAlways ExpireCondition = 0
+
+const (
+	ExpireIfNoTTL   ExpireCondition = 1 << iota // NX, only when the key has no expiration
+	ExpireIfHasTTL                              // XX, only when the key has an expiration
+	ExpireIfGreater                             // GT, only when the new expiration is later, a key without one never matches
+	ExpireIfLess                                // LT, only when the new expiration is sooner, a key without one always matches
+)
+
+// expireSampler is a keyspace the active expire cycle can clean up
+type expireSampler interface {
+	expireSample(n int) (int, int)
+}
+
+/*
+ 	* met checks if the condition allows replacing the current expiration with at
+	* @param current time.Time - the current expiration, zero if there is none
+	* @param at time.Time - the new expiration
+	* @return bool - true if the expiration can be set, false otherwise
+*/
+func (condition ExpireCondition) met(current, at time.Time) bool {
+	hasTTL := !current.IsZero()
+
+	if condition&ExpireIfNoTTL != 0 && hasTTL {
+		return false
+	}
+	if condition&ExpireIfHasTTL != 0 && !hasTTL {
+		return false
+	}
+	if condition&ExpireIfGreater != 0 && (!hasTTL || !at.After(current)) {
+		return false
+	}
+	if condition&ExpireIfLess != 0 && hasTTL && !at.Before(current) {
+		return false
+	}
+	return true
+}
+
+/*
+ 	* startActiveExpire runs the active expire cycle hz times per second in the background
+	* @param hz int - how many cycles per second
+	* @param effort int - from 1 to 10, how hard each cycle tries to keep expired keys out of memory
+*/
+func (s *Store) startActiveExpire(hz, effort int) {
+	go func() {
+		ticker := time.NewTicker(time.Second / time.Duration(hz))
+		defer ticker.Stop()
+
+		for range ticker.C {
+			activeExpireCycle(s.kv, hz, effort)
+			activeExpireCycle(s.streams, hz, effort)
+			activeExpireCycle(hashFieldSampler{s.kv}, hz, effort)
+		}
+	}()
+}
+
+/*
+ 	* activeExpireCycle deletes expired keys that nobody accessed, which lazy expiration would never free
+	* @param keyspace expireSampler - the keys to clean up
+	* @param hz int - how many cycles per second, used for the time budget
+	* @param effort int - from 1 to 10
+*/
+func activeExpireCycle(keyspace expireSampler, hz, effort int) {
+	extra := effort - 1
+	keysPerLoop := activeExpireKeysPerLoop + activeExpireKeysPerLoop/4*extra
+	acceptedStale := activeExpireAcceptedStale - extra
+	timeLimit := time.Second * time.Duration(activeExpireCycleTimePerc+2*extra) / time.Duration(hz) / 100
+
+	start := time.Now()
+	for {
+		sampled, expired := keyspace.expireSample(keysPerLoop)
+		if sampled == 0 || expired*100/sampled <= acceptedStale {
+			return
+		}
+		if time.Since(start) > timeLimit {
+			return
+		}
+	}
+}
+
+/*
+ 	* expireSample checks up to n keys that have a TTL and deletes the expired ones.
+	* map iteration starts at a random position, which is what makes this a sample
+	* @param n int - the maximum number of keys to check
+	* @return int - the number of keys checked
+	* @return int - the number of keys deleted
+*/
+func (kv *keyValueStore) expireSample(n int) (int, int) {
+	kv.mu.Lock()
+	defer kv.mu.Unlock()
+
+	now := time.Now()
+	sampled, expired := 0, 0
+
+	for key := range kv.expires {
+		if sampled == n {
+			break
+		}
+		sampled++
+
+		if kv.store[key].isExpired(now) {
+			kv.removeExpired(key)
+			expired++
+		}
+	}
+
+	return sampled, expired
+}
+
+// hashFieldSampler samples the hashes that have fields with a TTL, instead of the keys that have one
+type hashFieldSampler struct {
+	kv *keyValueStore
+}
+
+/*
+ 	* expireSample checks up to n hashes with field TTLs and deletes their expired fields, and the hash once it is empty
+	* @param n int - the maximum number of hashes to check
+	* @return int - the number of hashes checked
+	* @return int - the number of hashes that had expired fields
+*/
+func (sampler hashFieldSampler) expireSample(n int) (int, int) {
+	kv := sampler.kv
+	kv.mu.Lock()
+	defer kv.mu.Unlock()
+
+	now := time.Now()
+	sampled, expired := 0, 0
+
+	for key := range kv.hashFieldExpires {
+		if sampled == n {
+			break
+		}
+		sampled++
+
+		if kv.expireHashFieldsLocked(key, now) > 0 {
+			expired++
+		}
+	}
+
+	return sampled, expired
+}
+
+/*
+ 	* expireSample checks up to n streams that have a TTL and deletes the expired ones
+	* @param n int - the maximum number of streams to check
+	* @return int - the number of streams checked
+	* @return int - the number of streams deleted
+*/
+func (sm *streamManager) expireSample(n int) (int, int) {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+
+	now := time.Now()
+	sampled, expired := 0, 0
+
+	for streamName := range sm.expires {
+		if sampled == n {
+			break
+		}
+		sampled++
+
+		if sm.streams[streamName].isExpired(now) {
+			sm.removeExpired(streamName)
+			expired++
+		}
+	}
+
+	return sampled, expired
+}
+
+/*
+ 	* expireAt sets the expiration of a key if the condition allows it, a time that already passed deletes the key
+	* @param key string - the key
+	* @param at time.Time - the new expiration
+	* @param condition ExpireCondition - when to set it
+	* @return bool - true if the expiration was set or the key deleted
+	* @return bool - true if the key exists, false otherwise
+*/
+func (kv *keyValueStore) expireAt(key string, at time.Time, condition ExpireCondition) (bool, bool) {
+	kv.mu.Lock()
+	defer kv.mu.Unlock()
+
+	now := time.Now()
+	stored, exists := kv.lookupLocked(key, now)
+	if !exists {
+		return false, false
+	}
+
+	if !condition.met(stored.expiration, at) {
+		return false, true
+	}
+
+	if !at.After(now) {
+		kv.remove(key)
+		return true, true
+	}
+
+	stored.expiration = at
+	kv.put(key, stored)
+	return true, true
+}
+
+/*
+ 	* expireAt sets the expiration of a stream if the condition allows it, a time that already passed deletes the stream
+	* @param streamName string - the name of the stream
+	* @param at time.Time - the new expiration
+	* @param condition ExpireCondition - when to set it
+	* @return bool - true if the expiration was set or the stream deleted
+	* @return bool - true if the stream exists, false otherwise
+*/
+func (sm *streamManager) expireAt(streamName string, at time.Time, condition ExpireCondition) (bool, bool) {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+
+	now := time.Now()
+	stream, exists := sm.getStreamLocked(streamName, now)
+	if !exists {
+		return false, false
+	}
+
+	if !condition.met(stream.expiration, at) {
+		return false, true
+	}
+
+	if !at.After(now) {
+		sm.remove(streamName)
+		return true, true
+	}
+
+	sm.setExpiration(streamName, stream, at)
+	return true, true
+}
+
+/*
+ 	* persist removes the expiration of a key
+	* @param key string - the key
+	* @return bool - true if the key had an expiration that was removed
+	* @return bool - true if the key exists, false otherwise
+*/
+func (kv *keyValueStore) persist(key string) (bool, bool) {
+	kv.mu.Lock()
+	defer kv.mu.Unlock()
+
+	stored, exists := kv.lookupLocked(key, time.Now())
+	if !exists {
+		return false, false
+	}
+
+	if stored.expiration.IsZero() {
+		return false, true
+	}
+
+	stored.expiration = time.Time{}
+	kv.put(key, stored)
+	return true, true
+}
+
+/*
+ 	* persist removes the expiration of a stream
+	* @param streamName string - the name of the stream
+	* @return bool - true if the stream had an expiration that was removed
+	* @return bool - true if the stream exists, false otherwise
+*/
+func (sm *streamManager) persist(streamName string) (bool, bool) {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+
+	stream, exists := sm.getStreamLocked(streamName, time.Now())
+	if !exists {
+		return false, false
+	}
+
+	if stream.expiration.IsZero() {
+		return false, true
+	}
+
+	sm.setExpiration(streamName, stream, time.Time{})
+	return true, true
+}
+
+/*
+ 	* expireTime returns the expiration of a stream
+	* @param streamName string - the name of the stream
+	* @return time.Time - the expiration, zero if the stream has none
+	* @return bool - true if the stream exists, false otherwise
+*/
+func (sm *streamManager) expireTime(streamName string) (time.Time, bool) {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+
+	stream, exists := sm.getStreamLocked(streamName, time.Now())
+	if !exists {
+		return time.Time{}, false
+	}
+
+	return stream.expiration, true
+}
+
+/*
+ 	* ExpireAt sets the expiration of a string or a stream, when the condition allows it.
+	* a time that already passed deletes the key right away
+	* @param key string - the key
+	* @param at time.Time - the new expiration
+	* @param condition ExpireCondition - when to set it
+	* @return bool - true if the expiration was set or the key deleted, false if the key doesn't exist or the condition wasn't met
+*/
+func (s *Store) ExpireAt(key string, at time.Time, condition ExpireCondition) bool {
+	if set, exists := s.kv.expireAt(key, at, condition); exists {
+		return set
+	}
+
+	set, _ := s.streams.expireAt(key, at, condition)
+	return set
+}
+
+/*
+ 	* Persist removes the expiration of a key
+	* @param key string - the key
+	* @return bool - true if the key had an expiration that was removed
+*/
+func (s *Store) Persist(key string) bool {
+	if removed, exists := s.kv.persist(key); exists {
+		return removed
+	}
+
+	removed, _ := s.streams.persist(key)
+	return removed
+}
+
+/*
+ 	* ExpireTime returns the expiration of a key
+	* @param key string - the key
+	* @return time.Time - the expiration, zero if the key has none
+	* @return bool - true if the key exists, false otherwise
+*/
+func (s *Store) ExpireTime(key string) (time.Time, bool) {
+	if stored, exists := s.kv.lookup(key); exists {
+		return stored.expiration, true
+	}
+
+	return s.streams.expireTime(key)
+}
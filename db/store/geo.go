@@ -0,0 +1,180 @@
+package store
+
+import (
+	"errors"
+	"slices"
+	"time"
+)
+
+var ErrGeoMemberNotFound = errors.New("ERR could not decode requested zset member")
+
+// GeoSort is the order GEOSEARCH returns its results in
+type GeoSort int
+
+const (
+	GeoSortNone GeoSort = iota
+	GeoSortAsc          // nearest first
+	GeoSortDesc         // farthest first
+)
+
+// GeoQuery is what GEOSEARCH looks for
+type GeoQuery struct {
+	FromMember          bool    // search around Member rather than around Longitude and Latitude
+	Member              string  // the member at the center, with FromMember
+	Longitude, Latitude float64 // the center, without FromMember
+	ByBox               bool    // search in a Width by Height box rather than within Radius
+	Radius              float64
+	Width, Height       float64
+	Unit                float64 // the meters in the unit of Radius, Width, Height and the distances returned
+	Sort                GeoSort
+	Count               int  // the most results to return, 0 for all of them
+	Any                 bool // return the first Count results found rather than the Count nearest
+}
+
+// GeoResult is a member GEOSEARCH found
+type GeoResult struct {
+	Member              string
+	Score               float64 // the 52 bit geohash
+	Distance            float64 // from the center, in the unit of the query
+	Longitude, Latitude float64
+}
+
+/*
+ 	* geoSearchLocked finds the members of the sorted set at key inside the shape of a query, the key-value write lock
+	* must be held
+	* @param key string - the key of the sorted set
+	* @param now time.Time - the current time
+	* @param query GeoQuery - the query
+	* @return []GeoResult - the members, sorted as the query says
+	* @return error - ErrWrongType or ErrGeoMemberNotFound
+*/
+func (s *Store) geoSearchLocked(key string, now time.Time, query GeoQuery) ([]GeoResult, error) {
+	z, _, err := s.zsetLocked(key, now, false)
+	if err != nil || z == nil {
+		return nil, err
+	}
+
+	shape := geoShape{
+		longitude: query.Longitude,
+		latitude:  query.Latitude,
+		byBox:     query.ByBox,
+		radius:    query.Radius * query.Unit,
+		width:     query.Width * query.Unit,
+		height:    query.Height * query.Unit,
+	}
+	if query.FromMember {
+		score, exists := z.dict[query.Member]
+		if !exists {
+			return nil, ErrGeoMemberNotFound
+		}
+		shape.longitude, shape.latitude = GeoDecode(score)
+	}
+
+	// COUNT without ANY must see every match to keep the nearest ones
+	limit := 0
+	if query.Any {
+		limit = query.Count
+	}
+
+	var results []GeoResult
+	areas := geoSearchAreas(shape)
+	for i, area := range areas {
+		// with a large enough radius the boxes wrap around and the same one comes up twice in a row
+		if i > 0 && areas[i-1] == area {
+			continue
+		}
+
+		scoreRange := geoHashScoreRange(area)
+		for node := z.zsl.firstInRange(scoreRange); node != nil && scoreRange.belowMax(node); node = node.level[0].forward {
+			if limit > 0 && len(results) >= limit {
+				break
+			}
+
+			longitude, latitude := GeoDecode(node.score)
+			distance, inside := shape.distanceIfInside(longitude, latitude)
+			if !inside {
+				continue
+			}
+			results = append(results, GeoResult{
+				Member:    node.member,
+				Score:     node.score,
+				Distance:  distance / query.Unit,
+				Longitude: longitude,
+				Latitude:  latitude,
+			})
+		}
+	}
+
+	sortOrder := query.Sort
+	if sortOrder == GeoSortNone && query.Count > 0 && !query.Any {
+		sortOrder = GeoSortAsc
+	}
+	switch sortOrder {
+	case GeoSortAsc:
+		slices.SortStableFunc(results, func(a, b GeoResult) int { return compareFloat(a.Distance, b.Distance) })
+	case GeoSortDesc:
+		slices.SortStableFunc(results, func(a, b GeoResult) int { return compareFloat(b.Distance, a.Distance) })
+	}
+
+	if query.Count > 0 && len(results) > query.Count {
+		results = results[:query.Count]
+	}
+	return results, nil
+}
+
+func compareFloat(a, b float64) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+/*
+ 	* GeoSearch finds the members of the sorted set at key inside a circle or a box
+	* @param key string - the key of the sorted set
+	* @param query GeoQuery - the query
+	* @return []GeoResult - the members, none if the key doesn't exist
+	* @return error - ErrWrongType, or ErrGeoMemberNotFound if the member at the center doesn't exist
+*/
+func (s *Store) GeoSearch(key string, query GeoQuery) ([]GeoResult, error) {
+	s.kv.mu.Lock()
+	defer s.kv.mu.Unlock()
+
+	return s.geoSearchLocked(key, time.Now(), query)
+}
+
+/*
+ 	* GeoSearchStore stores what GeoSearch finds at dst as a sorted set, overwriting whatever it held, an empty result
+	* deletes dst
+	* @param dst string - the key to store the result at
+	* @param key string - the key of the sorted set to search
+	* @param query GeoQuery - the query
+	* @param storeDist bool - score the members with their distance rather than their geohash
+	* @return int - the number of members stored
+	* @return error - ErrWrongType, or ErrGeoMemberNotFound if the member at the center doesn't exist
+*/
+func (s *Store) GeoSearchStore(dst, key string, query GeoQuery, storeDist bool) (int, error) {
+	s.kv.mu.Lock()
+	defer s.kv.mu.Unlock()
+
+	results, err := s.geoSearchLocked(key, time.Now(), query)
+	if err != nil {
+		return 0, err
+	}
+
+	result := newZSet()
+	for _, found := range results {
+		score := found.Score
+		if storeDist {
+			score = found.Distance
+		}
+		result.add(found.Member, score, ZAddAlways, false)
+	}
+
+	s.overwriteLocked(dst)
+	s.storeZSetLocked(dst, result, storedValue{object: result})
+	return result.len(), nil
+}
@@ -0,0 +1,392 @@
+package store
+
+import (
+	"math"
+)
+
+// a point is stored in a sorted set with its 52 bit geohash as the score, which a float64 holds exactly. the longitude
+// and the latitude are each cut into 2^26 steps and their bits interleaved, latitude on the even bits and longitude on
+// the odd ones, so points close to each other get close scores and a geohash box is a range of scores. the latitude is
+// limited to what the web mercator projection covers, like Redis does
+const (
+	GeoLongMin = -180.0
+	GeoLongMax = 180.0
+	GeoLatMin  = -85.05112878
+	GeoLatMax  = 85.05112878
+
+	geoStepMax          = 26 // 52 bits
+	geoEarthRadius      = 6372797.560856
+	geoMercatorMax      = 20037726.37
+	geoHashAlphabet     = "0123456789bcdefghjkmnpqrstuvwxyz"
+	geoHashStringLength = 11
+)
+
+type geoRange struct {
+	min, max float64
+}
+
+// geoHash is a geohash of step steps, the 2*step low bits of bits
+type geoHash struct {
+	bits uint64
+	step uint
+}
+
+type geoArea struct {
+	hash      geoHash
+	longitude geoRange
+	latitude  geoRange
+}
+
+var geoLongRange = geoRange{GeoLongMin, GeoLongMax}
+var geoLatRange = geoRange{GeoLatMin, GeoLatMax}
+
+/*
+ 	* GeoValid checks if a point can be stored
+	* @param longitude float64 - the longitude
+	* @param latitude float64 - the latitude
+	* @return bool - true if it is within GeoLongMin, GeoLongMax, GeoLatMin and GeoLatMax
+*/
+func GeoValid(longitude, latitude float64) bool {
+	return longitude >= GeoLongMin && longitude <= GeoLongMax && latitude >= GeoLatMin && latitude <= GeoLatMax
+}
+
+/*
+ 	* GeoScore returns the score a point is stored with, its 52 bit geohash
+	* @param longitude float64 - the longitude, GeoValid must hold
+	* @param latitude float64 - the latitude
+	* @return float64 - the score
+*/
+func GeoScore(longitude, latitude float64) float64 {
+	return float64(geoEncode(geoLongRange, geoLatRange, longitude, latitude, geoStepMax).bits)
+}
+
+/*
+ 	* GeoDecode returns the point a score stands for, the center of its geohash box
+	* @param score float64 - the score
+	* @return float64 - the longitude
+	* @return float64 - the latitude
+*/
+func GeoDecode(score float64) (float64, float64) {
+	area := geoDecode(geoLongRange, geoLatRange, geoHash{uint64(score), geoStepMax})
+
+	longitude := min(max((area.longitude.min+area.longitude.max)/2, GeoLongMin), GeoLongMax)
+	latitude := min(max((area.latitude.min+area.latitude.max)/2, GeoLatMin), GeoLatMax)
+	return longitude, latitude
+}
+
+/*
+ 	* GeoHashString returns the standard 11 character geohash of the point a score stands for, which unlike the score is
+	* computed over latitudes from -90 to 90, so it can be used with other geohash tools
+	* @param score float64 - the score
+	* @return string - the geohash
+*/
+func GeoHashString(score float64) string {
+	longitude, latitude := GeoDecode(score)
+	hash := geoEncode(geoRange{-180, 180}, geoRange{-90, 90}, longitude, latitude, geoStepMax)
+
+	var buf [geoHashStringLength]byte
+	for i := range buf {
+		index := 0
+		// 52 bits make 10 characters and 2 bits, the last character stands for those 2 bits followed by zeros
+		if i < geoHashStringLength-1 {
+			index = int(hash.bits>>(52-(i+1)*5)) & 0x1f
+		}
+		buf[i] = geoHashAlphabet[index]
+	}
+	return string(buf[:])
+}
+
+/*
+ 	* GeoDistance returns the distance between two points in meters along the surface of the earth, with the haversine formula
+	* @param lon1 float64 - the longitude of the first point
+	* @param lat1 float64 - the latitude of the first point
+	* @param lon2 float64 - the longitude of the second point
+	* @param lat2 float64 - the latitude of the second point
+	* @return float64 - the distance in meters
+*/
+func GeoDistance(lon1, lat1, lon2, lat2 float64) float64 {
+	v := math.Sin((degToRad(lon2) - degToRad(lon1)) / 2)
+	// on the same meridian only the latitudes matter
+	if v == 0 {
+		return geoLatDistance(lat1, lat2)
+	}
+
+	lat1r, lat2r := degToRad(lat1), degToRad(lat2)
+	u := math.Sin((lat2r - lat1r) / 2)
+	a := u*u + math.Cos(lat1r)*math.Cos(lat2r)*v*v
+	return 2 * geoEarthRadius * math.Asin(math.Sqrt(a))
+}
+
+func geoLatDistance(lat1, lat2 float64) float64 {
+	return geoEarthRadius * math.Abs(degToRad(lat2)-degToRad(lat1))
+}
+
+func degToRad(deg float64) float64 {
+	return deg * (math.Pi / 180)
+}
+
+func radToDeg(rad float64) float64 {
+	return rad / (math.Pi / 180)
+}
+
+/*
+ 	* geoEncode returns the geohash of a point with step bits for each coordinate
+	* @param longRange geoRange - the range of longitudes
+	* @param latRange geoRange - the range of latitudes
+	* @param longitude float64 - the longitude
+	* @param latitude float64 - the latitude
+	* @param step uint - the number of bits for each coordinate
+	* @return geoHash - the geohash
+*/
+func geoEncode(longRange, latRange geoRange, longitude, latitude float64, step uint) geoHash {
+	latOffset := (latitude - latRange.min) / (latRange.max - latRange.min)
+	longOffset := (longitude - longRange.min) / (longRange.max - longRange.min)
+	latOffset *= float64(uint64(1) << step)
+	longOffset *= float64(uint64(1) << step)
+
+	return geoHash{interleave64(uint32(latOffset), uint32(longOffset)), step}
+}
+
+/*
+ 	* geoDecode returns the box a geohash stands for
+	* @param longRange geoRange - the range of longitudes
+	* @param latRange geoRange - the range of latitudes
+	* @param hash geoHash - the geohash
+	* @return geoArea - the box
+*/
+func geoDecode(longRange, latRange geoRange, hash geoHash) geoArea {
+	separated := deinterleave64(hash.bits)
+	latBits := float64(uint32(separated))
+	longBits := float64(uint32(separated >> 32))
+	cells := float64(uint64(1) << hash.step)
+
+	latScale := latRange.max - latRange.min
+	longScale := longRange.max - longRange.min
+
+	return geoArea{
+		hash: hash,
+		latitude: geoRange{
+			min: latRange.min + latBits/cells*latScale,
+			max: latRange.min + (latBits+1)/cells*latScale,
+		},
+		longitude: geoRange{
+			min: longRange.min + longBits/cells*longScale,
+			max: longRange.min + (longBits+1)/cells*longScale,
+		},
+	}
+}
+
+/*
+ 	* interleave64 interleaves the bits of x and y, x on the even bits and y on the odd ones
+	* @param x uint32 - the bits for the even positions
+	* @param y uint32 - the bits for the odd positions
+	* @return uint64 - the interleaved bits
+*/
+func interleave64(x, y uint32) uint64 {
+	spread := func(v uint64) uint64 {
+		v = (v | v<<16) & 0x0000FFFF0000FFFF
+		v = (v | v<<8) & 0x00FF00FF00FF00FF
+		v = (v | v<<4) & 0x0F0F0F0F0F0F0F0F
+		v = (v | v<<2) & 0x3333333333333333
+		v = (v | v<<1) & 0x5555555555555555
+		return v
+	}
+	return spread(uint64(x)) | spread(uint64(y))<<1
+}
+
+/*
+ 	* deinterleave64 undoes interleave64
+	* @param interleaved uint64 - the interleaved bits
+	* @return uint64 - the even bits in the low 32 bits and the odd ones in the high 32 bits
+*/
+func deinterleave64(interleaved uint64) uint64 {
+	squash := func(v uint64) uint64 {
+		v &= 0x5555555555555555
+		v = (v | v>>1) & 0x3333333333333333
+		v = (v | v>>2) & 0x0F0F0F0F0F0F0F0F
+		v = (v | v>>4) & 0x00FF00FF00FF00FF
+		v = (v | v>>8) & 0x0000FFFF0000FFFF
+		v = (v | v>>16) & 0x00000000FFFFFFFF
+		return v
+	}
+	return squash(interleaved) | squash(interleaved>>1)<<32
+}
+
+/*
+ 	* move returns the geohash next to this one, dx boxes east and dy boxes north, wrapping around at the edges
+	* @param dx int - -1, 0 or 1
+	* @param dy int - -1, 0 or 1
+	* @return geoHash - the neighbor
+*/
+func (hash geoHash) move(dx, dy int) geoHash {
+	const evenBits, oddBits = 0x5555555555555555, 0xaaaaaaaaaaaaaaaa
+
+	// the longitude is on the odd bits, the latitude on the even ones, adding to one of them carries through the bits
+	// of the other when those are all set first
+	step := func(bits, mask uint64, d int) uint64 {
+		part := bits & mask
+		other := bits &^ mask
+		fill := ^mask >> (64 - hash.step*2)
+		if d > 0 {
+			part += fill + 1
+		} else {
+			part = (part | fill) - (fill + 1)
+		}
+		return part&(mask>>(64-hash.step*2)) | other
+	}
+
+	bits := hash.bits
+	if dx != 0 {
+		bits = step(bits, oddBits, dx)
+	}
+	if dy != 0 {
+		bits = step(bits, evenBits, dy)
+	}
+	return geoHash{bits, hash.step}
+}
+
+/*
+ 	* geoEstimateSteps returns how many bits for each coordinate make geohash boxes about as large as a radius, fewer
+	* near the poles where the boxes get narrower
+	* @param radius float64 - the radius in meters
+	* @param latitude float64 - the latitude of the center
+	* @return uint - the number of steps, 1 to 26
+*/
+func geoEstimateSteps(radius, latitude float64) uint {
+	if radius == 0 {
+		return geoStepMax
+	}
+
+	step := 1
+	for radius < geoMercatorMax {
+		radius *= 2
+		step++
+	}
+	// make sure the radius fits in most cases
+	step -= 2
+
+	if latitude > 66 || latitude < -66 {
+		step--
+		if latitude > 80 || latitude < -80 {
+			step--
+		}
+	}
+
+	return uint(min(max(step, 1), geoStepMax))
+}
+
+/*
+ 	* geoSearchAreas returns the geohash boxes that cover a shape, the box of its center and the 8 around it, leaving out
+	* those the shape doesn't reach
+	* @param shape geoShape - the shape
+	* @return []geoHash - the boxes, with duplicates next to each other when the boxes are large enough to wrap around
+*/
+func geoSearchAreas(shape geoShape) []geoHash {
+	minLon, minLat, maxLon, maxLat := shape.boundingBox()
+
+	radius := shape.radius
+	if shape.byBox {
+		radius = math.Sqrt((shape.width/2)*(shape.width/2) + (shape.height/2)*(shape.height/2))
+	}
+	steps := geoEstimateSteps(radius, shape.latitude)
+
+	hash := geoEncode(geoLongRange, geoLatRange, shape.longitude, shape.latitude, steps)
+	area := geoDecode(geoLongRange, geoLatRange, hash)
+
+	// the boxes around may still not reach the edges of the shape, one step less makes every box twice as large
+	north := geoDecode(geoLongRange, geoLatRange, hash.move(0, 1))
+	south := geoDecode(geoLongRange, geoLatRange, hash.move(0, -1))
+	east := geoDecode(geoLongRange, geoLatRange, hash.move(1, 0))
+	west := geoDecode(geoLongRange, geoLatRange, hash.move(-1, 0))
+	if steps > 1 && (north.latitude.max < maxLat || south.latitude.min > minLat || east.longitude.max < maxLon || west.longitude.min > minLon) {
+		steps--
+		hash = geoEncode(geoLongRange, geoLatRange, shape.longitude, shape.latitude, steps)
+		area = geoDecode(geoLongRange, geoLatRange, hash)
+	}
+
+	// the order Redis walks them in: center, north, south, east, west, north east, north west, south east, south west
+	moves := [][2]int{{0, 0}, {0, 1}, {0, -1}, {1, 0}, {-1, 0}, {1, 1}, {-1, 1}, {1, -1}, {-1, -1}}
+	areas := make([]geoHash, 0, len(moves))
+	for _, move := range moves {
+		dx, dy := move[0], move[1]
+		// a box on a side the shape doesn't reach past the center box is useless
+		if steps >= 2 {
+			if (dy < 0 && area.latitude.min < minLat) || (dy > 0 && area.latitude.max > maxLat) ||
+				(dx < 0 && area.longitude.min < minLon) || (dx > 0 && area.longitude.max > maxLon) {
+				continue
+			}
+		}
+		areas = append(areas, hash.move(dx, dy))
+	}
+	return areas
+}
+
+/*
+ 	* geoHashScoreRange returns the range of 52 bit scores inside a geohash box
+	* @param hash geoHash - the box
+	* @return ScoreRange - the range, its end exclusive
+*/
+func geoHashScoreRange(hash geoHash) ScoreRange {
+	shift := 52 - hash.step*2
+	return ScoreRange{
+		Min:          float64(hash.bits << shift),
+		Max:          float64((hash.bits + 1) << shift),
+		MaxExclusive: true,
+	}
+}
+
+// geoShape is the area GEOSEARCH looks in, a circle or a box around a center, in meters
+type geoShape struct {
+	longitude, latitude float64
+	byBox               bool
+	radius              float64
+	width, height       float64
+}
+
+/*
+ 	* boundingBox returns the longitudes and latitudes that enclose the shape
+	* @return float64 - the smallest longitude
+	* @return float64 - the smallest latitude
+	* @return float64 - the largest longitude
+	* @return float64 - the largest latitude
+*/
+func (shape geoShape) boundingBox() (float64, float64, float64, float64) {
+	height, width := shape.radius, shape.radius
+	if shape.byBox {
+		height, width = shape.height/2, shape.width/2
+	}
+
+	latDelta := radToDeg(height / geoEarthRadius)
+	longDeltaTop := radToDeg(width / geoEarthRadius / math.Cos(degToRad(shape.latitude+latDelta)))
+	longDeltaBottom := radToDeg(width / geoEarthRadius / math.Cos(degToRad(shape.latitude-latDelta)))
+
+	// the box is widest on the side closer to the equator
+	longDelta := longDeltaTop
+	if shape.latitude < 0 {
+		longDelta = longDeltaBottom
+	}
+	return shape.longitude - longDelta, shape.latitude - latDelta, shape.longitude + longDelta, shape.latitude + latDelta
+}
+
+/*
+ 	* distanceIfInside returns the distance from the center of the shape to a point if the point is inside it
+	* @param longitude float64 - the longitude of the point
+	* @param latitude float64 - the latitude of the point
+	* @return float64 - the distance in meters
+	* @return bool - true if the point is inside the shape
+*/
+func (shape geoShape) distanceIfInside(longitude, latitude float64) (float64, bool) {
+	if !shape.byBox {
+		distance := GeoDistance(shape.longitude, shape.latitude, longitude, latitude)
+		return distance, distance <= shape.radius
+	}
+
+	// the latitude distance is cheaper to compute, so it goes first
+	if geoLatDistance(latitude, shape.latitude) > shape.height/2 {
+		return 0, false
+	}
+	if GeoDistance(longitude, latitude, shape.longitude, latitude) > shape.width/2 {
+		return 0, false
+	}
+	return GeoDistance(shape.longitude, shape.latitude, longitude, latitude), true
+}
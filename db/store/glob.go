@@ -0,0 +1,135 @@
+package store
+
+/*
+ 	* MatchGlob checks if s matches the glob-style pattern, with the same rules as Redis' stringmatchlen:
+	* ? matches one byte, * any number of bytes, [abc] and [a-z] a set or range of bytes,
+	* [^abc] any byte not in the set, and \ escapes the next byte
+	* @param pattern string - the pattern
+	* @param s string - the string to match
+	* @return bool - true if s matches the pattern, false otherwise
+*/
+func MatchGlob(pattern, s string) bool {
+	// the matcher never matches an empty string, but * is meant to match every key, as in Redis' KEYS
+	if pattern == "*" {
+		return true
+	}
+
+	matched, _ := matchGlobFrom(pattern, s, 0)
+	return matched
+}
+
+/*
+ 	* matchGlobFrom does the matching, recursing on every *.
+	* once a * fails to match any suffix, no shorter suffix can match either, skipLongerMatches
+	* reports that so the callers up the stack give up instead of trying every split again
+	* @param pattern string - the pattern
+	* @param s string - the string to match
+	* @param nesting int - how many * deep we are, caps the recursion on hostile patterns
+	* @return bool - true if s matches the pattern, false otherwise
+	* @return bool - skipLongerMatches, true if the callers should stop trying
+*/
+func matchGlobFrom(pattern, s string, nesting int) (bool, bool) {
+	// same limit as Redis, a pattern like "a*a*a*...b" is exponential otherwise
+	if nesting > 1000 {
+		return false, true
+	}
+
+	// p and i walk the pattern and the string, at returns the pattern byte at p, 0 past the end like a C string
+	p, i := 0, 0
+	at := func(p int) byte {
+		if p < len(pattern) {
+			return pattern[p]
+		}
+		return 0
+	}
+
+	for p < len(pattern) && i < len(s) {
+		switch pattern[p] {
+		case '*':
+			for p+1 < len(pattern) && pattern[p+1] == '*' {
+				p++
+			}
+			if p == len(pattern)-1 {
+				return true, false
+			}
+			for ; i < len(s); i++ {
+				matched, skip := matchGlobFrom(pattern[p+1:], s[i:], nesting+1)
+				if matched {
+					return true, false
+				}
+				if skip {
+					return false, true
+				}
+			}
+			return false, true
+
+		case '?':
+			i++
+
+		case '[':
+			p++
+			not := at(p) == '^'
+			if not {
+				p++
+			}
+
+			match := false
+			for {
+				if at(p) == '\\' && len(pattern)-p >= 2 {
+					p++
+					if pattern[p] == s[i] {
+						match = true
+					}
+				} else if at(p) == ']' {
+					break
+				} else if p >= len(pattern) {
+					// unterminated set, step back so the p++ below leaves p at the end
+					p--
+					break
+				} else if len(pattern)-p >= 3 && pattern[p+1] == '-' {
+					start, end := pattern[p], pattern[p+2]
+					if start > end {
+						start, end = end, start
+					}
+					p += 2
+					if s[i] >= start && s[i] <= end {
+						match = true
+					}
+				} else if pattern[p] == s[i] {
+					match = true
+				}
+				p++
+			}
+
+			if not {
+				match = !match
+			}
+			if !match {
+				return false, false
+			}
+			i++
+
+		case '\\':
+			if len(pattern)-p >= 2 {
+				p++
+			}
+			fallthrough
+
+		default:
+			if pattern[p] != s[i] {
+				return false, false
+			}
+			i++
+		}
+
+		p++
+		if i == len(s) {
+			for at(p) == '*' {
+				p++
+			}
+			break
+		}
+	}
+
+	return p >= len(pattern) && i == len(s), false
+}
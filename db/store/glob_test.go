@@ -0,0 +1,72 @@
+package store
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestMatchGlob(t *testing.T) {
+	tests := []struct {
+		pattern string
+		s       string
+		want    bool
+	}{
+		{"*", "", true},
+		{"*", "anything", true},
+		{"", "", true},
+		{"", "a", false},
+		{"hello", "hello", true},
+		{"hello", "hell", false},
+		{"hello", "hello!", false},
+
+		{"h?llo", "hello", true},
+		{"h?llo", "hllo", false},
+		{"???", "abc", true},
+		{"???", "ab", false},
+
+		{"h*llo", "hllo", true},
+		{"h*llo", "heeeello", true},
+		{"h*llo", "hellol", false},
+		{"a*", "a", true},
+		{"*a", "", false},
+		{"a**b", "ab", true},
+		{"*b*", "abc", true},
+		{"*.txt", "notes.txt", true},
+		{"*.txt", "notes.txt.bak", false},
+
+		{"h[ae]llo", "hello", true},
+		{"h[ae]llo", "hallo", true},
+		{"h[ae]llo", "hillo", false},
+		{"h[^e]llo", "hallo", true},
+		{"h[^e]llo", "hello", false},
+		{"h[a-b]llo", "hbllo", true},
+		{"h[a-b]llo", "hcllo", false},
+		{"h[b-a]llo", "hallo", true}, // a reversed range is swapped
+		{"[\\]]", "]", true},
+		{"[abc", "a", true}, // an unterminated set ends with the pattern
+		{"[abc", "d", false},
+
+		{"\\*", "*", true},
+		{"\\*", "a", false},
+		{"a\\?b", "a?b", true},
+		{"a\\?b", "axb", false},
+		{"user:*:name", "user:1000:name", true},
+		{"user:*:name", "user:1000:email", false},
+	}
+
+	for _, test := range tests {
+		if got := MatchGlob(test.pattern, test.s); got != test.want {
+			t.Errorf("MatchGlob(%q, %q) = %v, want %v", test.pattern, test.s, got, test.want)
+		}
+	}
+}
+
+func TestMatchGlobHostilePattern(t *testing.T) {
+	// without the nesting limit and giving up early on a failed *, this pattern takes exponential time
+	pattern := strings.Repeat("a*", 30) + "b"
+	s := strings.Repeat("a", 60)
+
+	if MatchGlob(pattern, s) {
+		t.Errorf("MatchGlob(%q, %q) = true, want false", pattern, s)
+	}
+}
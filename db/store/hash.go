@@ -0,0 +1,503 @@
+package store
+
+import (
+	"errors"
+	"math"
+	"strconv"
+	"time"
+)
+
+var ErrHashValueNotInteger = errors.New("ERR hash value is not an integer")
+var ErrHashValueNotFloat = errors.New("ERR hash value is not a float")
+var ErrIncrOverflow = errors.New("ERR increment or decrement would overflow")
+var ErrIncrNaN = errors.New("ERR increment would produce NaN or Infinity")
+
+/*
+ 	* expireHashFieldsLocked deletes the expired fields of the hash at key, and the key once no field is left.
+	* watchers are told about it like they are about an expired key. the write lock must be held
+	* @param key string - the key of the hash
+	* @param now time.Time - the current time
+	* @return int - the number of fields deleted
+*/
+func (kv *keyValueStore) expireHashFieldsLocked(key string, now time.Time) int {
+	stored, exists := kv.lookupLocked(key, now)
+	if !exists {
+		return 0
+	}
+
+	h, ok := stored.object.(*hash)
+	if !ok || h.expires == nil {
+		return 0
+	}
+
+	expired := h.expireFields(now)
+	if expired == 0 {
+		return 0
+	}
+
+	if h.len() == 0 {
+		kv.removeExpired(key)
+		return expired
+	}
+
+	kv.put(key, stored)
+	if kv.onExpire != nil {
+		kv.onExpire(key)
+	}
+	return expired
+}
+
+/*
+ 	* hashLocked returns the hash at key, without its expired fields, the key-value write lock must be held
+	* @param key string - the key of the hash
+	* @param now time.Time - the current time
+	* @param create bool - create an empty hash if the key doesn't exist, it is only stored once a field is added
+	* @return *hash - the hash, nil if the key doesn't exist and create is false
+	* @return storedValue - the value holding the hash
+	* @return error - ErrWrongType if the key holds something other than a hash
+*/
+func (s *Store) hashLocked(key string, now time.Time, create bool) (*hash, storedValue, error) {
+	s.kv.expireHashFieldsLocked(key, now)
+
+	stored, exists := s.kv.lookupLocked(key, now)
+	if !exists {
+		if s.streams.isStreamKey(key) {
+			return nil, stored, ErrWrongType
+		}
+		if !create {
+			return nil, stored, nil
+		}
+		stored = storedValue{object: newHash()}
+	}
+
+	h, ok := stored.object.(*hash)
+	if !ok {
+		return nil, stored, ErrWrongType
+	}
+
+	return h, stored, nil
+}
+
+/*
+ 	* storeHashLocked stores the hash back, or deletes the key once the hash is empty
+	* @param key string - the key of the hash
+	* @param h *hash - the hash
+	* @param stored storedValue - the value holding the hash
+*/
+func (s *Store) storeHashLocked(key string, h *hash, stored storedValue) {
+	if h.len() == 0 {
+		s.kv.remove(key)
+		return
+	}
+	s.kv.put(key, stored)
+}
+
+/*
+ 	* HSet sets fields of the hash, creating it if it doesn't exist, a field that is set loses its expiration
+	* @param key string - the key of the hash
+	* @param pairs [][]byte - field, value, field, value...
+	* @param nx bool - only set fields that don't exist, for HSETNX
+	* @return int - the number of fields that were added
+	* @return error - ErrWrongType if the key holds something other than a hash
+*/
+func (s *Store) HSet(key string, pairs [][]byte, nx bool) (int, error) {
+	s.kv.mu.Lock()
+	defer s.kv.mu.Unlock()
+
+	h, stored, err := s.hashLocked(key, time.Now(), true)
+	if err != nil {
+		return 0, err
+	}
+
+	added := 0
+	for i := 0; i+1 < len(pairs); i += 2 {
+		field := string(pairs[i])
+		if _, exists := h.get(field); exists && nx {
+			continue
+		}
+		if h.set(field, pairs[i+1]) {
+			added++
+		}
+		h.setFieldExpiration(field, time.Time{})
+	}
+
+	s.storeHashLocked(key, h, stored)
+	return added, nil
+}
+
+/*
+ 	* HGet returns the value of a field
+	* @param key string - the key of the hash
+	* @param field string - the field
+	* @return []byte - the value
+	* @return bool - false if the key or the field doesn't exist
+	* @return error - ErrWrongType if the key holds something other than a hash
+*/
+func (s *Store) HGet(key, field string) ([]byte, bool, error) {
+	s.kv.mu.Lock()
+	defer s.kv.mu.Unlock()
+
+	h, _, err := s.hashLocked(key, time.Now(), false)
+	if err != nil || h == nil {
+		return nil, false, err
+	}
+
+	value, exists := h.get(field)
+	return value, exists, nil
+}
+
+/*
+ 	* HMGet returns the values of the fields
+	* @param key string - the key of the hash
+	* @param fields []string - the fields
+	* @return [][]byte - the values, nil for the fields that don't exist
+	* @return error - ErrWrongType if the key holds something other than a hash
+*/
+func (s *Store) HMGet(key string, fields []string) ([][]byte, error) {
+	s.kv.mu.Lock()
+	defer s.kv.mu.Unlock()
+
+	values := make([][]byte, len(fields))
+
+	h, _, err := s.hashLocked(key, time.Now(), false)
+	if err != nil || h == nil {
+		return values, err
+	}
+
+	for i, field := range fields {
+		values[i], _ = h.get(field)
+	}
+	return values, nil
+}
+
+/*
+ 	* HDel deletes fields of the hash, and the key once no field is left
+	* @param key string - the key of the hash
+	* @param fields []string - the fields
+	* @return int - the number of fields that were deleted
+	* @return error - ErrWrongType if the key holds something other than a hash
+*/
+func (s *Store) HDel(key string, fields []string) (int, error) {
+	s.kv.mu.Lock()
+	defer s.kv.mu.Unlock()
+
+	h, stored, err := s.hashLocked(key, time.Now(), false)
+	if err != nil || h == nil {
+		return 0, err
+	}
+
+	deleted := 0
+	for _, field := range fields {
+		if h.del(field) {
+			deleted++
+		}
+	}
+
+	s.storeHashLocked(key, h, stored)
+	return deleted, nil
+}
+
+/*
+ 	* HLen returns the number of fields of the hash
+	* @param key string - the key of the hash
+	* @return int - the number of fields, 0 if the key doesn't exist
+	* @return error - ErrWrongType if the key holds something other than a hash
+*/
+func (s *Store) HLen(key string) (int, error) {
+	s.kv.mu.Lock()
+	defer s.kv.mu.Unlock()
+
+	h, _, err := s.hashLocked(key, time.Now(), false)
+	if err != nil || h == nil {
+		return 0, err
+	}
+	return h.len(), nil
+}
+
+/*
+ 	* HGetAll returns every field of the hash with its value
+	* @param key string - the key of the hash
+	* @return [][]byte - field, value, field, value..., empty if the key doesn't exist
+	* @return error - ErrWrongType if the key holds something other than a hash
+*/
+func (s *Store) HGetAll(key string) ([][]byte, error) {
+	s.kv.mu.Lock()
+	defer s.kv.mu.Unlock()
+
+	h, _, err := s.hashLocked(key, time.Now(), false)
+	if err != nil || h == nil {
+		return [][]byte{}, err
+	}
+
+	pairs := make([][]byte, 0, 2*h.len())
+	h.forEach(func(field string, value []byte) {
+		pairs = append(pairs, []byte(field), value)
+	})
+	return pairs, nil
+}
+
+/*
+ 	* HIncrBy adds delta to the integer value of a field, a missing field counts as 0, the expiration of the field is kept
+	* @param key string - the key of the hash
+	* @param field string - the field
+	* @param delta int64 - how much to add
+	* @return int64 - the new value
+	* @return error - ErrHashValueNotInteger, ErrIncrOverflow or ErrWrongType
+*/
+func (s *Store) HIncrBy(key, field string, delta int64) (int64, error) {
+	s.kv.mu.Lock()
+	defer s.kv.mu.Unlock()
+
+	h, stored, err := s.hashLocked(key, time.Now(), true)
+	if err != nil {
+		return 0, err
+	}
+
+	var current int64
+	if value, exists := h.get(field); exists {
+		current, err = strconv.ParseInt(string(value), 10, 64)
+		if err != nil {
+			return 0, ErrHashValueNotInteger
+		}
+	}
+
+	if (delta > 0 && current > math.MaxInt64-delta) || (delta < 0 && current < math.MinInt64-delta) {
+		return 0, ErrIncrOverflow
+	}
+
+	current += delta
+	h.set(field, []byte(strconv.FormatInt(current, 10)))
+
+	s.storeHashLocked(key, h, stored)
+	return current, nil
+}
+
+/*
+ 	* HIncrByFloat adds delta to the float value of a field, a missing field counts as 0, the expiration of the field is kept
+	* @param key string - the key of the hash
+	* @param field string - the field
+	* @param delta float64 - how much to add
+	* @return []byte - the new value, as it is stored
+	* @return error - ErrHashValueNotFloat, ErrIncrNaN or ErrWrongType
+*/
+func (s *Store) HIncrByFloat(key, field string, delta float64) ([]byte, error) {
+	s.kv.mu.Lock()
+	defer s.kv.mu.Unlock()
+
+	h, stored, err := s.hashLocked(key, time.Now(), true)
+	if err != nil {
+		return nil, err
+	}
+
+	var current float64
+	if value, exists := h.get(field); exists {
+		current, err = strconv.ParseFloat(string(value), 64)
+		if err != nil || math.IsNaN(current) || math.IsInf(current, 0) {
+			return nil, ErrHashValueNotFloat
+		}
+	}
+
+	current += delta
+	if math.IsNaN(current) || math.IsInf(current, 0) {
+		return nil, ErrIncrNaN
+	}
+
+	value := []byte(strconv.FormatFloat(current, 'f', -1, 64))
+	h.set(field, value)
+
+	s.storeHashLocked(key, h, stored)
+	return value, nil
+}
+
+/*
+ 	* HScan returns the next batch of fields of an HSCAN iteration, with the cursor semantics of Scan,
+	* a hash that is still compact comes back whole with the cursor 0
+	* @param key string - the key of the hash
+	* @param cursor uint64 - 0 to start, then the cursor returned by the previous call
+	* @param count int - how many fields to look at
+	* @param pattern string - the glob-style pattern the fields must match, "" for all
+	* @param noValues bool - return only the fields
+	* @return [][]byte - field, value, field, value..., or only the fields with noValues
+	* @return uint64 - the cursor for the next call, 0 when the iteration is over
+	* @return error - ErrWrongType if the key holds something other than a hash
+*/
+func (s *Store) HScan(key string, cursor uint64, count int, pattern string, noValues bool) ([][]byte, uint64, error) {
+	s.kv.mu.Lock()
+	defer s.kv.mu.Unlock()
+
+	h, _, err := s.hashLocked(key, time.Now(), false)
+	if err != nil || h == nil {
+		return [][]byte{}, 0, err
+	}
+
+	result := [][]byte{}
+	visit := func(field string, value []byte) {
+		if pattern != "" && !MatchGlob(pattern, field) {
+			return
+		}
+		result = append(result, []byte(field))
+		if !noValues {
+			result = append(result, value)
+		}
+	}
+
+	// like Redis, a compact hash is small enough to be returned whole by the first call
+	if h.dict == nil {
+		h.forEach(visit)
+		return result, 0, nil
+	}
+
+	nextCursor := h.order.scan(cursor, count, func(field string) {
+		visit(field, h.dict[field])
+	})
+	return result, nextCursor, nil
+}
+
+/*
+ 	* HExpireAt sets the expiration of fields of the hash, when the condition allows it.
+	* a time that already passed deletes the field right away
+	* @param key string - the key of the hash
+	* @param fields []string - the fields
+	* @param at time.Time - the new expiration
+	* @param condition ExpireCondition - when to set it, compared with the current expiration of each field
+	* @return []int - for each field, one of the HashField* results
+	* @return error - ErrWrongType if the key holds something other than a hash
+*/
+func (s *Store) HExpireAt(key string, fields []string, at time.Time, condition ExpireCondition) ([]int, error) {
+	s.kv.mu.Lock()
+	defer s.kv.mu.Unlock()
+
+	results := make([]int, len(fields))
+	for i := range results {
+		results[i] = HashFieldMissing
+	}
+
+	now := time.Now()
+	h, stored, err := s.hashLocked(key, now, false)
+	if err != nil || h == nil {
+		return results, err
+	}
+
+	for i, field := range fields {
+		if _, exists := h.get(field); !exists {
+			continue
+		}
+
+		if !condition.met(h.expires[field], at) {
+			results[i] = HashFieldNotSet
+			continue
+		}
+
+		if !at.After(now) {
+			h.del(field)
+			results[i] = HashFieldDeletedByTTL
+			continue
+		}
+
+		h.setFieldExpiration(field, at)
+		results[i] = HashFieldSet
+	}
+
+	s.storeHashLocked(key, h, stored)
+	return results, nil
+}
+
+/*
+ 	* HExpireTime returns the expiration of fields of the hash
+	* @param key string - the key of the hash
+	* @param fields []string - the fields
+	* @return []int64 - for each field, its expiration as a unix time in milliseconds, HashFieldNoTTL or HashFieldMissing
+	* @return error - ErrWrongType if the key holds something other than a hash
+*/
+func (s *Store) HExpireTime(key string, fields []string) ([]int64, error) {
+	s.kv.mu.Lock()
+	defer s.kv.mu.Unlock()
+
+	results := make([]int64, len(fields))
+	for i := range results {
+		results[i] = HashFieldMissing
+	}
+
+	h, _, err := s.hashLocked(key, time.Now(), false)
+	if err != nil || h == nil {
+		return results, err
+	}
+
+	for i, field := range fields {
+		if _, exists := h.get(field); !exists {
+			continue
+		}
+		if at, hasTTL := h.expires[field]; hasTTL {
+			results[i] = at.UnixMilli()
+		} else {
+			results[i] = HashFieldNoTTL
+		}
+	}
+
+	return results, nil
+}
+
+/*
+ 	* HPersist removes the expiration of fields of the hash
+	* @param key string - the key of the hash
+	* @param fields []string - the fields
+	* @return []int - for each field, HashFieldSet if the expiration was removed, HashFieldNoTTL or HashFieldMissing
+	* @return error - ErrWrongType if the key holds something other than a hash
+*/
+func (s *Store) HPersist(key string, fields []string) ([]int, error) {
+	s.kv.mu.Lock()
+	defer s.kv.mu.Unlock()
+
+	results := make([]int, len(fields))
+	for i := range results {
+		results[i] = HashFieldMissing
+	}
+
+	h, stored, err := s.hashLocked(key, time.Now(), false)
+	if err != nil || h == nil {
+		return results, err
+	}
+
+	for i, field := range fields {
+		if _, exists := h.get(field); !exists {
+			continue
+		}
+		if _, hasTTL := h.expires[field]; !hasTTL {
+			results[i] = HashFieldNoTTL
+			continue
+		}
+		h.setFieldExpiration(field, time.Time{})
+		results[i] = HashFieldSet
+	}
+
+	s.storeHashLocked(key, h, stored)
+	return results, nil
+}
+
+/*
+ 	* RestoreHash puts a hash read from disk back at key, fields that expired while the server was down are skipped
+	* @param key string - the key of the hash
+	* @param fields []HashField - the fields
+	* @param expiration time.Duration - the time to live of the key, 0 for none
+*/
+func (s *Store) RestoreHash(key string, fields []HashField, expiration time.Duration) {
+	s.kv.mu.Lock()
+	defer s.kv.mu.Unlock()
+
+	now := time.Now()
+	h := newHash()
+	for _, field := range fields {
+		if !field.Expiration.IsZero() && now.After(field.Expiration) {
+			continue
+		}
+		h.set(field.Field, field.Value)
+		h.setFieldExpiration(field.Field, field.Expiration)
+	}
+
+	stored := storedValue{object: h}
+	if expiration > 0 {
+		stored.expiration = now.Add(expiration)
+	}
+
+	s.storeHashLocked(key, h, stored)
+}
@@ -0,0 +1,230 @@
+package store
+
+import (
+	"time"
+)
+
+// like Redis, a small hash is a flat array of field-value pairs searched linearly, which costs far less memory than a map
+// and is just as fast at this size. it turns into a map, for good, once it grows past either limit
+const (
+	hashMaxListpackEntries = 128 // hash-max-listpack-entries
+	hashMaxListpackValue   = 64  // hash-max-listpack-value, in bytes, for fields and values alike
+)
+
+// the replies of HEXPIRE and HPERSIST for each field
+const (
+	HashFieldMissing      = -2 // no such field, or no such key
+	HashFieldNoTTL        = -1 // HPERSIST, the field has no expiration
+	HashFieldNotSet       = 0  // HEXPIRE, the NX/XX/GT/LT condition was not met
+	HashFieldSet          = 1  // the expiration was set, or removed by HPERSIST
+	HashFieldDeletedByTTL = 2  // HEXPIRE, the time already passed so the field was deleted
+)
+
+type hashEntry struct {
+	field string
+	value []byte
+}
+
+type hash struct {
+	entries []hashEntry          // compact encoding, nil once the hash turned into a map
+	dict    map[string][]byte    // hashtable encoding
+	order   *scanIndex           // the fields of the hashtable encoding in HSCAN order
+	expires map[string]time.Time // fields that have a TTL, nil while none has
+}
+
+// HashField is a field of a hash with its expiration, so a hash can be written to and restored from disk
+type HashField struct {
+	Field      string
+	Value      []byte
+	Expiration time.Time // zero if the field has no TTL
+}
+
+func newHash() *hash {
+	return &hash{}
+}
+
+func (h *hash) len() int {
+	if h.dict != nil {
+		return len(h.dict)
+	}
+	return len(h.entries)
+}
+
+/*
+ 	* get returns the value of a field
+	* @param field string - the field
+	* @return []byte - the value
+	* @return bool - true if the field exists, false otherwise
+*/
+func (h *hash) get(field string) ([]byte, bool) {
+	if h.dict != nil {
+		value, exists := h.dict[field]
+		return value, exists
+	}
+	for _, entry := range h.entries {
+		if entry.field == field {
+			return entry.value, true
+		}
+	}
+	return nil, false
+}
+
+/*
+ 	* set sets the value of a field, its expiration is left alone
+	* @param field string - the field
+	* @param value []byte - the value
+	* @return bool - true if the field is new, false if it was updated
+*/
+func (h *hash) set(field string, value []byte) bool {
+	if h.dict == nil && (len(field) > hashMaxListpackValue || len(value) > hashMaxListpackValue) {
+		h.convertToDict()
+	}
+
+	if h.dict != nil {
+		_, exists := h.dict[field]
+		h.dict[field] = value
+		if !exists {
+			h.order.insert(field)
+		}
+		return !exists
+	}
+
+	for i := range h.entries {
+		if h.entries[i].field == field {
+			h.entries[i].value = value
+			return false
+		}
+	}
+
+	h.entries = append(h.entries, hashEntry{field, value})
+	if len(h.entries) > hashMaxListpackEntries {
+		h.convertToDict()
+	}
+	return true
+}
+
+func (h *hash) convertToDict() {
+	h.dict = make(map[string][]byte, len(h.entries))
+	h.order = newScanIndex()
+	for _, entry := range h.entries {
+		h.dict[entry.field] = entry.value
+		h.order.insert(entry.field)
+	}
+	h.entries = nil
+}
+
+/*
+ 	* del deletes a field and its expiration
+	* @param field string - the field
+	* @return bool - true if the field existed, false otherwise
+*/
+func (h *hash) del(field string) bool {
+	delete(h.expires, field)
+
+	if h.dict != nil {
+		_, exists := h.dict[field]
+		delete(h.dict, field)
+		if exists {
+			h.order.delete(field)
+		}
+		return exists
+	}
+
+	for i := range h.entries {
+		if h.entries[i].field == field {
+			h.entries = append(h.entries[:i], h.entries[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+/*
+ 	* forEach calls fn with every field, in insertion order while the hash is compact
+	* @param fn func(field string, value []byte) - called for every field
+*/
+func (h *hash) forEach(fn func(field string, value []byte)) {
+	if h.dict != nil {
+		for field, value := range h.dict {
+			fn(field, value)
+		}
+		return
+	}
+	for _, entry := range h.entries {
+		fn(entry.field, entry.value)
+	}
+}
+
+/*
+ 	* setFieldExpiration sets or removes the expiration of a field
+	* @param field string - the field, it must exist
+	* @param at time.Time - the expiration, zero to remove it
+*/
+func (h *hash) setFieldExpiration(field string, at time.Time) {
+	if at.IsZero() {
+		delete(h.expires, field)
+		if len(h.expires) == 0 {
+			h.expires = nil
+		}
+		return
+	}
+
+	if h.expires == nil {
+		h.expires = make(map[string]time.Time)
+	}
+	h.expires[field] = at
+}
+
+/*
+ 	* expireFields deletes the fields whose expiration passed
+	* @param now time.Time - the current time
+	* @return int - the number of fields deleted
+*/
+func (h *hash) expireFields(now time.Time) int {
+	expired := 0
+	for field, at := range h.expires {
+		if now.After(at) {
+			h.del(field)
+			expired++
+		}
+	}
+	if len(h.expires) == 0 {
+		h.expires = nil
+	}
+	return expired
+}
+
+/*
+ 	* fields returns every field with its value and expiration
+	* @return []HashField - the fields
+*/
+func (h *hash) fields() []HashField {
+	fields := make([]HashField, 0, h.len())
+	h.forEach(func(field string, value []byte) {
+		fields = append(fields, HashField{Field: field, Value: value, Expiration: h.expires[field]})
+	})
+	return fields
+}
+
+/*
+ 	* clone returns a copy of the hash, the values are shared as they are never modified in place
+	* @return *hash - the copy
+*/
+func (h *hash) clone() *hash {
+	copied := newHash()
+	if h.entries != nil {
+		copied.entries = append([]hashEntry{}, h.entries...)
+	}
+	if h.dict != nil {
+		copied.dict = make(map[string][]byte, len(h.dict))
+		copied.order = newScanIndex()
+		for field, value := range h.dict {
+			copied.dict[field] = value
+			copied.order.insert(field)
+		}
+	}
+	for field, at := range h.expires {
+		copied.setFieldExpiration(field, at)
+	}
+	return copied
+}
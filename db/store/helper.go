@@ -3,6 +3,7 @@ package store
 import (
 	"errors"
 	"fmt"
+	"math"
 	"strconv"
 	"strings"
 	"time"
@@ -10,24 +11,13 @@ import (
 	RESP "github.com/manish-singh-bisht/Redis-From-Scratch/db/resp"
 )
 
-type operationType string
-
-var ErrInvalidStream = errors.New("ERR The stream specified does not exist")
 var ErrInvalidStreamId = errors.New("ERR Invalid stream ID format")
 var ErrInvalidStreamIdXAdd = errors.New("ERR The ID specified in XADD is equal or smaller than the target stream top item")
 var ErrInvalidMsTime = errors.New("ERR The millisecondsTime part of the ID specified is invalid")
 var ErrInvalidSeqNum = errors.New("ERR The sequenceNumber part of the ID specified is invalid")
 var ErrUnexpectedTypeInListElement = errors.New("ERR Unexpected type in list element")
 var ErrInvalidStreamIdXAddMustBeGreaterThanMin = errors.New("ERR The ID specified in XADD must be greater than 0-0")
-
-func unknownOperationType(op operationType) error {
-	return fmt.Errorf("ERR Unknown operation type for id validator: %v", op)
-}
-
-const (
-	operationTypeRange operationType = "range"
-	operationTypeRead  operationType = "read"
-)
+var ErrInvalidStreamIdArgument = errors.New("ERR Invalid stream ID specified as stream command argument")
 
 const (
 	streamIDMin      = "0-0"
@@ -42,22 +32,6 @@ const (
 	autoGeneratedTimeAndSeq = -2
 )
 
-type iDValidator func(string) bool
-
-var operationValidators = map[operationType]iDValidator{
-	operationTypeRange: isRangeQueryMarker,
-	operationTypeRead:  func(id string) bool { return false }, // for no specific checks always return false for now
-}
-
-/**
- * isRangeQueryMarker checks if the id is a range query marker like "-" or "+"
- * @param id string - the ID to check
- * @return bool - true if the ID is a range query marker, false otherwise
- */
-func isRangeQueryMarker(id string) bool {
-	return id == rangeQueryStart || id == rangeQueryEnd
-}
-
 /**
  * isWildcard checks if the id is a wildcard i.e. "*"
  * @param id string - the ID to check
@@ -90,53 +64,19 @@ func getCurrentMillisTime() int64 {
  * @return bool - true if the key exists, false otherwise
  */
 func (sm *streamManager) isStreamKey(key string) bool {
-	_, exists := sm.streams[key]
+	_, exists := sm.getStream(key)
 	return exists
 }
 
 /**
- * isValidStreamRecordIdExists checks if a stream record ID exists
- * @param streamName string - the name of the stream
- * @param id string - the ID to check
- * @param typeOfOperation string - the type of operation, "range" or "read" etc
- * @return bool - true if the ID exists, false otherwise
- */
-func (sm *streamManager) isValidStreamRecordIdExists(streamName string, id string, op operationType) (bool, error) {
-	if !sm.isStreamKey(streamName) {
-		return false, ErrInvalidStream
-	}
-
-	// some checks for specific operations like range where we need to check the id is a range query marker like "-" or "+"
-	validator, exists := operationValidators[op]
-	if !exists {
-		return false, unknownOperationType(op)
-	}
-
-	if validator(id) {
-		return true, nil
-	}
-
-	stream := sm.streams[streamName]
-	stream.mu.RLock()
-	defer stream.mu.RUnlock()
-
-	_, exists = stream.recordMap[id]
-	return exists, nil
-}
-
-/**
- * The ID should be greater than the ID of the last entry in the stream.
- * The millisecondsTime part of the ID should be greater than or equal to the millisecondsTime of the last entry.
- * If the millisecondsTime part of the ID is equal to the millisecondsTime of the last entry, the sequenceNumber part of the ID should be greater than the sequenceNumber of the last entry.
- * If the stream is empty, the ID should be greater than 0-0
+ * The ID should be greater than the last ID of the stream, the greatest ID ever added to it even if that entry was deleted since.
+ * The millisecondsTime part of the ID should be greater than or equal to the millisecondsTime of the last ID.
+ * If the millisecondsTime part of the ID is equal to the millisecondsTime of the last ID, the sequenceNumber part of the ID should be greater than the sequenceNumber of the last ID.
+ * If nothing was ever added to the stream, the ID should be greater than 0-0
+ * @param lastId streamID - the last ID of the stream, 0-0 if nothing was ever added to it
+ * @param id string - the ID given to XADD
  */
-func (sm *streamManager) verifyStreamId(streamName, id string) (bool, error) {
-	if !sm.isStreamKey(streamName) {
-		return false, ErrInvalidStream
-	}
-
-	stream := sm.streams[streamName]
-
+func (sm *streamManager) verifyStreamId(lastId streamID, id string) (bool, error) {
 	// check for "0-0"
 	if isMinStreamID(id) {
 		return false, ErrInvalidStreamIdXAddMustBeGreaterThanMin
@@ -147,14 +87,20 @@ func (sm *streamManager) verifyStreamId(streamName, id string) (bool, error) {
 		return false, err
 	}
 
-	// if the id is a wildcard or auto sequence, then it is valid
-	if wildcardNum == autoGeneratedTimeAndSeq || wildcardNum == autoGeneratedSeq {
+	// a fully generated id is always valid
+	if wildcardNum == autoGeneratedTimeAndSeq {
 		return true, nil
 	}
 
-	lastEntry := stream.recordList.Back()
+	// an auto sequence only needs a millisecondsTime that leaves room after the last ID
+	if wildcardNum == autoGeneratedSeq {
+		if uint64(msTime) < lastId.ms || (uint64(msTime) == lastId.ms && lastId.seq >= math.MaxInt) {
+			return false, ErrInvalidStreamIdXAdd
+		}
+		return true, nil
+	}
 
-	if lastEntry == nil {
+	if lastId == (streamID{}) {
 
 		// if id is greater than 0-0
 		if msTime > 0 || (msTime == 0 && seqNum > 0) {
@@ -164,16 +110,7 @@ func (sm *streamManager) verifyStreamId(streamName, id string) (bool, error) {
 		return false, ErrInvalidStreamIdXAddMustBeGreaterThanMin
 	}
 
-	lastEntryRecord, ok := lastEntry.Value.(*StreamRecord)
-	if !ok {
-		return false, ErrUnexpectedTypeInListElement
-	}
-
-	if lastEntryRecord.millisecondsTime > msTime {
-		return false, ErrInvalidStreamIdXAdd
-	}
-
-	if lastEntryRecord.millisecondsTime == msTime && lastEntryRecord.sequenceNumber >= seqNum {
+	if (streamID{ms: uint64(msTime), seq: uint64(seqNum)}).compare(lastId) <= 0 {
 		return false, ErrInvalidStreamIdXAdd
 	}
 
@@ -220,38 +157,168 @@ func (sm *streamManager) parseStreamId(id string) (int, int64, int, error) {
 	return 0, msTime, seqNum, nil
 }
 
+// streamID is a parsed entry ID, ordered by its millisecondsTime and then its sequenceNumber
+type streamID struct {
+	ms, seq uint64
+}
+
+var streamIDMax = streamID{ms: math.MaxUint64, seq: math.MaxUint64}
+
+func (id streamID) String() string {
+	return strconv.FormatUint(id.ms, 10) + "-" + strconv.FormatUint(id.seq, 10)
+}
+
+/**
+ * compare orders two IDs
+ * @param other streamID - the ID to compare with
+ * @return int - -1 if id is smaller than other, 1 if it is greater, 0 if they are equal
+ */
+func (id streamID) compare(other streamID) int {
+	switch {
+	case id.ms < other.ms, id.ms == other.ms && id.seq < other.seq:
+		return -1
+	case id == other:
+		return 0
+	}
+	return 1
+}
+
+/**
+ * next returns the ID right after id
+ * @return streamID - the next ID
+ * @return bool - false if id is the greatest ID there is
+ */
+func (id streamID) next() (streamID, bool) {
+	switch {
+	case id.seq < math.MaxUint64:
+		return streamID{ms: id.ms, seq: id.seq + 1}, true
+	case id.ms < math.MaxUint64:
+		return streamID{ms: id.ms + 1}, true
+	}
+	return id, false
+}
+
+/**
+ * prev returns the ID right before id
+ * @return streamID - the previous ID
+ * @return bool - false if id is 0-0
+ */
+func (id streamID) prev() (streamID, bool) {
+	switch {
+	case id.seq > 0:
+		return streamID{ms: id.ms, seq: id.seq - 1}, true
+	case id.ms > 0:
+		return streamID{ms: id.ms - 1, seq: math.MaxUint64}, true
+	}
+	return id, false
+}
+
+/**
+ * recordStreamID returns the parsed ID of a record
+ * @param record *StreamRecord - the record
+ * @return streamID - its ID
+ */
+func recordStreamID(record *StreamRecord) streamID {
+	return streamID{ms: uint64(record.millisecondsTime), seq: uint64(record.sequenceNumber)}
+}
+
+/**
+ * parseStreamEntryID parses an ID given to a stream command, "ms-seq" or just "ms"
+ * @param id string - the ID
+ * @param missingSeq uint64 - the sequence number of an ID given as just "ms"
+ * @return streamID - the parsed ID
+ * @return bool - false if it is not a valid ID
+ */
+func parseStreamEntryID(id string, missingSeq uint64) (streamID, bool) {
+	msPart, seqPart, hasSeq := strings.Cut(id, "-")
+
+	ms, err := strconv.ParseUint(msPart, 10, 64)
+	if err != nil {
+		return streamID{}, false
+	}
+	if !hasSeq {
+		return streamID{ms: ms, seq: missingSeq}, true
+	}
+
+	seq, err := strconv.ParseUint(seqPart, 10, 64)
+	if err != nil {
+		return streamID{}, false
+	}
+	return streamID{ms: ms, seq: seq}, true
+}
+
+/**
+ * ValidStreamId checks if id is an ID a stream command can take as an argument, "ms-seq" or just "ms"
+ * @param id string - the ID to check
+ * @return bool - true if it is valid
+ */
+func ValidStreamId(id string) bool {
+	_, ok := parseStreamEntryID(id, 0)
+	return ok
+}
+
+/**
+ * parseStreamRangeID parses a bound of an ID range: "-", "+", an ID, or an ID prefixed by "(" to leave it out of the range.
+ * a start given as just "ms" begins at ms-0 and an end given that way stops at the last sequence number of ms
+ * @param bound string - the bound
+ * @param isEnd bool - true for the end of the range
+ * @return streamID - the first or last ID in the range
+ * @return error - ErrInvalidStreamIdArgument, or an error if an excluded bound leaves nothing before or after it
+ */
+func parseStreamRangeID(bound string, isEnd bool) (streamID, error) {
+	missingSeq := uint64(0)
+	if isEnd {
+		missingSeq = math.MaxUint64
+	}
+
+	if bound == rangeQueryStart {
+		return streamID{}, nil
+	}
+	if bound == rangeQueryEnd {
+		return streamIDMax, nil
+	}
+
+	excluded := strings.HasPrefix(bound, "(")
+	id, ok := parseStreamEntryID(strings.TrimPrefix(bound, "("), missingSeq)
+	if !ok {
+		return streamID{}, ErrInvalidStreamIdArgument
+	}
+	if !excluded {
+		return id, nil
+	}
+
+	if isEnd {
+		if id, ok = id.prev(); !ok {
+			return streamID{}, errors.New("ERR invalid end ID for the interval")
+		}
+		return id, nil
+	}
+	if id, ok = id.next(); !ok {
+		return streamID{}, errors.New("ERR invalid start ID for the interval")
+	}
+	return id, nil
+}
+
 /**
  * generateStreamId generates a new stream ID
- * @param streamName string - the name of the stream
+ * @param lastId streamID - the last ID of the stream, 0-0 if nothing was ever added to it
  * @param id string - the ID of the new entry
  * @return string - the new stream ID
  * @return int64 - the millisecondsTime part of the new stream ID
  * @return int - the sequenceNumber part of the new stream ID
  * @return error - the error if there is one
  */
-func (sm *streamManager) generateStreamId(streamName string, id string) (string, int64, int, error) {
-
-	if !sm.isStreamKey(streamName) {
-		return "", 0, 0, ErrInvalidStream
-	}
-
-	stream := sm.streams[streamName]
+func (sm *streamManager) generateStreamId(lastId streamID, id string) (string, int64, int, error) {
 	wildcardNum, msTime, _, err := sm.parseStreamId(id)
 	if err != nil {
 		return "", 0, 0, err
 	}
 
-	lastEntry := stream.recordList.Back()
-	if lastEntry == nil {
+	if lastId == (streamID{}) {
 		return sm.generateFirstId(msTime, wildcardNum)
 	}
 
-	lastRecord, ok := lastEntry.Value.(*StreamRecord)
-	if !ok {
-		return "", 0, 0, ErrUnexpectedTypeInListElement
-	}
-
-	return sm.generateNextId(lastRecord, msTime, wildcardNum)
+	return sm.generateNextId(lastId, msTime, wildcardNum)
 }
 
 /**
@@ -284,7 +351,7 @@ func (sm *streamManager) generateFirstId(msTime int64, wildcardNum int) (string,
 
 /**
  * generateNextId generates the stream ID for the next entry in the stream
- * @param lastRecord *StreamRecord - the last entry in the stream
+ * @param lastId streamID - the last ID of the stream
  * @param msTime int64 - the millisecondsTime part of the new stream ID
  * @param wildcardNum int - the type of the ID, 0 means that the ID is not auto generated, -1 means that the sequence is auto generated, -2 means that the millisecondsTime and sequenceNumber are auto generated
  * @return string - the new stream ID
@@ -292,26 +359,21 @@ func (sm *streamManager) generateFirstId(msTime int64, wildcardNum int) (string,
  * @return int - the sequenceNumber part of the new stream ID
  * @return error - the error if there is one
  */
-func (sm *streamManager) generateNextId(lastRecord *StreamRecord, msTime int64, wildcardNum int) (string, int64, int, error) {
+func (sm *streamManager) generateNextId(lastId streamID, msTime int64, wildcardNum int) (string, int64, int, error) {
 	var newSeqNum int
 	var newMsTime int64
 
 	if wildcardNum == autoGeneratedSeq {
 
-		if msTime == lastRecord.millisecondsTime {
-			newSeqNum = lastRecord.sequenceNumber + 1
+		if uint64(msTime) == lastId.ms {
+			newSeqNum = int(lastId.seq) + 1
 		} else {
 			newSeqNum = 0
 		}
 		newMsTime = msTime
 	} else {
-
-		if msTime == lastRecord.millisecondsTime {
-			newSeqNum = lastRecord.sequenceNumber + 1
-		} else {
-			newSeqNum = 0
-		}
-		newMsTime = time.Now().UnixNano() / int64(time.Millisecond)
+		// the same generator NEXTID uses, so a stalled or regressed clock doesn't hand out an ID at or below the last one
+		newMsTime, newSeqNum = nextMonotonicID(int64(lastId.ms), int(lastId.seq), getCurrentMillisTime(), math.MaxInt)
 	}
 
 	return fmt.Sprintf("%d-%d", newMsTime, newSeqNum), newMsTime, newSeqNum, nil
@@ -342,6 +404,16 @@ func (sm *streamManager) createStreamMessages(records []StreamRecord) []RESP.RES
 			)
 		}
 
+		fields := RESP.RESPMessage{
+			RESPType:      RESP.Array,
+			RESPLen:       len(kvPairs),
+			RESPArrayElem: kvPairs,
+		}
+		// a pending entry that was deleted from the stream has no data, its fields are nil
+		if record.Data == nil {
+			fields = RESP.RESPMessage{RESPType: RESP.Array, RESPLen: -1}
+		}
+
 		entries[i] = RESP.RESPMessage{
 			RESPType: RESP.Array,
 			RESPLen:  2,
@@ -351,57 +423,9 @@ func (sm *streamManager) createStreamMessages(records []StreamRecord) []RESP.RES
 					RESPLen:   len(record.Id),
 					RESPValue: []byte(record.Id),
 				},
-				{
-					RESPType:      RESP.Array,
-					RESPLen:       len(kvPairs),
-					RESPArrayElem: kvPairs,
-				},
+				fields,
 			},
 		}
 	}
 	return entries
 }
-
-/*
- 	* subscribe subscribes a subscriber to the stream, adds a channel to the map and returns the channel
-	* @return chan struct{} - the channel to subscribe to
-*/
-func (s *stream) subscribe() chan struct{} {
-	// s.mu.Lock()
-	// defer s.mu.Unlock()
-	// keeping no locks here because we use it in the parent function, but what if we want to use it in other functions and that has no locks?not sure if this statement itself is right.
-	ch := make(chan struct{}, 1)
-	s.subscribers[ch] = struct{}{}
-	return ch
-}
-
-/*
- 	* unsubscribe unsubscribes a subscriber from the stream, deletes the channel from the map and closes the channel
-	* @param ch chan struct{} - the channel to unsubscribe
-*/
-func (s *stream) unsubscribe(ch chan struct{}) {
-	// s.mu.Lock()
-	// defer s.mu.Unlock()
-	// keeping no locks here because we use it in the parent function, but what if we want to use it in other functions and that has no locks?not sure if this statement itself is right.
-
-	delete(s.subscribers, ch)
-	close(ch)
-}
-
-/*
- 	* notifySubscribers notifies all subscribers of a new record
-	* @param s *Stream - the stream to notify subscribers of
-*/
-func (s *stream) notifySubscribers() {
-	// s.mu.Lock()
-	// defer s.mu.Unlock()
-	// keeping no locks here because we use it in the parent function, but what if we want to use it in other functions and that has no locks?not sure if this statement itself is right.
-
-	for ch := range s.subscribers {
-
-		select {
-		case ch <- struct{}{}:
-		default: // missing a notification is okay because the subscriber will check for new records anyway
-		}
-	}
-}
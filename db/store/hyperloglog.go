@@ -0,0 +1,179 @@
+package store
+
+import (
+	"errors"
+	"time"
+)
+
+var ErrNotHLL = errors.New("WRONGTYPE Key is not a valid HyperLogLog string value.")
+var ErrHLLCorrupted = errors.New("INVALIDOBJ Corrupted HLL object detected")
+
+/*
+ 	* hllLocked returns the HyperLogLog at key, the key-value write lock must be held
+	* @param key string - the key of the HyperLogLog
+	* @param now time.Time - the current time
+	* @return []byte - the HyperLogLog, nil if the key doesn't exist
+	* @return storedValue - the value holding it
+	* @return error - ErrWrongType if the key holds something other than a string, ErrNotHLL if the string is not a HyperLogLog
+*/
+func (s *Store) hllLocked(key string, now time.Time) ([]byte, storedValue, error) {
+	stored, exists, err := s.stringLocked(key, now)
+	if err != nil || !exists {
+		return nil, storedValue{}, err
+	}
+
+	data := stored.bytes()
+	if !isHLL(data) {
+		return nil, stored, ErrNotHLL
+	}
+	return data, stored, nil
+}
+
+/*
+ 	* hllMergeLocked merges the registers of the HyperLogLogs at keys into registers, keeping the largest value of each,
+	* missing keys are skipped. the key-value write lock must be held
+	* @param keys []string - the keys of the HyperLogLogs
+	* @param now time.Time - the current time
+	* @param registers *hllRegisterSet - the registers to merge into
+	* @return bool - true if any of the HyperLogLogs is dense
+	* @return error - ErrWrongType, ErrNotHLL or ErrHLLCorrupted
+*/
+func (s *Store) hllMergeLocked(keys []string, now time.Time, registers *hllRegisterSet) (bool, error) {
+	dense := false
+	var other hllRegisterSet
+	for _, key := range keys {
+		data, _, err := s.hllLocked(key, now)
+		if err != nil {
+			return false, err
+		}
+		if data == nil {
+			continue
+		}
+
+		if !hllRegistersOf(data, &other) {
+			return false, ErrHLLCorrupted
+		}
+		for i, value := range other {
+			registers[i] = max(registers[i], value)
+		}
+		dense = dense || data[4] == hllDense
+	}
+	return dense, nil
+}
+
+/*
+ 	* PFAdd adds elements to the HyperLogLog at key, creating it if it doesn't exist, the expiration is kept
+	* @param key string - the key of the HyperLogLog
+	* @param elements [][]byte - the elements
+	* @return bool - true if the key was created or a register changed, so the estimated cardinality may have changed
+	* @return error - ErrWrongType, ErrNotHLL or ErrHLLCorrupted
+*/
+func (s *Store) PFAdd(key string, elements [][]byte) (bool, error) {
+	s.kv.mu.Lock()
+	defer s.kv.mu.Unlock()
+
+	data, stored, err := s.hllLocked(key, time.Now())
+	if err != nil {
+		return false, err
+	}
+
+	created := data == nil
+	if created {
+		data = newHLL()
+	}
+
+	var registers hllRegisterSet
+	if !hllRegistersOf(data, &registers) {
+		return false, ErrHLLCorrupted
+	}
+
+	changed := false
+	for _, element := range elements {
+		index, count := hllPatLen(element)
+		if count > registers[index] {
+			registers[index] = count
+			changed = true
+		}
+	}
+
+	if changed {
+		data = encodeHLL(&registers, data[4] == hllSparse)
+	}
+	if changed || created {
+		stored.setBytes(data)
+		s.kv.put(key, stored)
+	}
+	return changed || created, nil
+}
+
+/*
+ 	* PFCount estimates the cardinality of the HyperLogLog at a key, or of the union of the HyperLogLogs at several keys.
+	* for a single key the estimate is cached in the header until the next change, like Redis does
+	* @param keys []string - the keys of the HyperLogLogs
+	* @return uint64 - the estimated cardinality, 0 if none of the keys exist
+	* @return error - ErrWrongType, ErrNotHLL or ErrHLLCorrupted
+*/
+func (s *Store) PFCount(keys []string) (uint64, error) {
+	s.kv.mu.Lock()
+	defer s.kv.mu.Unlock()
+
+	now := time.Now()
+	var registers hllRegisterSet
+
+	if len(keys) > 1 {
+		if _, err := s.hllMergeLocked(keys, now, &registers); err != nil {
+			return 0, err
+		}
+		return hllCount(&registers), nil
+	}
+
+	data, stored, err := s.hllLocked(keys[0], now)
+	if err != nil || data == nil {
+		return 0, err
+	}
+	if count, valid := hllCachedCount(data); valid {
+		return count, nil
+	}
+
+	if !hllRegistersOf(data, &registers) {
+		return 0, ErrHLLCorrupted
+	}
+	count := hllCount(&registers)
+
+	// cache it in a copy, the old bytes may still be in use by a reply being written or a snapshot being saved
+	data = append([]byte{}, data...)
+	setHLLCachedCount(data, count)
+	stored.setBytes(data)
+	s.kv.put(keys[0], stored)
+
+	return count, nil
+}
+
+/*
+ 	* PFMerge stores the union of the HyperLogLogs at dst and keys at dst, creating it if it doesn't exist. the result is
+	* dense if any of them is, and the expiration of dst is kept
+	* @param dst string - the key to store the union at
+	* @param keys []string - the keys of the HyperLogLogs to merge into it
+	* @return error - ErrWrongType, ErrNotHLL or ErrHLLCorrupted
+*/
+func (s *Store) PFMerge(dst string, keys []string) error {
+	s.kv.mu.Lock()
+	defer s.kv.mu.Unlock()
+
+	now := time.Now()
+
+	_, stored, err := s.hllLocked(dst, now)
+	if err != nil {
+		return err
+	}
+
+	var registers hllRegisterSet
+	dense, err := s.hllMergeLocked(append([]string{dst}, keys...), now, &registers)
+	if err != nil {
+		return err
+	}
+
+	stored.setBytes(encodeHLL(&registers, !dense))
+	s.kv.put(dst, stored)
+	return nil
+}
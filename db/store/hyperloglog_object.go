@@ -0,0 +1,361 @@
+package store
+
+import (
+	"encoding/binary"
+	"math"
+	"math/bits"
+)
+
+// a HyperLogLog is a string laid out exactly like Redis lays it out, so it can be moved between the two with GET and
+// SET or through an RDB file. a 16 byte header, "HYLL", the encoding, 3 unused bytes and the cardinality last computed,
+// followed by 16384 registers of 6 bits each. with that many registers the standard error is 1.04/sqrt(16384), 0.81%.
+//
+// dense, the registers are packed one after the other, 12288 bytes. sparse, they are run-length encoded with three
+// opcodes, which is what a HyperLogLog starts as, since most of its registers are 0 for a long time:
+//
+//	ZERO  00xxxxxx          - 1 to 64 registers set to 0
+//	XZERO 01xxxxxx yyyyyyyy - 1 to 16384 registers set to 0
+//	VAL   1vvvvvxx          - 1 to 4 registers set to 1 to 32
+//
+// a sparse HyperLogLog turns dense once a register goes past 32 or it grows past hllSparseMaxBytes
+const (
+	hllP              = 14
+	hllQ              = 64 - hllP
+	hllRegisters      = 1 << hllP
+	hllBits           = 6
+	hllRegisterMax    = 1<<hllBits - 1
+	hllHeaderSize     = 16
+	hllDenseSize      = hllHeaderSize + (hllRegisters*hllBits+7)/8
+	hllDense          = 0
+	hllSparse         = 1
+	hllSparseMaxBytes = 3000 // hll-sparse-max-bytes
+	hllSparseValMax   = 32
+	hllAlphaInf       = 0.721347520444481703680 // 0.5/ln(2)
+	hllHashSeed       = 0xadc83b19
+)
+
+type hllRegisterSet [hllRegisters]uint8
+
+/*
+ 	* isHLL checks if a string is a HyperLogLog, by its header and, when dense, its length
+	* @param data []byte - the string
+	* @return bool - true if it is, false otherwise
+*/
+func isHLL(data []byte) bool {
+	if len(data) < hllHeaderSize || string(data[:4]) != "HYLL" || data[4] > hllSparse {
+		return false
+	}
+	return data[4] != hllDense || len(data) == hllDenseSize
+}
+
+/*
+ 	* newHLL returns an empty sparse HyperLogLog, every register 0 and a cached cardinality of 0
+	* @return []byte - the HyperLogLog
+*/
+func newHLL() []byte {
+	data := make([]byte, hllHeaderSize, hllHeaderSize+2)
+	copy(data, "HYLL")
+	data[4] = hllSparse
+	return appendHLLZeros(data, hllRegisters)
+}
+
+/*
+ 	* hllCachedCount returns the cardinality cached in the header
+	* @param data []byte - the HyperLogLog
+	* @return uint64 - the cardinality
+	* @return bool - false if the registers changed since it was cached
+*/
+func hllCachedCount(data []byte) (uint64, bool) {
+	if data[15]&0x80 != 0 {
+		return 0, false
+	}
+	return binary.LittleEndian.Uint64(data[8:16]), true
+}
+
+/*
+ 	* setHLLCachedCount caches the cardinality in the header
+	* @param data []byte - the HyperLogLog
+	* @param count uint64 - the cardinality
+*/
+func setHLLCachedCount(data []byte, count uint64) {
+	binary.LittleEndian.PutUint64(data[8:16], count)
+}
+
+/*
+ 	* invalidateHLLCachedCount marks the cardinality cached in the header as stale, the way Redis does, with the most
+	* significant bit of its last byte
+	* @param data []byte - the HyperLogLog
+*/
+func invalidateHLLCachedCount(data []byte) {
+	data[15] |= 0x80
+}
+
+/*
+ 	* hllRegistersOf decodes the registers of a HyperLogLog of either encoding
+	* @param data []byte - the HyperLogLog, isHLL must hold
+	* @param registers *hllRegisterSet - where to decode them to
+	* @return bool - false if a sparse HyperLogLog is corrupted
+*/
+func hllRegistersOf(data []byte, registers *hllRegisterSet) bool {
+	if data[4] == hllDense {
+		for i := range registers {
+			registers[i] = hllDenseGet(data[hllHeaderSize:], i)
+		}
+		return true
+	}
+
+	index := 0
+	for p := hllHeaderSize; p < len(data); p++ {
+		op := data[p]
+		value, run := uint8(0), 0
+		switch {
+		case op&0xc0 == 0x00: // ZERO
+			run = int(op&0x3f) + 1
+		case op&0xc0 == 0x40: // XZERO
+			if p+1 >= len(data) {
+				return false
+			}
+			run = (int(op&0x3f)<<8 | int(data[p+1])) + 1
+			p++
+		default: // VAL
+			value = (op>>2)&0x1f + 1
+			run = int(op&0x03) + 1
+		}
+
+		if index+run > hllRegisters {
+			return false
+		}
+		for i := index; i < index+run; i++ {
+			registers[i] = value
+		}
+		index += run
+	}
+
+	return index == hllRegisters
+}
+
+/*
+ 	* encodeHLL encodes registers as a HyperLogLog, sparse when they fit in hllSparseMaxBytes and dense otherwise
+	* @param registers *hllRegisterSet - the registers
+	* @param sparse bool - try the sparse encoding
+	* @return []byte - the HyperLogLog, with its cached cardinality marked as stale
+*/
+func encodeHLL(registers *hllRegisterSet, sparse bool) []byte {
+	header := make([]byte, hllHeaderSize)
+	copy(header, "HYLL")
+	invalidateHLLCachedCount(header)
+
+	if sparse {
+		if data, ok := encodeSparseHLL(header, registers); ok {
+			return data
+		}
+	}
+
+	data := make([]byte, hllDenseSize)
+	copy(data, header)
+	data[4] = hllDense
+	for i, value := range registers {
+		if value != 0 {
+			hllDenseSet(data[hllHeaderSize:], i, value)
+		}
+	}
+	return data
+}
+
+/*
+ 	* encodeSparseHLL run-length encodes registers after header
+	* @param header []byte - the header
+	* @param registers *hllRegisterSet - the registers
+	* @return []byte - the HyperLogLog
+	* @return bool - false if a register is too large for a VAL opcode or the result is past hllSparseMaxBytes
+*/
+func encodeSparseHLL(header []byte, registers *hllRegisterSet) ([]byte, bool) {
+	data := append([]byte{}, header...)
+	data[4] = hllSparse
+
+	for i := 0; i < hllRegisters; {
+		value := registers[i]
+		run := 1
+		for i+run < hllRegisters && registers[i+run] == value {
+			run++
+		}
+
+		if value == 0 {
+			data = appendHLLZeros(data, run)
+		} else {
+			if value > hllSparseValMax {
+				return nil, false
+			}
+			for left := run; left > 0; left -= 4 {
+				data = append(data, 0x80|(value-1)<<2|byte(min(left, 4)-1))
+			}
+		}
+		if len(data) > hllSparseMaxBytes {
+			return nil, false
+		}
+
+		i += run
+	}
+
+	return data, true
+}
+
+/*
+ 	* appendHLLZeros appends the opcodes for run registers set to 0, ZERO for short runs and XZERO for long ones
+	* @param data []byte - the sparse HyperLogLog so far
+	* @param run int - the number of registers, up to hllRegisters
+	* @return []byte - the sparse HyperLogLog
+*/
+func appendHLLZeros(data []byte, run int) []byte {
+	if run <= 64 {
+		return append(data, byte(run-1))
+	}
+	return append(data, 0x40|byte((run-1)>>8), byte(run-1))
+}
+
+/*
+ 	* hllDenseGet reads register i of dense registers, 6 bits that may straddle two bytes, least significant bits first
+	* @param registers []byte - the registers, past the header
+	* @param i int - the register
+	* @return uint8 - its value
+*/
+func hllDenseGet(registers []byte, i int) uint8 {
+	byteIndex := i * hllBits / 8
+	shift := uint(i * hllBits & 7)
+
+	value := uint(registers[byteIndex]) >> shift
+	if byteIndex+1 < len(registers) {
+		value |= uint(registers[byteIndex+1]) << (8 - shift)
+	}
+	return uint8(value & hllRegisterMax)
+}
+
+/*
+ 	* hllDenseSet writes register i of dense registers
+	* @param registers []byte - the registers, past the header
+	* @param i int - the register
+	* @param value uint8 - its value
+*/
+func hllDenseSet(registers []byte, i int, value uint8) {
+	byteIndex := i * hllBits / 8
+	shift := uint(i * hllBits & 7)
+
+	registers[byteIndex] &^= byte(hllRegisterMax << shift)
+	registers[byteIndex] |= byte(uint(value) << shift)
+	if byteIndex+1 < len(registers) {
+		registers[byteIndex+1] &^= byte(hllRegisterMax >> (8 - shift))
+		registers[byteIndex+1] |= byte(uint(value) >> (8 - shift))
+	}
+}
+
+/*
+ 	* hllPatLen returns the register an element goes to and the length of the run of zeros, plus one, in the rest of
+	* its hash, which is what the register keeps the maximum of
+	* @param element []byte - the element
+	* @return int - the register
+	* @return uint8 - the run length, 1 to hllQ+1
+*/
+func hllPatLen(element []byte) (int, uint8) {
+	hash := murmurHash64A(element, hllHashSeed)
+	index := int(hash & (hllRegisters - 1))
+	hash >>= hllP
+	hash |= 1 << hllQ // so the run can't be longer than hllQ
+	return index, uint8(bits.TrailingZeros64(hash) + 1)
+}
+
+/*
+ 	* hllCount estimates the cardinality from the registers, with the estimator by Otmar Ertl Redis uses, which needs
+	* no bias correction at either end of the range
+	* @param registers *hllRegisterSet - the registers
+	* @return uint64 - the cardinality
+*/
+func hllCount(registers *hllRegisterSet) uint64 {
+	// registers only go up to hllQ+1, but a dense HyperLogLog that came from elsewhere may hold up to hllRegisterMax
+	var histogram [hllRegisterMax + 1]int
+	for _, value := range registers {
+		histogram[value]++
+	}
+
+	m := float64(hllRegisters)
+	z := m * hllTau((m-float64(histogram[hllQ+1]))/m)
+	for j := hllQ; j >= 1; j-- {
+		z += float64(histogram[j])
+		z *= 0.5
+	}
+	z += m * hllSigma(float64(histogram[0])/m)
+
+	return uint64(math.Round(hllAlphaInf * m * m / z))
+}
+
+func hllTau(x float64) float64 {
+	if x == 0 || x == 1 {
+		return 0
+	}
+
+	y := 1.0
+	z := 1 - x
+	for {
+		x = math.Sqrt(x)
+		prev := z
+		y *= 0.5
+		z -= math.Pow(1-x, 2) * y
+		if prev == z {
+			return z / 3
+		}
+	}
+}
+
+func hllSigma(x float64) float64 {
+	if x == 1 {
+		return math.Inf(1)
+	}
+
+	y := 1.0
+	z := x
+	for {
+		x *= x
+		prev := z
+		z += x * y
+		y += y
+		if prev == z {
+			return z
+		}
+	}
+}
+
+/*
+ 	* murmurHash64A is the 64 bit MurmurHash2 by Austin Appleby, the hash Redis gives HyperLogLog elements, on a little
+	* endian machine
+	* @param data []byte - the data to hash
+	* @param seed uint64 - the seed
+	* @return uint64 - the hash
+*/
+func murmurHash64A(data []byte, seed uint64) uint64 {
+	const m = 0xc6a4a7935bd1e995
+	const r = 47
+
+	h := seed ^ uint64(len(data))*m
+
+	for len(data) >= 8 {
+		k := binary.LittleEndian.Uint64(data)
+		k *= m
+		k ^= k >> r
+		k *= m
+
+		h ^= k
+		h *= m
+		data = data[8:]
+	}
+
+	if len(data) > 0 {
+		for i := len(data) - 1; i >= 0; i-- {
+			h ^= uint64(data[i]) << (8 * i)
+		}
+		h *= m
+	}
+
+	h ^= h >> r
+	h *= m
+	h ^= h >> r
+	return h
+}
@@ -0,0 +1,123 @@
+package store
+
+import (
+	"testing"
+)
+
+func TestEncodeHLL(t *testing.T) {
+	tests := []struct {
+		name      string
+		registers func(r *hllRegisterSet)
+		sparse    bool // asked for the sparse encoding
+		wantDense bool
+	}{
+		{"empty", func(r *hllRegisterSet) {}, true, false},
+		{"a few registers", func(r *hllRegisterSet) { r[0], r[100], r[hllRegisters-1] = 1, 5, 32 }, true, false},
+		{"long runs of a value", func(r *hllRegisterSet) {
+			for i := 1000; i < 3000; i++ {
+				r[i] = 3
+			}
+		}, true, false},
+		{"a value too large for VAL", func(r *hllRegisterSet) { r[42] = hllSparseValMax + 1 }, true, true},
+		{"past hll-sparse-max-bytes", func(r *hllRegisterSet) {
+			// alternating values, one VAL opcode each, take more than 3000 bytes
+			for i := 0; i < 4000; i++ {
+				r[i] = uint8(i%2 + 1)
+			}
+		}, true, true},
+		{"dense asked for", func(r *hllRegisterSet) { r[7] = 2 }, false, true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var registers hllRegisterSet
+			test.registers(&registers)
+
+			data := encodeHLL(&registers, test.sparse)
+			if !isHLL(data) {
+				t.Fatalf("the encoding is not a HyperLogLog")
+			}
+			if dense := data[4] == hllDense; dense != test.wantDense {
+				t.Fatalf("dense = %v, want %v", dense, test.wantDense)
+			}
+			if data[4] == hllSparse && len(data) > hllSparseMaxBytes {
+				t.Fatalf("a sparse encoding of %d bytes, past the %d limit", len(data), hllSparseMaxBytes)
+			}
+			if _, valid := hllCachedCount(data); valid {
+				t.Fatalf("a new encoding has a valid cached cardinality")
+			}
+
+			var decoded hllRegisterSet
+			if !hllRegistersOf(data, &decoded) {
+				t.Fatalf("the encoding does not decode")
+			}
+			if decoded != registers {
+				t.Fatalf("the registers changed in the round trip")
+			}
+		})
+	}
+}
+
+func TestHLLSparseToDense(t *testing.T) {
+	// registers filled one at a time, as PFADD does, stay sparse until the encoding can't hold them anymore
+	var registers hllRegisterSet
+	data := newHLL()
+	for i := 0; i < hllRegisters && data[4] == hllSparse; i += 3 {
+		registers[i] = uint8(i%hllSparseValMax + 1)
+		data = encodeHLL(&registers, data[4] == hllSparse)
+	}
+
+	if data[4] != hllDense {
+		t.Fatalf("the HyperLogLog never turned dense")
+	}
+	if len(data) != hllDenseSize {
+		t.Fatalf("a dense HyperLogLog of %d bytes, want %d", len(data), hllDenseSize)
+	}
+
+	// once dense, it stays dense even when the registers would fit in the sparse encoding again
+	var decoded hllRegisterSet
+	if !hllRegistersOf(data, &decoded) || decoded != registers {
+		t.Fatalf("the dense registers differ from the sparse ones")
+	}
+	if data = encodeHLL(&registers, data[4] == hllSparse); data[4] != hllDense {
+		t.Fatalf("a dense HyperLogLog turned sparse again")
+	}
+}
+
+func TestHLLRegistersOfCorrupted(t *testing.T) {
+	tests := []struct {
+		name    string
+		opcodes []byte
+	}{
+		{"too few registers", []byte{0x00}},
+		{"too many registers", []byte{0x7f, 0xff, 0x00}},
+		{"truncated XZERO", []byte{0x40}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			data := append(newHLL()[:hllHeaderSize], test.opcodes...)
+			var registers hllRegisterSet
+			if hllRegistersOf(data, &registers) {
+				t.Fatalf("a corrupted sparse HyperLogLog decoded")
+			}
+		})
+	}
+}
+
+func TestHLLCachedCount(t *testing.T) {
+	data := newHLL()
+	if count, valid := hllCachedCount(data); !valid || count != 0 {
+		t.Fatalf("a new HyperLogLog caches %d, valid %v, want 0", count, valid)
+	}
+
+	setHLLCachedCount(data, 12345)
+	if count, valid := hllCachedCount(data); !valid || count != 12345 {
+		t.Fatalf("the cached cardinality is %d, valid %v, want 12345", count, valid)
+	}
+
+	invalidateHLLCachedCount(data)
+	if _, valid := hllCachedCount(data); valid {
+		t.Fatalf("the cached cardinality is still valid after being invalidated")
+	}
+}
@@ -0,0 +1,304 @@
+package store
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"math"
+	"strconv"
+	"time"
+)
+
+var ErrInvalidCount = errors.New("ERR COUNT must be a positive integer")
+var ErrCountTooLarge = errors.New("ERR COUNT must be at most " + strconv.Itoa(NextIDMaxCount))
+
+// NextIDMaxCount is the most IDs a single NEXTID hands out, they are all generated with the lock held
+const NextIDMaxCount = 10000
+
+type IDFormat int
+
+const (
+	IDFormatSnowflake IDFormat = iota // 41 bits of milliseconds since snowflakeEpoch, 10 bits of node ID, 12 bits of sequence, as a decimal
+	IDFormatStream                    // milliseconds-sequence, like a stream entry ID
+	IDFormatULID                      // 48 bits of milliseconds and 80 random bits, in Crockford's base32
+)
+
+const (
+	MaxNodeID = 1<<snowflakeNodeBits - 1
+
+	snowflakeEpoch    = 1288834974657 // the epoch of Twitter's snowflake, 2010-11-04
+	snowflakeNodeBits = 10
+	snowflakeSeqBits  = 12
+	snowflakeMaxSeq   = 1<<snowflakeSeqBits - 1
+)
+
+const crockfordBase32 = "0123456789ABCDEFGHJKMNPQRSTVWXYZ"
+
+/*
+ 	* nextMonotonicID returns the ID that follows last: the current time with sequence 0 once the clock has moved past last,
+	* otherwise last's time with the next sequence, so the IDs keep growing when the clock stalls or goes back.
+	* a sequence past maxSeq borrows the next millisecond, as Redis does for stream IDs
+	* @param lastMs int64 - the milliseconds part of the last ID
+	* @param lastSeq int - the sequence part of the last ID
+	* @param nowMs int64 - the current time in milliseconds
+	* @param maxSeq int - the largest sequence the format can hold
+	* @return int64 - the milliseconds part of the next ID
+	* @return int - the sequence part of the next ID
+*/
+func nextMonotonicID(lastMs int64, lastSeq int, nowMs int64, maxSeq int) (int64, int) {
+	if nowMs > lastMs {
+		return nowMs, 0
+	}
+	if lastSeq >= maxSeq {
+		return lastMs + 1, 0
+	}
+	return lastMs, lastSeq + 1
+}
+
+// idGenerator is the high-water mark of a NEXTID namespace, every format draws from it so IDs stay monotonic within the namespace
+type idGenerator struct {
+	lastMs  int64
+	lastSeq int
+
+	// the random part of a ULID is drawn once per millisecond and then incremented, like monotonic ULID generators do
+	ulidMs     int64 // the millisecond the base was drawn for
+	ulidBaseHi uint16
+	ulidBaseLo uint64
+}
+
+// IDGeneratorSnapshot is the high-water mark of a NEXTID namespace, so it can be written to and restored from disk
+type IDGeneratorSnapshot struct {
+	LastMs  int64
+	LastSeq int
+}
+
+/*
+ 	* next advances the high-water mark and returns it
+	* @param nowMs int64 - the current time in milliseconds
+	* @param maxSeq int - the largest sequence the format can hold
+	* @return int64 - the milliseconds part of the ID
+	* @return int - the sequence part of the ID
+*/
+func (g *idGenerator) next(nowMs int64, maxSeq int) (int64, int) {
+	g.lastMs, g.lastSeq = nextMonotonicID(g.lastMs, g.lastSeq, nowMs, maxSeq)
+	return g.lastMs, g.lastSeq
+}
+
+/*
+ 	* nextString returns the next ID in the format
+	* @param format IDFormat - the format of the ID
+	* @param nodeID int - the node ID embedded in snowflake IDs
+	* @param nowMs int64 - the current time in milliseconds
+	* @return string - the ID
+*/
+func (g *idGenerator) nextString(format IDFormat, nodeID int, nowMs int64) string {
+	switch format {
+	case IDFormatStream:
+		ms, seq := g.next(nowMs, math.MaxInt)
+		return strconv.FormatInt(ms, 10) + "-" + strconv.Itoa(seq)
+
+	case IDFormatULID:
+		ms, seq := g.next(nowMs, math.MaxInt)
+		if seq != 0 && g.ulidMs != ms {
+			// the millisecond is already in use but its base is unknown, after a restart or another format took it,
+			// a fresh base could sort below the IDs already handed out, so move on to the next millisecond
+			g.lastMs, g.lastSeq = ms+1, 0
+			ms, seq = g.lastMs, g.lastSeq
+		}
+		if seq == 0 {
+			g.drawULIDBase(ms)
+		}
+		lo := g.ulidBaseLo + uint64(seq)
+		hi := g.ulidBaseHi
+		if lo < g.ulidBaseLo {
+			hi++ // the top bit of the base is clear, so this never overflows the 80 bits
+		}
+		return encodeULID(uint64(ms)<<16|uint64(hi), lo)
+
+	default:
+		ms, seq := g.next(max(nowMs, snowflakeEpoch), snowflakeMaxSeq)
+		id := (ms-snowflakeEpoch)<<(snowflakeNodeBits+snowflakeSeqBits) | int64(nodeID)<<snowflakeSeqBits | int64(seq)
+		return strconv.FormatInt(id, 10)
+	}
+}
+
+/*
+ 	* drawULIDBase picks the random part of the first ULID of a millisecond
+	* @param ms int64 - the millisecond
+*/
+func (g *idGenerator) drawULIDBase(ms int64) {
+	var buf [10]byte
+	rand.Read(buf[:])
+	buf[0] &= 0x7f // leaves room for the sequence to be added without overflowing
+
+	g.ulidMs = ms
+	g.ulidBaseHi = binary.BigEndian.Uint16(buf[:2])
+	g.ulidBaseLo = binary.BigEndian.Uint64(buf[2:])
+}
+
+/*
+ 	* encodeULID encodes 128 bits as 26 characters of Crockford's base32, the first character only holds 3 bits
+	* @param hi uint64 - the high 64 bits
+	* @param lo uint64 - the low 64 bits
+	* @return string - the ULID
+*/
+func encodeULID(hi, lo uint64) string {
+	var out [26]byte
+	for i := len(out) - 1; i >= 0; i-- {
+		out[i] = crockfordBase32[lo&0x1f]
+		lo = lo>>5 | hi<<59
+		hi >>= 5
+	}
+	return string(out[:])
+}
+
+/*
+ 	* DecodeID recovers the milliseconds and sequence an ID was made from.
+	* the random part of a ULID doesn't hold the sequence, so the largest one is returned, which is never lower than the real one
+	* @param id string - the ID
+	* @param format IDFormat - the format of the ID
+	* @return int64 - the milliseconds part
+	* @return int - the sequence part
+	* @return bool - false if the ID is not valid in the format
+*/
+func DecodeID(id string, format IDFormat) (int64, int, bool) {
+	switch format {
+	case IDFormatStream:
+		var sm streamManager
+		wildcardNum, ms, seq, err := sm.parseStreamId(id)
+		if err != nil || wildcardNum != 0 || ms < 0 || seq < 0 {
+			return 0, 0, false
+		}
+		return ms, seq, true
+
+	case IDFormatULID:
+		if len(id) != 26 {
+			return 0, 0, false
+		}
+		var hi, lo uint64
+		for i := 0; i < len(id); i++ {
+			v := -1
+			for j := 0; j < len(crockfordBase32); j++ {
+				if crockfordBase32[j] == id[i] {
+					v = j
+					break
+				}
+			}
+			if v < 0 || (i == 0 && v > 7) {
+				return 0, 0, false
+			}
+			hi = hi<<5 | lo>>59
+			lo = lo<<5 | uint64(v)
+		}
+		return int64(hi >> 16), math.MaxInt, true
+
+	default:
+		id, err := strconv.ParseInt(id, 10, 64)
+		if err != nil || id < 0 {
+			return 0, 0, false
+		}
+		return id>>(snowflakeNodeBits+snowflakeSeqBits) + snowflakeEpoch, int(id & snowflakeMaxSeq), true
+	}
+}
+
+/*
+ 	* idGeneratorLocked returns the generator at key, creating it if it doesn't exist, the write lock must be held
+	* @param key string - the key of the namespace
+	* @param now time.Time - the current time
+	* @return *idGenerator - the generator
+	* @return storedValue - the value holding the generator
+	* @return error - ErrWrongType if the key holds something other than an ID generator
+*/
+func (s *Store) idGeneratorLocked(key string, now time.Time) (*idGenerator, storedValue, error) {
+	stored, exists := s.kv.lookupLocked(key, now)
+	if !exists {
+		if s.streams.isStreamKey(key) {
+			return nil, stored, ErrWrongType
+		}
+		stored = storedValue{object: &idGenerator{}}
+	}
+
+	generator, ok := stored.object.(*idGenerator)
+	if !ok {
+		return nil, stored, ErrWrongType
+	}
+
+	return generator, stored, nil
+}
+
+/*
+ 	* NextID hands out the next IDs of a namespace, creating it if it doesn't exist
+	* @param key string - the key of the namespace
+	* @param count int - how many IDs to hand out
+	* @param format IDFormat - the format of the IDs
+	* @param nodeID int - the node ID embedded in snowflake IDs, from 0 to MaxNodeID
+	* @return []string - the IDs, in increasing order
+	* @return error - ErrInvalidCount, ErrCountTooLarge or ErrWrongType
+*/
+func (s *Store) NextID(key string, count int, format IDFormat, nodeID int) ([]string, error) {
+	if count < 1 {
+		return nil, ErrInvalidCount
+	}
+	if count > NextIDMaxCount {
+		return nil, ErrCountTooLarge
+	}
+
+	s.kv.mu.Lock()
+	defer s.kv.mu.Unlock()
+
+	now := time.Now()
+	generator, stored, err := s.idGeneratorLocked(key, now)
+	if err != nil {
+		return nil, err
+	}
+
+	ids := make([]string, count)
+	for i := range ids {
+		ids[i] = generator.nextString(format, nodeID, now.UnixMilli())
+	}
+
+	s.kv.put(key, stored)
+	return ids, nil
+}
+
+/*
+ 	* SetLastID raises the high-water mark of a namespace, creating it if it doesn't exist, a lower mark is ignored
+	* @param key string - the key of the namespace
+	* @param ms int64 - the milliseconds part of the mark
+	* @param seq int - the sequence part of the mark
+	* @return error - ErrWrongType if the key holds something other than an ID generator
+*/
+func (s *Store) SetLastID(key string, ms int64, seq int) error {
+	s.kv.mu.Lock()
+	defer s.kv.mu.Unlock()
+
+	generator, stored, err := s.idGeneratorLocked(key, time.Now())
+	if err != nil {
+		return err
+	}
+
+	if ms > generator.lastMs || (ms == generator.lastMs && seq > generator.lastSeq) {
+		generator.lastMs, generator.lastSeq = ms, seq
+	}
+
+	s.kv.put(key, stored)
+	return nil
+}
+
+/*
+ 	* RestoreIDGenerator puts a namespace read from disk back at key
+	* @param key string - the key of the namespace
+	* @param snapshot IDGeneratorSnapshot - the high-water mark
+	* @param expiration time.Duration - the time to live, 0 for none
+*/
+func (s *Store) RestoreIDGenerator(key string, snapshot IDGeneratorSnapshot, expiration time.Duration) {
+	s.kv.mu.Lock()
+	defer s.kv.mu.Unlock()
+
+	stored := storedValue{object: &idGenerator{lastMs: snapshot.LastMs, lastSeq: snapshot.LastSeq}}
+	if expiration > 0 {
+		stored.expiration = time.Now().Add(expiration)
+	}
+
+	s.kv.put(key, stored)
+}
@@ -1,18 +1,114 @@
 package store
 
 import (
+	"errors"
+	"strconv"
 	"sync"
 	"time"
 )
 
+var ErrWrongType = errors.New("WRONGTYPE Operation against a key holding the wrong kind of value")
+
 type storedValue struct {
 	value      []byte
+	integer    int64       // the value of a string kept as an integer by INCR and friends, when intEncoded is set
+	intEncoded bool        // the string is held in integer rather than value
+	inPlace    bool        // value was allocated by SETBIT or BITFIELD and never handed out, so they may modify it in place
+	object     interface{} // holds values that are not plain strings, like *rateMeter, nil for strings
 	expiration time.Time
 }
 
+/*
+ 	* bytes returns the string the value holds, formatting it when it is kept as an integer
+	* @return []byte - the string
+*/
+func (sv storedValue) bytes() []byte {
+	if sv.intEncoded {
+		return strconv.AppendInt(nil, sv.integer, 10)
+	}
+	return sv.value
+}
+
+/*
+ 	* setBytes replaces the string the value holds, dropping the integer encoding
+	* @param value []byte - the new string
+*/
+func (sv *storedValue) setBytes(value []byte) {
+	sv.value = value
+	sv.integer = 0
+	sv.intEncoded = false
+	sv.inPlace = false
+}
+
+/*
+ 	* typeName returns the name TYPE reports for the value
+	* @return string - the type name
+*/
+func (sv storedValue) typeName() string {
+	switch sv.object.(type) {
+	case *rateMeter:
+		return TypeRateMeter
+	case *quicklist:
+		return TypeList
+	case *hash:
+		return TypeHash
+	case *set:
+		return TypeSet
+	case *zset:
+		return TypeZSet
+	case *idGenerator:
+		return TypeIDGen
+	}
+	return TypeString
+}
+
+/*
+ 	* clone returns a deep copy of the value, so the copy can be modified without touching the original
+	* @return storedValue - the copy
+*/
+func (sv storedValue) clone() storedValue {
+	copied := sv
+	if sv.value != nil {
+		copied.value = append([]byte{}, sv.value...)
+	}
+
+	switch object := sv.object.(type) {
+	case *rateMeter:
+		meter := *object
+		copied.object = &meter
+	case *quicklist:
+		copied.object = object.clone()
+	case *hash:
+		copied.object = object.clone()
+	case *set:
+		copied.object = object.clone()
+	case *zset:
+		copied.object = object.clone()
+	case *idGenerator:
+		generator := *object
+		copied.object = &generator
+	}
+
+	return copied
+}
+
+/*
+ 	* isExpired checks if the value has an expiration that has passed
+	* @param now time.Time - the time to check against
+	* @return bool - true if the value is expired, false otherwise
+*/
+func (sv storedValue) isExpired(now time.Time) bool {
+	return !sv.expiration.IsZero() && now.After(sv.expiration)
+}
+
 type keyValueStore struct {
-	mu    sync.RWMutex
-	store map[string]storedValue
+	mu       sync.RWMutex
+	store    map[string]storedValue
+	expires  map[string]struct{} // keys that have an expiration, sampled by the active expire cycle
+	onExpire func(key string)    // called with the lock held whenever an expired key is deleted
+
+	hashFieldExpires map[string]struct{} // hashes with fields that have an expiration, sampled by the active expire cycle
+	scanOrder        *scanIndex          // every key, in SCAN order
 }
 
 var storeInstance *keyValueStore
@@ -23,7 +119,10 @@ var storeInstance *keyValueStore
 */
 func newKeyValueStore() *keyValueStore {
 	return &keyValueStore{
-		store: make(map[string]storedValue),
+		store:            make(map[string]storedValue),
+		expires:          make(map[string]struct{}),
+		hashFieldExpires: make(map[string]struct{}),
+		scanOrder:        newScanIndex(),
 	}
 }
 
@@ -56,7 +155,7 @@ func (kv *keyValueStore) set(key string, value []byte, expiration time.Duration)
 		storedValue.expiration = time.Now().Add(expiration)
 	}
 
-	kv.store[key] = storedValue
+	kv.put(key, storedValue)
 }
 
 /*
@@ -64,23 +163,64 @@ func (kv *keyValueStore) set(key string, value []byte, expiration time.Duration)
 	* @param key string - the key to get the value from
 	* @return []byte - the value of the key
 	* @return bool - true if the key exists, false otherwise
+	* @return error - ErrWrongType if the key holds something other than a string
 */
-func (kv *keyValueStore) get(key string) ([]byte, bool) {
-	kv.mu.RLock()
-	defer kv.mu.RUnlock()
-
-	storedValue, exists := kv.store[key]
+func (kv *keyValueStore) get(key string) ([]byte, bool, error) {
+	storedValue, exists := kv.lookup(key)
 	if !exists {
-		return nil, false
+		return nil, false, nil
 	}
 
-	// check whether expiration was provided and the time has passed more than the expiration, if yes return nil.
-	// should we delete the key from the store???
-	if !storedValue.expiration.IsZero() && time.Now().After(storedValue.expiration) {
-		return nil, false
+	if storedValue.object != nil {
+		return nil, true, ErrWrongType
+	}
+
+	// a string SETBIT may still modify must be taken from it under the write lock
+	if storedValue.inPlace {
+		kv.mu.Lock()
+		defer kv.mu.Unlock()
+
+		storedValue, exists = kv.lookupLocked(key, time.Now())
+		if !exists {
+			return nil, false, nil
+		}
+		if storedValue.object != nil {
+			return nil, true, ErrWrongType
+		}
+		return kv.shareLocked(key, &storedValue), true, nil
+	}
+
+	return storedValue.bytes(), true, nil
+}
+
+/*
+ 	* shareLocked returns the string the value at key holds for a reply, which is written once the lock is released,
+	* so SETBIT and BITFIELD must copy it from now on rather than modify it in place. the write lock must be held
+	* @param key string - the key
+	* @param stored *storedValue - the value at key, updated too
+	* @return []byte - the string
+*/
+func (kv *keyValueStore) shareLocked(key string, stored *storedValue) []byte {
+	if stored.inPlace {
+		stored.inPlace = false
+		kv.store[key] = *stored
+	}
+	return stored.bytes()
+}
+
+/*
+ 	* typeOf returns the type name of the value stored at key
+	* @param key string - the key to check
+	* @return string - the type name
+	* @return bool - true if the key exists, false otherwise
+*/
+func (kv *keyValueStore) typeOf(key string) (string, bool) {
+	storedValue, exists := kv.lookup(key)
+	if !exists {
+		return "", false
 	}
 
-	return storedValue.value, true
+	return storedValue.typeName(), true
 }
 
 /*
@@ -90,18 +230,121 @@ func (kv *keyValueStore) get(key string) ([]byte, bool) {
 */
 func (kv *keyValueStore) getKeys(pattern string) []string {
 	kv.mu.RLock()
-	defer kv.mu.RUnlock()
 
+	now := time.Now()
 	var keys []string
+	var expired []string
 	for key, value := range kv.store {
 
-		if !value.expiration.IsZero() && time.Now().After(value.expiration) {
+		if value.isExpired(now) {
+			expired = append(expired, key)
 			continue
 		}
-		// For now we only support "*" pattern which matches all keys
-		if pattern == "*" {
+		if MatchGlob(pattern, key) {
 			keys = append(keys, key)
 		}
 	}
+	kv.mu.RUnlock()
+
+	// we ran into these anyway, so free them now instead of waiting for the active expire cycle
+	for _, key := range expired {
+		kv.expireIfNeeded(key)
+	}
+
 	return keys
 }
+
+/*
+ 	* lookup returns the value at key, an expired key is deleted on the spot and reported as missing
+	* @param key string - the key to look up
+	* @return storedValue - the value
+	* @return bool - true if the key exists, false otherwise
+*/
+func (kv *keyValueStore) lookup(key string) (storedValue, bool) {
+	kv.mu.RLock()
+	storedValue, exists := kv.store[key]
+	kv.mu.RUnlock()
+
+	if !exists {
+		return storedValue, false
+	}
+
+	if storedValue.isExpired(time.Now()) {
+		kv.expireIfNeeded(key)
+		return storedValue, false
+	}
+
+	return storedValue, true
+}
+
+/*
+ 	* lookupLocked is lookup for callers that already hold the write lock
+	* @param key string - the key to look up
+	* @param now time.Time - the current time
+	* @return storedValue - the value
+	* @return bool - true if the key exists, false otherwise
+*/
+func (kv *keyValueStore) lookupLocked(key string, now time.Time) (storedValue, bool) {
+	storedValue, exists := kv.store[key]
+	if !exists {
+		return storedValue, false
+	}
+
+	if storedValue.isExpired(now) {
+		kv.removeExpired(key)
+		return storedValue, false
+	}
+
+	return storedValue, true
+}
+
+/*
+ 	* expireIfNeeded deletes the key if it has expired, checking again under the write lock
+	* because another client may have replaced the key since it was seen expired
+	* @param key string - the key to check
+*/
+func (kv *keyValueStore) expireIfNeeded(key string) {
+	kv.mu.Lock()
+	defer kv.mu.Unlock()
+
+	kv.lookupLocked(key, time.Now())
+}
+
+// put stores the value and keeps the expires and scan indexes in sync, the write lock must be held
+func (kv *keyValueStore) put(key string, storedValue storedValue) {
+	if _, exists := kv.store[key]; !exists {
+		kv.scanOrder.insert(key)
+	}
+	kv.store[key] = storedValue
+
+	if storedValue.expiration.IsZero() {
+		delete(kv.expires, key)
+	} else {
+		kv.expires[key] = struct{}{}
+	}
+
+	if h, ok := storedValue.object.(*hash); ok && h.expires != nil {
+		kv.hashFieldExpires[key] = struct{}{}
+	} else {
+		delete(kv.hashFieldExpires, key)
+	}
+}
+
+// remove deletes the key and its entries in the expires and scan indexes, the write lock must be held
+func (kv *keyValueStore) remove(key string) {
+	if _, exists := kv.store[key]; exists {
+		kv.scanOrder.delete(key)
+	}
+	delete(kv.store, key)
+	delete(kv.expires, key)
+	delete(kv.hashFieldExpires, key)
+}
+
+// removeExpired deletes an expired key and lets watchers know, the write lock must be held
+func (kv *keyValueStore) removeExpired(key string) {
+	kv.remove(key)
+
+	if kv.onExpire != nil {
+		kv.onExpire(key)
+	}
+}
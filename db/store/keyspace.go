@@ -0,0 +1,285 @@
+package store
+
+import (
+	"errors"
+	"time"
+)
+
+var ErrNoSuchKey = errors.New("ERR no such key")
+
+// values that take more than this much work to free are freed in the background by UNLINK, same as Redis' LAZYFREE_THRESHOLD
+const lazyfreeThreshold = 64
+
+// the commands here work across both keyspaces, so they take both locks, always the key-value one first
+
+func (s *Store) lockKeyspace() {
+	s.kv.mu.Lock()
+	s.streams.mu.Lock()
+}
+
+func (s *Store) unlockKeyspace() {
+	s.streams.mu.Unlock()
+	s.kv.mu.Unlock()
+}
+
+/*
+ 	* existsLocked checks if the key exists in either keyspace, both locks must be held
+	* @param key string - the key to check
+	* @param now time.Time - the current time
+	* @return bool - true if the key exists, false otherwise
+*/
+func (s *Store) existsLocked(key string, now time.Time) bool {
+	if _, exists := s.kv.lookupLocked(key, now); exists {
+		return true
+	}
+	_, exists := s.streams.getStreamLocked(key, now)
+	return exists
+}
+
+/*
+ 	* removeLocked deletes the key from both keyspaces, both locks must be held
+	* @param key string - the key to delete
+	* @param now time.Time - the current time
+	* @return []interface{} - the values that were deleted, a storedValue or a *stream
+*/
+func (s *Store) removeLocked(key string, now time.Time) []interface{} {
+	var removed []interface{}
+
+	if stored, exists := s.kv.lookupLocked(key, now); exists {
+		s.kv.remove(key)
+		removed = append(removed, stored)
+	}
+	if stream, exists := s.streams.getStreamLocked(key, now); exists {
+		s.streams.remove(key)
+		removed = append(removed, stream)
+	}
+
+	return removed
+}
+
+/*
+ 	* overwriteLocked deletes whatever is at key in either keyspace so a computed value can take its place,
+	* like the destination of SINTERSTORE. only the key-value write lock must be held, the stream one is taken here
+	* @param key string - the key to clear
+*/
+func (s *Store) overwriteLocked(key string) {
+	s.kv.remove(key)
+
+	s.streams.mu.Lock()
+	s.streams.remove(key)
+	s.streams.mu.Unlock()
+}
+
+/*
+ 	* Del deletes the keys
+	* @param keys []string - the keys to delete
+	* @return int - the number of keys that were deleted
+*/
+func (s *Store) Del(keys []string) int {
+	s.lockKeyspace()
+	defer s.unlockKeyspace()
+
+	now := time.Now()
+	deleted := 0
+	for _, key := range keys {
+		if len(s.removeLocked(key, now)) > 0 {
+			deleted++
+		}
+	}
+
+	return deleted
+}
+
+/*
+ 	* Unlink deletes the keys like Del, but large values are freed in the background
+	* so deleting a huge stream doesn't hold the locks while it is torn down
+	* @param keys []string - the keys to delete
+	* @return int - the number of keys that were deleted
+*/
+func (s *Store) Unlink(keys []string) int {
+	s.lockKeyspace()
+
+	now := time.Now()
+	deleted := 0
+	var lazyfree []interface{}
+	for _, key := range keys {
+		removed := s.removeLocked(key, now)
+		if len(removed) > 0 {
+			deleted++
+		}
+
+		for _, value := range removed {
+			if freeEffort(value) > lazyfreeThreshold {
+				lazyfree = append(lazyfree, value)
+			}
+		}
+	}
+
+	s.unlockKeyspace()
+
+	if len(lazyfree) > 0 {
+		go freeValues(lazyfree)
+	}
+
+	return deleted
+}
+
+/*
+ 	* freeEffort estimates how much work it takes to free a value, roughly the number of allocations it holds
+	* @param value interface{} - a storedValue or a *stream
+	* @return int - the effort
+*/
+func freeEffort(value interface{}) int {
+	switch value := value.(type) {
+	case *stream:
+		value.mu.RLock()
+		defer value.mu.RUnlock()
+		return value.entries.length
+	case storedValue:
+		switch object := value.object.(type) {
+		case *quicklist:
+			return object.nodes
+		case *hash:
+			// a compact hash is a single allocation
+			if object.dict != nil {
+				return len(object.dict)
+			}
+		case *set:
+			// so is an intset
+			if object.dict != nil {
+				return len(object.dict)
+			}
+		case *zset:
+			return object.len()
+		}
+	}
+	return 1
+}
+
+/*
+ 	* freeValues drops everything the values hold on to, so the garbage collector can take it piece by piece
+	* @param values []interface{} - the values to free, no longer reachable from the keyspace
+*/
+func freeValues(values []interface{}) {
+	for _, value := range values {
+		switch value := value.(type) {
+		case *stream:
+			// blocked readers may still hold the stream, so take its lock
+			value.mu.Lock()
+			value.entries = newStreamIndex()
+			clear(value.groups)
+			value.mu.Unlock()
+		case storedValue:
+			switch object := value.object.(type) {
+			case *quicklist:
+				for node := object.head; node != nil; node = node.next {
+					clear(node.entries)
+				}
+			case *hash:
+				clear(object.dict)
+				clear(object.expires)
+			case *set:
+				clear(object.dict)
+			case *zset:
+				clear(object.dict)
+			}
+		}
+	}
+}
+
+/*
+ 	* Exists counts how many of the keys exist, a key given more than once is counted more than once
+	* @param keys []string - the keys to check
+	* @return int - the number of keys that exist
+*/
+func (s *Store) Exists(keys []string) int {
+	count := 0
+	for _, key := range keys {
+		if _, exists := s.kv.lookup(key); exists {
+			count++
+		} else if s.streams.isStreamKey(key) {
+			count++
+		}
+	}
+
+	return count
+}
+
+/*
+ 	* Rename moves the value at src, with its expiration, to dst, overwriting whatever dst held
+	* @param src string - the key to rename
+	* @param dst string - the new name
+	* @param nx bool - only rename if dst doesn't exist
+	* @return bool - true if the key was renamed, false if nx was set and dst exists
+	* @return error - ErrNoSuchKey if src doesn't exist
+*/
+func (s *Store) Rename(src, dst string, nx bool) (bool, error) {
+	s.lockKeyspace()
+	defer s.unlockKeyspace()
+
+	now := time.Now()
+	stored, inKeyValue := s.kv.lookupLocked(src, now)
+	stream, inStreams := s.streams.getStreamLocked(src, now)
+	if !inKeyValue && !inStreams {
+		return false, ErrNoSuchKey
+	}
+
+	if src == dst {
+		return !nx, nil
+	}
+
+	if nx && s.existsLocked(dst, now) {
+		return false, nil
+	}
+
+	s.removeLocked(dst, now)
+
+	if inKeyValue {
+		s.kv.remove(src)
+		s.kv.put(dst, stored)
+	}
+	if inStreams {
+		s.streams.remove(src)
+		s.streams.put(dst, stream)
+		s.streams.setExpiration(dst, stream, stream.expiration)
+	}
+
+	return true, nil
+}
+
+/*
+ 	* Copy copies the value at src, with its expiration, to dst
+	* @param src string - the key to copy
+	* @param dst string - the key to copy to
+	* @param replace bool - overwrite dst if it exists
+	* @return bool - true if the key was copied, false if src doesn't exist or dst exists and replace is not set
+*/
+func (s *Store) Copy(src, dst string, replace bool) bool {
+	s.lockKeyspace()
+	defer s.unlockKeyspace()
+
+	now := time.Now()
+	stored, inKeyValue := s.kv.lookupLocked(src, now)
+	stream, inStreams := s.streams.getStreamLocked(src, now)
+	if !inKeyValue && !inStreams {
+		return false
+	}
+
+	if s.existsLocked(dst, now) {
+		if !replace {
+			return false
+		}
+		s.removeLocked(dst, now)
+	}
+
+	if inKeyValue {
+		s.kv.put(dst, stored.clone())
+	}
+	if inStreams {
+		copied := stream.clone()
+		s.streams.put(dst, copied)
+		s.streams.setExpiration(dst, copied, stream.expiration)
+	}
+
+	return true
+}
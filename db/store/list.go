@@ -0,0 +1,292 @@
+package store
+
+import (
+	"errors"
+	"time"
+)
+
+var ErrIndexOutOfRange = errors.New("ERR index out of range")
+
+/*
+ 	* listLocked returns the list at key, the key-value write lock must be held
+	* @param key string - the key of the list
+	* @param now time.Time - the current time
+	* @param create bool - create an empty list if the key doesn't exist, it is only stored once an element is added
+	* @return *quicklist - the list, nil if the key doesn't exist and create is false
+	* @return storedValue - the value holding the list
+	* @return error - ErrWrongType if the key holds something other than a list
+*/
+func (s *Store) listLocked(key string, now time.Time, create bool) (*quicklist, storedValue, error) {
+	stored, exists := s.kv.lookupLocked(key, now)
+	if !exists {
+		if s.streams.isStreamKey(key) {
+			return nil, stored, ErrWrongType
+		}
+		if !create {
+			return nil, stored, nil
+		}
+		stored = storedValue{object: newQuicklist()}
+	}
+
+	list, ok := stored.object.(*quicklist)
+	if !ok {
+		return nil, stored, ErrWrongType
+	}
+
+	return list, stored, nil
+}
+
+/*
+ 	* storeListLocked stores the list back, or deletes the key once the list is empty, like Redis does
+	* @param key string - the key of the list
+	* @param list *quicklist - the list
+	* @param stored storedValue - the value holding the list
+*/
+func (s *Store) storeListLocked(key string, list *quicklist, stored storedValue) {
+	if list.len() == 0 {
+		s.kv.remove(key)
+		return
+	}
+	s.kv.put(key, stored)
+}
+
+/*
+ 	* ListPush adds the values at the head or the tail of the list, creating it if it doesn't exist.
+	* pushing at the head one value at a time means the last value ends up first
+	* @param key string - the key of the list
+	* @param values [][]byte - the values to add
+	* @param head bool - true for LPUSH, false for RPUSH
+	* @return int - the length of the list after the push
+	* @return error - ErrWrongType if the key holds something other than a list
+*/
+func (s *Store) ListPush(key string, values [][]byte, head bool) (int, error) {
+	s.kv.mu.Lock()
+	defer s.kv.mu.Unlock()
+
+	list, stored, err := s.listLocked(key, time.Now(), true)
+	if err != nil {
+		return 0, err
+	}
+
+	for _, value := range values {
+		if head {
+			list.pushHead(value)
+		} else {
+			list.pushTail(value)
+		}
+	}
+
+	s.storeListLocked(key, list, stored)
+	return list.len(), nil
+}
+
+/*
+ 	* ListPop removes up to count values from the head or the tail of the list
+	* @param key string - the key of the list
+	* @param count int - the most values to remove
+	* @param head bool - true for LPOP, false for RPOP
+	* @return [][]byte - the values, in the order they were removed
+	* @return bool - true if the key exists, false otherwise
+	* @return error - ErrWrongType if the key holds something other than a list
+*/
+func (s *Store) ListPop(key string, count int, head bool) ([][]byte, bool, error) {
+	s.kv.mu.Lock()
+	defer s.kv.mu.Unlock()
+
+	list, stored, err := s.listLocked(key, time.Now(), false)
+	if err != nil || list == nil {
+		return nil, false, err
+	}
+
+	values := make([][]byte, 0, min(count, list.len()))
+	for len(values) < count {
+		var value []byte
+		var ok bool
+		if head {
+			value, ok = list.popHead()
+		} else {
+			value, ok = list.popTail()
+		}
+		if !ok {
+			break
+		}
+		values = append(values, value)
+	}
+
+	s.storeListLocked(key, list, stored)
+	return values, true, nil
+}
+
+/*
+ 	* ListRange returns the values from start to stop, both inclusive, negative indexes count from the tail
+	* @param key string - the key of the list
+	* @param start int - the first index
+	* @param stop int - the last index
+	* @return [][]byte - the values, empty if the key doesn't exist
+	* @return error - ErrWrongType if the key holds something other than a list
+*/
+func (s *Store) ListRange(key string, start, stop int) ([][]byte, error) {
+	s.kv.mu.Lock()
+	defer s.kv.mu.Unlock()
+
+	list, _, err := s.listLocked(key, time.Now(), false)
+	if err != nil || list == nil {
+		return [][]byte{}, err
+	}
+
+	return list.rangeOf(start, stop), nil
+}
+
+/*
+ 	* ListLen returns the length of the list
+	* @param key string - the key of the list
+	* @return int - the length, 0 if the key doesn't exist
+	* @return error - ErrWrongType if the key holds something other than a list
+*/
+func (s *Store) ListLen(key string) (int, error) {
+	s.kv.mu.Lock()
+	defer s.kv.mu.Unlock()
+
+	list, _, err := s.listLocked(key, time.Now(), false)
+	if err != nil || list == nil {
+		return 0, err
+	}
+
+	return list.len(), nil
+}
+
+/*
+ 	* ListIndex returns the value at index, negative indexes count from the tail
+	* @param key string - the key of the list
+	* @param index int - the index
+	* @return []byte - the value
+	* @return bool - false if the key doesn't exist or the index is out of range
+	* @return error - ErrWrongType if the key holds something other than a list
+*/
+func (s *Store) ListIndex(key string, index int) ([]byte, bool, error) {
+	s.kv.mu.Lock()
+	defer s.kv.mu.Unlock()
+
+	list, _, err := s.listLocked(key, time.Now(), false)
+	if err != nil || list == nil {
+		return nil, false, err
+	}
+
+	value, ok := list.index(index)
+	return value, ok, nil
+}
+
+/*
+ 	* ListSet replaces the value at index, negative indexes count from the tail
+	* @param key string - the key of the list
+	* @param index int - the index
+	* @param value []byte - the new value
+	* @return error - ErrNoSuchKey, ErrIndexOutOfRange or ErrWrongType
+*/
+func (s *Store) ListSet(key string, index int, value []byte) error {
+	s.kv.mu.Lock()
+	defer s.kv.mu.Unlock()
+
+	list, _, err := s.listLocked(key, time.Now(), false)
+	if err != nil {
+		return err
+	}
+	if list == nil {
+		return ErrNoSuchKey
+	}
+
+	if !list.set(index, value) {
+		return ErrIndexOutOfRange
+	}
+	return nil
+}
+
+/*
+ 	* ListRem removes values equal to value
+	* @param key string - the key of the list
+	* @param count int - more than 0 removes that many from the head, less than 0 from the tail, 0 removes all
+	* @param value []byte - the value to remove
+	* @return int - the number of values removed
+	* @return error - ErrWrongType if the key holds something other than a list
+*/
+func (s *Store) ListRem(key string, count int, value []byte) (int, error) {
+	s.kv.mu.Lock()
+	defer s.kv.mu.Unlock()
+
+	list, stored, err := s.listLocked(key, time.Now(), false)
+	if err != nil || list == nil {
+		return 0, err
+	}
+
+	removed := list.remove(count, value)
+	s.storeListLocked(key, list, stored)
+	return removed, nil
+}
+
+/*
+ 	* ListTrim keeps only the values from start to stop, both inclusive, negative indexes count from the tail
+	* @param key string - the key of the list
+	* @param start int - the first index to keep
+	* @param stop int - the last index to keep
+	* @return error - ErrWrongType if the key holds something other than a list
+*/
+func (s *Store) ListTrim(key string, start, stop int) error {
+	s.kv.mu.Lock()
+	defer s.kv.mu.Unlock()
+
+	list, stored, err := s.listLocked(key, time.Now(), false)
+	if err != nil || list == nil {
+		return err
+	}
+
+	list.trim(start, stop)
+	s.storeListLocked(key, list, stored)
+	return nil
+}
+
+/*
+ 	* ListInsert adds value right before or after the first value equal to pivot
+	* @param key string - the key of the list
+	* @param pivot []byte - the value to insert next to
+	* @param value []byte - the value to insert
+	* @param before bool - true to insert before the pivot, false to insert after it
+	* @return int - the length of the list after the insert, -1 if the pivot was not found, 0 if the key doesn't exist
+	* @return error - ErrWrongType if the key holds something other than a list
+*/
+func (s *Store) ListInsert(key string, pivot, value []byte, before bool) (int, error) {
+	s.kv.mu.Lock()
+	defer s.kv.mu.Unlock()
+
+	list, _, err := s.listLocked(key, time.Now(), false)
+	if err != nil || list == nil {
+		return 0, err
+	}
+
+	if !list.insert(pivot, value, before) {
+		return -1, nil
+	}
+	return list.len(), nil
+}
+
+/*
+ 	* RestoreList puts a list read from disk back at key
+	* @param key string - the key of the list
+	* @param values [][]byte - the values, head first
+	* @param expiration time.Duration - the time to live, 0 for none
+*/
+func (s *Store) RestoreList(key string, values [][]byte, expiration time.Duration) {
+	s.kv.mu.Lock()
+	defer s.kv.mu.Unlock()
+
+	list := newQuicklist()
+	for _, value := range values {
+		list.pushTail(value)
+	}
+
+	stored := storedValue{object: list}
+	if expiration > 0 {
+		stored.expiration = time.Now().Add(expiration)
+	}
+
+	s.storeListLocked(key, list, stored)
+}
@@ -0,0 +1,422 @@
+package store
+
+// like Redis, a list is a doubly linked list of nodes that each hold a small array of elements,
+// so walking and indexing touch a node per quicklistNodeMaxEntries elements instead of a node per element
+const quicklistNodeMaxEntries = 128
+
+type quicklistNode struct {
+	prev    *quicklistNode
+	next    *quicklistNode
+	entries [][]byte
+}
+
+type quicklist struct {
+	head  *quicklistNode
+	tail  *quicklistNode
+	count int // elements across all the nodes
+	nodes int
+}
+
+func newQuicklist() *quicklist {
+	return &quicklist{}
+}
+
+func (ql *quicklist) len() int {
+	return ql.count
+}
+
+/*
+ 	* pushHead adds an element at the head of the list
+	* @param value []byte - the element
+*/
+func (ql *quicklist) pushHead(value []byte) {
+	if ql.head == nil || len(ql.head.entries) >= quicklistNodeMaxEntries {
+		ql.linkBefore(ql.head, &quicklistNode{})
+	}
+	ql.head.entries = append(ql.head.entries, nil)
+	copy(ql.head.entries[1:], ql.head.entries)
+	ql.head.entries[0] = value
+	ql.count++
+}
+
+/*
+ 	* pushTail adds an element at the tail of the list
+	* @param value []byte - the element
+*/
+func (ql *quicklist) pushTail(value []byte) {
+	if ql.tail == nil || len(ql.tail.entries) >= quicklistNodeMaxEntries {
+		ql.linkAfter(ql.tail, &quicklistNode{})
+	}
+	ql.tail.entries = append(ql.tail.entries, value)
+	ql.count++
+}
+
+/*
+ 	* popHead removes the element at the head of the list
+	* @return []byte - the element
+	* @return bool - false if the list is empty
+*/
+func (ql *quicklist) popHead() ([]byte, bool) {
+	if ql.head == nil {
+		return nil, false
+	}
+	value := ql.head.entries[0]
+	ql.removeAt(ql.head, 0)
+	return value, true
+}
+
+/*
+ 	* popTail removes the element at the tail of the list
+	* @return []byte - the element
+	* @return bool - false if the list is empty
+*/
+func (ql *quicklist) popTail() ([]byte, bool) {
+	if ql.tail == nil {
+		return nil, false
+	}
+	last := len(ql.tail.entries) - 1
+	value := ql.tail.entries[last]
+	ql.removeAt(ql.tail, last)
+	return value, true
+}
+
+/*
+ 	* locate finds the node and the offset in it of the element at index, walking from the closer end
+	* @param index int - the index, from 0 to len()-1
+	* @return *quicklistNode - the node
+	* @return int - the offset of the element in the node
+*/
+func (ql *quicklist) locate(index int) (*quicklistNode, int) {
+	if index < ql.count/2 {
+		for node := ql.head; node != nil; node = node.next {
+			if index < len(node.entries) {
+				return node, index
+			}
+			index -= len(node.entries)
+		}
+		return nil, 0
+	}
+
+	index = ql.count - 1 - index // from the tail
+	for node := ql.tail; node != nil; node = node.prev {
+		if index < len(node.entries) {
+			return node, len(node.entries) - 1 - index
+		}
+		index -= len(node.entries)
+	}
+	return nil, 0
+}
+
+/*
+ 	* normalizeIndex turns a negative index, counting from the tail, into one counting from the head
+	* @param index int - the index, -1 is the last element
+	* @return int - the index from the head
+	* @return bool - false if the index is out of range
+*/
+func (ql *quicklist) normalizeIndex(index int) (int, bool) {
+	if index < 0 {
+		index += ql.count
+	}
+	return index, index >= 0 && index < ql.count
+}
+
+/*
+ 	* index returns the element at index
+	* @param index int - the index, negative counts from the tail
+	* @return []byte - the element
+	* @return bool - false if the index is out of range
+*/
+func (ql *quicklist) index(index int) ([]byte, bool) {
+	index, ok := ql.normalizeIndex(index)
+	if !ok {
+		return nil, false
+	}
+	node, offset := ql.locate(index)
+	return node.entries[offset], true
+}
+
+/*
+ 	* set replaces the element at index
+	* @param index int - the index, negative counts from the tail
+	* @param value []byte - the new element
+	* @return bool - false if the index is out of range
+*/
+func (ql *quicklist) set(index int, value []byte) bool {
+	index, ok := ql.normalizeIndex(index)
+	if !ok {
+		return false
+	}
+	node, offset := ql.locate(index)
+	node.entries[offset] = value
+	return true
+}
+
+/*
+ 	* rangeOf returns the elements from start to stop, both inclusive, with the LRANGE rules for out of range indexes
+	* @param start int - the first index, negative counts from the tail
+	* @param stop int - the last index, negative counts from the tail
+	* @return [][]byte - the elements
+*/
+func (ql *quicklist) rangeOf(start, stop int) [][]byte {
+	if start < 0 {
+		start += ql.count
+	}
+	if stop < 0 {
+		stop += ql.count
+	}
+	if start < 0 {
+		start = 0
+	}
+	if start > stop || start >= ql.count {
+		return [][]byte{}
+	}
+	if stop >= ql.count {
+		stop = ql.count - 1
+	}
+
+	result := make([][]byte, 0, stop-start+1)
+	node, offset := ql.locate(start)
+	for remaining := stop - start + 1; remaining > 0; node, offset = node.next, 0 {
+		end := min(len(node.entries), offset+remaining)
+		result = append(result, node.entries[offset:end]...)
+		remaining -= end - offset
+	}
+
+	return result
+}
+
+/*
+ 	* values returns every element of the list, head first
+	* @return [][]byte - the elements
+*/
+func (ql *quicklist) values() [][]byte {
+	result := make([][]byte, 0, ql.count)
+	for node := ql.head; node != nil; node = node.next {
+		result = append(result, node.entries...)
+	}
+	return result
+}
+
+/*
+ 	* trim keeps only the elements from start to stop, both inclusive, with the LTRIM rules for out of range indexes
+	* @param start int - the first index to keep, negative counts from the tail
+	* @param stop int - the last index to keep, negative counts from the tail
+*/
+func (ql *quicklist) trim(start, stop int) {
+	if start < 0 {
+		start += ql.count
+	}
+	if stop < 0 {
+		stop += ql.count
+	}
+	if start < 0 {
+		start = 0
+	}
+
+	var fromHead, fromTail int
+	if start > stop || start >= ql.count {
+		fromHead = ql.count // nothing is kept
+	} else {
+		if stop >= ql.count {
+			stop = ql.count - 1
+		}
+		fromHead = start
+		fromTail = ql.count - 1 - stop
+	}
+
+	// whole nodes are dropped at once, only the nodes at the edges are cut
+	for fromHead > 0 {
+		node := ql.head
+		if len(node.entries) <= fromHead {
+			fromHead -= len(node.entries)
+			ql.count -= len(node.entries)
+			ql.unlink(node)
+			continue
+		}
+		node.entries = append(node.entries[:0:0], node.entries[fromHead:]...)
+		ql.count -= fromHead
+		fromHead = 0
+	}
+	for fromTail > 0 {
+		node := ql.tail
+		if len(node.entries) <= fromTail {
+			fromTail -= len(node.entries)
+			ql.count -= len(node.entries)
+			ql.unlink(node)
+			continue
+		}
+		node.entries = node.entries[:len(node.entries)-fromTail]
+		ql.count -= fromTail
+		fromTail = 0
+	}
+}
+
+/*
+ 	* remove deletes elements equal to value, the way LREM does
+	* @param count int - more than 0 removes that many from the head, less than 0 from the tail, 0 removes all
+	* @param value []byte - the element to remove
+	* @return int - the number of elements removed
+*/
+func (ql *quicklist) remove(count int, value []byte) int {
+	removed := 0
+
+	if count >= 0 {
+		for node := ql.head; node != nil; {
+			next := node.next
+			for i := 0; i < len(node.entries); {
+				if string(node.entries[i]) != string(value) {
+					i++
+					continue
+				}
+				ql.removeAt(node, i)
+				removed++
+				if removed == count {
+					return removed
+				}
+			}
+			node = next
+		}
+		return removed
+	}
+
+	for node := ql.tail; node != nil; {
+		prev := node.prev
+		for i := len(node.entries) - 1; i >= 0; i-- {
+			if string(node.entries[i]) != string(value) {
+				continue
+			}
+			ql.removeAt(node, i)
+			removed++
+			if removed == -count {
+				return removed
+			}
+		}
+		node = prev
+	}
+	return removed
+}
+
+/*
+ 	* insert adds value right before or after the first element equal to pivot
+	* @param pivot []byte - the element to insert next to
+	* @param value []byte - the element to insert
+	* @param before bool - true to insert before the pivot, false to insert after it
+	* @return bool - false if the pivot was not found
+*/
+func (ql *quicklist) insert(pivot, value []byte, before bool) bool {
+	for node := ql.head; node != nil; node = node.next {
+		for i, entry := range node.entries {
+			if string(entry) != string(pivot) {
+				continue
+			}
+			if !before {
+				i++
+			}
+			ql.insertAt(node, i, value)
+			return true
+		}
+	}
+	return false
+}
+
+/*
+ 	* insertAt inserts value at offset in node, splitting the node in two when it is full
+	* @param node *quicklistNode - the node
+	* @param offset int - the offset, from 0 to len(node.entries)
+	* @param value []byte - the element to insert
+*/
+func (ql *quicklist) insertAt(node *quicklistNode, offset int, value []byte) {
+	if len(node.entries) >= quicklistNodeMaxEntries {
+		half := len(node.entries) / 2
+		split := &quicklistNode{entries: append([][]byte{}, node.entries[half:]...)}
+		node.entries = node.entries[:half:half]
+		ql.linkAfter(node, split)
+
+		if offset > half {
+			node, offset = split, offset-half
+		}
+	}
+
+	node.entries = append(node.entries, nil)
+	copy(node.entries[offset+1:], node.entries[offset:])
+	node.entries[offset] = value
+	ql.count++
+}
+
+/*
+ 	* removeAt deletes the element at offset in node, and the node itself once it is empty
+	* @param node *quicklistNode - the node
+	* @param offset int - the offset of the element
+*/
+func (ql *quicklist) removeAt(node *quicklistNode, offset int) {
+	copy(node.entries[offset:], node.entries[offset+1:])
+	node.entries[len(node.entries)-1] = nil
+	node.entries = node.entries[:len(node.entries)-1]
+	ql.count--
+
+	if len(node.entries) == 0 {
+		ql.unlink(node)
+	}
+}
+
+// linkBefore links node before at, or as the only node when the list is empty
+func (ql *quicklist) linkBefore(at, node *quicklistNode) {
+	ql.nodes++
+	if at == nil {
+		ql.head, ql.tail = node, node
+		return
+	}
+	node.next = at
+	node.prev = at.prev
+	if at.prev != nil {
+		at.prev.next = node
+	} else {
+		ql.head = node
+	}
+	at.prev = node
+}
+
+// linkAfter links node after at, or as the only node when the list is empty
+func (ql *quicklist) linkAfter(at, node *quicklistNode) {
+	ql.nodes++
+	if at == nil {
+		ql.head, ql.tail = node, node
+		return
+	}
+	node.prev = at
+	node.next = at.next
+	if at.next != nil {
+		at.next.prev = node
+	} else {
+		ql.tail = node
+	}
+	at.next = node
+}
+
+func (ql *quicklist) unlink(node *quicklistNode) {
+	ql.nodes--
+	if node.prev != nil {
+		node.prev.next = node.next
+	} else {
+		ql.head = node.next
+	}
+	if node.next != nil {
+		node.next.prev = node.prev
+	} else {
+		ql.tail = node.prev
+	}
+	node.prev, node.next = nil, nil
+}
+
+/*
+ 	* clone returns a copy of the list, the elements are shared as they are never modified in place
+	* @return *quicklist - the copy
+*/
+func (ql *quicklist) clone() *quicklist {
+	copied := newQuicklist()
+	for node := ql.head; node != nil; node = node.next {
+		copied.linkAfter(copied.tail, &quicklistNode{entries: append([][]byte{}, node.entries...)})
+	}
+	copied.count = ql.count
+	return copied
+}
@@ -0,0 +1,216 @@
+package store
+
+import (
+	"errors"
+	"math"
+	"time"
+)
+
+var ErrInvalidMarkCount = errors.New("ERR count must be a non-negative integer")
+
+const (
+	// the averages move in steps of rateTickInterval, the way the classic Unix load average does
+	rateTickInterval = 5 * time.Second
+)
+
+// the windows of the moving averages, 1, 5 and 15 minutes
+var rateWindows = [3]time.Duration{time.Minute, 5 * time.Minute, 15 * time.Minute}
+
+// rateMeter counts events and keeps exponentially weighted moving averages of their rate.
+// nothing runs in the background, the ticks that passed since the last access are applied when the meter is next touched
+type rateMeter struct {
+	count       int64      // events over the lifetime of the meter
+	uncounted   int64      // events since the last tick, not yet part of the averages
+	rates       [3]float64 // events per second over the 1, 5 and 15 minute windows
+	initialized bool       // false until the first tick, which seeds the averages with the instant rate
+	lastTick    time.Time
+	created     time.Time
+}
+
+// RateMeterSnapshot is the state of a rate meter, so it can be written to and restored from disk
+type RateMeterSnapshot struct {
+	Count       int64
+	Uncounted   int64
+	Rates       [3]float64
+	Initialized bool
+	LastTick    time.Time
+	Created     time.Time
+}
+
+// RateMeterReading is what RATE.GET reports
+type RateMeterReading struct {
+	Count    int64
+	MeanRate float64 // events per second since the meter was created
+	Rates    [3]float64
+}
+
+func newRateMeter(now time.Time) *rateMeter {
+	return &rateMeter{
+		lastTick: now,
+		created:  now,
+	}
+}
+
+/*
+ 	* tickIfNecessary applies every tick that elapsed since the last one.
+	* the first tick folds in the uncounted events, the others only decay the averages, which has a closed form,
+	* so a meter that sat idle for days catches up in constant time
+	* @param now time.Time - the current time
+*/
+func (m *rateMeter) tickIfNecessary(now time.Time) {
+	ticks := int64(now.Sub(m.lastTick) / rateTickInterval)
+	if ticks <= 0 {
+		return
+	}
+	m.lastTick = m.lastTick.Add(time.Duration(ticks) * rateTickInterval)
+
+	instantRate := float64(m.uncounted) / rateTickInterval.Seconds()
+	m.uncounted = 0
+
+	for i, window := range rateWindows {
+		// alpha is the weight of a new sample, decay is what is left of the old average after one tick
+		decay := math.Exp(-rateTickInterval.Seconds() / window.Seconds())
+		alpha := 1 - decay
+
+		if m.initialized {
+			m.rates[i] += alpha * (instantRate - m.rates[i])
+		} else {
+			m.rates[i] = instantRate
+		}
+
+		m.rates[i] *= math.Pow(decay, float64(ticks-1)) // the idle ticks, with an instant rate of 0
+	}
+	m.initialized = true
+}
+
+/*
+ 	* mark records n events
+	* @param n int64 - the number of events
+	* @param now time.Time - the current time
+*/
+func (m *rateMeter) mark(n int64, now time.Time) {
+	m.tickIfNecessary(now)
+	m.count += n
+	m.uncounted += n
+}
+
+/*
+ 	* reading returns the lifetime count and the rates
+	* @param now time.Time - the current time
+	* @return RateMeterReading - the reading
+*/
+func (m *rateMeter) reading(now time.Time) RateMeterReading {
+	m.tickIfNecessary(now)
+
+	reading := RateMeterReading{
+		Count: m.count,
+		Rates: m.rates,
+	}
+	if elapsed := now.Sub(m.created).Seconds(); elapsed > 0 {
+		reading.MeanRate = float64(m.count) / elapsed
+	}
+
+	return reading
+}
+
+func (m *rateMeter) snapshot() RateMeterSnapshot {
+	return RateMeterSnapshot{
+		Count:       m.count,
+		Uncounted:   m.uncounted,
+		Rates:       m.rates,
+		Initialized: m.initialized,
+		LastTick:    m.lastTick,
+		Created:     m.created,
+	}
+}
+
+/*
+ 	* RateMark records n events on the meter at key, creating the meter if it doesn't exist.
+	* the expiration of an existing meter is kept
+	* @param key string - the key of the meter
+	* @param n int64 - the number of events
+	* @param at time.Time - when they happened, the current time but for a mark replayed from the AOF
+	* @return int64 - the lifetime count after marking
+	* @return error - ErrWrongType if the key holds something other than a rate meter, a stream included
+*/
+func (s *Store) RateMark(key string, n int64, at time.Time) (int64, error) {
+	if n < 0 {
+		return 0, ErrInvalidMarkCount
+	}
+
+	// the key-value lock first, then the streams one to check the key isn't a stream, as lockKeyspace does
+	s.kv.mu.Lock()
+	defer s.kv.mu.Unlock()
+
+	now := time.Now()
+	stored, exists := s.kv.lookupLocked(key, now)
+	if !exists {
+		if s.streams.isStreamKey(key) {
+			return 0, ErrWrongType
+		}
+		stored = storedValue{object: newRateMeter(at)}
+	}
+
+	meter, ok := stored.object.(*rateMeter)
+	if !ok {
+		return 0, ErrWrongType
+	}
+
+	meter.mark(n, at)
+	s.kv.put(key, stored)
+
+	return meter.count, nil
+}
+
+/*
+ 	* rateGet reads the meter at key
+	* @param key string - the key of the meter
+	* @return RateMeterReading - the reading
+	* @return bool - true if the key exists, false otherwise
+	* @return error - ErrWrongType if the key holds something other than a rate meter
+*/
+func (kv *keyValueStore) rateGet(key string) (RateMeterReading, bool, error) {
+	// reading applies pending ticks, so it needs the write lock
+	kv.mu.Lock()
+	defer kv.mu.Unlock()
+
+	now := time.Now()
+	stored, exists := kv.lookupLocked(key, now)
+	if !exists {
+		return RateMeterReading{}, false, nil
+	}
+
+	meter, ok := stored.object.(*rateMeter)
+	if !ok {
+		return RateMeterReading{}, true, ErrWrongType
+	}
+
+	return meter.reading(now), true, nil
+}
+
+/*
+ 	* restoreRateMeter puts a meter read from disk back at key
+	* @param key string - the key of the meter
+	* @param snapshot RateMeterSnapshot - the state of the meter
+	* @param expiration time.Duration - the time to live, 0 for none
+*/
+func (kv *keyValueStore) restoreRateMeter(key string, snapshot RateMeterSnapshot, expiration time.Duration) {
+	kv.mu.Lock()
+	defer kv.mu.Unlock()
+
+	stored := storedValue{
+		object: &rateMeter{
+			count:       snapshot.Count,
+			uncounted:   snapshot.Uncounted,
+			rates:       snapshot.Rates,
+			initialized: snapshot.Initialized,
+			lastTick:    snapshot.LastTick,
+			created:     snapshot.Created,
+		},
+	}
+	if expiration > 0 {
+		stored.expiration = time.Now().Add(expiration)
+	}
+
+	kv.put(key, stored)
+}
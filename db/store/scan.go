@@ -0,0 +1,232 @@
+package store
+
+import (
+	"strings"
+	"time"
+)
+
+// Go maps have no stable iteration order, so SCAN can't walk hash table buckets like Redis does.
+// instead every key is placed by a 64 bit hash of its name, and the cursor is a position in that hash space:
+// each call returns the keys with the smallest hashes at or after the cursor, and the next cursor is just past the last one.
+// the keys are kept in an index ordered by that hash, so a call seeks to its cursor and only visits its batch.
+// a key keeps its hash for its whole life, so a key present for the whole iteration is returned exactly once,
+// however many keys are added or removed in between
+
+/*
+ 	* scanHash is the 64 bit FNV-1a hash of the key, the position of the key in the SCAN order
+	* @param key string - the key
+	* @return uint64 - the hash
+*/
+func scanHash(key string) uint64 {
+	hash := uint64(14695981039346656037)
+	for i := 0; i < len(key); i++ {
+		hash ^= uint64(key[i])
+		hash *= 1099511628211
+	}
+	return hash
+}
+
+// scanIndex keeps names ordered by scan hash, then by name for the rare names sharing a hash, so an iteration seeks
+// straight to its cursor and only visits the names of the batch. it is a skiplist like the one of a sorted set,
+// without spans as no rank is ever needed
+type scanIndex struct {
+	header *scanIndexNode // not a name, its levels point at the first node of each level
+	level  int            // the highest level of any node
+}
+
+type scanIndexNode struct {
+	hash    uint64
+	name    string
+	forward []*scanIndexNode
+}
+
+func newScanIndex() *scanIndex {
+	return &scanIndex{
+		header: &scanIndexNode{forward: make([]*scanIndexNode, skiplistMaxLevel)},
+		level:  1,
+	}
+}
+
+// before checks if the node comes before the name with this hash
+func (node *scanIndexNode) before(hash uint64, name string) bool {
+	return node.hash < hash || (node.hash == hash && node.name < name)
+}
+
+// next returns the node that follows in scan order, nil at the end
+func (node *scanIndexNode) next() *scanIndexNode {
+	return node.forward[0]
+}
+
+/*
+ 	* findUpdate finds, on every level, the last node before the name
+	* @param hash uint64 - the scan hash of the name
+	* @param name string - the name
+	* @return [skiplistMaxLevel]*scanIndexNode - the last node before the name on each level
+*/
+func (idx *scanIndex) findUpdate(hash uint64, name string) [skiplistMaxLevel]*scanIndexNode {
+	var update [skiplistMaxLevel]*scanIndexNode
+	node := idx.header
+	for i := idx.level - 1; i >= 0; i-- {
+		for node.forward[i] != nil && node.forward[i].before(hash, name) {
+			node = node.forward[i]
+		}
+		update[i] = node
+	}
+	return update
+}
+
+/*
+ 	* insert adds a name, nothing happens if it is there already
+	* @param name string - the name
+*/
+func (idx *scanIndex) insert(name string) {
+	hash := scanHash(name)
+	update := idx.findUpdate(hash, name)
+	if node := update[0].forward[0]; node != nil && node.hash == hash && node.name == name {
+		return
+	}
+
+	level := randomLevel()
+	for i := idx.level; i < level; i++ {
+		update[i] = idx.header
+	}
+	idx.level = max(idx.level, level)
+
+	node := &scanIndexNode{hash: hash, name: name, forward: make([]*scanIndexNode, level)}
+	for i := 0; i < level; i++ {
+		node.forward[i] = update[i].forward[i]
+		update[i].forward[i] = node
+	}
+}
+
+/*
+ 	* delete removes a name, nothing happens if it is not there
+	* @param name string - the name
+*/
+func (idx *scanIndex) delete(name string) {
+	hash := scanHash(name)
+	update := idx.findUpdate(hash, name)
+	node := update[0].forward[0]
+	if node == nil || node.hash != hash || node.name != name {
+		return
+	}
+
+	for i := 0; i < len(node.forward); i++ {
+		update[i].forward[i] = node.forward[i]
+	}
+	for idx.level > 1 && idx.header.forward[idx.level-1] == nil {
+		idx.level--
+	}
+}
+
+/*
+ 	* seek finds where an iteration resumes
+	* @param cursor uint64 - the cursor
+	* @return *scanIndexNode - the first node whose hash is at or after the cursor, nil if there is none
+*/
+func (idx *scanIndex) seek(cursor uint64) *scanIndexNode {
+	node := idx.header
+	for i := idx.level - 1; i >= 0; i-- {
+		for node.forward[i] != nil && node.forward[i].hash < cursor {
+			node = node.forward[i]
+		}
+	}
+	return node.forward[0]
+}
+
+/*
+ 	* scanBatch visits the batch of a SCAN-style iteration: count names, then every following name sharing the hash of the
+	* last one, so names sharing a hash are never split across batches
+	* @param count int - how many names to visit
+	* @param next func() *scanIndexNode - returns the names in scan order from the cursor, nil at the end
+	* @param visit func(name string) - called for every name of the batch
+	* @return uint64 - the cursor for the next call, 0 when the iteration is over
+*/
+func scanBatch(count int, next func() *scanIndexNode, visit func(name string)) uint64 {
+	var last uint64
+	visited := 0
+	for node := next(); node != nil; node = next() {
+		if visited >= count && node.hash != last {
+			return last + 1
+		}
+		visit(node.name)
+		last = node.hash
+		visited++
+	}
+	return 0
+}
+
+/*
+ 	* scan visits the batch of an iteration over the names of the index
+	* @param cursor uint64 - where the batch starts
+	* @param count int - how many names to visit
+	* @param visit func(name string) - called for every name of the batch
+	* @return uint64 - the cursor for the next call, 0 when the iteration is over
+*/
+func (idx *scanIndex) scan(cursor uint64, count int, visit func(name string)) uint64 {
+	node := idx.seek(cursor)
+	return scanBatch(count, func() *scanIndexNode {
+		current := node
+		if node != nil {
+			node = node.next()
+		}
+		return current
+	}, visit)
+}
+
+/*
+ 	* Scan returns the next batch of keys of a SCAN iteration.
+	* count is the number of keys looked at, expired keys included, pattern and typeName filter those afterwards,
+	* so a batch can come back smaller than count, even empty, before the iteration is over
+	* @param cursor uint64 - 0 to start, then the cursor returned by the previous call
+	* @param count int - how many keys to look at
+	* @param pattern string - the glob-style pattern the keys must match, "" for all
+	* @param typeName string - the type the keys must have, "" for any
+	* @return []string - the keys
+	* @return uint64 - the cursor for the next call, 0 when the iteration is over
+*/
+func (s *Store) Scan(cursor uint64, count int, pattern, typeName string) ([]string, uint64) {
+	s.kv.mu.RLock()
+	defer s.kv.mu.RUnlock()
+	s.streams.mu.RLock()
+	defer s.streams.mu.RUnlock()
+
+	now := time.Now()
+
+	// both keyspaces are walked together in scan order, a name in both of them is visited once
+	kvNode, streamNode := s.kv.scanOrder.seek(cursor), s.streams.scanOrder.seek(cursor)
+	next := func() *scanIndexNode {
+		var node *scanIndexNode
+		switch {
+		case kvNode == nil && streamNode == nil:
+			return nil
+		case streamNode == nil || (kvNode != nil && kvNode.before(streamNode.hash, streamNode.name)):
+			node, kvNode = kvNode, kvNode.next()
+		case kvNode == nil || streamNode.before(kvNode.hash, kvNode.name):
+			node, streamNode = streamNode, streamNode.next()
+		default:
+			node, kvNode, streamNode = kvNode, kvNode.next(), streamNode.next()
+		}
+		return node
+	}
+
+	keys := []string{}
+	nextCursor := scanBatch(count, next, func(key string) {
+		keyType := TypeStream
+		if value, exists := s.kv.store[key]; exists && !value.isExpired(now) {
+			keyType = value.typeName()
+		} else if stream, exists := s.streams.streams[key]; !exists || stream.isExpired(now) {
+			return
+		}
+
+		if pattern != "" && !MatchGlob(pattern, key) {
+			return
+		}
+		if typeName != "" && !strings.EqualFold(typeName, keyType) {
+			return
+		}
+		keys = append(keys, key)
+	})
+
+	return keys, nextCursor
+}
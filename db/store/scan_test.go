@@ -0,0 +1,93 @@
+package store
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestScanIndexIteration(t *testing.T) {
+	tests := []struct {
+		name    string
+		names   int
+		deleted int // the first names deleted before iterating
+		count   int
+	}{
+		{"empty", 0, 0, 10},
+		{"one batch", 5, 0, 10},
+		{"count of one", 50, 0, 1},
+		{"many batches", 1000, 0, 7},
+		{"after deletes", 1000, 400, 10},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			idx := newScanIndex()
+			for i := 0; i < test.names; i++ {
+				idx.insert(fmt.Sprintf("key:%d", i))
+				idx.insert(fmt.Sprintf("key:%d", i)) // a second insert is a no-op
+			}
+			for i := 0; i < test.deleted; i++ {
+				idx.delete(fmt.Sprintf("key:%d", i))
+			}
+			idx.delete("missing")
+
+			seen := make(map[string]int)
+			var cursor uint64
+			for calls := 0; ; calls++ {
+				if calls > test.names+1 {
+					t.Fatalf("the iteration did not end after %d calls", calls)
+				}
+
+				batch := 0
+				cursor = idx.scan(cursor, test.count, func(name string) {
+					seen[name]++
+					batch++
+				})
+				if batch > test.count && cursor != 0 {
+					t.Errorf("a batch visited %d names for a count of %d", batch, test.count)
+				}
+				if cursor == 0 {
+					break
+				}
+			}
+
+			if want := test.names - test.deleted; len(seen) != want {
+				t.Errorf("the iteration visited %d names, want %d", len(seen), want)
+			}
+			for name, times := range seen {
+				if times != 1 {
+					t.Errorf("%s was visited %d times", name, times)
+				}
+			}
+			for i := 0; i < test.deleted; i++ {
+				if _, exists := seen[fmt.Sprintf("key:%d", i)]; exists {
+					t.Errorf("key:%d was deleted but visited", i)
+				}
+			}
+		})
+	}
+}
+
+func TestScanIndexSeek(t *testing.T) {
+	idx := newScanIndex()
+	names := []string{"a", "b", "c", "d", "e"}
+	for _, name := range names {
+		idx.insert(name)
+	}
+
+	for _, name := range names {
+		node := idx.seek(scanHash(name))
+		if node == nil || node.name != name {
+			t.Errorf("seek(scanHash(%q)) did not land on %q", name, name)
+		}
+	}
+
+	// the nodes come out in hash order
+	var previous uint64
+	for node := idx.seek(0); node != nil; node = node.next() {
+		if node.hash < previous {
+			t.Errorf("%q comes after a larger hash", node.name)
+		}
+		previous = node.hash
+	}
+}
@@ -0,0 +1,457 @@
+package store
+
+import (
+	"slices"
+	"time"
+)
+
+// SetOperation is the algebra SUNION, SINTER and SDIFF apply to their sets
+type SetOperation int
+
+const (
+	SetUnion SetOperation = iota
+	SetInter
+	SetDiff
+)
+
+/*
+ 	* setLocked returns the set at key, the key-value write lock must be held
+	* @param key string - the key of the set
+	* @param now time.Time - the current time
+	* @param create bool - create an empty set if the key doesn't exist, it is only stored once a member is added
+	* @return *set - the set, nil if the key doesn't exist and create is false
+	* @return storedValue - the value holding the set
+	* @return error - ErrWrongType if the key holds something other than a set
+*/
+func (s *Store) setLocked(key string, now time.Time, create bool) (*set, storedValue, error) {
+	stored, exists := s.kv.lookupLocked(key, now)
+	if !exists {
+		if s.streams.isStreamKey(key) {
+			return nil, stored, ErrWrongType
+		}
+		if !create {
+			return nil, stored, nil
+		}
+		stored = storedValue{object: newSet()}
+	}
+
+	st, ok := stored.object.(*set)
+	if !ok {
+		return nil, stored, ErrWrongType
+	}
+
+	return st, stored, nil
+}
+
+/*
+ 	* storeSetLocked stores the set back, or deletes the key once the set is empty
+	* @param key string - the key of the set
+	* @param st *set - the set
+	* @param stored storedValue - the value holding the set
+*/
+func (s *Store) storeSetLocked(key string, st *set, stored storedValue) {
+	if st.len() == 0 {
+		s.kv.remove(key)
+		return
+	}
+	s.kv.put(key, stored)
+}
+
+/*
+ 	* setsLocked returns the sets at keys, nil for the keys that don't exist, the key-value write lock must be held
+	* @param keys []string - the keys of the sets
+	* @param now time.Time - the current time
+	* @return []*set - the sets
+	* @return error - ErrWrongType if any key holds something other than a set
+*/
+func (s *Store) setsLocked(keys []string, now time.Time) ([]*set, error) {
+	sets := make([]*set, len(keys))
+	for i, key := range keys {
+		st, _, err := s.setLocked(key, now, false)
+		if err != nil {
+			return nil, err
+		}
+		sets[i] = st
+	}
+	return sets, nil
+}
+
+/*
+ 	* SAdd adds members to the set, creating it if it doesn't exist
+	* @param key string - the key of the set
+	* @param members [][]byte - the members
+	* @return int - the number of members that were added, not counting the ones already there
+	* @return error - ErrWrongType if the key holds something other than a set
+*/
+func (s *Store) SAdd(key string, members [][]byte) (int, error) {
+	s.kv.mu.Lock()
+	defer s.kv.mu.Unlock()
+
+	st, stored, err := s.setLocked(key, time.Now(), true)
+	if err != nil {
+		return 0, err
+	}
+
+	added := 0
+	for _, member := range members {
+		if st.add(string(member)) {
+			added++
+		}
+	}
+
+	s.storeSetLocked(key, st, stored)
+	return added, nil
+}
+
+/*
+ 	* SRem removes members from the set, and the key once the set is empty
+	* @param key string - the key of the set
+	* @param members []string - the members
+	* @return int - the number of members that were removed
+	* @return error - ErrWrongType if the key holds something other than a set
+*/
+func (s *Store) SRem(key string, members []string) (int, error) {
+	s.kv.mu.Lock()
+	defer s.kv.mu.Unlock()
+
+	st, stored, err := s.setLocked(key, time.Now(), false)
+	if err != nil || st == nil {
+		return 0, err
+	}
+
+	removed := 0
+	for _, member := range members {
+		if st.remove(member) {
+			removed++
+		}
+	}
+
+	s.storeSetLocked(key, st, stored)
+	return removed, nil
+}
+
+/*
+ 	* SCard returns the number of members of the set
+	* @param key string - the key of the set
+	* @return int - the number of members, 0 if the key doesn't exist
+	* @return error - ErrWrongType if the key holds something other than a set
+*/
+func (s *Store) SCard(key string) (int, error) {
+	s.kv.mu.Lock()
+	defer s.kv.mu.Unlock()
+
+	st, _, err := s.setLocked(key, time.Now(), false)
+	if err != nil || st == nil {
+		return 0, err
+	}
+	return st.len(), nil
+}
+
+/*
+ 	* SIsMember checks which of the members are in the set
+	* @param key string - the key of the set
+	* @param members []string - the members
+	* @return []bool - for each member, true if it is in the set
+	* @return error - ErrWrongType if the key holds something other than a set
+*/
+func (s *Store) SIsMember(key string, members []string) ([]bool, error) {
+	s.kv.mu.Lock()
+	defer s.kv.mu.Unlock()
+
+	found := make([]bool, len(members))
+
+	st, _, err := s.setLocked(key, time.Now(), false)
+	if err != nil || st == nil {
+		return found, err
+	}
+
+	for i, member := range members {
+		found[i] = st.contains(member)
+	}
+	return found, nil
+}
+
+/*
+ 	* SMembers returns every member of the set
+	* @param key string - the key of the set
+	* @return [][]byte - the members, empty if the key doesn't exist
+	* @return error - ErrWrongType if the key holds something other than a set
+*/
+func (s *Store) SMembers(key string) ([][]byte, error) {
+	s.kv.mu.Lock()
+	defer s.kv.mu.Unlock()
+
+	st, _, err := s.setLocked(key, time.Now(), false)
+	if err != nil || st == nil {
+		return [][]byte{}, err
+	}
+	return st.members(), nil
+}
+
+/*
+ 	* SRandMember returns random members of the set without removing them
+	* @param key string - the key of the set
+	* @param count int - how many members
+	* @param distinct bool - true for count different members, at most the whole set, false to allow repeats
+	* @return [][]byte - the members, empty if the key doesn't exist
+	* @return error - ErrWrongType if the key holds something other than a set
+*/
+func (s *Store) SRandMember(key string, count int, distinct bool) ([][]byte, error) {
+	s.kv.mu.Lock()
+	defer s.kv.mu.Unlock()
+
+	st, _, err := s.setLocked(key, time.Now(), false)
+	if err != nil || st == nil {
+		return [][]byte{}, err
+	}
+	return st.random(count, distinct), nil
+}
+
+/*
+ 	* SPop removes and returns random members of the set, and deletes the key once the set is empty
+	* @param key string - the key of the set
+	* @param count int - how many members
+	* @return [][]byte - the members, empty if the key doesn't exist
+	* @return error - ErrWrongType if the key holds something other than a set
+*/
+func (s *Store) SPop(key string, count int) ([][]byte, error) {
+	s.kv.mu.Lock()
+	defer s.kv.mu.Unlock()
+
+	st, stored, err := s.setLocked(key, time.Now(), false)
+	if err != nil || st == nil {
+		return [][]byte{}, err
+	}
+
+	popped := st.random(count, true)
+	for _, member := range popped {
+		st.remove(string(member))
+	}
+
+	s.storeSetLocked(key, st, stored)
+	return popped, nil
+}
+
+/*
+ 	* SMove moves a member from one set to another, creating the destination if it doesn't exist
+	* @param src string - the key of the set to move from
+	* @param dst string - the key of the set to move to
+	* @param member string - the member
+	* @return bool - true if the member was moved, false if it is not in src
+	* @return error - ErrWrongType if either key holds something other than a set
+*/
+func (s *Store) SMove(src, dst, member string) (bool, error) {
+	s.kv.mu.Lock()
+	defer s.kv.mu.Unlock()
+
+	now := time.Now()
+	from, fromStored, err := s.setLocked(src, now, false)
+	if err != nil {
+		return false, err
+	}
+	to, toStored, err := s.setLocked(dst, now, true)
+	if err != nil {
+		return false, err
+	}
+
+	if from == nil || !from.contains(member) {
+		return false, nil
+	}
+	if src == dst {
+		return true, nil
+	}
+
+	from.remove(member)
+	to.add(member)
+
+	s.storeSetLocked(src, from, fromStored)
+	s.storeSetLocked(dst, to, toStored)
+	return true, nil
+}
+
+/*
+ 	* setOperation applies the operation to the sets, a missing set counts as an empty one
+	* @param op SetOperation - SetUnion, SetInter or SetDiff
+	* @param sets []*set - the sets, for SetDiff the first one minus all the others
+	* @return *set - the result
+*/
+func setOperation(op SetOperation, sets []*set) *set {
+	result := newSet()
+
+	switch op {
+	case SetUnion:
+		for _, st := range sets {
+			if st != nil {
+				st.forEach(func(member string) { result.add(member) })
+			}
+		}
+	case SetInter:
+		if slices.Contains(sets, nil) {
+			return result
+		}
+		// walk the smallest set, checking the others from the smallest up, so a member missing from most of them fails early
+		sorted := slices.Clone(sets)
+		slices.SortFunc(sorted, func(a, b *set) int { return a.len() - b.len() })
+		sorted[0].forEach(func(member string) {
+			for _, other := range sorted[1:] {
+				if !other.contains(member) {
+					return
+				}
+			}
+			result.add(member)
+		})
+	case SetDiff:
+		if len(sets) == 0 || sets[0] == nil {
+			return result
+		}
+		sets[0].forEach(func(member string) {
+			for _, other := range sets[1:] {
+				if other != nil && other.contains(member) {
+					return
+				}
+			}
+			result.add(member)
+		})
+	}
+
+	return result
+}
+
+/*
+ 	* SetOp returns the union, intersection or difference of the sets at keys
+	* @param op SetOperation - SetUnion, SetInter or SetDiff
+	* @param keys []string - the keys of the sets, for SetDiff the first one minus all the others
+	* @return [][]byte - the members of the result
+	* @return error - ErrWrongType if any key holds something other than a set
+*/
+func (s *Store) SetOp(op SetOperation, keys []string) ([][]byte, error) {
+	s.kv.mu.Lock()
+	defer s.kv.mu.Unlock()
+
+	sets, err := s.setsLocked(keys, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	return setOperation(op, sets).members(), nil
+}
+
+/*
+ 	* SetOpStore stores the union, intersection or difference of the sets at keys in dst, overwriting whatever is there.
+	* an empty result deletes dst
+	* @param op SetOperation - SetUnion, SetInter or SetDiff
+	* @param dst string - the key to store the result at
+	* @param keys []string - the keys of the sets, for SetDiff the first one minus all the others
+	* @return int - the number of members of the result
+	* @return error - ErrWrongType if any source key holds something other than a set
+*/
+func (s *Store) SetOpStore(op SetOperation, dst string, keys []string) (int, error) {
+	s.kv.mu.Lock()
+	defer s.kv.mu.Unlock()
+
+	sets, err := s.setsLocked(keys, time.Now())
+	if err != nil {
+		return 0, err
+	}
+
+	result := setOperation(op, sets)
+	s.overwriteLocked(dst)
+	s.storeSetLocked(dst, result, storedValue{object: result})
+	return result.len(), nil
+}
+
+/*
+ 	* SInterCard returns the size of the intersection of the sets at keys, without building it
+	* @param keys []string - the keys of the sets
+	* @param limit int - stop counting once the intersection has this many members, 0 for no limit
+	* @return int - the size of the intersection, at most limit
+	* @return error - ErrWrongType if any key holds something other than a set
+*/
+func (s *Store) SInterCard(keys []string, limit int) (int, error) {
+	s.kv.mu.Lock()
+	defer s.kv.mu.Unlock()
+
+	sets, err := s.setsLocked(keys, time.Now())
+	if err != nil {
+		return 0, err
+	}
+	if slices.Contains(sets, nil) {
+		return 0, nil
+	}
+
+	slices.SortFunc(sets, func(a, b *set) int { return a.len() - b.len() })
+
+	// forEach can't be stopped early, so once the limit is reached the remaining members are just skipped
+	count := 0
+	sets[0].forEach(func(member string) {
+		if limit > 0 && count >= limit {
+			return
+		}
+		for _, other := range sets[1:] {
+			if !other.contains(member) {
+				return
+			}
+		}
+		count++
+	})
+	return count, nil
+}
+
+/*
+ 	* SScan returns the next batch of members of an SSCAN iteration, with the same guarantees as Scan,
+	* an intset comes back whole with the cursor 0
+	* @param key string - the key of the set
+	* @param cursor uint64 - 0 to start, then the cursor returned by the previous call
+	* @param count int - how many members to look at
+	* @param pattern string - the glob-style pattern the members must match, "" for all
+	* @return [][]byte - the members
+	* @return uint64 - the cursor for the next call, 0 when the iteration is over
+	* @return error - ErrWrongType if the key holds something other than a set
+*/
+func (s *Store) SScan(key string, cursor uint64, count int, pattern string) ([][]byte, uint64, error) {
+	s.kv.mu.Lock()
+	defer s.kv.mu.Unlock()
+
+	st, _, err := s.setLocked(key, time.Now(), false)
+	if err != nil || st == nil {
+		return [][]byte{}, 0, err
+	}
+
+	result := [][]byte{}
+	visit := func(member string) {
+		if pattern != "" && !MatchGlob(pattern, member) {
+			return
+		}
+		result = append(result, []byte(member))
+	}
+
+	// like Redis, an intset is small enough to be returned whole by the first call
+	if st.dict == nil {
+		st.forEach(visit)
+		return result, 0, nil
+	}
+
+	return result, st.order.scan(cursor, count, visit), nil
+}
+
+/*
+ 	* RestoreSet puts a set read from disk back at key
+	* @param key string - the key of the set
+	* @param members [][]byte - the members
+	* @param expiration time.Duration - the time to live, 0 for none
+*/
+func (s *Store) RestoreSet(key string, members [][]byte, expiration time.Duration) {
+	s.kv.mu.Lock()
+	defer s.kv.mu.Unlock()
+
+	st := newSet()
+	for _, member := range members {
+		st.add(string(member))
+	}
+
+	stored := storedValue{object: st}
+	if expiration > 0 {
+		stored.expiration = time.Now().Add(expiration)
+	}
+
+	s.storeSetLocked(key, st, stored)
+}
@@ -0,0 +1,203 @@
+package store
+
+import (
+	"math/rand"
+	"slices"
+	"strconv"
+)
+
+// like Redis, a set whose members are all integers is a sorted array of int64, an intset, which costs far less memory
+// than a map and is searched with a binary search. it turns into a map, for good, once a member that is not an integer
+// is added or it grows past the limit
+const setMaxIntsetEntries = 512 // set-max-intset-entries
+
+type set struct {
+	ints  []int64             // intset encoding, sorted, nil once the set turned into a map
+	dict  map[string]struct{} // hashtable encoding
+	order *scanIndex          // the members of the hashtable encoding in SSCAN order
+}
+
+func newSet() *set {
+	return &set{ints: []int64{}}
+}
+
+func (st *set) len() int {
+	if st.dict != nil {
+		return len(st.dict)
+	}
+	return len(st.ints)
+}
+
+/*
+ 	* setInteger parses a member the way the intset encoding stores it, only the canonical form of an integer counts,
+	* "007" or "+7" must come back as they were added
+	* @param member string - the member
+	* @return int64 - the integer
+	* @return bool - true if the member can be stored in an intset
+*/
+func setInteger(member string) (int64, bool) {
+	n, err := strconv.ParseInt(member, 10, 64)
+	if err != nil || strconv.FormatInt(n, 10) != member {
+		return 0, false
+	}
+	return n, true
+}
+
+/*
+ 	* contains checks if a member is in the set
+	* @param member string - the member
+	* @return bool - true if it is, false otherwise
+*/
+func (st *set) contains(member string) bool {
+	if st.dict != nil {
+		_, exists := st.dict[member]
+		return exists
+	}
+
+	n, ok := setInteger(member)
+	if !ok {
+		return false
+	}
+	_, found := slices.BinarySearch(st.ints, n)
+	return found
+}
+
+/*
+ 	* add adds a member, converting the set to a map when the intset can't hold it
+	* @param member string - the member
+	* @return bool - true if the member is new, false if it was already there
+*/
+func (st *set) add(member string) bool {
+	if st.dict == nil {
+		if n, ok := setInteger(member); ok {
+			i, found := slices.BinarySearch(st.ints, n)
+			if found {
+				return false
+			}
+			st.ints = slices.Insert(st.ints, i, n)
+			if len(st.ints) > setMaxIntsetEntries {
+				st.convertToDict()
+			}
+			return true
+		}
+		st.convertToDict()
+	}
+
+	if _, exists := st.dict[member]; exists {
+		return false
+	}
+	st.dict[member] = struct{}{}
+	st.order.insert(member)
+	return true
+}
+
+func (st *set) convertToDict() {
+	st.dict = make(map[string]struct{}, len(st.ints))
+	st.order = newScanIndex()
+	for _, n := range st.ints {
+		member := strconv.FormatInt(n, 10)
+		st.dict[member] = struct{}{}
+		st.order.insert(member)
+	}
+	st.ints = nil
+}
+
+/*
+ 	* remove removes a member
+	* @param member string - the member
+	* @return bool - true if the member was there, false otherwise
+*/
+func (st *set) remove(member string) bool {
+	if st.dict != nil {
+		_, exists := st.dict[member]
+		delete(st.dict, member)
+		if exists {
+			st.order.delete(member)
+		}
+		return exists
+	}
+
+	n, ok := setInteger(member)
+	if !ok {
+		return false
+	}
+	i, found := slices.BinarySearch(st.ints, n)
+	if found {
+		st.ints = slices.Delete(st.ints, i, i+1)
+	}
+	return found
+}
+
+/*
+ 	* forEach calls fn with every member, in ascending order while the set is an intset
+	* @param fn func(member string) - called for every member
+*/
+func (st *set) forEach(fn func(member string)) {
+	if st.dict != nil {
+		for member := range st.dict {
+			fn(member)
+		}
+		return
+	}
+	for _, n := range st.ints {
+		fn(strconv.FormatInt(n, 10))
+	}
+}
+
+/*
+ 	* members returns every member
+	* @return [][]byte - the members
+*/
+func (st *set) members() [][]byte {
+	members := make([][]byte, 0, st.len())
+	st.forEach(func(member string) {
+		members = append(members, []byte(member))
+	})
+	return members
+}
+
+/*
+ 	* random picks count members at random
+	* @param count int - how many members
+	* @param distinct bool - true for count different members, at most the whole set, false to allow repeats
+	* @return [][]byte - the members
+*/
+func (st *set) random(count int, distinct bool) [][]byte {
+	members := st.members()
+	if len(members) == 0 {
+		return [][]byte{}
+	}
+
+	if !distinct {
+		picked := make([][]byte, count)
+		for i := range picked {
+			picked[i] = members[rand.Intn(len(members))]
+		}
+		return picked
+	}
+
+	// a partial Fisher-Yates shuffle, the first count members end up a uniform sample
+	count = min(count, len(members))
+	for i := 0; i < count; i++ {
+		j := i + rand.Intn(len(members)-i)
+		members[i], members[j] = members[j], members[i]
+	}
+	return members[:count]
+}
+
+/*
+ 	* clone returns a copy of the set
+	* @return *set - the copy
+*/
+func (st *set) clone() *set {
+	if st.dict == nil {
+		return &set{ints: slices.Clone(st.ints)}
+	}
+
+	copied := &set{dict: make(map[string]struct{}, len(st.dict)), order: newScanIndex()}
+	for member := range st.dict {
+		copied.dict[member] = struct{}{}
+		copied.order.insert(member)
+	}
+	return copied
+}
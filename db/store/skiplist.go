@@ -0,0 +1,342 @@
+package store
+
+import (
+	"math/rand"
+	"strings"
+)
+
+// the skiplist of a sorted set is the one from Redis: nodes ordered by score then member, each level of a node
+// knows how many nodes its forward pointer skips, the span, so the rank of a node is the sum of the spans walked to reach it
+const (
+	skiplistMaxLevel = 32   // enough for 2^64 elements
+	skiplistP        = 0.25 // the chance a node gets one more level
+)
+
+type skiplistLevel struct {
+	forward *skiplistNode
+	span    int // the number of nodes between this node and forward, forward included
+}
+
+type skiplistNode struct {
+	member   string
+	score    float64
+	backward *skiplistNode
+	level    []skiplistLevel
+}
+
+type skiplist struct {
+	header *skiplistNode // not an element, its levels point at the first node of each level
+	tail   *skiplistNode
+	length int
+	level  int // the highest level of any node
+}
+
+// zrangeSpec is a range of a sorted set, by score or by member
+type zrangeSpec interface {
+	empty() bool                      // true if no element can be in the range
+	aboveMin(node *skiplistNode) bool // true if the node is not before the start of the range
+	belowMax(node *skiplistNode) bool // true if the node is not after the end of the range
+}
+
+// ScoreRange is a range of scores, each end inclusive unless marked exclusive
+type ScoreRange struct {
+	Min, Max                   float64
+	MinExclusive, MaxExclusive bool
+}
+
+// LexBound is one end of a range of members: a member, inclusive or not, or - and + for the ends of the set
+type LexBound struct {
+	Value     string
+	Exclusive bool
+	Infinity  int // -1 for -, 1 for +, 0 for a member
+}
+
+// LexRange is a range of members compared byte by byte, only meaningful when every element has the same score
+type LexRange struct {
+	Min, Max LexBound
+}
+
+func newSkiplist() *skiplist {
+	return &skiplist{
+		header: &skiplistNode{level: make([]skiplistLevel, skiplistMaxLevel)},
+		level:  1,
+	}
+}
+
+func randomLevel() int {
+	level := 1
+	for level < skiplistMaxLevel && rand.Float64() < skiplistP {
+		level++
+	}
+	return level
+}
+
+// before checks if the node comes before the element with this score and member
+func (node *skiplistNode) before(score float64, member string) bool {
+	return node.score < score || (node.score == score && node.member < member)
+}
+
+/*
+ 	* insert adds an element, the member must not be in the skiplist already
+	* @param score float64 - the score
+	* @param member string - the member
+	* @return *skiplistNode - the new node
+*/
+func (zsl *skiplist) insert(score float64, member string) *skiplistNode {
+	var update [skiplistMaxLevel]*skiplistNode
+	var rank [skiplistMaxLevel]int
+
+	x := zsl.header
+	for i := zsl.level - 1; i >= 0; i-- {
+		if i != zsl.level-1 {
+			rank[i] = rank[i+1]
+		}
+		for x.level[i].forward != nil && x.level[i].forward.before(score, member) {
+			rank[i] += x.level[i].span
+			x = x.level[i].forward
+		}
+		update[i] = x
+	}
+
+	level := randomLevel()
+	if level > zsl.level {
+		for i := zsl.level; i < level; i++ {
+			rank[i] = 0
+			update[i] = zsl.header
+			update[i].level[i].span = zsl.length
+		}
+		zsl.level = level
+	}
+
+	x = &skiplistNode{member: member, score: score, level: make([]skiplistLevel, level)}
+	for i := 0; i < level; i++ {
+		x.level[i].forward = update[i].level[i].forward
+		update[i].level[i].forward = x
+
+		x.level[i].span = update[i].level[i].span - (rank[0] - rank[i])
+		update[i].level[i].span = rank[0] - rank[i] + 1
+	}
+
+	// the levels above the new node now skip one more node
+	for i := level; i < zsl.level; i++ {
+		update[i].level[i].span++
+	}
+
+	if update[0] != zsl.header {
+		x.backward = update[0]
+	}
+	if x.level[0].forward != nil {
+		x.level[0].forward.backward = x
+	} else {
+		zsl.tail = x
+	}
+
+	zsl.length++
+	return x
+}
+
+/*
+ 	* deleteNode unlinks a node
+	* @param x *skiplistNode - the node
+	* @param update *[skiplistMaxLevel]*skiplistNode - the last node before x on each level
+*/
+func (zsl *skiplist) deleteNode(x *skiplistNode, update *[skiplistMaxLevel]*skiplistNode) {
+	for i := 0; i < zsl.level; i++ {
+		if update[i].level[i].forward == x {
+			update[i].level[i].span += x.level[i].span - 1
+			update[i].level[i].forward = x.level[i].forward
+		} else {
+			update[i].level[i].span--
+		}
+	}
+
+	if x.level[0].forward != nil {
+		x.level[0].forward.backward = x.backward
+	} else {
+		zsl.tail = x.backward
+	}
+
+	for zsl.level > 1 && zsl.header.level[zsl.level-1].forward == nil {
+		zsl.level--
+	}
+	zsl.length--
+}
+
+/*
+ 	* delete removes an element
+	* @param score float64 - the score of the element
+	* @param member string - the member
+	* @return bool - true if the element was found, false otherwise
+*/
+func (zsl *skiplist) delete(score float64, member string) bool {
+	var update [skiplistMaxLevel]*skiplistNode
+
+	x := zsl.header
+	for i := zsl.level - 1; i >= 0; i-- {
+		for x.level[i].forward != nil && x.level[i].forward.before(score, member) {
+			x = x.level[i].forward
+		}
+		update[i] = x
+	}
+
+	x = x.level[0].forward
+	if x == nil || x.score != score || x.member != member {
+		return false
+	}
+
+	zsl.deleteNode(x, &update)
+	return true
+}
+
+/*
+ 	* rank returns the position of an element, starting at 1
+	* @param score float64 - the score of the element
+	* @param member string - the member
+	* @return int - the rank, 0 if the element is not in the skiplist
+*/
+func (zsl *skiplist) rank(score float64, member string) int {
+	rank := 0
+
+	x := zsl.header
+	for i := zsl.level - 1; i >= 0; i-- {
+		for x.level[i].forward != nil && (x.level[i].forward.before(score, member) ||
+			(x.level[i].forward.score == score && x.level[i].forward.member == member)) {
+			rank += x.level[i].span
+			x = x.level[i].forward
+		}
+
+		if x != zsl.header && x.score == score && x.member == member {
+			return rank
+		}
+	}
+
+	return 0
+}
+
+/*
+ 	* byRank returns the element at a position
+	* @param rank int - the position, starting at 1
+	* @return *skiplistNode - the node, nil if the rank is out of range
+*/
+func (zsl *skiplist) byRank(rank int) *skiplistNode {
+	traversed := 0
+
+	x := zsl.header
+	for i := zsl.level - 1; i >= 0; i-- {
+		for x.level[i].forward != nil && traversed+x.level[i].span <= rank {
+			traversed += x.level[i].span
+			x = x.level[i].forward
+		}
+
+		if traversed == rank && x != zsl.header {
+			return x
+		}
+	}
+
+	return nil
+}
+
+/*
+ 	* firstInRange returns the first element in the range
+	* @param spec zrangeSpec - the range
+	* @return *skiplistNode - the node, nil if no element is in the range
+*/
+func (zsl *skiplist) firstInRange(spec zrangeSpec) *skiplistNode {
+	first := zsl.header.level[0].forward
+	if spec.empty() || first == nil || !spec.aboveMin(zsl.tail) || !spec.belowMax(first) {
+		return nil
+	}
+
+	x := zsl.header
+	for i := zsl.level - 1; i >= 0; i-- {
+		for x.level[i].forward != nil && !spec.aboveMin(x.level[i].forward) {
+			x = x.level[i].forward
+		}
+	}
+
+	// the range overlaps the skiplist, so the next node exists
+	x = x.level[0].forward
+	if !spec.belowMax(x) {
+		return nil
+	}
+	return x
+}
+
+/*
+ 	* lastInRange returns the last element in the range
+	* @param spec zrangeSpec - the range
+	* @return *skiplistNode - the node, nil if no element is in the range
+*/
+func (zsl *skiplist) lastInRange(spec zrangeSpec) *skiplistNode {
+	first := zsl.header.level[0].forward
+	if spec.empty() || first == nil || !spec.aboveMin(zsl.tail) || !spec.belowMax(first) {
+		return nil
+	}
+
+	x := zsl.header
+	for i := zsl.level - 1; i >= 0; i-- {
+		for x.level[i].forward != nil && spec.belowMax(x.level[i].forward) {
+			x = x.level[i].forward
+		}
+	}
+
+	// the range overlaps the skiplist, so this is a node and not the header
+	if !spec.aboveMin(x) {
+		return nil
+	}
+	return x
+}
+
+func (r ScoreRange) empty() bool {
+	return r.Min > r.Max || (r.Min == r.Max && (r.MinExclusive || r.MaxExclusive))
+}
+
+func (r ScoreRange) aboveMin(node *skiplistNode) bool {
+	if r.MinExclusive {
+		return node.score > r.Min
+	}
+	return node.score >= r.Min
+}
+
+func (r ScoreRange) belowMax(node *skiplistNode) bool {
+	if r.MaxExclusive {
+		return node.score < r.Max
+	}
+	return node.score <= r.Max
+}
+
+// compare compares the bound with a member, -1 if the bound comes first, 1 if it comes after, 0 if they are equal
+func (b LexBound) compare(member string) int {
+	if b.Infinity != 0 {
+		return b.Infinity
+	}
+	return strings.Compare(b.Value, member)
+}
+
+func (r LexRange) empty() bool {
+	if r.Min.Infinity == 1 || r.Max.Infinity == -1 {
+		return true
+	}
+	if r.Min.Infinity == -1 || r.Max.Infinity == 1 {
+		return false
+	}
+
+	cmp := strings.Compare(r.Min.Value, r.Max.Value)
+	return cmp > 0 || (cmp == 0 && (r.Min.Exclusive || r.Max.Exclusive))
+}
+
+func (r LexRange) aboveMin(node *skiplistNode) bool {
+	cmp := r.Min.compare(node.member)
+	if r.Min.Exclusive {
+		return cmp < 0
+	}
+	return cmp <= 0
+}
+
+func (r LexRange) belowMax(node *skiplistNode) bool {
+	cmp := r.Max.compare(node.member)
+	if r.Max.Exclusive {
+		return cmp > 0
+	}
+	return cmp >= 0
+}
@@ -0,0 +1,110 @@
+package store
+
+import (
+	"time"
+)
+
+// SnapshotEntry is a point-in-time copy of a single key, used by persistence to write the dataset to disk
+type SnapshotEntry struct {
+	Key        string
+	Type       string                // one of the Type* constants
+	Value      []byte                // value of a string key
+	Elements   [][]byte              // elements of a list key, head first, or members of a set key
+	Records    []StreamRecord        // entries of a stream key, in ID order
+	LastId     string                // greatest ID ever added to a stream key, it may have been deleted since
+	Groups     []StreamGroupSnapshot // consumer groups of a stream key, by name
+	Fields     []HashField           // fields of a hash key
+	ZMembers   []ZMember             // members of a sorted set key, lowest score first
+	Meter      RateMeterSnapshot
+	IDGen      IDGeneratorSnapshot
+	Expiration time.Time // zero if the key has no TTL
+}
+
+/*
+ 	* snapshot copies every live key of the store while holding all the read locks, so the result is a consistent point-in-time view
+	* @return []SnapshotEntry - the copied keys
+*/
+func (s *Store) snapshot() []SnapshotEntry {
+	s.kv.mu.RLock()
+	defer s.kv.mu.RUnlock()
+
+	now := time.Now()
+	entries := make([]SnapshotEntry, 0, len(s.kv.store))
+
+	for key, value := range s.kv.store {
+		if value.isExpired(now) {
+			continue
+		}
+
+		entry := SnapshotEntry{
+			Key:        key,
+			Type:       value.typeName(),
+			Expiration: value.expiration,
+		}
+
+		switch object := value.object.(type) {
+		case *rateMeter:
+			entry.Meter = object.snapshot()
+		case *idGenerator:
+			entry.IDGen = IDGeneratorSnapshot{LastMs: object.lastMs, LastSeq: object.lastSeq}
+		case *hash:
+			entry.Fields = object.fields()
+		case *set:
+			entry.Elements = object.members()
+		case *zset:
+			entry.ZMembers = object.members()
+		case *quicklist:
+			// the elements are never modified in place, copying the slice headers is enough
+			entry.Elements = object.values()
+		default:
+			// copy the bytes, the background save keeps using them after the locks are released
+			entry.Value = append([]byte{}, value.bytes()...)
+		}
+
+		entries = append(entries, entry)
+	}
+
+	s.streams.mu.RLock()
+	defer s.streams.mu.RUnlock()
+
+	// lock every stream before copying any of them, so that no stream moves ahead of the others while we copy
+	streams := s.streams.streams
+	for _, stream := range streams {
+		stream.mu.RLock()
+	}
+	defer func() {
+		for _, stream := range streams {
+			stream.mu.RUnlock()
+		}
+	}()
+
+	for name, stream := range streams {
+		if stream.isExpired(now) {
+			continue
+		}
+
+		records := make([]StreamRecord, 0, stream.entries.length)
+		stream.entries.ascend(streamID{}, func(record *StreamRecord) bool {
+			data := make(map[string][]byte, len(record.Data))
+			for field, value := range record.Data {
+				data[field] = value
+			}
+
+			copied := *record
+			copied.Data = data
+			records = append(records, copied)
+			return true
+		})
+
+		entries = append(entries, SnapshotEntry{
+			Key:        name,
+			Type:       TypeStream,
+			Records:    records,
+			LastId:     stream.lastId.String(),
+			Groups:     stream.groupSnapshots(),
+			Expiration: stream.expiration,
+		})
+	}
+
+	return entries
+}
@@ -1,11 +1,25 @@
 package store
 
 import (
+	"sync"
 	"time"
 
 	RESP "github.com/manish-singh-bisht/Redis-From-Scratch/db/resp"
 )
 
+// the names TYPE reports
+const (
+	TypeNone      = "none"
+	TypeString    = "string"
+	TypeStream    = "stream"
+	TypeRateMeter = "ratemeter"
+	TypeList      = "list"
+	TypeIDGen     = "idgen"
+	TypeHash      = "hash"
+	TypeSet       = "set"
+	TypeZSet      = "zset"
+)
+
 type Store struct {
 	kv      *keyValueStore
 	streams *streamManager
@@ -18,32 +32,272 @@ func GetStore() *Store {
 	}
 }
 
-func (s *Store) Get(key string) ([]byte, bool) {
-	return s.kv.get(key)
+/*
+ 	* StartActiveExpire starts deleting expired keys in the background, on top of the lazy deletion on access
+	* @param hz int - how many expire cycles run per second
+	* @param effort int - from 1 to 10, how hard each cycle tries to keep expired keys out of memory
+*/
+func (s *Store) StartActiveExpire(hz, effort int) {
+	s.startActiveExpire(hz, effort)
+}
+
+/*
+ 	* OnExpire registers a function that is called with every key deleted because it expired
+	* @param hook func(key string) - the function to call, it must not call back into the store
+*/
+func (s *Store) OnExpire(hook func(key string)) {
+	s.kv.mu.Lock()
+	s.kv.onExpire = hook
+	s.kv.mu.Unlock()
+
+	s.streams.mu.Lock()
+	s.streams.onExpire = hook
+	s.streams.mu.Unlock()
+}
+
+/*
+ 	* Get returns the string at key
+	* @param key string - the key of the string
+	* @return []byte - the value
+	* @return bool - true if the key exists, false otherwise
+	* @return error - ErrWrongType if the key holds something other than a string, a stream included
+*/
+func (s *Store) Get(key string) ([]byte, bool, error) {
+	value, exists, err := s.kv.get(key)
+	if err == nil && !exists && s.streams.isStreamKey(key) {
+		return nil, false, ErrWrongType
+	}
+	return value, exists, err
 }
 
 func (s *Store) Set(key string, value []byte, expiration time.Duration) {
 	s.kv.set(key, value, expiration)
 }
 
+/*
+ 	* GetKeys returns all the keys, strings and streams alike, that match the glob-style pattern
+	* @param pattern string - the pattern to match
+	* @return []string - the keys
+*/
 func (s *Store) GetKeys(pattern string) []string {
-	return s.kv.getKeys(pattern)
+	keys := s.kv.getKeys(pattern)
+
+	seen := make(map[string]struct{}, len(keys))
+	for _, key := range keys {
+		seen[key] = struct{}{}
+	}
+	for _, streamName := range s.streams.getKeys(pattern) {
+		if _, exists := seen[streamName]; !exists {
+			keys = append(keys, streamName)
+		}
+	}
+
+	return keys
 }
 
-func (s *Store) XAdd(streamName, id string, data map[string][]byte) (StreamRecord, bool, error) {
-	return s.streams.xadd(streamName, id, data)
+func (s *Store) RateGet(key string) (RateMeterReading, bool, error) {
+	return s.kv.rateGet(key)
 }
 
-func (s *Store) XRange(streamName, startId, endId string) ([]StreamRecord, error) {
-	return s.streams.xrange(streamName, startId, endId)
+func (s *Store) RestoreRateMeter(key string, snapshot RateMeterSnapshot, expiration time.Duration) {
+	s.kv.restoreRateMeter(key, snapshot, expiration)
 }
 
-func (s *Store) XRead(streamName, startId string) ([]StreamRecord, error) {
-	return s.streams.xread(streamName, startId)
+func (s *Store) XAdd(streamName, id string, data map[string][]byte, noMkStream bool, trim *StreamTrim) (StreamRecord, bool, error) {
+	if _, exists := s.kv.typeOf(streamName); exists {
+		return StreamRecord{}, false, ErrWrongType
+	}
+	return s.streams.xadd(streamName, id, data, noMkStream, trim)
+}
+
+func (s *Store) XTrim(streamName string, trim StreamTrim) (int, error) {
+	if _, exists := s.kv.typeOf(streamName); exists {
+		return 0, ErrWrongType
+	}
+	return s.streams.xtrim(streamName, trim)
+}
+
+func (s *Store) XDel(streamName string, ids []string) (int, error) {
+	if _, exists := s.kv.typeOf(streamName); exists {
+		return 0, ErrWrongType
+	}
+	return s.streams.xdel(streamName, ids)
+}
+
+func (s *Store) XLen(streamName string) (int, error) {
+	if _, exists := s.kv.typeOf(streamName); exists {
+		return 0, ErrWrongType
+	}
+	return s.streams.xlen(streamName), nil
 }
 
-func (s *Store) XReadBlock(streamName, startId string, blockMs int, noTimeout bool) ([]StreamRecord, error) {
-	return s.streams.xreadblock(streamName, startId, blockMs, noTimeout)
+/*
+ 	* RestoreStreamLastId sets the last ID a stream had when it was saved, creating the stream if it has no entries
+	* @param streamName string - the name of the stream
+	* @param id string - the last ID
+	* @return error - ErrInvalidStreamIdArgument
+*/
+func (s *Store) RestoreStreamLastId(streamName, id string) error {
+	return s.streams.restoreLastId(streamName, id)
+}
+
+func (s *Store) XRange(streamName, startId, endId string, count int) ([]StreamRecord, error) {
+	if _, exists := s.kv.typeOf(streamName); exists {
+		return nil, ErrWrongType
+	}
+	return s.streams.xrange(streamName, startId, endId, count, false)
+}
+
+func (s *Store) XRevRange(streamName, endId, startId string, count int) ([]StreamRecord, error) {
+	if _, exists := s.kv.typeOf(streamName); exists {
+		return nil, ErrWrongType
+	}
+	return s.streams.xrange(streamName, startId, endId, count, true)
+}
+
+func (s *Store) XRead(streamNames, ids []string, count int) ([]StreamEntries, error) {
+	if err := s.checkStreamKeys(streamNames); err != nil {
+		return nil, err
+	}
+	return s.streams.xread(streamNames, ids, count)
+}
+
+func (s *Store) XReadPosition(streamNames, ids []string) ([]string, error) {
+	if err := s.checkStreamKeys(streamNames); err != nil {
+		return nil, err
+	}
+	return s.streams.xreadPosition(streamNames, ids)
+}
+
+func (s *Store) XReadBlock(streamNames, ids []string, count int, blockMs int, noTimeout bool) ([]StreamEntries, error) {
+	if err := s.checkStreamKeys(streamNames); err != nil {
+		return nil, err
+	}
+	return s.streams.xreadBlock(streamNames, ids, count, blockMs, noTimeout)
+}
+
+// checkStreamKeys fails with ErrWrongType if one of the keys holds something other than a stream
+func (s *Store) checkStreamKeys(streamNames []string) error {
+	for _, streamName := range streamNames {
+		if _, exists := s.kv.typeOf(streamName); exists {
+			return ErrWrongType
+		}
+	}
+	return nil
+}
+
+func (s *Store) XGroupCreate(streamName, group, id string, mkStream bool) error {
+	if _, exists := s.kv.typeOf(streamName); exists {
+		return ErrWrongType
+	}
+	return s.streams.xgroupCreate(streamName, group, id, mkStream)
+}
+
+func (s *Store) XGroupSetID(streamName, group, id string) error {
+	if err := s.checkStreamKeys([]string{streamName}); err != nil {
+		return err
+	}
+	return s.streams.xgroupSetID(streamName, group, id)
+}
+
+func (s *Store) XGroupDestroy(streamName, group string) (bool, error) {
+	if err := s.checkStreamKeys([]string{streamName}); err != nil {
+		return false, err
+	}
+	return s.streams.xgroupDestroy(streamName, group)
+}
+
+func (s *Store) XGroupCreateConsumer(streamName, group, consumer string) (bool, error) {
+	if err := s.checkStreamKeys([]string{streamName}); err != nil {
+		return false, err
+	}
+	return s.streams.xgroupCreateConsumer(streamName, group, consumer)
+}
+
+func (s *Store) XGroupDelConsumer(streamName, group, consumer string) (int, error) {
+	if err := s.checkStreamKeys([]string{streamName}); err != nil {
+		return 0, err
+	}
+	return s.streams.xgroupDelConsumer(streamName, group, consumer)
+}
+
+func (s *Store) XReadGroup(group, consumer string, streamNames, ids []string, count int, noAck bool) ([]StreamEntries, error) {
+	if err := s.checkStreamKeys(streamNames); err != nil {
+		return nil, err
+	}
+	return s.streams.xreadgroup(group, consumer, streamNames, ids, count, noAck)
+}
+
+func (s *Store) XReadGroupBlock(group, consumer string, streamNames, ids []string, count int, noAck bool, blockMs int, noTimeout bool, held sync.Locker) ([]StreamEntries, error) {
+	if err := s.checkStreamKeys(streamNames); err != nil {
+		return nil, err
+	}
+	return s.streams.xreadgroupBlock(group, consumer, streamNames, ids, count, noAck, blockMs, noTimeout, held)
+}
+
+func (s *Store) XAck(streamName, group string, ids []string) (int, error) {
+	if err := s.checkStreamKeys([]string{streamName}); err != nil {
+		return 0, err
+	}
+	return s.streams.xack(streamName, group, ids)
+}
+
+func (s *Store) XPending(streamName, group string) (StreamPendingSummary, error) {
+	if err := s.checkStreamKeys([]string{streamName}); err != nil {
+		return StreamPendingSummary{}, err
+	}
+	return s.streams.xpending(streamName, group)
+}
+
+func (s *Store) XPendingRange(streamName, group, start, end string, count int, consumer string, minIdle time.Duration) ([]StreamPendingEntry, error) {
+	if err := s.checkStreamKeys([]string{streamName}); err != nil {
+		return nil, err
+	}
+	return s.streams.xpendingRange(streamName, group, start, end, count, consumer, minIdle)
+}
+
+func (s *Store) XClaim(streamName, group, consumer string, ids []string, claim StreamClaim) ([]StreamRecord, error) {
+	if err := s.checkStreamKeys([]string{streamName}); err != nil {
+		return nil, err
+	}
+	return s.streams.xclaim(streamName, group, consumer, ids, claim)
+}
+
+func (s *Store) XAutoClaim(streamName, group, consumer string, minIdle time.Duration, start string, count int, justId bool) (string, []StreamRecord, []string, error) {
+	if err := s.checkStreamKeys([]string{streamName}); err != nil {
+		return "", nil, nil, err
+	}
+	return s.streams.xautoclaim(streamName, group, consumer, minIdle, start, count, justId)
+}
+
+func (s *Store) XInfoStream(streamName string, full bool, count int) (StreamInfo, error) {
+	if _, exists := s.kv.typeOf(streamName); exists {
+		return StreamInfo{}, ErrWrongType
+	}
+	return s.streams.xinfoStream(streamName, full, count)
+}
+
+func (s *Store) XInfoGroups(streamName string) ([]StreamGroupSnapshot, error) {
+	if _, exists := s.kv.typeOf(streamName); exists {
+		return nil, ErrWrongType
+	}
+	return s.streams.xinfoGroups(streamName)
+}
+
+func (s *Store) XInfoConsumers(streamName, group string) ([]StreamConsumerSnapshot, error) {
+	if _, exists := s.kv.typeOf(streamName); exists {
+		return nil, ErrWrongType
+	}
+	return s.streams.xinfoConsumers(streamName, group)
+}
+
+/*
+ 	* RestoreStreamGroups adds consumer groups loaded from disk to a stream, creating the stream if it has no entries
+	* @param streamName string - the name of the stream
+	* @param groups []StreamGroupSnapshot - the groups
+*/
+func (s *Store) RestoreStreamGroups(streamName string, groups []StreamGroupSnapshot) {
+	s.streams.restoreGroups(streamName, groups)
 }
 
 func (s *Store) CreateStreamMessages(records []StreamRecord) []RESP.RESPMessage {
@@ -53,3 +307,22 @@ func (s *Store) CreateStreamMessages(records []StreamRecord) []RESP.RESPMessage
 func (s *Store) IsStreamKey(key string) bool {
 	return s.streams.isStreamKey(key)
 }
+
+func (s *Store) Snapshot() []SnapshotEntry {
+	return s.snapshot()
+}
+
+/*
+ 	* Type returns the type of the value stored at key, "none" if there is no such key
+	* @param key string - the key to check
+	* @return string - the type name
+*/
+func (s *Store) Type(key string) string {
+	if typeName, exists := s.kv.typeOf(key); exists {
+		return typeName
+	}
+	if s.streams.isStreamKey(key) {
+		return TypeStream
+	}
+	return TypeNone
+}
@@ -0,0 +1,941 @@
+package store
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"sort"
+	"sync"
+	"time"
+)
+
+var ErrBusyGroup = errors.New("BUSYGROUP Consumer Group name already exists")
+var ErrXGroupNoKey = errors.New("ERR The XGROUP subcommand requires the key to exist. Note that for CREATE you may want to use the MKSTREAM option to create an empty stream automatically.")
+
+// errNoGroup is the error of the group commands when the stream or the group doesn't exist
+func errNoGroup(streamName, groupName string) error {
+	return fmt.Errorf("NOGROUP No such key '%s' or consumer group '%s'", streamName, groupName)
+}
+
+// errNoGroupForKey is errNoGroup for the XGROUP subcommands, which already checked that the stream exists
+func errNoGroupForKey(streamName, groupName string) error {
+	return fmt.Errorf("NOGROUP No such consumer group '%s' for key name '%s'", groupName, streamName)
+}
+
+// consumerGroup is a consumer group of a stream, guarded by the stream lock
+type consumerGroup struct {
+	lastID    streamID                   // the last entry delivered to the group, XREADGROUP > reads the entries after it
+	pending   map[streamID]*pendingEntry // entries delivered to a consumer and not acknowledged yet, the group's PEL
+	consumers map[string]*streamConsumer
+}
+
+// streamConsumer is a consumer of a group
+type streamConsumer struct {
+	name       string
+	seenTime   time.Time                  // the last time it read or claimed, whether it got anything or not
+	activeTime time.Time                  // the last time it got entries, zero if it never did
+	pending    map[streamID]*pendingEntry // the part of the group's PEL delivered to this consumer
+}
+
+// pendingEntry is an entry delivered to a consumer of a group and not acknowledged yet
+type pendingEntry struct {
+	consumer      *streamConsumer
+	deliveryTime  time.Time
+	deliveryCount int64
+}
+
+// StreamEntries are the entries a read got from one stream
+type StreamEntries struct {
+	Stream  string
+	Records []StreamRecord
+}
+
+// StreamPendingEntry is an entry of a group's pending entries list
+type StreamPendingEntry struct {
+	Id            string
+	Consumer      string
+	DeliveryTime  time.Time
+	DeliveryCount int64
+}
+
+// StreamConsumerPending is how many entries are pending for a consumer
+type StreamConsumerPending struct {
+	Name  string
+	Count int
+}
+
+// StreamPendingSummary is what XPENDING returns without a range
+type StreamPendingSummary struct {
+	Count     int
+	MinId     string                  // the smallest pending ID, empty when nothing is pending
+	MaxId     string                  // the greatest pending ID
+	Consumers []StreamConsumerPending // the consumers with pending entries, by name
+}
+
+// StreamClaim are the options of XCLAIM
+type StreamClaim struct {
+	MinIdle      time.Duration // only claim the entries idle for at least this long
+	DeliveryTime time.Time     // the delivery time set on the claimed entries, zero for now
+	RetryCount   int64         // the delivery count set on the claimed entries, -1 to increment it unless JustId
+	Force        bool          // add the entries that are in the stream but in nobody's pending list
+	JustId       bool          // return only the IDs and leave the delivery counts alone
+	LastId       string        // raise the last delivered ID of the group to this ID, empty to leave it
+}
+
+// StreamConsumerSnapshot is a copy of a consumer, used by persistence and XINFO
+type StreamConsumerSnapshot struct {
+	Name       string
+	SeenTime   time.Time
+	ActiveTime time.Time // zero if it never got any entries
+	Pending    int       // the number of entries pending for it
+}
+
+// StreamGroupSnapshot is a copy of a consumer group, used by persistence
+type StreamGroupSnapshot struct {
+	Name      string
+	LastId    string
+	Consumers []StreamConsumerSnapshot // by name
+	Pending   []StreamPendingEntry     // in ID order
+}
+
+func newConsumerGroup(lastID streamID) *consumerGroup {
+	return &consumerGroup{
+		lastID:    lastID,
+		pending:   make(map[streamID]*pendingEntry),
+		consumers: make(map[string]*streamConsumer),
+	}
+}
+
+/*
+ 	* clone returns a deep copy of the group
+	* @return *consumerGroup - the copy
+*/
+func (g *consumerGroup) clone() *consumerGroup {
+	copied := newConsumerGroup(g.lastID)
+	for name, consumer := range g.consumers {
+		copied.consumers[name] = &streamConsumer{
+			name:       name,
+			seenTime:   consumer.seenTime,
+			activeTime: consumer.activeTime,
+			pending:    make(map[streamID]*pendingEntry, len(consumer.pending)),
+		}
+	}
+	for id, entry := range g.pending {
+		consumer := copied.consumers[entry.consumer.name]
+		copiedEntry := &pendingEntry{consumer: consumer, deliveryTime: entry.deliveryTime, deliveryCount: entry.deliveryCount}
+		copied.pending[id] = copiedEntry
+		consumer.pending[id] = copiedEntry
+	}
+	return copied
+}
+
+/*
+ 	* consumer returns the consumer with the given name, creating it if it doesn't exist, and marks it as seen
+	* @param name string - the name of the consumer
+	* @param now time.Time - the current time
+	* @return *streamConsumer - the consumer
+*/
+func (g *consumerGroup) consumer(name string, now time.Time) *streamConsumer {
+	consumer, exists := g.consumers[name]
+	if !exists {
+		consumer = &streamConsumer{name: name, pending: make(map[streamID]*pendingEntry)}
+		g.consumers[name] = consumer
+	}
+	consumer.seenTime = now
+	return consumer
+}
+
+/*
+ 	* deliver adds an entry to the pending entries of a consumer, taking it from another consumer if it was pending there
+	* @param id streamID - the ID of the entry
+	* @param consumer *streamConsumer - the consumer it is delivered to
+	* @param now time.Time - the current time
+*/
+func (g *consumerGroup) deliver(id streamID, consumer *streamConsumer, now time.Time) {
+	entry, exists := g.pending[id]
+	if exists {
+		// only after XGROUP SETID moved the group back, delivering it again starts its count over
+		delete(entry.consumer.pending, id)
+		entry.consumer = consumer
+		entry.deliveryTime = now
+		entry.deliveryCount = 1
+	} else {
+		entry = &pendingEntry{consumer: consumer, deliveryTime: now, deliveryCount: 1}
+		g.pending[id] = entry
+	}
+	consumer.pending[id] = entry
+}
+
+/*
+ 	* claim moves a pending entry to a consumer
+	* @param id streamID - the ID of the entry
+	* @param entry *pendingEntry - the entry, its consumer is nil if it was just added to the group's PEL
+	* @param consumer *streamConsumer - the consumer claiming it
+*/
+func (g *consumerGroup) claim(id streamID, entry *pendingEntry, consumer *streamConsumer) {
+	if entry.consumer == consumer {
+		return
+	}
+	if entry.consumer != nil {
+		delete(entry.consumer.pending, id)
+	}
+	entry.consumer = consumer
+	consumer.pending[id] = entry
+}
+
+/*
+ 	* ack removes an entry from the pending entries
+	* @param id streamID - the ID of the entry
+	* @return bool - true if the entry was pending
+*/
+func (g *consumerGroup) ack(id streamID) bool {
+	entry, exists := g.pending[id]
+	if !exists {
+		return false
+	}
+	delete(g.pending, id)
+	delete(entry.consumer.pending, id)
+	return true
+}
+
+// sortedPendingIDs returns the IDs of a pending entries list in order
+func sortedPendingIDs(pending map[streamID]*pendingEntry) []streamID {
+	ids := make([]streamID, 0, len(pending))
+	for id := range pending {
+		ids = append(ids, id)
+	}
+	slices.SortFunc(ids, streamID.compare)
+	return ids
+}
+
+/*
+ 	* record returns the entry with the given ID, the stream lock must be held
+	* @param id streamID - the ID
+	* @return StreamRecord - the entry
+	* @return bool - true if it is in the stream
+*/
+func (s *stream) record(id streamID) (StreamRecord, bool) {
+	record := s.entries.get(id)
+	if record == nil {
+		return StreamRecord{}, false
+	}
+	return *record, true
+}
+
+/*
+ 	* recordsAfter returns the entries with an ID greater than after, the stream lock must be held
+	* @param after streamID - the ID to read after
+	* @param count int - the most entries to return, 0 for all of them
+	* @return []StreamRecord - the entries, in ID order
+*/
+func (s *stream) recordsAfter(after streamID, count int) []StreamRecord {
+	start, ok := after.next()
+	if !ok {
+		return nil
+	}
+	return s.recordsInRange(start, streamIDMax, count, false)
+}
+
+/*
+ 	* resolveGroupID parses the ID given to XGROUP CREATE and SETID, "$" being the last ID, the stream lock must be held
+	* @param id string - the ID
+	* @return streamID - the parsed ID
+	* @return error - ErrInvalidStreamIdArgument
+*/
+func (s *stream) resolveGroupID(id string) (streamID, error) {
+	if id == streamIDLast {
+		return s.lastId, nil
+	}
+	parsed, ok := parseStreamEntryID(id, 0)
+	if !ok {
+		return streamID{}, ErrInvalidStreamIdArgument
+	}
+	return parsed, nil
+}
+
+/*
+ 	* lockGroup looks up a consumer group and write locks its stream, the caller must unlock the stream when it is done
+	* @param streamName string - the name of the stream
+	* @param groupName string - the name of the group
+	* @return *stream - the stream, locked, nil if it doesn't exist
+	* @return *consumerGroup - the group, nil if it doesn't exist
+*/
+func (sm *streamManager) lockGroup(streamName, groupName string) (*stream, *consumerGroup) {
+	stream, exists := sm.getStream(streamName)
+	if !exists {
+		return nil, nil
+	}
+	stream.mu.Lock()
+	return stream, stream.groups[groupName]
+}
+
+/*
+ 	* xgroupCreate creates a consumer group
+	* @param streamName string - the name of the stream
+	* @param groupName string - the name of the group
+	* @param id string - the last delivered ID of the group, "$" for the last entry of the stream
+	* @param mkStream bool - create an empty stream if it doesn't exist
+	* @return error - ErrXGroupNoKey, ErrInvalidStreamIdArgument or ErrBusyGroup
+*/
+func (sm *streamManager) xgroupCreate(streamName, groupName, id string, mkStream bool) error {
+	if id != streamIDLast && !ValidStreamId(id) {
+		return ErrInvalidStreamIdArgument
+	}
+
+	var stream *stream
+	if mkStream {
+		stream = sm.getOrCreateStream(streamName)
+	} else {
+		var exists bool
+		if stream, exists = sm.getStream(streamName); !exists {
+			return ErrXGroupNoKey
+		}
+	}
+
+	stream.mu.Lock()
+	defer stream.mu.Unlock()
+
+	if _, exists := stream.groups[groupName]; exists {
+		return ErrBusyGroup
+	}
+
+	lastID, err := stream.resolveGroupID(id)
+	if err != nil {
+		return err
+	}
+	stream.groups[groupName] = newConsumerGroup(lastID)
+	return nil
+}
+
+/*
+ 	* xgroupSetID sets the last delivered ID of a consumer group
+	* @param streamName string - the name of the stream
+	* @param groupName string - the name of the group
+	* @param id string - the ID, "$" for the last entry of the stream
+	* @return error - ErrXGroupNoKey, the NOGROUP error or ErrInvalidStreamIdArgument
+*/
+func (sm *streamManager) xgroupSetID(streamName, groupName, id string) error {
+	stream, group := sm.lockGroup(streamName, groupName)
+	if stream == nil {
+		return ErrXGroupNoKey
+	}
+	defer stream.mu.Unlock()
+
+	if group == nil {
+		return errNoGroupForKey(streamName, groupName)
+	}
+
+	lastID, err := stream.resolveGroupID(id)
+	if err != nil {
+		return err
+	}
+	group.lastID = lastID
+	return nil
+}
+
+/*
+ 	* xgroupDestroy deletes a consumer group with its consumers and pending entries
+	* @param streamName string - the name of the stream
+	* @param groupName string - the name of the group
+	* @return bool - true if the group existed
+	* @return error - ErrXGroupNoKey
+*/
+func (sm *streamManager) xgroupDestroy(streamName, groupName string) (bool, error) {
+	stream, group := sm.lockGroup(streamName, groupName)
+	if stream == nil {
+		return false, ErrXGroupNoKey
+	}
+	defer stream.mu.Unlock()
+
+	if group == nil {
+		return false, nil
+	}
+	delete(stream.groups, groupName)
+
+	// the readers blocked on the group read again and find it gone
+	sm.notifyWaiters(streamName)
+	return true, nil
+}
+
+/*
+ 	* xgroupCreateConsumer creates a consumer in a consumer group
+	* @param streamName string - the name of the stream
+	* @param groupName string - the name of the group
+	* @param consumerName string - the name of the consumer
+	* @return bool - true if the consumer was created, false if it already existed
+	* @return error - ErrXGroupNoKey or the NOGROUP error
+*/
+func (sm *streamManager) xgroupCreateConsumer(streamName, groupName, consumerName string) (bool, error) {
+	stream, group := sm.lockGroup(streamName, groupName)
+	if stream == nil {
+		return false, ErrXGroupNoKey
+	}
+	defer stream.mu.Unlock()
+
+	if group == nil {
+		return false, errNoGroupForKey(streamName, groupName)
+	}
+	if _, exists := group.consumers[consumerName]; exists {
+		return false, nil
+	}
+	group.consumer(consumerName, time.Now())
+	return true, nil
+}
+
+/*
+ 	* xgroupDelConsumer deletes a consumer from a consumer group, its pending entries are dropped from the group
+	* @param streamName string - the name of the stream
+	* @param groupName string - the name of the group
+	* @param consumerName string - the name of the consumer
+	* @return int - the number of entries that were pending for the consumer
+	* @return error - ErrXGroupNoKey or the NOGROUP error
+*/
+func (sm *streamManager) xgroupDelConsumer(streamName, groupName, consumerName string) (int, error) {
+	stream, group := sm.lockGroup(streamName, groupName)
+	if stream == nil {
+		return 0, ErrXGroupNoKey
+	}
+	defer stream.mu.Unlock()
+
+	if group == nil {
+		return 0, errNoGroupForKey(streamName, groupName)
+	}
+
+	consumer, exists := group.consumers[consumerName]
+	if !exists {
+		return 0, nil
+	}
+	for id := range consumer.pending {
+		delete(group.pending, id)
+	}
+	delete(group.consumers, consumerName)
+	return len(consumer.pending), nil
+}
+
+/*
+ 	* xreadgroup reads from streams on behalf of a consumer of a group, creating the consumer if it doesn't exist.
+	* with the ID ">" it gets the entries never delivered to the group and moves them to the consumer's pending entries,
+	* with any other ID it gets the consumer's pending entries after that ID again
+	* @param groupName string - the name of the group
+	* @param consumerName string - the name of the consumer
+	* @param streamNames []string - the names of the streams
+	* @param ids []string - the ID to read after for each stream
+	* @param count int - the most entries to return per stream, 0 for all of them
+	* @param noAck bool - don't add the new entries to the pending entries, as if they were acknowledged right away
+	* @return []StreamEntries - the entries of each stream read with ">" that had new ones, and of every stream read with an ID
+	* @return error - the NOGROUP error or ErrInvalidStreamIdArgument
+*/
+func (sm *streamManager) xreadgroup(groupName, consumerName string, streamNames, ids []string, count int, noAck bool) ([]StreamEntries, error) {
+	// check every stream before reading any, so a failed read has no effect
+	for i, streamName := range streamNames {
+		if err := sm.checkReadGroup(streamName, groupName, ids[i]); err != nil {
+			return nil, err
+		}
+	}
+
+	var result []StreamEntries
+	for i, streamName := range streamNames {
+		records, err := sm.readGroup(streamName, groupName, consumerName, ids[i], count, noAck)
+		if err != nil {
+			return nil, err
+		}
+		if len(records) > 0 || ids[i] != ">" {
+			result = append(result, StreamEntries{Stream: streamName, Records: records})
+		}
+	}
+	return result, nil
+}
+
+/*
+ 	* checkReadGroup checks that XREADGROUP can read from a stream
+	* @param streamName string - the name of the stream
+	* @param groupName string - the name of the group
+	* @param id string - the ID to read after, or ">"
+	* @return error - the NOGROUP error or ErrInvalidStreamIdArgument
+*/
+func (sm *streamManager) checkReadGroup(streamName, groupName, id string) error {
+	if id != ">" && !ValidStreamId(id) {
+		return ErrInvalidStreamIdArgument
+	}
+
+	stream, group := sm.lockGroup(streamName, groupName)
+	if stream != nil {
+		stream.mu.Unlock()
+	}
+	if group == nil {
+		return fmt.Errorf("%w in XREADGROUP with GROUP option", errNoGroup(streamName, groupName))
+	}
+	return nil
+}
+
+/*
+ 	* readGroup is xreadgroup for a single stream
+	* @param streamName string - the name of the stream
+	* @param groupName string - the name of the group
+	* @param consumerName string - the name of the consumer
+	* @param id string - the ID to read after, or ">"
+	* @param count int - the most entries to return, 0 for all of them
+	* @param noAck bool - don't add the new entries to the pending entries
+	* @return []StreamRecord - the entries, a pending entry deleted from the stream has nil Data
+	* @return error - the NOGROUP error
+*/
+func (sm *streamManager) readGroup(streamName, groupName, consumerName, id string, count int, noAck bool) ([]StreamRecord, error) {
+	stream, group := sm.lockGroup(streamName, groupName)
+	if stream != nil {
+		defer stream.mu.Unlock()
+	}
+	if group == nil {
+		return nil, fmt.Errorf("%w in XREADGROUP with GROUP option", errNoGroup(streamName, groupName))
+	}
+
+	now := time.Now()
+	consumer := group.consumer(consumerName, now)
+
+	if id == ">" {
+		records := stream.recordsAfter(group.lastID, count)
+		for i := range records {
+			recordID := recordStreamID(&records[i])
+			group.lastID = recordID
+			if !noAck {
+				group.deliver(recordID, consumer, now)
+			}
+		}
+		if len(records) > 0 {
+			consumer.activeTime = now
+		}
+		return records, nil
+	}
+
+	after, _ := parseStreamEntryID(id, 0)
+	var records []StreamRecord
+	for _, pendingID := range sortedPendingIDs(consumer.pending) {
+		if count > 0 && len(records) >= count {
+			break
+		}
+		if pendingID.compare(after) <= 0 {
+			continue
+		}
+
+		record, exists := stream.record(pendingID)
+		if !exists {
+			records = append(records, StreamRecord{Id: pendingID.String()})
+			continue
+		}
+		entry := consumer.pending[pendingID]
+		entry.deliveryTime = now
+		entry.deliveryCount++
+		records = append(records, record)
+	}
+	return records, nil
+}
+
+/*
+ 	* xreadgroupBlock is xreadgroup that waits until one of the streams read with ">" gets new entries or the timeout passes.
+	* a read of pending entries never waits
+	* @param groupName string - the name of the group
+	* @param consumerName string - the name of the consumer
+	* @param streamNames []string - the names of the streams
+	* @param ids []string - the ID to read after for each stream
+	* @param count int - the most entries to return per stream, 0 for all of them
+	* @param noAck bool - don't add the new entries to the pending entries
+	* @param blockMs int - how long to wait for in milliseconds
+	* @param noTimeout bool - wait for as long as it takes
+	* @param held sync.Locker - a lock the caller holds, let go of while waiting and taken again before reading, nil for none
+	* @return []StreamEntries - the entries, nil if the timeout passed
+	* @return error - the NOGROUP error or ErrInvalidStreamIdArgument
+*/
+func (sm *streamManager) xreadgroupBlock(groupName, consumerName string, streamNames, ids []string, count int, noAck bool, blockMs int, noTimeout bool, held sync.Locker) ([]StreamEntries, error) {
+	if slices.ContainsFunc(ids, func(id string) bool { return id != ">" }) {
+		return sm.xreadgroup(groupName, consumerName, streamNames, ids, count, noAck)
+	}
+
+	for i, streamName := range streamNames {
+		if err := sm.checkReadGroup(streamName, groupName, ids[i]); err != nil {
+			return nil, err
+		}
+	}
+
+	var result []StreamEntries
+	err := sm.waitForEntries(streamNames, blockMs, noTimeout, held, func() (bool, error) {
+		var err error
+		result, err = sm.xreadgroup(groupName, consumerName, streamNames, ids, count, noAck)
+		return len(result) > 0, err
+	})
+	return result, err
+}
+
+/*
+ 	* xack acknowledges entries, removing them from the pending entries of a group
+	* @param streamName string - the name of the stream
+	* @param groupName string - the name of the group
+	* @param ids []string - the IDs of the entries
+	* @return int - the number of entries that were pending
+	* @return error - ErrInvalidStreamIdArgument
+*/
+func (sm *streamManager) xack(streamName, groupName string, ids []string) (int, error) {
+	parsed := make([]streamID, len(ids))
+	for i, id := range ids {
+		var ok bool
+		if parsed[i], ok = parseStreamEntryID(id, 0); !ok {
+			return 0, ErrInvalidStreamIdArgument
+		}
+	}
+
+	stream, group := sm.lockGroup(streamName, groupName)
+	if stream != nil {
+		defer stream.mu.Unlock()
+	}
+	if group == nil {
+		return 0, nil
+	}
+
+	acked := 0
+	for _, id := range parsed {
+		if group.ack(id) {
+			acked++
+		}
+	}
+	return acked, nil
+}
+
+/*
+ 	* xpending summarizes the pending entries of a group
+	* @param streamName string - the name of the stream
+	* @param groupName string - the name of the group
+	* @return StreamPendingSummary - the summary
+	* @return error - the NOGROUP error
+*/
+func (sm *streamManager) xpending(streamName, groupName string) (StreamPendingSummary, error) {
+	stream, group := sm.lockGroup(streamName, groupName)
+	if stream != nil {
+		defer stream.mu.Unlock()
+	}
+	if group == nil {
+		return StreamPendingSummary{}, errNoGroup(streamName, groupName)
+	}
+
+	summary := StreamPendingSummary{Count: len(group.pending)}
+	if len(group.pending) == 0 {
+		return summary, nil
+	}
+
+	first := true
+	var minID, maxID streamID
+	for id := range group.pending {
+		if first || id.compare(minID) < 0 {
+			minID = id
+		}
+		if first || id.compare(maxID) > 0 {
+			maxID = id
+		}
+		first = false
+	}
+	summary.MinId, summary.MaxId = minID.String(), maxID.String()
+
+	for name, consumer := range group.consumers {
+		if len(consumer.pending) > 0 {
+			summary.Consumers = append(summary.Consumers, StreamConsumerPending{Name: name, Count: len(consumer.pending)})
+		}
+	}
+	sort.Slice(summary.Consumers, func(i, j int) bool { return summary.Consumers[i].Name < summary.Consumers[j].Name })
+	return summary, nil
+}
+
+/*
+ 	* xpendingRange lists the pending entries of a group within a range of IDs
+	* @param streamName string - the name of the stream
+	* @param groupName string - the name of the group
+	* @param start string - the start of the range, see parseStreamRangeID
+	* @param end string - the end of the range
+	* @param count int - the most entries to return
+	* @param consumerName string - only list the entries of this consumer, empty for every consumer
+	* @param minIdle time.Duration - only list the entries delivered at least this long ago
+	* @return []StreamPendingEntry - the entries, in ID order
+	* @return error - the NOGROUP error or an error parsing the range
+*/
+func (sm *streamManager) xpendingRange(streamName, groupName, start, end string, count int, consumerName string, minIdle time.Duration) ([]StreamPendingEntry, error) {
+	startID, err := parseStreamRangeID(start, false)
+	if err != nil {
+		return nil, err
+	}
+	endID, err := parseStreamRangeID(end, true)
+	if err != nil {
+		return nil, err
+	}
+
+	stream, group := sm.lockGroup(streamName, groupName)
+	if stream != nil {
+		defer stream.mu.Unlock()
+	}
+	if group == nil {
+		return nil, errNoGroup(streamName, groupName)
+	}
+
+	pending := group.pending
+	if consumerName != "" {
+		consumer, exists := group.consumers[consumerName]
+		if !exists {
+			return nil, nil
+		}
+		pending = consumer.pending
+	}
+
+	now := time.Now()
+	var entries []StreamPendingEntry
+	for _, id := range sortedPendingIDs(pending) {
+		if len(entries) >= count || id.compare(endID) > 0 {
+			break
+		}
+		entry := pending[id]
+		if id.compare(startID) < 0 || now.Sub(entry.deliveryTime) < minIdle {
+			continue
+		}
+		entries = append(entries, StreamPendingEntry{
+			Id:            id.String(),
+			Consumer:      entry.consumer.name,
+			DeliveryTime:  entry.deliveryTime,
+			DeliveryCount: entry.deliveryCount,
+		})
+	}
+	return entries, nil
+}
+
+/*
+ 	* xclaim moves pending entries to a consumer, creating the consumer if it doesn't exist. the pending entries that were
+	* deleted from the stream are dropped from the group instead
+	* @param streamName string - the name of the stream
+	* @param groupName string - the name of the group
+	* @param consumerName string - the name of the consumer
+	* @param ids []string - the IDs of the entries
+	* @param claim StreamClaim - the options
+	* @return []StreamRecord - the entries claimed
+	* @return error - the NOGROUP error or ErrInvalidStreamIdArgument
+*/
+func (sm *streamManager) xclaim(streamName, groupName, consumerName string, ids []string, claim StreamClaim) ([]StreamRecord, error) {
+	parsed := make([]streamID, len(ids))
+	for i, id := range ids {
+		var ok bool
+		if parsed[i], ok = parseStreamEntryID(id, 0); !ok {
+			return nil, ErrInvalidStreamIdArgument
+		}
+	}
+	var lastID streamID
+	if claim.LastId != "" {
+		var ok bool
+		if lastID, ok = parseStreamEntryID(claim.LastId, 0); !ok {
+			return nil, ErrInvalidStreamIdArgument
+		}
+	}
+
+	stream, group := sm.lockGroup(streamName, groupName)
+	if stream != nil {
+		defer stream.mu.Unlock()
+	}
+	if group == nil {
+		return nil, errNoGroup(streamName, groupName)
+	}
+
+	if claim.LastId != "" && lastID.compare(group.lastID) > 0 {
+		group.lastID = lastID
+	}
+
+	now := time.Now()
+	deliveryTime := claim.DeliveryTime
+	if deliveryTime.IsZero() || deliveryTime.After(now) {
+		deliveryTime = now
+	}
+
+	var consumer *streamConsumer
+	var claimed []StreamRecord
+	for _, id := range parsed {
+		entry, pending := group.pending[id]
+
+		record, exists := stream.record(id)
+		if !exists {
+			if pending {
+				group.ack(id)
+			}
+			continue
+		}
+
+		if !pending {
+			if !claim.Force {
+				continue
+			}
+			entry = &pendingEntry{}
+			group.pending[id] = entry
+		}
+
+		// an entry FORCE just added has no consumer and no idle time to check
+		if entry.consumer != nil && now.Sub(entry.deliveryTime) < claim.MinIdle {
+			continue
+		}
+
+		if consumer == nil {
+			consumer = group.consumer(consumerName, now)
+		}
+		group.claim(id, entry, consumer)
+		entry.deliveryTime = deliveryTime
+		if claim.RetryCount >= 0 {
+			entry.deliveryCount = claim.RetryCount
+		} else if !claim.JustId {
+			entry.deliveryCount++
+		}
+		consumer.activeTime = now
+
+		claimed = append(claimed, record)
+	}
+	return claimed, nil
+}
+
+/*
+ 	* xautoclaim moves the pending entries idle for at least minIdle to a consumer, scanning the group's pending entries
+	* from start like SCAN does. the pending entries that were deleted from the stream are dropped from the group
+	* @param streamName string - the name of the stream
+	* @param groupName string - the name of the group
+	* @param consumerName string - the name of the consumer
+	* @param minIdle time.Duration - only claim the entries delivered at least this long ago
+	* @param start string - the ID to start scanning from, "(" before it to start after it
+	* @param count int - the most entries to claim, at most 10 times as many are looked at
+	* @param justId bool - leave the delivery counts alone
+	* @return string - the ID to start the next call from, "0-0" once the whole list was scanned
+	* @return []StreamRecord - the entries claimed
+	* @return []string - the IDs of the entries dropped because they were deleted from the stream
+	* @return error - the NOGROUP error or an error parsing start
+*/
+func (sm *streamManager) xautoclaim(streamName, groupName, consumerName string, minIdle time.Duration, start string, count int, justId bool) (string, []StreamRecord, []string, error) {
+	startID, err := parseStreamRangeID(start, false)
+	if err != nil {
+		return "", nil, nil, err
+	}
+
+	stream, group := sm.lockGroup(streamName, groupName)
+	if stream != nil {
+		defer stream.mu.Unlock()
+	}
+	if group == nil {
+		return "", nil, nil, errNoGroup(streamName, groupName)
+	}
+
+	now := time.Now()
+	var consumer *streamConsumer
+	var claimed []StreamRecord
+	var deleted []string
+
+	ids := sortedPendingIDs(group.pending)
+	i, _ := slices.BinarySearchFunc(ids, startID, streamID.compare)
+
+	attempts := count * 10
+	for ; i < len(ids) && attempts > 0 && count > 0; i++ {
+		attempts--
+		id := ids[i]
+		entry := group.pending[id]
+
+		record, exists := stream.record(id)
+		if !exists {
+			group.ack(id)
+			deleted = append(deleted, id.String())
+			count--
+			continue
+		}
+
+		if now.Sub(entry.deliveryTime) < minIdle {
+			continue
+		}
+
+		if consumer == nil {
+			consumer = group.consumer(consumerName, now)
+		}
+		group.claim(id, entry, consumer)
+		entry.deliveryTime = now
+		if !justId {
+			entry.deliveryCount++
+		}
+		consumer.activeTime = now
+
+		claimed = append(claimed, record)
+		count--
+	}
+
+	next := streamID{}
+	if i < len(ids) {
+		next = ids[i]
+	}
+	return next.String(), claimed, deleted, nil
+}
+
+/*
+ 	* groupSnapshots copies the consumer groups of the stream, the stream lock must be held
+	* @return []StreamGroupSnapshot - the groups, by name
+*/
+func (s *stream) groupSnapshots() []StreamGroupSnapshot {
+	snapshots := make([]StreamGroupSnapshot, 0, len(s.groups))
+	for name, group := range s.groups {
+		snapshot := StreamGroupSnapshot{Name: name, LastId: group.lastID.String(), Consumers: group.consumerSnapshots()}
+
+		for _, id := range sortedPendingIDs(group.pending) {
+			entry := group.pending[id]
+			snapshot.Pending = append(snapshot.Pending, StreamPendingEntry{
+				Id:            id.String(),
+				Consumer:      entry.consumer.name,
+				DeliveryTime:  entry.deliveryTime,
+				DeliveryCount: entry.deliveryCount,
+			})
+		}
+
+		snapshots = append(snapshots, snapshot)
+	}
+	sort.Slice(snapshots, func(i, j int) bool { return snapshots[i].Name < snapshots[j].Name })
+	return snapshots
+}
+
+// consumerSnapshots returns copies of the consumers of the group, by name
+func (g *consumerGroup) consumerSnapshots() []StreamConsumerSnapshot {
+	snapshots := make([]StreamConsumerSnapshot, 0, len(g.consumers))
+	for name, consumer := range g.consumers {
+		snapshots = append(snapshots, StreamConsumerSnapshot{
+			Name:       name,
+			SeenTime:   consumer.seenTime,
+			ActiveTime: consumer.activeTime,
+			Pending:    len(consumer.pending),
+		})
+	}
+	sort.Slice(snapshots, func(i, j int) bool { return snapshots[i].Name < snapshots[j].Name })
+	return snapshots
+}
+
+/*
+ 	* restoreGroups adds consumer groups loaded from disk to a stream, creating the stream if it doesn't exist,
+	* entries pending for a consumer that isn't listed create it
+	* @param streamName string - the name of the stream
+	* @param groups []StreamGroupSnapshot - the groups
+*/
+func (sm *streamManager) restoreGroups(streamName string, groups []StreamGroupSnapshot) {
+	stream := sm.getOrCreateStream(streamName)
+	stream.mu.Lock()
+	defer stream.mu.Unlock()
+
+	for _, snapshot := range groups {
+		lastID, _ := parseStreamEntryID(snapshot.LastId, 0)
+		group := newConsumerGroup(lastID)
+
+		for _, consumer := range snapshot.Consumers {
+			group.consumer(consumer.Name, consumer.SeenTime).activeTime = consumer.ActiveTime
+		}
+		for _, pending := range snapshot.Pending {
+			id, ok := parseStreamEntryID(pending.Id, 0)
+			if !ok {
+				continue
+			}
+			consumer, exists := group.consumers[pending.Consumer]
+			if !exists {
+				consumer = group.consumer(pending.Consumer, pending.DeliveryTime)
+			}
+			entry := &pendingEntry{consumer: consumer, deliveryTime: pending.DeliveryTime, deliveryCount: pending.DeliveryCount}
+			group.pending[id] = entry
+			consumer.pending[id] = entry
+		}
+
+		stream.groups[snapshot.Name] = group
+	}
+}
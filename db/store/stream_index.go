@@ -0,0 +1,333 @@
+package store
+
+import "sort"
+
+// the entries of a stream are kept in a B-tree ordered by ID, so a range can start at any ID, the ID of an entry or not,
+// after O(log n) comparisons, and an entry can be deleted from anywhere in the stream without walking up to it
+const (
+	streamIndexDegree     = 32                      // every node but the root holds between degree-1 and 2*degree-1 entries
+	streamIndexMinRecords = streamIndexDegree - 1   // fewer and the node borrows from or merges with a sibling
+	streamIndexMaxRecords = 2*streamIndexDegree - 1 // more and the node is split in two
+)
+
+type streamIndexNode struct {
+	records  []*StreamRecord    // ordered by ID
+	children []*streamIndexNode // empty for a leaf, else one more than the records, children[i] holding the IDs before records[i]
+}
+
+type streamIndex struct {
+	root   *streamIndexNode // never nil, a leaf with no entries when the stream is empty
+	length int
+	nodes  int
+}
+
+func newStreamIndex() *streamIndex {
+	return &streamIndex{root: &streamIndexNode{}, nodes: 1}
+}
+
+/*
+ 	* find looks for an ID among the entries of a node
+	* @param id streamID - the ID
+	* @return int - the index of the first entry with an ID not below id, len(records) if there is none
+	* @return bool - true if that entry has this very ID
+*/
+func (n *streamIndexNode) find(id streamID) (int, bool) {
+	i := sort.Search(len(n.records), func(i int) bool {
+		return recordStreamID(n.records[i]).compare(id) >= 0
+	})
+	return i, i < len(n.records) && recordStreamID(n.records[i]) == id
+}
+
+func (n *streamIndexNode) leaf() bool {
+	return len(n.children) == 0
+}
+
+/*
+ 	* split moves the entries after the i-th, and the children after them, to a new node
+	* @param i int - the index of the entry that goes up to the parent
+	* @return *StreamRecord - that entry
+	* @return *streamIndexNode - the new node, to go right after n
+*/
+func (n *streamIndexNode) split(i int) (*StreamRecord, *streamIndexNode) {
+	record := n.records[i]
+	next := &streamIndexNode{records: append([]*StreamRecord(nil), n.records[i+1:]...)}
+	clear(n.records[i:])
+	n.records = n.records[:i]
+
+	if !n.leaf() {
+		next.children = append([]*streamIndexNode(nil), n.children[i+1:]...)
+		clear(n.children[i+1:])
+		n.children = n.children[:i+1]
+	}
+	return record, next
+}
+
+/*
+ 	* get returns the entry with the given ID
+	* @param id streamID - the ID
+	* @return *StreamRecord - the entry, nil if there is none
+*/
+func (idx *streamIndex) get(id streamID) *StreamRecord {
+	for n := idx.root; ; {
+		i, found := n.find(id)
+		if found {
+			return n.records[i]
+		}
+		if n.leaf() {
+			return nil
+		}
+		n = n.children[i]
+	}
+}
+
+// first returns the entry with the smallest ID, nil if the stream is empty
+func (idx *streamIndex) first() *StreamRecord {
+	n := idx.root
+	for !n.leaf() {
+		n = n.children[0]
+	}
+	if len(n.records) == 0 {
+		return nil
+	}
+	return n.records[0]
+}
+
+// last returns the entry with the greatest ID, nil if the stream is empty
+func (idx *streamIndex) last() *StreamRecord {
+	n := idx.root
+	for !n.leaf() {
+		n = n.children[len(n.children)-1]
+	}
+	if len(n.records) == 0 {
+		return nil
+	}
+	return n.records[len(n.records)-1]
+}
+
+/*
+ 	* insert adds an entry, full nodes are split on the way down so there is always room for it in the leaf it lands in
+	* @param record *StreamRecord - the entry, replacing the one with the same ID if there is one
+*/
+func (idx *streamIndex) insert(record *StreamRecord) {
+	if len(idx.root.records) >= streamIndexMaxRecords {
+		middle, next := idx.root.split(streamIndexMaxRecords / 2)
+		idx.root = &streamIndexNode{
+			records:  []*StreamRecord{middle},
+			children: []*streamIndexNode{idx.root, next},
+		}
+		idx.nodes += 2
+	}
+
+	id := recordStreamID(record)
+	for n := idx.root; ; {
+		i, found := n.find(id)
+		if found {
+			n.records[i] = record
+			return
+		}
+		if n.leaf() {
+			n.records = append(n.records, nil)
+			copy(n.records[i+1:], n.records[i:])
+			n.records[i] = record
+			idx.length++
+			return
+		}
+
+		if child := n.children[i]; len(child.records) >= streamIndexMaxRecords {
+			middle, next := child.split(streamIndexMaxRecords / 2)
+			n.records = append(n.records, nil)
+			copy(n.records[i+1:], n.records[i:])
+			n.records[i] = middle
+			n.children = append(n.children, nil)
+			copy(n.children[i+2:], n.children[i+1:])
+			n.children[i+1] = next
+			idx.nodes++
+
+			switch recordStreamID(middle).compare(id) {
+			case 0:
+				n.records[i] = record
+				return
+			case -1:
+				i++
+			}
+		}
+		n = n.children[i]
+	}
+}
+
+/*
+ 	* delete removes the entry with the given ID
+	* @param id streamID - the ID
+	* @return bool - true if there was one
+*/
+func (idx *streamIndex) delete(id streamID) bool {
+	deleted := idx.root.delete(idx, id)
+	if len(idx.root.records) == 0 && !idx.root.leaf() {
+		idx.root = idx.root.children[0]
+		idx.nodes--
+	}
+	if deleted {
+		idx.length--
+	}
+	return deleted
+}
+
+/*
+ 	* delete removes an entry from the subtree of a node, which holds more than the fewest entries allowed unless it
+	* is the root, so that taking one out of it or handing one down to a child never leaves it with too few
+	* @param idx *streamIndex - the index, to keep count of the nodes
+	* @param id streamID - the ID of the entry
+	* @return bool - true if there was one
+*/
+func (n *streamIndexNode) delete(idx *streamIndex, id streamID) bool {
+	i, found := n.find(id)
+	if n.leaf() {
+		if !found {
+			return false
+		}
+		copy(n.records[i:], n.records[i+1:])
+		n.records[len(n.records)-1] = nil
+		n.records = n.records[:len(n.records)-1]
+		return true
+	}
+
+	if len(n.children[i].records) <= streamIndexMinRecords {
+		// the entry may move while the child is topped up, so look for it again afterwards
+		n.growChild(idx, i)
+		return n.delete(idx, id)
+	}
+
+	if found {
+		// the greatest entry of the left subtree takes the place of the one deleted
+		n.records[i] = n.children[i].deleteLast(idx)
+		return true
+	}
+	return n.children[i].delete(idx, id)
+}
+
+/*
+ 	* deleteLast removes the entry with the greatest ID from the subtree of a node, which holds more than the fewest entries allowed
+	* @param idx *streamIndex - the index, to keep count of the nodes
+	* @return *StreamRecord - the entry
+*/
+func (n *streamIndexNode) deleteLast(idx *streamIndex) *StreamRecord {
+	if n.leaf() {
+		record := n.records[len(n.records)-1]
+		n.records[len(n.records)-1] = nil
+		n.records = n.records[:len(n.records)-1]
+		return record
+	}
+
+	i := len(n.children) - 1
+	if len(n.children[i].records) <= streamIndexMinRecords {
+		n.growChild(idx, i)
+		return n.deleteLast(idx)
+	}
+	return n.children[i].deleteLast(idx)
+}
+
+/*
+ 	* growChild gives a child with the fewest entries allowed one more, borrowed through n from a sibling that can spare one,
+	* or else merges it with a sibling and the entry between them
+	* @param idx *streamIndex - the index, to keep count of the nodes
+	* @param i int - the index of the child
+*/
+func (n *streamIndexNode) growChild(idx *streamIndex, i int) {
+	child := n.children[i]
+
+	if i > 0 && len(n.children[i-1].records) > streamIndexMinRecords {
+		left := n.children[i-1]
+		child.records = append([]*StreamRecord{n.records[i-1]}, child.records...)
+		n.records[i-1] = left.records[len(left.records)-1]
+		left.records[len(left.records)-1] = nil
+		left.records = left.records[:len(left.records)-1]
+		if !left.leaf() {
+			child.children = append([]*streamIndexNode{left.children[len(left.children)-1]}, child.children...)
+			left.children[len(left.children)-1] = nil
+			left.children = left.children[:len(left.children)-1]
+		}
+		return
+	}
+
+	if i < len(n.records) && len(n.children[i+1].records) > streamIndexMinRecords {
+		right := n.children[i+1]
+		child.records = append(child.records, n.records[i])
+		n.records[i] = right.records[0]
+		right.records = append(right.records[:0], right.records[1:]...)
+		if !right.leaf() {
+			child.children = append(child.children, right.children[0])
+			right.children = append(right.children[:0], right.children[1:]...)
+		}
+		return
+	}
+
+	// neither sibling can spare an entry, merge the child with the one on its right, or with the one on its left if it is the last
+	if i == len(n.records) {
+		i--
+		child = n.children[i]
+	}
+	right := n.children[i+1]
+	child.records = append(append(child.records, n.records[i]), right.records...)
+	child.children = append(child.children, right.children...)
+
+	copy(n.records[i:], n.records[i+1:])
+	n.records[len(n.records)-1] = nil
+	n.records = n.records[:len(n.records)-1]
+	copy(n.children[i+1:], n.children[i+2:])
+	n.children[len(n.children)-1] = nil
+	n.children = n.children[:len(n.children)-1]
+	idx.nodes--
+}
+
+/*
+ 	* ascend calls fn with the entries from the first one with an ID not below from, in ID order, until it returns false
+	* @param from streamID - the ID to start at
+	* @param fn func(*StreamRecord) bool - called with each entry, false to stop
+*/
+func (idx *streamIndex) ascend(from streamID, fn func(*StreamRecord) bool) {
+	idx.root.ascend(from, fn)
+}
+
+func (n *streamIndexNode) ascend(from streamID, fn func(*StreamRecord) bool) bool {
+	i, _ := n.find(from)
+	for ; i < len(n.records); i++ {
+		if !n.leaf() && !n.children[i].ascend(from, fn) {
+			return false
+		}
+		if !fn(n.records[i]) {
+			return false
+		}
+	}
+	if !n.leaf() {
+		return n.children[len(n.children)-1].ascend(from, fn)
+	}
+	return true
+}
+
+/*
+ 	* descend calls fn with the entries from the last one with an ID not above from, backwards, until it returns false
+	* @param from streamID - the ID to start at
+	* @param fn func(*StreamRecord) bool - called with each entry, false to stop
+*/
+func (idx *streamIndex) descend(from streamID, fn func(*StreamRecord) bool) {
+	idx.root.descend(from, fn)
+}
+
+func (n *streamIndexNode) descend(from streamID, fn func(*StreamRecord) bool) bool {
+	i, found := n.find(from)
+	if found && !fn(n.records[i]) {
+		return false
+	}
+	if !n.leaf() && !n.children[i].descend(from, fn) {
+		return false
+	}
+	for i--; i >= 0; i-- {
+		if !fn(n.records[i]) {
+			return false
+		}
+		if !n.leaf() && !n.children[i].descend(from, fn) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,202 @@
+package store
+
+import (
+	"math/rand"
+	"testing"
+)
+
+func testRecord(ms int64) *StreamRecord {
+	return &StreamRecord{millisecondsTime: ms}
+}
+
+// checkStreamIndex fails the test if the B-tree is not balanced, has a node too full or too empty, or is out of order
+func checkStreamIndex(t *testing.T, idx *streamIndex) {
+	t.Helper()
+
+	leafDepth, records, nodes := -1, 0, 0
+	var previous *StreamRecord
+	var walk func(n *streamIndexNode, depth int)
+	walk = func(n *streamIndexNode, depth int) {
+		nodes++
+		if len(n.records) > streamIndexMaxRecords {
+			t.Fatalf("a node holds %d entries, at most %d allowed", len(n.records), streamIndexMaxRecords)
+		}
+		if n != idx.root && len(n.records) < streamIndexMinRecords {
+			t.Fatalf("a node holds %d entries, at least %d needed", len(n.records), streamIndexMinRecords)
+		}
+
+		if n.leaf() {
+			if leafDepth == -1 {
+				leafDepth = depth
+			} else if depth != leafDepth {
+				t.Fatalf("leaves at depths %d and %d", leafDepth, depth)
+			}
+		} else if len(n.children) != len(n.records)+1 {
+			t.Fatalf("a node has %d entries and %d children", len(n.records), len(n.children))
+		}
+
+		for i, record := range n.records {
+			if !n.leaf() {
+				walk(n.children[i], depth+1)
+			}
+			if previous != nil && recordStreamID(previous).compare(recordStreamID(record)) >= 0 {
+				t.Fatalf("%v comes after %v", recordStreamID(record), recordStreamID(previous))
+			}
+			previous = record
+			records++
+		}
+		if !n.leaf() {
+			walk(n.children[len(n.children)-1], depth+1)
+		}
+	}
+	walk(idx.root, 0)
+
+	if records != idx.length {
+		t.Fatalf("the tree holds %d entries, its length says %d", records, idx.length)
+	}
+	if nodes != idx.nodes {
+		t.Fatalf("the tree has %d nodes, its count says %d", nodes, idx.nodes)
+	}
+}
+
+func TestStreamIndexInsertAndDelete(t *testing.T) {
+	tests := []struct {
+		name    string
+		entries int
+		remove  func(ms int64) bool // which of the entries, numbered 1 to entries, to delete
+	}{
+		{"empty", 0, func(int64) bool { return false }},
+		{"single leaf", 10, func(ms int64) bool { return ms%2 == 0 }},
+		{"every other", 5000, func(ms int64) bool { return ms%2 == 0 }},
+		{"front half", 5000, func(ms int64) bool { return ms <= 2500 }},
+		{"back half", 5000, func(ms int64) bool { return ms > 2500 }},
+		{"all", 5000, func(int64) bool { return true }},
+		{"all but one", 5000, func(ms int64) bool { return ms != 1234 }},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			idx := newStreamIndex()
+			for ms := int64(1); ms <= int64(test.entries); ms++ {
+				idx.insert(testRecord(ms))
+			}
+			checkStreamIndex(t, idx)
+
+			kept := 0
+			for ms := int64(1); ms <= int64(test.entries); ms++ {
+				if !test.remove(ms) {
+					kept++
+					continue
+				}
+				if !idx.delete(streamID{ms: uint64(ms)}) {
+					t.Fatalf("delete(%d-0) found nothing", ms)
+				}
+			}
+			checkStreamIndex(t, idx)
+
+			if idx.length != kept {
+				t.Fatalf("length %d after the deletes, want %d", idx.length, kept)
+			}
+			if idx.delete(streamID{ms: uint64(test.entries + 1)}) {
+				t.Fatalf("delete of a missing ID reported an entry")
+			}
+			for ms := int64(1); ms <= int64(test.entries); ms++ {
+				if got := idx.get(streamID{ms: uint64(ms)}) != nil; got == test.remove(ms) {
+					t.Fatalf("get(%d-0) found=%v after the deletes", ms, got)
+				}
+			}
+		})
+	}
+}
+
+func TestStreamIndexRandomDeletes(t *testing.T) {
+	random := rand.New(rand.NewSource(1))
+	idx := newStreamIndex()
+	present := make(map[int64]bool)
+	for _, ms := range random.Perm(3000) {
+		idx.insert(testRecord(int64(ms)))
+		present[int64(ms)] = true
+	}
+
+	for _, ms := range random.Perm(3000)[:2000] {
+		if !idx.delete(streamID{ms: uint64(ms)}) {
+			t.Fatalf("delete(%d-0) found nothing", ms)
+		}
+		delete(present, int64(ms))
+	}
+	checkStreamIndex(t, idx)
+
+	if idx.length != len(present) {
+		t.Fatalf("length %d, want %d", idx.length, len(present))
+	}
+}
+
+func TestStreamIndexSeek(t *testing.T) {
+	idx := newStreamIndex()
+	for ms := int64(10); ms <= 10000; ms += 10 {
+		idx.insert(testRecord(ms))
+	}
+
+	tests := []struct {
+		name      string
+		from      streamID
+		ascFirst  int64 // the first entry ascend visits, 0 for none
+		descFirst int64 // the first entry descend visits, 0 for none
+	}{
+		{"before the first", streamID{ms: 0}, 10, 0},
+		{"an entry", streamID{ms: 500}, 500, 500},
+		{"between entries", streamID{ms: 505}, 510, 500},
+		{"same ms, later seq", streamID{ms: 500, seq: 1}, 510, 500},
+		{"the last", streamID{ms: 10000}, 10000, 10000},
+		{"after the last", streamIDMax, 0, 10000},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var ascFirst, descFirst int64
+			idx.ascend(test.from, func(record *StreamRecord) bool {
+				ascFirst = record.millisecondsTime
+				return false
+			})
+			idx.descend(test.from, func(record *StreamRecord) bool {
+				descFirst = record.millisecondsTime
+				return false
+			})
+
+			if ascFirst != test.ascFirst {
+				t.Errorf("ascend(%v) started at %d, want %d", test.from, ascFirst, test.ascFirst)
+			}
+			if descFirst != test.descFirst {
+				t.Errorf("descend(%v) started at %d, want %d", test.from, descFirst, test.descFirst)
+			}
+		})
+	}
+
+	// a full walk visits every entry in order, both ways
+	visited := 0
+	var previous int64
+	idx.ascend(streamID{}, func(record *StreamRecord) bool {
+		if record.millisecondsTime <= previous {
+			t.Fatalf("ascend visited %d after %d", record.millisecondsTime, previous)
+		}
+		previous = record.millisecondsTime
+		visited++
+		return true
+	})
+	if visited != idx.length {
+		t.Errorf("ascend visited %d entries, want %d", visited, idx.length)
+	}
+
+	visited, previous = 0, 1<<62
+	idx.descend(streamIDMax, func(record *StreamRecord) bool {
+		if record.millisecondsTime >= previous {
+			t.Fatalf("descend visited %d after %d", record.millisecondsTime, previous)
+		}
+		previous = record.millisecondsTime
+		visited++
+		return true
+	})
+	if visited != idx.length {
+		t.Errorf("descend visited %d entries, want %d", visited, idx.length)
+	}
+}
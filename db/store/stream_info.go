@@ -0,0 +1,90 @@
+package store
+
+// StreamInfo is what XINFO STREAM reports about a stream
+type StreamInfo struct {
+	Length     int
+	IndexKeys  int    // the entries in the B-tree of the stream, reported as radix-tree-keys
+	IndexNodes int    // the nodes of that B-tree, reported as radix-tree-nodes
+	LastId     string // the greatest ID ever added
+	Groups     []StreamGroupSnapshot
+	First      *StreamRecord  // nil if the stream is empty
+	Last       *StreamRecord  // nil if the stream is empty
+	Entries    []StreamRecord // with full, the first entries
+}
+
+/*
+ 	* xinfoStream describes a stream
+	* @param streamName string - the name of the stream
+	* @param full bool - include the entries, and the groups with their pending entries and consumers
+	* @param count int - with full, the most entries to include, 0 for all of them
+	* @return StreamInfo - the description
+	* @return error - ErrNoSuchKey
+*/
+func (sm *streamManager) xinfoStream(streamName string, full bool, count int) (StreamInfo, error) {
+	stream, exists := sm.getStream(streamName)
+	if !exists {
+		return StreamInfo{}, ErrNoSuchKey
+	}
+
+	stream.mu.RLock()
+	defer stream.mu.RUnlock()
+
+	info := StreamInfo{
+		Length:     stream.entries.length,
+		IndexKeys:  stream.entries.length,
+		IndexNodes: stream.entries.nodes,
+		LastId:     stream.lastId.String(),
+		Groups:     stream.groupSnapshots(),
+	}
+
+	if full {
+		info.Entries = stream.recordsInRange(streamID{}, streamIDMax, count, false)
+		return info, nil
+	}
+
+	if first := stream.entries.first(); first != nil {
+		firstRecord, lastRecord := *first, *stream.entries.last()
+		info.First, info.Last = &firstRecord, &lastRecord
+	}
+	return info, nil
+}
+
+/*
+ 	* xinfoGroups describes the consumer groups of a stream
+	* @param streamName string - the name of the stream
+	* @return []StreamGroupSnapshot - the groups, by name
+	* @return error - ErrNoSuchKey
+*/
+func (sm *streamManager) xinfoGroups(streamName string) ([]StreamGroupSnapshot, error) {
+	stream, exists := sm.getStream(streamName)
+	if !exists {
+		return nil, ErrNoSuchKey
+	}
+
+	stream.mu.RLock()
+	defer stream.mu.RUnlock()
+	return stream.groupSnapshots(), nil
+}
+
+/*
+ 	* xinfoConsumers describes the consumers of a group
+	* @param streamName string - the name of the stream
+	* @param groupName string - the name of the group
+	* @return []StreamConsumerSnapshot - the consumers, by name
+	* @return error - ErrNoSuchKey, or NOGROUP if the group doesn't exist
+*/
+func (sm *streamManager) xinfoConsumers(streamName, groupName string) ([]StreamConsumerSnapshot, error) {
+	stream, exists := sm.getStream(streamName)
+	if !exists {
+		return nil, ErrNoSuchKey
+	}
+
+	stream.mu.RLock()
+	defer stream.mu.RUnlock()
+
+	group, exists := stream.groups[groupName]
+	if !exists {
+		return nil, errNoGroupForKey(streamName, groupName)
+	}
+	return group.consumerSnapshots(), nil
+}
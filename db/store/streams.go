@@ -1,17 +1,12 @@
 package store
 
 import (
-	"container/list"
 	"sync"
 	"time"
 )
 
 var streamManagerInstance *streamManager
 
-const (
-	defaultStreamMaxLen = 1000
-)
-
 type StreamRecord struct {
 	Id               string // store ID (Milliseconds-SequenceNumber), this combination is most probably done to make it monotonically increasing, not completely dependent on the time(due to time-of-the-day clock skew), not sure though
 	millisecondsTime int64
@@ -20,25 +15,29 @@ type StreamRecord struct {
 }
 
 type stream struct {
-	mu sync.RWMutex
-	// Maps record ID to its corresponding list element for O(1) lookups
-	recordMap   map[string]*list.Element   // Fast lookup of records by ID
-	recordList  *list.List                 // Doubly linked list for ordered storage
-	maxLen      int                        // Maximum number of entries to keep, also in REDIS
-	subscribers map[chan struct{}]struct{} // map of channels to notify of new entries, for faster lookups during sending and removing of subscribers
+	mu         sync.RWMutex
+	entries    *streamIndex              // the entries ordered by ID
+	lastId     streamID                  // the greatest ID ever added, deleting that entry doesn't lower it
+	groups     map[string]*consumerGroup // consumer groups by name
+	expiration time.Time                 // zero if the stream has no TTL, guarded by the streamManager lock and not the stream lock
 
 }
 
 type streamManager struct {
-	streams map[string]*stream // Map of stream names to Stream objects, for faster lookups
+	mu        sync.RWMutex        // guards the streams map and the expiration of every stream
+	streams   map[string]*stream  // Map of stream names to Stream objects, for faster lookups
+	expires   map[string]struct{} // streams that have an expiration, sampled by the active expire cycle
+	onExpire  func(key string)    // called with the lock held whenever an expired stream is deleted
+	scanOrder *scanIndex          // every stream name, in SCAN order
+
+	waitersMu sync.Mutex                            // taken after any other lock
+	waiters   map[string]map[chan struct{}]struct{} // the channels of the readers blocked on each stream name, whether the stream exists or not
 }
 
 func newStream() *stream {
 	return &stream{
-		recordMap:   make(map[string]*list.Element),
-		recordList:  list.New(),
-		maxLen:      defaultStreamMaxLen,
-		subscribers: make(map[chan struct{}]struct{}),
+		entries: newStreamIndex(),
+		groups:  make(map[string]*consumerGroup),
 	}
 }
 
@@ -55,25 +54,257 @@ func getStreamManager() *streamManager {
 
 func newStreamManager() *streamManager {
 	return &streamManager{
-		streams: make(map[string]*stream),
+		streams:   make(map[string]*stream),
+		expires:   make(map[string]struct{}),
+		waiters:   make(map[string]map[chan struct{}]struct{}),
+		scanOrder: newScanIndex(),
+	}
+}
+
+/*
+ 	* getKeys returns the names of all the streams that match the pattern
+	* @param pattern string - the glob-style pattern to match
+	* @return []string - the names of the streams
+*/
+func (sm *streamManager) getKeys(pattern string) []string {
+	sm.mu.RLock()
+	defer sm.mu.RUnlock()
+
+	now := time.Now()
+	var keys []string
+	for streamName, stream := range sm.streams {
+		if !stream.isExpired(now) && MatchGlob(pattern, streamName) {
+			keys = append(keys, streamName)
+		}
+	}
+
+	return keys
+}
+
+/*
+ 	* getStream returns the stream with the given name, an expired stream is deleted on the spot and reported as missing
+	* @param streamName string - the name of the stream
+	* @return *stream - the stream
+	* @return bool - true if the stream exists, false otherwise
+*/
+func (sm *streamManager) getStream(streamName string) (*stream, bool) {
+	sm.mu.RLock()
+	stream, exists := sm.streams[streamName]
+	expired := exists && stream.isExpired(time.Now())
+	sm.mu.RUnlock()
+
+	if !expired {
+		return stream, exists
+	}
+
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+	return sm.getStreamLocked(streamName, time.Now())
+}
+
+/*
+ 	* getStreamLocked is getStream for callers that already hold the write lock
+	* @param streamName string - the name of the stream
+	* @param now time.Time - the current time
+	* @return *stream - the stream
+	* @return bool - true if the stream exists, false otherwise
+*/
+func (sm *streamManager) getStreamLocked(streamName string, now time.Time) (*stream, bool) {
+	stream, exists := sm.streams[streamName]
+	if !exists {
+		return nil, false
+	}
+
+	if stream.isExpired(now) {
+		sm.removeExpired(streamName)
+		return nil, false
+	}
+
+	return stream, true
+}
+
+/*
+ 	* getOrCreateStream returns the stream with the given name, creating an empty one if it doesn't exist
+	* @param streamName string - the name of the stream
+	* @return *stream - the stream
+*/
+func (sm *streamManager) getOrCreateStream(streamName string) *stream {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+
+	stream, exists := sm.getStreamLocked(streamName, time.Now())
+	if !exists {
+		stream = newStream()
+		sm.put(streamName, stream)
+	}
+
+	return stream
+}
+
+// setExpiration sets or clears the expiration of a stream and keeps the expires index in sync, the write lock must be held
+func (sm *streamManager) setExpiration(streamName string, stream *stream, expiration time.Time) {
+	stream.expiration = expiration
+
+	if expiration.IsZero() {
+		delete(sm.expires, streamName)
+	} else {
+		sm.expires[streamName] = struct{}{}
+	}
+}
+
+// put stores the stream and keeps the scan index in sync, the write lock must be held
+func (sm *streamManager) put(streamName string, stream *stream) {
+	if _, exists := sm.streams[streamName]; !exists {
+		sm.scanOrder.insert(streamName)
+	}
+	sm.streams[streamName] = stream
+}
+
+// remove deletes the stream and its entries in the expires and scan indexes, the write lock must be held
+func (sm *streamManager) remove(streamName string) {
+	if _, exists := sm.streams[streamName]; exists {
+		sm.scanOrder.delete(streamName)
+	}
+	delete(sm.streams, streamName)
+	delete(sm.expires, streamName)
+}
+
+// removeExpired deletes an expired stream and lets watchers know, the write lock must be held
+func (sm *streamManager) removeExpired(streamName string) {
+	sm.remove(streamName)
+
+	if sm.onExpire != nil {
+		sm.onExpire(streamName)
+	}
+}
+
+/*
+ 	* clone returns a copy of the stream with copies of its entries and consumer groups, without the expiration
+	* @return *stream - the copy
+*/
+func (s *stream) clone() *stream {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	copied := newStream()
+	copied.lastId = s.lastId
+
+	s.entries.ascend(streamID{}, func(entry *StreamRecord) bool {
+		record := *entry
+
+		data := make(map[string][]byte, len(record.Data))
+		for field, value := range record.Data {
+			data[field] = value
+		}
+		record.Data = data
+
+		copied.entries.insert(&record)
+		return true
+	})
+
+	for name, group := range s.groups {
+		copied.groups[name] = group.clone()
+	}
+
+	return copied
+}
+
+/*
+ 	* isExpired checks if the stream has an expiration that has passed, the streamManager lock must be held
+	* @param now time.Time - the time to check against
+	* @return bool - true if the stream is expired, false otherwise
+*/
+func (s *stream) isExpired(now time.Time) bool {
+	return !s.expiration.IsZero() && now.After(s.expiration)
+}
+
+// StreamTrim is how XADD and XTRIM trim a stream, the oldest entries go first
+type StreamTrim struct {
+	MaxLen int64  // keep at most this many entries, when MinId is empty
+	MinId  string // drop the entries with a smaller ID, empty to trim by MaxLen
+	Approx bool   // ~, trimming may stop early and keep more entries than asked, at Limit entries removed
+	Limit  int64  // with Approx, the most entries to remove, 0 for no limit
+}
+
+/*
+ 	* parseMinId parses the MinId of a trim, an ID given as just "ms" being ms-0
+	* @return streamID - the ID
+	* @return error - ErrInvalidStreamIdArgument
+*/
+func (t StreamTrim) parseMinId() (streamID, error) {
+	if t.MinId == "" {
+		return streamID{}, nil
 	}
+	id, ok := parseStreamEntryID(t.MinId, 0)
+	if !ok {
+		return streamID{}, ErrInvalidStreamIdArgument
+	}
+	return id, nil
 }
 
 /*
- 	* xadd adds a new entry to a stream
+ 	* trim removes the oldest entries of the stream as a trim says, the stream lock must be held
+	* @param trim StreamTrim - how to trim
+	* @param minId streamID - the parsed MinId of the trim
+	* @return int - the number of entries removed
+*/
+func (s *stream) trim(trim StreamTrim, minId streamID) int {
+	removed := 0
+	for oldest := s.entries.first(); oldest != nil; oldest = s.entries.first() {
+		if trim.Approx && trim.Limit > 0 && int64(removed) >= trim.Limit {
+			break
+		}
+
+		if trim.MinId != "" {
+			if recordStreamID(oldest).compare(minId) >= 0 {
+				break
+			}
+		} else if int64(s.entries.length) <= trim.MaxLen {
+			break
+		}
+
+		s.entries.delete(recordStreamID(oldest))
+		removed++
+	}
+	return removed
+}
+
+/*
+ 	* xadd adds a new entry to a stream, the stream is only created once the ID is known to be valid
 	* @param streamName string - the name of the stream
 	* @param id string - the ID of the new entry
 	* @param data map[string][]byte - the data for the new entry
+	* @param noMkStream bool - don't create the stream if it doesn't exist
+	* @param trim *StreamTrim - how to trim the stream after adding the entry, nil to leave it as it is
 	* @return StreamRecord - the new entry
-	* @return bool - true if the entry was added successfully, false otherwise
+	* @return bool - true if the entry was added, false if the stream doesn't exist and noMkStream is set
 	* @return error - the error if there is one
 */
-func (sm *streamManager) xadd(streamName, id string, data map[string][]byte) (StreamRecord, bool, error) {
+func (sm *streamManager) xadd(streamName, id string, data map[string][]byte, noMkStream bool, trim *StreamTrim) (StreamRecord, bool, error) {
+	var minId streamID
+	if trim != nil {
+		var err error
+		if minId, err = trim.parseMinId(); err != nil {
+			return StreamRecord{}, false, err
+		}
+	}
 
-	if !sm.isStreamKey(streamName) {
-		sm.streams[streamName] = newStream()
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+
+	stream, exists := sm.getStreamLocked(streamName, time.Now())
+	if !exists && noMkStream {
+		return StreamRecord{}, false, nil
+	}
+
+	var lastId streamID
+	if exists {
+		stream.mu.Lock()
+		defer stream.mu.Unlock()
+		lastId = stream.lastId
 	}
-	valid, err := sm.verifyStreamId(streamName, id)
+
+	valid, err := sm.verifyStreamId(lastId, id)
 	if !valid {
 		return StreamRecord{}, false, err
 	}
@@ -83,198 +314,363 @@ func (sm *streamManager) xadd(streamName, id string, data map[string][]byte) (St
 		return StreamRecord{}, false, err
 	}
 
-	var newId string = id
 	var newMillisecondsTime int64 = millisecondsTime
 	var newSequenceNumber int = sequenceNumber
 
-	if wildcardNum == autoGeneratedTimeAndSeq {
-		newId, newMillisecondsTime, newSequenceNumber, err = sm.generateStreamId(streamName, id)
+	if wildcardNum == autoGeneratedTimeAndSeq || wildcardNum == autoGeneratedSeq {
+		_, newMillisecondsTime, newSequenceNumber, err = sm.generateStreamId(lastId, id)
 		if err != nil {
 			return StreamRecord{}, false, err
 		}
 	}
 
-	if wildcardNum == autoGeneratedSeq {
-		newId, newMillisecondsTime, newSequenceNumber, err = sm.generateStreamId(streamName, id)
-		if err != nil {
-			return StreamRecord{}, false, err
-		}
+	if !exists {
+		stream = newStream()
+		sm.put(streamName, stream)
+		stream.mu.Lock()
+		defer stream.mu.Unlock()
 	}
 
-	stream := sm.streams[streamName]
-	stream.mu.Lock()
-	defer stream.mu.Unlock()
-
 	newStreamRecord := StreamRecord{
-		Id:               newId,
 		millisecondsTime: newMillisecondsTime,
 		sequenceNumber:   newSequenceNumber,
 		Data:             data,
 	}
-	element := stream.recordList.PushBack(&newStreamRecord)
-	stream.recordMap[newId] = element
-
-	// remove the oldest entries when len exceeds the specified max length for a stream
-	if stream.recordList.Len() > stream.maxLen {
-		numToRemove := stream.recordList.Len() - stream.maxLen
-		for i := 0; i < numToRemove; i++ {
-			oldest := stream.recordList.Front()
-			if oldest != nil {
-				record := oldest.Value.(*StreamRecord)
-				delete(stream.recordMap, record.Id)
-				stream.recordList.Remove(oldest)
-			}
-		}
+	// always the canonical form, so that 1-01 and 1-1 are the same entry
+	newStreamRecord.Id = recordStreamID(&newStreamRecord).String()
+
+	stream.entries.insert(&newStreamRecord)
+	stream.lastId = recordStreamID(&newStreamRecord)
+
+	if trim != nil {
+		stream.trim(*trim, minId)
 	}
 
-	stream.notifySubscribers()
+	sm.notifyWaiters(streamName)
 
 	return newStreamRecord, true, nil
 }
 
 /*
- 	* xrange gets a range of entries from a stream
+ 	* xtrim trims a stream
 	* @param streamName string - the name of the stream
-	* @param startId string - the ID of the start of the range
-	* @param endId string - the ID of the end of the range
-	* @return []StreamRecord - the range of entries, inclusive of the start and end IDs
-	* @return error - the error if there is one
+	* @param trim StreamTrim - how to trim it
+	* @return int - the number of entries removed, 0 if the stream doesn't exist
+	* @return error - ErrInvalidStreamIdArgument
 */
-func (sm *streamManager) xrange(streamName, startId, endId string) ([]StreamRecord, error) {
-	if !sm.isStreamKey(streamName) {
-		return nil, ErrInvalidStream
+func (sm *streamManager) xtrim(streamName string, trim StreamTrim) (int, error) {
+	minId, err := trim.parseMinId()
+	if err != nil {
+		return 0, err
 	}
 
-	exists, err := sm.isValidStreamRecordIdExists(streamName, startId, operationTypeRange)
+	stream, exists := sm.getStream(streamName)
 	if !exists {
-		return nil, err
+		return 0, nil
 	}
 
-	exists, err = sm.isValidStreamRecordIdExists(streamName, endId, operationTypeRange)
-	if !exists {
-		return nil, err
-	}
+	stream.mu.Lock()
+	defer stream.mu.Unlock()
+	return stream.trim(trim, minId), nil
+}
 
-	stream := sm.streams[streamName]
+/*
+ 	* xdel deletes entries from a stream, they stay in the pending entries lists of the consumer groups
+	* @param streamName string - the name of the stream
+	* @param ids []string - the IDs of the entries
+	* @return int - the number of entries deleted
+	* @return error - ErrInvalidStreamIdArgument, before anything is deleted
+*/
+func (sm *streamManager) xdel(streamName string, ids []string) (int, error) {
+	parsed := make([]streamID, len(ids))
+	for i, id := range ids {
+		var ok bool
+		if parsed[i], ok = parseStreamEntryID(id, 0); !ok {
+			return 0, ErrInvalidStreamIdArgument
+		}
+	}
 
-	stream.mu.RLock()
-	defer stream.mu.RUnlock()
+	stream, exists := sm.getStream(streamName)
+	if !exists {
+		return 0, nil
+	}
 
-	var startElem *list.Element
-	var endElem *list.Element
+	stream.mu.Lock()
+	defer stream.mu.Unlock()
 
-	if startId == rangeQueryEnd || endId == rangeQueryStart {
-		return nil, ErrInvalidStreamId
+	deleted := 0
+	for _, id := range parsed {
+		if stream.entries.delete(id) {
+			deleted++
+		}
 	}
+	return deleted, nil
+}
 
-	if startId == rangeQueryStart {
-		startElem = stream.recordList.Front()
-	} else {
-		startElem = stream.recordMap[startId]
+/*
+ 	* xlen returns the number of entries in a stream
+	* @param streamName string - the name of the stream
+	* @return int - the number of entries, 0 if the stream doesn't exist
+*/
+func (sm *streamManager) xlen(streamName string) int {
+	stream, exists := sm.getStream(streamName)
+	if !exists {
+		return 0
 	}
 
-	if endId == rangeQueryEnd {
-		endElem = stream.recordList.Back()
-	} else {
-		endElem = stream.recordMap[endId]
-	}
+	stream.mu.RLock()
+	defer stream.mu.RUnlock()
+	return stream.entries.length
+}
 
-	var result []StreamRecord
-	current := startElem
+/*
+ 	* restoreLastId raises the last ID of a stream to the one it had when it was saved, creating the stream if needed,
+	* so that it keeps refusing the IDs of entries deleted before the save
+	* @param streamName string - the name of the stream
+	* @param id string - the last ID
+	* @return error - ErrInvalidStreamIdArgument
+*/
+func (sm *streamManager) restoreLastId(streamName, id string) error {
+	lastId, ok := parseStreamEntryID(id, 0)
+	if !ok {
+		return ErrInvalidStreamIdArgument
+	}
 
-	for current != nil {
-		record := current.Value.(*StreamRecord)
-		result = append(result, *record)
+	stream := sm.getOrCreateStream(streamName)
+	stream.mu.Lock()
+	defer stream.mu.Unlock()
 
-		if current == endElem {
-			break
-		}
-		current = current.Next()
+	if lastId.compare(stream.lastId) > 0 {
+		stream.lastId = lastId
 	}
-
-	return result, nil
+	return nil
 }
 
 /*
- 	* xread gets a range of entries from a stream, exclusive of the start ID
+ 	* xrange gets a range of entries from a stream
 	* @param streamName string - the name of the stream
-	* @param startId string - the ID of the start of the range
-	* @return []StreamRecord - the range of entries, exclusive of the start ID
-	* @return error - the error if there is one
+	* @param startId string - the start of the range, "-", an ID, or an ID prefixed by "(" to leave it out of the range
+	* @param endId string - the end of the range, "+", an ID, or an ID prefixed by "(" to leave it out of the range
+	* @param count int - the most entries to return, 0 for all of them
+	* @param rev bool - return the entries from the end of the range backwards, for XREVRANGE
+	* @return []StreamRecord - the range of entries, none if the stream doesn't exist
+	* @return error - ErrInvalidStreamIdArgument, or an error if an excluded bound leaves nothing before or after it
 */
-func (sm *streamManager) xread(streamName, startId string) ([]StreamRecord, error) {
-	if !sm.isStreamKey(streamName) {
-		return nil, ErrInvalidStream
+func (sm *streamManager) xrange(streamName, startId, endId string, count int, rev bool) ([]StreamRecord, error) {
+	start, err := parseStreamRangeID(startId, false)
+	if err != nil {
+		return nil, err
 	}
-
-	_, msTime, seqNum, err := sm.parseStreamId(startId)
+	end, err := parseStreamRangeID(endId, true)
 	if err != nil {
 		return nil, err
 	}
 
-	stream := sm.streams[streamName]
+	stream, exists := sm.getStream(streamName)
+	if !exists || start.compare(end) > 0 {
+		return nil, nil
+	}
 
 	stream.mu.RLock()
 	defer stream.mu.RUnlock()
 
-	var result []StreamRecord
+	return stream.recordsInRange(start, end, count, rev), nil
+}
 
-	var startElem *list.Element
-	var current *list.Element
+/*
+ 	* recordsInRange returns the entries with an ID between start and end, the stream lock must be held
+	* @param start streamID - the first ID of the range
+	* @param end streamID - the last ID of the range
+	* @param count int - the most entries to return, 0 for all of them
+	* @param rev bool - walk the range from end to start
+	* @return []StreamRecord - the entries
+*/
+func (s *stream) recordsInRange(start, end streamID, count int, rev bool) []StreamRecord {
+	var records []StreamRecord
 
-	if startId == streamIDMin {
-		startElem = stream.recordList.Front()
-		current = startElem
-	} else {
+	if rev {
+		s.entries.descend(end, func(record *StreamRecord) bool {
+			if recordStreamID(record).compare(start) < 0 {
+				return false
+			}
+			records = append(records, *record)
+			return count <= 0 || len(records) < count
+		})
+		return records
+	}
 
-		// find the first record that is greater than the startId
-		for rec := stream.recordList.Front(); rec != nil; rec = rec.Next() {
-			record := rec.Value.(*StreamRecord)
-			if record.millisecondsTime > msTime || (record.millisecondsTime == msTime && record.sequenceNumber > seqNum) {
-				current = rec
-				break
+	s.entries.ascend(start, func(record *StreamRecord) bool {
+		if recordStreamID(record).compare(end) > 0 {
+			return false
+		}
+		records = append(records, *record)
+		return count <= 0 || len(records) < count
+	})
+	return records
+}
+
+/*
+ 	* resolveReadIDs parses the IDs given to XREAD, "$" standing for the last ID of the stream, 0-0 if it doesn't exist yet
+	* @param streamNames []string - the names of the streams
+	* @param ids []string - the ID to read after in each stream, "ms" standing for ms-0
+	* @return []streamID - the parsed IDs
+	* @return error - ErrInvalidStreamIdArgument
+*/
+func (sm *streamManager) resolveReadIDs(streamNames, ids []string) ([]streamID, error) {
+	after := make([]streamID, len(ids))
+	for i, id := range ids {
+		if id != streamIDLast {
+			var ok bool
+			if after[i], ok = parseStreamEntryID(id, 0); !ok {
+				return nil, ErrInvalidStreamIdArgument
 			}
+			continue
+		}
+
+		if stream, exists := sm.getStream(streamNames[i]); exists {
+			stream.mu.RLock()
+			after[i] = stream.lastId
+			stream.mu.RUnlock()
 		}
 	}
+	return after, nil
+}
 
-	for current != nil {
-		record := current.Value.(*StreamRecord)
-		result = append(result, *record)
+/*
+ 	* xreadPosition resolves the IDs given to XREAD to the concrete IDs it reads after, so the read can be done
+	* again later from the same place, "$" standing for the last ID at the time of the call
+	* @param streamNames []string - the names of the streams
+	* @param ids []string - the ID to read after in each stream
+	* @return []string - the IDs, in ms-seq form
+	* @return error - ErrInvalidStreamIdArgument
+*/
+func (sm *streamManager) xreadPosition(streamNames, ids []string) ([]string, error) {
+	after, err := sm.resolveReadIDs(streamNames, ids)
+	if err != nil {
+		return nil, err
+	}
 
-		current = current.Next()
+	position := make([]string, len(after))
+	for i, id := range after {
+		position[i] = id.String()
 	}
+	return position, nil
+}
+
+/*
+ 	* readStreams reads the entries after the given IDs of the streams
+	* @param streamNames []string - the names of the streams
+	* @param after []streamID - the ID to read after in each stream
+	* @param count int - the most entries to return from each stream, 0 for all of them
+	* @return []StreamEntries - the entries of each stream that has any, in the order the streams were given
+*/
+func (sm *streamManager) readStreams(streamNames []string, after []streamID, count int) []StreamEntries {
+	var result []StreamEntries
+	for i, streamName := range streamNames {
+		stream, exists := sm.getStream(streamName)
+		if !exists {
+			continue
+		}
 
-	return result, nil
+		stream.mu.RLock()
+		records := stream.recordsAfter(after[i], count)
+		stream.mu.RUnlock()
 
+		if len(records) > 0 {
+			result = append(result, StreamEntries{Stream: streamName, Records: records})
+		}
+	}
+	return result
 }
 
 /*
-when a xread with block comes a new subscriber is added to the map and then it first reads from the id specified and then waits for new incoming , when a another xadd happens during that time, the notifySubscribers is basically calling all the subscribers in the map(this calling is basically a way of just saying that a new has arrived and not what has arrived) this way the blocking subsribers in the xreadblock previously will now re-read and thus display the new entry
+ 	* xread gets the entries of streams after the given IDs
+	* @param streamNames []string - the names of the streams
+	* @param ids []string - the ID to read after in each stream, "$" for the last one
+	* @param count int - the most entries to return from each stream, 0 for all of them
+	* @return []StreamEntries - the entries of each stream that has any
+	* @return error - ErrInvalidStreamIdArgument
 */
-func (sm *streamManager) xreadblock(streamName, startId string, blockMs int, noTimeout bool) ([]StreamRecord, error) {
-	if !sm.isStreamKey(streamName) {
-		return nil, ErrInvalidStream
+func (sm *streamManager) xread(streamNames, ids []string, count int) ([]StreamEntries, error) {
+	after, err := sm.resolveReadIDs(streamNames, ids)
+	if err != nil {
+		return nil, err
 	}
+	return sm.readStreams(streamNames, after, count), nil
+}
 
-	if startId == streamIDLast {
-		stream := sm.streams[streamName]
-		stream.mu.RLock()
-		lastElem := stream.recordList.Back()
-		stream.mu.RUnlock()
+/*
+ 	* xreadBlock is xread waiting for entries when there are none yet, until one of the streams gets some,
+	* streams that don't exist yet included, "$" is resolved once so only the entries added while waiting are returned
+	* @param streamNames []string - the names of the streams
+	* @param ids []string - the ID to read after in each stream, "$" for the last one
+	* @param count int - the most entries to return from each stream, 0 for all of them
+	* @param blockMs int - how long to wait for in milliseconds
+	* @param noTimeout bool - wait for as long as it takes
+	* @return []StreamEntries - the entries, nil if the timeout passed
+	* @return error - ErrInvalidStreamIdArgument
+*/
+func (sm *streamManager) xreadBlock(streamNames, ids []string, count int, blockMs int, noTimeout bool) ([]StreamEntries, error) {
+	after, err := sm.resolveReadIDs(streamNames, ids)
+	if err != nil {
+		return nil, err
+	}
 
-		if lastElem != nil {
-			lastRecord := lastElem.Value.(*StreamRecord)
-			startId = lastRecord.Id
-		} else {
+	var result []StreamEntries
+	err = sm.waitForEntries(streamNames, blockMs, noTimeout, nil, func() (bool, error) {
+		result = sm.readStreams(streamNames, after, count)
+		return len(result) > 0, nil
+	})
+	return result, err
+}
+
+/*
+ 	* notifyWaiters wakes the readers blocked on a stream, they read again to find out whether it has what they wait for
+	* @param streamName string - the name of the stream
+*/
+func (sm *streamManager) notifyWaiters(streamName string) {
+	sm.waitersMu.Lock()
+	defer sm.waitersMu.Unlock()
 
-			startId = streamIDMin
+	for ch := range sm.waiters[streamName] {
+		select {
+		case ch <- struct{}{}:
+		default: // missing a notification is okay because the reader reads everything again anyway
 		}
 	}
+}
 
-	stream := sm.streams[streamName]
-
-	notify := stream.subscribe()     // add to map, and get channel
-	defer stream.unsubscribe(notify) // remove from map, and close channel
+/*
+ 	* waitForEntries blocks until read finds something, calling it again whenever an entry is added to one of the streams,
+	* the readers wait on the names rather than on the streams so that a stream created while they wait wakes them too
+	* @param streamNames []string - the names of the streams to wait on
+	* @param blockMs int - how long to wait for in milliseconds
+	* @param noTimeout bool - wait for as long as it takes
+	* @param held sync.Locker - a lock the caller holds around read, let go of while waiting, nil for none
+	* @param read func() (bool, error) - reads from the streams, true once it found something
+	* @return error - the error read returned
+*/
+func (sm *streamManager) waitForEntries(streamNames []string, blockMs int, noTimeout bool, held sync.Locker, read func() (bool, error)) error {
+	notify := make(chan struct{}, 1)
+	sm.waitersMu.Lock()
+	for _, streamName := range streamNames {
+		if sm.waiters[streamName] == nil {
+			sm.waiters[streamName] = make(map[chan struct{}]struct{})
+		}
+		sm.waiters[streamName][notify] = struct{}{}
+	}
+	sm.waitersMu.Unlock()
+
+	defer func() {
+		sm.waitersMu.Lock()
+		for _, streamName := range streamNames {
+			delete(sm.waiters[streamName], notify)
+			if len(sm.waiters[streamName]) == 0 {
+				delete(sm.waiters, streamName)
+			}
+		}
+		sm.waitersMu.Unlock()
+	}()
 
 	var deadline *time.Timer
 	if !noTimeout {
@@ -283,25 +679,29 @@ func (sm *streamManager) xreadblock(streamName, startId string, blockMs int, noT
 	}
 
 	for {
-		records, err := sm.xread(streamName, startId)
-		if err != nil {
-			return nil, err
-		}
-		if len(records) > 0 {
-			return records, nil
+		found, err := read()
+		if err != nil || found {
+			return err
 		}
 
+		if held != nil {
+			held.Unlock()
+		}
+		timedOut := false
 		if noTimeout {
-			<-notify // just wait for notification
-			continue
+			<-notify
+		} else {
+			select {
+			case <-notify:
+			case <-deadline.C:
+				timedOut = true
+			}
 		}
-
-		// block until either notification or timeout
-		select {
-		case <-notify:
-			continue // check for records again
-		case <-deadline.C:
-			return nil, nil // return nil on timeout
+		if held != nil {
+			held.Lock()
+		}
+		if timedOut {
+			return nil
 		}
 	}
 }
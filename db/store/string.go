@@ -0,0 +1,462 @@
+package store
+
+import (
+	"errors"
+	"math"
+	"strconv"
+	"time"
+)
+
+var ErrStringTooLong = errors.New("ERR string exceeds maximum allowed size (proto-max-bulk-len)")
+var ErrValueNotInteger = errors.New("ERR value is not an integer or out of range")
+var ErrValueNotFloat = errors.New("ERR value is not a valid float")
+
+// the longest a string can grow to with APPEND or SETRANGE, Redis' proto-max-bulk-len
+const stringMaxLen = 512 * 1024 * 1024
+
+// SetCondition is the NX or XX option of SET
+type SetCondition int
+
+const (
+	SetAlways SetCondition = iota
+	SetNX                  // only set the key if it doesn't exist
+	SetXX                  // only set the key if it exists
+)
+
+// SetOptions are the options of SET
+type SetOptions struct {
+	Condition SetCondition
+	ExpireAt  time.Time // when the key expires, zero for no expiration
+	KeepTTL   bool      // keep the expiration the key already had
+	Get       bool      // return the old value, failing with ErrWrongType if the key holds something other than a string
+}
+
+/*
+ 	* stringLocked returns the string at key, the key-value write lock must be held
+	* @param key string - the key of the string
+	* @param now time.Time - the current time
+	* @return storedValue - the value holding the string
+	* @return bool - true if the key exists, false otherwise
+	* @return error - ErrWrongType if the key holds something other than a string
+*/
+func (s *Store) stringLocked(key string, now time.Time) (storedValue, bool, error) {
+	stored, exists := s.kv.lookupLocked(key, now)
+	if !exists {
+		if s.streams.isStreamKey(key) {
+			return stored, false, ErrWrongType
+		}
+		return stored, false, nil
+	}
+
+	if stored.object != nil {
+		return stored, false, ErrWrongType
+	}
+
+	return stored, true, nil
+}
+
+/*
+ 	* keyExistsLocked checks if the key exists in either keyspace, the key-value write lock must be held
+	* @param key string - the key to check
+	* @param now time.Time - the current time
+	* @return bool - true if the key exists, false otherwise
+*/
+func (s *Store) keyExistsLocked(key string, now time.Time) bool {
+	if _, exists := s.kv.lookupLocked(key, now); exists {
+		return true
+	}
+	return s.streams.isStreamKey(key)
+}
+
+/*
+ 	* SetWithOptions sets the key to a string, overwriting whatever it held, as the options allow
+	* @param key string - the key to set
+	* @param value []byte - the value
+	* @param options SetOptions - NX/XX, the expiration, KEEPTTL and GET
+	* @return []byte - with Get, the old value
+	* @return bool - with Get, true if the key existed
+	* @return bool - true if the key was set, false if NX or XX prevented it
+	* @return error - with Get, ErrWrongType if the key holds something other than a string
+*/
+func (s *Store) SetWithOptions(key string, value []byte, options SetOptions) ([]byte, bool, bool, error) {
+	s.kv.mu.Lock()
+	defer s.kv.mu.Unlock()
+
+	now := time.Now()
+
+	var oldValue []byte
+	var oldExists bool
+	if options.Get {
+		old, exists, err := s.stringLocked(key, now)
+		if err != nil {
+			return nil, false, false, err
+		}
+		if exists {
+			oldValue, oldExists = s.kv.shareLocked(key, &old), true
+		}
+	}
+
+	switch options.Condition {
+	case SetNX:
+		if s.keyExistsLocked(key, now) {
+			return oldValue, oldExists, false, nil
+		}
+	case SetXX:
+		if !s.keyExistsLocked(key, now) {
+			return oldValue, oldExists, false, nil
+		}
+	}
+
+	stored := storedValue{value: value, expiration: options.ExpireAt}
+	if options.KeepTTL {
+		current, _ := s.kv.lookupLocked(key, now)
+		stored.expiration = current.expiration
+	}
+
+	s.overwriteLocked(key)
+	s.kv.put(key, stored)
+	return oldValue, oldExists, true, nil
+}
+
+/*
+ 	* GetEx returns the string at key, changing its expiration. an expiration that already passed deletes the key
+	* @param key string - the key of the string
+	* @param expireAt time.Time - the new expiration, zero to leave it as it is
+	* @param persist bool - remove the expiration
+	* @return []byte - the value
+	* @return bool - true if the key exists, false otherwise
+	* @return error - ErrWrongType if the key holds something other than a string
+*/
+func (s *Store) GetEx(key string, expireAt time.Time, persist bool) ([]byte, bool, error) {
+	s.kv.mu.Lock()
+	defer s.kv.mu.Unlock()
+
+	now := time.Now()
+	stored, exists, err := s.stringLocked(key, now)
+	if err != nil || !exists {
+		return nil, false, err
+	}
+	value := s.kv.shareLocked(key, &stored)
+
+	switch {
+	case persist:
+		stored.expiration = time.Time{}
+		s.kv.put(key, stored)
+	case !expireAt.IsZero() && !expireAt.After(now):
+		s.kv.remove(key)
+	case !expireAt.IsZero():
+		stored.expiration = expireAt
+		s.kv.put(key, stored)
+	}
+
+	return value, true, nil
+}
+
+/*
+ 	* GetDel returns the string at key and deletes it
+	* @param key string - the key of the string
+	* @return []byte - the value
+	* @return bool - true if the key existed, false otherwise
+	* @return error - ErrWrongType if the key holds something other than a string
+*/
+func (s *Store) GetDel(key string) ([]byte, bool, error) {
+	s.kv.mu.Lock()
+	defer s.kv.mu.Unlock()
+
+	stored, exists, err := s.stringLocked(key, time.Now())
+	if err != nil || !exists {
+		return nil, false, err
+	}
+
+	s.kv.remove(key)
+	return stored.bytes(), true, nil
+}
+
+/*
+ 	* Append appends to the string at key, creating it if it doesn't exist, the expiration is kept
+	* @param key string - the key of the string
+	* @param value []byte - what to append
+	* @return int - the length of the string afterwards
+	* @return error - ErrWrongType or ErrStringTooLong
+*/
+func (s *Store) Append(key string, value []byte) (int, error) {
+	s.kv.mu.Lock()
+	defer s.kv.mu.Unlock()
+
+	stored, _, err := s.stringLocked(key, time.Now())
+	if err != nil {
+		return 0, err
+	}
+
+	current := stored.bytes()
+	if len(current)+len(value) > stringMaxLen {
+		return 0, ErrStringTooLong
+	}
+
+	// a new slice, a snapshot being saved may still hold the old one
+	appended := make([]byte, 0, len(current)+len(value))
+	appended = append(appended, current...)
+	stored.setBytes(append(appended, value...))
+
+	s.kv.put(key, stored)
+	return len(stored.value), nil
+}
+
+/*
+ 	* StrLen returns the length of the string at key
+	* @param key string - the key of the string
+	* @return int - the length, 0 if the key doesn't exist
+	* @return error - ErrWrongType if the key holds something other than a string
+*/
+func (s *Store) StrLen(key string) (int, error) {
+	s.kv.mu.Lock()
+	defer s.kv.mu.Unlock()
+
+	stored, _, err := s.stringLocked(key, time.Now())
+	if err != nil {
+		return 0, err
+	}
+	return len(stored.bytes()), nil
+}
+
+/*
+ 	* GetRange returns the bytes of the string at key from start to end, both inclusive, negative offsets count from the end
+	* @param key string - the key of the string
+	* @param start int - the first offset
+	* @param end int - the last offset
+	* @return []byte - the bytes, empty if the range is out of the string or the key doesn't exist
+	* @return error - ErrWrongType if the key holds something other than a string
+*/
+func (s *Store) GetRange(key string, start, end int) ([]byte, error) {
+	s.kv.mu.Lock()
+	defer s.kv.mu.Unlock()
+
+	stored, _, err := s.stringLocked(key, time.Now())
+	if err != nil {
+		return nil, err
+	}
+
+	value := s.kv.shareLocked(key, &stored)
+	length := len(value)
+	if start < 0 && end < 0 && start > end {
+		return []byte{}, nil
+	}
+	if start < 0 {
+		start = max(length+start, 0)
+	}
+	if end < 0 {
+		end = max(length+end, 0)
+	}
+	end = min(end, length-1)
+	if start > end || length == 0 {
+		return []byte{}, nil
+	}
+
+	return value[start : end+1], nil
+}
+
+/*
+ 	* SetRange overwrites the string at key from offset on, padding it with zero bytes when it is shorter than offset,
+	* the key is created if it doesn't exist and the expiration is kept
+	* @param key string - the key of the string
+	* @param offset int - where to start writing
+	* @param value []byte - what to write
+	* @return int - the length of the string afterwards
+	* @return error - ErrWrongType or ErrStringTooLong
+*/
+func (s *Store) SetRange(key string, offset int, value []byte) (int, error) {
+	s.kv.mu.Lock()
+	defer s.kv.mu.Unlock()
+
+	stored, exists, err := s.stringLocked(key, time.Now())
+	if err != nil {
+		return 0, err
+	}
+
+	current := stored.bytes()
+
+	// writing nothing doesn't create the key nor pad the string
+	if len(value) == 0 {
+		return len(current), nil
+	}
+	if offset+len(value) > stringMaxLen {
+		return 0, ErrStringTooLong
+	}
+
+	updated := make([]byte, max(len(current), offset+len(value)))
+	copy(updated, current)
+	copy(updated[offset:], value)
+
+	if !exists {
+		stored = storedValue{}
+	}
+	stored.setBytes(updated)
+
+	s.kv.put(key, stored)
+	return len(updated), nil
+}
+
+/*
+ 	* MGet returns the strings at keys
+	* @param keys []string - the keys
+	* @return [][]byte - the values, nil for keys that don't exist or don't hold a string
+*/
+func (s *Store) MGet(keys []string) [][]byte {
+	s.kv.mu.Lock()
+	defer s.kv.mu.Unlock()
+
+	now := time.Now()
+	values := make([][]byte, len(keys))
+	for i, key := range keys {
+		stored, exists := s.kv.lookupLocked(key, now)
+		if exists && stored.object == nil {
+			values[i] = s.kv.shareLocked(key, &stored)
+		}
+	}
+	return values
+}
+
+/*
+ 	* MSet sets every key to its value at once, overwriting whatever they held and dropping their expiration
+	* @param keys []string - the keys
+	* @param values [][]byte - the value of each key
+*/
+func (s *Store) MSet(keys []string, values [][]byte) {
+	s.kv.mu.Lock()
+	defer s.kv.mu.Unlock()
+
+	s.msetLocked(keys, values)
+}
+
+/*
+ 	* MSetNX sets every key to its value at once, only if none of them exists
+	* @param keys []string - the keys
+	* @param values [][]byte - the value of each key
+	* @return bool - true if the keys were set, false if any of them exists
+*/
+func (s *Store) MSetNX(keys []string, values [][]byte) bool {
+	s.kv.mu.Lock()
+	defer s.kv.mu.Unlock()
+
+	now := time.Now()
+	for _, key := range keys {
+		if s.keyExistsLocked(key, now) {
+			return false
+		}
+	}
+
+	s.msetLocked(keys, values)
+	return true
+}
+
+func (s *Store) msetLocked(keys []string, values [][]byte) {
+	for i, key := range keys {
+		s.overwriteLocked(key)
+		s.kv.put(key, storedValue{value: values[i]})
+	}
+}
+
+/*
+ 	* IncrBy adds delta to the integer the string at key holds, a missing key counts as 0, the expiration is kept.
+	* the result is kept as an integer, so a counter isn't parsed and formatted again on every increment
+	* @param key string - the key of the string
+	* @param delta int64 - how much to add, negative to subtract
+	* @return int64 - the new value
+	* @return error - ErrValueNotInteger, ErrIncrOverflow or ErrWrongType
+*/
+func (s *Store) IncrBy(key string, delta int64) (int64, error) {
+	s.kv.mu.Lock()
+	defer s.kv.mu.Unlock()
+
+	stored, exists, err := s.stringLocked(key, time.Now())
+	if err != nil {
+		return 0, err
+	}
+
+	var current int64
+	if exists {
+		var ok bool
+		if current, ok = stored.integerValue(); !ok {
+			return 0, ErrValueNotInteger
+		}
+	} else {
+		stored = storedValue{}
+	}
+
+	if (delta > 0 && current > math.MaxInt64-delta) || (delta < 0 && current < math.MinInt64-delta) {
+		return 0, ErrIncrOverflow
+	}
+
+	current += delta
+	stored.value = nil
+	stored.integer = current
+	stored.intEncoded = true
+
+	s.kv.put(key, stored)
+	return current, nil
+}
+
+/*
+ 	* IncrByFloat adds delta to the float the string at key holds, a missing key counts as 0, the expiration is kept
+	* @param key string - the key of the string
+	* @param delta float64 - how much to add, negative to subtract
+	* @return []byte - the new value, as it is stored
+	* @return error - ErrValueNotFloat, ErrIncrNaN or ErrWrongType
+*/
+func (s *Store) IncrByFloat(key string, delta float64) ([]byte, error) {
+	s.kv.mu.Lock()
+	defer s.kv.mu.Unlock()
+
+	stored, exists, err := s.stringLocked(key, time.Now())
+	if err != nil {
+		return nil, err
+	}
+
+	var current float64
+	if exists {
+		if stored.intEncoded {
+			current = float64(stored.integer)
+		} else {
+			current, err = strconv.ParseFloat(string(stored.value), 64)
+			if err != nil || math.IsNaN(current) || math.IsInf(current, 0) {
+				return nil, ErrValueNotFloat
+			}
+		}
+	} else {
+		stored = storedValue{}
+	}
+
+	current += delta
+	if math.IsNaN(current) || math.IsInf(current, 0) {
+		return nil, ErrIncrNaN
+	}
+
+	value := []byte(strconv.FormatFloat(current, 'f', -1, 64))
+	stored.setBytes(value)
+
+	s.kv.put(key, stored)
+	return value, nil
+}
+
+/*
+ 	* integerValue returns the integer the string holds, only a string that reads exactly like the integer counts,
+	* so "007" or "+7" don't and GET keeps returning what was set
+	* @return int64 - the integer
+	* @return bool - true if the string holds an integer, false otherwise
+*/
+func (sv storedValue) integerValue() (int64, bool) {
+	if sv.intEncoded {
+		return sv.integer, true
+	}
+
+	// the longest int64 is 20 characters, with the sign
+	if len(sv.value) == 0 || len(sv.value) > 20 {
+		return 0, false
+	}
+
+	integer, err := strconv.ParseInt(string(sv.value), 10, 64)
+	if err != nil || strconv.FormatInt(integer, 10) != string(sv.value) {
+		return 0, false
+	}
+	return integer, true
+}
@@ -0,0 +1,532 @@
+package store
+
+import (
+	"math"
+	"slices"
+	"time"
+)
+
+// ZAggregate is how ZUNIONSTORE and ZINTERSTORE combine the scores of a member found in several sets
+type ZAggregate int
+
+const (
+	ZAggregateSum ZAggregate = iota
+	ZAggregateMin
+	ZAggregateMax
+)
+
+/*
+ 	* zsetLocked returns the sorted set at key, the key-value write lock must be held
+	* @param key string - the key of the sorted set
+	* @param now time.Time - the current time
+	* @param create bool - create an empty sorted set if the key doesn't exist, it is only stored once a member is added
+	* @return *zset - the sorted set, nil if the key doesn't exist and create is false
+	* @return storedValue - the value holding the sorted set
+	* @return error - ErrWrongType if the key holds something other than a sorted set
+*/
+func (s *Store) zsetLocked(key string, now time.Time, create bool) (*zset, storedValue, error) {
+	stored, exists := s.kv.lookupLocked(key, now)
+	if !exists {
+		if s.streams.isStreamKey(key) {
+			return nil, stored, ErrWrongType
+		}
+		if !create {
+			return nil, stored, nil
+		}
+		stored = storedValue{object: newZSet()}
+	}
+
+	z, ok := stored.object.(*zset)
+	if !ok {
+		return nil, stored, ErrWrongType
+	}
+
+	return z, stored, nil
+}
+
+/*
+ 	* storeZSetLocked stores the sorted set back, or deletes the key once the sorted set is empty
+	* @param key string - the key of the sorted set
+	* @param z *zset - the sorted set
+	* @param stored storedValue - the value holding the sorted set
+*/
+func (s *Store) storeZSetLocked(key string, z *zset, stored storedValue) {
+	if z.len() == 0 {
+		s.kv.remove(key)
+		return
+	}
+	s.kv.put(key, stored)
+}
+
+/*
+ 	* ZAdd adds members to the sorted set or updates their scores, as the flags allow, creating it if it doesn't exist
+	* @param key string - the key of the sorted set
+	* @param members []ZMember - the members with their scores
+	* @param flags ZAddFlags - NX, XX, GT and LT
+	* @return int - the number of members that were added
+	* @return int - the number of members whose score was updated
+	* @return error - ErrWrongType if the key holds something other than a sorted set
+*/
+func (s *Store) ZAdd(key string, members []ZMember, flags ZAddFlags) (int, int, error) {
+	s.kv.mu.Lock()
+	defer s.kv.mu.Unlock()
+
+	z, stored, err := s.zsetLocked(key, time.Now(), true)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	added, updated := 0, 0
+	for _, member := range members {
+		_, result, _ := z.add(member.Member, member.Score, flags, false)
+		switch result {
+		case zaddAdded:
+			added++
+		case zaddUpdated:
+			updated++
+		}
+	}
+
+	s.storeZSetLocked(key, z, stored)
+	return added, updated, nil
+}
+
+/*
+ 	* ZIncrBy adds delta to the score of a member, a missing member starts at 0, as the flags allow
+	* @param key string - the key of the sorted set
+	* @param member string - the member
+	* @param delta float64 - how much to add
+	* @param flags ZAddFlags - NX, XX, GT and LT
+	* @return float64 - the new score
+	* @return bool - false if the flags prevented the change
+	* @return error - ErrZScoreNaN or ErrWrongType
+*/
+func (s *Store) ZIncrBy(key, member string, delta float64, flags ZAddFlags) (float64, bool, error) {
+	s.kv.mu.Lock()
+	defer s.kv.mu.Unlock()
+
+	z, stored, err := s.zsetLocked(key, time.Now(), true)
+	if err != nil {
+		return 0, false, err
+	}
+
+	score, result, err := z.add(member, delta, flags, true)
+	if err != nil {
+		return 0, false, err
+	}
+
+	s.storeZSetLocked(key, z, stored)
+	return score, result != zaddNop, nil
+}
+
+/*
+ 	* ZRem removes members from the sorted set, and the key once it is empty
+	* @param key string - the key of the sorted set
+	* @param members []string - the members
+	* @return int - the number of members that were removed
+	* @return error - ErrWrongType if the key holds something other than a sorted set
+*/
+func (s *Store) ZRem(key string, members []string) (int, error) {
+	s.kv.mu.Lock()
+	defer s.kv.mu.Unlock()
+
+	z, stored, err := s.zsetLocked(key, time.Now(), false)
+	if err != nil || z == nil {
+		return 0, err
+	}
+
+	removed := 0
+	for _, member := range members {
+		if z.remove(member) {
+			removed++
+		}
+	}
+
+	s.storeZSetLocked(key, z, stored)
+	return removed, nil
+}
+
+/*
+ 	* ZCard returns the number of members of the sorted set
+	* @param key string - the key of the sorted set
+	* @return int - the number of members, 0 if the key doesn't exist
+	* @return error - ErrWrongType if the key holds something other than a sorted set
+*/
+func (s *Store) ZCard(key string) (int, error) {
+	s.kv.mu.Lock()
+	defer s.kv.mu.Unlock()
+
+	z, _, err := s.zsetLocked(key, time.Now(), false)
+	if err != nil || z == nil {
+		return 0, err
+	}
+	return z.len(), nil
+}
+
+/*
+ 	* ZScore returns the scores of members
+	* @param key string - the key of the sorted set
+	* @param members []string - the members
+	* @return []float64 - the scores
+	* @return []bool - for each member, true if it is in the set
+	* @return error - ErrWrongType if the key holds something other than a sorted set
+*/
+func (s *Store) ZScore(key string, members []string) ([]float64, []bool, error) {
+	s.kv.mu.Lock()
+	defer s.kv.mu.Unlock()
+
+	scores := make([]float64, len(members))
+	found := make([]bool, len(members))
+
+	z, _, err := s.zsetLocked(key, time.Now(), false)
+	if err != nil || z == nil {
+		return scores, found, err
+	}
+
+	for i, member := range members {
+		scores[i], found[i] = z.dict[member]
+	}
+	return scores, found, nil
+}
+
+/*
+ 	* ZRank returns the position of a member, the lowest score first or the highest when reverse is set
+	* @param key string - the key of the sorted set
+	* @param member string - the member
+	* @param reverse bool - count from the highest score
+	* @return int - the rank, starting at 0
+	* @return float64 - the score of the member
+	* @return bool - false if the key or the member doesn't exist
+	* @return error - ErrWrongType if the key holds something other than a sorted set
+*/
+func (s *Store) ZRank(key, member string, reverse bool) (int, float64, bool, error) {
+	s.kv.mu.Lock()
+	defer s.kv.mu.Unlock()
+
+	z, _, err := s.zsetLocked(key, time.Now(), false)
+	if err != nil || z == nil {
+		return 0, 0, false, err
+	}
+
+	rank, exists := z.rank(member, reverse)
+	return rank, z.dict[member], exists, nil
+}
+
+/*
+ 	* ZRangeByRank returns the members from start to stop, both inclusive, negative indexes count from the end
+	* @param key string - the key of the sorted set
+	* @param start int - the first rank
+	* @param stop int - the last rank
+	* @param reverse bool - rank from the highest score, and return the members highest first
+	* @return []ZMember - the members, empty if the key doesn't exist
+	* @return error - ErrWrongType if the key holds something other than a sorted set
+*/
+func (s *Store) ZRangeByRank(key string, start, stop int, reverse bool) ([]ZMember, error) {
+	s.kv.mu.Lock()
+	defer s.kv.mu.Unlock()
+
+	z, _, err := s.zsetLocked(key, time.Now(), false)
+	if err != nil || z == nil {
+		return []ZMember{}, err
+	}
+
+	length := z.len()
+	if start < 0 {
+		start = max(length+start, 0)
+	}
+	if stop < 0 {
+		stop = length + stop
+	}
+	stop = min(stop, length-1)
+	if start > stop {
+		return []ZMember{}, nil
+	}
+
+	return z.rangeByRank(start, stop, reverse), nil
+}
+
+/*
+ 	* ZRangeByScore returns the members with a score in the range
+	* @param key string - the key of the sorted set
+	* @param r ScoreRange - the range
+	* @param reverse bool - return the members highest first
+	* @param offset int - how many members in the range to skip
+	* @param count int - the most members to return, negative for all of them
+	* @return []ZMember - the members, empty if the key doesn't exist
+	* @return error - ErrWrongType if the key holds something other than a sorted set
+*/
+func (s *Store) ZRangeByScore(key string, r ScoreRange, reverse bool, offset, count int) ([]ZMember, error) {
+	return s.zrangeBySpec(key, r, reverse, offset, count)
+}
+
+/*
+ 	* ZRangeByLex returns the members in the range, comparing the members byte by byte
+	* @param key string - the key of the sorted set
+	* @param r LexRange - the range
+	* @param reverse bool - return the members last first
+	* @param offset int - how many members in the range to skip
+	* @param count int - the most members to return, negative for all of them
+	* @return []ZMember - the members, empty if the key doesn't exist
+	* @return error - ErrWrongType if the key holds something other than a sorted set
+*/
+func (s *Store) ZRangeByLex(key string, r LexRange, reverse bool, offset, count int) ([]ZMember, error) {
+	return s.zrangeBySpec(key, r, reverse, offset, count)
+}
+
+func (s *Store) zrangeBySpec(key string, spec zrangeSpec, reverse bool, offset, count int) ([]ZMember, error) {
+	s.kv.mu.Lock()
+	defer s.kv.mu.Unlock()
+
+	z, _, err := s.zsetLocked(key, time.Now(), false)
+	if err != nil || z == nil {
+		return []ZMember{}, err
+	}
+	return z.rangeBySpec(spec, reverse, offset, count), nil
+}
+
+/*
+ 	* ZCount returns the number of members with a score in the range
+	* @param key string - the key of the sorted set
+	* @param r ScoreRange - the range
+	* @return int - the number of members
+	* @return error - ErrWrongType if the key holds something other than a sorted set
+*/
+func (s *Store) ZCount(key string, r ScoreRange) (int, error) {
+	return s.zcountSpec(key, r)
+}
+
+/*
+ 	* ZLexCount returns the number of members in the range, comparing the members byte by byte
+	* @param key string - the key of the sorted set
+	* @param r LexRange - the range
+	* @return int - the number of members
+	* @return error - ErrWrongType if the key holds something other than a sorted set
+*/
+func (s *Store) ZLexCount(key string, r LexRange) (int, error) {
+	return s.zcountSpec(key, r)
+}
+
+func (s *Store) zcountSpec(key string, spec zrangeSpec) (int, error) {
+	s.kv.mu.Lock()
+	defer s.kv.mu.Unlock()
+
+	z, _, err := s.zsetLocked(key, time.Now(), false)
+	if err != nil || z == nil {
+		return 0, err
+	}
+	return z.count(spec), nil
+}
+
+/*
+ 	* ZPop removes up to count members with the lowest or the highest scores, and the key once it is empty
+	* @param key string - the key of the sorted set
+	* @param count int - the most members to remove
+	* @param highest bool - true for ZPOPMAX, false for ZPOPMIN
+	* @return []ZMember - the members, in the order they were removed
+	* @return error - ErrWrongType if the key holds something other than a sorted set
+*/
+func (s *Store) ZPop(key string, count int, highest bool) ([]ZMember, error) {
+	s.kv.mu.Lock()
+	defer s.kv.mu.Unlock()
+
+	z, stored, err := s.zsetLocked(key, time.Now(), false)
+	if err != nil || z == nil {
+		return []ZMember{}, err
+	}
+
+	popped := z.pop(count, highest)
+
+	s.storeZSetLocked(key, z, stored)
+	return popped, nil
+}
+
+// zsetSource is an input of ZUNIONSTORE and ZINTERSTORE, a sorted set, or a set whose members all score 1
+type zsetSource struct {
+	z  *zset
+	st *set
+}
+
+func (src zsetSource) len() int {
+	if src.z != nil {
+		return src.z.len()
+	}
+	return src.st.len()
+}
+
+func (src zsetSource) score(member string) (float64, bool) {
+	if src.z != nil {
+		score, exists := src.z.dict[member]
+		return score, exists
+	}
+	return 1, src.st.contains(member)
+}
+
+func (src zsetSource) forEach(fn func(member string, score float64)) {
+	if src.z != nil {
+		for member, score := range src.z.dict {
+			fn(member, score)
+		}
+		return
+	}
+	src.st.forEach(func(member string) { fn(member, 1) })
+}
+
+/*
+ 	* zsetSourcesLocked returns the inputs of ZUNIONSTORE and ZINTERSTORE, the key-value write lock must be held
+	* @param keys []string - the keys
+	* @param now time.Time - the current time
+	* @return []zsetSource - the inputs, nil for the keys that don't exist
+	* @return error - ErrWrongType if a key holds something other than a set or a sorted set
+*/
+func (s *Store) zsetSourcesLocked(keys []string, now time.Time) ([]*zsetSource, error) {
+	sources := make([]*zsetSource, len(keys))
+	for i, key := range keys {
+		stored, exists := s.kv.lookupLocked(key, now)
+		if !exists {
+			if s.streams.isStreamKey(key) {
+				return nil, ErrWrongType
+			}
+			continue
+		}
+
+		switch object := stored.object.(type) {
+		case *zset:
+			sources[i] = &zsetSource{z: object}
+		case *set:
+			sources[i] = &zsetSource{st: object}
+		default:
+			return nil, ErrWrongType
+		}
+	}
+	return sources, nil
+}
+
+/*
+ 	* aggregateScores combines two scores of a member, a sum of opposite infinities counts as 0 like in Redis
+	* @param a float64 - the score so far
+	* @param b float64 - the weighted score from one more input
+	* @param aggregate ZAggregate - SUM, MIN or MAX
+	* @return float64 - the combined score
+*/
+func aggregateScores(a, b float64, aggregate ZAggregate) float64 {
+	switch aggregate {
+	case ZAggregateMin:
+		return math.Min(a, b)
+	case ZAggregateMax:
+		return math.Max(a, b)
+	}
+
+	sum := a + b
+	if math.IsNaN(sum) {
+		return 0
+	}
+	return sum
+}
+
+/*
+ 	* weightScore multiplies a score by the weight of its input, 0 times infinity counts as 0 like in Redis
+	* @param score float64 - the score
+	* @param weight float64 - the weight
+	* @return float64 - the weighted score
+*/
+func weightScore(score, weight float64) float64 {
+	weighted := score * weight
+	if math.IsNaN(weighted) {
+		return 0
+	}
+	return weighted
+}
+
+/*
+ 	* ZSetOpStore stores the union or intersection of the sets and sorted sets at keys in dst, overwriting whatever is there.
+	* an empty result deletes dst
+	* @param op SetOperation - SetUnion or SetInter
+	* @param dst string - the key to store the result at
+	* @param keys []string - the keys of the inputs
+	* @param weights []float64 - what the scores of each input are multiplied by
+	* @param aggregate ZAggregate - how the scores of a member in several inputs are combined
+	* @return int - the number of members of the result
+	* @return error - ErrWrongType if any input holds something other than a set or a sorted set
+*/
+func (s *Store) ZSetOpStore(op SetOperation, dst string, keys []string, weights []float64, aggregate ZAggregate) (int, error) {
+	s.kv.mu.Lock()
+	defer s.kv.mu.Unlock()
+
+	sources, err := s.zsetSourcesLocked(keys, time.Now())
+	if err != nil {
+		return 0, err
+	}
+
+	scores := make(map[string]float64)
+
+	switch op {
+	case SetUnion:
+		for i, src := range sources {
+			if src == nil {
+				continue
+			}
+			src.forEach(func(member string, score float64) {
+				weighted := weightScore(score, weights[i])
+				if current, exists := scores[member]; exists {
+					weighted = aggregateScores(current, weighted, aggregate)
+				}
+				scores[member] = weighted
+			})
+		}
+	case SetInter:
+		if slices.Contains(sources, nil) {
+			break
+		}
+
+		// walk the smallest input, the weights go with their inputs so the order is kept in a separate slice
+		order := make([]int, len(sources))
+		for i := range order {
+			order[i] = i
+		}
+		slices.SortFunc(order, func(a, b int) int { return sources[a].len() - sources[b].len() })
+
+		sources[order[0]].forEach(func(member string, score float64) {
+			combined := weightScore(score, weights[order[0]])
+			for _, i := range order[1:] {
+				other, exists := sources[i].score(member)
+				if !exists {
+					return
+				}
+				combined = aggregateScores(combined, weightScore(other, weights[i]), aggregate)
+			}
+			scores[member] = combined
+		})
+	}
+
+	result := newZSet()
+	for member, score := range scores {
+		result.add(member, score, ZAddAlways, false)
+	}
+
+	s.overwriteLocked(dst)
+	s.storeZSetLocked(dst, result, storedValue{object: result})
+	return result.len(), nil
+}
+
+/*
+ 	* RestoreZSet puts a sorted set read from disk back at key
+	* @param key string - the key of the sorted set
+	* @param members []ZMember - the members with their scores
+	* @param expiration time.Duration - the time to live, 0 for none
+*/
+func (s *Store) RestoreZSet(key string, members []ZMember, expiration time.Duration) {
+	s.kv.mu.Lock()
+	defer s.kv.mu.Unlock()
+
+	z := newZSet()
+	for _, member := range members {
+		z.add(member.Member, member.Score, ZAddAlways, false)
+	}
+
+	stored := storedValue{object: z}
+	if expiration > 0 {
+		stored.expiration = time.Now().Add(expiration)
+	}
+
+	s.storeZSetLocked(key, z, stored)
+}
@@ -0,0 +1,267 @@
+package store
+
+import (
+	"errors"
+	"math"
+)
+
+var ErrZScoreNaN = errors.New("ERR resulting score is not a number (NaN)")
+
+// ZAddFlags holds the NX, XX, GT and LT flags of ZADD
+type ZAddFlags int
+
+const ZAddAlways ZAddFlags = 0
+
+const (
+	ZAddNX ZAddFlags = 1 << iota // only add new members, never update
+	ZAddXX                       // only update members, never add
+	ZAddGT                       // only update when the new score is greater, new members are still added
+	ZAddLT                       // only update when the new score is less, new members are still added
+)
+
+// what zset.add did with a member
+const (
+	zaddNop       = iota // the flags prevented the change
+	zaddAdded            // the member is new
+	zaddUpdated          // the score changed
+	zaddUnchanged        // the member already had that score
+)
+
+// ZMember is a member of a sorted set with its score
+type ZMember struct {
+	Member string
+	Score  float64
+}
+
+// zset is a sorted set: the map finds the score of a member, the skiplist keeps the members ordered by score
+type zset struct {
+	dict map[string]float64
+	zsl  *skiplist
+}
+
+func newZSet() *zset {
+	return &zset{
+		dict: make(map[string]float64),
+		zsl:  newSkiplist(),
+	}
+}
+
+func (z *zset) len() int {
+	return len(z.dict)
+}
+
+/*
+ 	* add adds a member or updates its score, as the flags allow
+	* @param member string - the member
+	* @param score float64 - the score, or the increment when incr is set
+	* @param flags ZAddFlags - NX, XX, GT and LT
+	* @param incr bool - add score to the current score instead of replacing it
+	* @return float64 - the score of the member afterwards
+	* @return int - zaddNop, zaddAdded, zaddUpdated or zaddUnchanged
+	* @return error - ErrZScoreNaN if the increment makes the score NaN
+*/
+func (z *zset) add(member string, score float64, flags ZAddFlags, incr bool) (float64, int, error) {
+	current, exists := z.dict[member]
+
+	if !exists {
+		if flags&ZAddXX != 0 {
+			return 0, zaddNop, nil
+		}
+		z.dict[member] = score
+		z.zsl.insert(score, member)
+		return score, zaddAdded, nil
+	}
+
+	if flags&ZAddNX != 0 {
+		return current, zaddNop, nil
+	}
+
+	if incr {
+		score += current
+		if math.IsNaN(score) {
+			return 0, zaddNop, ErrZScoreNaN
+		}
+	}
+
+	if (flags&ZAddGT != 0 && score <= current) || (flags&ZAddLT != 0 && score >= current) {
+		return current, zaddNop, nil
+	}
+	if score == current {
+		return current, zaddUnchanged, nil
+	}
+
+	z.zsl.delete(current, member)
+	z.zsl.insert(score, member)
+	z.dict[member] = score
+	return score, zaddUpdated, nil
+}
+
+/*
+ 	* remove removes a member
+	* @param member string - the member
+	* @return bool - true if the member was there, false otherwise
+*/
+func (z *zset) remove(member string) bool {
+	score, exists := z.dict[member]
+	if !exists {
+		return false
+	}
+
+	delete(z.dict, member)
+	z.zsl.delete(score, member)
+	return true
+}
+
+/*
+ 	* rank returns the position of a member, starting at 0
+	* @param member string - the member
+	* @param reverse bool - count from the highest score instead of the lowest
+	* @return int - the rank
+	* @return bool - true if the member is in the set, false otherwise
+*/
+func (z *zset) rank(member string, reverse bool) (int, bool) {
+	score, exists := z.dict[member]
+	if !exists {
+		return 0, false
+	}
+
+	rank := z.zsl.rank(score, member)
+	if reverse {
+		return z.len() - rank, true
+	}
+	return rank - 1, true
+}
+
+/*
+ 	* rangeByRank returns the members from start to stop, both inclusive, counting from 0
+	* @param start int - the first rank, already clamped to the set
+	* @param stop int - the last rank, already clamped to the set
+	* @param reverse bool - count from the highest score and return the members highest first
+	* @return []ZMember - the members
+*/
+func (z *zset) rangeByRank(start, stop int, reverse bool) []ZMember {
+	members := make([]ZMember, 0, stop-start+1)
+
+	var node *skiplistNode
+	if reverse {
+		node = z.zsl.byRank(z.len() - start)
+	} else {
+		node = z.zsl.byRank(start + 1)
+	}
+
+	for i := start; i <= stop && node != nil; i++ {
+		members = append(members, ZMember{node.member, node.score})
+		if reverse {
+			node = node.backward
+		} else {
+			node = node.level[0].forward
+		}
+	}
+
+	return members
+}
+
+/*
+ 	* rangeBySpec returns the members in a score or lex range
+	* @param spec zrangeSpec - the range
+	* @param reverse bool - return the members highest first
+	* @param offset int - how many members in the range to skip
+	* @param count int - the most members to return, negative for all of them
+	* @return []ZMember - the members
+*/
+func (z *zset) rangeBySpec(spec zrangeSpec, reverse bool, offset, count int) []ZMember {
+	members := []ZMember{}
+	if offset < 0 {
+		return members
+	}
+
+	var node *skiplistNode
+	if reverse {
+		node = z.zsl.lastInRange(spec)
+	} else {
+		node = z.zsl.firstInRange(spec)
+	}
+
+	next := func(node *skiplistNode) *skiplistNode {
+		if reverse {
+			return node.backward
+		}
+		return node.level[0].forward
+	}
+
+	for ; node != nil && offset > 0; offset-- {
+		node = next(node)
+	}
+
+	for ; node != nil && count != 0; node = next(node) {
+		if (reverse && !spec.aboveMin(node)) || (!reverse && !spec.belowMax(node)) {
+			break
+		}
+		members = append(members, ZMember{node.member, node.score})
+		count--
+	}
+
+	return members
+}
+
+/*
+ 	* count returns the number of members in a score or lex range
+	* @param spec zrangeSpec - the range
+	* @return int - the number of members
+*/
+func (z *zset) count(spec zrangeSpec) int {
+	first := z.zsl.firstInRange(spec)
+	if first == nil {
+		return 0
+	}
+	last := z.zsl.lastInRange(spec)
+
+	return z.zsl.rank(last.score, last.member) - z.zsl.rank(first.score, first.member) + 1
+}
+
+/*
+ 	* pop removes up to count members with the lowest or the highest scores
+	* @param count int - the most members to remove
+	* @param highest bool - true for the highest scores, false for the lowest
+	* @return []ZMember - the members, in the order they were removed
+*/
+func (z *zset) pop(count int, highest bool) []ZMember {
+	members := make([]ZMember, 0, min(count, z.len()))
+
+	for len(members) < count && z.len() > 0 {
+		node := z.zsl.header.level[0].forward
+		if highest {
+			node = z.zsl.tail
+		}
+
+		members = append(members, ZMember{node.member, node.score})
+		z.remove(node.member)
+	}
+
+	return members
+}
+
+/*
+ 	* members returns every member, lowest score first
+	* @return []ZMember - the members
+*/
+func (z *zset) members() []ZMember {
+	members := make([]ZMember, 0, z.len())
+	for node := z.zsl.header.level[0].forward; node != nil; node = node.level[0].forward {
+		members = append(members, ZMember{node.member, node.score})
+	}
+	return members
+}
+
+/*
+ 	* clone returns a copy of the sorted set
+	* @return *zset - the copy
+*/
+func (z *zset) clone() *zset {
+	copied := newZSet()
+	for node := z.zsl.header.level[0].forward; node != nil; node = node.level[0].forward {
+		copied.dict[node.member] = node.score
+		copied.zsl.insert(node.score, node.member)
+	}
+	return copied
+}
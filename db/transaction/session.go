@@ -0,0 +1,209 @@
+package transactions
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"time"
+)
+
+// DefaultSessionTTL is how long a session outlives its connection when SESSION CREATE is given no TTL
+const DefaultSessionTTL = 5 * time.Minute
+
+var ErrNoSuchSession = errors.New("ERR no such session, it may have expired")
+var ErrSessionExists = errors.New("ERR this connection already has a session")
+
+// a session carries the transaction, the watches and the blocked read of a client over to its next connection
+type session struct {
+	token        string
+	ttl          time.Duration // how long it is kept once no connection uses it
+	clientID     string        // the connection using it, "" while none does
+	expiry       *time.Timer   // running while no connection uses it
+	readPosition []string      // the stream and ID pairs a blocked XREAD reads after, nil when there is none
+}
+
+// SessionState is what a resumed session recovered
+type SessionState struct {
+	TTL          time.Duration
+	InMulti      bool
+	Queued       int      // the commands queued since MULTI
+	Watched      int      // the keys watched
+	ReadPosition []string // the stream and ID pairs a blocked XREAD was reading after, nil if none was
+}
+
+// sessionKey is the id the transaction and the watches of a session are kept under, instead of the id of its connection
+func sessionKey(token string) string {
+	return "session:" + token
+}
+
+/**
+ * resolve returns the id the transaction and the watches of a client are kept under
+ * @param clientID string - the client id
+ * @return string - the key of its session, or the client id if it has none
+ */
+func (tm *TxManager) resolve(clientID string) string {
+	tm.sessionsMu.Lock()
+	defer tm.sessionsMu.Unlock()
+
+	if s, exists := tm.clientSessions[clientID]; exists {
+		return sessionKey(s.token)
+	}
+	return clientID
+}
+
+/**
+ * HasSession checks if a client's connection belongs to a session
+ * @param clientID string - the client id
+ * @return bool - true if it does
+ */
+func (tm *TxManager) HasSession(clientID string) bool {
+	tm.sessionsMu.Lock()
+	defer tm.sessionsMu.Unlock()
+
+	_, exists := tm.clientSessions[clientID]
+	return exists
+}
+
+/**
+ * CreateSession creates a session for a client, the transaction and the watches it has so far become the session's
+ * @param clientID string - the client id
+ * @param ttl time.Duration - how long the session is kept once the connection is gone
+ * @return string - the token that resumes the session
+ * @return error - ErrSessionExists
+ */
+func (tm *TxManager) CreateSession(clientID string, ttl time.Duration) (string, error) {
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	token := hex.EncodeToString(buf)
+
+	tm.sessionsMu.Lock()
+	defer tm.sessionsMu.Unlock()
+
+	if _, exists := tm.clientSessions[clientID]; exists {
+		return "", ErrSessionExists
+	}
+
+	s := &session{token: token, ttl: ttl, clientID: clientID}
+	tm.sessions[token] = s
+	tm.clientSessions[clientID] = s
+
+	tm.mu.Lock()
+	if tx, exists := tm.txs[clientID]; exists {
+		delete(tm.txs, clientID)
+		tx.clientID = sessionKey(token)
+		tm.txs[sessionKey(token)] = tx
+	}
+	tm.mu.Unlock()
+	tm.clientWatches.move(clientID, sessionKey(token))
+
+	return token, nil
+}
+
+/**
+ * ResumeSession makes a client's connection take over a session, dropping the transaction and the watches
+ * the connection had of its own, the connection that used the session before, if still open, loses it
+ * @param clientID string - the client id
+ * @param token string - the token CreateSession returned
+ * @return SessionState - what the session holds
+ * @return error - ErrNoSuchSession
+ */
+func (tm *TxManager) ResumeSession(clientID, token string) (SessionState, error) {
+	tm.sessionsMu.Lock()
+	defer tm.sessionsMu.Unlock()
+
+	s, exists := tm.sessions[token]
+	if !exists {
+		return SessionState{}, ErrNoSuchSession
+	}
+
+	if current, exists := tm.clientSessions[clientID]; exists {
+		if current != s {
+			tm.detachLocked(current)
+		}
+	} else {
+		tm.forget(clientID)
+	}
+
+	if s.clientID != "" && s.clientID != clientID {
+		delete(tm.clientSessions, s.clientID)
+	}
+	if s.expiry != nil {
+		s.expiry.Stop()
+		s.expiry = nil
+	}
+	s.clientID = clientID
+	tm.clientSessions[clientID] = s
+
+	state := SessionState{TTL: s.ttl, ReadPosition: s.readPosition}
+
+	tm.mu.RLock()
+	if tx, exists := tm.txs[sessionKey(token)]; exists && tx.state == txStateStarted {
+		state.InMulti = true
+		state.Queued = len(tx.queuedCommands)
+	}
+	tm.mu.RUnlock()
+	state.Watched = tm.clientWatches.count(sessionKey(token))
+
+	return state, nil
+}
+
+/**
+ * SetReadPosition records where the blocked XREAD of a client reads from, so it can be read again from there
+ * if the connection is lost, nothing is recorded for a client without a session
+ * @param clientID string - the client id
+ * @param position []string - the stream and ID pairs, nil once the read replied
+ */
+func (tm *TxManager) SetReadPosition(clientID string, position []string) {
+	tm.sessionsMu.Lock()
+	defer tm.sessionsMu.Unlock()
+
+	if s, exists := tm.clientSessions[clientID]; exists {
+		s.readPosition = position
+	}
+}
+
+/**
+ * Disconnect lets go of a client whose connection is closed, its session, if it has one, is kept for its TTL
+ * and what it had of its own is dropped
+ * @param clientID string - the client id
+ */
+func (tm *TxManager) Disconnect(clientID string) {
+	tm.sessionsMu.Lock()
+	defer tm.sessionsMu.Unlock()
+
+	if s, exists := tm.clientSessions[clientID]; exists {
+		tm.detachLocked(s)
+		return
+	}
+	tm.forget(clientID)
+}
+
+// detachLocked leaves a session without a connection and starts counting down its TTL, sessionsMu must be held
+func (tm *TxManager) detachLocked(s *session) {
+	delete(tm.clientSessions, s.clientID)
+	s.clientID = ""
+	s.expiry = time.AfterFunc(s.ttl, func() { tm.expireSession(s) })
+}
+
+// expireSession drops a session whose TTL passed without any connection resuming it
+func (tm *TxManager) expireSession(s *session) {
+	tm.sessionsMu.Lock()
+	defer tm.sessionsMu.Unlock()
+
+	// resumed while the timer was firing
+	if tm.sessions[s.token] != s || s.clientID != "" {
+		return
+	}
+	delete(tm.sessions, s.token)
+	tm.forget(sessionKey(s.token))
+}
+
+// forget drops the transaction and the watches kept under an id
+func (tm *TxManager) forget(id string) {
+	tm.mu.Lock()
+	delete(tm.txs, id)
+	tm.mu.Unlock()
+	tm.clientWatches.unwatch(id)
+}
@@ -0,0 +1,162 @@
+package transactions
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	RESP "github.com/manish-singh-bisht/Redis-From-Scratch/db/resp"
+)
+
+func queueCommand(t *testing.T, tm *TxManager, clientID, cmd string) {
+	t.Helper()
+	message := RESP.RESPMessage{RESPType: RESP.BulkString, RESPLen: len(cmd), RESPValue: []byte(cmd)}
+	if err := tm.Queue(clientID, message, nil); err != nil {
+		t.Fatalf("Queue(%s) = %v", cmd, err)
+	}
+}
+
+func TestResumeSession(t *testing.T) {
+	tests := []struct {
+		name  string
+		setup func(t *testing.T, tm *TxManager, token string) // run by client "old" once the session exists
+		want  SessionState
+	}{
+		{
+			name:  "nothing to carry over",
+			setup: func(t *testing.T, tm *TxManager, token string) { tm.Disconnect("old") },
+			want:  SessionState{TTL: time.Minute},
+		},
+		{
+			name: "queued transaction and watches",
+			setup: func(t *testing.T, tm *TxManager, token string) {
+				tm.Watch("old", "a")
+				tm.Watch("old", "b")
+				if err := tm.Multi("old"); err != nil {
+					t.Fatal(err)
+				}
+				queueCommand(t, tm, "old", "SET")
+				queueCommand(t, tm, "old", "INCR")
+				tm.Disconnect("old")
+			},
+			want: SessionState{TTL: time.Minute, InMulti: true, Queued: 2, Watched: 2},
+		},
+		{
+			name: "blocked read position",
+			setup: func(t *testing.T, tm *TxManager, token string) {
+				tm.SetReadPosition("old", []string{"stream", "1-1"})
+				tm.Disconnect("old")
+			},
+			want: SessionState{TTL: time.Minute, ReadPosition: []string{"stream", "1-1"}},
+		},
+		{
+			name: "taken over from a live connection",
+			setup: func(t *testing.T, tm *TxManager, token string) {
+				if err := tm.Multi("old"); err != nil {
+					t.Fatal(err)
+				}
+			},
+			want: SessionState{TTL: time.Minute, InMulti: true},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			tm := NewTxManager()
+			token, err := tm.CreateSession("old", time.Minute)
+			if err != nil {
+				t.Fatal(err)
+			}
+			test.setup(t, tm, token)
+
+			// whatever the new connection had of its own is dropped
+			if err := tm.Multi("new"); err != nil {
+				t.Fatal(err)
+			}
+			queueCommand(t, tm, "new", "GET")
+
+			state, err := tm.ResumeSession("new", token)
+			if err != nil {
+				t.Fatalf("ResumeSession() = %v", err)
+			}
+
+			if state.TTL != test.want.TTL || state.InMulti != test.want.InMulti || state.Queued != test.want.Queued ||
+				state.Watched != test.want.Watched || len(state.ReadPosition) != len(test.want.ReadPosition) {
+				t.Fatalf("ResumeSession() = %+v, want %+v", state, test.want)
+			}
+			for i := range state.ReadPosition {
+				if state.ReadPosition[i] != test.want.ReadPosition[i] {
+					t.Fatalf("read position %v, want %v", state.ReadPosition, test.want.ReadPosition)
+				}
+			}
+
+			if !tm.HasSession("new") {
+				t.Errorf("the new connection has no session after resuming it")
+			}
+			if tm.HasSession("old") {
+				t.Errorf("the old connection still has the session")
+			}
+			if tm.InMulti("new") != test.want.InMulti {
+				t.Errorf("InMulti(new) = %v, want %v", tm.InMulti("new"), test.want.InMulti)
+			}
+		})
+	}
+}
+
+func TestSessionErrors(t *testing.T) {
+	tm := NewTxManager()
+
+	if _, err := tm.ResumeSession("client", "missing"); !errors.Is(err, ErrNoSuchSession) {
+		t.Errorf("ResumeSession(missing) = %v, want %v", err, ErrNoSuchSession)
+	}
+
+	if _, err := tm.CreateSession("client", time.Minute); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := tm.CreateSession("client", time.Minute); !errors.Is(err, ErrSessionExists) {
+		t.Errorf("a second CreateSession() = %v, want %v", err, ErrSessionExists)
+	}
+}
+
+func TestSessionExpiry(t *testing.T) {
+	const ttl = 20 * time.Millisecond
+
+	t.Run("expires once its connection is gone", func(t *testing.T) {
+		tm := NewTxManager()
+		token, _ := tm.CreateSession("old", ttl)
+		tm.Watch("old", "a")
+		tm.Disconnect("old")
+
+		time.Sleep(5 * ttl)
+		if _, err := tm.ResumeSession("new", token); !errors.Is(err, ErrNoSuchSession) {
+			t.Fatalf("ResumeSession() after the TTL = %v, want %v", err, ErrNoSuchSession)
+		}
+		if count := tm.clientWatches.count(sessionKey(token)); count != 0 {
+			t.Errorf("%d watches left behind by the expired session", count)
+		}
+	})
+
+	t.Run("kept while a connection uses it", func(t *testing.T) {
+		tm := NewTxManager()
+		token, _ := tm.CreateSession("old", ttl)
+
+		time.Sleep(5 * ttl)
+		if _, err := tm.ResumeSession("new", token); err != nil {
+			t.Fatalf("ResumeSession() of a session in use = %v", err)
+		}
+	})
+
+	t.Run("a resume stops the countdown", func(t *testing.T) {
+		tm := NewTxManager()
+		token, _ := tm.CreateSession("old", ttl)
+		tm.Disconnect("old")
+		if _, err := tm.ResumeSession("new", token); err != nil {
+			t.Fatal(err)
+		}
+
+		time.Sleep(5 * ttl)
+		if _, err := tm.ResumeSession("newer", token); err != nil {
+			t.Fatalf("ResumeSession() after resuming before the TTL = %v", err)
+		}
+	})
+}
@@ -37,21 +37,29 @@ func newTx(clientID string) *tx {
 type TxManager struct {
 	mu            sync.RWMutex
 	clientWatches *clientWatches
-	txs           map[string]*tx // clientID->transaction
+	txs           map[string]*tx // clientID->transaction, or session key->transaction for a client with a session
+
+	sessionsMu     sync.Mutex
+	sessions       map[string]*session // token->session
+	clientSessions map[string]*session // clientID->the session its connection took over
 }
 
 func NewTxManager() *TxManager {
 	return &TxManager{
-		clientWatches: NewClientWatches(getGlobalKeyVersions()),
-		txs:           make(map[string]*tx),
+		clientWatches:  NewClientWatches(getGlobalKeyVersions()),
+		txs:            make(map[string]*tx),
+		sessions:       make(map[string]*session),
+		clientSessions: make(map[string]*session),
 	}
 }
 
 func (tm *TxManager) Watch(clientID string, key string) {
+	clientID = tm.resolve(clientID)
 	tm.clientWatches.startWatch(clientID, key)
 }
 
 func (tm *TxManager) Unwatch(clientID string) {
+	clientID = tm.resolve(clientID)
 	tm.clientWatches.unwatch(clientID)
 }
 
@@ -61,6 +69,7 @@ func (tm *TxManager) Unwatch(clientID string) {
  * @return error - the error if there is one
  */
 func (tm *TxManager) Multi(clientID string) error {
+	clientID = tm.resolve(clientID)
 	tm.mu.Lock()
 	defer tm.mu.Unlock()
 
@@ -89,6 +98,7 @@ func (tm *TxManager) Multi(clientID string) error {
  * @return error - the error if there is one
  */
 func (tm *TxManager) Discard(clientID string) error {
+	clientID = tm.resolve(clientID)
 	tm.mu.Lock()
 	defer tm.mu.Unlock()
 
@@ -111,6 +121,7 @@ func (tm *TxManager) Discard(clientID string) error {
  * @return error - the error if there is one
  */
 func (tm *TxManager) Exec(clientID string) ([]commandQueued, error) {
+	clientID = tm.resolve(clientID)
 	tm.mu.Lock()
 	defer tm.mu.Unlock()
 
@@ -134,6 +145,20 @@ func (tm *TxManager) Exec(clientID string) ([]commandQueued, error) {
 	return commands, nil
 }
 
+/**
+ * InMulti checks if a client started a transaction that it hasn't executed or discarded yet
+ * @param clientID string - the client id
+ * @return bool - true if its commands are being queued
+ */
+func (tm *TxManager) InMulti(clientID string) bool {
+	clientID = tm.resolve(clientID)
+	tm.mu.RLock()
+	defer tm.mu.RUnlock()
+
+	tx, exists := tm.txs[clientID]
+	return exists && tx.state == txStateStarted
+}
+
 func (tm *TxManager) GetGlobalKeyVersions(key string) (uint64, bool) {
 	return tm.clientWatches.globalKeyVersions.getGlobalVersion(key)
 }
@@ -142,6 +167,19 @@ func (tm *TxManager) UpdateGlobalKeyVersionsMap(key string) {
 	tm.clientWatches.globalKeyVersions.upsertGlobalVersion(key)
 }
 
+/**
+ * SignalModifiedKey bumps the global version of a key if it is being watched, so transactions watching it abort.
+ * keys nobody watches are left out of the versions map
+ * @param key string - the key that was modified
+ */
+func (tm *TxManager) SignalModifiedKey(key string) {
+	_, exists := tm.GetGlobalKeyVersions(key)
+	if exists {
+		tm.UpdateGlobalKeyVersionsMap(key)
+	}
+}
+
 func (tm *TxManager) Queue(clientID string, cmd RESP.RESPMessage, args []RESP.RESPMessage) error {
+	clientID = tm.resolve(clientID)
 	return tm.queue(clientID, cmd, args)
 }
@@ -53,6 +53,33 @@ func (cw *clientWatches) unwatch(clientID string) {
 	delete(cw.watches, clientID)
 }
 
+/**
+ * move hands the watches of a client over to another id, keeping the versions first seen
+ * @param from string - the id they are kept under
+ * @param to string - the id to keep them under
+ */
+func (cw *clientWatches) move(from string, to string) {
+	cw.mu.Lock()
+	defer cw.mu.Unlock()
+
+	if versions, exists := cw.watches[from]; exists {
+		delete(cw.watches, from)
+		cw.watches[to] = versions
+	}
+}
+
+/**
+ * count returns how many keys a client watches
+ * @param clientID string - the client id
+ * @return int - the number of keys
+ */
+func (cw *clientWatches) count(clientID string) int {
+	cw.mu.RLock()
+	defer cw.mu.RUnlock()
+
+	return len(cw.watches[clientID])
+}
+
 // checkWatches compares local versions with the current global version and if any differences than transaction invalid, CAS
 /**
  * checkWatches checks if the transaction is valid